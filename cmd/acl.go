@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/TierMobility/boring-registry/pkg/module"
+)
+
+var (
+	flagModuleACL []string
+)
+
+func init() {
+	serverCmd.Flags().StringArrayVar(&flagModuleACL, "module-acl", nil, "Restrict read access of a module to comma-separated API keys, e.g. tier/vpc/aws=key1,key2 (can be repeated)")
+}
+
+// parseModuleACL parses the NAMESPACE/NAME/PROVIDER=KEYS pairs of the --module-acl flag.
+func parseModuleACL(entries []string) (module.ACL, error) {
+	acl := make(module.ACL)
+
+	for _, raw := range entries {
+		parts := strings.SplitN(raw, "=", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return nil, usageError{fmt.Errorf("invalid module ACL %q, expected NAMESPACE/NAME/PROVIDER=KEYS", raw)}
+		}
+
+		id := strings.Split(parts[0], "/")
+		if len(id) != 3 || id[0] == "" || id[1] == "" || id[2] == "" {
+			return nil, usageError{fmt.Errorf("invalid module %q in module ACL, expected NAMESPACE/NAME/PROVIDER", parts[0])}
+		}
+
+		acl[parts[0]] = append(acl[parts[0]], splitKeys(parts[1])...)
+	}
+
+	return acl, nil
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/TierMobility/boring-registry/pkg/module"
+)
+
+func TestParseModuleACL(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		entries   []string
+		expected  module.ACL
+		expectErr bool
+	}{
+		{
+			name:     "empty",
+			expected: module.ACL{},
+		},
+		{
+			name:     "merges entries of the same module",
+			entries:  []string{"tier/vpc/aws=key1,key2", "tier/vpc/aws=key3", "tier/dns/aws=key1"},
+			expected: module.ACL{"tier/vpc/aws": {"key1", "key2", "key3"}, "tier/dns/aws": {"key1"}},
+		},
+		{
+			name:      "missing keys",
+			entries:   []string{"tier/vpc/aws="},
+			expectErr: true,
+		},
+		{
+			name:      "incomplete module",
+			entries:   []string{"tier/vpc=key1"},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			acl, err := parseModuleACL(tc.entries)
+			if tc.expectErr {
+				assert.Equal(t, exitCodeUsage, exitCode(err))
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, acl)
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/TierMobility/boring-registry/pkg/module"
+)
+
+var (
+	flagAnomalyExpectedCIDR  string
+	flagAnomalyVersionSpike  int
+	flagAnomalyVersionWindow time.Duration
+	flagAnomalyWebhookURL    string
+)
+
+var anomaliesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "boring_registry_anomalies_total",
+	Help: "Number of detected anomalies by kind.",
+}, []string{"kind"})
+
+func init() {
+	prometheus.MustRegister(anomaliesTotal)
+
+	serverCmd.Flags().StringVar(&flagAnomalyExpectedCIDR, "anomaly-expected-cidr", "", "Comma-separated string of networks downloads are expected from, downloads from other addresses are reported")
+	serverCmd.Flags().IntVar(&flagAnomalyVersionSpike, "anomaly-version-spike", 0, "Report modules with more versions created within --anomaly-version-spike-window, 0 to disable")
+	serverCmd.Flags().DurationVar(&flagAnomalyVersionWindow, "anomaly-version-spike-window", time.Hour, "Window of --anomaly-version-spike")
+	serverCmd.Flags().StringVar(&flagAnomalyWebhookURL, "anomaly-webhook-url", "", "URL anomalies are posted to as JSON, in addition to the boring_registry_anomalies_total metric")
+}
+
+// anomalyMiddleware returns the middleware reporting anomalies, or nil if no heuristic is enabled.
+func anomalyMiddleware() (module.Middleware, error) {
+	cidrs, err := parseCIDRs(splitKeys(flagAnomalyExpectedCIDR))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cidrs) == 0 && flagAnomalyVersionSpike <= 0 {
+		return nil, nil
+	}
+
+	return module.AnomalyMiddleware(reportAnomaly,
+		module.WithAnomalyExpectedCIDRs(cidrs),
+		module.WithAnomalyTrustForwardedFor(flagTrustForwardedFor),
+		module.WithAnomalyVersionSpike(flagAnomalyVersionSpike, flagAnomalyVersionWindow),
+	), nil
+}
+
+// reportAnomaly logs and counts an anomaly and posts it to the webhook without blocking the request.
+func reportAnomaly(anomaly module.Anomaly) {
+	anomaliesTotal.WithLabelValues(anomaly.Kind).Inc()
+
+	_ = level.Warn(logger).Log(
+		"msg", "anomaly detected",
+		"kind", anomaly.Kind,
+		"module", fmt.Sprintf("%s/%s/%s", anomaly.Namespace, anomaly.Name, anomaly.Provider),
+		"detail", anomaly.Detail,
+	)
+
+	if flagAnomalyWebhookURL == "" {
+		return
+	}
+
+	go func() {
+		if err := postJSON(flagAnomalyWebhookURL, anomaly); err != nil {
+			_ = level.Error(logger).Log(
+				"msg", "failed to post anomaly",
+				"kind", anomaly.Kind,
+				"err", err,
+			)
+		}
+	}()
+}
+
+// postJSON posts v as JSON to a webhook.
+func postJSON(url string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		return errors.Errorf("webhook responded with %s", res.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/TierMobility/boring-registry/pkg/module"
+)
+
+func TestPostAnomaly(t *testing.T) {
+	t.Parallel()
+
+	var received module.Anomaly
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+
+		if received.Kind == module.AnomalyVersionSpike {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	anomaly := module.Anomaly{Kind: module.AnomalyUnexpectedCIDR, Namespace: "tier", Name: "vpc", Provider: "aws", ClientIP: "203.0.113.7"}
+	assert.NoError(t, postJSON(server.URL, anomaly))
+	assert.Equal(t, anomaly, received)
+
+	assert.Error(t, postJSON(server.URL, module.Anomaly{Kind: module.AnomalyVersionSpike}))
+}
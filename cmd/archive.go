@@ -9,7 +9,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
-	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -22,42 +22,140 @@ const (
 	moduleSpecFileName = "boring-registry.hcl"
 )
 
-func archiveModules(root string, storage module.Storage) error {
-	var err error
-	if flagRecursive {
-		err = filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
-			if fi.Name() != moduleSpecFileName {
-				return nil
-			}
-			return processModule(path, storage)
-		})
-	} else {
-		err = processModule(filepath.Join(root, moduleSpecFileName), storage)
+// Status of a module after running the upload command.
+const (
+	moduleStatusUploaded = "uploaded"
+	moduleStatusExists   = "exists"
+	moduleStatusSkipped  = "skipped"
+	moduleStatusFailed   = "failed"
+	// moduleStatusTestFailed marks modules that weren't uploaded because the test command failed.
+	moduleStatusTestFailed = "test_failed"
+	// moduleStatusPlanned marks modules that would have been uploaded without --dry-run.
+	moduleStatusPlanned = "planned"
+)
+
+// uploadResult is the machine-readable result of the upload command.
+type uploadResult struct {
+	Modules []moduleResult `json:"modules"`
+	Error   string         `json:"error,omitempty"`
+}
+
+type moduleResult struct {
+	Target      string `json:"target,omitempty"`
+	Path        string `json:"path"`
+	Namespace   string `json:"namespace"`
+	Name        string `json:"name"`
+	Provider    string `json:"provider"`
+	Version     string `json:"version"`
+	Status      string `json:"status"`
+	DownloadURL string `json:"download_url,omitempty"`
+	Error       string `json:"error,omitempty"`
+	// Fields lists the violations of the module address grammar by the module spec.
+	Fields     []module.FieldError `json:"fields,omitempty"`
+	TestOutput string              `json:"test_output,omitempty"`
+}
+
+// walkModules calls fn for the module spec file in root or, if --recursive is set, for every spec file below root.
+func walkModules(root string, fn func(path string) error) error {
+	if !flagRecursive {
+		return fn(filepath.Join(root, moduleSpecFileName))
 	}
-	return err
+
+	return filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if fi.Name() != moduleSpecFileName {
+			return nil
+		}
+		return fn(path)
+	})
 }
 
-func processModule(path string, storage module.Storage) error {
+func archiveModules(root string, storage module.Storage, result *uploadResult) error {
+	err := walkModules(root, func(path string) error {
+		return processModule(path, storage, result)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Test failures don't stop the upload of other modules and are reported together
+	failed := 0
+	for _, m := range result.Modules {
+		if m.Status == moduleStatusTestFailed {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return errors.Wrapf(errModuleTestFailed, "%d modules", failed)
+	}
+
+	return nil
+}
+
+func processModule(path string, storage module.Storage, result *uploadResult) error {
 	spec, err := module.ParseFile(path)
 	if err != nil {
+		res := moduleResult{
+			Path:   path,
+			Status: moduleStatusFailed,
+			Error:  err.Error(),
+		}
+
+		var validationErr *module.ValidationError
+		if errors.As(err, &validationErr) {
+			res.Fields = validationErr.Fields
+		}
+
+		result.Modules = append(result.Modules, res)
 		return err
 	}
 
+	if flagNormalizeAddresses {
+		m := &spec.Metadata
+		m.Namespace, m.Name, m.Provider = module.NormalizeAddress(m.Namespace, m.Name, m.Provider)
+	}
+
 	level.Debug(logger).Log(
 		"msg", "parsed module spec",
 		"path", path,
 		"name", spec.Name(),
 	)
 
+	res := moduleResult{
+		Path:      path,
+		Namespace: spec.Metadata.Namespace,
+		Name:      spec.Metadata.Name,
+		Provider:  spec.Metadata.Provider,
+		Version:   spec.Metadata.Version,
+	}
+
+	status, downloadURL, err := uploadModule(path, spec, storage)
+	res.Status = status
+	res.DownloadURL = downloadURL
+	if err != nil {
+		res.Error = err.Error()
+	}
+
+	var testErr *moduleTestError
+	if errors.As(err, &testErr) {
+		res.TestOutput = testErr.output
+		err = nil
+	}
+
+	result.Modules = append(result.Modules, res)
+
+	return err
+}
+
+func uploadModule(path string, spec *module.Spec, storage module.Storage) (string, string, error) {
 	// Check if the module meets version constraints
 	if versionConstraintsSemver != nil {
 		ok, err := meetsSemverConstraints(spec)
 		if err != nil {
-			return err
+			return moduleStatusFailed, "", err
 		} else if !ok {
 			// Skip the module, as it didn't pass the version constraints
 			level.Info(logger).Log("msg", "module doesn't meet semver version constraints, skipped", "name", spec.Name())
-			return nil
+			return moduleStatusSkipped, "", nil
 		}
 	}
 
@@ -65,37 +163,139 @@ func processModule(path string, storage module.Storage) error {
 		if !meetsRegexConstraints(spec) {
 			// Skip the module, as it didn't pass the regex version constraints
 			level.Info(logger).Log("msg", "module doesn't meet regex version constraints, skipped", "name", spec.Name())
-			return nil
+			return moduleStatusSkipped, "", nil
 		}
 	}
 
-	ctx := context.Background()
-	if res, err := storage.GetModule(ctx, spec.Metadata.Namespace, spec.Metadata.Name, spec.Metadata.Provider, spec.Metadata.Version); err == nil {
+	if changedModuleFiles != nil {
+		changed, err := moduleChanged(filepath.Dir(path), changedModuleFiles)
+		if err != nil {
+			return moduleStatusFailed, "", err
+		} else if !changed {
+			level.Info(logger).Log("msg", "module didn't change, skipped", "name", spec.Name(), "since", flagSince)
+			return moduleStatusSkipped, "", nil
+		}
+	}
+
+	var (
+		ctx = context.Background()
+		b   = backoff{retries: flagRetries, initial: flagRetryBackoff, max: flagRetryMaxBackoff}
+		res module.Module
+	)
+
+	err := b.retry(ctx, "GetModule", func() (err error) {
+		res, err = storage.GetModule(ctx, spec.Metadata.Namespace, spec.Metadata.Name, spec.Metadata.Provider, spec.Metadata.Version)
+		return err
+	})
+
+	moduleRoot := filepath.Dir(path)
+
+	switch {
+	case err == nil:
+		if flagSkipIdentical && res.Digest != "" {
+			buf, err := archiveModule(moduleRoot)
+			if err != nil {
+				return moduleStatusFailed, "", err
+			}
+
+			if module.ArchiveDigest(buf.Bytes()) == res.Digest {
+				level.Info(logger).Log(
+					"msg", "module already exists with identical content",
+					"download_url", res.DownloadURL,
+				)
+				return moduleStatusExists, res.DownloadURL, nil
+			}
+
+			level.Warn(logger).Log(
+				"msg", "module already exists with different content",
+				"name", spec.Name(),
+				"digest", res.Digest,
+			)
+		}
+
 		if flagIgnoreExistingModule {
 			level.Info(logger).Log(
 				"msg", "module already exists",
 				"download_url", res.DownloadURL,
 			)
-			return nil
-		} else {
+			return moduleStatusExists, res.DownloadURL, nil
+		}
+
+		level.Error(logger).Log(
+			"msg", "module already exists",
+			"download_url", res.DownloadURL,
+		)
+		return moduleStatusExists, res.DownloadURL, errors.Wrap(module.ErrAlreadyExists, spec.Name())
+	case !errors.Is(err, module.ErrNotFound):
+		return moduleStatusFailed, "", err
+	}
+
+	if flagTestCommand != "" {
+		if err := testModule(ctx, moduleRoot); err != nil {
 			level.Error(logger).Log(
-				"msg", "module already exists",
-				"download_url", res.DownloadURL,
+				"msg", "module tests failed, skipped",
+				"name", spec.Name(),
+				"err", err,
 			)
-			return errors.New("module already exists")
+			return moduleStatusTestFailed, "", err
 		}
 	}
 
-	moduleRoot := filepath.Dir(path)
-
 	buf, err := archiveModule(moduleRoot)
 	if err != nil {
-		return err
+		return moduleStatusFailed, "", err
 	}
 
-	res, err := storage.UploadModule(ctx, spec.Metadata.Namespace, spec.Metadata.Name, spec.Metadata.Provider, spec.Metadata.Version, buf)
-	if err != nil {
+	if flagDryRun {
+		level.Info(logger).Log(
+			"msg", "module would be uploaded, skipped by dry run",
+			"name", spec.Name(),
+			"digest", module.ArchiveDigest(buf.Bytes()),
+		)
+		return moduleStatusPlanned, "", nil
+	}
+
+	// The schedule is stored first, so the version is never visible before its publication time
+	if !publishAt.IsZero() {
+		err = b.retry(ctx, "ScheduleModule", func() error {
+			return storage.ScheduleModule(ctx, spec.Metadata.Namespace, spec.Metadata.Name, spec.Metadata.Provider, spec.Metadata.Version, publishAt)
+		})
+		if err != nil {
+			return moduleStatusFailed, "", err
+		}
+	}
+
+	// The archive is read again from the start on every attempt
+	err = b.retry(ctx, "UploadModule", func() (err error) {
+		res, err = storage.UploadModule(ctx, spec.Metadata.Namespace, spec.Metadata.Name, spec.Metadata.Provider, spec.Metadata.Version, bytes.NewReader(buf.Bytes()))
 		return err
+	})
+	if err != nil {
+		return moduleStatusFailed, "", err
+	}
+
+	// Labels of the spec replace the labels of the module, specs without labels keep them
+	if len(spec.Metadata.Labels) > 0 {
+		err = b.retry(ctx, "SetLabels", func() error {
+			return storage.SetLabels(ctx, spec.Metadata.Namespace, spec.Metadata.Name, spec.Metadata.Provider, spec.Metadata.Labels)
+		})
+		if err != nil {
+			return moduleStatusFailed, "", err
+		}
+	}
+
+	if spec.Metadata.Maturity != "" {
+		maturity := module.VersionMaturity{
+			Version:   spec.Metadata.Version,
+			Maturity:  spec.Metadata.Maturity,
+			UpdatedAt: time.Now().UTC(),
+		}
+		err = b.retry(ctx, "SetMaturity", func() error {
+			return storage.SetMaturity(ctx, spec.Metadata.Namespace, spec.Metadata.Name, spec.Metadata.Provider, maturity)
+		})
+		if err != nil {
+			return moduleStatusFailed, "", err
+		}
 	}
 
 	level.Info(logger).Log(
@@ -103,11 +303,16 @@ func processModule(path string, storage module.Storage) error {
 		"download_url", res.DownloadURL,
 	)
 
-	return nil
-
+	return moduleStatusUploaded, res.DownloadURL, nil
 }
 
-func archiveModule(root string) (io.Reader, error) {
+// archiveModuleModTime is the modification time of all files in module archives.
+var archiveModuleModTime = time.Unix(0, 0).UTC()
+
+// archiveModule creates a tar.gz archive of all regular files below root.
+// The archive is reproducible: identical files result in a byte-identical archive
+// independent of modification times, owners, umask and the operating system.
+func archiveModule(root string) (*bytes.Buffer, error) {
 	buf := new(bytes.Buffer)
 	// ensure the src actually exists before trying to tar it
 	if _, err := os.Stat(root); err != nil {
@@ -115,11 +320,9 @@ func archiveModule(root string) (io.Reader, error) {
 	}
 
 	gw := gzip.NewWriter(buf)
-	defer gw.Close()
-
 	tw := tar.NewWriter(gw)
-	defer tw.Close()
 
+	// filepath.Walk visits files in lexical order, which keeps the order of files stable
 	err := filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
 		// return on any error
 		if err != nil {
@@ -131,14 +334,24 @@ func archiveModule(root string) (io.Reader, error) {
 			return nil
 		}
 
-		// create a new dir/file header
-		header, err := tar.FileInfoHeader(fi, fi.Name())
+		name, err := filepath.Rel(root, path)
 		if err != nil {
 			return err
 		}
 
-		// update the name to correctly reflect the desired destination when untaring
-		header.Name = strings.TrimPrefix(strings.Replace(path, root, "", -1), string(filepath.Separator))
+		// Only keep the executable bit, everything else depends on the umask of the user
+		mode := int64(0644)
+		if fi.Mode()&0111 != 0 {
+			mode = 0755
+		}
+
+		header := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     filepath.ToSlash(name),
+			Size:     fi.Size(),
+			Mode:     mode,
+			ModTime:  archiveModuleModTime,
+		}
 
 		if err := tw.WriteHeader(header); err != nil {
 			return err
@@ -150,6 +363,7 @@ func archiveModule(root string) (io.Reader, error) {
 		}
 
 		if _, err := io.Copy(tw, data); err != nil {
+			data.Close()
 			return err
 		}
 
@@ -159,8 +373,15 @@ func archiveModule(root string) (io.Reader, error) {
 
 		return nil
 	})
+	if err != nil {
+		return buf, err
+	}
+
+	if err := tw.Close(); err != nil {
+		return buf, err
+	}
 
-	return buf, err
+	return buf, gw.Close()
 }
 
 // meetsSemverConstraints checks whether a module version matches the semver version constraints.
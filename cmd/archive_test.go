@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/TierMobility/boring-registry/pkg/module"
+)
+
+func TestArchiveModule_Reproducible(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	var digests []string
+	for i, mode := range []os.FileMode{0600, 0664} {
+		dir, err := ioutil.TempDir("", "boring-registry-archive")
+		assert.NoError(err)
+		defer os.RemoveAll(dir)
+
+		assert.NoError(os.MkdirAll(filepath.Join(dir, "modules", "nested"), 0755))
+		files := map[string]string{
+			"main.tf":                     "# main\n",
+			"variables.tf":                "# variables\n",
+			"modules/nested/main.tf":      "# nested\n",
+			"modules/nested/variables.tf": "# nested variables\n",
+		}
+		for name, content := range files {
+			path := filepath.Join(dir, filepath.FromSlash(name))
+			assert.NoError(ioutil.WriteFile(path, []byte(content), mode))
+			mtime := time.Now().Add(time.Duration(i) * time.Hour)
+			assert.NoError(os.Chtimes(path, mtime, mtime))
+		}
+
+		buf, err := archiveModule(dir)
+		assert.NoError(err)
+		digests = append(digests, module.ArchiveDigest(buf.Bytes()))
+
+		gr, err := gzip.NewReader(buf)
+		assert.NoError(err)
+		tr := tar.NewReader(gr)
+
+		var names []string
+		for {
+			header, err := tr.Next()
+			if err != nil {
+				break
+			}
+			names = append(names, header.Name)
+			assert.Equal(int64(0644), header.Mode)
+			assert.True(header.ModTime.Equal(archiveModuleModTime))
+		}
+		assert.Equal([]string{"main.tf", "modules/nested/main.tf", "modules/nested/variables.tf", "variables.tf"}, names)
+	}
+
+	assert.Equal(digests[0], digests[1])
+}
+
+func TestUploadModule_SkipIdentical(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "boring-registry-identical")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	specFile := filepath.Join(dir, moduleSpecFileName)
+	assert.NoError(ioutil.WriteFile(specFile, []byte("metadata {\n  namespace = \"tier\"\n  name = \"test\"\n  provider = \"dummy\"\n  version = \"1.0.0\"\n}\n"), 0644))
+	assert.NoError(ioutil.WriteFile(filepath.Join(dir, "main.tf"), []byte("# test\n"), 0644))
+
+	spec, err := module.ParseFile(specFile)
+	assert.NoError(err)
+
+	defer func(ignoreExisting, skipIdentical bool) {
+		flagIgnoreExistingModule, flagSkipIdentical = ignoreExisting, skipIdentical
+	}(flagIgnoreExistingModule, flagSkipIdentical)
+	flagIgnoreExistingModule, flagSkipIdentical = false, true
+
+	storage := module.NewInmemStorage()
+
+	status, _, err := uploadModule(specFile, spec, storage)
+	assert.NoError(err)
+	assert.Equal(moduleStatusUploaded, status)
+
+	// Uploading identical content again is skipped
+	status, _, err = uploadModule(specFile, spec, storage)
+	assert.NoError(err)
+	assert.Equal(moduleStatusExists, status)
+
+	// Different content for the same version is a conflict
+	assert.NoError(ioutil.WriteFile(filepath.Join(dir, "main.tf"), []byte("# changed\n"), 0644))
+	status, _, err = uploadModule(specFile, spec, storage)
+	assert.True(errors.Is(err, module.ErrAlreadyExists))
+	assert.Equal(moduleStatusExists, status)
+
+	// Without skipping identical content every existing version is a conflict
+	flagSkipIdentical = false
+	assert.NoError(ioutil.WriteFile(filepath.Join(dir, "main.tf"), []byte("# test\n"), 0644))
+	_, _, err = uploadModule(specFile, spec, storage)
+	assert.True(errors.Is(err, module.ErrAlreadyExists))
+}
+
+func TestUploadModule_DryRun(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "boring-registry-dry-run")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	specFile := filepath.Join(dir, moduleSpecFileName)
+	assert.NoError(ioutil.WriteFile(specFile, []byte("metadata {\n  namespace = \"tier\"\n  name = \"test\"\n  provider = \"dummy\"\n  version = \"1.0.0\"\n}\n"), 0644))
+	assert.NoError(ioutil.WriteFile(filepath.Join(dir, "main.tf"), []byte("# test\n"), 0644))
+
+	spec, err := module.ParseFile(specFile)
+	assert.NoError(err)
+
+	defer func(dryRun bool) { flagDryRun = dryRun }(flagDryRun)
+	flagDryRun = true
+
+	storage := module.NewInmemStorage()
+
+	status, _, err := uploadModule(specFile, spec, storage)
+	assert.NoError(err)
+	assert.Equal(moduleStatusPlanned, status)
+
+	_, err = storage.GetModule(context.Background(), "tier", "test", "dummy", "1.0.0")
+	assert.True(errors.Is(err, module.ErrNotFound))
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/go-kit/kit/log/level"
+
+	"github.com/TierMobility/boring-registry/pkg/module"
+)
+
+var (
+	flagAuditLog        bool
+	flagAuditLogFile    string
+	flagAuditWebhookURL string
+)
+
+func init() {
+	serverCmd.Flags().BoolVar(&flagAuditLog, "audit-log", false, "Record who uploaded and downloaded which module version in the server log")
+	serverCmd.Flags().StringVar(&flagAuditLogFile, "audit-log-file", "", "File audit events are appended to as JSON lines")
+	serverCmd.Flags().StringVar(&flagAuditWebhookURL, "audit-webhook-url", "", "URL audit events are posted to as JSON")
+}
+
+// auditMiddleware returns the middleware recording uploads and downloads, or nil if the audit log is disabled.
+func auditMiddleware() (module.Middleware, error) {
+	if !flagAuditLog && flagAuditLogFile == "" && flagAuditWebhookURL == "" {
+		return nil, nil
+	}
+
+	l := &auditLog{log: flagAuditLog, webhookURL: flagAuditWebhookURL}
+
+	if flagAuditLogFile != "" {
+		f, err := os.OpenFile(flagAuditLogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, usageError{fmt.Errorf("invalid --audit-log-file: %w", err)}
+		}
+		l.w = f
+	}
+
+	return module.AuditMiddleware(l.record, module.WithAuditTrustForwardedFor(flagTrustForwardedFor)), nil
+}
+
+// auditLog ships audit events to the server log, a writer of JSON lines and a webhook.
+type auditLog struct {
+	log        bool
+	webhookURL string
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// record writes an audit event and posts it to the webhook without blocking the request.
+// Audit events which can't be written are logged as errors, as the upload or download already happened.
+func (l *auditLog) record(event module.AuditEvent) {
+	if l.log {
+		_ = level.Info(logger).Log(
+			"msg", "audit",
+			"action", event.Action,
+			"module", fmt.Sprintf("%s/%s/%s", event.Namespace, event.Name, event.Provider),
+			"version", event.Version,
+			"identity", event.Identity,
+			"client_ip", event.ClientIP,
+		)
+	}
+
+	if l.w != nil {
+		if err := l.write(event); err != nil {
+			_ = level.Error(logger).Log(
+				"msg", "failed to write audit event",
+				"action", event.Action,
+				"err", err,
+			)
+		}
+	}
+
+	if l.webhookURL == "" {
+		return
+	}
+
+	go func() {
+		if err := postJSON(l.webhookURL, event); err != nil {
+			_ = level.Error(logger).Log(
+				"msg", "failed to post audit event",
+				"action", event.Action,
+				"err", err,
+			)
+		}
+	}()
+}
+
+func (l *auditLog) write(event module.AuditEvent) error {
+	b, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	_, err = l.w.Write(append(b, '\n'))
+	return err
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/TierMobility/boring-registry/pkg/module"
+)
+
+func TestAuditLog(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	received := make(chan module.AuditEvent, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event module.AuditEvent
+		assert.NoError(json.NewDecoder(r.Body).Decode(&event))
+		received <- event
+	}))
+	defer server.Close()
+
+	buf := new(bytes.Buffer)
+	l := &auditLog{w: buf, webhookURL: server.URL}
+
+	event := module.AuditEvent{
+		Time:      time.Date(2024, 5, 13, 9, 0, 0, 0, time.UTC),
+		Action:    module.AuditActionDownload,
+		Namespace: "tier",
+		Name:      "vpc",
+		Provider:  "aws",
+		Version:   "1.0.0",
+		Identity:  "sub:ci@example.com",
+		ClientIP:  "203.0.113.7",
+	}
+	l.record(event)
+	l.record(event)
+
+	line := `{"time":"2024-05-13T09:00:00Z","action":"download","namespace":"tier","name":"vpc","provider":"aws","version":"1.0.0","identity":"sub:ci@example.com","client_ip":"203.0.113.7"}` + "\n"
+	assert.Equal(line+line, buf.String())
+
+	select {
+	case e := <-received:
+		assert.Equal(event, e)
+	case <-time.After(5 * time.Second):
+		t.Fatal("audit event wasn't posted")
+	}
+}
@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/pkg/errors"
+
+	"github.com/TierMobility/boring-registry/pkg/auth"
+)
+
+// Routes of the server, which can require different authenticators.
+const (
+	authRouteModules   = "modules"
+	authRouteProviders = "providers"
+	authRouteMirror    = "mirror"
+)
+
+// Built-in authenticators, custom authenticators are compiled in with auth.Register.
+const (
+	authenticatorAPIKey      = "api-key"
+	authenticatorMTLS        = "mtls"
+	authenticatorSPIFFE      = "spiffe"
+	authenticatorOIDC        = "oidc"
+	authenticatorOIDCBrowser = "oidc-browser"
+	authenticatorOkta        = "okta"
+)
+
+var (
+	flagAuthRoutes           []string
+	flagTLSClientCAFile      string
+	flagTLSClientCommonNames string
+)
+
+// authRoutes are the authenticators of the routes of --auth-route, other routes accept all configured authenticators.
+var authRoutes map[string][]string
+
+func init() {
+	serverCmd.Flags().StringArrayVar(&flagAuthRoutes, "auth-route", nil, "Comma-separated authenticators accepted by a route in the order they are tried, e.g. mirror=mtls,api-key (can be repeated)")
+	serverCmd.Flags().StringVar(&flagTLSClientCAFile, "tls-client-ca-file", "", "CA certificates to verify client certificates with, enables the mtls authenticator")
+	serverCmd.Flags().StringVar(&flagTLSClientCommonNames, "tls-client-common-names", "", "Comma-separated common names of the client certificates accepted by the mtls authenticator (default all verified certificates)")
+}
+
+// setupAuth validates the authenticators of --auth-route, it has to be called after setupOIDC and setupOkta.
+func setupAuth() error {
+	authRoutes = make(map[string][]string)
+
+	available := make(map[string]bool)
+	for _, name := range availableAuthenticators(splitKeys(flagAPIKey)) {
+		available[name] = true
+	}
+
+	for _, raw := range flagAuthRoutes {
+		parts := strings.SplitN(raw, "=", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return usageError{fmt.Errorf("invalid --auth-route %q, expected ROUTE=AUTHENTICATORS", raw)}
+		}
+
+		route := parts[0]
+		switch route {
+		case authRouteModules, authRouteProviders, authRouteMirror:
+		default:
+			return usageError{fmt.Errorf("unknown route %q of --auth-route, expected %s, %s or %s", route, authRouteModules, authRouteProviders, authRouteMirror)}
+		}
+
+		for _, name := range splitKeys(parts[1]) {
+			if !available[name] {
+				return usageError{fmt.Errorf("authenticator %q of --auth-route %s isn't configured", name, route)}
+			}
+			authRoutes[route] = append(authRoutes[route], name)
+		}
+	}
+
+	return nil
+}
+
+// availableAuthenticators returns the names of the configured authenticators in the order they are tried by default.
+// Local checks like static keys, client certificates and JWTs are tried before the introspection endpoint of Okta,
+// custom authenticators are tried last.
+func availableAuthenticators(apiKeys []string) []string {
+	var names []string
+
+	if len(apiKeys) > 0 {
+		names = append(names, authenticatorAPIKey)
+	}
+	if flagTLSClientCAFile != "" {
+		names = append(names, authenticatorMTLS)
+	}
+	if flagSPIFFETrustDomain != "" {
+		names = append(names, authenticatorSPIFFE)
+	}
+	if oidcVerifier != nil {
+		names = append(names, authenticatorOIDC)
+	}
+	if oidcBrowserVerifier != nil {
+		names = append(names, authenticatorOIDCBrowser)
+	}
+	if oktaVerifier != nil {
+		names = append(names, authenticatorOkta)
+	}
+
+	return append(names, auth.RegisteredNames()...)
+}
+
+// authMiddleware authenticates the requests of a route with the authenticators of --auth-route,
+// or with all configured authenticators. Without any authenticator requests aren't authenticated.
+func authMiddleware(route string, apiKeys []string) endpoint.Middleware {
+	names, ok := authRoutes[route]
+	if !ok {
+		names = availableAuthenticators(apiKeys)
+	}
+
+	var authenticators []auth.Authenticator
+	for _, name := range names {
+		switch name {
+		case authenticatorAPIKey:
+			authenticators = append(authenticators, auth.KeyAuthenticator(apiKeys...))
+		case authenticatorMTLS:
+			authenticators = append(authenticators, auth.ClientCertAuthenticator(splitKeys(flagTLSClientCommonNames)...))
+		case authenticatorSPIFFE:
+			authenticators = append(authenticators, auth.SPIFFEAuthenticator(flagSPIFFETrustDomain))
+		case authenticatorOIDC:
+			authenticators = append(authenticators, auth.TokenAuthenticator(oidcVerifier))
+		case authenticatorOIDCBrowser:
+			authenticators = append(authenticators, auth.ReadOnlyAuthenticator(auth.TokenAuthenticator(oidcBrowserVerifier)))
+		case authenticatorOkta:
+			authenticators = append(authenticators, auth.TokenAuthenticator(oktaVerifier))
+		default:
+			if authenticator, ok := auth.Registered(name); ok {
+				authenticators = append(authenticators, authenticator)
+			}
+		}
+	}
+
+	return auth.ChainMiddleware(authenticators...)
+}
+
+// clientTLSConfig returns the TLS configuration verifying client certificates with --tls-client-ca-file, or nil without CAs.
+// Client certificates are optional on the TLS level, so clients of other authenticators can still connect.
+func clientTLSConfig() (*tls.Config, error) {
+	if flagTLSClientCAFile == "" {
+		if flagTLSClientCommonNames != "" || flagSPIFFETrustDomain != "" {
+			return nil, usageError{errors.New("--tls-client-common-names and --spiffe-trust-domain require --tls-client-ca-file")}
+		}
+		return nil, nil
+	}
+
+	if flagTLSCertFile == "" || flagTLSKeyFile == "" {
+		return nil, usageError{errors.New("--tls-client-ca-file requires --tls-cert-file and --tls-key-file")}
+	}
+
+	b, err := ioutil.ReadFile(flagTLSClientCAFile)
+	if err != nil {
+		return nil, usageError{err}
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, usageError{fmt.Errorf("no certificates in %s", flagTLSClientCAFile)}
+	}
+
+	return &tls.Config{
+		ClientCAs:  pool,
+		ClientAuth: tls.VerifyClientCertIfGiven,
+	}, nil
+}
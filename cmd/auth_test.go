@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/TierMobility/boring-registry/pkg/auth"
+)
+
+func TestAuthRoutes(t *testing.T) {
+	defer func(routes []string, apiKey, clientCAFile string) {
+		flagAuthRoutes, flagAPIKey, flagTLSClientCAFile, authRoutes = routes, apiKey, clientCAFile, nil
+	}(flagAuthRoutes, flagAPIKey, flagTLSClientCAFile)
+
+	flagAPIKey = "secret"
+	flagTLSClientCAFile = "ca.crt"
+
+	testCases := []struct {
+		name          string
+		routes        []string
+		expectedError bool
+	}{
+		{name: "default"},
+		{name: "restricted routes", routes: []string{"mirror=mtls", "modules=api-key,mtls"}},
+		{name: "unknown route", routes: []string{"admin=api-key"}, expectedError: true},
+		{name: "unconfigured authenticator", routes: []string{"mirror=oidc"}, expectedError: true},
+		{name: "missing authenticators", routes: []string{"mirror="}, expectedError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			flagAuthRoutes = tc.routes
+
+			err := setupAuth()
+			if tc.expectedError {
+				assert.Equal(t, exitCodeUsage, exitCode(err))
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	flagAuthRoutes = []string{"mirror=mtls"}
+	assert.NoError(t, setupAuth())
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{}}}}
+	clientCert := auth.PopulateRequestContext(context.Background(), r)
+	apiKey := context.WithValue(context.Background(), httptransport.ContextKeyRequestAuthorization, "Bearer secret")
+
+	authenticate := func(route string, ctx context.Context) error {
+		_, err := authMiddleware(route, splitKeys(flagAPIKey))(func(ctx context.Context, request interface{}) (interface{}, error) {
+			return nil, nil
+		})(ctx, nil)
+		return err
+	}
+
+	// Routes without --auth-route accept all configured authenticators
+	assert.NoError(t, authenticate(authRouteModules, apiKey))
+	assert.NoError(t, authenticate(authRouteModules, clientCert))
+	assert.Equal(t, auth.ErrInvalidKey, authenticate(authRouteModules, context.Background()))
+
+	assert.Equal(t, auth.ErrInvalidKey, authenticate(authRouteMirror, apiKey))
+	assert.NoError(t, authenticate(authRouteMirror, clientCert))
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/pkg/errors"
+
+	"github.com/TierMobility/boring-registry/pkg/auth"
+	"github.com/TierMobility/boring-registry/pkg/module"
+	"github.com/TierMobility/boring-registry/pkg/provider"
+)
+
+var (
+	flagAuthzURL      string
+	flagAuthzCacheTTL time.Duration
+	flagAuthzTimeout  time.Duration
+)
+
+func init() {
+	serverCmd.Flags().StringVar(&flagAuthzURL, "authz-url", "", "Endpoint authorizing requests to modules and providers, e.g. http://localhost:8181/v1/data/registry/allow of an Open Policy Agent")
+	serverCmd.Flags().DurationVar(&flagAuthzCacheTTL, "authz-cache-ttl", time.Minute, "How long decisions of --authz-url are cached, 0 disables the cache")
+	serverCmd.Flags().DurationVar(&flagAuthzTimeout, "authz-timeout", 5*time.Second, "Timeout of requests to --authz-url")
+}
+
+// setupAuthorizer returns the authorizer of --authz-url, or nil if requests aren't authorized externally.
+func setupAuthorizer() (auth.Authorizer, error) {
+	if flagAuthzURL == "" {
+		return nil, nil
+	}
+
+	if u, err := url.Parse(flagAuthzURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, usageError{errors.Errorf("invalid --authz-url %q, expected an http or https URL", flagAuthzURL)}
+	}
+	if flagAuthzCacheTTL < 0 {
+		return nil, usageError{errors.New("--authz-cache-ttl must not be negative")}
+	}
+
+	return auth.NewHTTPAuthorizer(
+		flagAuthzURL,
+		&http.Client{Timeout: flagAuthzTimeout},
+		auth.WithAuthorizationCacheTTL(flagAuthzCacheTTL),
+		auth.WithAuthorizationCacheReport(func(event auth.CacheEvent) {
+			countCacheEvent(cacheAuthz, entityDecisions, string(event))
+		}),
+	), nil
+}
+
+// moduleAuthorization returns the middleware authorizing requests to modules, which passes all requests without authorizer.
+func moduleAuthorization(authorizer auth.Authorizer) endpoint.Middleware {
+	if authorizer == nil {
+		return func(next endpoint.Endpoint) endpoint.Endpoint { return next }
+	}
+	return module.AuthorizationMiddleware(authorizer)
+}
+
+// providerAuthorization returns the middleware authorizing requests to providers, which passes all requests without authorizer.
+func providerAuthorization(authorizer auth.Authorizer) endpoint.Middleware {
+	if authorizer == nil {
+		return func(next endpoint.Endpoint) endpoint.Endpoint { return next }
+	}
+	return provider.AuthorizationMiddleware(authorizer)
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetupAuthorizer(t *testing.T) {
+	defer func(url string, ttl time.Duration) {
+		flagAuthzURL, flagAuthzCacheTTL = url, ttl
+	}(flagAuthzURL, flagAuthzCacheTTL)
+
+	testCases := []struct {
+		name          string
+		url           string
+		ttl           time.Duration
+		expectNil     bool
+		expectedError bool
+	}{
+		{name: "disabled", expectNil: true},
+		{name: "opa", url: "http://localhost:8181/v1/data/registry/allow", ttl: time.Minute},
+		{name: "without cache", url: "https://authz.example.com/check"},
+		{name: "invalid url", url: "localhost:8181", ttl: time.Minute, expectedError: true},
+		{name: "negative ttl", url: "http://localhost:8181", ttl: -time.Second, expectedError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			flagAuthzURL, flagAuthzCacheTTL = tc.url, tc.ttl
+
+			authorizer, err := setupAuthorizer()
+			if tc.expectedError {
+				assert.Equal(t, exitCodeUsage, exitCode(err))
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectNil, authorizer == nil)
+		})
+	}
+}
@@ -0,0 +1,331 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/TierMobility/boring-registry/pkg/module"
+)
+
+// Operations generated by the bench command.
+const (
+	benchOpVersions = "versions"
+	benchOpDownload = "download"
+	benchOpPublish  = "publish"
+)
+
+var (
+	flagBenchEndpoint       string
+	flagBenchToken          string
+	flagBenchModules        []string
+	flagBenchConcurrency    int
+	flagBenchDuration       time.Duration
+	flagBenchRequests       int64
+	flagBenchVersionsWeight int
+	flagBenchDownloadWeight int
+	flagBenchPublishWeight  int
+	flagBenchNamespace      string
+)
+
+var benchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Generate synthetic load against a registry",
+	Long: `Generate synthetic load against a registry and report latency percentiles.
+
+Read operations (version lookups and downloads) are sent to the given endpoint for the modules passed with --module.
+Publish operations upload test modules with unique versions to a scratch namespace of the configured
+storage backend and are disabled by default. Published modules are deleted after the run.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		b, err := newBenchmark()
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), flagBenchDuration)
+		defer cancel()
+
+		begin := time.Now()
+		b.run(ctx)
+		result := b.result(time.Since(begin))
+
+		if err := b.cleanup(); err != nil {
+			level.Error(logger).Log("msg", "failed to clean up published modules", "err", err)
+		}
+
+		if flagOutput == outputJSON {
+			return printJSON(os.Stdout, result)
+		}
+
+		return result.print(os.Stdout)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(benchCmd)
+	benchCmd.Flags().StringVar(&flagBenchEndpoint, "endpoint", "", "Base URL of the registry, e.g. https://registry.example.com")
+	benchCmd.Flags().StringVar(&flagBenchToken, "token", "", "API key to authenticate against the registry")
+	benchCmd.Flags().StringSliceVar(&flagBenchModules, "module", nil, "Module to read in the format namespace/name/provider, can be repeated")
+	benchCmd.Flags().IntVar(&flagBenchConcurrency, "concurrency", 10, "Number of concurrent workers")
+	benchCmd.Flags().DurationVar(&flagBenchDuration, "duration", 30*time.Second, "Duration of the benchmark")
+	benchCmd.Flags().Int64Var(&flagBenchRequests, "requests", 0, "Stop after this many operations, 0 means no limit")
+	benchCmd.Flags().IntVar(&flagBenchVersionsWeight, "versions-weight", 10, "Relative weight of version lookups")
+	benchCmd.Flags().IntVar(&flagBenchDownloadWeight, "download-weight", 5, "Relative weight of downloads")
+	benchCmd.Flags().IntVar(&flagBenchPublishWeight, "publish-weight", 0, "Relative weight of publishes, requires a configured storage backend")
+	benchCmd.Flags().StringVar(&flagBenchNamespace, "publish-namespace", "boring-registry-bench", "Scratch namespace for published modules")
+}
+
+type benchModule struct {
+	namespace string
+	name      string
+	provider  string
+	versions  []string
+}
+
+type benchmark struct {
+	client  *registryClient
+	storage module.Storage
+	archive []byte
+	modules []*benchModule
+	ops     []string
+
+	count     int64
+	mu        sync.Mutex
+	latencies map[string][]time.Duration
+	errors    map[string]int
+	published []string
+}
+
+func newBenchmark() (*benchmark, error) {
+	if flagBenchConcurrency < 1 {
+		return nil, usageError{errors.New("concurrency must be at least 1")}
+	}
+
+	client, err := newRegistryClient(flagBenchEndpoint, flagBenchToken)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &benchmark{
+		client:    client,
+		latencies: make(map[string][]time.Duration),
+		errors:    make(map[string]int),
+	}
+
+	for _, m := range flagBenchModules {
+		parts := strings.Split(m, "/")
+		if len(parts) != 3 {
+			return nil, usageError{fmt.Errorf("invalid module: %s", m)}
+		}
+		b.modules = append(b.modules, &benchModule{namespace: parts[0], name: parts[1], provider: parts[2]})
+	}
+
+	// The ops slice is used for weighted random selection of operations
+	weights := map[string]int{
+		benchOpVersions: flagBenchVersionsWeight,
+		benchOpDownload: flagBenchDownloadWeight,
+		benchOpPublish:  flagBenchPublishWeight,
+	}
+	for _, op := range []string{benchOpVersions, benchOpDownload, benchOpPublish} {
+		if op != benchOpPublish && len(b.modules) == 0 {
+			continue
+		}
+		for i := 0; i < weights[op]; i++ {
+			b.ops = append(b.ops, op)
+		}
+	}
+
+	if len(b.ops) == 0 {
+		return nil, usageError{errors.New("no operations to run, pass --module or a positive --publish-weight")}
+	}
+
+	if flagBenchPublishWeight > 0 {
+		if b.storage, err = setupModuleStorage(); err != nil {
+			return nil, errors.Wrap(err, "failed to setup storage")
+		}
+		if b.archive, err = smokeTestArchive(); err != nil {
+			return nil, err
+		}
+	}
+
+	return b, nil
+}
+
+func (b *benchmark) run(ctx context.Context) {
+	// Resolve the versions of all modules upfront so downloads target existing versions
+	if base, err := b.client.discoverModules(ctx); err == nil {
+		for _, m := range b.modules {
+			m.versions, _ = b.client.listModuleVersions(ctx, base, m.namespace, m.name, m.provider)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < flagBenchConcurrency; i++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			rnd := rand.New(rand.NewSource(seed))
+
+			for ctx.Err() == nil {
+				if flagBenchRequests > 0 && atomic.AddInt64(&b.count, 1) > flagBenchRequests {
+					return
+				}
+
+				op := b.ops[rnd.Intn(len(b.ops))]
+				begin := time.Now()
+				err := b.do(ctx, rnd, op)
+				b.record(op, time.Since(begin), err)
+			}
+		}(time.Now().UnixNano() + int64(i))
+	}
+
+	wg.Wait()
+}
+
+func (b *benchmark) do(ctx context.Context, rnd *rand.Rand, op string) error {
+	switch op {
+	case benchOpPublish:
+		version := fmt.Sprintf("0.0.0-bench.%d", rnd.Int63())
+		if _, err := b.storage.UploadModule(ctx, flagBenchNamespace, "bench", "null", version, bytes.NewReader(b.archive)); err != nil {
+			return err
+		}
+		b.mu.Lock()
+		b.published = append(b.published, version)
+		b.mu.Unlock()
+		return nil
+	}
+
+	m := b.modules[rnd.Intn(len(b.modules))]
+
+	base, err := b.client.discoverModules(ctx)
+	if err != nil {
+		return err
+	}
+
+	switch op {
+	case benchOpVersions:
+		_, err := b.client.listModuleVersions(ctx, base, m.namespace, m.name, m.provider)
+		return err
+	case benchOpDownload:
+		if len(m.versions) == 0 {
+			return fmt.Errorf("no versions found for %s/%s/%s", m.namespace, m.name, m.provider)
+		}
+		_, err := b.client.moduleDownloadURL(ctx, base, m.namespace, m.name, m.provider, m.versions[rnd.Intn(len(m.versions))])
+		return err
+	default:
+		return fmt.Errorf("unknown operation: %s", op)
+	}
+}
+
+func (b *benchmark) record(op string, took time.Duration, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Operations interrupted by the end of the benchmark are not counted
+	if err != nil && errors.Is(err, context.DeadlineExceeded) {
+		return
+	}
+
+	b.latencies[op] = append(b.latencies[op], took)
+	if err != nil {
+		b.errors[op]++
+		level.Debug(logger).Log("msg", "operation failed", "op", op, "err", err)
+	}
+}
+
+func (b *benchmark) cleanup() error {
+	ctx := context.Background()
+	for _, version := range b.published {
+		if err := b.storage.DeleteModule(ctx, flagBenchNamespace, "bench", "null", version); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// benchResult is the machine-readable result of the bench command.
+type benchResult struct {
+	Duration   string            `json:"duration"`
+	Operations []benchOperations `json:"operations"`
+}
+
+type benchOperations struct {
+	Operation  string  `json:"operation"`
+	Count      int     `json:"count"`
+	Errors     int     `json:"errors"`
+	Throughput float64 `json:"throughput"`
+	P50        string  `json:"p50"`
+	P90        string  `json:"p90"`
+	P99        string  `json:"p99"`
+	Max        string  `json:"max"`
+}
+
+func (b *benchmark) result(took time.Duration) benchResult {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	result := benchResult{
+		Duration:   took.Round(time.Millisecond).String(),
+		Operations: []benchOperations{},
+	}
+
+	for _, op := range []string{benchOpVersions, benchOpDownload, benchOpPublish} {
+		latencies := b.latencies[op]
+		if len(latencies) == 0 {
+			continue
+		}
+
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+		result.Operations = append(result.Operations, benchOperations{
+			Operation:  op,
+			Count:      len(latencies),
+			Errors:     b.errors[op],
+			Throughput: math.Round(float64(len(latencies))/took.Seconds()*100) / 100,
+			P50:        percentile(latencies, 50).String(),
+			P90:        percentile(latencies, 90).String(),
+			P99:        percentile(latencies, 99).String(),
+			Max:        latencies[len(latencies)-1].String(),
+		})
+	}
+
+	return result
+}
+
+func (r benchResult) print(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "OPERATION\tCOUNT\tERRORS\tOPS/S\tP50\tP90\tP99\tMAX\n")
+	for _, op := range r.Operations {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.2f\t%s\t%s\t%s\t%s\n", op.Operation, op.Count, op.Errors, op.Throughput, op.P50, op.P90, op.P99, op.Max)
+	}
+	fmt.Fprintf(tw, "\nDuration: %s\n", r.Duration)
+	return tw.Flush()
+}
+
+// percentile returns the nearest-rank percentile of sorted latencies.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+
+	return sorted[rank-1]
+}
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPercentile(t *testing.T) {
+	t.Parallel()
+
+	var latencies []time.Duration
+	for i := 1; i <= 100; i++ {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+
+	testCases := []struct {
+		name      string
+		latencies []time.Duration
+		p         float64
+		expected  time.Duration
+	}{
+		{name: "empty", latencies: nil, p: 50, expected: 0},
+		{name: "single value", latencies: []time.Duration{time.Second}, p: 99, expected: time.Second},
+		{name: "p50", latencies: latencies, p: 50, expected: 50 * time.Millisecond},
+		{name: "p99", latencies: latencies, p: 99, expected: 99 * time.Millisecond},
+		{name: "p100", latencies: latencies, p: 100, expected: 100 * time.Millisecond},
+		{name: "p0", latencies: latencies, p: 0, expected: time.Millisecond},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, percentile(tc.latencies, tc.p))
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Labels of the cache metrics.
+const (
+	cacheStorage       = "storage"
+	cacheAuthz         = "authz"
+	cacheIntrospection = "introspection"
+
+	entityModuleVersions = "module_versions"
+	entityDecisions      = "decisions"
+	entityTokens         = "tokens"
+)
+
+var (
+	cacheLookupsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "boring_registry_cache_lookups_total",
+		Help: "Number of lookups of cache entries by result, every hit saves a call of the storage backend or endpoint.",
+	}, []string{"cache", "entity", "result"})
+	cacheEvictionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "boring_registry_cache_evictions_total",
+		Help: "Number of removed cache entries by reason, i.e. expired, full or invalidated.",
+	}, []string{"cache", "entity", "reason"})
+)
+
+func init() {
+	prometheus.MustRegister(cacheLookupsTotal, cacheEvictionsTotal)
+}
+
+// countCacheEvent counts a lookup or removal of a cache entry, the events of all caches share their names.
+func countCacheEvent(cache, entity, event string) {
+	switch event {
+	case "hit", "miss":
+		cacheLookupsTotal.WithLabelValues(cache, entity, event).Inc()
+	default:
+		cacheEvictionsTotal.WithLabelValues(cache, entity, event).Inc()
+	}
+}
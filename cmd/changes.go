@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/TierMobility/boring-registry/pkg/module"
+)
+
+var (
+	flagChangesFrom string
+	flagChangesTo   string
+)
+
+var changesCmd = &cobra.Command{
+	Use:   "changes [flags] NAMESPACE...",
+	Short: "Report the changes of the module catalog between two points in time",
+	Long: `Report the changes of the module catalog between two points in time.
+
+New modules, new versions and deprecated versions of the given namespaces are reported in the order they happened.
+--from and --to accept an RFC 3339 time or a duration before now, e.g. --from=168h for the last week.
+Deleted versions aren't reported, as the storage backends don't keep a record of them.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return usageError{errors.New("expected at least one namespace")}
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		now := time.Now().UTC()
+
+		from, err := parseReportTime(flagChangesFrom, now)
+		if err != nil {
+			return usageError{errors.Wrap(err, "invalid --from")}
+		}
+		to, err := parseReportTime(flagChangesTo, now)
+		if err != nil {
+			return usageError{errors.Wrap(err, "invalid --to")}
+		}
+		if !from.Before(to) {
+			return usageError{errors.New("--from must be before --to")}
+		}
+
+		storage, err := setupModuleStorage()
+		if err != nil {
+			return errors.Wrap(err, "failed to setup storage")
+		}
+
+		result := &changesResult{From: from, To: to, Changes: []module.Change{}}
+		for _, namespace := range args {
+			changes, err := module.CatalogChanges(context.Background(), storage, namespace, from, to)
+			if err != nil {
+				return err
+			}
+			result.Changes = append(result.Changes, changes...)
+		}
+
+		if flagOutput == outputJSON {
+			return printJSON(os.Stdout, result)
+		}
+
+		return result.print(os.Stdout)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(changesCmd)
+	changesCmd.Flags().StringVar(&flagChangesFrom, "from", "168h", "Start of the report, as RFC 3339 time or duration before now")
+	changesCmd.Flags().StringVar(&flagChangesTo, "to", "0s", "End of the report, as RFC 3339 time or duration before now")
+}
+
+// parseReportTime parses an RFC 3339 time or a duration before now.
+func parseReportTime(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither an RFC 3339 time nor a duration", s)
+	}
+
+	return t.UTC(), nil
+}
+
+// changesResult is the machine-readable result of the changes command.
+type changesResult struct {
+	From    time.Time       `json:"from"`
+	To      time.Time       `json:"to"`
+	Changes []module.Change `json:"changes"`
+}
+
+func (r *changesResult) print(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "AT\tTYPE\tMODULE\tVERSION\n")
+	for _, c := range r.Changes {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", c.At.Format(time.RFC3339), c.Type, c.Module, c.Version)
+	}
+	return tw.Flush()
+}
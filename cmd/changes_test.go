@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseReportTime(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 5, 13, 9, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name          string
+		input         string
+		expected      time.Time
+		expectedError bool
+	}{
+		{name: "duration", input: "168h", expected: now.Add(-168 * time.Hour)},
+		{name: "now", input: "0s", expected: now},
+		{name: "time", input: "2024-05-06T02:00:00+02:00", expected: time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC)},
+		{name: "invalid", input: "last week", expectedError: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			res, err := parseReportTime(tc.input, now)
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, res)
+		})
+	}
+}
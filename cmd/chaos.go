@@ -0,0 +1,43 @@
+//go:build chaos
+
+package cmd
+
+import (
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+
+	"github.com/TierMobility/boring-registry/pkg/module"
+)
+
+var (
+	flagChaosMaxLatency      time.Duration
+	flagChaosErrorRate       float64
+	flagChaosPartialReadRate float64
+)
+
+func init() {
+	serverCmd.Flags().DurationVar(&flagChaosMaxLatency, "chaos-max-latency", 0, "Add a random latency up to this duration to every storage call")
+	serverCmd.Flags().Float64Var(&flagChaosErrorRate, "chaos-error-rate", 0, "Fraction of storage calls (0 to 1) that fail")
+	serverCmd.Flags().Float64Var(&flagChaosPartialReadRate, "chaos-partial-read-rate", 0, "Fraction of uploads (0 to 1) whose body is cut off")
+}
+
+// chaosModuleStorage wraps the storage with fault injection if any chaos flag is set.
+func chaosModuleStorage(storage module.Storage) module.Storage {
+	if flagChaosMaxLatency == 0 && flagChaosErrorRate == 0 && flagChaosPartialReadRate == 0 {
+		return storage
+	}
+
+	_ = level.Warn(logger).Log(
+		"msg", "injecting faults into the module storage",
+		"max-latency", flagChaosMaxLatency,
+		"error-rate", flagChaosErrorRate,
+		"partial-read-rate", flagChaosPartialReadRate,
+	)
+
+	return module.NewChaosStorage(storage,
+		module.WithChaosMaxLatency(flagChaosMaxLatency),
+		module.WithChaosErrorRate(flagChaosErrorRate),
+		module.WithChaosPartialReadRate(flagChaosPartialReadRate),
+	)
+}
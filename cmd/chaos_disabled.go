@@ -0,0 +1,12 @@
+//go:build !chaos
+
+package cmd
+
+import (
+	"github.com/TierMobility/boring-registry/pkg/module"
+)
+
+// chaosModuleStorage is a no-op in builds without the chaos tag.
+func chaosModuleStorage(storage module.Storage) module.Storage {
+	return storage
+}
@@ -0,0 +1,202 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// registryClient talks to a registry the same way Terraform does.
+type registryClient struct {
+	endpoint *url.URL
+	token    string
+	client   *http.Client
+}
+
+func newRegistryClient(endpoint, token string) (*registryClient, error) {
+	if endpoint == "" {
+		return nil, usageError{errors.New("endpoint must not be empty")}
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, usageError{errors.Wrap(err, "invalid endpoint")}
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, usageError{fmt.Errorf("invalid endpoint scheme: %s", u.Scheme)}
+	}
+
+	return &registryClient{
+		endpoint: u,
+		token:    token,
+		client:   http.DefaultClient,
+	}, nil
+}
+
+// discoverModules returns the base URL of the Module Registry Protocol using service discovery.
+func (c *registryClient) discoverModules(ctx context.Context) (*url.URL, error) {
+	return c.discover(ctx, "modules.v1", "Module Registry Protocol")
+}
+
+// discoverProviders returns the base URL of the Provider Registry Protocol using service discovery.
+func (c *registryClient) discoverProviders(ctx context.Context) (*url.URL, error) {
+	return c.discover(ctx, "providers.v1", "Provider Registry Protocol")
+}
+
+func (c *registryClient) discover(ctx context.Context, service, protocol string) (*url.URL, error) {
+	res, err := c.get(ctx, c.endpoint.ResolveReference(&url.URL{Path: "/.well-known/terraform.json"}))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	var services map[string]string
+	if err := json.NewDecoder(res.Body).Decode(&services); err != nil {
+		return nil, errors.Wrap(err, "failed to decode service discovery document")
+	}
+
+	base, ok := services[service]
+	if !ok {
+		return nil, fmt.Errorf("registry does not support the %s", protocol)
+	}
+
+	u, err := url.Parse(base)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.endpoint.ResolveReference(u), nil
+}
+
+// listModuleVersions lists the versions of a module.
+func (c *registryClient) listModuleVersions(ctx context.Context, base *url.URL, namespace, name, provider string) ([]string, error) {
+	res, err := c.get(ctx, base.ResolveReference(&url.URL{Path: fmt.Sprintf("%s/%s/%s/versions", namespace, name, provider)}))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	var body struct {
+		Modules []struct {
+			Versions []struct {
+				Version string `json:"version"`
+			} `json:"versions"`
+		} `json:"modules"`
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, errors.Wrap(err, "failed to decode versions")
+	}
+
+	var versions []string
+	for _, module := range body.Modules {
+		for _, v := range module.Versions {
+			versions = append(versions, v.Version)
+		}
+	}
+
+	return versions, nil
+}
+
+// listProviderVersions lists the versions of a provider.
+func (c *registryClient) listProviderVersions(ctx context.Context, base *url.URL, namespace, name string) ([]string, error) {
+	res, err := c.get(ctx, base.ResolveReference(&url.URL{Path: fmt.Sprintf("%s/%s/versions", namespace, name)}))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	var body struct {
+		Versions []struct {
+			Version string `json:"version"`
+		} `json:"versions"`
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, errors.Wrap(err, "failed to decode versions")
+	}
+
+	var versions []string
+	for _, v := range body.Versions {
+		versions = append(versions, v.Version)
+	}
+
+	return versions, nil
+}
+
+// moduleDownloadURL returns the source address of a module version from the X-Terraform-Get header.
+func (c *registryClient) moduleDownloadURL(ctx context.Context, base *url.URL, namespace, name, provider, version string) (string, error) {
+	res, err := c.get(ctx, base.ResolveReference(&url.URL{Path: fmt.Sprintf("%s/%s/%s/%s/download", namespace, name, provider, version)}))
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	source := res.Header.Get("X-Terraform-Get")
+	if source == "" {
+		return "", errors.New("download response is missing the X-Terraform-Get header")
+	}
+
+	return source, nil
+}
+
+// download fetches an archive from a source address, only HTTP sources are supported.
+func (c *registryClient) download(ctx context.Context, base *url.URL, source string) (io.ReadCloser, error) {
+	u, err := url.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+
+	u = base.ResolveReference(u)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported source address: %s", source)
+	}
+
+	res, err := c.get(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Body, nil
+}
+
+func (c *registryClient) get(ctx context.Context, u *url.URL) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only send credentials to the registry itself and not to e.g. storage backends
+	if c.token != "" && strings.EqualFold(u.Host, c.endpoint.Host) {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode >= 300 {
+		res.Body.Close()
+		return nil, &statusError{url: u.String(), code: res.StatusCode}
+	}
+
+	return res, nil
+}
+
+// statusError is returned for unexpected HTTP status codes.
+type statusError struct {
+	url  string
+	code int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%s returned status %d", e.url, e.code)
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistryClient(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/terraform.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"modules.v1": "/v1/modules/"}`))
+	})
+	mux.HandleFunc("/v1/modules/tier/test/dummy/versions", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"modules": [{"versions": [{"version": "1.0.0"}, {"version": "1.1.0"}]}]}`))
+	})
+	mux.HandleFunc("/v1/modules/tier/test/dummy/1.0.0/download", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Terraform-Get", "/archives/test.tar.gz")
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("/archives/test.tar.gz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("archive"))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	ctx := context.Background()
+
+	client, err := newRegistryClient(server.URL, "secret")
+	assert.NoError(err)
+
+	base, err := client.discoverModules(ctx)
+	assert.NoError(err)
+	assert.Equal(server.URL+"/v1/modules/", base.String())
+
+	versions, err := client.listModuleVersions(ctx, base, "tier", "test", "dummy")
+	assert.NoError(err)
+	assert.Equal([]string{"1.0.0", "1.1.0"}, versions)
+
+	source, err := client.moduleDownloadURL(ctx, base, "tier", "test", "dummy", "1.0.0")
+	assert.NoError(err)
+	assert.Equal("/archives/test.tar.gz", source)
+
+	body, err := client.download(ctx, base, source)
+	assert.NoError(err)
+	data, err := ioutil.ReadAll(body)
+	body.Close()
+	assert.NoError(err)
+	assert.Equal("archive", string(data))
+
+	_, err = client.download(ctx, base, "s3::https://bucket.s3.amazonaws.com/test.tar.gz")
+	assert.Error(err)
+
+	unauthorized, err := newRegistryClient(server.URL, "")
+	assert.NoError(err)
+	_, err = unauthorized.listModuleVersions(ctx, base, "tier", "test", "dummy")
+	assert.Equal(exitCodeAuth, exitCode(err))
+}
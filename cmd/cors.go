@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"net/http"
+	"strings"
+)
+
+var (
+	flagCORSAllowedOrigins string
+)
+
+// corsMaxAge is how long browsers cache the result of a preflight request in seconds.
+const corsMaxAge = "600"
+
+func init() {
+	serverCmd.Flags().StringVar(&flagCORSAllowedOrigins, "cors-allowed-origins", "", "Comma-separated origins of browser tools allowed to call the read APIs, e.g. https://portal.example.com")
+}
+
+// corsHandler allows browser tools of the origins to read the discovery document as well as the module and provider APIs.
+// Only GET and HEAD requests are allowed, and credentials like cookies aren't, as browser tools send Bearer tokens.
+// Other requests are passed on unchanged, so browsers block cross-origin responses to them.
+func corsHandler(next http.Handler, origins []string) http.Handler {
+	if len(origins) == 0 {
+		return next
+	}
+
+	allowed := make(map[string]bool)
+	for _, origin := range origins {
+		allowed[strings.TrimSuffix(origin, "/")] = true
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || !corsPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		if !allowed[origin] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Preflight requests are answered here, as the APIs don't route OPTIONS requests
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			switch r.Header.Get("Access-Control-Request-Method") {
+			case http.MethodGet, http.MethodHead:
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD")
+				w.Header().Set("Access-Control-Allow-Headers", "Authorization")
+				w.Header().Set("Access-Control-Max-Age", corsMaxAge)
+				w.WriteHeader(http.StatusNoContent)
+			default:
+				w.WriteHeader(http.StatusForbidden)
+			}
+			return
+		}
+
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// corsPath reports whether browser tools may call the path, which excludes e.g. the metrics and the provider mirror.
+func corsPath(path string) bool {
+	return path == "/.well-known/terraform.json" ||
+		path == prefixModules ||
+		strings.HasPrefix(path, prefixModules+"/") ||
+		strings.HasPrefix(path, prefixProviders+"/")
+}
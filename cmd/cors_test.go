@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCORSHandler(t *testing.T) {
+	t.Parallel()
+
+	handler := corsHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}), []string{"https://portal.example.com/"})
+
+	testCases := []struct {
+		name           string
+		method         string
+		path           string
+		origin         string
+		preflight      string
+		expectedStatus int
+		expectedOrigin string
+	}{
+		{
+			name:           "same origin",
+			method:         http.MethodGet,
+			path:           prefixModules + "/tier/vpc/aws/versions",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "allowed origin",
+			method:         http.MethodGet,
+			path:           prefixModules + "/tier/vpc/aws/versions",
+			origin:         "https://portal.example.com",
+			expectedStatus: http.StatusOK,
+			expectedOrigin: "https://portal.example.com",
+		},
+		{
+			name:           "discovery document",
+			method:         http.MethodGet,
+			path:           "/.well-known/terraform.json",
+			origin:         "https://portal.example.com",
+			expectedStatus: http.StatusOK,
+			expectedOrigin: "https://portal.example.com",
+		},
+		{
+			name:           "other origin",
+			method:         http.MethodGet,
+			path:           prefixModules + "/tier/vpc/aws/versions",
+			origin:         "https://evil.example.com",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "metrics",
+			method:         http.MethodGet,
+			path:           "/metrics",
+			origin:         "https://portal.example.com",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "preflight",
+			method:         http.MethodOptions,
+			path:           prefixProviders + "/hashicorp/aws/versions",
+			origin:         "https://portal.example.com",
+			preflight:      http.MethodGet,
+			expectedStatus: http.StatusNoContent,
+			expectedOrigin: "https://portal.example.com",
+		},
+		{
+			name:           "preflight of upload",
+			method:         http.MethodOptions,
+			path:           prefixModules + "/tier/vpc/aws/1.0.0",
+			origin:         "https://portal.example.com",
+			preflight:      http.MethodPost,
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "upload",
+			method:         http.MethodPost,
+			path:           prefixModules + "/tier/vpc/aws/1.0.0",
+			origin:         "https://portal.example.com",
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest(tc.method, tc.path, nil)
+			if tc.origin != "" {
+				r.Header.Set("Origin", tc.origin)
+			}
+			if tc.preflight != "" {
+				r.Header.Set("Access-Control-Request-Method", tc.preflight)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			assert.Equal(t, tc.expectedOrigin, w.Header().Get("Access-Control-Allow-Origin"))
+			assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/TierMobility/boring-registry/pkg/module"
+)
+
+var deprecatedDownloadsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "boring_registry_deprecated_downloads_total",
+	Help: "Number of downloads of deprecated module versions.",
+}, []string{"module", "version"})
+
+func init() {
+	prometheus.MustRegister(deprecatedDownloadsTotal)
+}
+
+// reportDeprecatedDownload logs and counts the download of a deprecated module version.
+func reportDeprecatedDownload(download module.DeprecatedDownload) {
+	id := fmt.Sprintf("%s/%s/%s", download.Namespace, download.Name, download.Provider)
+
+	deprecatedDownloadsTotal.WithLabelValues(id, download.Version).Inc()
+
+	_ = level.Warn(logger).Log(
+		"msg", "deprecated module version downloaded",
+		"module", id,
+		"version", download.Version,
+		"reason", download.Reason,
+	)
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/TierMobility/boring-registry/pkg/module"
+)
+
+// digestResult is the machine-readable result of the upload command with --print-digest.
+type digestResult struct {
+	Modules []moduleDigest `json:"modules"`
+}
+
+type moduleDigest struct {
+	Path      string `json:"path"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Provider  string `json:"provider"`
+	Version   string `json:"version"`
+	Digest    string `json:"digest"`
+}
+
+// moduleDigests archives all modules below root and returns their digests without uploading them.
+func moduleDigests(root string) (*digestResult, error) {
+	result := &digestResult{Modules: []moduleDigest{}}
+
+	err := walkModules(root, func(path string) error {
+		spec, err := module.ParseFile(path)
+		if err != nil {
+			return err
+		}
+
+		buf, err := archiveModule(filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+
+		result.Modules = append(result.Modules, moduleDigest{
+			Path:      path,
+			Namespace: spec.Metadata.Namespace,
+			Name:      spec.Metadata.Name,
+			Provider:  spec.Metadata.Provider,
+			Version:   spec.Metadata.Version,
+			Digest:    module.ArchiveDigest(buf.Bytes()),
+		})
+
+		return nil
+	})
+
+	return result, err
+}
+
+func (r *digestResult) print(w io.Writer) error {
+	for _, m := range r.Modules {
+		if _, err := fmt.Fprintf(w, "%s  %s/%s/%s/%s\n", m.Digest, m.Namespace, m.Name, m.Provider, m.Version); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func printModuleDigests(root string) error {
+	result, err := moduleDigests(root)
+	if err != nil {
+		return err
+	}
+
+	if flagOutput == outputJSON {
+		return printJSON(os.Stdout, result)
+	}
+
+	return result.print(os.Stdout)
+}
@@ -0,0 +1,351 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var flagDoctorTimeout time.Duration
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the permissions of the registry on the S3 bucket",
+	Long: `Check the permissions of the registry on the S3 bucket.
+
+The bucket of --storage-s3-bucket is probed with the operations the registry uses:
+a temporary object is written below the modules of --storage-s3-prefix, read, listed,
+downloaded through a presigned URL like Terraform does, and deleted again.
+Every failed check names the missing IAM permission and the resource it is needed on,
+and the identity of the credentials is reported to find the policy to change.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if flagS3Bucket == "" {
+			return usageError{errors.New("doctor requires --storage-s3-bucket, other storage backends aren't supported")}
+		}
+
+		client, err := newS3Client()
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), flagDoctorTimeout)
+		defer cancel()
+
+		d := &doctor{
+			client:   client,
+			identity: callerIdentity,
+			http:     s3HTTPClient(),
+			bucket:   flagS3Bucket,
+			prefix:   path.Join(flagS3Prefix, "modules"),
+			region:   flagS3Region,
+		}
+		result, err := d.run(ctx)
+
+		if flagOutput == outputJSON {
+			if err != nil {
+				result.Error = err.Error()
+			}
+			if err := printJSON(os.Stdout, result); err != nil {
+				return err
+			}
+		} else if err := result.print(os.Stdout); err != nil {
+			return err
+		}
+
+		return err
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+	doctorCmd.Flags().DurationVar(&flagDoctorTimeout, "timeout", time.Minute, "Timeout for all checks")
+}
+
+// callerIdentity returns the ARN of the identity of the S3 credentials.
+func callerIdentity(ctx context.Context) (string, error) {
+	sess, cfg, err := newS3Session()
+	if err != nil {
+		return "", err
+	}
+
+	res, err := sts.New(sess, cfg).GetCallerIdentityWithContext(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", err
+	}
+
+	return aws.StringValue(res.Arn), nil
+}
+
+// Status of a doctor check.
+const (
+	doctorStatusPassed  = "passed"
+	doctorStatusFailed  = "failed"
+	doctorStatusSkipped = "skipped"
+)
+
+// doctorResult is the machine-readable result of the doctor command.
+type doctorResult struct {
+	Bucket   string        `json:"bucket"`
+	Prefix   string        `json:"prefix"`
+	Identity string        `json:"identity,omitempty"`
+	Checks   []doctorCheck `json:"checks"`
+	Error    string        `json:"error,omitempty"`
+}
+
+type doctorCheck struct {
+	Name string `json:"name"`
+	// Operation is the S3 API call of the check.
+	Operation string `json:"operation"`
+	Status    string `json:"status"`
+	// Permission and Resource are set if the check failed for a missing permission.
+	Permission string `json:"permission,omitempty"`
+	Resource   string `json:"resource,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+func (r *doctorResult) print(w io.Writer) error {
+	if r.Identity != "" {
+		fmt.Fprintf(w, "Checking s3://%s/%s as %s\n\n", r.Bucket, r.Prefix, r.Identity)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "CHECK\tOPERATION\tSTATUS\tDETAIL\n")
+	for _, check := range r.Checks {
+		detail := check.Error
+		if check.Permission != "" {
+			detail = fmt.Sprintf("missing %s on %s", check.Permission, check.Resource)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", check.Name, check.Operation, check.Status, detail)
+	}
+	return tw.Flush()
+}
+
+// doctorDeniedError is returned by checks which were denied for a missing permission.
+type doctorDeniedError struct {
+	permission string
+	resource   string
+	err        error
+}
+
+func (e *doctorDeniedError) Error() string {
+	return fmt.Sprintf("missing %s on %s: %s", e.permission, e.resource, e.err)
+}
+
+// doctor probes a bucket with the operations of the registry.
+type doctor struct {
+	client s3iface.S3API
+	// identity returns the identity of the credentials, it is optional.
+	identity func(ctx context.Context) (string, error)
+	// http downloads presigned URLs, the default client is used if it is nil.
+	http   *http.Client
+	bucket string
+	prefix string
+	region string
+}
+
+// run runs all checks, so all missing permissions are reported at once.
+// Checks depending on the temporary object are skipped if it couldn't be written.
+func (d *doctor) run(ctx context.Context) (*doctorResult, error) {
+	result := &doctorResult{
+		Bucket: d.bucket,
+		Prefix: d.prefix,
+		Checks: []doctorCheck{},
+	}
+
+	if d.identity != nil {
+		if identity, err := d.identity(ctx); err == nil {
+			result.Identity = identity
+		}
+	}
+
+	var (
+		key        = path.Join(d.prefix, fmt.Sprintf(".doctor/%d", time.Now().UnixNano()))
+		missingKey = key + "-missing"
+		content    = []byte("boring-registry")
+		bucketARN  = fmt.Sprintf("arn:%s:s3:::%s", s3Partition(d.region), d.bucket)
+		objectsARN = fmt.Sprintf("%s/%s/*", bucketARN, d.prefix)
+	)
+	if d.prefix == "" {
+		objectsARN = bucketARN + "/*"
+	}
+
+	check := func(name, operation string, enabled bool, fn func() error) bool {
+		c := doctorCheck{Name: name, Operation: operation, Status: doctorStatusPassed}
+		if !enabled {
+			c.Status = doctorStatusSkipped
+			result.Checks = append(result.Checks, c)
+			return false
+		}
+
+		if err := fn(); err != nil {
+			c.Status = doctorStatusFailed
+			c.Error = err.Error()
+
+			var denied *doctorDeniedError
+			if errors.As(err, &denied) {
+				c.Permission, c.Resource, c.Error = denied.permission, denied.resource, denied.err.Error()
+			}
+		}
+		result.Checks = append(result.Checks, c)
+
+		return c.Status == doctorStatusPassed
+	}
+
+	bucketExists := check("bucket", "HeadBucket", true, func() error {
+		_, err := d.client.HeadBucketWithContext(ctx, &s3.HeadBucketInput{Bucket: aws.String(d.bucket)})
+		if isAWSErrorCode(err, "NotFound", s3.ErrCodeNoSuchBucket) {
+			return fmt.Errorf("bucket %s doesn't exist", d.bucket)
+		}
+		return denied(err, "s3:ListBucket", bucketARN)
+	})
+
+	check("list", "ListObjectsV2", bucketExists, func() error {
+		_, err := d.client.ListObjectsV2WithContext(ctx, &s3.ListObjectsV2Input{
+			Bucket: aws.String(d.bucket),
+			Prefix: aws.String(d.prefix + "/"),
+		})
+		return denied(err, "s3:ListBucket", bucketARN)
+	})
+
+	written := check("upload", "PutObject", bucketExists, func() error {
+		_, err := d.client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+			Bucket: aws.String(d.bucket),
+			Key:    aws.String(key),
+			Body:   bytes.NewReader(content),
+		})
+		if isKMSError(err) {
+			return denied(err, "kms:GenerateDataKey", "the KMS key of the bucket")
+		}
+		return denied(err, "s3:PutObject", objectsARN)
+	})
+
+	check("head", "HeadObject", written, func() error {
+		_, err := d.client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(d.bucket),
+			Key:    aws.String(key),
+		})
+		return denied(err, "s3:GetObject", objectsARN)
+	})
+
+	// Without s3:ListBucket, S3 answers requests for missing objects with 403 instead of 404,
+	// so the registry can't tell new versions from forbidden ones.
+	check("head-missing", "HeadObject", bucketExists, func() error {
+		_, err := d.client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(d.bucket),
+			Key:    aws.String(missingKey),
+		})
+		switch {
+		case err == nil:
+			return fmt.Errorf("object %s exists unexpectedly", missingKey)
+		case isAWSErrorCode(err, "NotFound", s3.ErrCodeNoSuchKey):
+			return nil
+		default:
+			return denied(err, "s3:ListBucket", bucketARN)
+		}
+	})
+
+	check("presigned-download", "GetObject", written, func() error {
+		req, _ := d.client.GetObjectRequest(&s3.GetObjectInput{
+			Bucket: aws.String(d.bucket),
+			Key:    aws.String(key),
+		})
+		u, err := req.Presign(time.Minute)
+		if err != nil {
+			return err
+		}
+
+		return d.download(ctx, u, content, objectsARN)
+	})
+
+	check("delete", "DeleteObject", written, func() error {
+		_, err := d.client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(d.bucket),
+			Key:    aws.String(key),
+		})
+		return denied(err, "s3:DeleteObject", objectsARN)
+	})
+
+	var failed []string
+	for _, c := range result.Checks {
+		if c.Status == doctorStatusFailed {
+			failed = append(failed, c.Name)
+		}
+	}
+	if len(failed) > 0 {
+		return result, fmt.Errorf("checks of bucket %s failed: %s", d.bucket, strings.Join(failed, ", "))
+	}
+
+	return result, nil
+}
+
+// download fetches a presigned URL without any credentials, like Terraform downloads providers.
+func (d *doctor) download(ctx context.Context, u string, expected []byte, objectsARN string) error {
+	client := d.http
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	b, err := ioutil.ReadAll(io.LimitReader(res.Body, 64*1024))
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case res.StatusCode == http.StatusForbidden && bytes.Contains(b, []byte("KMS")):
+		return &doctorDeniedError{permission: "kms:Decrypt", resource: "the KMS key of the bucket", err: fmt.Errorf("presigned URL was rejected with status %d", res.StatusCode)}
+	case res.StatusCode == http.StatusForbidden:
+		return &doctorDeniedError{permission: "s3:GetObject", resource: objectsARN, err: fmt.Errorf("presigned URL was rejected with status %d", res.StatusCode)}
+	case res.StatusCode != http.StatusOK:
+		return fmt.Errorf("presigned URL returned status %d", res.StatusCode)
+	case !bytes.Equal(b, expected):
+		return errors.New("presigned URL returned unexpected content")
+	}
+
+	return nil
+}
+
+// denied marks access denied errors with the permission the operation needs.
+func denied(err error, permission, resource string) error {
+	if err == nil {
+		return nil
+	}
+
+	if isAWSErrorCode(err, "AccessDenied", "Forbidden") {
+		return &doctorDeniedError{permission: permission, resource: resource, err: err}
+	}
+
+	return err
+}
+
+// isKMSError reports whether S3 denied access for a missing permission on the KMS key of the bucket.
+func isKMSError(err error) bool {
+	var awsErr awserr.Error
+	return errors.As(err, &awsErr) && awsErr.Code() == "AccessDenied" && strings.Contains(awsErr.Message(), "KMS")
+}
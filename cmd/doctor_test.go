@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeS3Server serves the S3 API calls of the doctor for a single bucket, denying the operations of a policy.
+type fakeS3Server struct {
+	bucket string
+	// denied lists the operations which are denied, e.g. "PutObject".
+	denied map[string]bool
+
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (s *fakeS3Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/"+s.bucket), "/")
+
+	var operation string
+	switch {
+	case r.Method == http.MethodHead && key == "":
+		operation = "HeadBucket"
+	case r.Method == http.MethodGet && key == "":
+		operation = "ListObjectsV2"
+	case r.Method == http.MethodPut:
+		operation = "PutObject"
+	case r.Method == http.MethodHead:
+		operation = "HeadObject"
+	case r.Method == http.MethodGet:
+		operation = "GetObject"
+	case r.Method == http.MethodDelete:
+		operation = "DeleteObject"
+	}
+
+	if s.denied[operation] {
+		w.WriteHeader(http.StatusForbidden)
+		if r.Method != http.MethodHead {
+			fmt.Fprint(w, `<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`)
+		}
+		return
+	}
+
+	b, exists := s.objects[key]
+	if !exists && (operation == "HeadObject" || operation == "GetObject") {
+		// Without s3:ListBucket, S3 doesn't reveal whether an object exists
+		if s.denied["ListObjectsV2"] {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch operation {
+	case "ListObjectsV2":
+		fmt.Fprint(w, `<ListBucketResult><Name>registry</Name><KeyCount>0</KeyCount></ListBucketResult>`)
+	case "PutObject":
+		s.objects[key], _ = ioutil.ReadAll(r.Body)
+	case "GetObject":
+		_, _ = w.Write(b)
+	case "DeleteObject":
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestDoctor(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name               string
+		denied             []string
+		expectedStatuses   map[string]string
+		expectedPermission map[string]string
+	}{
+		{
+			name: "all permissions",
+			expectedStatuses: map[string]string{
+				"bucket": doctorStatusPassed, "list": doctorStatusPassed, "upload": doctorStatusPassed, "head": doctorStatusPassed,
+				"head-missing": doctorStatusPassed, "presigned-download": doctorStatusPassed, "delete": doctorStatusPassed,
+			},
+		},
+		{
+			name:   "missing list",
+			denied: []string{"ListObjectsV2"},
+			expectedStatuses: map[string]string{
+				"bucket": doctorStatusPassed, "list": doctorStatusFailed, "upload": doctorStatusPassed, "head": doctorStatusPassed,
+				"head-missing": doctorStatusFailed, "presigned-download": doctorStatusPassed, "delete": doctorStatusPassed,
+			},
+			expectedPermission: map[string]string{
+				"list":         "s3:ListBucket",
+				"head-missing": "s3:ListBucket",
+			},
+		},
+		{
+			name:   "read only",
+			denied: []string{"PutObject", "DeleteObject"},
+			expectedStatuses: map[string]string{
+				"bucket": doctorStatusPassed, "list": doctorStatusPassed, "upload": doctorStatusFailed, "head": doctorStatusSkipped,
+				"head-missing": doctorStatusPassed, "presigned-download": doctorStatusSkipped, "delete": doctorStatusSkipped,
+			},
+			expectedPermission: map[string]string{
+				"upload": "s3:PutObject",
+			},
+		},
+		{
+			name:   "missing get",
+			denied: []string{"HeadObject", "GetObject"},
+			expectedStatuses: map[string]string{
+				"bucket": doctorStatusPassed, "list": doctorStatusPassed, "upload": doctorStatusPassed, "head": doctorStatusFailed,
+				"head-missing": doctorStatusFailed, "presigned-download": doctorStatusFailed, "delete": doctorStatusPassed,
+			},
+			expectedPermission: map[string]string{
+				"head":               "s3:GetObject",
+				"head-missing":       "s3:ListBucket",
+				"presigned-download": "s3:GetObject",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+
+			backend := &fakeS3Server{bucket: "registry", denied: make(map[string]bool), objects: make(map[string][]byte)}
+			for _, operation := range tc.denied {
+				backend.denied[operation] = true
+			}
+			server := httptest.NewServer(backend)
+			defer server.Close()
+
+			sess, err := session.NewSession(aws.NewConfig().
+				WithRegion("eu-central-1").
+				WithEndpoint(server.URL).
+				WithS3ForcePathStyle(true).
+				WithMaxRetries(0).
+				WithCredentials(credentials.NewStaticCredentials("id", "secret", "")))
+			assert.NoError(err)
+
+			d := &doctor{
+				client: s3.New(sess),
+				bucket: "registry",
+				prefix: "registry/modules",
+				region: "eu-central-1",
+			}
+			result, err := d.run(context.Background())
+			assert.Equal(len(tc.expectedPermission) > 0, err != nil)
+
+			statuses := make(map[string]string)
+			permissions := make(map[string]string)
+			for _, check := range result.Checks {
+				statuses[check.Name] = check.Status
+				if check.Permission != "" {
+					permissions[check.Name] = check.Permission
+					assert.Contains(check.Resource, "arn:aws:s3:::registry")
+				}
+			}
+			assert.Equal(tc.expectedStatuses, statuses)
+			if len(tc.expectedPermission) > 0 {
+				assert.Equal(tc.expectedPermission, permissions)
+			}
+
+			// The temporary object is deleted if the policy allows it
+			if !backend.denied["DeleteObject"] {
+				assert.Empty(backend.objects)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/pkg/errors"
+	"google.golang.org/api/googleapi"
+
+	"github.com/TierMobility/boring-registry/pkg/module"
+)
+
+// Exit codes returned by the CLI.
+// These are part of the public interface of the CLI and must not be changed.
+const (
+	exitCodeOK        = 0
+	exitCodeError     = 1
+	exitCodeUsage     = 2
+	exitCodeConflict  = 3
+	exitCodeAuth      = 4
+	exitCodeTransport = 5
+)
+
+// usageError marks errors caused by an invalid invocation of the CLI.
+type usageError struct {
+	err error
+}
+
+func (e usageError) Error() string {
+	return e.err.Error()
+}
+
+func (e usageError) Unwrap() error {
+	return e.err
+}
+
+// exitCode translates an error into the exit code of the CLI.
+func exitCode(err error) int {
+	if err == nil {
+		return exitCodeOK
+	}
+
+	var (
+		usageErr  usageError
+		awsErr    awserr.Error
+		googleErr *googleapi.Error
+		netErr    net.Error
+		statusErr *statusError
+	)
+
+	switch {
+	case errors.As(err, &usageErr):
+		return exitCodeUsage
+	case errors.Is(err, module.ErrAlreadyExists):
+		return exitCodeConflict
+	case errors.Is(err, module.ErrReserved):
+		return exitCodeAuth
+	case errors.As(err, &awsErr):
+		switch awsErr.Code() {
+		case "AccessDenied", "Forbidden", "InvalidAccessKeyId", "SignatureDoesNotMatch", "ExpiredToken", "NoCredentialProviders":
+			return exitCodeAuth
+		case "RequestError", "RequestTimeout", "RequestTimeoutException":
+			return exitCodeTransport
+		}
+	case errors.As(err, &googleErr):
+		switch googleErr.Code {
+		case 401, 403:
+			return exitCodeAuth
+		}
+	case errors.As(err, &statusErr):
+		switch statusErr.code {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return exitCodeAuth
+		case http.StatusConflict:
+			return exitCodeConflict
+		}
+	case errors.As(err, &netErr):
+		return exitCodeTransport
+	}
+
+	return exitCodeError
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	pkgerrors "github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/googleapi"
+
+	"github.com/TierMobility/boring-registry/pkg/module"
+)
+
+func TestExitCode(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		err      error
+		expected int
+	}{
+		{
+			name:     "no error",
+			err:      nil,
+			expected: exitCodeOK,
+		},
+		{
+			name:     "unclassified error",
+			err:      errors.New("something went wrong"),
+			expected: exitCodeError,
+		},
+		{
+			name:     "usage error",
+			err:      pkgerrors.Wrap(usageError{errors.New("missing argument")}, "failed to setup storage"),
+			expected: exitCodeUsage,
+		},
+		{
+			name:     "module already exists",
+			err:      pkgerrors.Wrap(module.ErrAlreadyExists, "tier/test/dummy/1.0.0"),
+			expected: exitCodeConflict,
+		},
+		{
+			name:     "reserved namespace",
+			err:      pkgerrors.Wrap(module.ErrReserved, "namespace hashicorp"),
+			expected: exitCodeAuth,
+		},
+		{
+			name:     "aws access denied",
+			err:      pkgerrors.Wrap(awserr.New("AccessDenied", "Access Denied", nil), "failed to upload"),
+			expected: exitCodeAuth,
+		},
+		{
+			name:     "aws request error",
+			err:      awserr.New("RequestError", "send request failed", nil),
+			expected: exitCodeTransport,
+		},
+		{
+			name:     "aws unclassified error",
+			err:      awserr.New("InternalError", "internal error", nil),
+			expected: exitCodeError,
+		},
+		{
+			name:     "google forbidden",
+			err:      pkgerrors.Wrap(&googleapi.Error{Code: 403}, "failed to upload"),
+			expected: exitCodeAuth,
+		},
+		{
+			name:     "registry unauthorized",
+			err:      &statusError{url: "https://registry.example.com/v1/modules/", code: 401},
+			expected: exitCodeAuth,
+		},
+		{
+			name:     "network error",
+			err:      &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")},
+			expected: exitCodeTransport,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, exitCode(tc.err))
+		})
+	}
+}
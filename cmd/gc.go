@@ -0,0 +1,247 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/TierMobility/boring-registry/pkg/module"
+)
+
+var (
+	flagRetention         []string
+	flagRetentionPins     string
+	flagRetentionInterval time.Duration
+	flagGCDryRun          bool
+)
+
+var gcCmd = &cobra.Command{
+	Use:   "gc [flags]",
+	Short: "Delete module versions which aren't kept by the retention policies of their namespace",
+	Long: `Delete module versions which aren't kept by the retention policies of their namespace.
+
+Every --retention policy keeps the latest versions of the modules of a namespace and optionally all versions
+uploaded within a duration, modules of namespaces without policy are never deleted. Versions pinned by workspaces
+are kept as well if the output of "boring-registry pins --output=json" is passed with --retention-pins.
+Only released versions count towards keep-last, versions scheduled in the future or pending approval in an
+--approval-namespace are always kept.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		retention, err := parseRetention(flagRetention)
+		if err != nil {
+			return err
+		}
+		if len(retention) == 0 {
+			return usageError{errors.New("expected at least one --retention policy")}
+		}
+
+		pinned, err := readPinnedVersions(flagRetentionPins)
+		if err != nil {
+			return err
+		}
+
+		storage, err := setupModuleStorage()
+		if err != nil {
+			return errors.Wrap(err, "failed to setup storage")
+		}
+
+		result, err := pruneVersions(context.Background(), storage, retention, pinned, flagGCDryRun)
+		if err != nil {
+			return err
+		}
+
+		if flagOutput == outputJSON {
+			return printJSON(os.Stdout, result)
+		}
+
+		return result.print(os.Stdout)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(gcCmd)
+	addRetentionFlags(gcCmd.Flags())
+	addRetentionFlags(serverCmd.Flags())
+	gcCmd.Flags().BoolVar(&flagGCDryRun, "dry-run", false, "Only print the module versions which would be deleted")
+	gcCmd.Flags().StringVar(&flagApprovalNamespace, "approval-namespace", "", "Comma-separated string of namespaces whose module versions aren't released until they are approved")
+	serverCmd.Flags().DurationVar(&flagRetentionInterval, "retention-interval", 0, "Interval the server deletes module versions which aren't kept by the --retention policies in, 0 disables it")
+}
+
+func addRetentionFlags(flags *pflag.FlagSet) {
+	flags.StringArrayVar(&flagRetention, "retention", nil, "Retention policy of a namespace, e.g. tier=keep-last=5,keep-within=2160h to keep the 5 latest versions and all versions of the last 90 days (can be repeated)")
+	flags.StringVar(&flagRetentionPins, "retention-pins", "", "JSON output of the pins command, whose module versions are always kept")
+}
+
+// setupRetention returns the retention policies the server applies every --retention-interval, nil if it doesn't delete versions.
+func setupRetention() (map[string]module.RetentionPolicy, error) {
+	if flagRetentionInterval < 0 {
+		return nil, usageError{errors.New("--retention-interval must not be negative")}
+	}
+	if flagRetentionInterval == 0 {
+		if len(flagRetention) > 0 || flagRetentionPins != "" {
+			return nil, usageError{errors.New("--retention and --retention-pins require --retention-interval")}
+		}
+		return nil, nil
+	}
+
+	retention, err := parseRetention(flagRetention)
+	if err != nil {
+		return nil, err
+	}
+	if len(retention) == 0 {
+		return nil, usageError{errors.New("--retention-interval requires at least one --retention policy")}
+	}
+
+	// Unreadable pins fail on startup instead of in the background
+	if _, err := readPinnedVersions(flagRetentionPins); err != nil {
+		return nil, err
+	}
+
+	return retention, nil
+}
+
+// parseRetention parses the NAMESPACE=keep-last=N,keep-within=DURATION policies of the --retention flag.
+func parseRetention(entries []string) (map[string]module.RetentionPolicy, error) {
+	retention := make(map[string]module.RetentionPolicy)
+
+	for _, raw := range entries {
+		parts := strings.SplitN(raw, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, usageError{fmt.Errorf("invalid retention policy %q, expected NAMESPACE=keep-last=N,keep-within=DURATION", raw)}
+		}
+
+		var policy module.RetentionPolicy
+		for _, option := range strings.Split(parts[1], ",") {
+			kv := strings.SplitN(option, "=", 2)
+			if len(kv) != 2 {
+				return nil, usageError{fmt.Errorf("invalid option %q of retention policy %q, expected KEY=VALUE", option, raw)}
+			}
+
+			var err error
+			switch kv[0] {
+			case "keep-last":
+				policy.KeepLast, err = strconv.Atoi(kv[1])
+			case "keep-within":
+				policy.KeepWithin, err = time.ParseDuration(kv[1])
+			default:
+				err = fmt.Errorf("unknown option, expected keep-last or keep-within")
+			}
+			if err != nil {
+				return nil, usageError{errors.Wrapf(err, "invalid option %q of retention policy %q", option, raw)}
+			}
+		}
+
+		if policy.KeepLast < 1 || policy.KeepWithin < 0 {
+			return nil, usageError{fmt.Errorf("retention policy %q must keep at least the latest version", raw)}
+		}
+
+		retention[parts[0]] = policy
+	}
+
+	return retention, nil
+}
+
+// readPinnedVersions reads the module versions of the JSON output of the pins command, it returns nil without file.
+func readPinnedVersions(path string) (module.PinnedVersions, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var result pinsResult
+	if err := json.Unmarshal(b, &result); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s", path)
+	}
+
+	pinned := make(module.PinnedVersions)
+	for _, p := range result.Pins {
+		if p.Kind == pinKindModule {
+			pinned[fmt.Sprintf("%s/%s", p.Source, p.Version)] = true
+		}
+	}
+
+	return pinned, nil
+}
+
+// gcResult is the machine-readable result of the gc command.
+type gcResult struct {
+	Versions []module.PrunedVersion `json:"versions"`
+}
+
+// pruneVersions applies the retention policies to their namespaces in alphabetical order.
+func pruneVersions(ctx context.Context, storage module.Storage, retention map[string]module.RetentionPolicy, pinned module.PinnedVersions, dryRun bool) (*gcResult, error) {
+	namespaces := make([]string, 0, len(retention))
+	for namespace := range retention {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+
+	approval := make(map[string]bool)
+	for _, namespace := range splitKeys(flagApprovalNamespace) {
+		approval[namespace] = true
+	}
+
+	result := &gcResult{Versions: []module.PrunedVersion{}}
+	for _, namespace := range namespaces {
+		versions, err := module.PruneVersions(ctx, storage, namespace, retention[namespace], pinned, time.Now(), dryRun, module.WithPruneApproval(approval[namespace]))
+		result.Versions = append(result.Versions, versions...)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+func (r *gcResult) print(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "MODULE\tVERSION\tPUBLISHED\tSTATUS\n")
+	for _, v := range r.Versions {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", v.Module, v.Version, v.PublishedAt.Format(time.RFC3339), v.Status)
+	}
+	return tw.Flush()
+}
+
+// collectGarbage deletes the module versions which aren't kept by the retention policies every --retention-interval.
+// The pins are read again on every run, so they can be updated while the server is running.
+func collectGarbage(storage module.Storage, retention map[string]module.RetentionPolicy) {
+	ticker := time.NewTicker(flagRetentionInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		pinned, err := readPinnedVersions(flagRetentionPins)
+		if err != nil {
+			_ = level.Error(logger).Log("msg", "failed to read pinned module versions", "err", err)
+			continue
+		}
+
+		result, err := pruneVersions(context.Background(), storage, retention, pinned, false)
+		for _, v := range result.Versions {
+			_ = level.Info(logger).Log(
+				"msg", "deleted module version",
+				"module", v.Module,
+				"version", v.Version,
+				"published", v.PublishedAt,
+			)
+		}
+		if err != nil {
+			_ = level.Error(logger).Log("msg", "failed to delete module versions", "err", err)
+		}
+	}
+}
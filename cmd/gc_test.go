@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/TierMobility/boring-registry/pkg/module"
+)
+
+func TestParseRetention(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		entries   []string
+		expected  map[string]module.RetentionPolicy
+		expectErr bool
+	}{
+		{
+			name:     "empty",
+			expected: map[string]module.RetentionPolicy{},
+		},
+		{
+			name:    "policies",
+			entries: []string{"tier=keep-last=5,keep-within=2160h", "payments=keep-last=10"},
+			expected: map[string]module.RetentionPolicy{
+				"tier":     {KeepLast: 5, KeepWithin: 90 * 24 * time.Hour},
+				"payments": {KeepLast: 10},
+			},
+		},
+		{
+			name:      "missing policy",
+			entries:   []string{"tier="},
+			expectErr: true,
+		},
+		{
+			name:      "nothing kept",
+			entries:   []string{"tier=keep-within=720h"},
+			expectErr: true,
+		},
+		{
+			name:      "unknown option",
+			entries:   []string{"tier=keep-last=5,keep-pinned=true"},
+			expectErr: true,
+		},
+		{
+			name:      "invalid duration",
+			entries:   []string{"tier=keep-last=5,keep-within=90d"},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+
+			retention, err := parseRetention(tc.entries)
+			if tc.expectErr {
+				assert.Error(err)
+				assert.IsType(usageError{}, err)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(tc.expected, retention)
+		})
+	}
+}
+
+func TestReadPinnedVersions(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "pins.json")
+	assert.NoError(ioutil.WriteFile(path, []byte(`{
+  "pins": [
+    {"workspace": "infra/prod", "kind": "module", "source": "tier/vpc/aws", "version": "1.2.0", "status": "outdated"},
+    {"workspace": "infra/prod", "kind": "provider", "source": "tier/aws", "version": "4.0.0", "status": "latest"}
+  ]
+}`), 0600))
+
+	pinned, err := readPinnedVersions(path)
+	assert.NoError(err)
+	assert.Equal(module.PinnedVersions{"tier/vpc/aws/1.2.0": true}, pinned)
+
+	pinned, err = readPinnedVersions("")
+	assert.NoError(err)
+	assert.Nil(pinned)
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChangedFiles(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	t.Parallel()
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "boring-registry-git")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"vpc", "vpc-peering", "dns"} {
+		assert.NoError(os.MkdirAll(filepath.Join(dir, "modules", name), 0755))
+		assert.NoError(ioutil.WriteFile(filepath.Join(dir, "modules", name, "main.tf"), []byte("# "+name+"\n"), 0644))
+	}
+
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "-A"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "initial"},
+		{"tag", "base"},
+	} {
+		_, err := git(dir, args...)
+		assert.NoError(err)
+	}
+
+	assert.NoError(ioutil.WriteFile(filepath.Join(dir, "modules", "vpc-peering", "main.tf"), []byte("# changed\n"), 0644))
+
+	files, err := changedFiles(filepath.Join(dir, "modules"), "base")
+	assert.NoError(err)
+	assert.Len(files, 1)
+
+	testCases := []struct {
+		module   string
+		expected bool
+	}{
+		{module: "vpc-peering", expected: true},
+		{module: "vpc", expected: false},
+		{module: "dns", expected: false},
+	}
+
+	for _, tc := range testCases {
+		changed, err := moduleChanged(filepath.Join(dir, "modules", tc.module), files)
+		assert.NoError(err)
+		assert.Equal(tc.expected, changed, tc.module)
+	}
+
+	_, err = changedFiles(dir, "does-not-exist")
+	assert.Error(err)
+}
@@ -0,0 +1,329 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	gcs "cloud.google.com/go/storage"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const (
+	storageTypeS3  = "s3"
+	storageTypeGCS = "gcs"
+)
+
+var (
+	flagInitFile  string
+	flagInitForce bool
+)
+
+var initCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Interactively generate a server configuration",
+	Long: `Interactively generate a server configuration.
+
+The configuration is written as a file of environment variables in the format
+expected by "docker run --env-file". Values are written verbatim without quoting.
+Prefixes inside a bucket do not have to be created upfront.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := os.Stat(flagInitFile); err == nil && !flagInitForce {
+			return usageError{fmt.Errorf("%s already exists, use --force to overwrite it", flagInitFile)}
+		}
+
+		// The questions are asked on stderr if stdout is reserved for the machine-readable output
+		p := &prompter{r: bufio.NewReader(os.Stdin), w: os.Stdout}
+		if flagOutput == outputJSON {
+			p.w = os.Stderr
+		}
+
+		cfg, err := promptServerConfig(p)
+		if err != nil {
+			return err
+		}
+
+		if err := cfg.validate(); err != nil {
+			return usageError{err}
+		}
+
+		createBucket := p.confirm("Create the bucket now?", false)
+
+		// Write the configuration before touching the storage backend,
+		// so an unwritable file doesn't leave behind a bucket without configuration.
+		buf := new(bytes.Buffer)
+		if err := cfg.write(buf); err != nil {
+			return err
+		}
+
+		if err := ioutil.WriteFile(flagInitFile, buf.Bytes(), 0600); err != nil {
+			return err
+		}
+
+		result := &initResult{File: flagInitFile, Bucket: cfg.bucket}
+		if createBucket {
+			if err := cfg.createBucket(context.Background()); err != nil {
+				return errors.Wrap(err, "failed to create bucket")
+			}
+			result.BucketCreated = true
+		}
+
+		if flagOutput == outputJSON {
+			return printJSON(os.Stdout, result)
+		}
+
+		return result.print(os.Stdout)
+	},
+}
+
+// initResult is the machine-readable result of the init command.
+type initResult struct {
+	File          string `json:"file"`
+	Bucket        string `json:"bucket"`
+	BucketCreated bool   `json:"bucket_created"`
+}
+
+func (r *initResult) print(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "Configuration written to %s\n", r.File); err != nil {
+		return err
+	}
+	if r.BucketCreated {
+		if _, err := fmt.Fprintf(w, "Bucket %s created\n", r.Bucket); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(initCmd)
+	initCmd.Flags().StringVar(&flagInitFile, "file", "boring-registry.env", "File to write the configuration to")
+	initCmd.Flags().BoolVar(&flagInitForce, "force", false, "Overwrite the configuration file if it already exists")
+}
+
+// serverConfig holds the answers given during init.
+type serverConfig struct {
+	storageType string
+	bucket      string
+	prefix      string
+	region      string
+	endpoint    string
+	pathStyle   bool
+	gcsProject  string
+	apiKeys     string
+	tlsCertFile string
+	tlsKeyFile  string
+}
+
+func promptServerConfig(p *prompter) (*serverConfig, error) {
+	cfg := &serverConfig{}
+
+	cfg.storageType = p.choice("Storage backend", []string{storageTypeS3, storageTypeGCS}, storageTypeS3)
+	cfg.bucket = p.ask("Bucket name", "")
+	cfg.prefix = p.ask("Bucket prefix", "")
+
+	switch cfg.storageType {
+	case storageTypeS3:
+		cfg.region = p.ask("Bucket region (leave empty to detect it automatically)", "")
+		cfg.endpoint = p.ask("Custom S3 endpoint, e.g. for MINIO (leave empty for AWS)", "")
+		if cfg.endpoint != "" {
+			cfg.pathStyle = p.confirm("Use path style addressing?", true)
+		}
+	case storageTypeGCS:
+		cfg.gcsProject = p.ask("GCP project (only needed to create the bucket)", os.Getenv("GOOGLE_CLOUD_PROJECT"))
+	}
+
+	cfg.apiKeys = p.ask("Comma-separated API keys (leave empty to disable authentication)", "")
+
+	if p.confirm("Enable TLS?", false) {
+		cfg.tlsCertFile = p.ask("TLS certificate file", "")
+		cfg.tlsKeyFile = p.ask("TLS private key file", "")
+	}
+
+	return cfg, p.err
+}
+
+func (c *serverConfig) validate() error {
+	if c.bucket == "" {
+		return errors.New("bucket name must not be empty")
+	}
+
+	for _, v := range []string{c.bucket, c.prefix, c.region, c.endpoint, c.apiKeys, c.tlsCertFile, c.tlsKeyFile} {
+		if strings.ContainsAny(v, "\r\n") {
+			return errors.New("values must not contain line breaks")
+		}
+	}
+
+	if (c.tlsCertFile == "") != (c.tlsKeyFile == "") {
+		return errors.New("TLS requires both a certificate and a private key file")
+	}
+
+	for _, file := range []string{c.tlsCertFile, c.tlsKeyFile} {
+		if file == "" {
+			continue
+		}
+		if _, err := os.Stat(file); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *serverConfig) createBucket(ctx context.Context) error {
+	switch c.storageType {
+	case storageTypeS3:
+		sess, err := session.NewSession()
+		if err != nil {
+			return err
+		}
+
+		cfg := aws.NewConfig()
+		if c.region != "" {
+			cfg = cfg.WithRegion(c.region)
+		}
+		if c.endpoint != "" {
+			cfg = cfg.WithEndpoint(c.endpoint).WithS3ForcePathStyle(c.pathStyle)
+		}
+
+		input := &s3.CreateBucketInput{
+			Bucket: aws.String(c.bucket),
+		}
+
+		// us-east-1 is the default location and must not be passed as constraint
+		if c.region != "" && c.region != "us-east-1" {
+			input.CreateBucketConfiguration = &s3.CreateBucketConfiguration{
+				LocationConstraint: aws.String(c.region),
+			}
+		}
+
+		_, err = s3.New(sess, cfg).CreateBucketWithContext(ctx, input)
+		return err
+	case storageTypeGCS:
+		if c.gcsProject == "" {
+			return errors.New("GCP project must not be empty")
+		}
+
+		client, err := gcs.NewClient(ctx)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+
+		return client.Bucket(c.bucket).Create(ctx, c.gcsProject, nil)
+	default:
+		return fmt.Errorf("unknown storage backend: %s", c.storageType)
+	}
+}
+
+// write writes the configuration as environment variables understood by the server.
+func (c *serverConfig) write(w io.Writer) error {
+	vars := [][2]string{}
+
+	switch c.storageType {
+	case storageTypeS3:
+		vars = append(vars,
+			[2]string{"storage-s3-bucket", c.bucket},
+			[2]string{"storage-s3-prefix", c.prefix},
+			[2]string{"storage-s3-region", c.region},
+			[2]string{"storage-s3-endpoint", c.endpoint},
+		)
+		if c.pathStyle {
+			vars = append(vars, [2]string{"storage-s3-pathstyle", "true"})
+		}
+	case storageTypeGCS:
+		vars = append(vars,
+			[2]string{"storage-gcs-bucket", c.bucket},
+			[2]string{"storage-gcs-prefix", c.prefix},
+		)
+	}
+
+	vars = append(vars,
+		[2]string{"api-key", c.apiKeys},
+		[2]string{"tls-cert-file", c.tlsCertFile},
+		[2]string{"tls-key-file", c.tlsKeyFile},
+	)
+
+	for _, v := range vars {
+		if v[1] == "" {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "%s=%s\n", envVarName(v[0]), v[1]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// prompter asks questions on the terminal.
+// The first read error is recorded and all following questions return their defaults.
+type prompter struct {
+	r   *bufio.Reader
+	w   io.Writer
+	err error
+}
+
+func (p *prompter) ask(question, def string) string {
+	if p.err != nil {
+		return def
+	}
+
+	if def != "" {
+		fmt.Fprintf(p.w, "%s [%s]: ", question, def)
+	} else {
+		fmt.Fprintf(p.w, "%s: ", question)
+	}
+
+	line, err := p.r.ReadString('\n')
+	if err != nil && !(err == io.EOF && line != "") {
+		p.err = err
+		return def
+	}
+
+	if answer := strings.TrimSpace(line); answer != "" {
+		return answer
+	}
+
+	return def
+}
+
+func (p *prompter) choice(question string, choices []string, def string) string {
+	for {
+		answer := p.ask(fmt.Sprintf("%s (%s)", question, strings.Join(choices, "/")), def)
+		for _, c := range choices {
+			if strings.EqualFold(answer, c) {
+				return c
+			}
+		}
+		if p.err != nil {
+			return def
+		}
+		fmt.Fprintf(p.w, "Please choose one of: %s\n", strings.Join(choices, ", "))
+	}
+}
+
+func (p *prompter) confirm(question string, def bool) bool {
+	d := "n"
+	if def {
+		d = "y"
+	}
+
+	switch strings.ToLower(p.ask(fmt.Sprintf("%s (y/n)", question), d)) {
+	case "y", "yes":
+		return true
+	case "n", "no":
+		return false
+	default:
+		return def
+	}
+}
@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testPrompter(input string) *prompter {
+	return &prompter{
+		r: bufio.NewReader(strings.NewReader(input)),
+		w: ioutil.Discard,
+	}
+}
+
+func TestPrompter_Choice(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	out := new(bytes.Buffer)
+	p := testPrompter("foo\nGCS\n")
+	p.w = out
+
+	assert.Equal(storageTypeGCS, p.choice("Storage backend", []string{storageTypeS3, storageTypeGCS}, storageTypeS3))
+	assert.NoError(p.err)
+	assert.Contains(out.String(), "Please choose one of: s3, gcs")
+}
+
+func TestPrompter_EOF(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	p := testPrompter("my-bucket")
+
+	assert.Equal("my-bucket", p.ask("Bucket name", ""))
+	assert.NoError(p.err)
+
+	assert.Equal("default", p.ask("Bucket prefix", "default"))
+	assert.Equal(io.EOF, p.err)
+
+	assert.Equal(storageTypeS3, p.choice("Storage backend", []string{storageTypeS3, storageTypeGCS}, storageTypeS3))
+	assert.True(p.confirm("Enable TLS?", true))
+}
+
+func TestServerConfig_Write(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		cfg      serverConfig
+		expected string
+	}{
+		{
+			name: "s3 storage",
+			cfg: serverConfig{
+				storageType: storageTypeS3,
+				bucket:      "registry",
+				prefix:      "terraform",
+				endpoint:    "https://minio.example.com",
+				pathStyle:   true,
+				apiKeys:     "foo,bar",
+			},
+			expected: `BORING_REGISTRY_STORAGE_S3_BUCKET=registry
+BORING_REGISTRY_STORAGE_S3_PREFIX=terraform
+BORING_REGISTRY_STORAGE_S3_ENDPOINT=https://minio.example.com
+BORING_REGISTRY_STORAGE_S3_PATHSTYLE=true
+BORING_REGISTRY_API_KEY=foo,bar
+`,
+		},
+		{
+			name: "gcs storage",
+			cfg: serverConfig{
+				storageType: storageTypeGCS,
+				bucket:      "registry",
+				gcsProject:  "my-project",
+				tlsCertFile: "tls.crt",
+				tlsKeyFile:  "tls.key",
+			},
+			expected: `BORING_REGISTRY_STORAGE_GCS_BUCKET=registry
+BORING_REGISTRY_TLS_CERT_FILE=tls.crt
+BORING_REGISTRY_TLS_KEY_FILE=tls.key
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			assert.NoError(t, tc.cfg.write(buf))
+			assert.Equal(t, tc.expected, buf.String())
+		})
+	}
+}
+
+func TestServerConfig_Validate(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	cert := filepath.Join(dir, "tls.crt")
+	key := filepath.Join(dir, "tls.key")
+	for _, f := range []string{cert, key} {
+		assert.NoError(t, ioutil.WriteFile(f, nil, 0600))
+	}
+
+	testCases := []struct {
+		name        string
+		cfg         serverConfig
+		expectError bool
+	}{
+		{
+			name: "valid config",
+			cfg:  serverConfig{storageType: storageTypeS3, bucket: "registry"},
+		},
+		{
+			name:        "missing bucket",
+			cfg:         serverConfig{storageType: storageTypeS3},
+			expectError: true,
+		},
+		{
+			name:        "line break in value",
+			cfg:         serverConfig{storageType: storageTypeS3, bucket: "registry", apiKeys: "foo\nbar"},
+			expectError: true,
+		},
+		{
+			name: "valid tls",
+			cfg:  serverConfig{storageType: storageTypeS3, bucket: "registry", tlsCertFile: cert, tlsKeyFile: key},
+		},
+		{
+			name:        "tls certificate only",
+			cfg:         serverConfig{storageType: storageTypeS3, bucket: "registry", tlsCertFile: cert},
+			expectError: true,
+		},
+		{
+			name:        "tls key only",
+			cfg:         serverConfig{storageType: storageTypeS3, bucket: "registry", tlsKeyFile: key},
+			expectError: true,
+		},
+		{
+			name:        "missing tls files",
+			cfg:         serverConfig{storageType: storageTypeS3, bucket: "registry", tlsCertFile: cert + ".missing", tlsKeyFile: key},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.cfg.validate()
+			if tc.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
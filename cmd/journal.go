@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-kit/kit/log/level"
+
+	"github.com/TierMobility/boring-registry/pkg/module"
+)
+
+var flagUploadJournalDir string
+
+func init() {
+	serverCmd.Flags().StringVar(&flagUploadJournalDir, "upload-journal-dir", "", "Directory on a persistent disk uploads are journaled in, so uploads interrupted by a crash are completed on the next start")
+}
+
+// setupJournal returns the journal of uploads, which is nil without --upload-journal-dir.
+func setupJournal() (module.Journal, error) {
+	if flagUploadJournalDir == "" {
+		return nil, nil
+	}
+
+	return module.NewFileJournal(flagUploadJournalDir)
+}
+
+// replayJournal completes the uploads interrupted by a crash, failed entries are replayed again on the next start.
+func replayJournal(storage module.Storage, journal module.Journal) {
+	replayed, err := module.ReplayJournal(context.Background(), storage, journal)
+	for _, entry := range replayed {
+		_ = level.Warn(logger).Log(
+			"msg", "completed interrupted upload",
+			"module", fmt.Sprintf("%s/%s/%s", entry.Namespace, entry.Name, entry.Provider),
+			"version", entry.Version,
+			"started", entry.StartedAt,
+		)
+	}
+	if err != nil {
+		_ = level.Error(logger).Log("msg", "failed to replay upload journal", "err", err)
+	}
+}
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	keychainService = projectName
+)
+
+var (
+	errKeychainUnavailable = errors.New("no supported credential store found")
+	errKeychainNotFound    = errors.New("token not found in credential store")
+)
+
+// keychain stores tokens in the credential store of the operating system.
+type keychain interface {
+	get(account string) (string, error)
+	set(account, token string) error
+	delete(account string) error
+}
+
+// osKeychain is the credential store of the current platform.
+var osKeychain keychain = newOSKeychain()
+
+func newOSKeychain() keychain {
+	switch runtime.GOOS {
+	case "darwin":
+		return &macOSKeychain{}
+	case "linux", "freebsd", "openbsd":
+		return &secretServiceKeychain{}
+	default:
+		return unavailableKeychain{}
+	}
+}
+
+// macOSKeychain uses the security command to access the macOS Keychain.
+type macOSKeychain struct{}
+
+func (k *macOSKeychain) get(account string) (string, error) {
+	out, err := runKeychainCommand(nil, "security", "find-generic-password", "-s", keychainService, "-a", account, "-w")
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", errKeychainNotFound
+		}
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+func (k *macOSKeychain) set(account, token string) error {
+	// security has no way to read the secret from stdin when running non-interactively
+	_, err := runKeychainCommand(nil, "security", "add-generic-password", "-U", "-s", keychainService, "-a", account, "-w", token)
+	return err
+}
+
+func (k *macOSKeychain) delete(account string) error {
+	_, err := runKeychainCommand(nil, "security", "delete-generic-password", "-s", keychainService, "-a", account)
+	return err
+}
+
+// secretServiceKeychain uses secret-tool to access the Secret Service API (GNOME Keyring, KWallet).
+type secretServiceKeychain struct{}
+
+func (k *secretServiceKeychain) get(account string) (string, error) {
+	out, err := runKeychainCommand(nil, "secret-tool", "lookup", "service", keychainService, "account", account)
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", errKeychainNotFound
+		}
+		return "", err
+	}
+	if out == "" {
+		return "", errKeychainNotFound
+	}
+	return out, nil
+}
+
+func (k *secretServiceKeychain) set(account, token string) error {
+	label := fmt.Sprintf("%s token (%s)", projectName, account)
+	_, err := runKeychainCommand(strings.NewReader(token), "secret-tool", "store", "--label", label, "service", keychainService, "account", account)
+	return err
+}
+
+func (k *secretServiceKeychain) delete(account string) error {
+	_, err := runKeychainCommand(nil, "secret-tool", "clear", "service", keychainService, "account", account)
+	return err
+}
+
+// unavailableKeychain is used on platforms without a supported credential store.
+type unavailableKeychain struct{}
+
+func (unavailableKeychain) get(string) (string, error) { return "", errKeychainUnavailable }
+func (unavailableKeychain) set(string, string) error   { return errKeychainUnavailable }
+func (unavailableKeychain) delete(string) error        { return errKeychainUnavailable }
+
+func runKeychainCommand(stdin io.Reader, name string, args ...string) (string, error) {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "", errKeychainUnavailable
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(path, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.Stdin = stdin
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", errors.Wrap(err, msg)
+		}
+		return "", err
+	}
+
+	return stdout.String(), nil
+}
@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+var (
+	flagListenReusePort bool
+	flagShutdownTimeout time.Duration
+)
+
+func init() {
+	serverCmd.Flags().BoolVar(&flagListenReusePort, "listen-reuse-port", false, "Listen with SO_REUSEPORT, so a new server process can take over the address while the old one drains its requests")
+	serverCmd.Flags().DurationVar(&flagShutdownTimeout, "shutdown-timeout", 30*time.Second, "Time to wait for in-flight requests to complete on shutdown")
+}
+
+// listen announces on the TCP address, with SO_REUSEPORT if --listen-reuse-port is set.
+func listen(ctx context.Context, addr string) (net.Listener, error) {
+	lc := net.ListenConfig{}
+	if flagListenReusePort {
+		lc.Control = reusePort
+	}
+
+	return lc.Listen(ctx, "tcp", addr)
+}
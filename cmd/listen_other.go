@@ -0,0 +1,14 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package cmd
+
+import (
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// reusePort fails on platforms without SO_REUSEPORT.
+func reusePort(network, address string, c syscall.RawConn) error {
+	return errors.New("--listen-reuse-port is not supported on this platform")
+}
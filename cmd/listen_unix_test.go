@@ -0,0 +1,32 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package cmd
+
+import (
+	"context"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReusePort(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	lc := net.ListenConfig{Control: reusePort}
+
+	first, err := lc.Listen(context.Background(), "tcp", "127.0.0.1:0")
+	assert.NoError(err)
+	defer first.Close()
+
+	// A second process, e.g. the upgraded binary, can bind the same address
+	second, err := lc.Listen(context.Background(), "tcp", first.Addr().String())
+	assert.NoError(err)
+	defer second.Close()
+
+	// Without SO_REUSEPORT the address is in use
+	_, err = net.Listen("tcp", first.Addr().String())
+	assert.ErrorIs(err, syscall.EADDRINUSE)
+}
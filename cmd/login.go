@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"gopkg.in/ini.v1"
+)
+
+var loginCmd = &cobra.Command{
+	Use:   "login",
+	Short: "Store a registry token for the selected profile",
+	Long: `Store a registry token for the selected profile.
+
+The token is read from stdin and stored in the credential store of the operating system
+(macOS Keychain or the Secret Service API on Linux). If no credential store is available,
+the token is written to the configuration file with restricted permissions instead.
+Commands with a --token flag use the stored token of the selected profile if the flag is not set.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		profile := currentProfile()
+
+		fmt.Fprintf(os.Stderr, "Token for profile %s: ", profile)
+		token, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if token = strings.TrimSpace(token); token == "" {
+			if err != nil {
+				return usageError{errors.Wrap(err, "failed to read token")}
+			}
+			return usageError{errors.New("token must not be empty")}
+		}
+
+		result, err := storeToken(profile, token)
+		if err != nil {
+			return err
+		}
+
+		if flagOutput == outputJSON {
+			return printJSON(os.Stdout, result)
+		}
+
+		return result.print(os.Stdout)
+	},
+}
+
+var logoutCmd = &cobra.Command{
+	Use:   "logout",
+	Short: "Remove the stored registry token of the selected profile",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		profile := currentProfile()
+
+		result, err := removeToken(profile)
+		if err != nil {
+			return err
+		}
+
+		if flagOutput == outputJSON {
+			return printJSON(os.Stdout, result)
+		}
+
+		return result.print(os.Stdout)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(loginCmd)
+	rootCmd.AddCommand(logoutCmd)
+}
+
+// Token stores of the login result.
+const (
+	tokenStoreKeychain = "keychain"
+	tokenStoreFile     = "file"
+)
+
+// loginResult is the machine-readable result of the login and logout commands.
+type loginResult struct {
+	Profile string `json:"profile"`
+	// Store is where the token was stored, it's empty after logout.
+	Store string `json:"store,omitempty"`
+	// File is the configuration file holding the token if the credential store isn't available.
+	File string `json:"file,omitempty"`
+}
+
+func (r *loginResult) print(w io.Writer) error {
+	var err error
+	switch r.Store {
+	case tokenStoreKeychain:
+		_, err = fmt.Fprintf(w, "Token of profile %s stored in the credential store\n", r.Profile)
+	case tokenStoreFile:
+		_, err = fmt.Fprintf(w, "Token of profile %s stored in %s\n", r.Profile, r.File)
+	default:
+		_, err = fmt.Fprintf(w, "Token of profile %s removed\n", r.Profile)
+	}
+	return err
+}
+
+// storeToken stores the token of a profile in the credential store, or in the configuration file if there is none.
+func storeToken(profile, token string) (*loginResult, error) {
+	if err := osKeychain.set(profile, token); err != nil {
+		_ = level.Warn(logger).Log(
+			"msg", "failed to store token in credential store, falling back to the configuration file",
+			"file", flagConfigFile,
+			"err", err,
+		)
+
+		if err := writeProfileToken(flagConfigFile, profile, token); err != nil {
+			return nil, err
+		}
+
+		return &loginResult{Profile: profile, Store: tokenStoreFile, File: flagConfigFile}, nil
+	}
+
+	// A plaintext token of the profile would take precedence over the credential store
+	if err := writeProfileToken(flagConfigFile, profile, ""); err != nil {
+		return nil, err
+	}
+
+	return &loginResult{Profile: profile, Store: tokenStoreKeychain}, nil
+}
+
+// removeToken removes the token of a profile from the credential store and the configuration file.
+func removeToken(profile string) (*loginResult, error) {
+	if err := osKeychain.delete(profile); err != nil && !errors.Is(err, errKeychainUnavailable) {
+		_ = level.Debug(logger).Log("msg", "failed to delete token from credential store", "err", err)
+	}
+
+	if err := writeProfileToken(flagConfigFile, profile, ""); err != nil {
+		return nil, err
+	}
+
+	return &loginResult{Profile: profile}, nil
+}
+
+// applyStoredToken sets the --token flag of cmd to the token stored in the credential store
+// if neither the flag, an environment variable nor the profile provide a token.
+func applyStoredToken(cmd *cobra.Command) {
+	f := cmd.Flags().Lookup("token")
+	if f == nil || f.Changed || f.Value.String() != "" {
+		return
+	}
+
+	token, err := osKeychain.get(currentProfile())
+	if err != nil {
+		return
+	}
+
+	_ = cmd.Flags().Set(f.Name, token)
+}
+
+// writeProfileToken sets the token of a profile in the configuration file, an empty token removes it.
+func writeProfileToken(file, profile, token string) error {
+	if file == "" {
+		return usageError{errors.New("no configuration file given")}
+	}
+
+	cfg := ini.Empty()
+	if _, err := os.Stat(file); err == nil {
+		if cfg, err = ini.Load(file); err != nil {
+			return errors.Wrap(err, "failed to read config file")
+		}
+	} else if token == "" {
+		return nil
+	}
+
+	if token == "" {
+		cfg.Section(profile).DeleteKey("token")
+	} else {
+		cfg.Section(profile).Key("token").SetValue(token)
+	}
+
+	buf := new(bytes.Buffer)
+	if _, err := cfg.WriteTo(buf); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(file, buf.Bytes(), 0600); err != nil {
+		return err
+	}
+
+	// WriteFile keeps the permissions of existing files
+	return os.Chmod(file, 0600)
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeKeychain map[string]string
+
+func (k fakeKeychain) get(account string) (string, error) {
+	token, ok := k[account]
+	if !ok {
+		return "", errKeychainNotFound
+	}
+	return token, nil
+}
+
+func (k fakeKeychain) set(account, token string) error {
+	k[account] = token
+	return nil
+}
+
+func (k fakeKeychain) delete(account string) error {
+	delete(k, account)
+	return nil
+}
+
+func TestApplyStoredToken(t *testing.T) {
+	defer func(k keychain) { osKeychain = k }(osKeychain)
+	osKeychain = fakeKeychain{"default": "stored-token"}
+
+	testCases := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{name: "stored token", args: nil, expected: "stored-token"},
+		{name: "flag takes precedence", args: []string{"--token=flag-token"}, expected: "flag-token"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			cmd := &cobra.Command{}
+			cmd.Flags().String("token", "", "")
+			assert.NoError(cmd.ParseFlags(tc.args))
+
+			applyStoredToken(cmd)
+
+			token, err := cmd.Flags().GetString("token")
+			assert.NoError(err)
+			assert.Equal(tc.expected, token)
+		})
+	}
+}
+
+func TestWriteProfileToken(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "boring-registry-login")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "nested", "config")
+
+	// Removing a token without a configuration file is a no-op
+	assert.NoError(writeProfileToken(file, "staging", ""))
+	_, err = os.Stat(file)
+	assert.True(os.IsNotExist(err))
+
+	assert.NoError(writeProfileToken(file, "staging", "secret"))
+
+	fi, err := os.Stat(file)
+	assert.NoError(err)
+	assert.Equal(os.FileMode(0600), fi.Mode().Perm())
+
+	data, err := ioutil.ReadFile(file)
+	assert.NoError(err)
+	assert.Contains(string(data), "[staging]")
+	assert.Contains(string(data), "secret")
+
+	assert.NoError(writeProfileToken(file, "staging", ""))
+
+	data, err = ioutil.ReadFile(file)
+	assert.NoError(err)
+	assert.NotContains(string(data), "secret")
+}
+
+func TestStoreToken(t *testing.T) {
+	defer func(k keychain, file string) { osKeychain, flagConfigFile = k, file }(osKeychain, flagConfigFile)
+
+	dir, err := ioutil.TempDir("", "boring-registry-login")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	flagConfigFile = filepath.Join(dir, "config")
+
+	testCases := []struct {
+		name     string
+		keychain keychain
+		expected loginResult
+	}{
+		{
+			name:     "credential store",
+			keychain: fakeKeychain{},
+			expected: loginResult{Profile: "staging", Store: tokenStoreKeychain},
+		},
+		{
+			name:     "configuration file",
+			keychain: unavailableKeychain{},
+			expected: loginResult{Profile: "staging", Store: tokenStoreFile, File: filepath.Join(dir, "config")},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+			osKeychain = tc.keychain
+
+			result, err := storeToken("staging", "secret")
+			assert.NoError(err)
+			assert.Equal(&tc.expected, result)
+
+			result, err = removeToken("staging")
+			assert.NoError(err)
+			assert.Equal(&loginResult{Profile: "staging"}, result)
+		})
+	}
+}
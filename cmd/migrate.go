@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/TierMobility/boring-registry/pkg/module"
+)
+
+var (
+	flagNormalizeAddresses bool
+	flagMigrateDryRun      bool
+)
+
+var migrateAddressesCmd = &cobra.Command{
+	Use:   "migrate-addresses [flags] NAMESPACE...",
+	Short: "Move modules with mixed-case addresses to their lowercase addresses",
+	Long: `Move modules with mixed-case addresses to their lowercase addresses.
+
+Run this command for every namespace, including namespaces with mixed-case names, before enabling
+--module-normalize-addresses. Annotations, approvals and schedules are moved together with the modules.
+Module versions whose lowercase address is taken already are reported as conflicts and kept.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return usageError{errors.New("expected at least one namespace")}
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		storage, err := setupModuleStorage()
+		if err != nil {
+			return errors.Wrap(err, "failed to setup storage")
+		}
+
+		result := &migrateResult{Migrations: []module.AddressMigration{}}
+		for _, namespace := range args {
+			migrations, err := module.MigrateAddresses(context.Background(), storage, namespace, flagMigrateDryRun)
+			result.Migrations = append(result.Migrations, migrations...)
+			if err != nil {
+				return err
+			}
+		}
+
+		if flagOutput == outputJSON {
+			if err := printJSON(os.Stdout, result); err != nil {
+				return err
+			}
+		} else if err := result.print(os.Stdout); err != nil {
+			return err
+		}
+
+		if conflicts := result.conflicts(); conflicts > 0 {
+			return errors.Wrapf(module.ErrAlreadyExists, "%d module versions conflict with their lowercase address", conflicts)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(migrateAddressesCmd)
+	rootCmd.PersistentFlags().BoolVar(&flagNormalizeAddresses, "module-normalize-addresses", false, "Lowercase module addresses on upload and lookup, migrate existing modules with migrate-addresses first")
+	migrateAddressesCmd.Flags().BoolVar(&flagMigrateDryRun, "dry-run", false, "Only print the planned migrations")
+}
+
+// migrateResult is the machine-readable result of the migrate-addresses command.
+type migrateResult struct {
+	Migrations []module.AddressMigration `json:"migrations"`
+}
+
+func (r *migrateResult) conflicts() int {
+	conflicts := 0
+	for _, m := range r.Migrations {
+		if m.Status == module.MigrationStatusConflict {
+			conflicts++
+		}
+	}
+	return conflicts
+}
+
+func (r *migrateResult) print(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "FROM\tTO\tSTATUS\n")
+	for _, m := range r.Migrations {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", m.From, m.To, m.Status)
+	}
+	return tw.Flush()
+}
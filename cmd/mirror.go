@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/go-kit/kit/transport"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/TierMobility/boring-registry/pkg/auth"
+	"github.com/TierMobility/boring-registry/pkg/mirror"
+)
+
+var (
+	flagMirrorDir                string
+	flagMirrorUpstream           string
+	flagMirrorVersionConstraints []string
+	flagMirrorPlatforms          []string
+)
+
+var (
+	mirrorBehindDesc = prometheus.NewDesc(
+		"boring_registry_mirror_behind",
+		"Whether the latest upstream version of a provider isn't mirrored yet.",
+		[]string{"provider"}, nil,
+	)
+	mirrorFailuresDesc = prometheus.NewDesc(
+		"boring_registry_mirror_upstream_failures",
+		"Number of consecutive failures to query or mirror a provider from the upstream.",
+		[]string{"provider"}, nil,
+	)
+	mirrorLastSyncDesc = prometheus.NewDesc(
+		"boring_registry_mirror_last_sync_timestamp_seconds",
+		"Time an archive of a provider was last mirrored from the upstream.",
+		[]string{"provider"}, nil,
+	)
+)
+
+// mirrorMetrics exports the status of the providers of the mirror, once it is registered.
+var mirrorMetrics = &mirrorCollector{}
+
+func init() {
+	prometheus.MustRegister(mirrorMetrics)
+
+	serverCmd.Flags().StringVar(&flagMirrorDir, "mirror-dir", "", "Directory of mirrored provider archives, enables the provider network mirror under "+prefixMirror)
+	serverCmd.Flags().StringVar(&flagMirrorUpstream, "mirror-upstream", mirror.DefaultUpstreamURL, "Provider API of the registry providers are mirrored from on their first download, empty to only serve the mirror directory")
+	serverCmd.Flags().StringArrayVar(&flagMirrorVersionConstraints, "mirror-version-constraint", nil, "Version constraint of providers mirrored from the upstream, e.g. 'hashicorp/aws=>= 5.0' or '*=>= 1.0' for all providers (can be repeated)")
+	serverCmd.Flags().StringArrayVar(&flagMirrorPlatforms, "mirror-platforms", nil, "Comma-separated platforms of providers mirrored from the upstream, e.g. hashicorp/aws=linux_amd64,darwin_arm64 or *=linux_amd64 for all providers (can be repeated)")
+}
+
+// registerMirror registers the provider network mirror, which serves the archives of the directory
+// and mirrors those of the upstream registry into it.
+func registerMirror(mux *http.ServeMux, dir, upstreamURL string, apiKeys []string) error {
+	storage, err := mirror.NewLocalStorage(dir)
+	if err != nil {
+		return err
+	}
+
+	var options []mirror.ServiceOption
+	if upstreamURL != "" {
+		upstream, err := mirror.NewUpstream(upstreamURL, nil)
+		if err != nil {
+			return usageError{fmt.Errorf("invalid --mirror-upstream: %w", err)}
+		}
+		options = append(options, mirror.WithUpstream(upstream))
+	}
+
+	policy, err := parseMirrorPolicy(flagMirrorVersionConstraints, flagMirrorPlatforms)
+	if err != nil {
+		return err
+	}
+	options = append(options, mirror.WithPolicy(policy))
+
+	service := mirror.NewService(storage, options...)
+	mirrorMetrics.setService(service)
+	{
+		service = mirror.LoggingMiddleware(logger)(service)
+	}
+
+	opts := []httptransport.ServerOption{
+		httptransport.ServerErrorHandler(
+			transport.NewLogErrorHandler(logger),
+		),
+		httptransport.ServerErrorEncoder(errorReporter.reportErrors(mirror.ErrorEncoder)),
+		httptransport.ServerBefore(
+			httptransport.PopulateRequestContext,
+			auth.PopulateRequestContext,
+		),
+	}
+
+	mux.Handle(
+		fmt.Sprintf(`%s/`, prefixMirror),
+		http.StripPrefix(
+			prefixMirror,
+			mirror.MakeHandler(
+				service,
+				authMiddleware(authRouteMirror, apiKeys),
+				opts...,
+			),
+		),
+	)
+
+	return nil
+}
+
+// parseMirrorPolicy parses the PROVIDER=VALUE entries of the mirror policy flags.
+func parseMirrorPolicy(constraints, platforms []string) (*mirror.Policy, error) {
+	policy := mirror.NewPolicy()
+
+	for _, raw := range constraints {
+		parts := strings.SplitN(raw, "=", 2)
+		if len(parts) != 2 {
+			return nil, usageError{fmt.Errorf("invalid --mirror-version-constraint %q, expected PROVIDER=CONSTRAINT", raw)}
+		}
+		if err := policy.SetVersionConstraint(parts[0], parts[1]); err != nil {
+			return nil, usageError{fmt.Errorf("invalid --mirror-version-constraint: %w", err)}
+		}
+	}
+
+	for _, raw := range platforms {
+		parts := strings.SplitN(raw, "=", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return nil, usageError{fmt.Errorf("invalid --mirror-platforms %q, expected PROVIDER=PLATFORMS", raw)}
+		}
+		if err := policy.SetPlatforms(parts[0], splitKeys(parts[1])); err != nil {
+			return nil, usageError{fmt.Errorf("invalid --mirror-platforms: %w", err)}
+		}
+	}
+
+	return policy, nil
+}
+
+// mirrorCollector collects the status of the mirrored providers on every scrape.
+type mirrorCollector struct {
+	mu      sync.Mutex
+	service mirror.Service
+}
+
+func (c *mirrorCollector) setService(service mirror.Service) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.service = service
+}
+
+func (c *mirrorCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- mirrorBehindDesc
+	ch <- mirrorFailuresDesc
+	ch <- mirrorLastSyncDesc
+}
+
+func (c *mirrorCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	service := c.service
+	c.mu.Unlock()
+
+	if service == nil {
+		return
+	}
+
+	status, err := service.Status(context.Background())
+	if err != nil {
+		_ = level.Error(logger).Log(
+			"msg", "failed to collect mirror status",
+			"err", err,
+		)
+		return
+	}
+
+	for _, s := range status {
+		var behind float64
+		if s.Behind {
+			behind = 1
+		}
+		ch <- prometheus.MustNewConstMetric(mirrorBehindDesc, prometheus.GaugeValue, behind, s.Provider)
+		ch <- prometheus.MustNewConstMetric(mirrorFailuresDesc, prometheus.GaugeValue, float64(s.Failures), s.Provider)
+		if !s.LastSync.IsZero() {
+			ch <- prometheus.MustNewConstMetric(mirrorLastSyncDesc, prometheus.GaugeValue, float64(s.LastSync.Unix()), s.Provider)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/TierMobility/boring-registry/pkg/mirror"
+)
+
+func TestRegisterMirror(t *testing.T) {
+	t.Parallel()
+
+	// The packed layout written by `terraform providers mirror`
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "registry.terraform.io", "hashicorp", "random", "terraform-provider-random_3.1.0_linux_amd64.zip")
+	assert.NoError(t, os.MkdirAll(filepath.Dir(archive), 0o755))
+	assert.NoError(t, os.WriteFile(archive, []byte("data"), 0o644))
+
+	mux := http.NewServeMux()
+	assert.NoError(t, registerMirror(mux, dir, "", []string{"secret"}))
+
+	testCases := []struct {
+		name   string
+		path   string
+		apiKey string
+		status int
+	}{
+		{
+			name:   "versions",
+			path:   "/v1/mirror/registry.terraform.io/hashicorp/random/index.json",
+			apiKey: "secret",
+			status: http.StatusOK,
+		},
+		{
+			name:   "archive",
+			path:   "/v1/mirror/registry.terraform.io/hashicorp/random/terraform-provider-random_3.1.0_linux_amd64.zip",
+			apiKey: "secret",
+			status: http.StatusOK,
+		},
+		{
+			name:   "status",
+			path:   "/v1/mirror/status",
+			apiKey: "secret",
+			status: http.StatusOK,
+		},
+		{
+			name:   "not mirrored without upstream",
+			path:   "/v1/mirror/registry.terraform.io/hashicorp/null/index.json",
+			apiKey: "secret",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "missing api key",
+			path:   "/v1/mirror/registry.terraform.io/hashicorp/random/index.json",
+			status: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.apiKey != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.apiKey)
+			}
+
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+			assert.Equal(t, tc.status, rec.Code)
+		})
+	}
+}
+
+func TestRegisterMirror_InvalidUpstream(t *testing.T) {
+	t.Parallel()
+
+	err := registerMirror(http.NewServeMux(), t.TempDir(), "registry.terraform.io", nil)
+	assert.Equal(t, exitCodeUsage, exitCode(err))
+}
+
+func TestParseMirrorPolicy(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name          string
+		constraints   []string
+		platforms     []string
+		expectedError bool
+	}{
+		{name: "empty"},
+		{name: "per provider", constraints: []string{"hashicorp/aws=>= 5.0, < 6.0"}, platforms: []string{"hashicorp/aws=linux_amd64,darwin_arm64"}},
+		{name: "all providers", constraints: []string{"*=>= 1.0"}, platforms: []string{"*=linux_amd64"}},
+		{name: "missing constraint", constraints: []string{"hashicorp/aws"}, expectedError: true},
+		{name: "invalid constraint", constraints: []string{"hashicorp/aws=latest"}, expectedError: true},
+		{name: "missing platforms", platforms: []string{"hashicorp/aws="}, expectedError: true},
+		{name: "invalid provider", platforms: []string{"aws=linux_amd64"}, expectedError: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			policy, err := parseMirrorPolicy(tc.constraints, tc.platforms)
+			if tc.expectedError {
+				assert.Equal(t, exitCodeUsage, exitCode(err))
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.NotNil(t, policy)
+		})
+	}
+}
+
+type statusService struct {
+	mirror.Service
+	status []mirror.ProviderStatus
+}
+
+func (s statusService) Status(ctx context.Context) ([]mirror.ProviderStatus, error) {
+	return s.status, nil
+}
+
+func TestMirrorCollector(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	c := &mirrorCollector{}
+	registry := prometheus.NewRegistry()
+	assert.NoError(registry.Register(c))
+
+	families, err := registry.Gather()
+	assert.NoError(err)
+	assert.Empty(families)
+
+	c.setService(statusService{status: []mirror.ProviderStatus{
+		{Provider: "registry.terraform.io/hashicorp/aws", Behind: true, Failures: 2},
+		{Provider: "registry.terraform.io/hashicorp/random", LastSync: time.Unix(1715590800, 0)},
+	}})
+
+	families, err = registry.Gather()
+	assert.NoError(err)
+
+	values := make(map[string]float64)
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			values[family.GetName()+" "+m.GetLabel()[0].GetValue()] = m.GetGauge().GetValue()
+		}
+	}
+	assert.Equal(map[string]float64{
+		"boring_registry_mirror_behind registry.terraform.io/hashicorp/aws":                         1,
+		"boring_registry_mirror_behind registry.terraform.io/hashicorp/random":                      0,
+		"boring_registry_mirror_upstream_failures registry.terraform.io/hashicorp/aws":              2,
+		"boring_registry_mirror_upstream_failures registry.terraform.io/hashicorp/random":           0,
+		"boring_registry_mirror_last_sync_timestamp_seconds registry.terraform.io/hashicorp/random": 1715590800,
+	}, values)
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/TierMobility/boring-registry/pkg/module"
+)
+
+var (
+	flagDownloadCIDR      []string
+	flagTrustForwardedFor bool
+)
+
+func init() {
+	serverCmd.Flags().StringArrayVar(&flagDownloadCIDR, "download-cidr", nil, "Restrict downloads of a namespace to comma-separated networks, e.g. tier=10.0.0.0/8,192.0.2.0/24 (can be repeated)")
+	serverCmd.Flags().BoolVar(&flagTrustForwardedFor, "trust-forwarded-for", false, "Use the X-Forwarded-For header as client address, only enable behind a proxy that sets it")
+}
+
+// parseDownloadNetworks parses the NAMESPACE=CIDRS pairs of the --download-cidr flag.
+func parseDownloadNetworks(entries []string) (module.DownloadNetworks, error) {
+	networks := make(module.DownloadNetworks)
+
+	for _, raw := range entries {
+		parts := strings.SplitN(raw, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" || strings.Contains(parts[0], "/") {
+			return nil, usageError{fmt.Errorf("invalid download networks %q, expected NAMESPACE=CIDRS", raw)}
+		}
+
+		cidrs, err := parseCIDRs(splitKeys(parts[1]))
+		if err != nil {
+			return nil, err
+		}
+
+		networks[parts[0]] = append(networks[parts[0]], cidrs...)
+	}
+
+	return networks, nil
+}
+
+func parseCIDRs(raw []string) ([]*net.IPNet, error) {
+	var cidrs []*net.IPNet
+
+	for _, r := range raw {
+		_, cidr, err := net.ParseCIDR(r)
+		if err != nil {
+			return nil, usageError{fmt.Errorf("invalid network %q, expected a CIDR like 10.0.0.0/8", r)}
+		}
+		cidrs = append(cidrs, cidr)
+	}
+
+	return cidrs, nil
+}
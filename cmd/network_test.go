@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDownloadNetworks(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		entries   []string
+		expected  map[string][]string
+		expectErr bool
+	}{
+		{
+			name:     "empty",
+			expected: map[string][]string{},
+		},
+		{
+			name:     "merges entries of the same namespace",
+			entries:  []string{"tier=10.0.0.0/8,192.0.2.0/24", "tier=2001:db8::/32", "other=203.0.113.0/24"},
+			expected: map[string][]string{"tier": {"10.0.0.0/8", "192.0.2.0/24", "2001:db8::/32"}, "other": {"203.0.113.0/24"}},
+		},
+		{
+			name:      "missing networks",
+			entries:   []string{"tier="},
+			expectErr: true,
+		},
+		{
+			name:      "module instead of namespace",
+			entries:   []string{"tier/vpc/aws=10.0.0.0/8"},
+			expectErr: true,
+		},
+		{
+			name:      "address instead of network",
+			entries:   []string{"tier=10.0.0.1"},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			networks, err := parseDownloadNetworks(tc.entries)
+			if tc.expectErr {
+				assert.Equal(t, exitCodeUsage, exitCode(err))
+				return
+			}
+
+			assert.NoError(t, err)
+
+			actual := make(map[string][]string)
+			for namespace, cidrs := range networks {
+				actual[namespace] = []string{}
+				for _, cidr := range cidrs {
+					actual[namespace] = append(actual[namespace], cidr.String())
+				}
+			}
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
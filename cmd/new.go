@@ -0,0 +1,429 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"text/template"
+
+	"github.com/hashicorp/go-version"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/TierMobility/boring-registry/pkg/core"
+	"github.com/TierMobility/boring-registry/pkg/module"
+)
+
+var (
+	flagNewDir       string
+	flagNewNamespace string
+	flagNewVersion   string
+	flagNewForce     bool
+
+	flagNewModuleProvider string
+)
+
+// scaffoldNamePattern matches names that are valid in the Module and Provider Registry Protocols.
+var scaffoldNamePattern = regexp.MustCompile(`^[0-9A-Za-z][0-9A-Za-z_-]*$`)
+
+var newCmd = &cobra.Command{
+	Use:   "new",
+	Short: "Scaffold new modules and providers",
+}
+
+var newModuleCmd = &cobra.Command{
+	Use:   "module [flags] NAME",
+	Short: "Scaffold a new module",
+	Long: `Scaffold a new module.
+
+The module skeleton contains the Terraform files, a README, the boring-registry.hcl file
+expected by the upload command and a GitHub Actions workflow that publishes the module.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return usageError{errors.New("expected exactly one module name")}
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		data := scaffoldData{
+			Namespace: flagNewNamespace,
+			Name:      args[0],
+			Provider:  flagNewModuleProvider,
+			Version:   flagNewVersion,
+		}
+
+		// Modules follow the address grammar the upload command and the server enforce
+		if err := module.ValidateAddress(data.Namespace, data.Name, data.Provider, data.Version); err != nil {
+			return usageError{err}
+		}
+
+		dir := flagNewDir
+		if dir == "" {
+			dir = data.Name
+		}
+
+		return printScaffold(dir, moduleTemplates, data)
+	},
+}
+
+var newProviderCmd = &cobra.Command{
+	Use:   "provider [flags] NAME",
+	Short: "Scaffold a new provider",
+	Long: `Scaffold a new provider.
+
+The provider skeleton is based on the Terraform Plugin SDK and contains a goreleaser configuration
+and a GitHub Actions workflow that build, checksum and sign releases in the format the registry expects.
+Run "boring-registry provider release-check dist/" to validate a release before publishing it.`,
+	Args: newModuleCmd.Args,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		data := scaffoldData{
+			Namespace: flagNewNamespace,
+			Name:      args[0],
+			// The provider type of a provider is its name
+			Provider: args[0],
+			Version:  flagNewVersion,
+		}
+
+		if err := data.validate(); err != nil {
+			return usageError{err}
+		}
+
+		dir := flagNewDir
+		if dir == "" {
+			dir = core.ProviderPrefix + data.Name
+		}
+
+		return printScaffold(dir, providerTemplates, data)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(newCmd)
+	newCmd.AddCommand(newModuleCmd)
+	newCmd.AddCommand(newProviderCmd)
+
+	newCmd.PersistentFlags().StringVar(&flagNewDir, "dir", "", "Directory to create the files in, defaults to the name")
+	newCmd.PersistentFlags().StringVar(&flagNewNamespace, "namespace", "", "Namespace in the registry")
+	newCmd.PersistentFlags().StringVar(&flagNewVersion, "version", "0.1.0", "Initial version")
+	newCmd.PersistentFlags().BoolVar(&flagNewForce, "force", false, "Overwrite existing files")
+	newModuleCmd.Flags().StringVar(&flagNewModuleProvider, "provider", "", "Main provider of the module, e.g. aws")
+}
+
+// scaffoldData is passed to the scaffolding templates.
+type scaffoldData struct {
+	Namespace string
+	Name      string
+	Provider  string
+	Version   string
+}
+
+func (d scaffoldData) validate() error {
+	for _, v := range []struct{ name, value string }{
+		{"namespace", d.Namespace},
+		{"name", d.Name},
+		{"provider", d.Provider},
+	} {
+		if v.value == "" {
+			return fmt.Errorf("%s must not be empty", v.name)
+		}
+		if !scaffoldNamePattern.MatchString(v.value) {
+			return fmt.Errorf("invalid %s %q, only letters, digits, dashes and underscores are allowed", v.name, v.value)
+		}
+	}
+
+	if _, err := version.NewSemver(d.Version); err != nil {
+		return errors.Wrap(err, "invalid version")
+	}
+
+	return nil
+}
+
+// newResult is the machine-readable result of the new commands.
+type newResult struct {
+	Dir   string   `json:"dir"`
+	Files []string `json:"files"`
+}
+
+func (r *newResult) print(w io.Writer) error {
+	for _, file := range r.Files {
+		if _, err := fmt.Fprintf(w, "created %s\n", file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func printScaffold(dir string, templates map[string]string, data interface{}) error {
+	files, err := scaffold(dir, templates, data, flagNewForce)
+	if err != nil {
+		return err
+	}
+
+	result := &newResult{Dir: dir, Files: files}
+	if flagOutput == outputJSON {
+		return printJSON(os.Stdout, result)
+	}
+
+	return result.print(os.Stdout)
+}
+
+// scaffold renders the templates into dir and returns the paths of the created files.
+// Nothing is written if any of the files already exists, unless force is set.
+func scaffold(dir string, templates map[string]string, data interface{}, force bool) ([]string, error) {
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	files := make(map[string][]byte, len(templates))
+	for _, name := range names {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if _, err := os.Stat(path); err == nil && !force {
+			return nil, usageError{fmt.Errorf("%s already exists, use --force to overwrite it", path)}
+		}
+
+		tmpl, err := template.New(name).Delims("[[", "]]").Parse(templates[name])
+		if err != nil {
+			return nil, err
+		}
+
+		buf := new(bytes.Buffer)
+		if err := tmpl.Execute(buf, data); err != nil {
+			return nil, err
+		}
+		files[path] = buf.Bytes()
+	}
+
+	created := make([]string, 0, len(names))
+	for _, name := range names {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return created, err
+		}
+		if err := ioutil.WriteFile(path, files[path], 0644); err != nil {
+			return created, err
+		}
+		created = append(created, path)
+	}
+
+	return created, nil
+}
+
+// moduleTemplates are the files of a new module.
+// The templates use [[ ]] as delimiters to not clash with GitHub Actions expressions.
+var moduleTemplates = map[string]string{
+	moduleSpecFileName: `metadata {
+  namespace = "[[ .Namespace ]]"
+  name      = "[[ .Name ]]"
+  provider  = "[[ .Provider ]]"
+  version   = "[[ .Version ]]"
+}
+`,
+	"main.tf": `terraform {
+  required_version = ">= 0.13"
+}
+`,
+	"variables.tf": `# Input variables of the module.
+# See https://www.terraform.io/docs/language/values/variables.html
+`,
+	"outputs.tf": `# Output values of the module.
+# See https://www.terraform.io/docs/language/values/outputs.html
+`,
+	"README.md": `# [[ .Name ]]
+
+Describe what the module does.
+
+## Usage
+
+` + "```hcl" + `
+module "[[ .Name ]]" {
+  source  = "<registry>/[[ .Namespace ]]/[[ .Name ]]/[[ .Provider ]]"
+  version = "~> [[ .Version ]]"
+}
+` + "```" + `
+
+## Releasing
+
+Bump the version in ` + "`" + moduleSpecFileName + "`" + `. The publish workflow uploads every version that doesn't exist in the registry yet.
+`,
+	".github/workflows/publish.yml": `name: publish
+
+on:
+  push:
+    branches:
+      - main
+
+jobs:
+  publish:
+    runs-on: ubuntu-latest
+    steps:
+      - uses: actions/checkout@v2
+      - name: Publish module
+        run: |
+          docker run --rm -v "$PWD:/module" \
+            -e BORING_REGISTRY_STORAGE_S3_BUCKET \
+            -e AWS_ACCESS_KEY_ID -e AWS_SECRET_ACCESS_KEY -e AWS_REGION \
+            ghcr.io/tiermobility/boring-registry:latest \
+            upload --recursive=false /module
+        env:
+          BORING_REGISTRY_STORAGE_S3_BUCKET: ${{ secrets.BORING_REGISTRY_BUCKET }}
+          AWS_ACCESS_KEY_ID: ${{ secrets.AWS_ACCESS_KEY_ID }}
+          AWS_SECRET_ACCESS_KEY: ${{ secrets.AWS_SECRET_ACCESS_KEY }}
+          AWS_REGION: ${{ secrets.AWS_REGION }}
+`,
+}
+
+// providerTemplates are the files of a new provider.
+var providerTemplates = map[string]string{
+	"main.go": `package main
+
+import (
+	"github.com/hashicorp/terraform-plugin-sdk/v2/plugin"
+
+	"github.com/[[ .Namespace ]]/terraform-provider-[[ .Name ]]/internal/provider"
+)
+
+// version is set by goreleaser.
+var version = "dev"
+
+func main() {
+	plugin.Serve(&plugin.ServeOpts{
+		ProviderFunc: provider.New(version),
+	})
+}
+`,
+	"internal/provider/provider.go": `package provider
+
+import (
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// New returns the provider.
+func New(version string) func() *schema.Provider {
+	return func() *schema.Provider {
+		return &schema.Provider{
+			Schema:         map[string]*schema.Schema{},
+			ResourcesMap:   map[string]*schema.Resource{},
+			DataSourcesMap: map[string]*schema.Resource{},
+		}
+	}
+}
+`,
+	"go.mod": `module github.com/[[ .Namespace ]]/terraform-provider-[[ .Name ]]
+
+go 1.18
+
+require github.com/hashicorp/terraform-plugin-sdk/v2 v2.10.1
+`,
+	"terraform-registry-manifest.json": `{
+  "version": 1,
+  "metadata": {
+    "protocol_versions": ["5.0"]
+  }
+}
+`,
+	"README.md": `# terraform-provider-[[ .Name ]]
+
+Describe what the provider does.
+
+## Usage
+
+` + "```hcl" + `
+terraform {
+  required_providers {
+    [[ .Name ]] = {
+      source  = "<registry>/[[ .Namespace ]]/[[ .Name ]]"
+      version = "~> [[ .Version ]]"
+    }
+  }
+}
+` + "```" + `
+
+## Releasing
+
+Push a tag like ` + "`v[[ .Version ]]`" + `. The release workflow builds, checksums and signs the provider with goreleaser
+and validates the release with ` + "`boring-registry provider release-check`" + `.
+`,
+	".goreleaser.yml": `before:
+  hooks:
+    - go mod tidy
+builds:
+  - env:
+      - CGO_ENABLED=0
+    mod_timestamp: "{{ .CommitTimestamp }}"
+    flags:
+      - -trimpath
+    ldflags:
+      - "-s -w -X main.version={{ .Version }}"
+    goos:
+      - freebsd
+      - windows
+      - linux
+      - darwin
+    goarch:
+      - amd64
+      - "386"
+      - arm
+      - arm64
+    ignore:
+      - goos: darwin
+        goarch: "386"
+    binary: "{{ .ProjectName }}_v{{ .Version }}"
+archives:
+  - format: zip
+    name_template: "{{ .ProjectName }}_{{ .Version }}_{{ .Os }}_{{ .Arch }}"
+checksum:
+  extra_files:
+    - glob: terraform-registry-manifest.json
+      name_template: "{{ .ProjectName }}_{{ .Version }}_manifest.json"
+  name_template: "{{ .ProjectName }}_{{ .Version }}_SHA256SUMS"
+  algorithm: sha256
+signs:
+  - artifacts: checksum
+    args: ["--batch", "--local-user", "{{ .Env.GPG_FINGERPRINT }}", "--output", "${signature}", "--detach-sign", "${artifact}"]
+release:
+  extra_files:
+    - glob: terraform-registry-manifest.json
+      name_template: "{{ .ProjectName }}_{{ .Version }}_manifest.json"
+changelog:
+  skip: true
+`,
+	".github/workflows/release.yml": `name: release
+
+on:
+  push:
+    tags:
+      - "v*"
+
+jobs:
+  release:
+    runs-on: ubuntu-latest
+    steps:
+      - uses: actions/checkout@v2
+      - uses: actions/setup-go@v2
+        with:
+          go-version: "1.18"
+      - name: Import GPG key
+        id: import_gpg
+        uses: crazy-max/ghaction-import-gpg@v4
+        with:
+          gpg_private_key: ${{ secrets.GPG_PRIVATE_KEY }}
+          passphrase: ${{ secrets.GPG_PASSPHRASE }}
+      - name: Build release
+        uses: goreleaser/goreleaser-action@v2
+        with:
+          args: release --rm-dist --skip-publish
+        env:
+          GPG_FINGERPRINT: ${{ steps.import_gpg.outputs.fingerprint }}
+      - name: Check release
+        run: |
+          cp terraform-registry-manifest.json "dist/terraform-provider-[[ .Name ]]_${GITHUB_REF_NAME#v}_manifest.json"
+          docker run --rm -v "$PWD/dist:/dist" ghcr.io/tiermobility/boring-registry:latest provider release-check /dist
+`,
+}
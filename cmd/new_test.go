@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/TierMobility/boring-registry/pkg/module"
+)
+
+func TestScaffoldData_Validate(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		data      scaffoldData
+		expectErr bool
+	}{
+		{name: "valid", data: scaffoldData{Namespace: "tier", Name: "vpc", Provider: "aws", Version: "0.1.0"}},
+		{name: "missing provider", data: scaffoldData{Namespace: "tier", Name: "vpc", Version: "0.1.0"}, expectErr: true},
+		{name: "invalid name", data: scaffoldData{Namespace: "tier", Name: "../vpc", Provider: "aws", Version: "0.1.0"}, expectErr: true},
+		{name: "invalid version", data: scaffoldData{Namespace: "tier", Name: "vpc", Provider: "aws", Version: "latest"}, expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tc.data.validate()
+			if tc.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestScaffold_Module(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "boring-registry-new")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	data := scaffoldData{Namespace: "tier", Name: "vpc", Provider: "aws", Version: "0.1.0"}
+	files, err := scaffold(dir, moduleTemplates, data, false)
+	assert.NoError(err)
+	assert.Len(files, len(moduleTemplates))
+	assert.Contains(files, filepath.Join(dir, moduleSpecFileName))
+
+	// The generated spec must be accepted by the upload command
+	spec, err := module.ParseFile(filepath.Join(dir, moduleSpecFileName))
+	assert.NoError(err)
+	assert.Equal("tier/vpc/aws/0.1.0", spec.Name())
+
+	workflow, err := ioutil.ReadFile(filepath.Join(dir, ".github", "workflows", "publish.yml"))
+	assert.NoError(err)
+	assert.Contains(string(workflow), "${{ secrets.BORING_REGISTRY_BUCKET }}")
+
+	// Existing files are not overwritten without force
+	_, err = scaffold(dir, moduleTemplates, data, false)
+	assert.Equal(exitCodeUsage, exitCode(err))
+	_, err = scaffold(dir, moduleTemplates, data, true)
+	assert.NoError(err)
+}
+
+func TestScaffold_Provider(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "boring-registry-new")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	data := scaffoldData{Namespace: "tier", Name: "dummy", Provider: "dummy", Version: "0.1.0"}
+	_, err = scaffold(dir, providerTemplates, data, false)
+	assert.NoError(err)
+
+	gomod, err := ioutil.ReadFile(filepath.Join(dir, "go.mod"))
+	assert.NoError(err)
+	assert.Contains(string(gomod), "module github.com/tier/terraform-provider-dummy")
+
+	// goreleaser templates must be kept as they are
+	goreleaser, err := ioutil.ReadFile(filepath.Join(dir, ".goreleaser.yml"))
+	assert.NoError(err)
+	assert.Contains(string(goreleaser), `name_template: "{{ .ProjectName }}_{{ .Version }}_SHA256SUMS"`)
+}
@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/smtp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/TierMobility/boring-registry/pkg/module"
+)
+
+// Events sent to namespace owners.
+const (
+	notificationEventPublish = "publish"
+	notificationEventStale   = "stale"
+)
+
+const defaultNotificationTemplate = `Subject: [boring-registry] {{ .Namespace }}/{{ .Name }}/{{ .Provider }} {{ .Version }} published
+
+Version {{ .Version }} of the module {{ .Namespace }}/{{ .Name }}/{{ .Provider }} has been published.
+`
+
+const defaultStaleNotificationTemplate = `Subject: [boring-registry] Stale modules in {{ .Namespace }}
+
+{{ if .Stale.Inactive -}}
+Nothing has been published to the namespace {{ .Namespace }} since {{ .Stale.LastPublishedAt.Format "2006-01-02" }}.
+{{ end -}}
+{{ if .Stale.Modules -}}
+The latest versions of these modules of the namespace {{ .Namespace }} are outdated:
+{{ range .Stale.Modules }}
+  {{ .Module }} {{ .Version }}, published {{ .PublishedAt.Format "2006-01-02" }}
+{{- end }}
+{{ end -}}
+`
+
+var (
+	flagNotifySMTPAddress  string
+	flagNotifySMTPUsername string
+	flagNotifySMTPPassword string
+	flagNotifyFrom         string
+	flagNotifyOwners       []string
+	flagNotifyTemplate     string
+)
+
+func init() {
+	addNotifyFlags(uploadCmd.Flags())
+}
+
+// addNotifyFlags adds the flags of the notifier to the commands sending notifications.
+func addNotifyFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&flagNotifySMTPAddress, "notify-smtp-address", "", "SMTP server to send notifications to namespace owners with, e.g. smtp.example.com:587")
+	flags.StringVar(&flagNotifySMTPUsername, "notify-smtp-username", "", "Username to authenticate against the SMTP server")
+	flags.StringVar(&flagNotifySMTPPassword, "notify-smtp-password", "", "Password to authenticate against the SMTP server")
+	flags.StringVar(&flagNotifyFrom, "notify-from", "", "Sender address of notifications")
+	flags.StringArrayVar(&flagNotifyOwners, "notify-owner", nil, "Comma-separated email addresses of the owners of a namespace, e.g. tier=a@example.com,b@example.com (can be repeated)")
+	flags.StringVar(&flagNotifyTemplate, "notify-template", "", "Go template file of notification emails, it must start with a Subject header followed by an empty line")
+}
+
+// notification is the data passed to the notification template.
+type notification struct {
+	Event       string
+	Namespace   string
+	Name        string
+	Provider    string
+	Version     string
+	DownloadURL string
+	// Stale is the report of stale modules of the namespace, it is only set for stale events.
+	Stale *module.StaleReport
+}
+
+// notifier sends emails about events to the owners of the namespace of a module.
+type notifier struct {
+	addr   string
+	auth   smtp.Auth
+	from   string
+	owners map[string][]string
+	tmpl   *template.Template
+	send   func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+	now    func() time.Time
+}
+
+// newNotifier returns a notifier configured by flags, or nil if notifications are disabled.
+// The default template is used unless --notify-template is given.
+func newNotifier(defaultTemplate string) (*notifier, error) {
+	if flagNotifySMTPAddress == "" {
+		if len(flagNotifyOwners) > 0 {
+			return nil, usageError{errors.New("--notify-owner requires --notify-smtp-address")}
+		}
+		return nil, nil
+	}
+
+	if flagNotifyFrom == "" {
+		return nil, usageError{errors.New("--notify-smtp-address requires --notify-from")}
+	}
+
+	text := defaultTemplate
+	if flagNotifyTemplate != "" {
+		b, err := ioutil.ReadFile(flagNotifyTemplate)
+		if err != nil {
+			return nil, usageError{err}
+		}
+		text = string(b)
+	}
+
+	tmpl, err := template.New("notification").Parse(text)
+	if err != nil {
+		return nil, usageError{errors.Wrap(err, "invalid notification template")}
+	}
+
+	owners, err := parseNamespaceOwners(flagNotifyOwners)
+	if err != nil {
+		return nil, err
+	}
+
+	n := &notifier{
+		addr:   flagNotifySMTPAddress,
+		from:   flagNotifyFrom,
+		owners: owners,
+		tmpl:   tmpl,
+		send:   smtp.SendMail,
+		now:    time.Now,
+	}
+
+	if flagNotifySMTPUsername != "" {
+		host, _, err := net.SplitHostPort(flagNotifySMTPAddress)
+		if err != nil {
+			return nil, usageError{errors.Wrap(err, "invalid SMTP address")}
+		}
+		n.auth = smtp.PlainAuth("", flagNotifySMTPUsername, flagNotifySMTPPassword, host)
+	}
+
+	return n, nil
+}
+
+func parseNamespaceOwners(entries []string) (map[string][]string, error) {
+	owners := make(map[string][]string)
+
+	for _, raw := range entries {
+		parts := strings.SplitN(raw, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, usageError{fmt.Errorf("invalid namespace owners %q, expected NAMESPACE=EMAILS", raw)}
+		}
+
+		for _, addr := range splitKeys(parts[1]) {
+			if strings.ContainsAny(addr, "\r\n") || !strings.Contains(addr, "@") {
+				return nil, usageError{fmt.Errorf("invalid email address %q", addr)}
+			}
+			owners[parts[0]] = append(owners[parts[0]], strings.TrimSpace(addr))
+		}
+	}
+
+	return owners, nil
+}
+
+// notifyUploads sends a publish notification for every uploaded module version.
+// Modules published to several targets are only notified once.
+// Failed notifications are logged and don't fail the upload, as the modules are already published.
+func (n *notifier) notifyUploads(result *uploadResult) {
+	if n == nil {
+		return
+	}
+
+	seen := make(map[string]bool)
+	for _, m := range result.Modules {
+		id := fmt.Sprintf("%s/%s/%s/%s", m.Namespace, m.Name, m.Provider, m.Version)
+		if m.Status != moduleStatusUploaded || seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		err := n.notify(notification{
+			Event:       notificationEventPublish,
+			Namespace:   m.Namespace,
+			Name:        m.Name,
+			Provider:    m.Provider,
+			Version:     m.Version,
+			DownloadURL: m.DownloadURL,
+		})
+		if err != nil {
+			_ = level.Error(logger).Log(
+				"msg", "failed to send notification",
+				"module", id,
+				"err", err,
+			)
+		}
+	}
+}
+
+func (n *notifier) notify(data notification) error {
+	to, ok := n.owners[data.Namespace]
+	if !ok {
+		return nil
+	}
+
+	body := new(bytes.Buffer)
+	if err := n.tmpl.Execute(body, data); err != nil {
+		return errors.Wrap(err, "failed to render notification")
+	}
+
+	msg := new(bytes.Buffer)
+	fmt.Fprintf(msg, "From: %s\n", n.from)
+	fmt.Fprintf(msg, "To: %s\n", strings.Join(to, ", "))
+	fmt.Fprintf(msg, "Date: %s\n", n.now().Format(time.RFC1123Z))
+	fmt.Fprintf(msg, "X-Boring-Registry-Event: %s\n", data.Event)
+	msg.Write(body.Bytes())
+
+	// SMTP requires CRLF line endings
+	crlf := strings.ReplaceAll(strings.ReplaceAll(msg.String(), "\r\n", "\n"), "\n", "\r\n")
+
+	return n.send(n.addr, n.auth, n.from, to, []byte(crlf))
+}
@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"net/smtp"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotifier(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	type mail struct {
+		to  []string
+		msg string
+	}
+
+	var sent []mail
+
+	n := &notifier{
+		addr:   "smtp.example.com:25",
+		from:   "registry@example.com",
+		owners: map[string][]string{"tier": {"a@example.com", "b@example.com"}},
+		tmpl:   template.Must(template.New("notification").Parse(defaultNotificationTemplate)),
+		send: func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+			sent = append(sent, mail{to: to, msg: string(msg)})
+			return nil
+		},
+		now: func() time.Time { return time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC) },
+	}
+
+	n.notifyUploads(&uploadResult{
+		Modules: []moduleResult{
+			{Namespace: "tier", Name: "vpc", Provider: "aws", Version: "1.0.0", Status: moduleStatusUploaded, Target: "s3://a"},
+			{Namespace: "tier", Name: "vpc", Provider: "aws", Version: "1.0.0", Status: moduleStatusUploaded, Target: "s3://b"},
+			{Namespace: "tier", Name: "dns", Provider: "aws", Version: "1.0.0", Status: moduleStatusExists},
+			{Namespace: "other", Name: "dns", Provider: "aws", Version: "1.0.0", Status: moduleStatusUploaded},
+		},
+	})
+
+	assert.Len(sent, 1)
+	assert.Equal([]string{"a@example.com", "b@example.com"}, sent[0].to)
+	assert.Equal(strings.Join([]string{
+		"From: registry@example.com",
+		"To: a@example.com, b@example.com",
+		"Date: Wed, 01 May 2024 12:00:00 +0000",
+		"X-Boring-Registry-Event: publish",
+		"Subject: [boring-registry] tier/vpc/aws 1.0.0 published",
+		"",
+		"Version 1.0.0 of the module tier/vpc/aws has been published.",
+		"",
+	}, "\r\n"), sent[0].msg)
+}
+
+func TestParseNamespaceOwners(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		entries   []string
+		expected  map[string][]string
+		expectErr bool
+	}{
+		{
+			name:     "valid",
+			entries:  []string{"tier=a@example.com, b@example.com", "other=c@example.com"},
+			expected: map[string][]string{"tier": {"a@example.com", "b@example.com"}, "other": {"c@example.com"}},
+		},
+		{
+			name:      "missing addresses",
+			entries:   []string{"tier="},
+			expectErr: true,
+		},
+		{
+			name:      "invalid address",
+			entries:   []string{"tier=a@example.com\r\nBcc: x@example.com"},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			owners, err := parseNamespaceOwners(tc.entries)
+			if tc.expectErr {
+				assert.Equal(t, exitCodeUsage, exitCode(err))
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, owners)
+		})
+	}
+}
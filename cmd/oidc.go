@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/TierMobility/boring-registry/pkg/auth"
+)
+
+var (
+	flagOIDCIssuer   string
+	flagOIDCAudience string
+	flagOIDCClientID string
+
+	flagOIDCBrowserClientID string
+)
+
+const (
+	// oidcDiscoveryTimeout limits the discovery of the issuer on startup.
+	oidcDiscoveryTimeout = 30 * time.Second
+)
+
+// loginPorts is the range of local ports terraform login listens on for the redirect of the issuer.
+// The redirect URIs http://localhost:10000/login to http://localhost:10010/login have to be allowed for the client.
+var loginPorts = []int{10000, 10010}
+
+// oidcVerifier verifies Bearer tokens as JWTs of --oidc-issuer, it is nil without issuer.
+var oidcVerifier *auth.OIDCVerifier
+
+// oidcBrowserVerifier verifies the JWTs of --oidc-issuer issued for --oidc-browser-client-id, it is nil without browser client.
+var oidcBrowserVerifier *auth.OIDCVerifier
+
+func init() {
+	serverCmd.Flags().StringVar(&flagOIDCIssuer, "oidc-issuer", "", "OpenID Connect issuer whose JWTs are accepted as Bearer tokens in addition to the API keys, e.g. https://idp.example.com")
+	serverCmd.Flags().StringVar(&flagOIDCAudience, "oidc-audience", "", "Audience the JWTs have to be issued for (default the --oidc-client-id)")
+	serverCmd.Flags().StringVar(&flagOIDCClientID, "oidc-client-id", "", "OAuth client ID of the issuer to advertise to terraform login")
+	serverCmd.Flags().StringVar(&flagOIDCBrowserClientID, "oidc-browser-client-id", "", "Public OAuth client ID of the issuer to advertise to browser tools, whose tokens can only read")
+}
+
+// setupOIDC discovers the issuer of --oidc-issuer.
+func setupOIDC() error {
+	oidcVerifier, oidcBrowserVerifier = nil, nil
+
+	if flagOIDCIssuer == "" {
+		if flagOIDCClientID != "" || flagOIDCAudience != "" || flagOIDCBrowserClientID != "" {
+			return usageError{errors.New("--oidc-client-id, --oidc-audience and --oidc-browser-client-id require --oidc-issuer")}
+		}
+		return nil
+	}
+
+	audience := flagOIDCAudience
+	if audience == "" {
+		audience = flagOIDCClientID
+	}
+	if audience == "" {
+		return usageError{errors.New("--oidc-issuer requires --oidc-audience or --oidc-client-id")}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), oidcDiscoveryTimeout)
+	defer cancel()
+
+	verifier, err := auth.NewOIDCVerifier(ctx, flagOIDCIssuer, audience, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to setup OIDC")
+	}
+	oidcVerifier = verifier
+
+	// Tokens of browser tools are issued for their own client, so they aren't accepted where the tokens of terraform login are
+	if flagOIDCBrowserClientID != "" {
+		verifier, err := auth.NewOIDCVerifier(ctx, flagOIDCIssuer, flagOIDCBrowserClientID, nil)
+		if err != nil {
+			return errors.Wrap(err, "failed to setup OIDC for browser tools")
+		}
+		oidcBrowserVerifier = verifier
+	}
+
+	return nil
+}
+
+// discoveryDocument returns the service discovery document of the registry.
+// With --oidc-client-id it advertises the issuer to terraform login,
+// and with --oidc-browser-client-id to browser tools using the authorization code flow with PKCE.
+func discoveryDocument() map[string]interface{} {
+	doc := map[string]interface{}{
+		"modules.v1":   prefixModules + "/",
+		"providers.v1": prefixProviders + "/",
+	}
+
+	if oidcVerifier != nil && flagOIDCClientID != "" {
+		provider := oidcVerifier.Provider()
+		doc["login.v1"] = map[string]interface{}{
+			"client":      flagOIDCClientID,
+			"grant_types": []string{"authz_code"},
+			"authz":       provider.AuthorizationEndpoint,
+			"token":       provider.TokenEndpoint,
+			"ports":       loginPorts,
+		}
+	}
+
+	if oidcBrowserVerifier != nil {
+		provider := oidcBrowserVerifier.Provider()
+		doc["browser-login.v1"] = map[string]interface{}{
+			"client":                 flagOIDCBrowserClientID,
+			"grant_types":            []string{"authz_code"},
+			"authz":                  provider.AuthorizationEndpoint,
+			"token":                  provider.TokenEndpoint,
+			"code_challenge_methods": []string{"S256"},
+		}
+	}
+
+	return doc
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/TierMobility/boring-registry/pkg/auth"
+)
+
+var (
+	flagOktaIssuer       string
+	flagOktaClientID     string
+	flagOktaClientSecret string
+	flagOktaAudience     string
+)
+
+// oktaVerifier verifies Bearer tokens with the introspection endpoint of --okta-issuer, it is nil without issuer.
+var oktaVerifier *auth.IntrospectionVerifier
+
+func init() {
+	serverCmd.Flags().StringVar(&flagOktaIssuer, "okta-issuer", "", "Okta authorization server whose access tokens are accepted as Bearer tokens in addition to the API keys, e.g. https://example.okta.com/oauth2/default")
+	serverCmd.Flags().StringVar(&flagOktaClientID, "okta-client-id", "", "Client ID to authenticate at the introspection endpoint of --okta-issuer with")
+	serverCmd.Flags().StringVar(&flagOktaClientSecret, "okta-client-secret", "", "Client secret to authenticate at the introspection endpoint of --okta-issuer with")
+	serverCmd.Flags().StringVar(&flagOktaAudience, "okta-audience", "", "Audience the access tokens of --okta-issuer have to be issued for, e.g. api://default")
+}
+
+// setupOkta sets up the verifier of the access tokens of --okta-issuer, which are checked with its introspection endpoint.
+func setupOkta() error {
+	oktaVerifier = nil
+
+	if flagOktaIssuer == "" {
+		if flagOktaClientID != "" || flagOktaClientSecret != "" || flagOktaAudience != "" {
+			return usageError{errors.New("--okta-client-id, --okta-client-secret and --okta-audience require --okta-issuer")}
+		}
+		return nil
+	}
+
+	if flagOktaClientID == "" || flagOktaClientSecret == "" || flagOktaAudience == "" {
+		return usageError{errors.New("--okta-issuer requires --okta-client-id, --okta-client-secret and --okta-audience")}
+	}
+
+	oktaVerifier = auth.NewOktaVerifier(flagOktaIssuer, flagOktaClientID, flagOktaClientSecret, flagOktaAudience, nil,
+		auth.WithIntrospectionCacheReport(func(event auth.CacheEvent) {
+			countCacheEvent(cacheIntrospection, entityTokens, string(event))
+		}),
+	)
+
+	return nil
+}
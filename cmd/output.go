@@ -0,0 +1,18 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// Supported output formats for command results.
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+func printJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
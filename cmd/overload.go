@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/TierMobility/boring-registry/pkg/module"
+)
+
+var (
+	flagOverloadMaxInFlight  int
+	flagOverloadMaxLatency   time.Duration
+	flagOverloadQueueTimeout time.Duration
+)
+
+var overloadUploadsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "boring_registry_overload_uploads_total",
+	Help: "Number of uploads which were queued or shed because the registry was saturated.",
+}, []string{"result"})
+
+func init() {
+	prometheus.MustRegister(overloadUploadsTotal)
+
+	serverCmd.Flags().IntVar(&flagOverloadMaxInFlight, "overload-max-in-flight", 0, "Queue uploads while this many downloads and uploads are processed, 0 to disable")
+	serverCmd.Flags().DurationVar(&flagOverloadMaxLatency, "overload-max-latency", 0, "Queue uploads while the average latency of downloads exceeds this duration, 0 to disable")
+	serverCmd.Flags().DurationVar(&flagOverloadQueueTimeout, "overload-queue-timeout", 3*time.Second, "Duration uploads are queued for before they are rejected with 503 Service Unavailable, must be shorter than --server-read-timeout and --server-write-timeout")
+}
+
+// setupOverload returns the tracker prioritizing downloads over uploads, or nil if no saturation limit is enabled.
+// It's shared by all hosts, as they share the resources of the server.
+func setupOverload() (*module.Overload, error) {
+	switch {
+	case flagOverloadMaxInFlight < 0:
+		return nil, usageError{errors.New("--overload-max-in-flight must not be negative")}
+	case flagOverloadMaxLatency < 0:
+		return nil, usageError{errors.New("--overload-max-latency must not be negative")}
+	case flagOverloadQueueTimeout < 0:
+		return nil, usageError{errors.New("--overload-queue-timeout must not be negative")}
+	}
+
+	// Queued uploads would run into the deadlines of their connection before they are rejected
+	for _, timeout := range []struct {
+		flag     string
+		duration time.Duration
+	}{
+		{"--server-read-timeout", flagServerReadTimeout},
+		{"--server-write-timeout", flagServerWriteTimeout},
+	} {
+		if timeout.duration > 0 && flagOverloadQueueTimeout >= timeout.duration {
+			return nil, usageError{fmt.Errorf("--overload-queue-timeout must be shorter than %s", timeout.flag)}
+		}
+	}
+
+	if flagOverloadMaxInFlight == 0 && flagOverloadMaxLatency == 0 {
+		return nil, nil
+	}
+
+	return module.NewOverload(
+		module.WithOverloadMaxInFlight(flagOverloadMaxInFlight),
+		module.WithOverloadMaxLatency(flagOverloadMaxLatency),
+		module.WithOverloadQueueTimeout(flagOverloadQueueTimeout),
+		module.WithOverloadReport(func(event module.OverloadEvent) {
+			overloadUploadsTotal.WithLabelValues(string(event)).Inc()
+		}),
+	), nil
+}
@@ -0,0 +1,358 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/hcl"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const (
+	lockFileName       = ".terraform.lock.hcl"
+	moduleManifestName = "modules.json"
+
+	pinKindModule   = "module"
+	pinKindProvider = "provider"
+
+	pinStatusLatest   = "latest"
+	pinStatusOutdated = "outdated"
+	pinStatusUnknown  = "unknown"
+)
+
+var (
+	flagPinsEndpoint string
+	flagPinsToken    string
+	flagPinsOutdated bool
+)
+
+var pinsCmd = &cobra.Command{
+	Use:   "pins [DIR...]",
+	Short: "Report which module and provider versions are pinned by Terraform workspaces",
+	Long: `Report which module and provider versions are pinned by Terraform workspaces.
+
+All directories are searched for dependency lock files (.terraform.lock.hcl) and module
+manifests (.terraform/modules/modules.json) written by "terraform init". Every module and
+provider of the registry is joined against the versions available in the registry.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			args = []string{"."}
+		}
+
+		client, err := newRegistryClient(flagPinsEndpoint, flagPinsToken)
+		if err != nil {
+			return err
+		}
+
+		var pins []pin
+		for _, dir := range args {
+			found, err := findPins(dir, client.endpoint.Host)
+			if err != nil {
+				return err
+			}
+			pins = append(pins, found...)
+		}
+
+		result, err := resolvePins(context.Background(), client, pins)
+		if err != nil {
+			return err
+		}
+
+		if flagPinsOutdated {
+			result = result.outdated()
+		}
+
+		if flagOutput == outputJSON {
+			return printJSON(os.Stdout, result)
+		}
+
+		return result.print(os.Stdout)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pinsCmd)
+	pinsCmd.Flags().StringVar(&flagPinsEndpoint, "endpoint", "", "Base URL of the registry, e.g. https://registry.example.com")
+	pinsCmd.Flags().StringVar(&flagPinsToken, "token", "", "API key to authenticate against the registry")
+	pinsCmd.Flags().BoolVar(&flagPinsOutdated, "outdated", false, "Only report pins of versions older than the latest version")
+}
+
+// pin is a version of a module or provider used by a workspace.
+type pin struct {
+	Workspace string `json:"workspace"`
+	Kind      string `json:"kind"`
+	Source    string `json:"source"`
+	Version   string `json:"version"`
+	Latest    string `json:"latest,omitempty"`
+	Status    string `json:"status"`
+}
+
+// pinsResult is the machine-readable result of the pins command.
+type pinsResult struct {
+	Pins []pin `json:"pins"`
+}
+
+// findPins searches a directory for lock files and module manifests and returns the pins of the registry host.
+func findPins(root, host string) ([]pin, error) {
+	var pins []pin
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		dir := filepath.Dir(path)
+
+		switch {
+		case info.IsDir() && info.Name() == ".terraform":
+			found, err := readModuleManifest(filepath.Join(path, "modules", moduleManifestName), dir, host)
+			if err != nil {
+				return err
+			}
+			pins = append(pins, found...)
+
+			// Downloaded modules may contain lock files of their own
+			return filepath.SkipDir
+		case !info.IsDir() && info.Name() == lockFileName:
+			found, err := readLockFile(path, dir, host)
+			if err != nil {
+				return err
+			}
+			pins = append(pins, found...)
+		}
+
+		return nil
+	})
+
+	return pins, err
+}
+
+// readModuleManifest returns the registry modules of a module manifest, a missing manifest is ignored.
+func readModuleManifest(path, workspace, host string) ([]pin, error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var manifest struct {
+		Modules []struct {
+			Source  string `json:"Source"`
+			Version string `json:"Version"`
+		} `json:"Modules"`
+	}
+
+	if err := json.Unmarshal(b, &manifest); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s", path)
+	}
+
+	var pins []pin
+	for _, m := range manifest.Modules {
+		// Strip the subdirectory of a module package
+		source := strings.SplitN(m.Source, "//", 2)[0]
+
+		parts := strings.Split(source, "/")
+		if m.Version == "" || len(parts) != 4 || !strings.EqualFold(parts[0], host) {
+			continue
+		}
+
+		pins = append(pins, pin{
+			Workspace: workspace,
+			Kind:      pinKindModule,
+			Source:    strings.Join(parts[1:], "/"),
+			Version:   m.Version,
+		})
+	}
+
+	return pins, nil
+}
+
+// readLockFile returns the registry providers of a dependency lock file.
+func readLockFile(path, workspace, host string) ([]pin, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var lock struct {
+		Providers map[string]struct {
+			Version string `hcl:"version"`
+		} `hcl:"provider"`
+	}
+
+	if err := hcl.Unmarshal(b, &lock); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s", path)
+	}
+
+	var pins []pin
+	for address, p := range lock.Providers {
+		parts := strings.Split(address, "/")
+		if len(parts) != 3 || !strings.EqualFold(parts[0], host) {
+			continue
+		}
+
+		pins = append(pins, pin{
+			Workspace: workspace,
+			Kind:      pinKindProvider,
+			Source:    strings.Join(parts[1:], "/"),
+			Version:   p.Version,
+		})
+	}
+
+	return pins, nil
+}
+
+// resolvePins joins the pins against the latest versions available in the registry.
+func resolvePins(ctx context.Context, client *registryClient, pins []pin) (*pinsResult, error) {
+	var (
+		result   = &pinsResult{Pins: []pin{}}
+		latest   = make(map[string]string)
+		resolver = &pinResolver{client: client}
+	)
+
+	for _, p := range pins {
+		key := p.Kind + ":" + p.Source
+		if _, ok := latest[key]; !ok {
+			versions, err := resolver.versions(ctx, p)
+			var statusErr *statusError
+			if errors.As(err, &statusErr) && statusErr.code == http.StatusNotFound {
+				// Pins of unknown modules and providers are reported with an unknown status
+				err = nil
+			}
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to list versions of %s %s", p.Kind, p.Source)
+			}
+			latest[key] = latestVersion(versions)
+		}
+
+		p.Latest = latest[key]
+		p.Status = pinStatus(p.Version, p.Latest)
+
+		result.Pins = append(result.Pins, p)
+	}
+
+	sort.Slice(result.Pins, func(i, j int) bool {
+		a, b := result.Pins[i], result.Pins[j]
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+		if a.Version != b.Version {
+			return a.Version < b.Version
+		}
+		return a.Workspace < b.Workspace
+	})
+
+	return result, nil
+}
+
+// pinResolver lists versions of the registry and only discovers the protocols that are needed.
+type pinResolver struct {
+	client    *registryClient
+	modules   *url.URL
+	providers *url.URL
+}
+
+func (r *pinResolver) versions(ctx context.Context, p pin) ([]string, error) {
+	var (
+		parts = strings.Split(p.Source, "/")
+		err   error
+	)
+
+	if p.Kind == pinKindModule {
+		if r.modules == nil {
+			if r.modules, err = r.client.discoverModules(ctx); err != nil {
+				return nil, err
+			}
+		}
+		return r.client.listModuleVersions(ctx, r.modules, parts[0], parts[1], parts[2])
+	}
+
+	if r.providers == nil {
+		if r.providers, err = r.client.discoverProviders(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return r.client.listProviderVersions(ctx, r.providers, parts[0], parts[1])
+}
+
+// latestVersion returns the highest release, or the highest pre-release if there are no releases.
+func latestVersion(versions []string) string {
+	var latest, latestPrerelease *version.Version
+
+	for _, v := range versions {
+		ver, err := version.NewVersion(v)
+		if err != nil {
+			continue
+		}
+
+		if ver.Prerelease() != "" {
+			if latestPrerelease == nil || ver.GreaterThan(latestPrerelease) {
+				latestPrerelease = ver
+			}
+			continue
+		}
+
+		if latest == nil || ver.GreaterThan(latest) {
+			latest = ver
+		}
+	}
+
+	switch {
+	case latest != nil:
+		return latest.Original()
+	case latestPrerelease != nil:
+		return latestPrerelease.Original()
+	default:
+		return ""
+	}
+}
+
+func pinStatus(pinned, latest string) string {
+	p, err := version.NewVersion(pinned)
+	if err != nil {
+		return pinStatusUnknown
+	}
+
+	l, err := version.NewVersion(latest)
+	if err != nil {
+		return pinStatusUnknown
+	}
+
+	if p.LessThan(l) {
+		return pinStatusOutdated
+	}
+
+	return pinStatusLatest
+}
+
+func (r *pinsResult) outdated() *pinsResult {
+	result := &pinsResult{Pins: []pin{}}
+	for _, p := range r.Pins {
+		if p.Status == pinStatusOutdated {
+			result.Pins = append(result.Pins, p)
+		}
+	}
+	return result
+}
+
+func (r *pinsResult) print(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "KIND\tSOURCE\tVERSION\tLATEST\tSTATUS\tWORKSPACE\n")
+	for _, p := range r.Pins {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", p.Kind, p.Source, p.Version, p.Latest, p.Status, p.Workspace)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPins(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/terraform.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"modules.v1": "/v1/modules/", "providers.v1": "/v1/providers/"}`))
+	})
+	mux.HandleFunc("/v1/modules/tier/vpc/aws/versions", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"modules": [{"versions": [{"version": "1.0.0"}, {"version": "2.1.0"}, {"version": "3.0.0-rc.1"}]}]}`))
+	})
+	mux.HandleFunc("/v1/providers/tier/dummy/versions", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"versions": [{"version": "0.9.0"}, {"version": "1.0.0"}]}`))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client, err := newRegistryClient(server.URL, "")
+	assert.NoError(err)
+	host := client.endpoint.Host
+
+	dir, err := ioutil.TempDir("", "boring-registry-pins")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	var (
+		network = filepath.Join(dir, "network")
+		dns     = filepath.Join(dir, "dns")
+	)
+
+	lock := `
+provider "` + host + `/tier/dummy" {
+  version     = "1.0.0"
+  constraints = "~> 1.0"
+  hashes = [
+    "h1:abc=",
+  ]
+}
+
+provider "registry.terraform.io/hashicorp/aws" {
+  version = "5.30.0"
+}
+`
+	manifest := `{"Modules":[
+  {"Key":"","Source":"","Dir":"."},
+  {"Key":"vpc","Source":"` + host + `/tier/vpc/aws//modules/subnet","Version":"1.0.0","Dir":".terraform/modules/vpc"},
+  {"Key":"unknown","Source":"` + host + `/tier/unknown/aws","Version":"1.0.0","Dir":".terraform/modules/unknown"},
+  {"Key":"local","Source":"./local","Dir":"local"}
+]}`
+
+	assert.NoError(os.MkdirAll(filepath.Join(network, ".terraform", "modules", "vpc"), 0755))
+	assert.NoError(os.MkdirAll(dns, 0755))
+	assert.NoError(ioutil.WriteFile(filepath.Join(network, lockFileName), []byte(lock), 0644))
+	assert.NoError(ioutil.WriteFile(filepath.Join(network, ".terraform", "modules", moduleManifestName), []byte(manifest), 0644))
+	// Lock files of downloaded modules are ignored
+	assert.NoError(ioutil.WriteFile(filepath.Join(network, ".terraform", "modules", "vpc", lockFileName), []byte(lock), 0644))
+	assert.NoError(ioutil.WriteFile(filepath.Join(dns, lockFileName), []byte(strings.Replace(lock, `"1.0.0"`, `"0.9.0"`, 1)), 0644))
+
+	pins, err := findPins(dir, host)
+	assert.NoError(err)
+
+	result, err := resolvePins(context.Background(), client, pins)
+	assert.NoError(err)
+
+	assert.Equal([]pin{
+		{Workspace: dns, Kind: pinKindProvider, Source: "tier/dummy", Version: "0.9.0", Latest: "1.0.0", Status: pinStatusOutdated},
+		{Workspace: network, Kind: pinKindProvider, Source: "tier/dummy", Version: "1.0.0", Latest: "1.0.0", Status: pinStatusLatest},
+		{Workspace: network, Kind: pinKindModule, Source: "tier/unknown/aws", Version: "1.0.0", Status: pinStatusUnknown},
+		{Workspace: network, Kind: pinKindModule, Source: "tier/vpc/aws", Version: "1.0.0", Latest: "2.1.0", Status: pinStatusOutdated},
+	}, result.Pins)
+
+	assert.Len(result.outdated().Pins, 2)
+}
+
+func TestLatestVersion(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		versions []string
+		expected string
+	}{
+		{name: "empty", expected: ""},
+		{name: "releases", versions: []string{"1.10.0", "1.9.0", "v1.2.0"}, expected: "1.10.0"},
+		{name: "ignores pre-releases", versions: []string{"1.0.0", "2.0.0-rc.1"}, expected: "1.0.0"},
+		{name: "only pre-releases", versions: []string{"2.0.0-rc.1", "2.0.0-rc.2"}, expected: "2.0.0-rc.2"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, latestVersion(tc.versions))
+		})
+	}
+}
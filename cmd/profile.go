@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+const (
+	defaultProfile = "default"
+)
+
+// defaultConfigFile returns the path of the CLI configuration file in the home directory of the user.
+func defaultConfigFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(home, "."+projectName, "config")
+}
+
+// applyProfile sets all flags of cmd that are neither passed explicitly
+// nor set by an environment variable to the values of the selected profile.
+//
+// The configuration file uses the INI format with one section per profile.
+// Keys are flag names, e.g.:
+//
+//	[staging]
+//	endpoint = https://registry.staging.example.com
+//	token = very-secure-token
+//	namespace = tier
+func applyProfile(cmd *cobra.Command, v *viper.Viper) error {
+	profile := currentProfile()
+
+	if flagConfigFile == "" {
+		return nil
+	}
+
+	if _, err := os.Stat(flagConfigFile); err != nil {
+		// The configuration file is optional unless a profile is requested explicitly
+		if os.IsNotExist(err) && flagProfile == "" {
+			return nil
+		}
+		return usageError{errors.Wrap(err, "failed to read config file")}
+	}
+
+	pv := viper.New()
+	pv.SetConfigFile(flagConfigFile)
+	pv.SetConfigType("ini")
+	if err := pv.ReadInConfig(); err != nil {
+		return usageError{errors.Wrap(err, "failed to read config file")}
+	}
+
+	if flagProfile != "" && !profileExists(pv, profile) {
+		return usageError{fmt.Errorf("profile %s not found in %s", profile, flagConfigFile)}
+	}
+
+	var err error
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		key := fmt.Sprintf("%s.%s", profile, f.Name)
+		if err != nil || f.Changed || v.IsSet(f.Name) || !pv.IsSet(key) {
+			return
+		}
+		if setErr := cmd.Flags().Set(f.Name, pv.GetString(key)); setErr != nil {
+			err = usageError{fmt.Errorf("invalid value for %s in profile %s: %v", f.Name, profile, setErr)}
+		}
+	})
+
+	return err
+}
+
+// currentProfile returns the name of the selected profile.
+func currentProfile() string {
+	if flagProfile == "" {
+		return defaultProfile
+	}
+	return flagProfile
+}
+
+func profileExists(v *viper.Viper, profile string) bool {
+	for _, key := range v.AllKeys() {
+		if strings.HasPrefix(key, profile+".") {
+			return true
+		}
+	}
+	return false
+}
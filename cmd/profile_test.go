@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyProfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "boring-registry-profile")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	configFile := filepath.Join(dir, "config")
+	config := `[default]
+endpoint = https://registry.example.com
+token = default-token
+
+[staging]
+endpoint = https://registry.staging.example.com
+namespace = tier
+`
+	assert.NoError(t, ioutil.WriteFile(configFile, []byte(config), 0600))
+
+	testCases := []struct {
+		name       string
+		configFile string
+		profile    string
+		args       []string
+		expected   map[string]string
+		expectErr  bool
+	}{
+		{
+			name:       "default profile",
+			configFile: configFile,
+			expected:   map[string]string{"endpoint": "https://registry.example.com", "token": "default-token", "namespace": ""},
+		},
+		{
+			name:       "named profile",
+			configFile: configFile,
+			profile:    "staging",
+			expected:   map[string]string{"endpoint": "https://registry.staging.example.com", "token": "", "namespace": "tier"},
+		},
+		{
+			name:       "flags take precedence",
+			configFile: configFile,
+			profile:    "staging",
+			args:       []string{"--endpoint=http://localhost:5601"},
+			expected:   map[string]string{"endpoint": "http://localhost:5601", "namespace": "tier"},
+		},
+		{
+			name:       "unknown profile",
+			configFile: configFile,
+			profile:    "prod",
+			expectErr:  true,
+		},
+		{
+			name:       "missing config file",
+			configFile: filepath.Join(dir, "missing"),
+			expected:   map[string]string{"endpoint": ""},
+		},
+		{
+			name:       "missing config file with profile",
+			configFile: filepath.Join(dir, "missing"),
+			profile:    "staging",
+			expectErr:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			flagConfigFile, flagProfile = tc.configFile, tc.profile
+			defer func() { flagConfigFile, flagProfile = "", "" }()
+
+			cmd := &cobra.Command{}
+			cmd.Flags().String("endpoint", "", "")
+			cmd.Flags().String("token", "", "")
+			cmd.Flags().String("namespace", "", "")
+			assert.NoError(cmd.ParseFlags(tc.args))
+
+			err := applyProfile(cmd, viper.New())
+			if tc.expectErr {
+				assert.Equal(exitCodeUsage, exitCode(err))
+				return
+			}
+
+			assert.NoError(err)
+			for name, expected := range tc.expected {
+				value, err := cmd.Flags().GetString(name)
+				assert.NoError(err)
+				assert.Equal(expected, value, name)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+)
+
+var (
+	flagModuleProxy          bool
+	flagModuleProxySecret    string
+	flagModuleProxyURLExpiry time.Duration
+
+	flagModuleProxyMaxConcurrency int
+)
+
+func init() {
+	serverCmd.Flags().BoolVar(&flagModuleProxy, "module-proxy", false, "Stream module archives through the registry under "+prefixArchives+" instead of returning the URLs of the storage")
+	serverCmd.Flags().StringVar(&flagModuleProxySecret, "module-proxy-secret", "", "Secret signing the download URLs of --module-proxy, all replicas need the same secret (default a random secret per process)")
+	serverCmd.Flags().DurationVar(&flagModuleProxyURLExpiry, "module-proxy-url-expiry", 15*time.Minute, "How long the download URLs of --module-proxy are valid")
+	serverCmd.Flags().IntVar(&flagModuleProxyMaxConcurrency, "module-proxy-max-concurrency", 64, "Maximum number of archives streamed by --module-proxy at the same time, further downloads are rejected with 503 (0 is unlimited)")
+}
+
+var (
+	sharedModuleProxyKeyOnce sync.Once
+	sharedModuleProxyKey     []byte
+)
+
+// moduleProxyKey returns the key signing the download URLs of proxied module archives.
+// Without --module-proxy-secret a random key is generated, which only works with a single replica.
+func moduleProxyKey() []byte {
+	sharedModuleProxyKeyOnce.Do(func() {
+		if flagModuleProxySecret != "" {
+			sharedModuleProxyKey = []byte(flagModuleProxySecret)
+			return
+		}
+
+		_ = level.Warn(logger).Log(
+			"msg", "signing module download URLs with a random secret, set --module-proxy-secret when running multiple replicas",
+		)
+
+		sharedModuleProxyKey = make([]byte, 32)
+		if _, err := rand.Read(sharedModuleProxyKey); err != nil {
+			panic(err)
+		}
+	})
+
+	return sharedModuleProxyKey
+}
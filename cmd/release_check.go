@@ -0,0 +1,313 @@
+package cmd
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/hashicorp/go-version"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/TierMobility/boring-registry/pkg/core"
+)
+
+// Status of a release check.
+const (
+	checkStatusPass = "pass"
+	checkStatusWarn = "warn"
+	checkStatusFail = "fail"
+)
+
+var (
+	flagReleaseCheckPlatforms []string
+	flagReleaseCheckGPGVerify bool
+)
+
+var errReleaseCheckFailed = errors.New("release check failed")
+
+var providerCmd = &cobra.Command{
+	Use:   "provider",
+	Short: "Manage providers",
+}
+
+var releaseCheckCmd = &cobra.Command{
+	Use:   "release-check [flags] DIR",
+	Short: "Validate a provider release directory before publishing it",
+	Long: `Validate a provider release directory before publishing it.
+
+The directory has to contain the provider archives, the SHA256SUMS file and its signature
+as produced by e.g. goreleaser. The check verifies the file names, the required platforms,
+the checksums of all archives, the signature and the registry manifest.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return usageError{errors.New("expected exactly one release directory")}
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := os.Stat(args[0]); err != nil {
+			return usageError{err}
+		}
+
+		result := checkProviderRelease(args[0], flagReleaseCheckPlatforms, flagReleaseCheckGPGVerify)
+
+		var err error
+		if result.failed() {
+			err = errReleaseCheckFailed
+		}
+
+		if flagOutput == outputJSON {
+			if err := printJSON(os.Stdout, result); err != nil {
+				return err
+			}
+			return err
+		}
+
+		if err := result.print(os.Stdout); err != nil {
+			return err
+		}
+
+		return err
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(providerCmd)
+	providerCmd.AddCommand(releaseCheckCmd)
+	releaseCheckCmd.Flags().StringSliceVar(&flagReleaseCheckPlatforms, "platform", []string{"linux_amd64", "darwin_amd64", "windows_amd64"}, "Platforms in the format os_arch the release must contain")
+	releaseCheckCmd.Flags().BoolVar(&flagReleaseCheckGPGVerify, "gpg-verify", false, "Verify the signature of the SHA256SUMS file with the gpg keyring of the current user")
+}
+
+// releaseCheckResult is the machine-readable result of the release-check command.
+type releaseCheckResult struct {
+	Name    string         `json:"name"`
+	Version string         `json:"version"`
+	Checks  []releaseCheck `json:"checks"`
+}
+
+type releaseCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+func (r *releaseCheckResult) add(name, status, format string, args ...interface{}) {
+	r.Checks = append(r.Checks, releaseCheck{Name: name, Status: status, Message: fmt.Sprintf(format, args...)})
+}
+
+func (r *releaseCheckResult) failed() bool {
+	for _, c := range r.Checks {
+		if c.Status == checkStatusFail {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *releaseCheckResult) print(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "Provider %s %s\n\n", r.Name, r.Version)
+	for _, c := range r.Checks {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", strings.ToUpper(c.Status), c.Name, c.Message)
+	}
+	return tw.Flush()
+}
+
+// checkProviderRelease validates the release in dir against the requirements of the Provider Registry Protocol.
+func checkProviderRelease(dir string, platforms []string, gpgVerify bool) *releaseCheckResult {
+	result := &releaseCheckResult{Checks: []releaseCheck{}}
+
+	archives, err := filepath.Glob(filepath.Join(dir, core.ProviderPrefix+"*"+core.ProviderExtension))
+	if err != nil || len(archives) == 0 {
+		result.add("archives", checkStatusFail, "no %s*%s archives found", core.ProviderPrefix, core.ProviderExtension)
+		return result
+	}
+	sort.Strings(archives)
+
+	providers := make([]core.Provider, 0, len(archives))
+	for _, archive := range archives {
+		p, err := core.NewProviderFromArchive(archive)
+		if err != nil {
+			result.add("archives", checkStatusFail, "%v", err)
+			return result
+		}
+		providers = append(providers, p)
+	}
+
+	result.Name, result.Version = providers[0].Name, providers[0].Version
+	for _, p := range providers {
+		if p.Name != result.Name || p.Version != result.Version {
+			result.add("archives", checkStatusFail, "archives of different releases found: %s", p.Filename)
+			return result
+		}
+	}
+	result.add("archives", checkStatusPass, "%d archives found", len(providers))
+
+	if _, err := version.NewSemver(result.Version); err != nil {
+		result.add("version", checkStatusFail, "%s is not a valid semantic version", result.Version)
+	} else {
+		result.add("version", checkStatusPass, "%s", result.Version)
+	}
+
+	checkPlatforms(result, providers, platforms)
+
+	sums := checkSHASums(result, dir, providers)
+	if sums != "" {
+		checkSignature(result, sums, gpgVerify)
+	}
+
+	checkManifest(result, dir)
+
+	return result
+}
+
+func checkPlatforms(result *releaseCheckResult, providers []core.Provider, platforms []string) {
+	found := make(map[string]bool, len(providers))
+	for _, p := range providers {
+		found[fmt.Sprintf("%s_%s", p.OS, p.Arch)] = true
+	}
+
+	var missing []string
+	for _, platform := range platforms {
+		if !found[platform] {
+			missing = append(missing, platform)
+		}
+	}
+
+	if len(missing) > 0 {
+		result.add("platforms", checkStatusFail, "missing platforms: %s", strings.Join(missing, ", "))
+		return
+	}
+
+	result.add("platforms", checkStatusPass, "all %d required platforms found", len(platforms))
+}
+
+// checkSHASums verifies the checksums of all archives and returns the path of the SHA256SUMS file if it exists.
+func checkSHASums(result *releaseCheckResult, dir string, providers []core.Provider) string {
+	name, _ := providers[0].ShasumFileName()
+	path := filepath.Join(dir, name)
+
+	f, err := os.Open(path)
+	if err != nil {
+		result.add("shasums", checkStatusFail, "%s not found", name)
+		return ""
+	}
+	defer f.Close()
+
+	sums := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// The same format as expected by the storage implementations: "<sha256>  <file>"
+		parts := strings.Split(scanner.Text(), " ")
+		if len(parts) == 3 {
+			sums[parts[2]] = parts[0]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		result.add("shasums", checkStatusFail, "failed to read %s: %v", name, err)
+		return path
+	}
+
+	var problems []string
+	for _, p := range providers {
+		expected, ok := sums[p.Filename]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s is missing", p.Filename))
+			continue
+		}
+
+		actual, err := sha256File(filepath.Join(dir, p.Filename))
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", p.Filename, err))
+		} else if actual != expected {
+			problems = append(problems, fmt.Sprintf("%s has a mismatching checksum", p.Filename))
+		}
+	}
+
+	if len(problems) > 0 {
+		result.add("shasums", checkStatusFail, "%s", strings.Join(problems, ", "))
+		return path
+	}
+
+	result.add("shasums", checkStatusPass, "checksums of all archives match")
+	return path
+}
+
+func checkSignature(result *releaseCheckResult, sums string, gpgVerify bool) {
+	sig := sums + ".sig"
+
+	fi, err := os.Stat(sig)
+	if err != nil || fi.Size() == 0 {
+		result.add("signature", checkStatusFail, "%s not found or empty", filepath.Base(sig))
+		return
+	}
+
+	if !gpgVerify {
+		result.add("signature", checkStatusPass, "%s found, pass --gpg-verify to verify it", filepath.Base(sig))
+		return
+	}
+
+	out, err := exec.Command("gpg", "--verify", sig, sums).CombinedOutput()
+	if err != nil {
+		result.add("signature", checkStatusFail, "gpg verification failed: %s", strings.TrimSpace(string(out)))
+		return
+	}
+
+	result.add("signature", checkStatusPass, "signature verified")
+}
+
+// checkManifest validates the optional terraform-registry-manifest.json of the release.
+func checkManifest(result *releaseCheckResult, dir string) {
+	name := fmt.Sprintf("%s%s_%s_manifest.json", core.ProviderPrefix, result.Name, result.Version)
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		result.add("manifest", checkStatusWarn, "%s not found, Terraform assumes protocol version 5", name)
+		return
+	}
+
+	var manifest struct {
+		Version  int `json:"version"`
+		Metadata struct {
+			ProtocolVersions []string `json:"protocol_versions"`
+		} `json:"metadata"`
+	}
+
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		result.add("manifest", checkStatusFail, "invalid %s: %v", name, err)
+		return
+	}
+
+	if len(manifest.Metadata.ProtocolVersions) == 0 {
+		result.add("manifest", checkStatusFail, "%s does not declare any protocol versions", name)
+		return
+	}
+
+	result.add("manifest", checkStatusPass, "protocol versions %s", strings.Join(manifest.Metadata.ProtocolVersions, ", "))
+}
+
+func sha256File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckProviderRelease(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		modify   func(dir string) error
+		expected map[string]string
+	}{
+		{
+			name:   "valid release",
+			modify: func(dir string) error { return nil },
+			expected: map[string]string{
+				"archives":  checkStatusPass,
+				"platforms": checkStatusPass,
+				"shasums":   checkStatusPass,
+				"signature": checkStatusPass,
+				"manifest":  checkStatusPass,
+			},
+		},
+		{
+			name: "missing platform",
+			modify: func(dir string) error {
+				return os.Remove(filepath.Join(dir, "terraform-provider-dummy_1.0.0_darwin_amd64.zip"))
+			},
+			expected: map[string]string{
+				"platforms": checkStatusFail,
+				"shasums":   checkStatusPass,
+			},
+		},
+		{
+			name: "modified archive",
+			modify: func(dir string) error {
+				return ioutil.WriteFile(filepath.Join(dir, "terraform-provider-dummy_1.0.0_linux_amd64.zip"), []byte("modified"), 0644)
+			},
+			expected: map[string]string{
+				"shasums": checkStatusFail,
+			},
+		},
+		{
+			name: "missing signature",
+			modify: func(dir string) error {
+				return os.Remove(filepath.Join(dir, "terraform-provider-dummy_1.0.0_SHA256SUMS.sig"))
+			},
+			expected: map[string]string{
+				"signature": checkStatusFail,
+			},
+		},
+		{
+			name: "missing manifest",
+			modify: func(dir string) error {
+				return os.Remove(filepath.Join(dir, "terraform-provider-dummy_1.0.0_manifest.json"))
+			},
+			expected: map[string]string{
+				"manifest": checkStatusWarn,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+
+			dir, err := ioutil.TempDir("", "boring-registry-release")
+			assert.NoError(err)
+			defer os.RemoveAll(dir)
+
+			assert.NoError(writeTestRelease(dir))
+			assert.NoError(tc.modify(dir))
+
+			result := checkProviderRelease(dir, []string{"linux_amd64", "darwin_amd64"}, false)
+			assert.Equal("dummy", result.Name)
+			assert.Equal("1.0.0", result.Version)
+
+			statuses := make(map[string]string)
+			for _, c := range result.Checks {
+				statuses[c.Name] = c.Status
+			}
+			for name, status := range tc.expected {
+				assert.Equal(status, statuses[name], name)
+			}
+		})
+	}
+}
+
+func TestCheckProviderRelease_Empty(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "boring-registry-release")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.True(t, checkProviderRelease(dir, nil, false).failed())
+}
+
+func writeTestRelease(dir string) error {
+	var sums strings.Builder
+	for _, platform := range []string{"linux_amd64", "darwin_amd64"} {
+		name := fmt.Sprintf("terraform-provider-dummy_1.0.0_%s.zip", platform)
+		data := []byte(platform)
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		fmt.Fprintf(&sums, "%s  %s\n", hex.EncodeToString(sum[:]), name)
+	}
+
+	files := map[string]string{
+		"terraform-provider-dummy_1.0.0_SHA256SUMS":     sums.String(),
+		"terraform-provider-dummy_1.0.0_SHA256SUMS.sig": "signature",
+		"terraform-provider-dummy_1.0.0_manifest.json":  `{"version": 1, "metadata": {"protocol_versions": ["5.0"]}}`,
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/TierMobility/boring-registry/pkg/module"
+)
+
+var (
+	flagStorageSecondary              string
+	flagStorageSecondaryFailurePolicy string
+)
+
+var replicationFailuresTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "boring_registry_storage_replication_failures_total",
+	Help: "Number of module writes which failed to replicate to the secondary storage.",
+}, []string{"operation"})
+
+func init() {
+	prometheus.MustRegister(replicationFailuresTotal)
+
+	serverCmd.Flags().StringVar(&flagStorageSecondary, "storage-secondary", "", "Storage URL all module writes are replicated to, e.g. s3://bucket/prefix?region=eu-central-1 or gs://bucket/prefix, while reads stay on the storage")
+	serverCmd.Flags().StringVar(&flagStorageSecondaryFailurePolicy, "storage-secondary-failure-policy", string(module.ReplicationPolicyFail), "Outcome of writes which failed to replicate to the secondary storage, fail or ignore")
+}
+
+// replicatingModuleStorage wraps the storage to replicate its writes to the --storage-secondary storage, if set.
+func replicatingModuleStorage(storage module.Storage) (module.Storage, error) {
+	if flagStorageSecondary == "" {
+		return storage, nil
+	}
+
+	policy := module.ReplicationPolicy(flagStorageSecondaryFailurePolicy)
+	if policy != module.ReplicationPolicyFail && policy != module.ReplicationPolicyIgnore {
+		return nil, usageError{fmt.Errorf("invalid secondary storage failure policy %q, expected fail or ignore", flagStorageSecondaryFailurePolicy)}
+	}
+
+	secondary, err := parseTarget(flagStorageSecondary)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to setup secondary storage")
+	}
+
+	return module.NewReplicatingStorage(storage, secondary,
+		module.WithReplicationPolicy(policy),
+		module.WithReplicationReport(reportReplicationFailure),
+	), nil
+}
+
+// reportReplicationFailure logs and counts a write which failed to replicate to the secondary storage.
+func reportReplicationFailure(failure module.ReplicationFailure) {
+	replicationFailuresTotal.WithLabelValues(failure.Operation).Inc()
+
+	_ = level.Error(logger).Log(
+		"msg", "failed to replicate to secondary storage",
+		"operation", failure.Operation,
+		"module", fmt.Sprintf("%s/%s/%s", failure.Namespace, failure.Name, failure.Provider),
+		"version", failure.Version,
+		"err", failure.Err,
+	)
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/TierMobility/boring-registry/pkg/module"
+)
+
+var (
+	flagReserved            []string
+	flagReservedAdminAPIKey string
+)
+
+func init() {
+	serverCmd.Flags().StringArrayVar(&flagReserved, "reserved", nil, "Namespace or NAMESPACE/NAME that can't be claimed by uploads, e.g. hashicorp or */aws (can be repeated)")
+	serverCmd.Flags().StringVar(&flagReservedAdminAPIKey, "reserved-admin-api-key", "", "Comma-separated string of upload API keys allowed to upload to reserved namespaces and names")
+}
+
+// parseReserved parses the NAMESPACE and NAMESPACE/NAME entries of the --reserved flag.
+func parseReserved(entries []string) (module.Reserved, error) {
+	reserved := make(module.Reserved, 0, len(entries))
+
+	for _, raw := range entries {
+		parts := strings.Split(raw, "/")
+		if len(parts) > 2 || parts[0] == "" || (len(parts) == 2 && parts[1] == "") {
+			return nil, usageError{fmt.Errorf("invalid reserved entry %q, expected NAMESPACE or NAMESPACE/NAME", raw)}
+		}
+		if len(parts) == 1 && parts[0] == "*" {
+			return nil, usageError{fmt.Errorf("invalid reserved entry %q, the wildcard namespace requires a NAME", raw)}
+		}
+
+		reserved = append(reserved, raw)
+	}
+
+	return reserved, nil
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/TierMobility/boring-registry/pkg/module"
+)
+
+func TestParseReserved(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		entries   []string
+		expected  module.Reserved
+		expectErr bool
+	}{
+		{
+			name:     "empty",
+			expected: module.Reserved{},
+		},
+		{
+			name:     "namespaces and names",
+			entries:  []string{"hashicorp", "tier/aws", "*/terraform"},
+			expected: module.Reserved{"hashicorp", "tier/aws", "*/terraform"},
+		},
+		{
+			name:      "missing name",
+			entries:   []string{"tier/"},
+			expectErr: true,
+		},
+		{
+			name:      "wildcard namespace",
+			entries:   []string{"*"},
+			expectErr: true,
+		},
+		{
+			name:      "too many parts",
+			entries:   []string{"tier/vpc/aws"},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			reserved, err := parseReserved(tc.entries)
+			if tc.expectErr {
+				assert.Equal(t, exitCodeUsage, exitCode(err))
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, reserved)
+		})
+	}
+}
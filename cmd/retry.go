@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/TierMobility/boring-registry/pkg/module"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	"google.golang.org/api/googleapi"
+)
+
+// backoff configures how failed operations are retried.
+type backoff struct {
+	retries int
+	initial time.Duration
+	max     time.Duration
+}
+
+// delay returns the time to wait before the given retry attempt, starting at 1.
+// It uses exponential backoff with full jitter.
+func (b backoff) delay(attempt int) time.Duration {
+	d := b.initial << uint(attempt-1)
+	if d <= 0 || d > b.max {
+		d = b.max
+	}
+
+	if d <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(d)) + 1)
+}
+
+// retry calls fn until it succeeds, returns a non-retryable error or the retries are exhausted.
+func (b backoff) retry(ctx context.Context, op string, fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= b.retries || !isRetryable(err) {
+			return err
+		}
+
+		delay := b.delay(attempt + 1)
+		_ = level.Warn(logger).Log(
+			"msg", "retrying failed operation",
+			"op", op,
+			"attempt", attempt+1,
+			"delay", delay,
+			"err", err,
+		)
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+// isRetryable reports whether err is a transient failure that might succeed when retried.
+// Conflicts, authentication failures and invalid requests are never retried.
+func isRetryable(err error) bool {
+	var (
+		reqErr    awserr.RequestFailure
+		awsErr    awserr.Error
+		googleErr *googleapi.Error
+		statusErr *statusError
+		netErr    net.Error
+	)
+
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return false
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, module.ErrChecksumMismatch):
+		return true
+	case errors.As(err, &reqErr):
+		return retryableStatus(reqErr.StatusCode())
+	case errors.As(err, &awsErr):
+		switch awsErr.Code() {
+		case "RequestError", "RequestTimeout", "RequestTimeoutException", "Throttling", "ThrottlingException", "SlowDown", "InternalError", "ServiceUnavailable":
+			return true
+		}
+		return false
+	case errors.As(err, &googleErr):
+		return retryableStatus(googleErr.Code)
+	case errors.As(err, &statusErr):
+		return retryableStatus(statusErr.code)
+	case errors.As(err, &netErr):
+		return true
+	}
+
+	return false
+}
+
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/googleapi"
+
+	"github.com/TierMobility/boring-registry/pkg/module"
+)
+
+func TestIsRetryable(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "nil", err: nil, expected: false},
+		{name: "unclassified", err: errors.New("boom"), expected: false},
+		{name: "conflict", err: errors.Wrap(module.ErrAlreadyExists, "tier/test/dummy"), expected: false},
+		{name: "canceled", err: context.Canceled, expected: false},
+		{name: "aws service unavailable", err: awserr.NewRequestFailure(awserr.New("ServiceUnavailable", "", nil), 503, ""), expected: true},
+		{name: "aws access denied", err: awserr.NewRequestFailure(awserr.New("AccessDenied", "", nil), 403, ""), expected: false},
+		{name: "aws slow down", err: awserr.New("SlowDown", "", nil), expected: true},
+		{name: "googleapi too many requests", err: &googleapi.Error{Code: 429}, expected: true},
+		{name: "googleapi forbidden", err: &googleapi.Error{Code: 403}, expected: false},
+		{name: "registry unavailable", err: &statusError{url: "https://example.com", code: 503}, expected: true},
+		{name: "registry conflict", err: &statusError{url: "https://example.com", code: 409}, expected: false},
+		{name: "network", err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, expected: true},
+		{name: "unexpected eof", err: errors.Wrap(io.ErrUnexpectedEOF, "upload"), expected: true},
+		{name: "checksum mismatch", err: errors.Wrap(module.ErrChecksumMismatch, "tier/test/dummy"), expected: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, isRetryable(tc.err))
+		})
+	}
+}
+
+func TestBackoff_Retry(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name          string
+		err           error
+		succeedAfter  int
+		expectedCalls int
+		expectErr     bool
+	}{
+		{name: "success", err: nil, succeedAfter: 0, expectedCalls: 1, expectErr: false},
+		{name: "transient failure", err: &googleapi.Error{Code: 503}, succeedAfter: 2, expectedCalls: 3, expectErr: false},
+		{name: "retries exhausted", err: &googleapi.Error{Code: 503}, succeedAfter: 10, expectedCalls: 4, expectErr: true},
+		{name: "non-retryable", err: module.ErrAlreadyExists, succeedAfter: 10, expectedCalls: 1, expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+
+			b := backoff{retries: 3, initial: time.Millisecond, max: time.Millisecond}
+
+			calls := 0
+			err := b.retry(context.Background(), "test", func() error {
+				calls++
+				if calls > tc.succeedAfter {
+					return nil
+				}
+				return tc.err
+			})
+
+			assert.Equal(tc.expectedCalls, calls)
+			if tc.expectErr {
+				assert.Error(err)
+			} else {
+				assert.NoError(err)
+			}
+		})
+	}
+}
+
+func TestBackoff_Delay(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	b := backoff{initial: time.Second, max: 4 * time.Second}
+
+	for attempt := 1; attempt <= 10; attempt++ {
+		d := b.delay(attempt)
+		assert.True(d > 0)
+		assert.True(d <= 4*time.Second)
+	}
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+)
+
+var flagRewrites []string
+
+func init() {
+	serverCmd.Flags().StringArrayVar(&flagRewrites, "rewrite", nil, "Serve requests for a namespace, module or provider from another one in the format FROM=TO, e.g. hashicorp=vetted or terraform-aws-modules/vpc/aws=platform/vpc/aws (can be repeated)")
+}
+
+// rewriteRules are the rewrite rules of modules and providers.
+type rewriteRules struct {
+	modules   map[string]string
+	providers map[string]string
+}
+
+// parseRewrites parses rewrite rules in the format FROM=TO into the rules of modules and providers.
+// A namespace rule applies to both, a rule of namespace/name/provider to modules and of namespace/name to providers.
+func parseRewrites(raw []string) (rewriteRules, error) {
+	rules := rewriteRules{
+		modules:   make(map[string]string),
+		providers: make(map[string]string),
+	}
+
+	for _, r := range raw {
+		parts := strings.SplitN(r, "=", 2)
+		if len(parts) != 2 {
+			return rewriteRules{}, usageError{fmt.Errorf("invalid rewrite %q, expected FROM=TO", r)}
+		}
+
+		from, to := strings.Split(parts[0], "/"), strings.Split(parts[1], "/")
+		if len(from) != len(to) || hasEmpty(from) || hasEmpty(to) {
+			return rewriteRules{}, usageError{fmt.Errorf("invalid rewrite %q, both sides must be a namespace, namespace/name or namespace/name/provider", r)}
+		}
+
+		switch len(from) {
+		case 1:
+			rules.modules[parts[0]] = parts[1]
+			rules.providers[parts[0]] = parts[1]
+		case 2:
+			rules.providers[parts[0]] = parts[1]
+		case 3:
+			rules.modules[parts[0]] = parts[1]
+		default:
+			return rewriteRules{}, usageError{fmt.Errorf("invalid rewrite %q, both sides must be a namespace, namespace/name or namespace/name/provider", r)}
+		}
+	}
+
+	return rules, nil
+}
+
+func hasEmpty(parts []string) bool {
+	for _, part := range parts {
+		if part == "" {
+			return true
+		}
+	}
+	return false
+}
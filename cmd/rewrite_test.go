@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRewrites(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		entries   []string
+		expected  rewriteRules
+		expectErr bool
+	}{
+		{
+			name:     "empty",
+			expected: rewriteRules{modules: map[string]string{}, providers: map[string]string{}},
+		},
+		{
+			name:    "namespace, provider and module rules",
+			entries: []string{"hashicorp=vetted", "integrations/github=vetted/github", "terraform-aws-modules/vpc/aws=platform/vpc/aws"},
+			expected: rewriteRules{
+				modules:   map[string]string{"hashicorp": "vetted", "terraform-aws-modules/vpc/aws": "platform/vpc/aws"},
+				providers: map[string]string{"hashicorp": "vetted", "integrations/github": "vetted/github"},
+			},
+		},
+		{
+			name:      "missing target",
+			entries:   []string{"hashicorp"},
+			expectErr: true,
+		},
+		{
+			name:      "mismatched sides",
+			entries:   []string{"terraform-aws-modules/vpc/aws=platform"},
+			expectErr: true,
+		},
+		{
+			name:      "empty segment",
+			entries:   []string{"hashicorp/=vetted/aws"},
+			expectErr: true,
+		},
+		{
+			name:      "too many segments",
+			entries:   []string{"a/b/c/d=e/f/g/h"},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			rules, err := parseRewrites(tc.entries)
+			if tc.expectErr {
+				assert.Equal(t, exitCodeUsage, exitCode(err))
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, rules)
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"io"
 	"os"
 	"path"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -28,8 +29,13 @@ const (
 )
 
 var (
-	flagJSON  bool
-	flagDebug bool
+	flagJSON   bool
+	flagDebug  bool
+	flagOutput string
+
+	// CLI configuration options.
+	flagConfigFile string
+	flagProfile    string
 
 	// S3 options.
 	flagS3Bucket    string
@@ -38,16 +44,24 @@ var (
 	flagS3Endpoint  string
 	flagS3PathStyle bool
 
+	flagS3StorageClass          string
+	flagS3NamespaceStorageClass []string
+
 	// GCS options.
 	flagGCSBucket          string
 	flagGCSPrefix          string
 	flagGCSServiceAccount  string
 	flagGCSSignedURL       bool
 	flagGCSSignedURLExpiry time.Duration
+
+	// Local storage options.
+	flagLocalDir     string
+	flagLocalBaseURL string
 )
 
 var (
-	logger log.Logger
+	// logger is replaced in PersistentPreRunE once the output flags are parsed.
+	logger log.Logger = log.NewNopLogger()
 )
 
 var rootCmd = &cobra.Command{
@@ -58,7 +72,15 @@ var rootCmd = &cobra.Command{
 			return err
 		}
 
-		logger = setupLogger(os.Stdout)
+		switch flagOutput {
+		case outputText:
+			logger = setupLogger(os.Stdout)
+		case outputJSON:
+			// Keep stdout reserved for the machine-readable output
+			logger = setupLogger(os.Stderr)
+		default:
+			return usageError{fmt.Errorf("invalid output format: %s", flagOutput)}
+		}
 
 		if flagDebug {
 			level.Debug(logger).Log("msg", "debug mode enabled")
@@ -71,18 +93,27 @@ var rootCmd = &cobra.Command{
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		os.Exit(exitCode(err))
 	}
 }
 
 func init() {
+	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return usageError{err}
+	})
+
 	rootCmd.PersistentFlags().BoolVar(&flagJSON, "json", false, "Enable json logging")
 	rootCmd.PersistentFlags().BoolVar(&flagDebug, "debug", false, "Enable debug logging")
+	rootCmd.PersistentFlags().StringVar(&flagOutput, "output", outputText, "Output format of command results (text or json)")
+	rootCmd.PersistentFlags().StringVar(&flagConfigFile, "config-file", defaultConfigFile(), "Path to the CLI configuration file containing named profiles")
+	rootCmd.PersistentFlags().StringVar(&flagProfile, "profile", "", "Named profile of the configuration file to use, defaults to the \"default\" profile if present")
 	rootCmd.PersistentFlags().StringVar(&flagS3Bucket, "storage-s3-bucket", "", "S3 bucket to use for the registry")
 	rootCmd.PersistentFlags().StringVar(&flagS3Prefix, "storage-s3-prefix", "", "S3 bucket prefix to use for the registry")
 	rootCmd.PersistentFlags().StringVar(&flagS3Region, "storage-s3-region", "", "S3 bucket region to use for the registry")
 	rootCmd.PersistentFlags().StringVar(&flagS3Endpoint, "storage-s3-endpoint", "", "S3 bucket endpoint URL (required for MINIO)")
 	rootCmd.PersistentFlags().BoolVar(&flagS3PathStyle, "storage-s3-pathstyle", false, "S3 use PathStyle (required for MINIO)")
+	rootCmd.PersistentFlags().StringVar(&flagS3StorageClass, "storage-s3-storage-class", "", "S3 storage class of uploaded modules, e.g. STANDARD_IA or INTELLIGENT_TIERING")
+	rootCmd.PersistentFlags().StringArrayVar(&flagS3NamespaceStorageClass, "storage-s3-namespace-storage-class", nil, "S3 storage class of modules uploaded to a namespace, e.g. archive=ONEZONE_IA (can be repeated)")
 	rootCmd.PersistentFlags().StringVar(&flagGCSBucket, "storage-gcs-bucket", "", "Bucket to use when using the GCS registry type")
 	rootCmd.PersistentFlags().StringVar(&flagGCSPrefix, "storage-gcs-prefix", "", "Prefix to use when using the GCS registry type")
 	rootCmd.PersistentFlags().StringVar(&flagGCSServiceAccount, "storage-gcs-sa-email", "", `Google service account email to be used for Application Default Credentials (ADC)
@@ -90,6 +121,8 @@ GOOGLE_APPLICATION_CREDENTIALS environment variable might be used as alternative
 For GCS presigned URLs this SA needs the iam.serviceAccountTokenCreator role.`)
 	rootCmd.PersistentFlags().BoolVar(&flagGCSSignedURL, "storage-gcs-signedurl", false, `Generate GCS signedURL (public) instead of relying on GCP credentials being set on terraform init.
 WARNING: only use in combination with api-key option.`)
+	rootCmd.PersistentFlags().StringVar(&flagLocalDir, "storage-local-dir", "", "Directory on the local disk to use for the registry instead of a bucket")
+	rootCmd.PersistentFlags().StringVar(&flagLocalBaseURL, "storage-local-base-url", prefixFiles, "URL the server serves the files of the local storage under, relative URLs are resolved against the registry host")
 	rootCmd.PersistentFlags().DurationVar(&flagGCSSignedURLExpiry, "storage-gcs-signedurl-expiry", 30*time.Second, "Generate GCS signed URL valid for X seconds. Only meaningful if used in combination with `gcs-signedurl`")
 }
 
@@ -98,6 +131,10 @@ func initializeConfig(cmd *cobra.Command) error {
 	v.SetEnvPrefix(envPrefix)
 	v.AutomaticEnv()
 	bindFlags(cmd, v)
+	if err := applyProfile(cmd, v); err != nil {
+		return err
+	}
+	applyStoredToken(cmd)
 	return nil
 }
 
@@ -130,8 +167,7 @@ func setupLogger(w io.Writer) log.Logger {
 
 func bindFlags(cmd *cobra.Command, v *viper.Viper) {
 	cmd.Flags().VisitAll(func(f *pflag.Flag) {
-		envVarSuffix := strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
-		v.BindEnv(f.Name, fmt.Sprintf("%s_%s", envPrefix, envVarSuffix))
+		v.BindEnv(f.Name, envVarName(f.Name))
 		if !f.Changed && v.IsSet(f.Name) {
 			val := v.Get(f.Name)
 			cmd.Flags().Set(f.Name, fmt.Sprintf("%v", val))
@@ -139,13 +175,67 @@ func bindFlags(cmd *cobra.Command, v *viper.Viper) {
 	})
 }
 
+// envVarName returns the name of the environment variable for a given flag.
+func envVarName(flag string) string {
+	return fmt.Sprintf("%s_%s", envPrefix, strings.ToUpper(strings.ReplaceAll(flag, "-", "_")))
+}
+
 func setupS3ModuleStorage() (module.Storage, error) {
-	return module.NewS3Storage(flagS3Bucket,
+	classes, err := s3StorageClassOptions(flagS3StorageClass, flagS3NamespaceStorageClass)
+	if err != nil {
+		return nil, err
+	}
+
+	cloudFront, err := s3CloudFrontOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	creds, err := s3Credentials()
+	if err != nil {
+		return nil, err
+	}
+
+	options := append([]module.S3StorageOption{
 		module.WithS3StorageBucketPrefix(path.Join(flagS3Prefix, "modules")),
 		module.WithS3ArchiveFormat(flagModuleArchiveFormat),
 		module.WithS3StorageBucketRegion(flagS3Region),
 		module.WithS3StorageBucketEndpoint(flagS3Endpoint),
 		module.WithS3StoragePathStyle(flagS3PathStyle),
+		module.WithS3HTTPClient(s3HTTPClient()),
+		module.WithS3Credentials(creds),
+		module.WithS3MaxRetries(flagS3MaxRetries),
+		module.WithS3UploadPartSize(flagS3UploadPartSize),
+		module.WithS3UploadConcurrency(flagS3UploadConcurrency),
+		module.WithS3DownloadURLTemplate(flagS3DownloadURLTemplate),
+		module.WithS3Logger(logger),
+	}, classes...)
+	options = append(options, cloudFront...)
+
+	return module.NewS3Storage(flagS3Bucket, options...)
+}
+
+// s3StorageClassOptions returns the options of the storage class of uploads and its overrides in the format NAMESPACE=CLASS.
+func s3StorageClassOptions(class string, namespaceClasses []string) ([]module.S3StorageOption, error) {
+	options := []module.S3StorageOption{
+		module.WithS3StorageClass(class),
+	}
+
+	for _, raw := range namespaceClasses {
+		parts := strings.SplitN(raw, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, usageError{fmt.Errorf("invalid namespace storage class %q, expected NAMESPACE=CLASS", raw)}
+		}
+		options = append(options, module.WithS3NamespaceStorageClass(parts[0], parts[1]))
+	}
+
+	return options, nil
+}
+
+func setupLocalModuleStorage() (module.Storage, error) {
+	return module.NewLocalStorage(filepath.Join(flagLocalDir, "modules"),
+		module.WithLocalStorageBaseURL(flagLocalBaseURL+"/modules"),
+		module.WithLocalArchiveFormat(flagModuleArchiveFormat),
 	)
 }
 
@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/processcreds"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/pkg/errors"
+
+	"github.com/TierMobility/boring-registry/pkg/module"
+)
+
+// s3CredentialsExpiryWindow is how long before their expiry S3 credentials are refreshed,
+// so requests and presigned URLs never use credentials which are about to expire.
+const s3CredentialsExpiryWindow = 5 * time.Minute
+
+var (
+	flagS3MaxIdleConns int
+	flagS3Timeout      time.Duration
+	flagS3MaxRetries   int
+
+	flagS3UploadPartSize    int64
+	flagS3UploadConcurrency int
+
+	flagS3DownloadURLTemplate string
+
+	flagS3CloudFrontKeyPairID  string
+	flagS3CloudFrontPrivateKey string
+	flagS3CloudFrontURLExpiry  time.Duration
+
+	flagS3RoleARN              string
+	flagS3WebIdentityTokenFile string
+	flagS3CredentialProcess    string
+)
+
+func init() {
+	rootCmd.PersistentFlags().IntVar(&flagS3MaxIdleConns, "storage-s3-max-idle-conns", 0, "Maximum number of idle connections kept open to S3, 0 keeps the Go default of 2")
+	rootCmd.PersistentFlags().DurationVar(&flagS3Timeout, "storage-s3-timeout", 0, "Timeout of a single request to S3 including reading the response, 0 disables the timeout")
+	rootCmd.PersistentFlags().IntVar(&flagS3MaxRetries, "storage-s3-max-retries", -1, "Number of retries of failed requests to S3, -1 keeps the SDK default of 3")
+	rootCmd.PersistentFlags().Int64Var(&flagS3UploadPartSize, "storage-s3-upload-part-size", 0, "Size in bytes of the parts larger module archives are uploaded to S3 in, 0 keeps the SDK default of 5 MiB")
+	rootCmd.PersistentFlags().IntVar(&flagS3UploadConcurrency, "storage-s3-upload-concurrency", 0, "Number of parts of a module archive uploaded to S3 in parallel, 0 keeps the SDK default of 5")
+	rootCmd.PersistentFlags().StringVar(&flagS3DownloadURLTemplate, "storage-s3-download-url-template", "", "Go template of the download URLs of modules with .Bucket, .Region, .Endpoint and .Key, e.g. https://modules.example.com/{{.Key}}")
+	rootCmd.PersistentFlags().StringVar(&flagS3CloudFrontKeyPairID, "storage-s3-cloudfront-key-pair-id", "", "ID of the public key download URLs are signed for CloudFront with, requires --storage-s3-download-url-template")
+	rootCmd.PersistentFlags().StringVar(&flagS3CloudFrontPrivateKey, "storage-s3-cloudfront-private-key-file", "", "PEM file of the RSA private key of --storage-s3-cloudfront-key-pair-id")
+	rootCmd.PersistentFlags().DurationVar(&flagS3CloudFrontURLExpiry, "storage-s3-cloudfront-url-expiry", 5*time.Minute, "Duration signed CloudFront download URLs are valid for")
+	rootCmd.PersistentFlags().StringVar(&flagS3RoleARN, "storage-s3-role-arn", "", "IAM role assumed with the token of --storage-s3-web-identity-token-file")
+	rootCmd.PersistentFlags().StringVar(&flagS3WebIdentityTokenFile, "storage-s3-web-identity-token-file", "", "File of the web identity token, e.g. of IRSA, which is read again whenever the credentials are refreshed")
+	rootCmd.PersistentFlags().StringVar(&flagS3CredentialProcess, "storage-s3-credential-process", "", "Command printing the S3 credentials in the credential_process format, which is run again whenever they expire")
+}
+
+var (
+	sharedS3HTTPClientOnce sync.Once
+	sharedS3HTTPClient     *http.Client
+)
+
+// s3HTTPClient returns the HTTP client shared by all S3 storages, so they reuse connections.
+// It is nil if no tuning flag is set, which keeps the SDK default client.
+func s3HTTPClient() *http.Client {
+	sharedS3HTTPClientOnce.Do(func() {
+		if flagS3MaxIdleConns == 0 && flagS3Timeout == 0 {
+			return
+		}
+		sharedS3HTTPClient = newS3HTTPClient(flagS3MaxIdleConns, flagS3Timeout)
+	})
+
+	return sharedS3HTTPClient
+}
+
+func newS3HTTPClient(maxIdleConns int, timeout time.Duration) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if maxIdleConns > 0 {
+		// All requests go to the same host, so the idle connections per host are raised as well
+		transport.MaxIdleConns = maxIdleConns
+		transport.MaxIdleConnsPerHost = maxIdleConns
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+	}
+}
+
+// s3CloudFrontOptions returns the option signing download URLs for CloudFront, it's empty without --storage-s3-cloudfront-key-pair-id.
+func s3CloudFrontOptions() ([]module.S3StorageOption, error) {
+	if flagS3CloudFrontKeyPairID == "" && flagS3CloudFrontPrivateKey == "" {
+		return nil, nil
+	}
+	if flagS3CloudFrontKeyPairID == "" || flagS3CloudFrontPrivateKey == "" {
+		return nil, usageError{fmt.Errorf("--storage-s3-cloudfront-key-pair-id and --storage-s3-cloudfront-private-key-file must be set together")}
+	}
+	if flagS3DownloadURLTemplate == "" {
+		return nil, usageError{fmt.Errorf("--storage-s3-cloudfront-key-pair-id requires --storage-s3-download-url-template with the URL of the distribution")}
+	}
+
+	key, err := ioutil.ReadFile(flagS3CloudFrontPrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := module.NewCloudFrontSigner(flagS3CloudFrontKeyPairID, key, flagS3CloudFrontURLExpiry)
+	if err != nil {
+		return nil, usageError{errors.Wrap(err, "invalid CloudFront key")}
+	}
+
+	return []module.S3StorageOption{module.WithS3CloudFrontSigner(signer)}, nil
+}
+
+var (
+	sharedS3CredentialsOnce sync.Once
+	sharedS3Credentials     *credentials.Credentials
+	sharedS3CredentialsErr  error
+)
+
+// s3Credentials returns the credentials shared by all S3 storages, so they are only refreshed once.
+// It is nil if no credential flag is set, which keeps the default credential chain of the SDK.
+func s3Credentials() (*credentials.Credentials, error) {
+	sharedS3CredentialsOnce.Do(func() {
+		sharedS3Credentials, sharedS3CredentialsErr = newS3Credentials(flagS3RoleARN, flagS3WebIdentityTokenFile, flagS3CredentialProcess)
+	})
+
+	return sharedS3Credentials, sharedS3CredentialsErr
+}
+
+func newS3Credentials(roleARN, tokenFile, process string) (*credentials.Credentials, error) {
+	switch {
+	case process != "" && (roleARN != "" || tokenFile != ""):
+		return nil, usageError{fmt.Errorf("--storage-s3-credential-process can't be combined with --storage-s3-role-arn or --storage-s3-web-identity-token-file")}
+	case process != "":
+		return processcreds.NewCredentials(process, func(p *processcreds.ProcessProvider) {
+			p.ExpiryWindow = s3CredentialsExpiryWindow
+		}), nil
+	case roleARN == "" && tokenFile == "":
+		return nil, nil
+	case roleARN == "" || tokenFile == "":
+		return nil, usageError{fmt.Errorf("--storage-s3-role-arn and --storage-s3-web-identity-token-file must be set together")}
+	}
+
+	sess, err := session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	provider := stscreds.NewWebIdentityRoleProvider(sts.New(sess), roleARN, "boring-registry", tokenFile)
+	provider.ExpiryWindow = s3CredentialsExpiryWindow
+
+	return credentials.NewCredentials(provider), nil
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewS3HTTPClient(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name         string
+		maxIdleConns int
+		timeout      time.Duration
+		expectedIdle int
+	}{
+		{name: "go defaults", expectedIdle: http.DefaultTransport.(*http.Transport).MaxIdleConnsPerHost},
+		{name: "tuned", maxIdleConns: 64, timeout: time.Minute, expectedIdle: 64},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+
+			client := newS3HTTPClient(tc.maxIdleConns, tc.timeout)
+			transport := client.Transport.(*http.Transport)
+
+			assert.Equal(tc.timeout, client.Timeout)
+			assert.Equal(tc.expectedIdle, transport.MaxIdleConnsPerHost)
+			// The default transport must not be changed
+			assert.NotSame(http.DefaultTransport, transport)
+		})
+	}
+}
+
+func TestNewS3Credentials(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		roleARN   string
+		tokenFile string
+		process   string
+		expectNil bool
+	}{
+		{name: "default chain", expectNil: true},
+		{name: "web identity", roleARN: "arn:aws:iam::123456789012:role/registry", tokenFile: "/var/run/secrets/token"},
+		{name: "credential process", process: "aws-vault export --format=json registry"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+
+			creds, err := newS3Credentials(tc.roleARN, tc.tokenFile, tc.process)
+			assert.NoError(err)
+			assert.Equal(tc.expectNil, creds == nil)
+		})
+	}
+}
+
+func TestNewS3Credentials_Usage(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		roleARN   string
+		tokenFile string
+		process   string
+	}{
+		{name: "role without token", roleARN: "arn:aws:iam::123456789012:role/registry"},
+		{name: "token without role", tokenFile: "/var/run/secrets/token"},
+		{name: "process and web identity", roleARN: "arn:aws:iam::123456789012:role/registry", tokenFile: "/var/run/secrets/token", process: "true"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := newS3Credentials(tc.roleARN, tc.tokenFile, tc.process)
+			assert.Equal(t, exitCodeUsage, exitCode(err))
+		})
+	}
+}
+
+func TestNewS3Credentials_RefreshBeforeExpiry(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("credential process is run by sh")
+	}
+	t.Parallel()
+	assert := assert.New(t)
+
+	// The credentials expire within the expiry window, so they are refreshed on the next request
+	expiration := time.Now().Add(s3CredentialsExpiryWindow / 2).UTC().Format(time.RFC3339)
+	process := fmt.Sprintf(`echo '{"Version":1,"AccessKeyId":"AKID","SecretAccessKey":"SECRET","SessionToken":"TOKEN","Expiration":"%s"}'`, expiration)
+
+	creds, err := newS3Credentials("", "", process)
+	assert.NoError(err)
+
+	value, err := creds.Get()
+	assert.NoError(err)
+	assert.Equal("AKID", value.AccessKeyID)
+	assert.Equal("TOKEN", value.SessionToken)
+	assert.True(creds.IsExpired())
+}
@@ -0,0 +1,318 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/pkg/errors"
+
+	"github.com/TierMobility/boring-registry/version"
+)
+
+// sentryQueueSize is the number of events which are buffered while they are sent, further events are dropped.
+const sentryQueueSize = 64
+
+var (
+	flagSentryDSN         string
+	flagSentryEnvironment string
+)
+
+// errorReporter reports panics and server errors, it's nil without --sentry-dsn.
+var errorReporter *sentryReporter
+
+func init() {
+	serverCmd.Flags().StringVar(&flagSentryDSN, "sentry-dsn", "", "DSN of a Sentry-compatible project panics and server errors are reported to")
+	serverCmd.Flags().StringVar(&flagSentryEnvironment, "sentry-environment", "", "Environment of the reported errors, e.g. production")
+}
+
+// setupErrorReporting starts reporting errors to the project of --sentry-dsn.
+func setupErrorReporting() error {
+	if flagSentryDSN == "" {
+		return nil
+	}
+
+	reporter, err := newSentryReporter(flagSentryDSN, flagSentryEnvironment)
+	if err != nil {
+		return usageError{errors.Wrap(err, "invalid --sentry-dsn")}
+	}
+	go reporter.run()
+
+	errorReporter = reporter
+	return nil
+}
+
+// sentryReporter sends events to the store endpoint of a Sentry-compatible project in the background.
+type sentryReporter struct {
+	endpoint    string
+	auth        string
+	environment string
+	serverName  string
+	client      *http.Client
+	events      chan sentryEvent
+}
+
+// newSentryReporter parses a DSN in the format SCHEME://KEY@HOST[/PATH]/PROJECT.
+func newSentryReporter(dsn, environment string) (*sentryReporter, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.Errorf("unsupported scheme %q, expected http or https", u.Scheme)
+	}
+	if u.User == nil || u.User.Username() == "" {
+		return nil, errors.New("missing public key")
+	}
+
+	prefix, project := path.Split(strings.TrimSuffix(u.Path, "/"))
+	if project == "" {
+		return nil, errors.New("missing project ID")
+	}
+
+	auth := fmt.Sprintf("Sentry sentry_version=7, sentry_client=boring-registry/%s, sentry_key=%s", version.Version, u.User.Username())
+	if secret, ok := u.User.Password(); ok {
+		auth += ", sentry_secret=" + secret
+	}
+
+	serverName, _ := os.Hostname()
+
+	return &sentryReporter{
+		endpoint:    fmt.Sprintf("%s://%s%sapi/%s/store/", u.Scheme, u.Host, prefix, project),
+		auth:        auth,
+		environment: environment,
+		serverName:  serverName,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		events:      make(chan sentryEvent, sentryQueueSize),
+	}, nil
+}
+
+type sentryEvent struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   string            `json:"timestamp"`
+	Level       string            `json:"level"`
+	Platform    string            `json:"platform"`
+	Logger      string            `json:"logger"`
+	ServerName  string            `json:"server_name,omitempty"`
+	Release     string            `json:"release,omitempty"`
+	Environment string            `json:"environment,omitempty"`
+	Exception   sentryExceptions  `json:"exception"`
+	Request     *sentryRequest    `json:"request,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Extra       map[string]string `json:"extra,omitempty"`
+}
+
+type sentryExceptions struct {
+	Values []sentryException `json:"values"`
+}
+
+type sentryException struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type sentryRequest struct {
+	Method      string            `json:"method,omitempty"`
+	URL         string            `json:"url,omitempty"`
+	QueryString string            `json:"query_string,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+}
+
+// capture queues an event without blocking the request, it's dropped if the queue is full.
+func (r *sentryReporter) capture(severity, kind, message string, req *sentryRequest, tags, extra map[string]string) {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+
+	event := sentryEvent{
+		EventID:     hex.EncodeToString(id),
+		Timestamp:   time.Now().UTC().Format(time.RFC3339),
+		Level:       severity,
+		Platform:    "go",
+		Logger:      "boring-registry",
+		ServerName:  r.serverName,
+		Release:     version.Version,
+		Environment: r.environment,
+		Exception: sentryExceptions{
+			Values: []sentryException{{Type: kind, Value: scrubSecrets(message)}},
+		},
+		Request: req,
+		Tags:    tags,
+		Extra:   extra,
+	}
+
+	select {
+	case r.events <- event:
+	default:
+		_ = level.Warn(logger).Log("msg", "dropped error report, too many errors are reported", "event", event.EventID)
+	}
+}
+
+// run sends the queued events one after another.
+func (r *sentryReporter) run() {
+	for event := range r.events {
+		if err := r.send(event); err != nil {
+			_ = level.Warn(logger).Log("msg", "failed to report error", "event", event.EventID, "err", err)
+		}
+	}
+}
+
+func (r *sentryReporter) send(event sentryEvent) error {
+	b, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, r.endpoint, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", r.auth)
+
+	res, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		return errors.Errorf("sentry responded with %s", res.Status)
+	}
+
+	return nil
+}
+
+// reportErrors reports the errors the encoder responds to with a server error, a nil reporter returns the encoder as is.
+func (r *sentryReporter) reportErrors(encoder httptransport.ErrorEncoder) httptransport.ErrorEncoder {
+	if r == nil {
+		return encoder
+	}
+
+	return func(ctx context.Context, err error, w http.ResponseWriter) {
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		encoder(ctx, err, rec)
+
+		if rec.status < http.StatusInternalServerError {
+			return
+		}
+
+		stringValue := func(key interface{}) string {
+			v, _ := ctx.Value(key).(string)
+			return v
+		}
+
+		// The cause is the registry error, e.g. "failed to upload module", which groups the reports
+		r.capture("error", errors.Cause(err).Error(), err.Error(), newSentryRequest(
+			stringValue(httptransport.ContextKeyRequestMethod),
+			stringValue(httptransport.ContextKeyRequestXForwardedProto),
+			stringValue(httptransport.ContextKeyRequestHost),
+			stringValue(httptransport.ContextKeyRequestURI),
+			stringValue(httptransport.ContextKeyRequestUserAgent),
+			stringValue(httptransport.ContextKeyRequestXRequestID),
+		), map[string]string{"status": strconv.Itoa(rec.status)}, nil)
+	}
+}
+
+// reportPanics reports panics of the handler before passing them on to the server, a nil reporter returns the handler as is.
+func (r *sentryReporter) reportPanics(next http.Handler) http.Handler {
+	if r == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+
+			if v != http.ErrAbortHandler {
+				r.capture("fatal", "panic", fmt.Sprint(v), newSentryRequest(
+					req.Method,
+					req.Header.Get("X-Forwarded-Proto"),
+					req.Host,
+					req.RequestURI,
+					req.UserAgent(),
+					req.Header.Get("X-Request-Id"),
+				), nil, map[string]string{"stack": string(debug.Stack())})
+			}
+
+			// The server logs the panic and closes the connection like without reporter
+			panic(v)
+		}()
+
+		next.ServeHTTP(w, req)
+	})
+}
+
+// newSentryRequest describes a request without secrets: only harmless headers are kept and the values of query parameters,
+// e.g. download signatures, are filtered.
+func newSentryRequest(method, proto, host, requestURI, userAgent, requestID string) *sentryRequest {
+	if proto == "" {
+		proto = "http"
+	}
+
+	req := &sentryRequest{
+		Method:  method,
+		Headers: make(map[string]string),
+	}
+
+	if u, err := url.ParseRequestURI(requestURI); err == nil {
+		req.URL = fmt.Sprintf("%s://%s%s", proto, host, u.Path)
+
+		query := u.Query()
+		for key := range query {
+			query.Set(key, "[Filtered]")
+		}
+		req.QueryString = query.Encode()
+	}
+
+	if userAgent != "" {
+		req.Headers["User-Agent"] = userAgent
+	}
+	if requestID != "" {
+		req.Headers["X-Request-Id"] = requestID
+	}
+
+	return req
+}
+
+var (
+	// secretQueryValue matches the values of query parameters of URLs in error messages, e.g. presigned URLs.
+	// A trailing colon separates the URL from the rest of the message.
+	secretQueryValue = regexp.MustCompile(`([?&][^=\s&"]+=)[^&\s"]*[^&\s":]`)
+	// secretCredentials matches credentials of authorization headers in error messages.
+	secretCredentials = regexp.MustCompile(`(?i)\b(bearer|basic)\s+[^\s"]+`)
+)
+
+// scrubSecrets filters query values and credentials in error messages.
+func scrubSecrets(s string) string {
+	s = secretQueryValue.ReplaceAllString(s, "${1}[Filtered]")
+	return secretCredentials.ReplaceAllString(s, "${1} [Filtered]")
+}
+
+// statusRecorder records the status code written to a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/TierMobility/boring-registry/pkg/module"
+)
+
+func TestNewSentryReporter(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name             string
+		dsn              string
+		expectedEndpoint string
+		expectErr        bool
+	}{
+		{
+			name:             "sentry",
+			dsn:              "https://0123abcd@o42.ingest.sentry.io/1337",
+			expectedEndpoint: "https://o42.ingest.sentry.io/api/1337/store/",
+		},
+		{
+			name:             "path prefix",
+			dsn:              "http://0123abcd@sentry.example.com:9000/sentry/7",
+			expectedEndpoint: "http://sentry.example.com:9000/sentry/api/7/store/",
+		},
+		{
+			name:      "missing key",
+			dsn:       "https://o42.ingest.sentry.io/1337",
+			expectErr: true,
+		},
+		{
+			name:      "missing project",
+			dsn:       "https://0123abcd@o42.ingest.sentry.io/",
+			expectErr: true,
+		},
+		{
+			name:      "unsupported scheme",
+			dsn:       "udp://0123abcd@o42.ingest.sentry.io/1337",
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+
+			reporter, err := newSentryReporter(tc.dsn, "")
+			if tc.expectErr {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(tc.expectedEndpoint, reporter.endpoint)
+		})
+	}
+}
+
+// sentryServer returns a reporter whose events are received from the channel.
+func sentryServer(t *testing.T) (*sentryReporter, <-chan sentryEvent) {
+	events := make(chan sentryEvent, 8)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/1337/store/", r.URL.Path)
+		assert.Contains(t, r.Header.Get("X-Sentry-Auth"), "sentry_key=0123abcd")
+
+		var event sentryEvent
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+		events <- event
+	}))
+	t.Cleanup(server.Close)
+
+	reporter, err := newSentryReporter("http://0123abcd@"+server.Listener.Addr().String()+"/1337", "test")
+	assert.NoError(t, err)
+	go reporter.run()
+
+	return reporter, events
+}
+
+func receiveEvent(t *testing.T, events <-chan sentryEvent) sentryEvent {
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event reported")
+		return sentryEvent{}
+	}
+}
+
+func TestSentryReporter_ReportErrors(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	reporter, events := sentryServer(t)
+	encoder := reporter.reportErrors(module.ErrorEncoder)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/modules/tier/vpc/aws/1.0.0/download?signature=abc&expires=123", nil)
+	req.Header.Set("Authorization", "Bearer secret-token")
+	req.Header.Set("User-Agent", "Terraform/1.5.0")
+	ctx := httptransport.PopulateRequestContext(context.Background(), req)
+
+	// Client errors aren't reported, so the first event is the one of the server error
+	encoder(ctx, module.ErrNotFound, httptest.NewRecorder())
+
+	rec := httptest.NewRecorder()
+	encoder(ctx, errors.Wrap(module.ErrAccessDenied, "GET https://bucket.s3.amazonaws.com/key?X-Amz-Signature=0123abcd"), rec)
+	assert.Equal(http.StatusBadGateway, rec.Code)
+
+	event := receiveEvent(t, events)
+	assert.Equal("error", event.Level)
+	assert.Equal("test", event.Environment)
+	assert.Equal("502", event.Tags["status"])
+	if assert.Len(event.Exception.Values, 1) {
+		assert.Equal(module.ErrAccessDenied.Error(), event.Exception.Values[0].Type)
+		assert.Equal("GET https://bucket.s3.amazonaws.com/key?X-Amz-Signature=[Filtered]: storage denied access", event.Exception.Values[0].Value)
+	}
+	if assert.NotNil(event.Request) {
+		assert.Equal("GET", event.Request.Method)
+		assert.Equal("http://example.com/v1/modules/tier/vpc/aws/1.0.0/download", event.Request.URL)
+		assert.Equal("expires=%5BFiltered%5D&signature=%5BFiltered%5D", event.Request.QueryString)
+		assert.Equal(map[string]string{"User-Agent": "Terraform/1.5.0"}, event.Request.Headers)
+	}
+}
+
+func TestSentryReporter_ReportPanics(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	reporter, events := sentryServer(t)
+	handler := reporter.reportPanics(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("nil map")
+	}))
+
+	assert.PanicsWithValue("nil map", func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/modules/tier", nil))
+	}, "the server still handles the panic")
+
+	event := receiveEvent(t, events)
+	assert.Equal("fatal", event.Level)
+	if assert.Len(event.Exception.Values, 1) {
+		assert.Equal("panic", event.Exception.Values[0].Type)
+		assert.Equal("nil map", event.Exception.Values[0].Value)
+	}
+	assert.Contains(event.Extra["stack"], "TestSentryReporter_ReportPanics")
+}
+
+func TestSentryReporter_Disabled(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	var reporter *sentryReporter
+	handler := http.NewServeMux()
+	assert.Same(handler, reporter.reportPanics(handler))
+
+	rec := httptest.NewRecorder()
+	reporter.reportErrors(module.ErrorEncoder)(context.Background(), module.ErrAccessDenied, rec)
+	assert.Equal(http.StatusBadGateway, rec.Code)
+}
+
+func TestScrubSecrets(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		in       string
+		expected string
+	}{
+		{
+			in:       "failed to upload module",
+			expected: "failed to upload module",
+		},
+		{
+			in:       `Get "https://storage.googleapis.com/bucket/key?X-Goog-Signature=abc&X-Goog-Expires=900": context deadline exceeded`,
+			expected: `Get "https://storage.googleapis.com/bucket/key?X-Goog-Signature=[Filtered]&X-Goog-Expires=[Filtered]": context deadline exceeded`,
+		},
+		{
+			in:       "introspection rejected Authorization: Bearer eyJhbGciOi",
+			expected: "introspection rejected Authorization: Bearer [Filtered]",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.in, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, scrubSecrets(tc.in))
+		})
+	}
+}
@@ -2,16 +2,19 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/TierMobility/boring-registry/pkg/storage"
 	"net/http"
 	"net/http/pprof"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strings"
 	"syscall"
 	"time"
 
+	"github.com/go-kit/kit/endpoint"
 	httptransport "github.com/go-kit/kit/transport/http"
 
 	"github.com/go-kit/kit/log"
@@ -37,6 +40,9 @@ var (
 	prefix          = fmt.Sprintf("/%s", apiVersion)
 	prefixModules   = fmt.Sprintf("%s/modules", prefix)
 	prefixProviders = fmt.Sprintf("%s/providers", prefix)
+	prefixFiles     = fmt.Sprintf("%s/files", prefix)
+	prefixMirror    = fmt.Sprintf("%s/mirror", prefix)
+	prefixArchives  = fmt.Sprintf("%s/archives", prefix)
 )
 
 var (
@@ -46,7 +52,26 @@ var (
 	flagTLSKeyFile          string
 	flagListenAddr          string
 	flagTelemetryListenAddr string
+	flagServerReadTimeout   time.Duration
+	flagServerWriteTimeout  time.Duration
 	flagModuleArchiveFormat string
+	flagPreviewAPIKey       string
+	flagPreviewTTL          time.Duration
+	flagAnnotationAPIKey    string
+	flagUploadAPIKey        string
+	flagApprovalNamespace   string
+	flagApproverAPIKey      string
+
+	// GitHub pull-through cache options.
+	flagGitHubCacheOrg    string
+	flagGitHubCacheAPIURL string
+	flagGitHubCacheToken  string
+
+	// Module version cache options.
+	flagModuleCacheSize int
+	flagModuleCacheTTL  time.Duration
+
+	flagModuleMaxArchiveSize int64
 )
 
 var serverCmd = &cobra.Command{
@@ -63,11 +88,17 @@ var serverCmd = &cobra.Command{
 			return errors.Wrap(err, "failed to setup server")
 		}
 
+		tlsConfig, err := clientTLSConfig()
+		if err != nil {
+			return err
+		}
+
 		server := &http.Server{
 			Addr:         flagListenAddr,
-			ReadTimeout:  5 * time.Second,
-			WriteTimeout: 5 * time.Second,
+			ReadTimeout:  flagServerReadTimeout,
+			WriteTimeout: flagServerWriteTimeout,
 			Handler:      mux,
+			TLSConfig:    tlsConfig,
 		}
 
 		telemetryServer := &http.Server{
@@ -77,6 +108,18 @@ var serverCmd = &cobra.Command{
 			Handler:      mux,
 		}
 
+		// The listeners are bound before the server is reported ready, connections are queued until it serves them
+		ln, err := listen(ctx, flagListenAddr)
+		if err != nil {
+			return err
+		}
+
+		telemetryLn, err := listen(ctx, flagTelemetryListenAddr)
+		if err != nil {
+			ln.Close()
+			return err
+		}
+
 		sigint := make(chan os.Signal, 1)
 		signal.Notify(sigint, os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
 
@@ -91,11 +134,22 @@ var serverCmd = &cobra.Command{
 			return nil
 		})
 
+		// Systemd watchdog.
+		group.Go(func() error {
+			sdWatchdog(ctx, sdWatchdogInterval())
+			return nil
+		})
+
 		// Server handler.
 		group.Go(func() error {
 			<-ctx.Done()
+			sdNotify(sdNotifyStopping)
 
-			if err := server.Shutdown(ctx); err != nil {
+			// In-flight requests are drained with a fresh context, as ctx is already canceled
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), flagShutdownTimeout)
+			defer cancel()
+
+			if err := server.Shutdown(shutdownCtx); err != nil {
 				if err != context.Canceled {
 					_ = level.Error(logger).Log(
 						"msg", "failed to terminate server",
@@ -104,7 +158,7 @@ var serverCmd = &cobra.Command{
 				}
 			}
 
-			if err := telemetryServer.Shutdown(ctx); err != nil {
+			if err := telemetryServer.Shutdown(shutdownCtx); err != nil {
 				if err != context.Canceled {
 					_ = level.Error(logger).Log(
 						"msg", "failed to terminate telemetry server",
@@ -123,13 +177,13 @@ var serverCmd = &cobra.Command{
 			defer level.Info(logger).Log("msg", "shutting down server")
 
 			if flagTLSCertFile != "" || flagTLSKeyFile != "" {
-				if err := server.ListenAndServeTLS(flagTLSCertFile, flagTLSKeyFile); err != nil {
+				if err := server.ServeTLS(ln, flagTLSCertFile, flagTLSKeyFile); err != nil {
 					if err != http.ErrServerClosed {
 						return err
 					}
 				}
 			} else {
-				if err := server.ListenAndServe(); err != nil {
+				if err := server.Serve(ln); err != nil {
 					if err != http.ErrServerClosed {
 						return err
 					}
@@ -144,7 +198,7 @@ var serverCmd = &cobra.Command{
 			_ = level.Info(logger).Log("msg", "starting telemetry server")
 			defer level.Info(logger).Log("msg", "shutting down telemetry server")
 
-			if err := telemetryServer.ListenAndServe(); err != nil {
+			if err := telemetryServer.Serve(telemetryLn); err != nil {
 				if err != http.ErrServerClosed {
 					return err
 				}
@@ -152,6 +206,8 @@ var serverCmd = &cobra.Command{
 			return nil
 		})
 
+		sdNotify(sdNotifyReady)
+
 		return group.Wait()
 	},
 }
@@ -159,11 +215,19 @@ var serverCmd = &cobra.Command{
 func setupStorage() (storage.Storage, error) {
 	switch {
 	case flagS3Bucket != "":
+		creds, err := s3Credentials()
+		if err != nil {
+			return nil, err
+		}
+
 		return storage.NewS3Storage(flagS3Bucket,
 			storage.WithS3StorageBucketPrefix(flagS3Prefix),
 			storage.WithS3StorageBucketRegion(flagS3Region),
 			storage.WithS3StorageBucketEndpoint(flagS3Endpoint),
 			storage.WithS3StoragePathStyle(flagS3PathStyle),
+			storage.WithS3HTTPClient(s3HTTPClient()),
+			storage.WithS3Credentials(creds),
+			storage.WithS3MaxRetries(flagS3MaxRetries),
 		)
 	case flagGCSBucket != "":
 		return storage.NewGCSStorage(flagGCSBucket,
@@ -172,8 +236,12 @@ func setupStorage() (storage.Storage, error) {
 			storage.WithGCSSignedUrlExpiry(flagGCSSignedURLExpiry),
 			storage.WithGCSUseSignedURL(flagGCSSignedURL),
 		)
+	case flagLocalDir != "":
+		return storage.NewLocalStorage(flagLocalDir,
+			storage.WithLocalStorageBaseURL(flagLocalBaseURL),
+		)
 	default:
-		return nil, errors.New("please specify a valid storage provider")
+		return nil, usageError{errors.New("please specify a valid storage provider")}
 	}
 }
 
@@ -184,8 +252,10 @@ func setupModuleStorage() (module.Storage, error) {
 		return setupS3ModuleStorage()
 	case flagGCSBucket != "":
 		return setupGCSModuleStorage()
+	case flagLocalDir != "":
+		return setupLocalModuleStorage()
 	default:
-		return nil, errors.New("please specify a valid storage provider")
+		return nil, usageError{errors.New("please specify a valid storage provider")}
 	}
 }
 
@@ -196,33 +266,217 @@ func init() {
 	serverCmd.Flags().StringVar(&flagTLSCertFile, "tls-cert-file", "", "TLS certificate to serve")
 	serverCmd.Flags().StringVar(&flagListenAddr, "listen-address", ":5601", "Address to listen on")
 	serverCmd.Flags().StringVar(&flagTelemetryListenAddr, "listen-telemetry-address", ":7801", "Telemetry address to listen on")
+	serverCmd.Flags().DurationVar(&flagServerReadTimeout, "server-read-timeout", 5*time.Second, "Maximum duration for reading a request including its body, e.g. an uploaded archive")
+	serverCmd.Flags().DurationVar(&flagServerWriteTimeout, "server-write-timeout", 5*time.Second, "Maximum duration for writing a response, e.g. an archive streamed by --module-proxy")
 	serverCmd.Flags().StringVar(&flagModuleArchiveFormat, "storage-module-archive-format", module.DefaultArchiveFormat, "Archive file format for modules")
+	serverCmd.Flags().StringVar(&flagPreviewAPIKey, "preview-api-key", "", "Comma-separated string of API keys allowed to see preview versions")
+	serverCmd.Flags().StringVar(&flagAnnotationAPIKey, "annotation-api-key", "", "Comma-separated string of API keys allowed to annotate module versions")
+	serverCmd.Flags().StringVar(&flagUploadAPIKey, "upload-api-key", "", "Comma-separated string of API keys allowed to upload module versions")
+	serverCmd.Flags().StringVar(&flagApprovalNamespace, "approval-namespace", "", "Comma-separated string of namespaces whose module versions are hidden until they are approved")
+	serverCmd.Flags().StringVar(&flagApproverAPIKey, "approver-api-key", "", "Comma-separated string of API keys allowed to approve module versions")
+	serverCmd.Flags().StringVar(&flagGitHubCacheOrg, "github-cache-org", "", "Comma-separated string of GitHub organizations whose module repositories are pulled through on first request")
+	serverCmd.Flags().StringVar(&flagGitHubCacheAPIURL, "github-cache-api-url", module.DefaultGitHubAPIURL, "GitHub API to pull modules through from, e.g. https://github.example.com/api/v3 for GitHub Enterprise")
+	serverCmd.Flags().StringVar(&flagGitHubCacheToken, "github-cache-token", "", "GitHub token to pull modules of private repositories through with")
+	serverCmd.Flags().DurationVar(&flagPreviewTTL, "preview-ttl", 7*24*time.Hour, "Duration after which preview versions are hidden from all clients, 0 to never hide them")
+	serverCmd.Flags().IntVar(&flagModuleCacheSize, "module-cache-size", 0, "Number of modules whose versions are cached in memory, 0 to disable the cache")
+	serverCmd.Flags().DurationVar(&flagModuleCacheTTL, "module-cache-ttl", 30*time.Second, "Duration the versions of a module are cached, the longest uploads by other processes stay unlisted")
+	serverCmd.Flags().Int64Var(&flagModuleMaxArchiveSize, "module-max-archive-size", module.DefaultMaxArchiveSize, "Maximum size in bytes of module archives uploaded through the API, archives are held in memory while they are uploaded")
 }
 
-func serveMux() (*http.ServeMux, error) {
+func serveMux() (http.Handler, error) {
 	mux := http.NewServeMux()
 
+	registerMetrics(mux)
+
+	if flagModuleMaxArchiveSize <= 0 {
+		return nil, usageError{errors.New("--module-max-archive-size must be positive")}
+	}
+
+	if err := setupErrorReporting(); err != nil {
+		return nil, err
+	}
+
+	// JWTs are verified locally, so they are tried before asking the introspection endpoint of Okta
+	if err := setupOIDC(); err != nil {
+		return nil, err
+	}
+
+	if err := setupOkta(); err != nil {
+		return nil, err
+	}
+
+	if err := setupAuth(); err != nil {
+		return nil, err
+	}
+
+	s, err := setupStorage()
+	if err != nil {
+		return nil, err
+	}
+
+	ms, err := setupModuleStorage()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to setup module storage")
+	}
+
+	ms, err = replicatingModuleStorage(ms)
+	if err != nil {
+		return nil, err
+	}
+
+	var opts registryOptions
+
+	opts.acl, err = parseModuleACL(flagModuleACL)
+	if err != nil {
+		return nil, err
+	}
+
+	opts.networks, err = parseDownloadNetworks(flagDownloadCIDR)
+	if err != nil {
+		return nil, err
+	}
+
+	opts.workloads, err = parseWorkloadPermissions(flagSPIFFEUpload, flagSPIFFEDownload)
+	if err != nil {
+		return nil, err
+	}
+
+	opts.reserved, err = parseReserved(flagReserved)
+	if err != nil {
+		return nil, err
+	}
+
+	opts.rewrites, err = parseRewrites(flagRewrites)
+	if err != nil {
+		return nil, err
+	}
+
+	opts.anomalies, err = anomalyMiddleware()
+	if err != nil {
+		return nil, err
+	}
+
+	opts.audit, err = auditMiddleware()
+	if err != nil {
+		return nil, err
+	}
+
+	opts.overload, err = setupOverload()
+	if err != nil {
+		return nil, err
+	}
+
+	opts.authorizer, err = setupAuthorizer()
+	if err != nil {
+		return nil, err
+	}
+
+	opts.journal, err = setupJournal()
+	if err != nil {
+		return nil, err
+	}
+
+	opts.retention, err = setupRetention()
+	if err != nil {
+		return nil, err
+	}
+
+	registerRegistry(mux, ms, s, splitKeys(flagAPIKey), opts)
+
+	if flagLocalDir != "" {
+		registerFiles(mux, flagLocalDir)
+	}
+
+	if flagMirrorDir != "" {
+		if err := registerMirror(mux, flagMirrorDir, flagMirrorUpstream, splitKeys(flagAPIKey)); err != nil {
+			return nil, errors.Wrap(err, "failed to setup provider mirror")
+		}
+	}
+
+	if flagUI {
+		registerUI(mux)
+	}
+
+	handler, err := virtualHostRouter(mux, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return corsHandler(errorReporter.reportPanics(handler), splitKeys(flagCORSAllowedOrigins)), nil
+}
+
+// registryOptions configure the module and provider APIs of the default and all virtual hosts.
+type registryOptions struct {
+	acl       module.ACL
+	networks  module.DownloadNetworks
+	workloads module.WorkloadPermissions
+	reserved  module.Reserved
+	rewrites  rewriteRules
+	anomalies module.Middleware
+	audit     module.Middleware
+	// overload is nil if uploads aren't queued under load.
+	overload *module.Overload
+	// authorizer is nil if requests aren't authorized externally.
+	authorizer auth.Authorizer
+	// journal is nil if uploads aren't journaled.
+	journal module.Journal
+	// retention is nil if the server doesn't delete module versions.
+	retention map[string]module.RetentionPolicy
+}
+
+// registerRegistry registers the discovery document as well as the module and provider APIs.
+func registerRegistry(mux *http.ServeMux, ms module.Storage, s storage.Storage, apiKeys []string, opts registryOptions) {
 	mux.HandleFunc("/.well-known/terraform.json", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Content-type", "application/json")
-		w.Write([]byte(fmt.Sprintf(`{"modules.v1": "%s/", "providers.v1": "%s/"}`, prefixModules, prefixProviders)))
+		_ = json.NewEncoder(w).Encode(discoveryDocument())
 	})
 
-	registerMetrics(mux)
+	registerModule(mux, ms, apiKeys, opts)
+	registerProvider(mux, s, apiKeys, opts)
+}
 
-	s, err := setupStorage()
+// registerFiles serves the module archives and provider files of the local storage.
+// Like presigned bucket URLs the files are served without API key, as Terraform doesn't send credentials when downloading them.
+func registerFiles(mux *http.ServeMux, dir string) {
+	mux.Handle(
+		fmt.Sprintf(`%s/modules/blobs/`, prefixFiles),
+		http.StripPrefix(
+			fmt.Sprintf(`%s/modules/blobs/`, prefixFiles),
+			http.FileServer(onlyFiles{http.Dir(filepath.Join(dir, "modules", "blobs"))}),
+		),
+	)
+	mux.Handle(
+		fmt.Sprintf(`%s/providers/`, prefixFiles),
+		http.StripPrefix(
+			fmt.Sprintf(`%s/providers/`, prefixFiles),
+			http.FileServer(onlyFiles{http.Dir(filepath.Join(dir, "providers"))}),
+		),
+	)
+}
+
+// onlyFiles is a http.FileSystem which doesn't list directories.
+type onlyFiles struct {
+	fs http.FileSystem
+}
+
+func (o onlyFiles) Open(name string) (http.File, error) {
+	f, err := o.fs.Open(name)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := registerModule(mux); err != nil {
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
 		return nil, err
 	}
 
-	if err := registerProvider(mux, s); err != nil {
-		return nil, err
+	if info.IsDir() {
+		f.Close()
+		return nil, os.ErrNotExist
 	}
 
-	return mux, nil
+	return f, nil
 }
 
 func registerMetrics(mux *http.ServeMux) {
@@ -239,14 +493,69 @@ func registerMetrics(mux *http.ServeMux) {
 	mux.Handle("/debug/pprof/threadcreate", pprof.Handler("threadcreate"))
 }
 
-func registerModule(mux *http.ServeMux) error {
-	storage, err := setupModuleStorage()
-	if err != nil {
-		return errors.Wrap(err, "failed to setup module storage")
+func registerModule(mux *http.ServeMux, storage module.Storage, apiKeys []string, options registryOptions) {
+	storage = chaosModuleStorage(storage)
+	if flagNormalizeAddresses {
+		storage = module.NewNormalizingStorage(storage)
+	}
+	if flagModuleCacheSize > 0 {
+		storage = module.NewCachingStorage(storage,
+			module.WithCacheSize(flagModuleCacheSize),
+			module.WithCacheTTL(flagModuleCacheTTL),
+			module.WithCacheReport(func(event module.CacheEvent) {
+				countCacheEvent(cacheStorage, entityModuleVersions, string(event))
+			}),
+		)
+	}
+	if orgs := splitKeys(flagGitHubCacheOrg); len(orgs) > 0 {
+		storage = module.NewGitHubCacheStorage(storage, orgs,
+			module.WithGitHubCacheAPIURL(flagGitHubCacheAPIURL),
+			module.WithGitHubCacheToken(flagGitHubCacheToken),
+		)
+	}
+
+	var proxy *module.ProxyStorage
+	if flagModuleProxy {
+		proxy = module.NewProxyStorage(storage, prefixArchives, moduleProxyKey(),
+			module.WithProxyURLExpiry(flagModuleProxyURLExpiry),
+			module.WithProxyArchiveFormat(flagModuleArchiveFormat),
+			module.WithProxyMaxConcurrency(flagModuleProxyMaxConcurrency),
+		)
+		storage = proxy
 	}
 
-	service := module.NewService(storage)
+	if options.journal != nil {
+		replayJournal(storage, options.journal)
+	}
+	if options.retention != nil {
+		go collectGarbage(storage, options.retention)
+	}
+
+	service := module.NewService(storage, module.WithJournal(options.journal))
 	{
+		service = module.ScheduleMiddleware()(service)
+		service = module.ApprovalMiddleware(splitKeys(flagApprovalNamespace), splitKeys(flagApproverAPIKey))(service)
+		if keys := splitKeys(flagPreviewAPIKey); len(keys) > 0 {
+			service = module.PreviewMiddleware(keys, flagPreviewTTL)(service)
+		}
+		service = module.AnnotatorMiddleware(splitKeys(flagAnnotationAPIKey))(service)
+		service = module.UploaderMiddleware(splitKeys(flagUploadAPIKey),
+			module.WithUploaderWorkloads(options.workloads),
+			module.WithUploaderReserved(options.reserved, splitKeys(flagReservedAdminAPIKey)),
+		)(service)
+		service = module.DeprecationMiddleware(reportDeprecatedDownload)(service)
+		if options.audit != nil {
+			service = options.audit(service)
+		}
+		if options.anomalies != nil {
+			service = options.anomalies(service)
+		}
+		if len(options.rewrites.modules) > 0 {
+			service = module.RewriteMiddleware(options.rewrites.modules)(service)
+		}
+		if options.overload != nil {
+			service = options.overload.Middleware()(service)
+		}
 		service = module.LoggingMiddleware(logger)(service)
 	}
 
@@ -254,30 +563,59 @@ func registerModule(mux *http.ServeMux) error {
 		httptransport.ServerErrorHandler(
 			transport.NewLogErrorHandler(logger),
 		),
-		httptransport.ServerErrorEncoder(module.ErrorEncoder),
+		httptransport.ServerErrorEncoder(errorReporter.reportErrors(module.ErrorEncoder)),
 		httptransport.ServerBefore(
 			httptransport.PopulateRequestContext,
+			auth.PopulateRequestContext,
 		),
+		module.WithMaxArchiveSize(flagModuleMaxArchiveSize),
 	}
 
-	mux.Handle(
-		fmt.Sprintf(`%s/`, prefixModules),
-		http.StripPrefix(
-			prefixModules,
-			module.MakeHandler(
-				service,
-				auth.Middleware(splitKeys(flagAPIKey)...),
-				opts...,
+	modules := http.StripPrefix(
+		prefixModules,
+		module.MakeHandler(
+			service,
+			endpoint.Chain(
+				authMiddleware(authRouteModules, apiKeys),
+				module.ACLMiddleware(options.acl),
+				module.DownloadNetworksMiddleware(options.networks, flagTrustForwardedFor, logger),
+				workloadPermissions(options.workloads),
+				moduleAuthorization(options.authorizer),
 			),
+			opts...,
 		),
 	)
-
-	return nil
+	// Uploads are admitted before their body is read
+	if options.overload != nil {
+		modules = options.overload.Handler(modules)
+	}
+	mux.Handle(fmt.Sprintf(`%s/`, prefixModules), modules)
+
+	// The listing of all modules is served without trailing slash as well, like by the public registry
+	mux.Handle(prefixModules, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r2 := r.Clone(r.Context())
+		r2.URL.Path = prefixModules + "/"
+		r2.URL.RawPath = ""
+		modules.ServeHTTP(w, r2)
+	}))
+
+	if proxy != nil {
+		mux.Handle(
+			fmt.Sprintf(`%s/`, prefixArchives),
+			http.StripPrefix(
+				prefixArchives,
+				module.MakeArchiveHandler(proxy, opts...),
+			),
+		)
+	}
 }
 
-func registerProvider(mux *http.ServeMux, s storage.Storage) error {
+func registerProvider(mux *http.ServeMux, s storage.Storage, apiKeys []string, options registryOptions) {
 	service := provider.NewService(s)
 	{
+		if len(options.rewrites.providers) > 0 {
+			service = provider.RewriteMiddleware(options.rewrites.providers)(service)
+		}
 		service = provider.LoggingMiddleware(logger)(service)
 	}
 
@@ -285,9 +623,10 @@ func registerProvider(mux *http.ServeMux, s storage.Storage) error {
 		httptransport.ServerErrorHandler(
 			transport.NewLogErrorHandler(logger),
 		),
-		httptransport.ServerErrorEncoder(module.ErrorEncoder),
+		httptransport.ServerErrorEncoder(errorReporter.reportErrors(module.ErrorEncoder)),
 		httptransport.ServerBefore(
 			httptransport.PopulateRequestContext,
+			auth.PopulateRequestContext,
 		),
 	}
 
@@ -297,13 +636,14 @@ func registerProvider(mux *http.ServeMux, s storage.Storage) error {
 			prefixProviders,
 			provider.MakeHandler(
 				service,
-				auth.Middleware(splitKeys(flagAPIKey)...),
+				endpoint.Chain(
+					authMiddleware(authRouteProviders, apiKeys),
+					providerAuthorization(options.authorizer),
+				),
 				opts...,
 			),
 		),
 	)
-
-	return nil
 }
 
 func splitKeys(in string) []string {
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	for _, name := range []string{
+		"modules/blobs/sha256/abc.tar.gz",
+		"modules/namespace=tier/name=s3/provider=aws/version=1.0.0/tier-s3-aws-1.0.0.tar.gz",
+		"providers/tier/dummy/terraform-provider-dummy_1.0.0_linux_amd64.zip",
+	} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		assert.NoError(t, os.WriteFile(p, []byte("data"), 0o644))
+	}
+
+	mux := http.NewServeMux()
+	registerFiles(mux, dir)
+
+	testCases := []struct {
+		name   string
+		path   string
+		status int
+	}{
+		{
+			name:   "module archive",
+			path:   "/v1/files/modules/blobs/sha256/abc.tar.gz",
+			status: http.StatusOK,
+		},
+		{
+			name:   "provider archive",
+			path:   "/v1/files/providers/tier/dummy/terraform-provider-dummy_1.0.0_linux_amd64.zip",
+			status: http.StatusOK,
+		},
+		{
+			name:   "directory listing",
+			path:   "/v1/files/modules/blobs/sha256/",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "module reference",
+			path:   "/v1/files/modules/namespace=tier/name=s3/provider=aws/version=1.0.0/tier-s3-aws-1.0.0.tar.gz",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "path traversal",
+			path:   "/v1/files/providers/../modules/blobs/sha256/abc.tar.gz",
+			status: http.StatusMovedPermanently,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+			assert.Equal(t, tc.status, rec.Code)
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagSmokeEndpoint  string
+	flagSmokeToken     string
+	flagSmokeNamespace string
+	flagSmokeName      string
+	flagSmokeProvider  string
+	flagSmokeTimeout   time.Duration
+)
+
+var smokeTestCmd = &cobra.Command{
+	Use:   "smoke-test",
+	Short: "Publish, resolve and download a test module end-to-end",
+	Long: `Publish, resolve and download a test module end-to-end.
+
+The test module is uploaded with a unique version to the configured storage backend,
+resolved through the Module Registry Protocol of the given endpoint like Terraform would,
+downloaded and verified. The module is deleted from the storage backend afterwards.
+Only HTTP download URLs can be verified, other source addresses are skipped.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		result := &smokeTestResult{
+			Steps: []smokeTestStep{},
+		}
+
+		err := runSmokeTest(result)
+
+		if flagOutput == outputJSON {
+			if err != nil {
+				result.Error = err.Error()
+			}
+			if err := printJSON(os.Stdout, result); err != nil {
+				return err
+			}
+		}
+
+		return err
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(smokeTestCmd)
+	smokeTestCmd.Flags().StringVar(&flagSmokeEndpoint, "endpoint", "", "Base URL of the registry to test, e.g. https://registry.example.com")
+	smokeTestCmd.Flags().StringVar(&flagSmokeToken, "token", "", "API key to authenticate against the registry")
+	smokeTestCmd.Flags().StringVar(&flagSmokeNamespace, "namespace", "boring-registry-smoke-test", "Scratch namespace to publish the test module to")
+	smokeTestCmd.Flags().StringVar(&flagSmokeName, "name", "smoke-test", "Name of the test module")
+	smokeTestCmd.Flags().StringVar(&flagSmokeProvider, "provider", "null", "Provider of the test module")
+	smokeTestCmd.Flags().DurationVar(&flagSmokeTimeout, "timeout", time.Minute, "Timeout for the whole smoke test")
+}
+
+// smokeTestResult is the machine-readable result of the smoke-test command.
+type smokeTestResult struct {
+	Version string          `json:"version"`
+	Steps   []smokeTestStep `json:"steps"`
+	Error   string          `json:"error,omitempty"`
+}
+
+type smokeTestStep struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Took   string `json:"took"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Status of a smoke test step.
+const (
+	smokeTestStatusPassed  = "passed"
+	smokeTestStatusFailed  = "failed"
+	smokeTestStatusSkipped = "skipped"
+)
+
+// errSmokeTestSkipped marks steps which could not be executed.
+var errSmokeTestSkipped = errors.New("skipped")
+
+func (r *smokeTestResult) run(name string, fn func() error) error {
+	begin := time.Now()
+	err := fn()
+
+	step := smokeTestStep{
+		Name:   name,
+		Status: smokeTestStatusPassed,
+		Took:   time.Since(begin).String(),
+	}
+
+	switch {
+	case errors.Is(err, errSmokeTestSkipped):
+		step.Status = smokeTestStatusSkipped
+		step.Error = err.Error()
+		err = nil
+	case err != nil:
+		step.Status = smokeTestStatusFailed
+		step.Error = err.Error()
+	}
+
+	r.Steps = append(r.Steps, step)
+
+	l := level.Info(logger)
+	if err != nil {
+		l = level.Error(logger)
+	}
+	_ = l.Log(
+		"msg", "smoke test step finished",
+		"step", name,
+		"status", step.Status,
+		"took", step.Took,
+		"err", step.Error,
+	)
+
+	return err
+}
+
+func runSmokeTest(result *smokeTestResult) error {
+	client, err := newRegistryClient(flagSmokeEndpoint, flagSmokeToken)
+	if err != nil {
+		return err
+	}
+
+	storage, err := setupModuleStorage()
+	if err != nil {
+		return errors.Wrap(err, "failed to setup storage")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), flagSmokeTimeout)
+	defer cancel()
+
+	var (
+		namespace = flagSmokeNamespace
+		name      = flagSmokeName
+		provider  = flagSmokeProvider
+		version   = fmt.Sprintf("0.0.0-smoke.%d", time.Now().Unix())
+	)
+	result.Version = version
+
+	archive, err := smokeTestArchive()
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256(archive)
+
+	if err := result.run("publish", func() error {
+		_, err := storage.UploadModule(ctx, namespace, name, provider, version, bytes.NewReader(archive))
+		return err
+	}); err != nil {
+		return err
+	}
+
+	defer result.run("cleanup", func() error {
+		// The test context might already be expired at this point
+		return storage.DeleteModule(context.Background(), namespace, name, provider, version)
+	})
+
+	var base *url.URL
+	if err := result.run("discovery", func() error {
+		base, err = client.discoverModules(ctx)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	if err := result.run("versions", func() error {
+		versions, err := client.listModuleVersions(ctx, base, namespace, name, provider)
+		if err != nil {
+			return err
+		}
+		for _, v := range versions {
+			if v == version {
+				return nil
+			}
+		}
+		return fmt.Errorf("version %s is not listed", version)
+	}); err != nil {
+		return err
+	}
+
+	var source string
+	if err := result.run("download", func() error {
+		source, err = client.moduleDownloadURL(ctx, base, namespace, name, provider, version)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	return result.run("verify", func() error {
+		body, err := client.download(ctx, base, source)
+		if err != nil {
+			return errors.Wrap(errSmokeTestSkipped, err.Error())
+		}
+		defer body.Close()
+
+		h := sha256.New()
+		if _, err := io.Copy(h, body); err != nil {
+			return err
+		}
+
+		if actual := hex.EncodeToString(h.Sum(nil)); actual != hex.EncodeToString(digest[:]) {
+			return fmt.Errorf("checksum mismatch: expected %x, got %s", digest, actual)
+		}
+
+		return nil
+	})
+}
+
+// smokeTestArchive packages a minimal module.
+func smokeTestArchive() ([]byte, error) {
+	dir, err := ioutil.TempDir("", "boring-registry-smoke-test")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	content := "# Published by boring-registry smoke-test\noutput \"ok\" {\n  value = true\n}\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "main.tf"), []byte(content), 0644); err != nil {
+		return nil, err
+	}
+
+	buf, err := archiveModule(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
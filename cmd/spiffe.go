@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/pkg/errors"
+
+	"github.com/TierMobility/boring-registry/pkg/module"
+)
+
+var (
+	flagSPIFFETrustDomain string
+	flagSPIFFEUpload      []string
+	flagSPIFFEDownload    []string
+)
+
+func init() {
+	serverCmd.Flags().StringVar(&flagSPIFFETrustDomain, "spiffe-trust-domain", "", "SPIFFE trust domain whose X.509 SVIDs are accepted as workload identity, enables the spiffe authenticator")
+	serverCmd.Flags().StringArrayVar(&flagSPIFFEUpload, "spiffe-upload", nil, "Allow comma-separated SPIFFE IDs to upload and download the modules of a namespace, e.g. tier=spiffe://example.org/ci/* (can be repeated)")
+	serverCmd.Flags().StringArrayVar(&flagSPIFFEDownload, "spiffe-download", nil, "Allow comma-separated SPIFFE IDs to download the modules of a namespace, e.g. tier=spiffe://example.org/deploy (can be repeated)")
+}
+
+// parseWorkloadPermissions parses the NAMESPACE=SPIFFE_IDS pairs of the --spiffe-upload and --spiffe-download flags.
+func parseWorkloadPermissions(upload, download []string) (module.WorkloadPermissions, error) {
+	permissions := make(module.WorkloadPermissions)
+
+	if flagSPIFFETrustDomain == "" {
+		if len(upload) > 0 || len(download) > 0 {
+			return nil, usageError{errors.New("--spiffe-upload and --spiffe-download require --spiffe-trust-domain")}
+		}
+		return permissions, nil
+	}
+
+	parse := func(flag string, entries []string, add func(grant *module.WorkloadGrant, ids []string)) error {
+		for _, raw := range entries {
+			parts := strings.SplitN(raw, "=", 2)
+			if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+				return usageError{fmt.Errorf("invalid --%s %q, expected NAMESPACE=SPIFFE_IDS", flag, raw)}
+			}
+
+			ids := splitKeys(parts[1])
+			for _, id := range ids {
+				if !strings.HasPrefix(id, "spiffe://"+flagSPIFFETrustDomain+"/") {
+					return usageError{fmt.Errorf("invalid SPIFFE ID %q of --%s, expected an ID of the trust domain %s", id, flag, flagSPIFFETrustDomain)}
+				}
+			}
+
+			grant := permissions[parts[0]]
+			add(&grant, ids)
+			permissions[parts[0]] = grant
+		}
+		return nil
+	}
+
+	if err := parse("spiffe-upload", upload, func(grant *module.WorkloadGrant, ids []string) {
+		grant.Upload = append(grant.Upload, ids...)
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := parse("spiffe-download", download, func(grant *module.WorkloadGrant, ids []string) {
+		grant.Download = append(grant.Download, ids...)
+	}); err != nil {
+		return nil, err
+	}
+
+	return permissions, nil
+}
+
+// workloadPermissions returns the middleware enforcing the permissions of workloads,
+// which passes all requests without --spiffe-trust-domain, so SVIDs accepted by the mtls authenticator aren't restricted.
+func workloadPermissions(permissions module.WorkloadPermissions) endpoint.Middleware {
+	if flagSPIFFETrustDomain == "" {
+		return func(next endpoint.Endpoint) endpoint.Endpoint { return next }
+	}
+	return module.WorkloadPermissionsMiddleware(permissions)
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/TierMobility/boring-registry/pkg/module"
+)
+
+func TestParseWorkloadPermissions(t *testing.T) {
+	defer func(trustDomain string) {
+		flagSPIFFETrustDomain = trustDomain
+	}(flagSPIFFETrustDomain)
+
+	testCases := []struct {
+		name        string
+		trustDomain string
+		upload      []string
+		download    []string
+		expected    module.WorkloadPermissions
+		expectErr   bool
+	}{
+		{
+			name:     "disabled",
+			expected: module.WorkloadPermissions{},
+		},
+		{
+			name:        "merges entries of the same namespace",
+			trustDomain: "example.org",
+			upload:      []string{"tier=spiffe://example.org/ci/*"},
+			download:    []string{"tier=spiffe://example.org/deploy,spiffe://example.org/plan", "payments=spiffe://example.org/deploy"},
+			expected: module.WorkloadPermissions{
+				"tier":     {Upload: []string{"spiffe://example.org/ci/*"}, Download: []string{"spiffe://example.org/deploy", "spiffe://example.org/plan"}},
+				"payments": {Download: []string{"spiffe://example.org/deploy"}},
+			},
+		},
+		{
+			name:      "without trust domain",
+			upload:    []string{"tier=spiffe://example.org/ci/*"},
+			expectErr: true,
+		},
+		{
+			name:        "id of other trust domain",
+			trustDomain: "example.org",
+			download:    []string{"tier=spiffe://example.com/deploy"},
+			expectErr:   true,
+		},
+		{
+			name:        "missing ids",
+			trustDomain: "example.org",
+			download:    []string{"tier="},
+			expectErr:   true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			flagSPIFFETrustDomain = tc.trustDomain
+
+			permissions, err := parseWorkloadPermissions(tc.upload, tc.download)
+			if tc.expectErr {
+				assert.Equal(t, exitCodeUsage, exitCode(err))
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, permissions)
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/TierMobility/boring-registry/pkg/module"
+)
+
+var (
+	flagStaleMaxAge        time.Duration
+	flagStaleInactiveAfter time.Duration
+	flagStaleWebhookURL    string
+)
+
+var staleCmd = &cobra.Command{
+	Use:   "stale [flags] NAMESPACE...",
+	Short: "Report modules and namespaces which aren't maintained anymore",
+	Long: `Report modules and namespaces which aren't maintained anymore.
+
+Modules are stale if their latest version was published longer than --max-age ago,
+namespaces are inactive if nothing has been published to them for --inactive-after.
+The command is meant to be run on a schedule, e.g. by a cron job: the report is posted as JSON to --webhook-url,
+and namespace owners are notified by email about their stale modules with the --notify-* flags.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return usageError{errors.New("expected at least one namespace")}
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if flagStaleMaxAge <= 0 || flagStaleInactiveAfter <= 0 {
+			return usageError{errors.New("--max-age and --inactive-after must be positive")}
+		}
+
+		notifier, err := newNotifier(defaultStaleNotificationTemplate)
+		if err != nil {
+			return err
+		}
+
+		storage, err := setupModuleStorage()
+		if err != nil {
+			return errors.Wrap(err, "failed to setup storage")
+		}
+
+		now := time.Now().UTC()
+		result := &staleResult{Now: now, Namespaces: []*module.StaleReport{}}
+		for _, namespace := range args {
+			report, err := module.StaleModules(context.Background(), storage, namespace, now.Add(-flagStaleMaxAge), now.Add(-flagStaleInactiveAfter))
+			if err != nil {
+				return err
+			}
+			result.Namespaces = append(result.Namespaces, report)
+		}
+
+		if flagStaleWebhookURL != "" {
+			if err := postJSON(flagStaleWebhookURL, result); err != nil {
+				return errors.Wrap(err, "failed to post stale report")
+			}
+		}
+		notifier.notifyStale(result)
+
+		if flagOutput == outputJSON {
+			return printJSON(os.Stdout, result)
+		}
+
+		return result.print(os.Stdout)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(staleCmd)
+	staleCmd.Flags().DurationVar(&flagStaleMaxAge, "max-age", 180*24*time.Hour, "Age of the latest version after which a module is stale")
+	staleCmd.Flags().DurationVar(&flagStaleInactiveAfter, "inactive-after", 365*24*time.Hour, "Time without publishing after which a namespace is inactive")
+	staleCmd.Flags().StringVar(&flagStaleWebhookURL, "webhook-url", "", "URL to post the report to as JSON")
+	addNotifyFlags(staleCmd.Flags())
+}
+
+// staleResult is the machine-readable result of the stale command.
+type staleResult struct {
+	Now        time.Time             `json:"now"`
+	Namespaces []*module.StaleReport `json:"namespaces"`
+}
+
+func (r *staleResult) print(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "MODULE\tVERSION\tPUBLISHED\n")
+	for _, report := range r.Namespaces {
+		if report.Inactive {
+			fmt.Fprintf(tw, "%s/*\t-\t%s\n", report.Namespace, report.LastPublishedAt.Format(time.RFC3339))
+		}
+		for _, m := range report.Modules {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", m.Module, m.Version, m.PublishedAt.Format(time.RFC3339))
+		}
+	}
+	return tw.Flush()
+}
+
+// notifyStale sends a stale notification to the owners of every namespace with stale modules or without recent publishing.
+// Failed notifications are logged and don't fail the report.
+func (n *notifier) notifyStale(result *staleResult) {
+	if n == nil {
+		return
+	}
+
+	for _, report := range result.Namespaces {
+		if report.Empty() {
+			continue
+		}
+
+		err := n.notify(notification{
+			Event:     notificationEventStale,
+			Namespace: report.Namespace,
+			Stale:     report,
+		})
+		if err != nil {
+			_ = level.Error(logger).Log(
+				"msg", "failed to send notification",
+				"namespace", report.Namespace,
+				"err", err,
+			)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"net/smtp"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/TierMobility/boring-registry/pkg/module"
+)
+
+func TestNotifyStale(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	var sent []string
+
+	now := time.Date(2024, 5, 13, 9, 0, 0, 0, time.UTC)
+	n := &notifier{
+		addr:   "smtp.example.com:25",
+		from:   "registry@example.com",
+		owners: map[string][]string{"tier": {"a@example.com"}, "payments": {"b@example.com"}},
+		tmpl:   template.Must(template.New("notification").Parse(defaultStaleNotificationTemplate)),
+		send: func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+			sent = append(sent, string(msg))
+			return nil
+		},
+		now: func() time.Time { return now },
+	}
+
+	n.notifyStale(&staleResult{
+		Now: now,
+		Namespaces: []*module.StaleReport{
+			{
+				Namespace:       "tier",
+				LastPublishedAt: time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC),
+				Inactive:        true,
+				Modules: []module.StaleModule{
+					{Module: "tier/s3/aws", Version: "0.2.0", PublishedAt: time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)},
+					{Module: "tier/vpc/aws", Version: "1.1.0", PublishedAt: time.Date(2022, 10, 24, 0, 0, 0, 0, time.UTC)},
+				},
+			},
+			{Namespace: "payments", LastPublishedAt: now, Modules: []module.StaleModule{}},
+		},
+	})
+
+	assert.Len(sent, 1)
+	assert.Equal(strings.Join([]string{
+		"From: registry@example.com",
+		"To: a@example.com",
+		"Date: Mon, 13 May 2024 09:00:00 +0000",
+		"X-Boring-Registry-Event: stale",
+		"Subject: [boring-registry] Stale modules in tier",
+		"",
+		"Nothing has been published to the namespace tier since 2023-03-01.",
+		"The latest versions of these modules of the namespace tier are outdated:",
+		"",
+		"  tier/s3/aws 0.2.0, published 2023-03-01",
+		"  tier/vpc/aws 1.1.0, published 2022-10-24",
+		"",
+	}, "\r\n"), sent[0])
+}
@@ -0,0 +1,567 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// Bucket settings managed by storage init, they are identified by these IDs so existing settings are kept.
+const (
+	storageInitPolicySid     = "BoringRegistryDenyInsecureTransport"
+	storageInitLifecycleRule = "boring-registry-abort-incomplete-multipart-uploads"
+	// storageInitProbeKey is the object written to verify the permissions of the registry.
+	storageInitProbeKey = ".boring-registry/permission-check"
+)
+
+// Encryptions of storage init.
+const (
+	storageEncryptionS3   = s3.ServerSideEncryptionAes256
+	storageEncryptionKMS  = s3.ServerSideEncryptionAwsKms
+	storageEncryptionNone = "none"
+)
+
+var (
+	flagStorageInitCreateBucket       bool
+	flagStorageInitBlockPublicAccess  bool
+	flagStorageInitTLSOnly            bool
+	flagStorageInitEncryption         string
+	flagStorageInitKMSKeyID           string
+	flagStorageInitAbortMultipartDays int
+	flagStorageInitTimeout            time.Duration
+)
+
+var storageCmd = &cobra.Command{
+	Use:   "storage",
+	Short: "Manage the storage backend",
+}
+
+var storageInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Create and configure the S3 bucket of the registry",
+	Long: `Create and configure the S3 bucket of the registry.
+
+The bucket of --storage-s3-bucket is created if it doesn't exist, and the recommended
+settings are applied: public access is blocked, objects are encrypted by default,
+requests without TLS are denied by the bucket policy, and incomplete multipart uploads
+below --storage-s3-prefix are aborted. Existing bucket policies, lifecycle rules and
+stronger encryption are kept. Finally an object is written, read, listed and deleted
+below the prefix to verify the permissions the registry needs.
+
+Running it again only applies missing settings.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if flagS3Bucket == "" {
+			return usageError{errors.New("storage init requires --storage-s3-bucket")}
+		}
+
+		switch flagStorageInitEncryption {
+		case storageEncryptionS3, storageEncryptionNone:
+			if flagStorageInitKMSKeyID != "" {
+				return usageError{fmt.Errorf("--kms-key-id requires --encryption=%s", storageEncryptionKMS)}
+			}
+		case storageEncryptionKMS:
+		default:
+			return usageError{fmt.Errorf("invalid --encryption %q, expected %s, %s or %s", flagStorageInitEncryption, storageEncryptionS3, storageEncryptionKMS, storageEncryptionNone)}
+		}
+
+		client, err := newS3Client()
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), flagStorageInitTimeout)
+		defer cancel()
+
+		si := &storageInit{
+			client:             client,
+			bucket:             flagS3Bucket,
+			prefix:             flagS3Prefix,
+			region:             flagS3Region,
+			createBucket:       flagStorageInitCreateBucket,
+			blockPublicAccess:  flagStorageInitBlockPublicAccess,
+			tlsOnly:            flagStorageInitTLSOnly,
+			encryption:         flagStorageInitEncryption,
+			kmsKeyID:           flagStorageInitKMSKeyID,
+			abortMultipartDays: flagStorageInitAbortMultipartDays,
+		}
+		result, err := si.run(ctx)
+
+		if flagOutput == outputJSON {
+			if err != nil {
+				result.Error = err.Error()
+			}
+			if err := printJSON(os.Stdout, result); err != nil {
+				return err
+			}
+		} else if err := result.print(os.Stdout); err != nil {
+			return err
+		}
+
+		return err
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(storageCmd)
+	storageCmd.AddCommand(storageInitCmd)
+	storageInitCmd.Flags().BoolVar(&flagStorageInitCreateBucket, "create-bucket", true, "Create the bucket if it doesn't exist")
+	storageInitCmd.Flags().BoolVar(&flagStorageInitBlockPublicAccess, "block-public-access", true, "Block all public access to the bucket")
+	storageInitCmd.Flags().BoolVar(&flagStorageInitTLSOnly, "tls-only", true, "Deny requests without TLS with a statement of the bucket policy")
+	storageInitCmd.Flags().StringVar(&flagStorageInitEncryption, "encryption", storageEncryptionS3, "Default encryption of the bucket, AES256, aws:kms or none")
+	storageInitCmd.Flags().StringVar(&flagStorageInitKMSKeyID, "kms-key-id", "", "KMS key of --encryption=aws:kms (default the AWS managed key)")
+	storageInitCmd.Flags().IntVar(&flagStorageInitAbortMultipartDays, "abort-multipart-days", 7, "Days after which incomplete multipart uploads are aborted, 0 to skip the lifecycle rule")
+	storageInitCmd.Flags().DurationVar(&flagStorageInitTimeout, "timeout", 2*time.Minute, "Timeout for the whole initialization")
+}
+
+// newS3Client returns an S3 client configured by the S3 flags like the storage of the registry.
+func newS3Client() (s3iface.S3API, error) {
+	sess, cfg, err := newS3Session()
+	if err != nil {
+		return nil, err
+	}
+
+	return s3.New(sess, cfg), nil
+}
+
+// newS3Session returns a session and the config of the S3 flags, including the credentials.
+func newS3Session() (*session.Session, *aws.Config, error) {
+	sess, err := session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cfg := aws.NewConfig()
+	if flagS3Region != "" {
+		cfg = cfg.WithRegion(flagS3Region)
+	}
+	if flagS3Endpoint != "" {
+		cfg = cfg.WithEndpoint(flagS3Endpoint).WithS3ForcePathStyle(flagS3PathStyle)
+	}
+	if client := s3HTTPClient(); client != nil {
+		cfg = cfg.WithHTTPClient(client)
+	}
+	if flagS3MaxRetries >= 0 {
+		cfg = cfg.WithMaxRetries(flagS3MaxRetries)
+	}
+
+	creds, err := s3Credentials()
+	if err != nil {
+		return nil, nil, err
+	}
+	if creds != nil {
+		cfg = cfg.WithCredentials(creds)
+	}
+
+	return sess, cfg, nil
+}
+
+// Status of a storage init step.
+const (
+	storageInitStatusCreated   = "created"
+	storageInitStatusApplied   = "applied"
+	storageInitStatusUnchanged = "unchanged"
+	storageInitStatusPassed    = "passed"
+	storageInitStatusSkipped   = "skipped"
+	storageInitStatusFailed    = "failed"
+)
+
+// storageInitResult is the machine-readable result of the storage init command.
+type storageInitResult struct {
+	Bucket string            `json:"bucket"`
+	Prefix string            `json:"prefix"`
+	Steps  []storageInitStep `json:"steps"`
+	Error  string            `json:"error,omitempty"`
+}
+
+type storageInitStep struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+func (r *storageInitResult) print(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "STEP\tSTATUS\tDETAIL\n")
+	for _, step := range r.Steps {
+		detail := step.Detail
+		if step.Error != "" {
+			detail = step.Error
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", step.Name, step.Status, detail)
+	}
+	return tw.Flush()
+}
+
+// storageInit creates and configures a bucket, every step is idempotent.
+type storageInit struct {
+	client             s3iface.S3API
+	bucket             string
+	prefix             string
+	region             string
+	createBucket       bool
+	blockPublicAccess  bool
+	tlsOnly            bool
+	encryption         string
+	kmsKeyID           string
+	abortMultipartDays int
+}
+
+// run runs all steps, so all missing permissions are reported at once.
+// Only a missing bucket stops the initialization early.
+func (i *storageInit) run(ctx context.Context) (*storageInitResult, error) {
+	result := &storageInitResult{
+		Bucket: i.bucket,
+		Prefix: i.prefix,
+		Steps:  []storageInitStep{},
+	}
+
+	step := func(name string, enabled bool, fn func() (string, string, error)) {
+		if !enabled {
+			result.Steps = append(result.Steps, storageInitStep{Name: name, Status: storageInitStatusSkipped})
+			return
+		}
+
+		status, detail, err := fn()
+		s := storageInitStep{Name: name, Status: status, Detail: detail}
+		if err != nil {
+			s.Status = storageInitStatusFailed
+			s.Error = err.Error()
+		}
+		result.Steps = append(result.Steps, s)
+	}
+
+	step("bucket", true, func() (string, string, error) { return i.ensureBucket(ctx) })
+	if result.Steps[0].Status == storageInitStatusFailed {
+		return result, errors.New(result.Steps[0].Error)
+	}
+
+	step("public-access-block", i.blockPublicAccess, func() (string, string, error) { return i.applyPublicAccessBlock(ctx) })
+	step("encryption", i.encryption != storageEncryptionNone, func() (string, string, error) { return i.applyEncryption(ctx) })
+	step("tls-only-policy", i.tlsOnly, func() (string, string, error) { return i.applyTLSOnlyPolicy(ctx) })
+	step("lifecycle", i.abortMultipartDays > 0, func() (string, string, error) { return i.applyLifecycle(ctx) })
+	step("permissions", true, func() (string, string, error) { return i.verifyPermissions(ctx) })
+
+	var failed []string
+	for _, s := range result.Steps {
+		if s.Status == storageInitStatusFailed {
+			failed = append(failed, s.Name)
+		}
+	}
+	if len(failed) > 0 {
+		return result, fmt.Errorf("failed to initialize bucket %s: %s", i.bucket, strings.Join(failed, ", "))
+	}
+
+	return result, nil
+}
+
+func (i *storageInit) ensureBucket(ctx context.Context) (string, string, error) {
+	_, err := i.client.HeadBucketWithContext(ctx, &s3.HeadBucketInput{Bucket: aws.String(i.bucket)})
+	if err == nil {
+		return storageInitStatusUnchanged, "", nil
+	}
+	if !isAWSErrorCode(err, "NotFound", s3.ErrCodeNoSuchBucket) {
+		return "", "", err
+	}
+	if !i.createBucket {
+		return "", "", fmt.Errorf("bucket %s doesn't exist", i.bucket)
+	}
+
+	input := &s3.CreateBucketInput{
+		Bucket: aws.String(i.bucket),
+	}
+
+	// us-east-1 is the default location and must not be passed as constraint
+	if i.region != "" && i.region != "us-east-1" {
+		input.CreateBucketConfiguration = &s3.CreateBucketConfiguration{
+			LocationConstraint: aws.String(i.region),
+		}
+	}
+
+	if _, err := i.client.CreateBucketWithContext(ctx, input); err != nil {
+		return "", "", err
+	}
+
+	return storageInitStatusCreated, "", nil
+}
+
+// applyPublicAccessBlock blocks all public access to the bucket, unless it is already blocked.
+func (i *storageInit) applyPublicAccessBlock(ctx context.Context) (string, string, error) {
+	res, err := i.client.GetPublicAccessBlockWithContext(ctx, &s3.GetPublicAccessBlockInput{Bucket: aws.String(i.bucket)})
+	switch {
+	case err == nil:
+		if c := res.PublicAccessBlockConfiguration; c != nil &&
+			aws.BoolValue(c.BlockPublicAcls) && aws.BoolValue(c.BlockPublicPolicy) &&
+			aws.BoolValue(c.IgnorePublicAcls) && aws.BoolValue(c.RestrictPublicBuckets) {
+			return storageInitStatusUnchanged, "", nil
+		}
+	case !isAWSErrorCode(err, "NoSuchPublicAccessBlockConfiguration"):
+		return "", "", err
+	}
+
+	_, err = i.client.PutPublicAccessBlockWithContext(ctx, &s3.PutPublicAccessBlockInput{
+		Bucket: aws.String(i.bucket),
+		PublicAccessBlockConfiguration: &s3.PublicAccessBlockConfiguration{
+			BlockPublicAcls:       aws.Bool(true),
+			BlockPublicPolicy:     aws.Bool(true),
+			IgnorePublicAcls:      aws.Bool(true),
+			RestrictPublicBuckets: aws.Bool(true),
+		},
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	return storageInitStatusApplied, "", nil
+}
+
+// applyEncryption sets the default encryption of the bucket, unless it is already encrypted as requested or with KMS.
+func (i *storageInit) applyEncryption(ctx context.Context) (string, string, error) {
+	res, err := i.client.GetBucketEncryptionWithContext(ctx, &s3.GetBucketEncryptionInput{Bucket: aws.String(i.bucket)})
+	if err != nil && !isAWSErrorCode(err, "ServerSideEncryptionConfigurationNotFoundError") {
+		return "", "", err
+	}
+
+	if err == nil && res.ServerSideEncryptionConfiguration != nil {
+		for _, rule := range res.ServerSideEncryptionConfiguration.Rules {
+			if rule.ApplyServerSideEncryptionByDefault == nil {
+				continue
+			}
+			algorithm := aws.StringValue(rule.ApplyServerSideEncryptionByDefault.SSEAlgorithm)
+			// Encryption is never weakened, e.g. from KMS to S3 managed keys
+			if algorithm == i.encryption || algorithm == storageEncryptionKMS {
+				return storageInitStatusUnchanged, algorithm, nil
+			}
+		}
+	}
+
+	encryption := &s3.ServerSideEncryptionByDefault{
+		SSEAlgorithm: aws.String(i.encryption),
+	}
+	if i.kmsKeyID != "" {
+		encryption.KMSMasterKeyID = aws.String(i.kmsKeyID)
+	}
+
+	_, err = i.client.PutBucketEncryptionWithContext(ctx, &s3.PutBucketEncryptionInput{
+		Bucket: aws.String(i.bucket),
+		ServerSideEncryptionConfiguration: &s3.ServerSideEncryptionConfiguration{
+			Rules: []*s3.ServerSideEncryptionRule{
+				{
+					ApplyServerSideEncryptionByDefault: encryption,
+				},
+			},
+		},
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	return storageInitStatusApplied, i.encryption, nil
+}
+
+// applyTLSOnlyPolicy adds a statement denying requests without TLS to the bucket policy, other statements are kept.
+func (i *storageInit) applyTLSOnlyPolicy(ctx context.Context) (string, string, error) {
+	var current string
+	res, err := i.client.GetBucketPolicyWithContext(ctx, &s3.GetBucketPolicyInput{Bucket: aws.String(i.bucket)})
+	switch {
+	case err == nil:
+		current = aws.StringValue(res.Policy)
+	case !isAWSErrorCode(err, "NoSuchBucketPolicy"):
+		return "", "", err
+	}
+
+	policy, changed, err := withTLSOnlyStatement(current, i.bucket, i.region)
+	if err != nil {
+		return "", "", err
+	}
+	if !changed {
+		return storageInitStatusUnchanged, "", nil
+	}
+
+	if _, err := i.client.PutBucketPolicyWithContext(ctx, &s3.PutBucketPolicyInput{
+		Bucket: aws.String(i.bucket),
+		Policy: aws.String(policy),
+	}); err != nil {
+		return "", "", err
+	}
+
+	return storageInitStatusApplied, "", nil
+}
+
+// withTLSOnlyStatement returns the bucket policy with the statement denying requests without TLS,
+// and whether the statement had to be added.
+func withTLSOnlyStatement(policy, bucket, region string) (string, bool, error) {
+	doc := map[string]interface{}{
+		"Version": "2012-10-17",
+	}
+	if policy != "" {
+		if err := json.Unmarshal([]byte(policy), &doc); err != nil {
+			return "", false, errors.Wrap(err, "invalid bucket policy")
+		}
+	}
+
+	// A policy with a single statement may have it as object instead of array
+	var statements []interface{}
+	switch s := doc["Statement"].(type) {
+	case []interface{}:
+		statements = s
+	case map[string]interface{}:
+		statements = []interface{}{s}
+	}
+
+	for _, s := range statements {
+		if statement, ok := s.(map[string]interface{}); ok && statement["Sid"] == storageInitPolicySid {
+			return policy, false, nil
+		}
+	}
+
+	arn := fmt.Sprintf("arn:%s:s3:::%s", s3Partition(region), bucket)
+	doc["Statement"] = append(statements, map[string]interface{}{
+		"Sid":       storageInitPolicySid,
+		"Effect":    "Deny",
+		"Principal": "*",
+		"Action":    "s3:*",
+		"Resource":  []string{arn, arn + "/*"},
+		"Condition": map[string]interface{}{
+			"Bool": map[string]string{"aws:SecureTransport": "false"},
+		},
+	})
+
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return "", false, err
+	}
+
+	return string(b), true, nil
+}
+
+// s3Partition returns the AWS partition of a region, which is part of the ARNs of its buckets.
+func s3Partition(region string) string {
+	switch {
+	case strings.HasPrefix(region, "cn-"):
+		return "aws-cn"
+	case strings.HasPrefix(region, "us-gov-"):
+		return "aws-us-gov"
+	default:
+		return "aws"
+	}
+}
+
+// applyLifecycle adds a rule aborting incomplete multipart uploads below the prefix, other rules are kept.
+func (i *storageInit) applyLifecycle(ctx context.Context) (string, string, error) {
+	var rules []*s3.LifecycleRule
+	res, err := i.client.GetBucketLifecycleConfigurationWithContext(ctx, &s3.GetBucketLifecycleConfigurationInput{Bucket: aws.String(i.bucket)})
+	switch {
+	case err == nil:
+		rules = res.Rules
+	case !isAWSErrorCode(err, "NoSuchLifecycleConfiguration"):
+		return "", "", err
+	}
+
+	for _, rule := range rules {
+		if aws.StringValue(rule.ID) == storageInitLifecycleRule {
+			return storageInitStatusUnchanged, "", nil
+		}
+	}
+
+	rules = append(rules, &s3.LifecycleRule{
+		ID:     aws.String(storageInitLifecycleRule),
+		Status: aws.String(s3.ExpirationStatusEnabled),
+		Filter: &s3.LifecycleRuleFilter{Prefix: aws.String(i.prefix)},
+		AbortIncompleteMultipartUpload: &s3.AbortIncompleteMultipartUpload{
+			DaysAfterInitiation: aws.Int64(int64(i.abortMultipartDays)),
+		},
+	})
+
+	if _, err := i.client.PutBucketLifecycleConfigurationWithContext(ctx, &s3.PutBucketLifecycleConfigurationInput{
+		Bucket:                 aws.String(i.bucket),
+		LifecycleConfiguration: &s3.BucketLifecycleConfiguration{Rules: rules},
+	}); err != nil {
+		return "", "", err
+	}
+
+	return storageInitStatusApplied, fmt.Sprintf("abort incomplete multipart uploads after %d days", i.abortMultipartDays), nil
+}
+
+// verifyPermissions writes, reads, lists and deletes an object below the prefix like the registry does.
+func (i *storageInit) verifyPermissions(ctx context.Context) (string, string, error) {
+	key := path.Join(i.prefix, storageInitProbeKey)
+	content := []byte("boring-registry")
+
+	if _, err := i.client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(i.bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(content),
+	}); err != nil {
+		return "", "", errors.Wrap(err, "s3:PutObject")
+	}
+
+	res, err := i.client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(i.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return "", "", errors.Wrap(err, "s3:GetObject")
+	}
+	b, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return "", "", errors.Wrap(err, "s3:GetObject")
+	}
+	if !bytes.Equal(b, content) {
+		return "", "", errors.New("s3:GetObject returned unexpected content")
+	}
+
+	list, err := i.client.ListObjectsV2WithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket: aws.String(i.bucket),
+		Prefix: aws.String(key),
+	})
+	if err != nil {
+		return "", "", errors.Wrap(err, "s3:ListBucket")
+	}
+	if len(list.Contents) == 0 {
+		return "", "", errors.New("s3:ListBucket doesn't list the written object")
+	}
+
+	if _, err := i.client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(i.bucket),
+		Key:    aws.String(key),
+	}); err != nil {
+		return "", "", errors.Wrap(err, "s3:DeleteObject")
+	}
+
+	return storageInitStatusPassed, "s3:PutObject, s3:GetObject, s3:ListBucket, s3:DeleteObject", nil
+}
+
+func isAWSErrorCode(err error, codes ...string) bool {
+	var awsErr awserr.Error
+	if !errors.As(err, &awsErr) {
+		return false
+	}
+
+	for _, code := range codes {
+		if awsErr.Code() == code {
+			return true
+		}
+	}
+
+	return false
+}
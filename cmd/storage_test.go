@@ -0,0 +1,315 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeBucket is an in-memory bucket implementing the calls of storage init.
+type fakeBucket struct {
+	s3iface.S3API
+
+	exists            bool
+	publicAccessBlock *s3.PublicAccessBlockConfiguration
+	encryption        *s3.ServerSideEncryptionConfiguration
+	policy            string
+	lifecycle         []*s3.LifecycleRule
+	objects           map[string][]byte
+	denyDelete        bool
+}
+
+func (f *fakeBucket) HeadBucketWithContext(aws.Context, *s3.HeadBucketInput, ...request.Option) (*s3.HeadBucketOutput, error) {
+	if !f.exists {
+		return nil, awserr.New("NotFound", "not found", nil)
+	}
+	return &s3.HeadBucketOutput{}, nil
+}
+
+func (f *fakeBucket) CreateBucketWithContext(aws.Context, *s3.CreateBucketInput, ...request.Option) (*s3.CreateBucketOutput, error) {
+	f.exists = true
+	return &s3.CreateBucketOutput{}, nil
+}
+
+func (f *fakeBucket) GetPublicAccessBlockWithContext(aws.Context, *s3.GetPublicAccessBlockInput, ...request.Option) (*s3.GetPublicAccessBlockOutput, error) {
+	if f.publicAccessBlock == nil {
+		return nil, awserr.New("NoSuchPublicAccessBlockConfiguration", "not found", nil)
+	}
+	return &s3.GetPublicAccessBlockOutput{PublicAccessBlockConfiguration: f.publicAccessBlock}, nil
+}
+
+func (f *fakeBucket) PutPublicAccessBlockWithContext(_ aws.Context, input *s3.PutPublicAccessBlockInput, _ ...request.Option) (*s3.PutPublicAccessBlockOutput, error) {
+	f.publicAccessBlock = input.PublicAccessBlockConfiguration
+	return &s3.PutPublicAccessBlockOutput{}, nil
+}
+
+func (f *fakeBucket) GetBucketEncryptionWithContext(aws.Context, *s3.GetBucketEncryptionInput, ...request.Option) (*s3.GetBucketEncryptionOutput, error) {
+	if f.encryption == nil {
+		return nil, awserr.New("ServerSideEncryptionConfigurationNotFoundError", "not found", nil)
+	}
+	return &s3.GetBucketEncryptionOutput{ServerSideEncryptionConfiguration: f.encryption}, nil
+}
+
+func (f *fakeBucket) PutBucketEncryptionWithContext(_ aws.Context, input *s3.PutBucketEncryptionInput, _ ...request.Option) (*s3.PutBucketEncryptionOutput, error) {
+	f.encryption = input.ServerSideEncryptionConfiguration
+	return &s3.PutBucketEncryptionOutput{}, nil
+}
+
+func (f *fakeBucket) GetBucketPolicyWithContext(aws.Context, *s3.GetBucketPolicyInput, ...request.Option) (*s3.GetBucketPolicyOutput, error) {
+	if f.policy == "" {
+		return nil, awserr.New("NoSuchBucketPolicy", "not found", nil)
+	}
+	return &s3.GetBucketPolicyOutput{Policy: aws.String(f.policy)}, nil
+}
+
+func (f *fakeBucket) PutBucketPolicyWithContext(_ aws.Context, input *s3.PutBucketPolicyInput, _ ...request.Option) (*s3.PutBucketPolicyOutput, error) {
+	f.policy = aws.StringValue(input.Policy)
+	return &s3.PutBucketPolicyOutput{}, nil
+}
+
+func (f *fakeBucket) GetBucketLifecycleConfigurationWithContext(aws.Context, *s3.GetBucketLifecycleConfigurationInput, ...request.Option) (*s3.GetBucketLifecycleConfigurationOutput, error) {
+	if len(f.lifecycle) == 0 {
+		return nil, awserr.New("NoSuchLifecycleConfiguration", "not found", nil)
+	}
+	return &s3.GetBucketLifecycleConfigurationOutput{Rules: f.lifecycle}, nil
+}
+
+func (f *fakeBucket) PutBucketLifecycleConfigurationWithContext(_ aws.Context, input *s3.PutBucketLifecycleConfigurationInput, _ ...request.Option) (*s3.PutBucketLifecycleConfigurationOutput, error) {
+	f.lifecycle = input.LifecycleConfiguration.Rules
+	return &s3.PutBucketLifecycleConfigurationOutput{}, nil
+}
+
+func (f *fakeBucket) PutObjectWithContext(_ aws.Context, input *s3.PutObjectInput, _ ...request.Option) (*s3.PutObjectOutput, error) {
+	b, err := ioutil.ReadAll(input.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.objects[aws.StringValue(input.Key)] = b
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (f *fakeBucket) GetObjectWithContext(_ aws.Context, input *s3.GetObjectInput, _ ...request.Option) (*s3.GetObjectOutput, error) {
+	b, ok := f.objects[aws.StringValue(input.Key)]
+	if !ok {
+		return nil, awserr.New(s3.ErrCodeNoSuchKey, "not found", nil)
+	}
+	return &s3.GetObjectOutput{Body: ioutil.NopCloser(bytes.NewReader(b))}, nil
+}
+
+func (f *fakeBucket) ListObjectsV2WithContext(_ aws.Context, input *s3.ListObjectsV2Input, _ ...request.Option) (*s3.ListObjectsV2Output, error) {
+	out := &s3.ListObjectsV2Output{}
+	if _, ok := f.objects[aws.StringValue(input.Prefix)]; ok {
+		out.Contents = []*s3.Object{{Key: input.Prefix}}
+	}
+	return out, nil
+}
+
+func (f *fakeBucket) DeleteObjectWithContext(_ aws.Context, input *s3.DeleteObjectInput, _ ...request.Option) (*s3.DeleteObjectOutput, error) {
+	if f.denyDelete {
+		return nil, awserr.New("AccessDenied", "access denied", nil)
+	}
+	delete(f.objects, aws.StringValue(input.Key))
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+func stepStatuses(result *storageInitResult) map[string]string {
+	statuses := make(map[string]string)
+	for _, step := range result.Steps {
+		statuses[step.Name] = step.Status
+	}
+	return statuses
+}
+
+func TestStorageInit(t *testing.T) {
+	t.Parallel()
+
+	bucket := &fakeBucket{objects: make(map[string][]byte)}
+	si := &storageInit{
+		client:             bucket,
+		bucket:             "registry",
+		prefix:             "modules",
+		region:             "eu-central-1",
+		createBucket:       true,
+		blockPublicAccess:  true,
+		tlsOnly:            true,
+		encryption:         storageEncryptionS3,
+		abortMultipartDays: 7,
+	}
+
+	result, err := si.run(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"bucket":              storageInitStatusCreated,
+		"public-access-block": storageInitStatusApplied,
+		"encryption":          storageInitStatusApplied,
+		"tls-only-policy":     storageInitStatusApplied,
+		"lifecycle":           storageInitStatusApplied,
+		"permissions":         storageInitStatusPassed,
+	}, stepStatuses(result))
+	assert.Empty(t, bucket.objects)
+	assert.Equal(t, "modules", aws.StringValue(bucket.lifecycle[0].Filter.Prefix))
+
+	// A second run finds everything in place
+	result, err = si.run(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"bucket":              storageInitStatusUnchanged,
+		"public-access-block": storageInitStatusUnchanged,
+		"encryption":          storageInitStatusUnchanged,
+		"tls-only-policy":     storageInitStatusUnchanged,
+		"lifecycle":           storageInitStatusUnchanged,
+		"permissions":         storageInitStatusPassed,
+	}, stepStatuses(result))
+}
+
+func TestStorageInitFailures(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name             string
+		bucket           *fakeBucket
+		createBucket     bool
+		encryption       string
+		expectedStatuses map[string]string
+	}{
+		{
+			name:         "missing bucket",
+			bucket:       &fakeBucket{objects: make(map[string][]byte)},
+			createBucket: false,
+			encryption:   storageEncryptionS3,
+			expectedStatuses: map[string]string{
+				"bucket": storageInitStatusFailed,
+			},
+		},
+		{
+			name:       "missing permission",
+			bucket:     &fakeBucket{exists: true, objects: make(map[string][]byte), denyDelete: true},
+			encryption: storageEncryptionNone,
+			expectedStatuses: map[string]string{
+				"bucket":              storageInitStatusUnchanged,
+				"public-access-block": storageInitStatusSkipped,
+				"encryption":          storageInitStatusSkipped,
+				"tls-only-policy":     storageInitStatusSkipped,
+				"lifecycle":           storageInitStatusSkipped,
+				"permissions":         storageInitStatusFailed,
+			},
+		},
+		{
+			name: "kms is kept",
+			bucket: &fakeBucket{exists: true, objects: make(map[string][]byte), encryption: &s3.ServerSideEncryptionConfiguration{
+				Rules: []*s3.ServerSideEncryptionRule{{
+					ApplyServerSideEncryptionByDefault: &s3.ServerSideEncryptionByDefault{SSEAlgorithm: aws.String(storageEncryptionKMS)},
+				}},
+			}},
+			encryption: storageEncryptionS3,
+			expectedStatuses: map[string]string{
+				"bucket":              storageInitStatusUnchanged,
+				"public-access-block": storageInitStatusSkipped,
+				"encryption":          storageInitStatusUnchanged,
+				"tls-only-policy":     storageInitStatusSkipped,
+				"lifecycle":           storageInitStatusSkipped,
+				"permissions":         storageInitStatusPassed,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			si := &storageInit{
+				client:       tc.bucket,
+				bucket:       "registry",
+				createBucket: tc.createBucket,
+				encryption:   tc.encryption,
+			}
+
+			result, err := si.run(context.Background())
+			assert.Equal(t, tc.expectedStatuses, stepStatuses(result))
+			assert.Equal(t, tc.expectedStatuses["permissions"] != storageInitStatusPassed, err != nil)
+		})
+	}
+}
+
+func TestWithTLSOnlyStatement(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name               string
+		policy             string
+		region             string
+		expectedChanged    bool
+		expectedStatements int
+		expectedResource   string
+		expectedError      bool
+	}{
+		{
+			name:               "empty policy",
+			region:             "eu-central-1",
+			expectedChanged:    true,
+			expectedStatements: 1,
+			expectedResource:   "arn:aws:s3:::registry",
+		},
+		{
+			name:               "single statement object",
+			policy:             `{"Version":"2012-10-17","Statement":{"Sid":"ReadOnly","Effect":"Allow","Principal":{"AWS":"arn:aws:iam::123456789012:root"},"Action":"s3:GetObject","Resource":"arn:aws:s3:::registry/*"}}`,
+			region:             "cn-north-1",
+			expectedChanged:    true,
+			expectedStatements: 2,
+			expectedResource:   "arn:aws-cn:s3:::registry",
+		},
+		{
+			name:            "already present",
+			policy:          `{"Version":"2012-10-17","Statement":[{"Sid":"BoringRegistryDenyInsecureTransport","Effect":"Deny"}]}`,
+			expectedChanged: false,
+		},
+		{
+			name:          "invalid policy",
+			policy:        `{`,
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			policy, changed, err := withTLSOnlyStatement(tc.policy, "registry", tc.region)
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedChanged, changed)
+			if !changed {
+				assert.Equal(t, tc.policy, policy)
+				return
+			}
+
+			var doc struct {
+				Statement []struct {
+					Sid      string
+					Resource interface{}
+				}
+			}
+			assert.NoError(t, json.Unmarshal([]byte(policy), &doc))
+			assert.Len(t, doc.Statement, tc.expectedStatements)
+
+			added := doc.Statement[len(doc.Statement)-1]
+			assert.Equal(t, storageInitPolicySid, added.Sid)
+			assert.Equal(t, []interface{}{tc.expectedResource, tc.expectedResource + "/*"}, added.Resource)
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+)
+
+// States sent to systemd, see sd_notify(3).
+const (
+	sdNotifyReady    = "READY=1"
+	sdNotifyStopping = "STOPPING=1"
+	sdNotifyWatchdog = "WATCHDOG=1"
+)
+
+// sdNotify tells systemd about a state change of a service of Type=notify.
+// It does nothing if the server isn't started by systemd.
+func sdNotify(state string) {
+	if err := notifySocket(os.Getenv("NOTIFY_SOCKET"), state); err != nil {
+		_ = level.Warn(logger).Log(
+			"msg", "failed to notify systemd",
+			"state", state,
+			"err", err,
+		)
+	}
+}
+
+func notifySocket(socket, state string) error {
+	if socket == "" {
+		return nil
+	}
+
+	// Abstract sockets are passed with a leading @
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// sdWatchdogInterval returns the interval to send keep-alive pings to the systemd watchdog in, zero if it is disabled.
+func sdWatchdogInterval() time.Duration {
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+
+	// Pinging at half the timeout tolerates a delayed ping
+	return time.Duration(usec) * time.Microsecond / 2
+}
+
+// sdWatchdog pings the systemd watchdog until the context is canceled.
+func sdWatchdog(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			sdNotify(sdNotifyWatchdog)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
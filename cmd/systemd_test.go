@@ -0,0 +1,38 @@
+//go:build linux
+
+package cmd
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotifySocket(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "boring-registry-systemd")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	assert.NoError(err)
+	defer conn.Close()
+
+	assert.NoError(notifySocket(socket, sdNotifyReady))
+
+	buf := make([]byte, 64)
+	n, err := conn.Read(buf)
+	assert.NoError(err)
+	assert.Equal(sdNotifyReady, string(buf[:n]))
+
+	// Without a socket the server isn't managed by systemd
+	assert.NoError(notifySocket("", sdNotifyReady))
+	assert.Error(notifySocket(filepath.Join(dir, "missing.sock"), sdNotifyReady))
+}
@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/TierMobility/boring-registry/pkg/module"
+	"github.com/TierMobility/boring-registry/pkg/storage"
+)
+
+// uploadTarget is a storage backend modules are published to.
+type uploadTarget struct {
+	name    string
+	storage module.Storage
+}
+
+// setupUploadTargets returns the configured storage backend followed by all additional --target backends.
+func setupUploadTargets() ([]uploadTarget, error) {
+	var targets []uploadTarget
+
+	if flagS3Bucket != "" || flagGCSBucket != "" || flagLocalDir != "" || len(flagTargets) == 0 {
+		storage, err := setupModuleStorage()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to setup storage")
+		}
+
+		name := fmt.Sprintf("s3://%s", flagS3Bucket)
+		switch {
+		case flagGCSBucket != "":
+			name = fmt.Sprintf("gs://%s", flagGCSBucket)
+		case flagLocalDir != "":
+			name = flagLocalDir
+		}
+
+		targets = append(targets, uploadTarget{name: name, storage: storage})
+	}
+
+	for _, raw := range flagTargets {
+		storage, err := parseTarget(raw)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, uploadTarget{name: raw, storage: storage})
+	}
+
+	return targets, nil
+}
+
+// parseTarget creates a module storage from a URL like
+// s3://bucket/prefix?region=eu-central-1&endpoint=https://minio.example.com&pathstyle=true or gs://bucket/prefix?signedurl=true.
+func parseTarget(raw string) (module.Storage, error) {
+	u, err := parseStorageURL(raw)
+	if err != nil {
+		return nil, err
+	}
+	return u.moduleStorage()
+}
+
+// storageURL is a storage backend given as URL, see parseTarget for the supported format.
+type storageURL struct {
+	scheme string
+	bucket string
+	prefix string
+
+	region       string
+	endpoint     string
+	pathStyle    bool
+	storageClass string
+
+	signedURL       bool
+	signedURLExpiry time.Duration
+	serviceAccount  string
+}
+
+func parseStorageURL(raw string) (*storageURL, error) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return nil, usageError{fmt.Errorf("invalid storage URL: %s", raw)}
+	}
+
+	var (
+		query = u.Query()
+		s     = &storageURL{
+			scheme:          u.Scheme,
+			bucket:          u.Host,
+			prefix:          strings.TrimPrefix(u.Path, "/"),
+			region:          query.Get("region"),
+			endpoint:        query.Get("endpoint"),
+			storageClass:    query.Get("storage-class"),
+			serviceAccount:  query.Get("service-account"),
+			signedURLExpiry: 30 * time.Second,
+		}
+	)
+
+	switch u.Scheme {
+	case "s3":
+		if s.pathStyle, err = parseTargetBool(query.Get("pathstyle")); err != nil {
+			return nil, usageError{fmt.Errorf("invalid storage URL %s: %v", raw, err)}
+		}
+	case "gs", "gcs":
+		if s.signedURL, err = parseTargetBool(query.Get("signedurl")); err != nil {
+			return nil, usageError{fmt.Errorf("invalid storage URL %s: %v", raw, err)}
+		}
+		if v := query.Get("signedurl-expiry"); v != "" {
+			if s.signedURLExpiry, err = time.ParseDuration(v); err != nil {
+				return nil, usageError{fmt.Errorf("invalid storage URL %s: %v", raw, err)}
+			}
+		}
+	default:
+		return nil, usageError{fmt.Errorf("unsupported storage scheme %q, expected s3 or gs", u.Scheme)}
+	}
+
+	return s, nil
+}
+
+// moduleStorage returns the module storage below the "modules" directory of the prefix.
+func (s *storageURL) moduleStorage() (module.Storage, error) {
+	prefix := path.Join(s.prefix, "modules")
+
+	if s.scheme == "s3" {
+		creds, err := s3Credentials()
+		if err != nil {
+			return nil, err
+		}
+
+		return module.NewS3Storage(s.bucket,
+			module.WithS3StorageBucketPrefix(prefix),
+			module.WithS3ArchiveFormat(flagModuleArchiveFormat),
+			module.WithS3StorageBucketRegion(s.region),
+			module.WithS3StorageBucketEndpoint(s.endpoint),
+			module.WithS3StoragePathStyle(s.pathStyle),
+			module.WithS3StorageClass(s.storageClass),
+			module.WithS3HTTPClient(s3HTTPClient()),
+			module.WithS3Credentials(creds),
+			module.WithS3MaxRetries(flagS3MaxRetries),
+			module.WithS3UploadPartSize(flagS3UploadPartSize),
+			module.WithS3UploadConcurrency(flagS3UploadConcurrency),
+			module.WithS3Logger(logger),
+		)
+	}
+
+	return module.NewGCSStorage(s.bucket,
+		module.WithGCSStorageBucketPrefix(prefix),
+		module.WithGCSArchiveFormat(flagModuleArchiveFormat),
+		module.WithGCSStorageSignedURL(s.signedURL),
+		module.WithGCSServiceAccount(s.serviceAccount),
+		module.WithGCSSignedUrlExpiry(int64(s.signedURLExpiry.Seconds())),
+	)
+}
+
+// providerStorage returns the provider storage, which manages its own directories below the prefix.
+func (s *storageURL) providerStorage() (storage.Storage, error) {
+	if s.scheme == "s3" {
+		creds, err := s3Credentials()
+		if err != nil {
+			return nil, err
+		}
+
+		return storage.NewS3Storage(s.bucket,
+			storage.WithS3StorageBucketPrefix(s.prefix),
+			storage.WithS3StorageBucketRegion(s.region),
+			storage.WithS3StorageBucketEndpoint(s.endpoint),
+			storage.WithS3StoragePathStyle(s.pathStyle),
+			storage.WithS3HTTPClient(s3HTTPClient()),
+			storage.WithS3Credentials(creds),
+			storage.WithS3MaxRetries(flagS3MaxRetries),
+		)
+	}
+
+	return storage.NewGCSStorage(s.bucket,
+		storage.WithGCSStorageBucketPrefix(s.prefix),
+		storage.WithGCSServiceAccount(s.serviceAccount),
+		storage.WithGCSSignedUrlExpiry(s.signedURLExpiry),
+		storage.WithGCSUseSignedURL(s.signedURL),
+	)
+}
+
+func parseTargetBool(v string) (bool, error) {
+	if v == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(v)
+}
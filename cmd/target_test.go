@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/TierMobility/boring-registry/pkg/module"
+)
+
+func TestParseTarget_Invalid(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name   string
+		target string
+	}{
+		{name: "missing bucket", target: "s3:///prefix"},
+		{name: "unsupported scheme", target: "azure://bucket"},
+		{name: "invalid pathstyle", target: "s3://bucket?pathstyle=maybe"},
+		{name: "invalid expiry", target: "gs://bucket?signedurl-expiry=forever"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := parseTarget(tc.target)
+			assert.Equal(t, exitCodeUsage, exitCode(err))
+		})
+	}
+}
+
+func TestPublishTargets(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "boring-registry-target")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	spec := "metadata {\n  namespace = \"tier\"\n  name = \"test\"\n  provider = \"dummy\"\n  version = \"1.0.0\"\n}\n"
+	assert.NoError(ioutil.WriteFile(filepath.Join(dir, moduleSpecFileName), []byte(spec), 0644))
+	assert.NoError(ioutil.WriteFile(filepath.Join(dir, "main.tf"), []byte("# test\n"), 0644))
+
+	var (
+		ctx       = context.Background()
+		primary   = module.NewInmemStorage()
+		secondary = module.NewInmemStorage()
+		targets   = []uploadTarget{
+			{name: "primary", storage: primary},
+			{name: "secondary", storage: secondary},
+		}
+	)
+
+	// The module already exists in the secondary target, which must not affect the primary one
+	_, err = secondary.UploadModule(ctx, "tier", "test", "dummy", "1.0.0", strings.NewReader("other content"))
+	assert.NoError(err)
+
+	result := &uploadResult{}
+	assert.NoError(publishTargets(dir, targets, result))
+
+	assert.Len(result.Modules, 2)
+	assert.Equal("primary", result.Modules[0].Target)
+	assert.Equal(moduleStatusUploaded, result.Modules[0].Status)
+	assert.Equal("secondary", result.Modules[1].Target)
+	assert.Equal(moduleStatusExists, result.Modules[1].Status)
+
+	_, err = primary.GetModule(ctx, "tier", "test", "dummy", "1.0.0")
+	assert.NoError(err)
+}
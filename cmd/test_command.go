@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"sync"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+)
+
+const (
+	// maxTestOutput limits the test output kept per module to its tail.
+	maxTestOutput = 4096
+)
+
+var errModuleTestFailed = errors.New("module tests failed")
+
+// moduleTestError is returned if the test command of a module fails.
+type moduleTestError struct {
+	err    error
+	output string
+}
+
+func (e *moduleTestError) Error() string {
+	return fmt.Sprintf("%s: %v", errModuleTestFailed, e.err)
+}
+
+func (e *moduleTestError) Is(target error) bool {
+	return target == errModuleTestFailed
+}
+
+func (e *moduleTestError) Unwrap() error {
+	return e.err
+}
+
+// moduleTestRuns memoizes the test results per module directory,
+// so publishing to multiple targets runs the tests of every module only once.
+var moduleTestRuns sync.Map
+
+type moduleTestRun struct {
+	once sync.Once
+	err  error
+}
+
+// testModule runs the configured test command in the module directory.
+func testModule(ctx context.Context, dir string) error {
+	v, _ := moduleTestRuns.LoadOrStore(dir, &moduleTestRun{})
+	run := v.(*moduleTestRun)
+	run.once.Do(func() {
+		run.err = runTestCommand(ctx, dir)
+	})
+	return run.err
+}
+
+func runTestCommand(ctx context.Context, dir string) error {
+	if flagTestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, flagTestTimeout)
+		defer cancel()
+	}
+
+	shell, flag := "sh", "-c"
+	if runtime.GOOS == "windows" {
+		shell, flag = "cmd", "/C"
+	}
+
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, shell, flag, flagTestCommand)
+	cmd.Dir = dir
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	level.Debug(logger).Log("msg", "running module tests", "dir", dir, "command", flagTestCommand)
+
+	if err := cmd.Run(); err != nil {
+		out := output.Bytes()
+		if len(out) > maxTestOutput {
+			out = out[len(out)-maxTestOutput:]
+		}
+		return &moduleTestError{err: err, output: string(out)}
+	}
+
+	return nil
+}
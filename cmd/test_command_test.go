@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/TierMobility/boring-registry/pkg/module"
+)
+
+func TestArchiveModules_TestCommand(t *testing.T) {
+	assert := assert.New(t)
+
+	root, err := ioutil.TempDir("", "boring-registry-test-command")
+	assert.NoError(err)
+	defer os.RemoveAll(root)
+
+	for _, name := range []string{"passing", "failing"} {
+		dir := filepath.Join(root, name)
+		assert.NoError(os.MkdirAll(dir, 0755))
+
+		spec := "metadata {\n  namespace = \"tier\"\n  name = \"" + name + "\"\n  provider = \"dummy\"\n  version = \"1.0.0\"\n}\n"
+		assert.NoError(ioutil.WriteFile(filepath.Join(dir, moduleSpecFileName), []byte(spec), 0644))
+	}
+	assert.NoError(ioutil.WriteFile(filepath.Join(root, "failing", "FAIL"), nil, 0644))
+
+	defer func(recursive bool) {
+		flagRecursive, flagTestCommand = recursive, ""
+	}(flagRecursive)
+	flagRecursive = true
+	flagTestCommand = "if [ -f FAIL ]; then echo broken; exit 1; fi"
+
+	result := &uploadResult{}
+	err = archiveModules(root, module.NewInmemStorage(), result)
+	assert.True(errors.Is(err, errModuleTestFailed))
+
+	statuses := make(map[string]moduleResult)
+	for _, m := range result.Modules {
+		statuses[m.Name] = m
+	}
+
+	assert.Equal(moduleStatusUploaded, statuses["passing"].Status)
+	assert.Equal(moduleStatusTestFailed, statuses["failing"].Status)
+	assert.Equal("broken\n", statuses["failing"].TestOutput)
+}
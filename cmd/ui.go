@@ -0,0 +1,21 @@
+package cmd
+
+import (
+	"net/http"
+
+	"github.com/TierMobility/boring-registry/pkg/ui"
+)
+
+const prefixUI = "/ui"
+
+var flagUI bool
+
+func init() {
+	serverCmd.Flags().BoolVar(&flagUI, "ui", false, "Serve the web UI for browsing modules under "+prefixUI+"/")
+}
+
+// registerUI registers the web UI, which reads the module APIs of the same server.
+func registerUI(mux *http.ServeMux) {
+	mux.Handle(prefixUI+"/", http.StripPrefix(prefixUI, ui.Handler()))
+	mux.Handle(prefixUI, http.RedirectHandler(prefixUI+"/", http.StatusMovedPermanently))
+}
@@ -4,7 +4,10 @@ import (
 	"fmt"
 	"os"
 	"regexp"
+	"sync"
+	"time"
 
+	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/go-version"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
@@ -15,11 +18,28 @@ var (
 	flagIgnoreExistingModule     bool
 	flagVersionConstraintsRegex  string
 	flagVersionConstraintsSemver string
+	flagRetries                  int
+	flagRetryBackoff             time.Duration
+	flagRetryMaxBackoff          time.Duration
+	flagTargets                  []string
+	flagTestCommand              string
+	flagTestTimeout              time.Duration
+	flagSince                    string
+	flagPrintDigest              bool
+	flagSkipIdentical            bool
+	flagPublishAt                string
+	flagDryRun                   bool
 )
 
 var (
 	versionConstraintsRegex  *regexp.Regexp
 	versionConstraintsSemver version.Constraints
+
+	// changedModuleFiles contains the changed files if --since is set.
+	changedModuleFiles []string
+
+	// publishAt is the scheduled publication time if --publish-at is set.
+	publishAt time.Time
 )
 
 func init() {
@@ -30,43 +50,150 @@ func init() {
 		"Can be combined with the -version-constraints-semver flag")
 	uploadCmd.Flags().StringVar(&flagVersionConstraintsSemver, "version-constraints-semver", "", "Limit the module versions that are eligible for upload with version constraints.\n"+
 		"The version string has to be formatted as a string literal containing one or more conditions, which are separated by commas. Can be combined with the -version-constrained-regex flag")
+	uploadCmd.Flags().BoolVar(&flagSkipIdentical, "skip-identical", true, "Skip existing module versions with identical content even if --ignore-existing=false.\n"+
+		"If set to false, existing versions are handled by --ignore-existing regardless of their content")
+	uploadCmd.Flags().BoolVar(&flagDryRun, "dry-run", false, "Discover, test and package the modules without uploading them")
+	uploadCmd.Flags().BoolVar(&flagPrintDigest, "print-digest", false, "Print the digests of the module archives instead of uploading them")
+	uploadCmd.Flags().StringVar(&flagPublishAt, "publish-at", "", "Hide the uploaded module versions until the given RFC 3339 time, e.g. 2024-05-01T09:00:00Z")
+	uploadCmd.Flags().StringVar(&flagSince, "since", "", "Only upload modules with changes since the given git ref, e.g. origin/main")
+	uploadCmd.Flags().StringVar(&flagTestCommand, "test-command", "", "Command to run in every module directory before the upload, e.g. \"terraform init -backend=false && terraform test\".\n"+
+		"Modules are only uploaded if the command succeeds")
+	uploadCmd.Flags().DurationVar(&flagTestTimeout, "test-timeout", 10*time.Minute, "Timeout of the test command per module, 0 disables the timeout")
+	uploadCmd.Flags().IntVar(&flagRetries, "retries", 3, "Number of retries for transient storage failures, 0 disables retries")
+	uploadCmd.Flags().DurationVar(&flagRetryBackoff, "retry-backoff", time.Second, "Initial delay between retries, doubled after every attempt")
+	uploadCmd.Flags().StringArrayVar(&flagTargets, "target", nil, "Additional storage backend to publish to in the format s3://bucket/prefix?region=... or gs://bucket/prefix, can be repeated")
+	uploadCmd.Flags().DurationVar(&flagRetryMaxBackoff, "retry-max-backoff", 30*time.Second, "Maximum delay between retries")
 }
 
 var uploadCmd = &cobra.Command{
 	Use:   "upload [flags] MODULE",
 	Short: "Upload modules",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		storage, err := setupModuleStorage()
-		if err != nil {
-			return errors.Wrap(err, "failed to setup storage")
+		if flagPrintDigest {
+			if len(args) == 0 {
+				return usageError{errors.New("missing argument")}
+			}
+			return printModuleDigests(args[0])
 		}
 
-		if len(args) == 0 {
-			return fmt.Errorf("missing argument")
+		result := &uploadResult{
+			Modules: []moduleResult{},
 		}
 
-		if _, err := os.Stat(args[0]); errors.Is(err, os.ErrNotExist) {
-			return err
-		}
+		err := runUpload(args, result)
 
-		// Validate the semver version constraints
-		if flagVersionConstraintsSemver != "" {
-			constraints, err := version.NewConstraint(flagVersionConstraintsSemver)
+		if flagOutput == outputJSON {
 			if err != nil {
+				result.Error = err.Error()
+			}
+			if err := printJSON(os.Stdout, result); err != nil {
 				return err
 			}
-			versionConstraintsSemver = constraints
 		}
 
-		// Validate the regex version constraints
-		if flagVersionConstraintsRegex != "" {
-			constraints, err := regexp.Compile(flagVersionConstraintsRegex)
-			if err != nil {
-				return fmt.Errorf("invalid regex given: %v", err)
+		return err
+	},
+}
+
+func runUpload(args []string, result *uploadResult) error {
+	if len(args) == 0 {
+		return usageError{errors.New("missing argument")}
+	}
+
+	if _, err := os.Stat(args[0]); errors.Is(err, os.ErrNotExist) {
+		return usageError{err}
+	}
+
+	// Validate the semver version constraints
+	if flagVersionConstraintsSemver != "" {
+		constraints, err := version.NewConstraint(flagVersionConstraintsSemver)
+		if err != nil {
+			return usageError{err}
+		}
+		versionConstraintsSemver = constraints
+	}
+
+	// Validate the regex version constraints
+	if flagVersionConstraintsRegex != "" {
+		constraints, err := regexp.Compile(flagVersionConstraintsRegex)
+		if err != nil {
+			return usageError{fmt.Errorf("invalid regex given: %v", err)}
+		}
+		versionConstraintsRegex = constraints
+	}
+
+	if flagSince != "" {
+		files, err := changedFiles(args[0], flagSince)
+		if err != nil {
+			return usageError{errors.Wrap(err, "failed to determine changed files")}
+		}
+		changedModuleFiles = files
+	}
+
+	if flagPublishAt != "" {
+		t, err := time.Parse(time.RFC3339, flagPublishAt)
+		if err != nil {
+			return usageError{errors.Wrap(err, "invalid publish time")}
+		}
+		publishAt = t
+	}
+
+	if flagRetries < 0 {
+		return usageError{errors.New("retries must not be negative")}
+	}
+
+	notifier, err := newNotifier(defaultNotificationTemplate)
+	if err != nil {
+		return err
+	}
+
+	targets, err := setupUploadTargets()
+	if err != nil {
+		return err
+	}
+
+	if len(targets) == 1 {
+		err = archiveModules(args[0], targets[0].storage, result)
+	} else {
+		err = publishTargets(args[0], targets, result)
+	}
+
+	// Modules uploaded before a failure are published nevertheless
+	notifier.notifyUploads(result)
+
+	return err
+}
+
+// publishTargets uploads the modules to all targets in parallel and reports the status per target.
+func publishTargets(root string, targets []uploadTarget, result *uploadResult) error {
+	var (
+		wg      sync.WaitGroup
+		results = make([]*uploadResult, len(targets))
+		errs    = make([]error, len(targets))
+	)
+
+	for i, t := range targets {
+		wg.Add(1)
+		go func(i int, t uploadTarget) {
+			defer wg.Done()
+
+			results[i] = &uploadResult{}
+			errs[i] = archiveModules(root, t.storage, results[i])
+			for j := range results[i].Modules {
+				results[i].Modules[j].Target = t.name
 			}
-			versionConstraintsRegex = constraints
+		}(i, t)
+	}
+
+	wg.Wait()
+
+	var merr *multierror.Error
+	for i, t := range targets {
+		result.Modules = append(result.Modules, results[i].Modules...)
+		if errs[i] != nil {
+			merr = multierror.Append(merr, errors.Wrapf(errs[i], "target %s", t.name))
 		}
+	}
 
-		return archiveModules(args[0], storage)
-	},
+	return merr.ErrorOrNil()
 }
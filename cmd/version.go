@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/TierMobility/boring-registry/version"
 	"github.com/spf13/cobra"
@@ -14,7 +15,24 @@ func init() {
 var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Prints the version of the Boring Registry",
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if flagOutput == outputJSON {
+			return printJSON(os.Stdout, versionResult{
+				Version: version.Version,
+				Commit:  version.Commit,
+				Date:    version.Date,
+				BuiltBy: version.BuiltBy,
+			})
+		}
+
 		fmt.Println(version.String())
+		return nil
 	},
 }
+
+type versionResult struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit"`
+	Date    string `json:"date"`
+	BuiltBy string `json:"built_by"`
+}
@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+)
+
+var (
+	// Virtual host options.
+	flagVirtualHosts       []string
+	flagVirtualHostAPIKeys []string
+)
+
+func init() {
+	serverCmd.Flags().StringArrayVar(&flagVirtualHosts, "virtual-host", nil, "Serve a separate registry for a Host header, e.g. registry-b.example.com=s3://bucket/prefix (can be repeated)")
+	serverCmd.Flags().StringArrayVar(&flagVirtualHostAPIKeys, "virtual-host-api-key", nil, "Comma-separated API keys of a virtual host, e.g. registry-b.example.com=key1,key2 (defaults to --api-key)")
+}
+
+// virtualHost is a registry served for requests to a specific Host header.
+type virtualHost struct {
+	host    string
+	storage *storageURL
+	apiKeys []string
+}
+
+// parseVirtualHosts parses the HOST=STORAGE_URL and HOST=KEYS pairs of the virtual host flags.
+// Virtual hosts without their own API keys are protected by the default API keys.
+func parseVirtualHosts(hosts, apiKeys, defaultAPIKeys []string) ([]virtualHost, error) {
+	var (
+		vhosts  []virtualHost
+		indexes = make(map[string]int)
+	)
+
+	for _, raw := range hosts {
+		host, value, err := splitVirtualHost(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := indexes[host]; ok {
+			return nil, usageError{fmt.Errorf("virtual host %s is defined more than once", host)}
+		}
+
+		u, err := parseStorageURL(value)
+		if err != nil {
+			return nil, err
+		}
+
+		indexes[host] = len(vhosts)
+		vhosts = append(vhosts, virtualHost{host: host, storage: u, apiKeys: defaultAPIKeys})
+	}
+
+	for _, raw := range apiKeys {
+		host, value, err := splitVirtualHost(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		i, ok := indexes[host]
+		if !ok {
+			return nil, usageError{fmt.Errorf("API keys configured for unknown virtual host %s", host)}
+		}
+
+		vhosts[i].apiKeys = splitKeys(value)
+	}
+
+	return vhosts, nil
+}
+
+func splitVirtualHost(raw string) (string, string, error) {
+	parts := strings.SplitN(raw, "=", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", usageError{fmt.Errorf("invalid virtual host %q, expected HOST=VALUE", raw)}
+	}
+
+	return strings.ToLower(parts[0]), parts[1], nil
+}
+
+// virtualHostRouter serves the configured virtual hosts and falls back to the given handler for all other hosts.
+func virtualHostRouter(fallback http.Handler, opts registryOptions) (http.Handler, error) {
+	vhosts, err := parseVirtualHosts(flagVirtualHosts, flagVirtualHostAPIKeys, splitKeys(flagAPIKey))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(vhosts) == 0 {
+		return fallback, nil
+	}
+
+	router := &hostRouter{
+		hosts:    make(map[string]http.Handler),
+		fallback: fallback,
+	}
+
+	for _, vh := range vhosts {
+		s, err := vh.storage.providerStorage()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to setup storage of virtual host %s", vh.host)
+		}
+
+		ms, err := vh.storage.moduleStorage()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to setup module storage of virtual host %s", vh.host)
+		}
+
+		// The journal and the retention policies only apply to the storage of the default host
+		vhOpts := opts
+		vhOpts.journal = nil
+		vhOpts.retention = nil
+
+		mux := http.NewServeMux()
+		registerRegistry(mux, ms, s, vh.apiKeys, vhOpts)
+		router.hosts[vh.host] = mux
+
+		_ = level.Info(logger).Log("msg", "serving virtual host", "host", vh.host, "storage", vh.storage.scheme+"://"+vh.storage.bucket)
+	}
+
+	return router, nil
+}
+
+// hostRouter dispatches requests to a handler based on their Host header.
+type hostRouter struct {
+	hosts    map[string]http.Handler
+	fallback http.Handler
+}
+
+func (h *hostRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	host := r.Host
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		host = name
+	}
+
+	if handler, ok := h.hosts[strings.ToLower(host)]; ok {
+		handler.ServeHTTP(w, r)
+		return
+	}
+
+	h.fallback.ServeHTTP(w, r)
+}
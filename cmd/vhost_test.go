@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseVirtualHosts(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		hosts     []string
+		apiKeys   []string
+		expectErr bool
+		expected  map[string][]string
+	}{
+		{
+			name:     "default api keys",
+			hosts:    []string{"registry-b.example.com=s3://bucket/prefix"},
+			expected: map[string][]string{"registry-b.example.com": {"default"}},
+		},
+		{
+			name:     "own api keys",
+			hosts:    []string{"Registry-B.example.com=gs://bucket"},
+			apiKeys:  []string{"registry-b.example.com=key1,key2"},
+			expected: map[string][]string{"registry-b.example.com": {"key1", "key2"}},
+		},
+		{
+			name:      "missing storage",
+			hosts:     []string{"registry-b.example.com"},
+			expectErr: true,
+		},
+		{
+			name:      "invalid storage",
+			hosts:     []string{"registry-b.example.com=azure://bucket"},
+			expectErr: true,
+		},
+		{
+			name:      "duplicate host",
+			hosts:     []string{"registry-b.example.com=s3://a", "registry-b.example.com=s3://b"},
+			expectErr: true,
+		},
+		{
+			name:      "api keys of unknown host",
+			hosts:     []string{"registry-b.example.com=s3://bucket"},
+			apiKeys:   []string{"registry-c.example.com=key"},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+
+			vhosts, err := parseVirtualHosts(tc.hosts, tc.apiKeys, []string{"default"})
+			if tc.expectErr {
+				assert.Equal(exitCodeUsage, exitCode(err))
+				return
+			}
+			assert.NoError(err)
+
+			actual := make(map[string][]string)
+			for _, vh := range vhosts {
+				actual[vh.host] = vh.apiKeys
+			}
+			assert.Equal(tc.expected, actual)
+		})
+	}
+}
+
+func TestHostRouter(t *testing.T) {
+	t.Parallel()
+
+	handler := func(name string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name))
+		})
+	}
+
+	router := &hostRouter{
+		hosts: map[string]http.Handler{
+			"registry-b.example.com": handler("b"),
+		},
+		fallback: handler("default"),
+	}
+
+	testCases := []struct {
+		host     string
+		expected string
+	}{
+		{host: "registry-b.example.com", expected: "b"},
+		{host: "REGISTRY-B.example.com:5601", expected: "b"},
+		{host: "registry-a.example.com", expected: "default"},
+		{host: "localhost:5601", expected: "default"},
+	}
+
+	for _, tc := range testCases {
+		req := httptest.NewRequest(http.MethodGet, "/.well-known/terraform.json", nil)
+		req.Host = tc.host
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, tc.expected, rec.Body.String(), tc.host)
+	}
+}
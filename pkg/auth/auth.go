@@ -2,29 +2,26 @@ package auth
 
 import (
 	"context"
-	"fmt"
 
 	"github.com/go-kit/kit/endpoint"
-	httptransport "github.com/go-kit/kit/transport/http"
 )
 
 // Middleware provides basic endpoint auth.
 func Middleware(keys ...string) endpoint.Middleware {
-	return func(next endpoint.Endpoint) endpoint.Endpoint {
-		return func(ctx context.Context, request interface{}) (interface{}, error) {
-			if len(keys) < 1 {
-				return next(ctx, request)
-			}
+	if len(keys) < 1 {
+		return ChainMiddleware()
+	}
 
-			for _, key := range keys {
-				key := fmt.Sprintf("Bearer %s", key)
-				if key == ctx.Value(httptransport.ContextKeyRequestAuthorization) {
-					return next(ctx, request)
-				}
-			}
+	return ChainMiddleware(KeyAuthenticator(keys...))
+}
 
-			return nil, ErrInvalidKey
+// Verifier verifies Bearer tokens which are no static API keys, e.g. JWTs of an identity provider.
+type Verifier interface {
+	Verify(ctx context.Context, token string) error
+}
 
-		}
-	}
+// TokenMiddleware provides endpoint auth with static API keys and the tokens accepted by the verifier.
+// Unlike Middleware, requests are always authenticated, even without keys.
+func TokenMiddleware(verifier Verifier, keys ...string) endpoint.Middleware {
+	return ChainMiddleware(KeyAuthenticator(keys...), TokenAuthenticator(verifier))
 }
@@ -0,0 +1,239 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// defaultAuthorizationCacheTTL limits how long decisions are reused without asking the authorization endpoint again,
+	// which is the longest a changed policy takes to apply.
+	defaultAuthorizationCacheTTL = time.Minute
+	// maxAuthorizationCacheEntries limits the memory of the cache of decisions.
+	maxAuthorizationCacheEntries = 4096
+)
+
+// CacheEvent is a lookup or removal of an entry of the caches of decisions and tokens.
+type CacheEvent string
+
+// Events of caches. Lookups are hits or misses, every hit saves a request to the endpoint.
+const (
+	CacheHit  CacheEvent = "hit"
+	CacheMiss CacheEvent = "miss"
+	// CacheExpired is the removal of an entry which outlived its TTL.
+	CacheExpired CacheEvent = "expired"
+	// CacheFull is the removal of an entry which hadn't expired yet, as the cache is full.
+	CacheFull CacheEvent = "full"
+)
+
+// Resource types of authorization requests.
+const (
+	ResourceModule   = "module"
+	ResourceProvider = "provider"
+)
+
+// Actions of authorization requests.
+const (
+	// ActionList lists the versions of a module or provider, or the modules of a namespace.
+	ActionList = "list"
+	// ActionDownload downloads a module or provider version.
+	ActionDownload = "download"
+	// ActionUpload uploads a module version.
+	ActionUpload = "upload"
+	// ActionRead reads the metadata of a module, like its annotations and labels.
+	ActionRead = "read"
+	// ActionWrite changes the metadata of a module, like its annotations, labels, maturity or approvals.
+	ActionWrite = "write"
+)
+
+// AuthorizationRequest describes a request to be authorized by an external policy.
+type AuthorizationRequest struct {
+	// Subject identifies the client, see Identity.
+	Subject      string `json:"subject"`
+	Action       string `json:"action"`
+	ResourceType string `json:"resource_type"`
+	// Resource is the address of the resource, e.g. "namespace/name/provider/version" of a module version,
+	// "namespace/name/provider" of a module, "namespace/name/version" or "namespace/name" of a provider,
+	// or "namespace" for listings of a namespace.
+	Resource string `json:"resource"`
+}
+
+// Authorizer decides whether a request is allowed.
+// It returns nil if the request is allowed, ErrForbidden if it is denied, and other errors if no decision could be made.
+type Authorizer interface {
+	Authorize(ctx context.Context, req AuthorizationRequest) error
+}
+
+// HTTPAuthorizer delegates authorization decisions to an HTTP endpoint, e.g. the data API of an Open Policy Agent sidecar.
+// The request is posted as {"input": {...}} and the endpoint responds with {"result": true} to allow it.
+// Decisions are cached, so not every request is sent to the endpoint.
+type HTTPAuthorizer struct {
+	endpoint string
+	client   *http.Client
+	ttl      time.Duration
+	now      func() time.Time
+	report   func(CacheEvent)
+
+	mu    sync.Mutex
+	cache map[AuthorizationRequest]authorizationDecision
+}
+
+type authorizationDecision struct {
+	allowed bool
+	expires time.Time
+}
+
+// HTTPAuthorizerOption provides additional options for the HTTPAuthorizer.
+type HTTPAuthorizerOption func(*HTTPAuthorizer)
+
+// WithAuthorizationCacheTTL sets how long decisions are cached, decisions aren't cached with a TTL of 0.
+func WithAuthorizationCacheTTL(ttl time.Duration) HTTPAuthorizerOption {
+	return func(a *HTTPAuthorizer) {
+		a.ttl = ttl
+	}
+}
+
+// WithAuthorizationCacheReport configures a function which is called on every lookup and removal of a cached decision.
+// It is called while the cache is locked, so it must not block.
+func WithAuthorizationCacheReport(report func(CacheEvent)) HTTPAuthorizerOption {
+	return func(a *HTTPAuthorizer) {
+		a.report = report
+	}
+}
+
+// NewHTTPAuthorizer returns an authorizer asking the endpoint, the http.DefaultClient is used if client is nil.
+func NewHTTPAuthorizer(endpoint string, client *http.Client, options ...HTTPAuthorizerOption) *HTTPAuthorizer {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	a := &HTTPAuthorizer{
+		endpoint: endpoint,
+		client:   client,
+		ttl:      defaultAuthorizationCacheTTL,
+		now:      time.Now,
+		report:   func(CacheEvent) {},
+		cache:    make(map[AuthorizationRequest]authorizationDecision),
+	}
+
+	for _, option := range options {
+		option(a)
+	}
+
+	return a
+}
+
+// Authorize asks the endpoint whether the request is allowed. Errors of the endpoint aren't cached and fail the request.
+func (a *HTTPAuthorizer) Authorize(ctx context.Context, req AuthorizationRequest) error {
+	now := a.now()
+
+	decision, ok := a.lookup(req, now)
+	if !ok {
+		allowed, err := a.decide(ctx, req)
+		if err != nil {
+			return errors.Wrap(err, "failed to authorize request")
+		}
+
+		decision = authorizationDecision{allowed: allowed, expires: now.Add(a.ttl)}
+		if a.ttl > 0 {
+			a.remember(req, decision, now)
+		}
+	}
+
+	if !decision.allowed {
+		return ErrForbidden
+	}
+
+	return nil
+}
+
+// lookup returns the cached decision of a request, expired decisions are dropped. Without TTL, nothing is cached.
+func (a *HTTPAuthorizer) lookup(req AuthorizationRequest, now time.Time) (authorizationDecision, bool) {
+	if a.ttl <= 0 {
+		return authorizationDecision{}, false
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	decision, ok := a.cache[req]
+	if ok && !now.Before(decision.expires) {
+		delete(a.cache, req)
+		a.report(CacheExpired)
+		ok = false
+	}
+
+	if ok {
+		a.report(CacheHit)
+	} else {
+		a.report(CacheMiss)
+	}
+
+	return decision, ok
+}
+
+// remember caches a decision until it expires, expired decisions are dropped once the cache is full.
+func (a *HTTPAuthorizer) remember(req AuthorizationRequest, decision authorizationDecision, now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if len(a.cache) >= maxAuthorizationCacheEntries {
+		for k, d := range a.cache {
+			if !now.Before(d.expires) {
+				delete(a.cache, k)
+				a.report(CacheExpired)
+			}
+		}
+	}
+	if len(a.cache) >= maxAuthorizationCacheEntries {
+		for range a.cache {
+			a.report(CacheFull)
+		}
+		a.cache = make(map[AuthorizationRequest]authorizationDecision)
+	}
+
+	a.cache[req] = decision
+}
+
+func (a *HTTPAuthorizer) decide(ctx context.Context, req AuthorizationRequest) (bool, error) {
+	b, err := json.Marshal(struct {
+		Input AuthorizationRequest `json:"input"`
+	}{req})
+	if err != nil {
+		return false, err
+	}
+
+	r, err := http.NewRequest(http.MethodPost, a.endpoint, bytes.NewReader(b))
+	if err != nil {
+		return false, err
+	}
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Accept", "application/json")
+
+	res, err := a.client.Do(r.WithContext(ctx))
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("unexpected status %d from %s", res.StatusCode, a.endpoint)
+	}
+
+	// An undefined result of OPA, e.g. for a missing rule, is treated like a denial
+	var out struct {
+		Result bool `json:"result"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		return false, err
+	}
+
+	return out.Result, nil
+}
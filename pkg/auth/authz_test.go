@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPAuthorizer(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+
+		var body struct {
+			Input AuthorizationRequest `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		switch body.Input.Subject {
+		case "sub:platform":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"result": true})
+		case "sub:broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			// OPA omits the result if the rule is undefined
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{})
+		}
+	}))
+	defer server.Close()
+
+	now := time.Date(2024, 5, 13, 9, 0, 0, 0, time.UTC)
+	events := make(map[CacheEvent]int)
+	authorizer := NewHTTPAuthorizer(server.URL, nil, WithAuthorizationCacheTTL(time.Minute), WithAuthorizationCacheReport(func(event CacheEvent) {
+		events[event]++
+	}))
+	authorizer.now = func() time.Time { return now }
+
+	req := func(subject string) AuthorizationRequest {
+		return AuthorizationRequest{Subject: subject, Action: ActionUpload, ResourceType: ResourceModule, Resource: "tier/vpc/aws/1.0.0"}
+	}
+	ctx := context.Background()
+
+	assert.NoError(authorizer.Authorize(ctx, req("sub:platform")))
+	assert.Equal(ErrForbidden, authorizer.Authorize(ctx, req("sub:ci")))
+	assert.EqualValues(2, atomic.LoadInt32(&requests))
+
+	// Decisions are cached until they expire
+	assert.NoError(authorizer.Authorize(ctx, req("sub:platform")))
+	assert.Equal(ErrForbidden, authorizer.Authorize(ctx, req("sub:ci")))
+	assert.EqualValues(2, atomic.LoadInt32(&requests))
+
+	now = now.Add(time.Minute)
+	assert.NoError(authorizer.Authorize(ctx, req("sub:platform")))
+	assert.EqualValues(3, atomic.LoadInt32(&requests))
+
+	// Errors aren't decisions and aren't cached
+	err := authorizer.Authorize(ctx, req("sub:broken"))
+	assert.Error(err)
+	assert.NotEqual(ErrForbidden, err)
+	_ = authorizer.Authorize(ctx, req("sub:broken"))
+	assert.EqualValues(5, atomic.LoadInt32(&requests))
+
+	// Every miss is a request to the endpoint
+	assert.Equal(map[CacheEvent]int{CacheHit: 2, CacheMiss: 5, CacheExpired: 1}, events)
+}
@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+)
+
+type contextKey string
+
+const (
+	contextKeyTLSState contextKey = "tls-state"
+	contextKeyHeader   contextKey = "header"
+)
+
+// Authenticator authenticates a request by the values of its context, which are populated by the
+// PopulateRequestContext functions of go-kit and this package.
+// It returns nil if it accepts the request and an error otherwise, e.g. if the request lacks its kind of credentials.
+type Authenticator interface {
+	Authenticate(ctx context.Context) error
+}
+
+// AuthenticatorFunc is an adapter to use ordinary functions as Authenticator.
+type AuthenticatorFunc func(ctx context.Context) error
+
+// Authenticate calls f(ctx).
+func (f AuthenticatorFunc) Authenticate(ctx context.Context) error {
+	return f(ctx)
+}
+
+// ChainMiddleware authenticates requests with an ordered chain of authenticators, the first one accepting a request wins.
+// Cheap authenticators like static keys should come first, as every authenticator is tried until one accepts the request.
+// Without authenticators all requests are allowed.
+func ChainMiddleware(authenticators ...Authenticator) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			if len(authenticators) == 0 {
+				return next(ctx, request)
+			}
+
+			for _, authenticator := range authenticators {
+				if err := authenticator.Authenticate(ctx); err == nil {
+					return next(ctx, request)
+				}
+			}
+
+			return nil, ErrInvalidKey
+		}
+	}
+}
+
+// KeyAuthenticator accepts the static API keys as Bearer tokens.
+func KeyAuthenticator(keys ...string) Authenticator {
+	return AuthenticatorFunc(func(ctx context.Context) error {
+		for _, key := range keys {
+			if fmt.Sprintf("Bearer %s", key) == ctx.Value(httptransport.ContextKeyRequestAuthorization) {
+				return nil
+			}
+		}
+
+		return ErrInvalidKey
+	})
+}
+
+// TokenAuthenticator accepts the Bearer tokens accepted by the verifier.
+func TokenAuthenticator(verifier Verifier) Authenticator {
+	return AuthenticatorFunc(func(ctx context.Context) error {
+		authorization, _ := ctx.Value(httptransport.ContextKeyRequestAuthorization).(string)
+
+		token := strings.TrimPrefix(authorization, "Bearer ")
+		if token == authorization || token == "" {
+			return ErrInvalidKey
+		}
+
+		return verifier.Verify(ctx, token)
+	})
+}
+
+// ClientCertAuthenticator accepts requests with a client certificate verified by the TLS server, see tls.Config.ClientCAs.
+// If common names are given, the common name of the certificate has to be one of them.
+func ClientCertAuthenticator(commonNames ...string) Authenticator {
+	return AuthenticatorFunc(func(ctx context.Context) error {
+		state := TLSState(ctx)
+		if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+			return ErrInvalidKey
+		}
+
+		if len(commonNames) == 0 {
+			return nil
+		}
+
+		cn := state.VerifiedChains[0][0].Subject.CommonName
+		for _, name := range commonNames {
+			if name == cn {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("unexpected client certificate %q", cn)
+	})
+}
+
+// ReadOnlyAuthenticator only lets the authenticator accept requests which don't change anything, i.e. GET and HEAD requests.
+// It requires the request method in the context, see go-kit's PopulateRequestContext.
+func ReadOnlyAuthenticator(authenticator Authenticator) Authenticator {
+	return AuthenticatorFunc(func(ctx context.Context) error {
+		switch ctx.Value(httptransport.ContextKeyRequestMethod) {
+		case http.MethodGet, http.MethodHead:
+			return authenticator.Authenticate(ctx)
+		default:
+			return ErrInvalidKey
+		}
+	})
+}
+
+// PopulateRequestContext stores the TLS state and the headers of the request in the context,
+// so authenticators can authenticate requests by client certificates or other headers than Authorization.
+func PopulateRequestContext(ctx context.Context, r *http.Request) context.Context {
+	ctx = context.WithValue(ctx, contextKeyTLSState, r.TLS)
+	return context.WithValue(ctx, contextKeyHeader, r.Header)
+}
+
+// TLSState returns the TLS state of the request, or nil for requests without TLS.
+func TLSState(ctx context.Context) *tls.ConnectionState {
+	state, _ := ctx.Value(contextKeyTLSState).(*tls.ConnectionState)
+	return state
+}
+
+// Header returns the headers of the request.
+func Header(ctx context.Context) http.Header {
+	header, _ := ctx.Value(contextKeyHeader).(http.Header)
+	if header == nil {
+		return http.Header{}
+	}
+	return header
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Authenticator)
+)
+
+// Register makes a custom authenticator available under the name, which is used to refer to it in the configuration.
+// It is meant to be called from the init function of a package compiled into the registry, like database/sql.Register,
+// and panics if the name is already registered.
+func Register(name string, authenticator Authenticator) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if authenticator == nil {
+		panic("auth: Register authenticator is nil")
+	}
+	if _, ok := registry[name]; ok {
+		panic("auth: Register called twice for authenticator " + name)
+	}
+
+	registry[name] = authenticator
+}
+
+// Registered returns the custom authenticator registered under the name.
+func Registered(name string) (Authenticator, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	authenticator, ok := registry[name]
+	return authenticator, ok
+}
+
+// RegisteredNames returns the sorted names of the custom authenticators.
+func RegisteredNames() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChainMiddleware(t *testing.T) {
+	t.Parallel()
+
+	// internal accepts requests with the header of a bespoke auth scheme
+	internal := AuthenticatorFunc(func(ctx context.Context) error {
+		if Header(ctx).Get("X-Internal-Auth") != "valid" {
+			return errors.New("missing internal auth")
+		}
+		return nil
+	})
+
+	jwt := TokenAuthenticator(verifierFunc(func(ctx context.Context, token string) error {
+		if token != "jwt" {
+			return errors.New("invalid jwt")
+		}
+		return nil
+	}))
+
+	clientCert := func(cn string) *tls.ConnectionState {
+		return &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: cn}}}}}
+	}
+
+	testCases := []struct {
+		name           string
+		authenticators []Authenticator
+		authorization  string
+		header         http.Header
+		tls            *tls.ConnectionState
+		expectError    bool
+	}{
+		{name: "no authenticators"},
+		{name: "api key", authenticators: []Authenticator{KeyAuthenticator("foo"), jwt}, authorization: "Bearer foo"},
+		{name: "jwt", authenticators: []Authenticator{KeyAuthenticator("foo"), jwt}, authorization: "Bearer jwt"},
+		{name: "invalid token", authenticators: []Authenticator{KeyAuthenticator("foo"), jwt}, authorization: "Bearer bar", expectError: true},
+		{name: "missing token", authenticators: []Authenticator{KeyAuthenticator("foo"), jwt}, expectError: true},
+		{name: "custom", authenticators: []Authenticator{jwt, internal}, header: http.Header{"X-Internal-Auth": {"valid"}}},
+		{name: "invalid custom", authenticators: []Authenticator{jwt, internal}, header: http.Header{"X-Internal-Auth": {"forged"}}, expectError: true},
+		{name: "client certificate", authenticators: []Authenticator{ClientCertAuthenticator()}, tls: clientCert("ci")},
+		{name: "client certificate of common name", authenticators: []Authenticator{ClientCertAuthenticator("ci")}, tls: clientCert("ci")},
+		{name: "client certificate of other common name", authenticators: []Authenticator{ClientCertAuthenticator("ci")}, tls: clientCert("laptop"), expectError: true},
+		{name: "unverified client", authenticators: []Authenticator{ClientCertAuthenticator()}, tls: &tls.ConnectionState{}, expectError: true},
+		{name: "without tls", authenticators: []Authenticator{ClientCertAuthenticator()}, expectError: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.header != nil {
+				r.Header = tc.header
+			}
+			r.TLS = tc.tls
+
+			ctx := PopulateRequestContext(context.Background(), r)
+			if tc.authorization != "" {
+				ctx = context.WithValue(ctx, httptransport.ContextKeyRequestAuthorization, tc.authorization)
+			}
+
+			_, err := ChainMiddleware(tc.authenticators...)(nopEndpoint)(ctx, nil)
+			if tc.expectError {
+				assert.Equal(t, ErrInvalidKey, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestReadOnlyAuthenticator(t *testing.T) {
+	t.Parallel()
+
+	authenticator := ReadOnlyAuthenticator(KeyAuthenticator("foo"))
+	ctx := context.WithValue(context.Background(), httptransport.ContextKeyRequestAuthorization, "Bearer foo")
+
+	for method, expectError := range map[string]bool{
+		http.MethodGet:  false,
+		http.MethodHead: false,
+		http.MethodPost: true,
+		"":              true,
+	} {
+		err := authenticator.Authenticate(context.WithValue(ctx, httptransport.ContextKeyRequestMethod, method))
+		assert.Equal(t, expectError, err != nil, method)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	t.Parallel()
+
+	authenticator := AuthenticatorFunc(func(ctx context.Context) error { return nil })
+	Register("test-register", authenticator)
+
+	_, ok := Registered("test-register")
+	assert.True(t, ok)
+	assert.Contains(t, RegisteredNames(), "test-register")
+
+	_, ok = Registered("unknown")
+	assert.False(t, ok)
+
+	assert.Panics(t, func() { Register("test-register", authenticator) })
+}
@@ -5,4 +5,5 @@ import "errors"
 // Middleware errors.
 var (
 	ErrInvalidKey = errors.New("invalid key")
+	ErrForbidden  = errors.New("access denied")
 )
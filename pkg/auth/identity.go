@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	httptransport "github.com/go-kit/kit/transport/http"
+)
+
+// Identity identifies the client of an authenticated request for audit logs and authorization decisions.
+// It is the subject of a JWT ("sub:<subject>"), a fingerprint of other Bearer tokens ("token:<fingerprint>"),
+// the SPIFFE ID of a verified X.509 SVID ("spiffe://<trust domain>/<path>"),
+// the common name of other verified client certificates ("cn:<name>"), or "anonymous".
+// The claims of JWTs aren't verified here, so it must only be used after the request has been authenticated.
+// Tokens like API keys are identified by a fingerprint, so they don't end up in logs.
+func Identity(ctx context.Context) string {
+	authorization, _ := ctx.Value(httptransport.ContextKeyRequestAuthorization).(string)
+	token := strings.TrimPrefix(authorization, "Bearer ")
+	if token != authorization && token != "" {
+		if parts := strings.Split(token, "."); len(parts) == 3 {
+			var claims struct {
+				Subject string `json:"sub"`
+			}
+			if b, err := base64.RawURLEncoding.DecodeString(parts[1]); err == nil && json.Unmarshal(b, &claims) == nil && claims.Subject != "" {
+				return "sub:" + claims.Subject
+			}
+		}
+
+		sum := sha256.Sum256([]byte(token))
+		return fmt.Sprintf("token:%x", sum[:6])
+	}
+
+	if id := SPIFFEID(ctx); id != "" {
+		return id
+	}
+
+	if state := TLSState(ctx); state != nil && len(state.VerifiedChains) > 0 && len(state.VerifiedChains[0]) > 0 {
+		return "cn:" + state.VerifiedChains[0][0].Subject.CommonName
+	}
+
+	return "anonymous"
+}
@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdentity(t *testing.T) {
+	t.Parallel()
+
+	jwt := func(claims string) string {
+		return "eyJhbGciOiJSUzI1NiJ9." + base64.RawURLEncoding.EncodeToString([]byte(claims)) + ".c2lnbmF0dXJl"
+	}
+
+	testCases := []struct {
+		name          string
+		authorization string
+		tls           *tls.ConnectionState
+		expected      string
+	}{
+		{name: "no token", expected: "anonymous"},
+		{name: "basic auth", authorization: "Basic dXNlcjpwYXNz", expected: "anonymous"},
+		{name: "api key", authorization: "Bearer secret", expected: "token:2bb80d537b1d"},
+		{name: "jwt", authorization: "Bearer " + jwt(`{"sub":"ci@example.com"}`), expected: "sub:ci@example.com"},
+		{name: "jwt without subject", authorization: "Bearer " + jwt(`{"aud":"registry"}`), expected: "token:"},
+		{name: "client certificate", tls: &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "ci"}}}}}, expected: "cn:ci"},
+		{name: "svid", tls: &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{URIs: []*url.URL{{Scheme: "spiffe", Host: "example.org", Path: "/ci/runner"}}}}}}, expected: "spiffe://example.org/ci/runner"},
+		{name: "unverified client certificate", tls: &tls.ConnectionState{}, expected: "anonymous"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest("GET", "/", nil)
+			r.TLS = tc.tls
+
+			ctx := PopulateRequestContext(context.Background(), r)
+			if tc.authorization != "" {
+				ctx = context.WithValue(ctx, httptransport.ContextKeyRequestAuthorization, tc.authorization)
+			}
+
+			assert.True(t, strings.HasPrefix(Identity(ctx), tc.expected))
+		})
+	}
+}
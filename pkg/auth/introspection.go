@@ -0,0 +1,209 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// introspectionCacheTTL limits how long active tokens are accepted without asking the introspection endpoint again,
+	// which is the longest a revoked token stays valid.
+	introspectionCacheTTL = time.Minute
+	// maxIntrospectionCacheEntries limits the memory of the cache of active tokens.
+	maxIntrospectionCacheEntries = 1024
+)
+
+// IntrospectionVerifier verifies opaque tokens with an OAuth 2.0 token introspection endpoint (RFC 7662).
+// Active tokens are cached for a minute, so not every request is sent to the introspection endpoint.
+type IntrospectionVerifier struct {
+	endpoint     string
+	clientID     string
+	clientSecret string
+	issuer       string
+	audience     string
+	client       *http.Client
+	now          func() time.Time
+	report       func(CacheEvent)
+
+	mu    sync.Mutex
+	cache map[[sha256.Size]byte]time.Time
+}
+
+// IntrospectionOption provides additional options for the IntrospectionVerifier.
+type IntrospectionOption func(*IntrospectionVerifier)
+
+// WithIntrospectionCacheReport configures a function which is called on every lookup and removal of a cached token.
+// It is called while the cache is locked, so it must not block.
+func WithIntrospectionCacheReport(report func(CacheEvent)) IntrospectionOption {
+	return func(v *IntrospectionVerifier) {
+		v.report = report
+	}
+}
+
+// NewIntrospectionVerifier returns a verifier of tokens, which authenticates at the introspection endpoint with the client credentials.
+// Tokens have to be issued for the audience, the issuer is only checked if it is not empty.
+// The http.DefaultClient is used if client is nil.
+func NewIntrospectionVerifier(endpoint, clientID, clientSecret, issuer, audience string, client *http.Client, options ...IntrospectionOption) *IntrospectionVerifier {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	v := &IntrospectionVerifier{
+		endpoint:     endpoint,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		issuer:       issuer,
+		audience:     audience,
+		client:       client,
+		now:          time.Now,
+		report:       func(CacheEvent) {},
+		cache:        make(map[[sha256.Size]byte]time.Time),
+	}
+
+	for _, option := range options {
+		option(v)
+	}
+
+	return v
+}
+
+// NewOktaVerifier returns a verifier of the access tokens of an Okta authorization server, e.g. https://example.okta.com/oauth2/default.
+// Unlike JWTs of custom authorization servers, the opaque tokens of the Okta org authorization server can only be verified this way.
+func NewOktaVerifier(issuer, clientID, clientSecret, audience string, client *http.Client, options ...IntrospectionOption) *IntrospectionVerifier {
+	issuer = strings.TrimSuffix(issuer, "/")
+	return NewIntrospectionVerifier(issuer+"/v1/introspect", clientID, clientSecret, issuer, audience, client, options...)
+}
+
+// Verify asks the introspection endpoint whether the token is active, and checks its issuer, audience and expiry.
+func (v *IntrospectionVerifier) Verify(ctx context.Context, token string) error {
+	key := sha256.Sum256([]byte(token))
+	now := v.now()
+
+	if v.lookup(key, now) {
+		return nil
+	}
+
+	var res struct {
+		Active   bool     `json:"active"`
+		Issuer   string   `json:"iss"`
+		Audience audience `json:"aud"`
+		Expiry   int64    `json:"exp"`
+	}
+	if err := v.introspect(ctx, token, &res); err != nil {
+		return errors.Wrap(err, "failed to introspect token")
+	}
+
+	switch {
+	case !res.Active:
+		return errors.New("token is not active")
+	case v.issuer != "" && res.Issuer != v.issuer:
+		return fmt.Errorf("unexpected issuer %q", res.Issuer)
+	case !res.Audience.contains(v.audience):
+		return fmt.Errorf("token is not issued for audience %q", v.audience)
+	case res.Expiry != 0 && !now.Before(time.Unix(res.Expiry, 0)):
+		return errors.New("token is expired")
+	}
+
+	expires := now.Add(introspectionCacheTTL)
+	if res.Expiry != 0 && time.Unix(res.Expiry, 0).Before(expires) {
+		expires = time.Unix(res.Expiry, 0)
+	}
+	v.remember(key, expires, now)
+
+	return nil
+}
+
+// lookup returns whether the token is cached as active, expired tokens are dropped.
+func (v *IntrospectionVerifier) lookup(key [sha256.Size]byte, now time.Time) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	expires, ok := v.cache[key]
+	if ok && !now.Before(expires) {
+		delete(v.cache, key)
+		v.report(CacheExpired)
+		ok = false
+	}
+
+	if ok {
+		v.report(CacheHit)
+	} else {
+		v.report(CacheMiss)
+	}
+
+	return ok
+}
+
+// remember caches an active token until it expires, expired tokens are dropped once the cache is full.
+func (v *IntrospectionVerifier) remember(key [sha256.Size]byte, expires, now time.Time) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if len(v.cache) >= maxIntrospectionCacheEntries {
+		for k, e := range v.cache {
+			if !now.Before(e) {
+				delete(v.cache, k)
+				v.report(CacheExpired)
+			}
+		}
+	}
+	if len(v.cache) >= maxIntrospectionCacheEntries {
+		for range v.cache {
+			v.report(CacheFull)
+		}
+		v.cache = make(map[[sha256.Size]byte]time.Time)
+	}
+
+	v.cache[key] = expires
+}
+
+func (v *IntrospectionVerifier) introspect(ctx context.Context, token string, out interface{}) error {
+	form := url.Values{
+		"token":           {token},
+		"token_type_hint": {"access_token"},
+	}
+
+	req, err := http.NewRequest(http.MethodPost, v.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(v.clientID), url.QueryEscape(v.clientSecret))
+
+	res, err := v.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", res.StatusCode, v.endpoint)
+	}
+
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+// Verifiers accepts the tokens accepted by any of its verifiers, e.g. the JWTs of an OpenID Connect issuer and the opaque tokens of Okta.
+type Verifiers []Verifier
+
+// Verify returns the error of the last verifier if no verifier accepts the token.
+func (vs Verifiers) Verify(ctx context.Context, token string) error {
+	err := errors.New("no verifier configured")
+	for _, v := range vs {
+		if err = v.Verify(ctx, token); err == nil {
+			return nil
+		}
+	}
+
+	return err
+}
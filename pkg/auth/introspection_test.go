@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestIntrospectionServer returns an Okta authorization server, which knows the tokens of the map.
+func newTestIntrospectionServer(t *testing.T, tokens map[string]map[string]interface{}, requests *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+
+		if r.URL.Path != "/oauth2/default/v1/introspect" || r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if id, secret, ok := r.BasicAuth(); !ok || id != "registry" || secret != "s3cr3t" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		assert.Equal(t, "access_token", r.PostFormValue("token_type_hint"))
+
+		res, ok := tokens[r.PostFormValue("token")]
+		if !ok {
+			res = map[string]interface{}{"active": false}
+		}
+		_ = json.NewEncoder(w).Encode(res)
+	}))
+}
+
+func TestOktaVerifier(t *testing.T) {
+	t.Parallel()
+
+	var requests int32
+	var issuer string
+	claims := func(modify func(map[string]interface{})) map[string]interface{} {
+		c := map[string]interface{}{
+			"active": true,
+			"iss":    issuer,
+			"aud":    "api://default",
+			"exp":    time.Now().Add(time.Hour).Unix(),
+		}
+		if modify != nil {
+			modify(c)
+		}
+		return c
+	}
+
+	tokens := make(map[string]map[string]interface{})
+	server := newTestIntrospectionServer(t, tokens, &requests)
+	defer server.Close()
+	issuer = server.URL + "/oauth2/default"
+
+	tokens["valid"] = claims(nil)
+	tokens["audience list"] = claims(func(c map[string]interface{}) { c["aud"] = []string{"other", "api://default"} })
+	tokens["inactive"] = claims(func(c map[string]interface{}) { c["active"] = false })
+	tokens["other audience"] = claims(func(c map[string]interface{}) { c["aud"] = "other" })
+	tokens["other issuer"] = claims(func(c map[string]interface{}) { c["iss"] = "https://evil.example.com" })
+	tokens["expired"] = claims(func(c map[string]interface{}) { c["exp"] = time.Now().Add(-time.Minute).Unix() })
+
+	verifier := NewOktaVerifier(issuer+"/", "registry", "s3cr3t", "api://default", nil)
+
+	testCases := []struct {
+		name        string
+		token       string
+		expectError bool
+	}{
+		{name: "valid", token: "valid"},
+		{name: "audience list", token: "audience list"},
+		{name: "inactive", token: "inactive", expectError: true},
+		{name: "other audience", token: "other audience", expectError: true},
+		{name: "other issuer", token: "other issuer", expectError: true},
+		{name: "expired", token: "expired", expectError: true},
+		{name: "unknown", token: "unknown", expectError: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			err := verifier.Verify(context.Background(), tc.token)
+			if tc.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	t.Run("wrong client secret", func(t *testing.T) {
+		verifier := NewOktaVerifier(issuer, "registry", "wrong", "api://default", nil)
+		assert.Error(t, verifier.Verify(context.Background(), "valid"))
+	})
+}
+
+func TestIntrospectionVerifier_Cache(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	var requests int32
+	tokens := map[string]map[string]interface{}{
+		"valid": {"active": true, "aud": "api://default", "exp": time.Now().Add(time.Hour).Unix()},
+	}
+	server := newTestIntrospectionServer(t, tokens, &requests)
+	defer server.Close()
+
+	now := time.Now()
+	events := make(map[CacheEvent]int)
+	verifier := NewIntrospectionVerifier(server.URL+"/oauth2/default/v1/introspect", "registry", "s3cr3t", "", "api://default", nil,
+		WithIntrospectionCacheReport(func(event CacheEvent) {
+			events[event]++
+		}),
+	)
+	verifier.now = func() time.Time { return now }
+
+	assert.NoError(verifier.Verify(context.Background(), "valid"))
+	assert.NoError(verifier.Verify(context.Background(), "valid"))
+	assert.Equal(int32(1), atomic.LoadInt32(&requests))
+
+	// Inactive tokens aren't cached
+	assert.Error(verifier.Verify(context.Background(), "unknown"))
+	assert.Error(verifier.Verify(context.Background(), "unknown"))
+	assert.Equal(int32(3), atomic.LoadInt32(&requests))
+
+	// Revoked tokens are rejected once the cache expired
+	delete(tokens, "valid")
+	now = now.Add(introspectionCacheTTL)
+	assert.Error(verifier.Verify(context.Background(), "valid"))
+	assert.Equal(int32(4), atomic.LoadInt32(&requests))
+	assert.Equal(map[CacheEvent]int{CacheHit: 1, CacheMiss: 4, CacheExpired: 1}, events)
+}
+
+func TestVerifiers(t *testing.T) {
+	t.Parallel()
+
+	only := func(accepted string) Verifier {
+		return verifierFunc(func(ctx context.Context, token string) error {
+			if token != accepted {
+				return errors.New("unknown token")
+			}
+			return nil
+		})
+	}
+
+	verifiers := Verifiers{only("jwt"), only("opaque")}
+	assert.NoError(t, verifiers.Verify(context.Background(), "jwt"))
+	assert.NoError(t, verifiers.Verify(context.Background(), "opaque"))
+	assert.Error(t, verifiers.Verify(context.Background(), "other"))
+	assert.Error(t, Verifiers{}.Verify(context.Background(), "jwt"))
+}
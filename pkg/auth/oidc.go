@@ -0,0 +1,350 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// jwksRefreshInterval limits how often the keys of the issuer are fetched again for tokens with unknown key IDs.
+	jwksRefreshInterval = time.Minute
+	// clockSkew is the tolerance for the expiry and not-before times of tokens.
+	clockSkew = time.Minute
+)
+
+// OIDCProvider is the discovery document of an OpenID Connect issuer.
+type OIDCProvider struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// OIDCVerifier verifies JWTs signed by an OpenID Connect issuer.
+// The signing keys are discovered from the issuer and fetched again if a token is signed with an unknown key.
+type OIDCVerifier struct {
+	provider OIDCProvider
+	audience string
+	client   *http.Client
+	now      func() time.Time
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	refreshed time.Time
+}
+
+// NewOIDCVerifier discovers the issuer and returns a verifier of the tokens it issues for the audience.
+// The http.DefaultClient is used if client is nil.
+func NewOIDCVerifier(ctx context.Context, issuer, audience string, client *http.Client) (*OIDCVerifier, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	v := &OIDCVerifier{
+		audience: audience,
+		client:   client,
+		now:      time.Now,
+	}
+
+	if err := v.get(ctx, strings.TrimSuffix(issuer, "/")+"/.well-known/openid-configuration", &v.provider); err != nil {
+		return nil, errors.Wrap(err, "failed to discover issuer")
+	}
+
+	// The issuer of the discovery document has to match exactly, as tokens are checked against it
+	if v.provider.Issuer != issuer {
+		return nil, fmt.Errorf("issuer %q doesn't match the discovered issuer %q", issuer, v.provider.Issuer)
+	}
+
+	if err := v.refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// Provider returns the discovery document of the issuer.
+func (v *OIDCVerifier) Provider() OIDCProvider {
+	return v.provider
+}
+
+// Verify checks the signature, issuer, audience and lifetime of a JWT.
+func (v *OIDCVerifier) Verify(ctx context.Context, token string) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errors.New("token is not a JWT")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return errors.Wrap(err, "invalid header")
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return errors.Wrap(err, "invalid signature")
+	}
+
+	key, err := v.key(ctx, header.Kid)
+	if err != nil {
+		return err
+	}
+
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return err
+	}
+
+	var claims struct {
+		Issuer    string   `json:"iss"`
+		Audience  audience `json:"aud"`
+		Expiry    *int64   `json:"exp"`
+		NotBefore *int64   `json:"nbf"`
+	}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return errors.Wrap(err, "invalid claims")
+	}
+
+	now := v.now()
+	switch {
+	case claims.Issuer != v.provider.Issuer:
+		return fmt.Errorf("unexpected issuer %q", claims.Issuer)
+	case !claims.Audience.contains(v.audience):
+		return fmt.Errorf("token is not issued for audience %q", v.audience)
+	case claims.Expiry == nil:
+		return errors.New("token doesn't expire")
+	case now.After(time.Unix(*claims.Expiry, 0).Add(clockSkew)):
+		return errors.New("token is expired")
+	case claims.NotBefore != nil && now.Before(time.Unix(*claims.NotBefore, 0).Add(-clockSkew)):
+		return errors.New("token is not valid yet")
+	}
+
+	return nil
+}
+
+// key returns the signing key with the given ID, keys are fetched again if the ID is unknown.
+func (v *OIDCVerifier) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if key, ok := v.keys[kid]; ok {
+		return key, nil
+	}
+
+	if v.now().Sub(v.refreshed) < jwksRefreshInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	if err := v.refreshLocked(ctx); err != nil {
+		return nil, err
+	}
+
+	if key, ok := v.keys[kid]; ok {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func (v *OIDCVerifier) refresh(ctx context.Context) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return v.refreshLocked(ctx)
+}
+
+func (v *OIDCVerifier) refreshLocked(ctx context.Context) error {
+	v.refreshed = v.now()
+
+	var jwks struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := v.get(ctx, v.provider.JWKSURI, &jwks); err != nil {
+		return errors.Wrap(err, "failed to fetch signing keys")
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+	for _, k := range jwks.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, err := k.publicKey()
+		if err != nil {
+			// Keys of unsupported types don't prevent the use of the others
+			continue
+		}
+		keys[k.Kid] = key
+	}
+
+	v.keys = keys
+	return nil
+}
+
+func (v *OIDCVerifier) get(ctx context.Context, url string, out interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := v.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", res.StatusCode, url)
+	}
+
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+// jwk is a JSON Web Key of an RSA or elliptic curve public key.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// verifySignature verifies the JWS signature of the signing input with the algorithm of the header.
+// The algorithm has to match the type of the key, which rules out "none" and HMAC algorithms.
+func verifySignature(alg string, key crypto.PublicKey, input string, signature []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+
+	h := hash.New()
+	h.Write([]byte(input))
+	digest := h.Sum(nil)
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") {
+			break
+		}
+		if err := rsa.VerifyPKCS1v15(key, hash, digest, signature); err != nil {
+			return errors.New("invalid signature")
+		}
+		return nil
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if !strings.HasPrefix(alg, "ES") || len(signature) != 2*size {
+			break
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+
+	return fmt.Errorf("algorithm %q doesn't match the signing key", alg)
+}
+
+// audience is the aud claim, which is either a single string or an array of strings.
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+
+	var multiple []string
+	if err := json.Unmarshal(b, &multiple); err != nil {
+		return err
+	}
+	*a = multiple
+	return nil
+}
+
+func (a audience) contains(s string) bool {
+	for _, v := range a {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func decodeSegment(segment string, out interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, out)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
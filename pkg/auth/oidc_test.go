@@ -0,0 +1,265 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/stretchr/testify/assert"
+)
+
+type testIssuer struct {
+	*httptest.Server
+	rsaKey *rsa.PrivateKey
+	ecKey  *ecdsa.PrivateKey
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	issuer := &testIssuer{rsaKey: rsaKey, ecKey: ecKey}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(OIDCProvider{
+			Issuer:                issuer.URL,
+			AuthorizationEndpoint: issuer.URL + "/authorize",
+			TokenEndpoint:         issuer.URL + "/token",
+			JWKSURI:               issuer.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string][]jwk{"keys": {
+			{
+				Kty: "RSA",
+				Kid: "rsa",
+				Use: "sig",
+				N:   base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes()),
+			},
+			{
+				Kty: "EC",
+				Kid: "ec",
+				Crv: "P-256",
+				X:   base64.RawURLEncoding.EncodeToString(ecKey.X.Bytes()),
+				Y:   base64.RawURLEncoding.EncodeToString(ecKey.Y.Bytes()),
+			},
+		}})
+	})
+	issuer.Server = httptest.NewServer(mux)
+
+	return issuer
+}
+
+// sign returns a JWT with the given claims, signed with the key of the algorithm.
+func (i *testIssuer) sign(t *testing.T, alg, kid string, claims map[string]interface{}) string {
+	encode := func(v interface{}) string {
+		b, err := json.Marshal(v)
+		assert.NoError(t, err)
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+
+	input := encode(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"}) + "." + encode(claims)
+	digest := sha256.Sum256([]byte(input))
+
+	var signature []byte
+	switch alg {
+	case "RS256":
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, i.rsaKey, crypto.SHA256, digest[:])
+		assert.NoError(t, err)
+	case "ES256":
+		r, s, err := ecdsa.Sign(rand.Reader, i.ecKey, digest[:])
+		assert.NoError(t, err)
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	}
+
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestOIDCVerifier(t *testing.T) {
+	t.Parallel()
+
+	issuer := newTestIssuer(t)
+	defer issuer.Close()
+
+	verifier, err := NewOIDCVerifier(context.Background(), issuer.URL, "boring-registry", nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, issuer.URL+"/token", verifier.Provider().TokenEndpoint)
+
+	claims := func(modify func(map[string]interface{})) map[string]interface{} {
+		c := map[string]interface{}{
+			"iss": issuer.URL,
+			"aud": "boring-registry",
+			"exp": time.Now().Add(time.Hour).Unix(),
+			"nbf": time.Now().Unix(),
+		}
+		if modify != nil {
+			modify(c)
+		}
+		return c
+	}
+
+	// tamper replaces the claims of a token with claims for another audience
+	tamper := func(token string) string {
+		parts := strings.Split(token, ".")
+		b, _ := json.Marshal(claims(func(c map[string]interface{}) { c["aud"] = "other" }))
+		return parts[0] + "." + base64.RawURLEncoding.EncodeToString(b) + "." + parts[2]
+	}
+
+	testCases := []struct {
+		name        string
+		token       string
+		expectError bool
+	}{
+		{name: "rsa", token: issuer.sign(t, "RS256", "rsa", claims(nil))},
+		{name: "ecdsa", token: issuer.sign(t, "ES256", "ec", claims(nil))},
+		{
+			name:  "audience list",
+			token: issuer.sign(t, "RS256", "rsa", claims(func(c map[string]interface{}) { c["aud"] = []string{"other", "boring-registry"} })),
+		},
+		{
+			name:        "other audience",
+			token:       issuer.sign(t, "RS256", "rsa", claims(func(c map[string]interface{}) { c["aud"] = "other" })),
+			expectError: true,
+		},
+		{
+			name:        "other issuer",
+			token:       issuer.sign(t, "RS256", "rsa", claims(func(c map[string]interface{}) { c["iss"] = "https://evil.example.com" })),
+			expectError: true,
+		},
+		{
+			name:        "expired",
+			token:       issuer.sign(t, "RS256", "rsa", claims(func(c map[string]interface{}) { c["exp"] = time.Now().Add(-time.Hour).Unix() })),
+			expectError: true,
+		},
+		{
+			name:        "without expiry",
+			token:       issuer.sign(t, "RS256", "rsa", claims(func(c map[string]interface{}) { delete(c, "exp") })),
+			expectError: true,
+		},
+		{
+			name:        "not valid yet",
+			token:       issuer.sign(t, "RS256", "rsa", claims(func(c map[string]interface{}) { c["nbf"] = time.Now().Add(time.Hour).Unix() })),
+			expectError: true,
+		},
+		{
+			name:        "algorithm of other key type",
+			token:       issuer.sign(t, "ES256", "rsa", claims(nil)),
+			expectError: true,
+		},
+		{
+			name:        "unknown key",
+			token:       issuer.sign(t, "RS256", "unknown", claims(nil)),
+			expectError: true,
+		},
+		{
+			name:        "unsigned",
+			token:       issuer.sign(t, "none", "rsa", claims(nil)),
+			expectError: true,
+		},
+		{
+			name:        "tampered claims",
+			token:       tamper(issuer.sign(t, "RS256", "rsa", claims(nil))),
+			expectError: true,
+		},
+		{
+			name:        "no JWT",
+			token:       "static-key",
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := verifier.Verify(context.Background(), tc.token)
+			if tc.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestNewOIDCVerifier_IssuerMismatch(t *testing.T) {
+	t.Parallel()
+
+	issuer := newTestIssuer(t)
+	defer issuer.Close()
+
+	_, err := NewOIDCVerifier(context.Background(), issuer.URL+"/", "boring-registry", nil)
+	assert.Error(t, err)
+}
+
+type verifierFunc func(ctx context.Context, token string) error
+
+func (f verifierFunc) Verify(ctx context.Context, token string) error {
+	return f(ctx, token)
+}
+
+func TestTokenMiddleware(t *testing.T) {
+	t.Parallel()
+
+	verifier := verifierFunc(func(ctx context.Context, token string) error {
+		if token != "jwt" {
+			return ErrInvalidKey
+		}
+		return nil
+	})
+
+	next := func(ctx context.Context, request interface{}) (interface{}, error) {
+		return nil, nil
+	}
+
+	testCases := []struct {
+		name          string
+		authorization string
+		keys          []string
+		expectError   bool
+	}{
+		{name: "static key", authorization: "Bearer foo", keys: []string{"foo"}},
+		{name: "token", authorization: "Bearer jwt", keys: []string{"foo"}},
+		{name: "token without keys", authorization: "Bearer jwt"},
+		{name: "invalid token", authorization: "Bearer bar", keys: []string{"foo"}, expectError: true},
+		{name: "missing authorization", expectError: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.WithValue(context.Background(), httptransport.ContextKeyRequestAuthorization, tc.authorization)
+			_, err := TokenMiddleware(verifier, tc.keys...)(next)(ctx, nil)
+			if tc.expectError {
+				assert.Equal(t, ErrInvalidKey, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// SPIFFEID returns the SPIFFE ID of the verified client certificate of a request, which is the URI SAN of an X.509 SVID,
+// or an empty string if the request has no such certificate.
+func SPIFFEID(ctx context.Context) string {
+	state := TLSState(ctx)
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return ""
+	}
+
+	for _, uri := range state.VerifiedChains[0][0].URIs {
+		if uri.Scheme == "spiffe" {
+			return uri.String()
+		}
+	}
+
+	return ""
+}
+
+// SPIFFEAuthenticator accepts requests with an X.509 SVID of the trust domain, e.g. example.org.
+// The SVIDs have to be verified by the TLS server with the trust bundle of the trust domain, see tls.Config.ClientCAs.
+func SPIFFEAuthenticator(trustDomain string) Authenticator {
+	return AuthenticatorFunc(func(ctx context.Context) error {
+		id := SPIFFEID(ctx)
+		if id == "" {
+			return ErrInvalidKey
+		}
+
+		if !strings.HasPrefix(id, "spiffe://"+trustDomain+"/") {
+			return fmt.Errorf("SPIFFE ID %q isn't part of the trust domain %s", id, trustDomain)
+		}
+
+		return nil
+	})
+}
+
+// MatchSPIFFEID reports whether the SPIFFE ID matches the pattern,
+// which is either a SPIFFE ID or a prefix ending in "/*", e.g. spiffe://example.org/ci/*.
+func MatchSPIFFEID(pattern, id string) bool {
+	if prefix := strings.TrimSuffix(pattern, "*"); prefix != pattern && strings.HasSuffix(prefix, "/") {
+		return strings.HasPrefix(id, prefix)
+	}
+
+	return pattern == id
+}
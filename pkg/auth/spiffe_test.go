@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSPIFFEAuthenticator(t *testing.T) {
+	t.Parallel()
+
+	svid := func(id string) *tls.ConnectionState {
+		u, _ := url.Parse(id)
+		return &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{URIs: []*url.URL{u}}}}}
+	}
+
+	testCases := []struct {
+		name        string
+		tls         *tls.ConnectionState
+		expectError bool
+	}{
+		{name: "svid of trust domain", tls: svid("spiffe://example.org/ci/runner")},
+		{name: "svid of other trust domain", tls: svid("spiffe://example.com/ci/runner"), expectError: true},
+		{name: "svid of trust domain prefix", tls: svid("spiffe://example.org.evil/ci/runner"), expectError: true},
+		{name: "certificate without spiffe id", tls: svid("https://example.org/ci"), expectError: true},
+		{name: "unverified svid", tls: &tls.ConnectionState{}, expectError: true},
+		{name: "without tls", expectError: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest("GET", "/", nil)
+			r.TLS = tc.tls
+
+			err := SPIFFEAuthenticator("example.org").Authenticate(PopulateRequestContext(context.Background(), r))
+			assert.Equal(t, tc.expectError, err != nil)
+		})
+	}
+}
+
+func TestMatchSPIFFEID(t *testing.T) {
+	t.Parallel()
+
+	assert := assert.New(t)
+
+	assert.True(MatchSPIFFEID("spiffe://example.org/ci/runner", "spiffe://example.org/ci/runner"))
+	assert.False(MatchSPIFFEID("spiffe://example.org/ci/runner", "spiffe://example.org/ci/runner/2"))
+	assert.True(MatchSPIFFEID("spiffe://example.org/ci/*", "spiffe://example.org/ci/runner"))
+	assert.True(MatchSPIFFEID("spiffe://example.org/ci/*", "spiffe://example.org/ci/team/runner"))
+	assert.False(MatchSPIFFEID("spiffe://example.org/ci/*", "spiffe://example.org/cicd/runner"))
+	assert.False(MatchSPIFFEID("spiffe://example.org/ci*", "spiffe://example.org/cicd"))
+}
@@ -0,0 +1,108 @@
+package mirror
+
+import (
+	"context"
+	"io"
+
+	"github.com/go-kit/kit/endpoint"
+
+	"github.com/TierMobility/boring-registry/pkg/core"
+)
+
+type listVersionsRequest struct {
+	hostname  string
+	namespace string
+	name      string
+}
+
+type listVersionsResponse struct {
+	Versions map[string]struct{} `json:"versions"`
+}
+
+func listVersionsEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(listVersionsRequest)
+
+		res, err := svc.ListVersions(ctx, req.hostname, req.namespace, req.name)
+		if err != nil {
+			return nil, err
+		}
+
+		versions := make(map[string]struct{}, len(res))
+		for _, version := range res {
+			versions[version] = struct{}{}
+		}
+
+		return listVersionsResponse{
+			Versions: versions,
+		}, nil
+	}
+}
+
+type listArchivesRequest struct {
+	hostname  string
+	namespace string
+	name      string
+	version   string
+}
+
+type listArchivesResponse struct {
+	Archives map[string]Archive `json:"archives"`
+}
+
+func listArchivesEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(listArchivesRequest)
+
+		res, err := svc.ListArchives(ctx, req.hostname, req.namespace, req.name, req.version)
+		if err != nil {
+			return nil, err
+		}
+
+		return listArchivesResponse{
+			Archives: res,
+		}, nil
+	}
+}
+
+type archiveRequest struct {
+	provider core.Provider
+}
+
+type archiveResponse struct {
+	body io.ReadCloser
+}
+
+func archiveEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(archiveRequest)
+
+		res, err := svc.GetArchive(ctx, req.provider)
+		if err != nil {
+			return nil, err
+		}
+
+		return archiveResponse{
+			body: res,
+		}, nil
+	}
+}
+
+type statusRequest struct{}
+
+type statusResponse struct {
+	Providers []ProviderStatus `json:"providers"`
+}
+
+func statusEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		res, err := svc.Status(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return statusResponse{
+			Providers: res,
+		}, nil
+	}
+}
@@ -0,0 +1,21 @@
+package mirror
+
+import "errors"
+
+// Storage errors.
+var (
+	ErrNotFound         = errors.New("failed to locate mirrored provider")
+	ErrUploadFailed     = errors.New("failed to store mirrored provider")
+	ErrChecksumMismatch = errors.New("mirrored provider checksum mismatch")
+)
+
+// Upstream errors.
+var (
+	ErrUpstreamFailed = errors.New("failed to query upstream registry")
+)
+
+// Transport errors.
+var (
+	ErrVarMissing     = errors.New("variable missing")
+	ErrInvalidArchive = errors.New("invalid archive name")
+)
@@ -0,0 +1,102 @@
+package mirror
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+
+	"github.com/TierMobility/boring-registry/pkg/core"
+)
+
+// Middleware is a Service middleware.
+type Middleware func(Service) Service
+
+type loggingMiddleware struct {
+	next   Service
+	logger log.Logger
+}
+
+// LoggingMiddleware is a logging Service middleware.
+func LoggingMiddleware(logger log.Logger) Middleware {
+	return func(next Service) Service {
+		return &loggingMiddleware{
+			logger: logger,
+			next:   next,
+		}
+	}
+}
+
+func (mw loggingMiddleware) ListVersions(ctx context.Context, hostname, namespace, name string) (versions []string, err error) {
+	defer func(begin time.Time) {
+		logger := level.Info(mw.logger)
+		if err != nil {
+			logger = level.Error(mw.logger)
+		}
+
+		_ = logger.Log(
+			"op", "ListVersions",
+			"provider", fmt.Sprintf("%s/%s/%s", hostname, namespace, name),
+			"took", time.Since(begin),
+			"err", err,
+		)
+	}(time.Now())
+
+	return mw.next.ListVersions(ctx, hostname, namespace, name)
+}
+
+func (mw loggingMiddleware) ListArchives(ctx context.Context, hostname, namespace, name, version string) (archives map[string]Archive, err error) {
+	defer func(begin time.Time) {
+		logger := level.Info(mw.logger)
+		if err != nil {
+			logger = level.Error(mw.logger)
+		}
+
+		_ = logger.Log(
+			"op", "ListArchives",
+			"provider", fmt.Sprintf("%s/%s/%s/%s", hostname, namespace, name, version),
+			"took", time.Since(begin),
+			"err", err,
+		)
+	}(time.Now())
+
+	return mw.next.ListArchives(ctx, hostname, namespace, name, version)
+}
+
+func (mw loggingMiddleware) GetArchive(ctx context.Context, provider core.Provider) (body io.ReadCloser, err error) {
+	defer func(begin time.Time) {
+		logger := level.Info(mw.logger)
+		if err != nil {
+			logger = level.Error(mw.logger)
+		}
+
+		_ = logger.Log(
+			"op", "GetArchive",
+			"provider", fmt.Sprintf("%s/%s/%s/%s/%s_%s", provider.Hostname, provider.Namespace, provider.Name, provider.Version, provider.OS, provider.Arch),
+			"took", time.Since(begin),
+			"err", err,
+		)
+	}(time.Now())
+
+	return mw.next.GetArchive(ctx, provider)
+}
+
+func (mw loggingMiddleware) Status(ctx context.Context) (status []ProviderStatus, err error) {
+	defer func(begin time.Time) {
+		logger := level.Info(mw.logger)
+		if err != nil {
+			logger = level.Error(mw.logger)
+		}
+
+		_ = logger.Log(
+			"op", "Status",
+			"took", time.Since(begin),
+			"err", err,
+		)
+	}(time.Now())
+
+	return mw.next.Status(ctx)
+}
@@ -0,0 +1,116 @@
+package mirror
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+)
+
+// AllProviders is the provider of policy rules, which apply to providers without rules of their own.
+const AllProviders = "*"
+
+// Policy restricts the versions and platforms mirrored from the upstream registry, which bounds the storage of large providers.
+// Rules are set per provider in the format namespace/name, or for AllProviders. A nil Policy mirrors everything.
+type Policy struct {
+	constraints map[string]version.Constraints
+	platforms   map[string]map[string]bool
+}
+
+// NewPolicy returns a policy without rules.
+func NewPolicy() *Policy {
+	return &Policy{
+		constraints: make(map[string]version.Constraints),
+		platforms:   make(map[string]map[string]bool),
+	}
+}
+
+// SetVersionConstraint only mirrors versions of the provider which match the constraint, e.g. ">= 5.0".
+func (p *Policy) SetVersionConstraint(provider, constraint string) error {
+	key, err := policyKey(provider)
+	if err != nil {
+		return err
+	}
+
+	c, err := version.NewConstraint(constraint)
+	if err != nil {
+		return fmt.Errorf("invalid version constraint %q: %w", constraint, err)
+	}
+
+	p.constraints[key] = c
+	return nil
+}
+
+// SetPlatforms only mirrors the platforms of the provider in the format os_arch, e.g. linux_amd64.
+func (p *Policy) SetPlatforms(provider string, platforms []string) error {
+	key, err := policyKey(provider)
+	if err != nil {
+		return err
+	}
+
+	allowed := make(map[string]bool)
+	for _, platform := range platforms {
+		platform = strings.TrimSpace(platform)
+		if parts := strings.Split(platform, "_"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("invalid platform %q, expected os_arch", platform)
+		}
+		allowed[platform] = true
+	}
+
+	p.platforms[key] = allowed
+	return nil
+}
+
+// AllowsVersion reports whether the version of the provider is mirrored.
+// Versions which can't be parsed never match a constraint.
+func (p *Policy) AllowsVersion(namespace, name, v string) bool {
+	if p == nil {
+		return true
+	}
+
+	c, ok := p.constraints[strings.ToLower(namespace+"/"+name)]
+	if !ok {
+		c, ok = p.constraints[AllProviders]
+	}
+	if !ok {
+		return true
+	}
+
+	parsed, err := version.NewVersion(v)
+	if err != nil {
+		return false
+	}
+
+	return c.Check(parsed)
+}
+
+// AllowsPlatform reports whether the platform of the provider is mirrored.
+func (p *Policy) AllowsPlatform(namespace, name, os, arch string) bool {
+	if p == nil {
+		return true
+	}
+
+	allowed, ok := p.platforms[strings.ToLower(namespace+"/"+name)]
+	if !ok {
+		allowed, ok = p.platforms[AllProviders]
+	}
+	if !ok {
+		return true
+	}
+
+	return allowed[os+"_"+arch]
+}
+
+// policyKey validates the provider of a rule, provider addresses are case-insensitive.
+func policyKey(provider string) (string, error) {
+	if provider == AllProviders {
+		return provider, nil
+	}
+
+	parts := strings.Split(provider, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", fmt.Errorf("invalid provider %q, expected namespace/name or %s", provider, AllProviders)
+	}
+
+	return strings.ToLower(provider), nil
+}
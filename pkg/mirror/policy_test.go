@@ -0,0 +1,97 @@
+package mirror
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/TierMobility/boring-registry/pkg/core"
+)
+
+func TestPolicy(t *testing.T) {
+	t.Parallel()
+
+	policy := NewPolicy()
+	assert.NoError(t, policy.SetVersionConstraint("hashicorp/aws", ">= 5.0"))
+	assert.NoError(t, policy.SetPlatforms("HashiCorp/AWS", []string{"linux_amd64", "darwin_arm64"}))
+	assert.NoError(t, policy.SetPlatforms(AllProviders, []string{"linux_amd64"}))
+
+	testCases := []struct {
+		name      string
+		namespace string
+		provider  string
+		version   string
+		os        string
+		arch      string
+		expected  bool
+	}{
+		{name: "allowed", namespace: "hashicorp", provider: "aws", version: "5.1.0", os: "darwin", arch: "arm64", expected: true},
+		{name: "case-insensitive", namespace: "HashiCorp", provider: "aws", version: "5.0.0", os: "linux", arch: "amd64", expected: true},
+		{name: "old version", namespace: "hashicorp", provider: "aws", version: "4.67.0", os: "linux", arch: "amd64"},
+		{name: "invalid version", namespace: "hashicorp", provider: "aws", version: "latest", os: "linux", arch: "amd64"},
+		{name: "other platform", namespace: "hashicorp", provider: "aws", version: "5.1.0", os: "windows", arch: "amd64"},
+		{name: "default rule", namespace: "hashicorp", provider: "random", version: "3.1.0", os: "linux", arch: "amd64", expected: true},
+		{name: "excluded by default rule", namespace: "hashicorp", provider: "random", version: "3.1.0", os: "darwin", arch: "arm64"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			allowed := policy.AllowsVersion(tc.namespace, tc.provider, tc.version) && policy.AllowsPlatform(tc.namespace, tc.provider, tc.os, tc.arch)
+			assert.Equal(t, tc.expected, allowed)
+		})
+	}
+
+	var none *Policy
+	assert.True(t, none.AllowsVersion("hashicorp", "aws", "1.0.0"))
+	assert.True(t, none.AllowsPlatform("hashicorp", "aws", "windows", "386"))
+}
+
+func TestPolicy_Invalid(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	policy := NewPolicy()
+	assert.Error(policy.SetVersionConstraint("aws", ">= 5.0"))
+	assert.Error(policy.SetVersionConstraint("hashicorp/aws", "five"))
+	assert.Error(policy.SetPlatforms("hashicorp/aws", []string{"linux"}))
+}
+
+func TestService_UpstreamPolicy(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	sum := sha256.Sum256(testArchive)
+	upstream, downloads := newTestUpstream(t, hex.EncodeToString(sum[:]))
+	storage, err := NewLocalStorage(t.TempDir())
+	assert.NoError(err)
+
+	policy := NewPolicy()
+	assert.NoError(policy.SetPlatforms("hashicorp/random", []string{"darwin_arm64"}))
+
+	svc := NewService(storage, WithUpstream(upstream), WithPolicy(policy))
+	ctx := context.Background()
+	host := upstream.Hostname()
+
+	archives, err := svc.ListArchives(ctx, host, "hashicorp", "random", "3.1.0")
+	assert.NoError(err)
+	assert.Equal(map[string]Archive{
+		"darwin_arm64": {URL: "terraform-provider-random_3.1.0_darwin_arm64.zip"},
+	}, archives)
+
+	provider := core.Provider{Hostname: host, Namespace: "hashicorp", Name: "random", Version: "3.1.0", OS: "linux", Arch: "amd64"}
+	_, err = svc.GetArchive(ctx, provider)
+	assert.Equal(ErrNotFound, errors.Cause(err))
+	assert.Equal(int32(0), *downloads)
+
+	// Versions outside of the constraint aren't listed
+	assert.NoError(policy.SetVersionConstraint(AllProviders, ">= 4.0"))
+	_, err = svc.ListVersions(ctx, host, "hashicorp", "random")
+	assert.Equal(ErrNotFound, errors.Cause(err))
+}
@@ -0,0 +1,211 @@
+package mirror
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/TierMobility/boring-registry/pkg/core"
+)
+
+// Service implements the Provider Network Mirror Protocol.
+// For more information see: https://www.terraform.io/docs/internals/provider-network-mirror-protocol.html.
+type Service interface {
+	ListVersions(ctx context.Context, hostname, namespace, name string) ([]string, error)
+	ListArchives(ctx context.Context, hostname, namespace, name, version string) (map[string]Archive, error)
+	GetArchive(ctx context.Context, provider core.Provider) (io.ReadCloser, error)
+	Status(ctx context.Context) ([]ProviderStatus, error)
+}
+
+type service struct {
+	storage  Storage
+	upstream *Upstream
+	policy   *Policy
+	status   *statusTracker
+}
+
+// ServiceOption provides additional options for the Service.
+type ServiceOption func(*service)
+
+// WithUpstream mirrors providers of the upstream registry on their first download.
+// Without an upstream only the providers of the storage are served, e.g. in air-gapped environments.
+func WithUpstream(upstream *Upstream) ServiceOption {
+	return func(s *service) {
+		s.upstream = upstream
+	}
+}
+
+// WithPolicy restricts the versions and platforms mirrored from the upstream registry.
+// Providers which are already mirrored are served regardless of the policy.
+func WithPolicy(policy *Policy) ServiceOption {
+	return func(s *service) {
+		s.policy = policy
+	}
+}
+
+// NewService returns a fully initialized Service.
+func NewService(storage Storage, options ...ServiceOption) Service {
+	s := &service{
+		storage: storage,
+		status:  newStatusTracker(),
+	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	return s
+}
+
+// ListVersions returns the mirrored versions together with those of the upstream registry allowed by the policy.
+// If the upstream registry is unavailable, the mirrored versions are served on their own.
+func (s *service) ListVersions(ctx context.Context, hostname, namespace, name string) ([]string, error) {
+	versions, err := s.storage.ListVersions(ctx, hostname, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !s.mirrors(hostname) {
+		return versions, notFound(len(versions), hostname, namespace, name)
+	}
+
+	upstream, err := s.listUpstreamVersions(ctx, hostname, namespace, name)
+	if err != nil {
+		if len(versions) > 0 {
+			return versions, nil
+		}
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	for _, version := range versions {
+		seen[version] = true
+	}
+
+	for _, version := range upstream {
+		if !seen[version.Version] && s.policy.AllowsVersion(namespace, name, version.Version) {
+			seen[version.Version] = true
+			versions = append(versions, version.Version)
+		}
+	}
+
+	return versions, notFound(len(versions), hostname, namespace, name)
+}
+
+// ListArchives returns the mirrored archives together with the platforms of the upstream registry allowed by the policy,
+// which are mirrored once they are downloaded.
+func (s *service) ListArchives(ctx context.Context, hostname, namespace, name, version string) (map[string]Archive, error) {
+	archives, err := s.storage.ListArchives(ctx, hostname, namespace, name, version)
+	if err != nil {
+		return nil, err
+	}
+
+	if !s.mirrors(hostname) {
+		return archives, notFound(len(archives), hostname, namespace, name, version)
+	}
+
+	upstream, err := s.listUpstreamVersions(ctx, hostname, namespace, name)
+	if err != nil {
+		if len(archives) > 0 {
+			return archives, nil
+		}
+		return nil, err
+	}
+
+	if archives == nil {
+		archives = make(map[string]Archive)
+	}
+
+	for _, v := range upstream {
+		if v.Version != version || !s.policy.AllowsVersion(namespace, name, version) {
+			continue
+		}
+
+		for _, platform := range v.Platforms {
+			if _, ok := archives[platform.OS+"_"+platform.Arch]; ok {
+				continue
+			}
+			if !s.policy.AllowsPlatform(namespace, name, platform.OS, platform.Arch) {
+				continue
+			}
+
+			p := core.Provider{Name: name, Version: version, OS: platform.OS, Arch: platform.Arch}
+			filename, err := p.ArchiveFileName()
+			if err != nil {
+				continue
+			}
+
+			archives[platform.OS+"_"+platform.Arch] = Archive{URL: filename}
+		}
+	}
+
+	return archives, notFound(len(archives), hostname, namespace, name, version)
+}
+
+// GetArchive opens a mirrored archive, archives of the upstream registry are mirrored first if they are missing.
+func (s *service) GetArchive(ctx context.Context, provider core.Provider) (io.ReadCloser, error) {
+	r, err := s.storage.GetArchive(ctx, provider)
+	if err == nil || !errors.Is(err, ErrNotFound) || !s.mirrors(provider.Hostname) {
+		return r, err
+	}
+
+	if !s.policy.AllowsVersion(provider.Namespace, provider.Name, provider.Version) ||
+		!s.policy.AllowsPlatform(provider.Namespace, provider.Name, provider.OS, provider.Arch) {
+		return nil, errors.Wrap(err, "excluded by mirror policy")
+	}
+
+	err = s.mirror(ctx, provider)
+	s.status.observeSync(provider, err)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.storage.GetArchive(ctx, provider)
+}
+
+// mirror stores the archive of the upstream registry.
+func (s *service) mirror(ctx context.Context, provider core.Provider) error {
+	res, err := s.upstream.GetProvider(ctx, provider.Namespace, provider.Name, provider.Version, provider.OS, provider.Arch)
+	if err != nil {
+		return err
+	}
+
+	body, err := s.upstream.Download(ctx, res.DownloadURL)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	return s.storage.UploadArchive(ctx, provider, res.Shasum, body)
+}
+
+// listUpstreamVersions lists the versions of the upstream registry and records the latest version allowed by the policy.
+func (s *service) listUpstreamVersions(ctx context.Context, hostname, namespace, name string) ([]core.ProviderVersion, error) {
+	res, err := s.upstream.ListProviderVersions(ctx, namespace, name)
+
+	var allowed []string
+	for _, v := range res {
+		if s.policy.AllowsVersion(namespace, name, v.Version) {
+			allowed = append(allowed, v.Version)
+		}
+	}
+	s.status.observeVersions(core.Provider{Hostname: hostname, Namespace: namespace, Name: name}, allowed, err)
+
+	return res, err
+}
+
+// mirrors reports whether providers of the hostname are mirrored from the upstream registry.
+func (s *service) mirrors(hostname string) bool {
+	return s.upstream != nil && s.upstream.Hostname() == hostname
+}
+
+// notFound returns ErrNotFound for a provider or version without any mirrored or upstream entries.
+func notFound(entries int, coordinates ...string) error {
+	if entries > 0 {
+		return nil
+	}
+
+	return errors.Wrap(ErrNotFound, strings.Join(coordinates, "/"))
+}
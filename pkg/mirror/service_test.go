@@ -0,0 +1,224 @@
+package mirror
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/TierMobility/boring-registry/pkg/auth"
+	"github.com/TierMobility/boring-registry/pkg/core"
+)
+
+var testArchive = []byte("provider archive")
+
+// newTestUpstream serves hashicorp/random 3.1.0 for linux_amd64 and darwin_arm64 like the public registry.
+func newTestUpstream(t *testing.T, shasum string) (*Upstream, *int32) {
+	var downloads int32
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/providers/hashicorp/random/versions", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"versions": []core.ProviderVersion{{
+				Version:   "3.1.0",
+				Platforms: []core.Platform{{OS: "linux", Arch: "amd64"}, {OS: "darwin", Arch: "arm64"}},
+			}},
+		})
+	})
+	mux.HandleFunc("/v1/providers/hashicorp/random/3.1.0/download/linux/amd64", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(core.Provider{
+			DownloadURL: "/releases/terraform-provider-random_3.1.0_linux_amd64.zip",
+			Shasum:      shasum,
+		})
+	})
+	mux.HandleFunc("/releases/terraform-provider-random_3.1.0_linux_amd64.zip", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&downloads, 1)
+		_, _ = w.Write(testArchive)
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	upstream, err := NewUpstream(server.URL+"/v1/providers/", server.Client())
+	assert.NoError(t, err)
+
+	return upstream, &downloads
+}
+
+func TestService_Upstream(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	sum := sha256.Sum256(testArchive)
+	shasum := hex.EncodeToString(sum[:])
+
+	upstream, downloads := newTestUpstream(t, shasum)
+	storage, err := NewLocalStorage(t.TempDir())
+	assert.NoError(err)
+
+	svc := NewService(storage, WithUpstream(upstream))
+	ctx := context.Background()
+	host := upstream.Hostname()
+
+	versions, err := svc.ListVersions(ctx, host, "hashicorp", "random")
+	assert.NoError(err)
+	assert.Equal([]string{"3.1.0"}, versions)
+
+	archives, err := svc.ListArchives(ctx, host, "hashicorp", "random", "3.1.0")
+	assert.NoError(err)
+	assert.Equal(map[string]Archive{
+		"linux_amd64":  {URL: "terraform-provider-random_3.1.0_linux_amd64.zip"},
+		"darwin_arm64": {URL: "terraform-provider-random_3.1.0_darwin_arm64.zip"},
+	}, archives)
+
+	provider := core.Provider{Hostname: host, Namespace: "hashicorp", Name: "random", Version: "3.1.0", OS: "linux", Arch: "amd64"}
+
+	// The second download is served from the storage
+	for i := 0; i < 2; i++ {
+		r, err := svc.GetArchive(ctx, provider)
+		if assert.NoError(err) {
+			b, err := ioutil.ReadAll(r)
+			assert.NoError(err)
+			assert.Equal(testArchive, b)
+			r.Close()
+		}
+	}
+	assert.Equal(int32(1), atomic.LoadInt32(downloads))
+
+	archives, err = svc.ListArchives(ctx, host, "hashicorp", "random", "3.1.0")
+	assert.NoError(err)
+	assert.Equal([]string{"zh:" + shasum}, archives["linux_amd64"].Hashes)
+
+	// Other hostnames are only served from the storage
+	_, err = svc.ListVersions(ctx, "registry.example.com", "hashicorp", "random")
+	assert.Equal(ErrNotFound, errors.Cause(err))
+}
+
+func TestService_UpstreamChecksumMismatch(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	upstream, _ := newTestUpstream(t, "0000000000000000000000000000000000000000000000000000000000000000")
+	storage, err := NewLocalStorage(t.TempDir())
+	assert.NoError(err)
+
+	svc := NewService(storage, WithUpstream(upstream))
+	provider := core.Provider{Hostname: upstream.Hostname(), Namespace: "hashicorp", Name: "random", Version: "3.1.0", OS: "linux", Arch: "amd64"}
+
+	_, err = svc.GetArchive(context.Background(), provider)
+	assert.Equal(ErrChecksumMismatch, errors.Cause(err))
+
+	// Archives failing verification are never served
+	_, err = storage.GetArchive(context.Background(), provider)
+	assert.Equal(ErrNotFound, errors.Cause(err))
+}
+
+func TestService_UpstreamUnavailable(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	upstream, err := NewUpstream(server.URL+"/v1/providers/", server.Client())
+	assert.NoError(err)
+
+	storage, err := NewLocalStorage(t.TempDir())
+	assert.NoError(err)
+
+	provider := core.Provider{Hostname: upstream.Hostname(), Namespace: "hashicorp", Name: "random", Version: "3.0.0", OS: "linux", Arch: "amd64"}
+	assert.NoError(storage.UploadArchive(context.Background(), provider, "", bytes.NewReader(testArchive)))
+
+	svc := NewService(storage, WithUpstream(upstream))
+
+	versions, err := svc.ListVersions(context.Background(), upstream.Hostname(), "hashicorp", "random")
+	assert.NoError(err)
+	assert.Equal([]string{"3.0.0"}, versions)
+
+	_, err = svc.ListVersions(context.Background(), upstream.Hostname(), "hashicorp", "null")
+	assert.Equal(ErrUpstreamFailed, errors.Cause(err))
+}
+
+func TestMakeHandler(t *testing.T) {
+	t.Parallel()
+
+	storage, err := NewLocalStorage(t.TempDir())
+	assert.NoError(t, err)
+
+	provider := core.Provider{Hostname: "registry.terraform.io", Namespace: "hashicorp", Name: "random", Version: "3.1.0", OS: "linux", Arch: "amd64"}
+	assert.NoError(t, storage.UploadArchive(context.Background(), provider, "", bytes.NewReader(testArchive)))
+
+	sum := sha256.Sum256(testArchive)
+
+	handler := MakeHandler(
+		NewService(storage),
+		auth.Middleware(),
+		httptransport.ServerErrorEncoder(ErrorEncoder),
+	)
+
+	testCases := []struct {
+		name           string
+		path           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "versions",
+			path:           "/registry.terraform.io/hashicorp/random/index.json",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"versions":{"3.1.0":{}}}`,
+		},
+		{
+			name:           "archives",
+			path:           "/registry.terraform.io/hashicorp/random/3.1.0.json",
+			expectedStatus: http.StatusOK,
+			expectedBody:   fmt.Sprintf(`{"archives":{"linux_amd64":{"url":"terraform-provider-random_3.1.0_linux_amd64.zip","hashes":["zh:%x"]}}}`, sum),
+		},
+		{
+			name:           "archive",
+			path:           "/registry.terraform.io/hashicorp/random/terraform-provider-random_3.1.0_linux_amd64.zip",
+			expectedStatus: http.StatusOK,
+			expectedBody:   string(testArchive),
+		},
+		{
+			name:           "unknown provider",
+			path:           "/registry.terraform.io/hashicorp/null/index.json",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "archive of another provider",
+			path:           "/registry.terraform.io/hashicorp/random/terraform-provider-null_3.1.0_linux_amd64.zip",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+			assert.Equal(tc.expectedStatus, rec.Code, fmt.Sprintf("body: %s", rec.Body))
+			if tc.expectedBody != "" {
+				assert.Equal(tc.expectedBody, strings.TrimSpace(rec.Body.String()))
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package mirror
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-version"
+	"github.com/pkg/errors"
+
+	"github.com/TierMobility/boring-registry/pkg/core"
+)
+
+// ProviderStatus is the state of a provider mirrored from the upstream registry.
+type ProviderStatus struct {
+	// Provider is the address of the provider in the format hostname/namespace/name.
+	Provider string `json:"provider"`
+	// LastSync is when an archive of the provider was last mirrored from the upstream.
+	LastSync time.Time `json:"last_sync"`
+	// UpstreamLatest is the latest version of the upstream allowed by the policy, as of the last request for the provider.
+	UpstreamLatest string `json:"upstream_latest,omitempty"`
+	MirroredLatest string `json:"mirrored_latest,omitempty"`
+	// Behind is set if the latest version of the upstream isn't mirrored yet.
+	Behind bool `json:"behind"`
+	// Failures is the number of consecutive failures to query or mirror from the upstream.
+	Failures    int       `json:"failures"`
+	LastFailure time.Time `json:"last_failure"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+// providerKey identifies a provider independent of its versions and platforms.
+type providerKey struct {
+	hostname, namespace, name string
+}
+
+// statusTracker tracks the state of the providers requested from the upstream since the start of the server.
+type statusTracker struct {
+	mu        sync.Mutex
+	providers map[providerKey]*ProviderStatus
+	now       func() time.Time
+}
+
+func newStatusTracker() *statusTracker {
+	return &statusTracker{
+		providers: make(map[providerKey]*ProviderStatus),
+		now:       time.Now,
+	}
+}
+
+// observeVersions records the outcome of listing the upstream versions of a provider.
+// Providers unknown to the upstream aren't tracked, as they can't fall behind.
+func (t *statusTracker) observeVersions(p core.Provider, versions []string, err error) {
+	if errors.Is(err, ErrNotFound) {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	status := t.get(p)
+	if err != nil {
+		t.fail(status, err)
+		return
+	}
+
+	status.Failures = 0
+	if latest := latestVersion(versions); latest != "" {
+		status.UpstreamLatest = latest
+	}
+}
+
+// observeSync records the outcome of mirroring an archive of a provider from the upstream.
+func (t *statusTracker) observeSync(p core.Provider, err error) {
+	if errors.Is(err, ErrNotFound) {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	status := t.get(p)
+	if err != nil {
+		t.fail(status, err)
+		return
+	}
+
+	status.Failures = 0
+	status.LastSync = t.now().UTC()
+}
+
+func (t *statusTracker) get(p core.Provider) *ProviderStatus {
+	key := providerKey{hostname: p.Hostname, namespace: p.Namespace, name: p.Name}
+
+	status, ok := t.providers[key]
+	if !ok {
+		status = &ProviderStatus{Provider: fmt.Sprintf("%s/%s/%s", p.Hostname, p.Namespace, p.Name)}
+		t.providers[key] = status
+	}
+
+	return status
+}
+
+func (t *statusTracker) fail(status *ProviderStatus, err error) {
+	status.Failures++
+	status.LastFailure = t.now().UTC()
+	status.LastError = err.Error()
+}
+
+// snapshot returns copies of the tracked states together with their providers, ordered by provider.
+func (t *statusTracker) snapshot() ([]providerKey, []ProviderStatus) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	providers := make([]providerKey, 0, len(t.providers))
+	for p := range t.providers {
+		providers = append(providers, p)
+	}
+	sort.Slice(providers, func(i, j int) bool {
+		return t.providers[providers[i]].Provider < t.providers[providers[j]].Provider
+	})
+
+	res := make([]ProviderStatus, 0, len(providers))
+	for _, p := range providers {
+		res = append(res, *t.providers[p])
+	}
+
+	return providers, res
+}
+
+// Status returns the state of the providers requested from the upstream since the start of the server.
+func (s *service) Status(ctx context.Context) ([]ProviderStatus, error) {
+	providers, res := s.status.snapshot()
+
+	for i, p := range providers {
+		versions, err := s.storage.ListVersions(ctx, p.hostname, p.namespace, p.name)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+
+		res[i].MirroredLatest = latestVersion(versions)
+		res[i].Behind = res[i].UpstreamLatest != "" && versionLess(res[i].MirroredLatest, res[i].UpstreamLatest)
+	}
+
+	return res, nil
+}
+
+// latestVersion returns the latest version which isn't a pre-release, or an empty string if there is none.
+func latestVersion(versions []string) string {
+	var latest *version.Version
+	for _, raw := range versions {
+		v, err := version.NewVersion(raw)
+		if err != nil || v.Prerelease() != "" {
+			continue
+		}
+		if latest == nil || v.GreaterThan(latest) {
+			latest = v
+		}
+	}
+
+	if latest == nil {
+		return ""
+	}
+
+	return latest.Original()
+}
+
+// versionLess reports whether the version a is lower than b, an empty version is lower than any other.
+func versionLess(a, b string) bool {
+	if a == "" {
+		return b != ""
+	}
+
+	va, err := version.NewVersion(a)
+	if err != nil {
+		return true
+	}
+	vb, err := version.NewVersion(b)
+	if err != nil {
+		return false
+	}
+
+	return va.LessThan(vb)
+}
@@ -0,0 +1,130 @@
+package mirror
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/TierMobility/boring-registry/pkg/auth"
+	"github.com/TierMobility/boring-registry/pkg/core"
+)
+
+func TestService_Status(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	sum := sha256.Sum256(testArchive)
+	upstream, _ := newTestUpstream(t, hex.EncodeToString(sum[:]))
+	storage, err := NewLocalStorage(t.TempDir())
+	assert.NoError(err)
+
+	now := time.Date(2024, 5, 13, 9, 0, 0, 0, time.UTC)
+	svc := NewService(storage, WithUpstream(upstream)).(*service)
+	svc.status.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	host := upstream.Hostname()
+	random := core.Provider{Hostname: host, Namespace: "hashicorp", Name: "random", Version: "3.1.0", OS: "linux", Arch: "amd64"}
+
+	_, err = svc.ListVersions(ctx, host, "hashicorp", "random")
+	assert.NoError(err)
+
+	// The test upstream doesn't serve the darwin_arm64 download
+	_, err = svc.GetArchive(ctx, core.Provider{Hostname: host, Namespace: "hashicorp", Name: "random", Version: "3.1.0", OS: "darwin", Arch: "arm64"})
+	assert.Error(err)
+
+	// Providers unknown to the upstream aren't tracked
+	_, err = svc.ListVersions(ctx, host, "hashicorp", "null")
+	assert.Error(err)
+
+	status, err := svc.Status(ctx)
+	assert.NoError(err)
+	assert.Equal([]ProviderStatus{{
+		Provider:       host + "/hashicorp/random",
+		UpstreamLatest: "3.1.0",
+		Behind:         true,
+	}}, status)
+
+	r, err := svc.GetArchive(ctx, random)
+	if assert.NoError(err) {
+		r.Close()
+	}
+
+	status, err = svc.Status(ctx)
+	assert.NoError(err)
+	assert.Equal([]ProviderStatus{{
+		Provider:       host + "/hashicorp/random",
+		LastSync:       now,
+		UpstreamLatest: "3.1.0",
+		MirroredLatest: "3.1.0",
+	}}, status)
+}
+
+func TestService_StatusFailures(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	upstream, err := NewUpstream(server.URL+"/v1/providers/", server.Client())
+	assert.NoError(err)
+	storage, err := NewLocalStorage(t.TempDir())
+	assert.NoError(err)
+
+	svc := NewService(storage, WithUpstream(upstream))
+	for i := 0; i < 2; i++ {
+		_, err = svc.ListVersions(context.Background(), upstream.Hostname(), "hashicorp", "random")
+		assert.Error(err)
+	}
+
+	status, err := svc.Status(context.Background())
+	assert.NoError(err)
+	if assert.Len(status, 1) {
+		assert.Equal(2, status[0].Failures)
+		assert.Contains(status[0].LastError, "502 Bad Gateway")
+		assert.False(status[0].Behind)
+	}
+}
+
+func TestMakeHandler_Status(t *testing.T) {
+	t.Parallel()
+
+	storage, err := NewLocalStorage(t.TempDir())
+	assert.NoError(t, err)
+
+	handler := MakeHandler(
+		NewService(storage),
+		auth.Middleware("secret"),
+		httptransport.ServerBefore(httptransport.PopulateRequestContext),
+		httptransport.ServerErrorEncoder(ErrorEncoder),
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/status", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `{"providers":[]}`, strings.TrimSpace(rec.Body.String()))
+}
+
+func TestLatestVersion(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "5.10.0", latestVersion([]string{"5.9.0", "5.10.0", "6.0.0-beta1", "invalid"}))
+	assert.Equal(t, "", latestVersion([]string{"6.0.0-beta1"}))
+	assert.True(t, versionLess("", "1.0.0"))
+	assert.False(t, versionLess("1.0.0", ""))
+	assert.True(t, versionLess("5.9.0", "5.10.0"))
+}
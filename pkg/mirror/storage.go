@@ -0,0 +1,30 @@
+package mirror
+
+import (
+	"context"
+	"io"
+
+	"github.com/TierMobility/boring-registry/pkg/core"
+)
+
+// Archive is a mirrored archive of a provider for one platform.
+type Archive struct {
+	// URL is relative to the URL of the version, as the archives are served by the mirror itself.
+	URL    string   `json:"url"`
+	Hashes []string `json:"hashes,omitempty"`
+}
+
+// Storage represents the Storage of mirrored Terraform providers.
+type Storage interface {
+	// ListVersions returns the mirrored versions of a provider.
+	ListVersions(ctx context.Context, hostname, namespace, name string) ([]string, error)
+
+	// ListArchives returns the mirrored archives of a provider version keyed by their platform, e.g. linux_amd64.
+	ListArchives(ctx context.Context, hostname, namespace, name, version string) (map[string]Archive, error)
+
+	// GetArchive opens a mirrored archive.
+	GetArchive(ctx context.Context, provider core.Provider) (io.ReadCloser, error)
+
+	// UploadArchive stores an archive, which must match the SHA256 sum if it isn't empty.
+	UploadArchive(ctx context.Context, provider core.Provider, shasum string, body io.Reader) error
+}
@@ -0,0 +1,207 @@
+package mirror
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/TierMobility/boring-registry/pkg/core"
+)
+
+// shasumExtension is the extension of the files next to the archives holding their SHA256 sum.
+const shasumExtension = ".sha256"
+
+// LocalStorage is a Storage implementation backed by a directory on the local disk.
+// It uses the packed layout of `terraform providers mirror`, HOSTNAME/NAMESPACE/NAME/terraform-provider-NAME_VERSION_OS_ARCH.zip,
+// so a directory populated by Terraform can be served as is.
+type LocalStorage struct {
+	dir string
+}
+
+// ListVersions returns the mirrored versions of a provider.
+func (s *LocalStorage) ListVersions(ctx context.Context, hostname, namespace, name string) ([]string, error) {
+	providers, err := s.list(hostname, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var versions []string
+	for _, p := range providers {
+		if !seen[p.Version] {
+			seen[p.Version] = true
+			versions = append(versions, p.Version)
+		}
+	}
+
+	return versions, nil
+}
+
+// ListArchives returns the mirrored archives of a provider version.
+// Archives mirrored from an upstream registry carry the hash of their verified SHA256 sum.
+func (s *LocalStorage) ListArchives(ctx context.Context, hostname, namespace, name, version string) (map[string]Archive, error) {
+	providers, err := s.list(hostname, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	archives := make(map[string]Archive)
+	for _, p := range providers {
+		if p.Version != version {
+			continue
+		}
+
+		archive := Archive{URL: p.Filename}
+		if shasum, err := ioutil.ReadFile(filepath.Join(s.dir, hostname, namespace, name, p.Filename+shasumExtension)); err == nil {
+			archive.Hashes = []string{"zh:" + strings.TrimSpace(string(shasum))}
+		}
+
+		archives[p.OS+"_"+p.Arch] = archive
+	}
+
+	return archives, nil
+}
+
+// GetArchive opens a mirrored archive.
+func (s *LocalStorage) GetArchive(ctx context.Context, provider core.Provider) (io.ReadCloser, error) {
+	p, err := s.path(provider)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(p)
+	if os.IsNotExist(err) {
+		return nil, errors.Wrap(ErrNotFound, provider.Filename)
+	} else if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// UploadArchive stores an archive, it only becomes visible once it is complete and matches the SHA256 sum.
+func (s *LocalStorage) UploadArchive(ctx context.Context, provider core.Provider, shasum string, body io.Reader) error {
+	p, err := s.path(provider)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return errors.Wrap(ErrUploadFailed, err.Error())
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(p), ".tmp-")
+	if err != nil {
+		return errors.Wrap(ErrUploadFailed, err.Error())
+	}
+	defer os.Remove(f.Name())
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, h), body); err != nil {
+		f.Close()
+		return errors.Wrap(ErrUploadFailed, err.Error())
+	}
+
+	if err := f.Close(); err != nil {
+		return errors.Wrap(ErrUploadFailed, err.Error())
+	}
+
+	sum := hex.EncodeToString(h.Sum(nil))
+	if shasum != "" && !strings.EqualFold(sum, shasum) {
+		return errors.Wrapf(ErrChecksumMismatch, "%s has SHA256 sum %s, expected %s", provider.Filename, sum, shasum)
+	}
+
+	if err := os.Chmod(f.Name(), 0o644); err != nil {
+		return errors.Wrap(ErrUploadFailed, err.Error())
+	}
+
+	// The sum is written first, so a listed archive never lacks its hash
+	if err := ioutil.WriteFile(p+shasumExtension, []byte(sum+"\n"), 0o644); err != nil {
+		return errors.Wrap(ErrUploadFailed, err.Error())
+	}
+
+	if err := os.Rename(f.Name(), p); err != nil {
+		return errors.Wrap(ErrUploadFailed, err.Error())
+	}
+
+	return nil
+}
+
+// list returns the archives of a provider sorted by their file name.
+func (s *LocalStorage) list(hostname, namespace, name string) ([]core.Provider, error) {
+	dir, err := s.dirOf(hostname, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var providers []core.Provider
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), core.ProviderExtension) {
+			continue
+		}
+
+		p, err := core.NewProviderFromArchive(entry.Name())
+		if err != nil || p.Name != name {
+			continue
+		}
+
+		providers = append(providers, p)
+	}
+
+	sort.Slice(providers, func(i, j int) bool { return providers[i].Filename < providers[j].Filename })
+
+	return providers, nil
+}
+
+// path returns the path of an archive on the local disk.
+func (s *LocalStorage) path(provider core.Provider) (string, error) {
+	dir, err := s.dirOf(provider.Hostname, provider.Namespace, provider.Name)
+	if err != nil {
+		return "", err
+	}
+
+	filename, err := provider.ArchiveFileName()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, filename), nil
+}
+
+// dirOf returns the directory of a provider, it rejects coordinates which would escape the storage directory.
+func (s *LocalStorage) dirOf(hostname, namespace, name string) (string, error) {
+	for _, segment := range []string{hostname, namespace, name} {
+		if segment == "" || segment == "." || segment == ".." || strings.ContainsAny(segment, `/\`) {
+			return "", fmt.Errorf("invalid provider %s/%s/%s", hostname, namespace, name)
+		}
+	}
+
+	return filepath.Join(s.dir, hostname, namespace, name), nil
+}
+
+// NewLocalStorage returns a fully initialized local storage.
+func NewLocalStorage(dir string) (*LocalStorage, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, errors.Wrap(err, "failed to create mirror directory")
+	}
+
+	return &LocalStorage{
+		dir: dir,
+	}, nil
+}
@@ -0,0 +1,209 @@
+package mirror
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+
+	"github.com/TierMobility/boring-registry/pkg/auth"
+	"github.com/TierMobility/boring-registry/pkg/core"
+)
+
+type muxVar string
+
+const (
+	varHostname  muxVar = "hostname"
+	varNamespace muxVar = "namespace"
+	varName      muxVar = "name"
+	varVersion   muxVar = "version"
+	varArchive   muxVar = "archive"
+)
+
+// MakeHandler returns a fully initialized http.Handler.
+func MakeHandler(svc Service, auth endpoint.Middleware, options ...httptransport.ServerOption) http.Handler {
+	r := mux.NewRouter().StrictSlash(true)
+
+	r.Methods("GET").Path(`/status`).Handler(
+		httptransport.NewServer(
+			auth(statusEndpoint(svc)),
+			decodeStatusRequest,
+			httptransport.EncodeJSONResponse,
+			options...,
+		),
+	)
+
+	r.Methods("GET").Path(`/{hostname}/{namespace}/{name}/index.json`).Handler(
+		httptransport.NewServer(
+			auth(listVersionsEndpoint(svc)),
+			decodeListVersionsRequest,
+			httptransport.EncodeJSONResponse,
+			append(
+				options,
+				httptransport.ServerBefore(extractMuxVars(varHostname, varNamespace, varName)),
+			)...,
+		),
+	)
+
+	r.Methods("GET").Path(`/{hostname}/{namespace}/{name}/{version}.json`).Handler(
+		httptransport.NewServer(
+			auth(listArchivesEndpoint(svc)),
+			decodeListArchivesRequest,
+			httptransport.EncodeJSONResponse,
+			append(
+				options,
+				httptransport.ServerBefore(extractMuxVars(varHostname, varNamespace, varName, varVersion)),
+			)...,
+		),
+	)
+
+	r.Methods("GET").Path(`/{hostname}/{namespace}/{name}/{archive}`).Handler(
+		httptransport.NewServer(
+			auth(archiveEndpoint(svc)),
+			decodeArchiveRequest,
+			encodeArchiveResponse,
+			append(
+				options,
+				httptransport.ServerBefore(extractMuxVars(varHostname, varNamespace, varName, varArchive)),
+			)...,
+		),
+	)
+
+	return r
+}
+
+func decodeStatusRequest(_ context.Context, _ *http.Request) (interface{}, error) {
+	return statusRequest{}, nil
+}
+
+func decodeListVersionsRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	hostname, namespace, name, err := providerVars(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return listVersionsRequest{
+		hostname:  hostname,
+		namespace: namespace,
+		name:      name,
+	}, nil
+}
+
+func decodeListArchivesRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	hostname, namespace, name, err := providerVars(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	version, ok := ctx.Value(varVersion).(string)
+	if !ok {
+		return nil, errors.Wrap(ErrVarMissing, "version")
+	}
+
+	return listArchivesRequest{
+		hostname:  hostname,
+		namespace: namespace,
+		name:      name,
+		version:   version,
+	}, nil
+}
+
+func decodeArchiveRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	hostname, namespace, name, err := providerVars(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	archive, ok := ctx.Value(varArchive).(string)
+	if !ok {
+		return nil, errors.Wrap(ErrVarMissing, "archive")
+	}
+
+	if !strings.HasPrefix(archive, core.ProviderPrefix) || !strings.HasSuffix(archive, core.ProviderExtension) {
+		return nil, errors.Wrap(ErrInvalidArchive, archive)
+	}
+
+	provider, err := core.NewProviderFromArchive(archive)
+	if err != nil || provider.Name != name {
+		return nil, errors.Wrap(ErrInvalidArchive, archive)
+	}
+
+	provider.Hostname = hostname
+	provider.Namespace = namespace
+
+	return archiveRequest{
+		provider: provider,
+	}, nil
+}
+
+func providerVars(ctx context.Context) (string, string, string, error) {
+	hostname, ok := ctx.Value(varHostname).(string)
+	if !ok {
+		return "", "", "", errors.Wrap(ErrVarMissing, "hostname")
+	}
+
+	namespace, ok := ctx.Value(varNamespace).(string)
+	if !ok {
+		return "", "", "", errors.Wrap(ErrVarMissing, "namespace")
+	}
+
+	name, ok := ctx.Value(varName).(string)
+	if !ok {
+		return "", "", "", errors.Wrap(ErrVarMissing, "name")
+	}
+
+	return hostname, namespace, name, nil
+}
+
+func encodeArchiveResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(archiveResponse)
+	defer res.body.Close()
+
+	w.Header().Set("Content-Type", "application/zip")
+	_, err := io.Copy(w, res.body)
+	return err
+}
+
+// ErrorEncoder translates domain specific errors to HTTP status codes.
+func ErrorEncoder(_ context.Context, err error, w http.ResponseWriter) {
+	status := http.StatusInternalServerError
+	switch errors.Cause(err) {
+	case ErrVarMissing:
+		status = http.StatusBadRequest
+	case ErrNotFound, ErrInvalidArchive:
+		status = http.StatusNotFound
+	case auth.ErrInvalidKey:
+		status = http.StatusUnauthorized
+	case auth.ErrForbidden:
+		status = http.StatusForbidden
+	case ErrUpstreamFailed, ErrChecksumMismatch:
+		status = http.StatusBadGateway
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+
+	_ = json.NewEncoder(w).Encode(struct {
+		Error string `json:"error"`
+	}{
+		Error: err.Error(),
+	})
+}
+
+func extractMuxVars(keys ...muxVar) httptransport.RequestFunc {
+	return func(ctx context.Context, r *http.Request) context.Context {
+		for _, k := range keys {
+			if v, ok := mux.Vars(r)[string(k)]; ok {
+				ctx = context.WithValue(ctx, k, v)
+			}
+		}
+
+		return ctx
+	}
+}
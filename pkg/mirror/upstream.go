@@ -0,0 +1,139 @@
+package mirror
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/TierMobility/boring-registry/pkg/core"
+)
+
+// DefaultUpstreamURL is the provider API of the public Terraform registry.
+const DefaultUpstreamURL = "https://registry.terraform.io/v1/providers/"
+
+// Upstream is a registry implementing the Provider Registry Protocol, which providers are mirrored from.
+type Upstream struct {
+	hostname string
+	url      string
+	client   *http.Client
+}
+
+// NewUpstream returns an upstream for the provider API URL of a registry, e.g. DefaultUpstreamURL.
+// Only providers requested with the hostname of the URL are mirrored from it.
+func NewUpstream(rawURL string, client *http.Client) (*Upstream, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid upstream URL %q, expected an absolute URL", rawURL)
+	}
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &Upstream{
+		hostname: u.Hostname(),
+		url:      strings.TrimSuffix(u.String(), "/") + "/",
+		client:   client,
+	}, nil
+}
+
+// Hostname returns the hostname of the providers mirrored from the upstream.
+func (u *Upstream) Hostname() string {
+	return u.hostname
+}
+
+// ListProviderVersions lists the versions of a provider and their platforms.
+func (u *Upstream) ListProviderVersions(ctx context.Context, namespace, name string) ([]core.ProviderVersion, error) {
+	var res struct {
+		Versions []core.ProviderVersion `json:"versions"`
+	}
+
+	if err := u.getJSON(ctx, fmt.Sprintf("%s%s/%s/versions", u.url, namespace, name), &res); err != nil {
+		return nil, err
+	}
+
+	return res.Versions, nil
+}
+
+// GetProvider returns the download URL and SHA256 sum of the archive of a provider version for a platform.
+func (u *Upstream) GetProvider(ctx context.Context, namespace, name, version, os, arch string) (core.Provider, error) {
+	var res core.Provider
+
+	if err := u.getJSON(ctx, fmt.Sprintf("%s%s/%s/%s/download/%s/%s", u.url, namespace, name, version, os, arch), &res); err != nil {
+		return core.Provider{}, err
+	}
+
+	if res.DownloadURL == "" {
+		return core.Provider{}, errors.Wrapf(ErrUpstreamFailed, "no download URL for %s/%s %s %s_%s", namespace, name, version, os, arch)
+	}
+
+	// The download URL can be relative to the download endpoint
+	base, err := url.Parse(fmt.Sprintf("%s%s/%s/%s/download/%s/%s", u.url, namespace, name, version, os, arch))
+	if err != nil {
+		return core.Provider{}, err
+	}
+	downloadURL, err := base.Parse(res.DownloadURL)
+	if err != nil {
+		return core.Provider{}, errors.Wrap(ErrUpstreamFailed, err.Error())
+	}
+	res.DownloadURL = downloadURL.String()
+
+	return res, nil
+}
+
+// Download downloads an archive.
+func (u *Upstream) Download(ctx context.Context, downloadURL string) (io.ReadCloser, error) {
+	res, err := u.get(ctx, downloadURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Body, nil
+}
+
+func (u *Upstream) getJSON(ctx context.Context, rawURL string, v interface{}) error {
+	res, err := u.get(ctx, rawURL)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return errors.Wrap(ErrUpstreamFailed, err.Error())
+	}
+
+	return nil
+}
+
+func (u *Upstream) get(ctx context.Context, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := u.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(ErrUpstreamFailed, err.Error())
+	}
+
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		res.Body.Close()
+		return nil, errors.Wrap(ErrNotFound, rawURL)
+	case res.StatusCode != http.StatusOK:
+		res.Body.Close()
+		return nil, errors.Wrapf(ErrUpstreamFailed, "%s responded with %s", rawURL, res.Status)
+	}
+
+	return res, nil
+}
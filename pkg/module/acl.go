@@ -0,0 +1,92 @@
+package module
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/TierMobility/boring-registry/pkg/auth"
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+)
+
+// ACL restricts read access of modules to a set of API keys.
+// It is keyed by "namespace/name/provider", modules without an entry are readable by every client.
+type ACL map[string][]string
+
+func (acl ACL) allowed(namespace, name, provider string, authorization interface{}) bool {
+	keys, ok := acl[fmt.Sprintf("%s/%s/%s", namespace, name, provider)]
+	if !ok {
+		return true
+	}
+
+	for _, key := range keys {
+		if fmt.Sprintf("Bearer %s", key) == authorization {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ACLMiddleware enforces the ACL on the endpoints of modules.
+func ACLMiddleware(acl ACL) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			var namespace, name, provider string
+
+			switch req := request.(type) {
+			case listRequest:
+				namespace, name, provider = req.namespace, req.name, req.provider
+			case downloadRequest:
+				namespace, name, provider = req.namespace, req.name, req.provider
+			case docsRequest:
+				namespace, name, provider = req.namespace, req.name, req.provider
+			case annotationsRequest:
+				namespace, name, provider = req.namespace, req.name, req.provider
+			case labelsRequest:
+				namespace, name, provider = req.namespace, req.name, req.provider
+			case maturityRequest:
+				namespace, name, provider = req.namespace, req.name, req.provider
+			case changesRequest:
+				// The report of a namespace only contains the modules readable by the client
+				authorization := ctx.Value(httptransport.ContextKeyRequestAuthorization)
+				req.filter = func(m Module) bool {
+					return acl.allowed(m.Namespace, m.Name, m.Provider, authorization)
+				}
+				return next(ctx, req)
+			case modulesRequest:
+				// The listing of a namespace only contains the modules readable by the client
+				authorization := ctx.Value(httptransport.ContextKeyRequestAuthorization)
+				req.filter = func(m Module) bool {
+					return acl.allowed(m.Namespace, m.Name, m.Provider, authorization)
+				}
+				return next(ctx, req)
+			case searchRequest:
+				// Search results only contain the modules readable by the client
+				authorization := ctx.Value(httptransport.ContextKeyRequestAuthorization)
+				req.filter = andFilter(req.filter, func(m Module) bool {
+					return acl.allowed(m.Namespace, m.Name, m.Provider, authorization)
+				})
+				return next(ctx, req)
+			case feedRequest:
+				if req.name == "" {
+					// The feed of a namespace only contains the modules readable by the client
+					authorization := ctx.Value(httptransport.ContextKeyRequestAuthorization)
+					req.filter = func(m Module) bool {
+						return acl.allowed(m.Namespace, m.Name, m.Provider, authorization)
+					}
+					return next(ctx, req)
+				}
+				namespace, name, provider = req.namespace, req.name, req.provider
+			default:
+				return next(ctx, request)
+			}
+
+			if !acl.allowed(namespace, name, provider, ctx.Value(httptransport.ContextKeyRequestAuthorization)) {
+				return nil, auth.ErrForbidden
+			}
+
+			return next(ctx, request)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package module
+
+import (
+	"context"
+	"testing"
+
+	"github.com/TierMobility/boring-registry/pkg/auth"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestACLMiddleware(t *testing.T) {
+	t.Parallel()
+
+	acl := ACL{
+		"tier/secret/aws": {"team-a", "team-b"},
+	}
+
+	testCases := []struct {
+		name        string
+		token       string
+		request     interface{}
+		expectError bool
+	}{
+		{
+			name:    "unrestricted module",
+			token:   "other",
+			request: listRequest{namespace: "tier", name: "public", provider: "aws"},
+		},
+		{
+			name:    "listed token",
+			token:   "team-b",
+			request: listRequest{namespace: "tier", name: "secret", provider: "aws"},
+		},
+		{
+			name:        "unlisted token on versions",
+			token:       "other",
+			request:     listRequest{namespace: "tier", name: "secret", provider: "aws"},
+			expectError: true,
+		},
+		{
+			name:        "unlisted token on download",
+			token:       "other",
+			request:     downloadRequest{namespace: "tier", name: "secret", provider: "aws", version: "1.0.0"},
+			expectError: true,
+		},
+		{
+			name:        "missing token",
+			request:     downloadRequest{namespace: "tier", name: "secret", provider: "aws", version: "1.0.0"},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			if tc.token != "" {
+				ctx = context.WithValue(ctx, httptransport.ContextKeyRequestAuthorization, "Bearer "+tc.token)
+			}
+
+			_, err := ACLMiddleware(acl)(func(context.Context, interface{}) (interface{}, error) {
+				return nil, nil
+			})(ctx, tc.request)
+
+			if tc.expectError {
+				assert.Equal(t, auth.ErrForbidden, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package module
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+)
+
+// maxAddressPartLength is the maximum length of the namespace, name and provider of a module address.
+const maxAddressPartLength = 64
+
+var (
+	// namePattern matches namespaces and names of the Module Registry Protocol.
+	namePattern = regexp.MustCompile(`^[0-9A-Za-z](?:[0-9A-Za-z_-]*[0-9A-Za-z])?$`)
+	// providerPattern matches providers, which Terraform only resolves in lowercase.
+	providerPattern = regexp.MustCompile(`^[0-9a-z]+$`)
+)
+
+// reservedNames are the literal path segments of the registry API,
+// which are not allowed as namespace, name or provider to keep module addresses distinguishable from API paths.
+var reservedNames = map[string]bool{
+	"versions":    true,
+	"releases":    true,
+	"download":    true,
+	"annotations": true,
+	"approve":     true,
+	"archive":     true,
+	"labels":      true,
+	"maturity":    true,
+}
+
+// FieldError is a violation of the module address grammar by a single field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every field of a module address which violates the address grammar.
+type ValidationError struct {
+	Fields []FieldError `json:"fields"`
+}
+
+func (e *ValidationError) Error() string {
+	violations := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		violations = append(violations, fmt.Sprintf("%s %s", f.Field, f.Message))
+	}
+
+	return fmt.Sprintf("%s: %s", ErrInvalidAddress, strings.Join(violations, "; "))
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrInvalidAddress
+}
+
+func (e *ValidationError) Cause() error {
+	return ErrInvalidAddress
+}
+
+// check records a violation of field if rule rejects value.
+func (e *ValidationError) check(field, value string, rule func(string) string) {
+	if msg := rule(value); msg != "" {
+		e.Fields = append(e.Fields, FieldError{Field: field, Message: msg})
+	}
+}
+
+func (e *ValidationError) errorOrNil() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+// ValidateAddress validates a module address against the address grammar.
+// The returned error is a *ValidationError listing all violations.
+func ValidateAddress(namespace, name, provider, version string) error {
+	v := &ValidationError{}
+	v.check("namespace", namespace, validateName)
+	v.check("name", name, validateName)
+	v.check("provider", provider, validateProvider)
+	v.check("version", version, validateVersion)
+
+	return v.errorOrNil()
+}
+
+// validateModuleAddress validates the address of a module without version.
+func validateModuleAddress(namespace, name, provider string) error {
+	v := &ValidationError{}
+	v.check("namespace", namespace, validateName)
+	v.check("name", name, validateName)
+	v.check("provider", provider, validateProvider)
+
+	return v.errorOrNil()
+}
+
+func validateName(s string) string {
+	switch {
+	case s == "":
+		return "must not be empty"
+	case len(s) > maxAddressPartLength:
+		return fmt.Sprintf("must not be longer than %d characters", maxAddressPartLength)
+	case !namePattern.MatchString(s):
+		return "must only contain letters, digits, dashes and underscores, and start and end with a letter or digit"
+	case reservedNames[strings.ToLower(s)]:
+		return fmt.Sprintf("must not be the reserved name %q", s)
+	}
+
+	return ""
+}
+
+func validateProvider(s string) string {
+	switch {
+	case s == "":
+		return "must not be empty"
+	case len(s) > maxAddressPartLength:
+		return fmt.Sprintf("must not be longer than %d characters", maxAddressPartLength)
+	case strings.ToLower(s) != s:
+		return "must be lowercase"
+	case !providerPattern.MatchString(s):
+		return "must only contain letters and digits"
+	case reservedNames[s]:
+		return fmt.Sprintf("must not be the reserved name %q", s)
+	}
+
+	return ""
+}
+
+func validateVersion(s string) string {
+	switch {
+	case s == "":
+		return "must not be empty"
+	case strings.HasPrefix(s, "v"):
+		return "must not have a \"v\" prefix"
+	}
+
+	if _, err := version.NewSemver(s); err != nil {
+		return "must be a semantic version such as 1.2.3"
+	}
+
+	return ""
+}
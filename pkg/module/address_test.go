@@ -0,0 +1,135 @@
+package module
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateAddress(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		namespace string
+		module    string
+		provider  string
+		version   string
+		expected  []FieldError
+	}{
+		{name: "valid", namespace: "tier", module: "s3-bucket_v2", provider: "aws", version: "1.0.0"},
+		{name: "mixed case names", namespace: "Tier", module: "S3", provider: "aws", version: "1.0.0-rc.1"},
+		{
+			name: "empty",
+			expected: []FieldError{
+				{Field: "namespace", Message: "must not be empty"},
+				{Field: "name", Message: "must not be empty"},
+				{Field: "provider", Message: "must not be empty"},
+				{Field: "version", Message: "must not be empty"},
+			},
+		},
+		{
+			name:      "invalid characters",
+			namespace: "../tier",
+			module:    "s3-",
+			provider:  "aws",
+			version:   "1.0.0",
+			expected: []FieldError{
+				{Field: "namespace", Message: "must only contain letters, digits, dashes and underscores, and start and end with a letter or digit"},
+				{Field: "name", Message: "must only contain letters, digits, dashes and underscores, and start and end with a letter or digit"},
+			},
+		},
+		{
+			name:      "uppercase provider",
+			namespace: "tier",
+			module:    "s3",
+			provider:  "AWS",
+			version:   "1.0.0",
+			expected:  []FieldError{{Field: "provider", Message: "must be lowercase"}},
+		},
+		{
+			name:      "provider with dash",
+			namespace: "tier",
+			module:    "s3",
+			provider:  "aws-cn",
+			version:   "1.0.0",
+			expected:  []FieldError{{Field: "provider", Message: "must only contain letters and digits"}},
+		},
+		{
+			name:      "reserved name",
+			namespace: "tier",
+			module:    "Versions",
+			provider:  "aws",
+			version:   "1.0.0",
+			expected:  []FieldError{{Field: "name", Message: `must not be the reserved name "Versions"`}},
+		},
+		{
+			name:      "version prefix",
+			namespace: "tier",
+			module:    "s3",
+			provider:  "aws",
+			version:   "v1.0.0",
+			expected:  []FieldError{{Field: "version", Message: `must not have a "v" prefix`}},
+		},
+		{
+			name:      "invalid version",
+			namespace: "tier",
+			module:    "s3",
+			provider:  "aws",
+			version:   "latest",
+			expected:  []FieldError{{Field: "version", Message: "must be a semantic version such as 1.2.3"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+
+			err := ValidateAddress(tc.namespace, tc.module, tc.provider, tc.version)
+			if tc.expected == nil {
+				assert.NoError(err)
+				return
+			}
+
+			var validationErr *ValidationError
+			assert.True(errors.Is(err, ErrInvalidAddress))
+			if assert.True(errors.As(err, &validationErr)) {
+				assert.Equal(tc.expected, validationErr.Fields)
+			}
+		})
+	}
+}
+
+func TestErrorEncoder_ValidationError(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	ctx := context.WithValue(context.Background(), varNamespace, "tier")
+	ctx = context.WithValue(ctx, varName, "s3")
+	ctx = context.WithValue(ctx, varProvider, "AWS")
+	ctx = context.WithValue(ctx, varVersion, "latest")
+
+	_, err := decodeDownloadRequest(ctx, httptest.NewRequest(http.MethodGet, "/tier/s3/AWS/latest/download", nil))
+
+	rec := httptest.NewRecorder()
+	ErrorEncoder(ctx, err, rec)
+
+	var body struct {
+		Error  string       `json:"error"`
+		Fields []FieldError `json:"fields"`
+	}
+	assert.Equal(http.StatusBadRequest, rec.Code)
+	assert.NoError(json.NewDecoder(rec.Body).Decode(&body))
+	assert.Equal("invalid module address: provider must be lowercase; version must be a semantic version such as 1.2.3", body.Error)
+	assert.Equal([]FieldError{
+		{Field: "provider", Message: "must be lowercase"},
+		{Field: "version", Message: "must be a semantic version such as 1.2.3"},
+	}, body.Fields)
+}
@@ -0,0 +1,79 @@
+package module
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/TierMobility/boring-registry/pkg/auth"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/pkg/errors"
+)
+
+// maxAnnotationLength limits the length of the text of an annotation.
+const maxAnnotationLength = 4096
+
+// Annotation is a note attached to a module version, e.g. about known issues or approvals.
+type Annotation struct {
+	Text      string    `json:"text"`
+	Author    string    `json:"author,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (a Annotation) validate() error {
+	if strings.TrimSpace(a.Text) == "" {
+		return errors.Wrap(ErrInvalidAnnotation, "text must not be empty")
+	}
+
+	if len(a.Text) > maxAnnotationLength || len(a.Author) > maxAnnotationLength {
+		return errors.Wrapf(ErrInvalidAnnotation, "text and author must not be longer than %d bytes", maxAnnotationLength)
+	}
+
+	return nil
+}
+
+// annotationPrefix returns the prefix of the annotations of a module version.
+// Annotations are kept apart from the module archives, so listing module versions doesn't pick them up.
+func annotationPrefix(prefix, namespace, name, provider, version string) string {
+	return path.Join(
+		prefix,
+		"annotations",
+		fmt.Sprintf("namespace=%s", namespace),
+		fmt.Sprintf("name=%s", name),
+		fmt.Sprintf("provider=%s", provider),
+		fmt.Sprintf("version=%s", version),
+	) + "/"
+}
+
+// annotationPath returns the path of an annotation, which sorts annotations by their creation time.
+func annotationPath(prefix, namespace, name, provider, version string, a Annotation) string {
+	return fmt.Sprintf("%s%020d.json", annotationPrefix(prefix, namespace, name, provider, version), a.CreatedAt.UnixNano())
+}
+
+type annotatorMiddleware struct {
+	Service
+	keys []string
+}
+
+// AnnotatorMiddleware only allows clients with one of the given API keys to annotate module versions.
+// Without keys, annotations can't be added at all.
+func AnnotatorMiddleware(keys []string) Middleware {
+	return func(next Service) Service {
+		return &annotatorMiddleware{
+			Service: next,
+			keys:    keys,
+		}
+	}
+}
+
+func (mw *annotatorMiddleware) AddAnnotation(ctx context.Context, namespace, name, provider, version string, annotation Annotation) (Annotation, error) {
+	for _, key := range mw.keys {
+		if fmt.Sprintf("Bearer %s", key) == ctx.Value(httptransport.ContextKeyRequestAuthorization) {
+			return mw.Service.AddAnnotation(ctx, namespace, name, provider, version, annotation)
+		}
+	}
+
+	return Annotation{}, auth.ErrForbidden
+}
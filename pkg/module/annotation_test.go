@@ -0,0 +1,68 @@
+package module
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/TierMobility/boring-registry/pkg/auth"
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnnotations(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	storage := NewInmemStorage()
+	_, err := storage.UploadModule(context.Background(), "tier", "vpc", "aws", "1.0.0", strings.NewReader("data"))
+	assert.NoError(err)
+
+	handler := MakeHandler(
+		AnnotatorMiddleware([]string{"security"})(NewService(storage)),
+		endpoint.Chain(auth.Middleware("security", "reader")),
+		httptransport.ServerBefore(httptransport.PopulateRequestContext),
+		httptransport.ServerErrorEncoder(ErrorEncoder),
+	)
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	testCases := []struct {
+		name         string
+		path         string
+		token        string
+		body         string
+		expectedCode int
+	}{
+		{name: "reader can't annotate", path: "/tier/vpc/aws/1.0.0/annotations", token: "reader", body: `{"text": "approved"}`, expectedCode: http.StatusForbidden},
+		{name: "empty text", path: "/tier/vpc/aws/1.0.0/annotations", token: "security", body: `{"text": " "}`, expectedCode: http.StatusBadRequest},
+		{name: "invalid body", path: "/tier/vpc/aws/1.0.0/annotations", token: "security", body: `{`, expectedCode: http.StatusBadRequest},
+		{name: "unknown version", path: "/tier/vpc/aws/2.0.0/annotations", token: "security", body: `{"text": "approved"}`, expectedCode: http.StatusNotFound},
+		{name: "annotator", path: "/tier/vpc/aws/1.0.0/annotations", token: "security", body: `{"text": "approved by security", "author": "jane"}`, expectedCode: http.StatusCreated},
+	}
+
+	for _, tc := range testCases {
+		rec := do(http.MethodPost, tc.path, tc.token, tc.body)
+		assert.Equal(tc.expectedCode, rec.Code, tc.name)
+	}
+
+	rec := do(http.MethodGet, "/tier/vpc/aws/1.0.0/annotations", "reader", "")
+	assert.Equal(http.StatusOK, rec.Code)
+
+	var res annotationsResponse
+	assert.NoError(json.NewDecoder(rec.Body).Decode(&res))
+	assert.Len(res.Annotations, 1)
+	assert.Equal("approved by security", res.Annotations[0].Text)
+	assert.Equal("jane", res.Annotations[0].Author)
+	assert.False(res.Annotations[0].CreatedAt.IsZero())
+}
@@ -0,0 +1,170 @@
+package module
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// Kinds of anomalies.
+const (
+	AnomalyUnexpectedCIDR = "unexpected_cidr"
+	AnomalyVersionSpike   = "version_spike"
+)
+
+// Anomaly is unusual activity of a module, which might be an attack on the supply chain.
+type Anomaly struct {
+	Kind       string    `json:"kind"`
+	Namespace  string    `json:"namespace"`
+	Name       string    `json:"name"`
+	Provider   string    `json:"provider"`
+	Version    string    `json:"version,omitempty"`
+	ClientIP   string    `json:"client_ip,omitempty"`
+	Detail     string    `json:"detail"`
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+type anomalyMiddleware struct {
+	Service
+	report func(Anomaly)
+
+	cidrs             []*net.IPNet
+	trustForwardedFor bool
+	spikeVersions     int
+	spikeWindow       time.Duration
+	interval          time.Duration
+	now               func() time.Time
+
+	mu       sync.Mutex
+	reported map[string]time.Time
+}
+
+// AnomalyOption provides additional options for the AnomalyMiddleware.
+type AnomalyOption func(*anomalyMiddleware)
+
+// WithAnomalyExpectedCIDRs reports downloads by clients outside of the given networks.
+func WithAnomalyExpectedCIDRs(cidrs []*net.IPNet) AnomalyOption {
+	return func(mw *anomalyMiddleware) {
+		mw.cidrs = cidrs
+	}
+}
+
+// WithAnomalyTrustForwardedFor uses the first address of the X-Forwarded-For header as client address, e.g. behind a load balancer.
+func WithAnomalyTrustForwardedFor(trust bool) AnomalyOption {
+	return func(mw *anomalyMiddleware) {
+		mw.trustForwardedFor = trust
+	}
+}
+
+// WithAnomalyVersionSpike reports modules with more than the given number of versions created within the window.
+func WithAnomalyVersionSpike(versions int, window time.Duration) AnomalyOption {
+	return func(mw *anomalyMiddleware) {
+		mw.spikeVersions = versions
+		mw.spikeWindow = window
+	}
+}
+
+// WithAnomalyInterval configures how long the same anomaly isn't reported again.
+func WithAnomalyInterval(interval time.Duration) AnomalyOption {
+	return func(mw *anomalyMiddleware) {
+		mw.interval = interval
+	}
+}
+
+// AnomalyMiddleware reports unusual activity with the report function without changing any response.
+// Every anomaly is only reported once per interval, which defaults to an hour.
+func AnomalyMiddleware(report func(Anomaly), options ...AnomalyOption) Middleware {
+	return func(next Service) Service {
+		mw := &anomalyMiddleware{
+			Service:  next,
+			report:   report,
+			interval: time.Hour,
+			now:      time.Now,
+			reported: make(map[string]time.Time),
+		}
+
+		for _, option := range options {
+			option(mw)
+		}
+
+		return mw
+	}
+}
+
+func (mw *anomalyMiddleware) GetModule(ctx context.Context, namespace, name, provider, version string) (Module, error) {
+	res, err := mw.Service.GetModule(ctx, namespace, name, provider, version)
+	if err != nil || len(mw.cidrs) == 0 {
+		return res, err
+	}
+
+	ip := clientIP(ctx, mw.trustForwardedFor)
+	if ip == nil || containsIP(mw.cidrs, ip) {
+		return res, nil
+	}
+
+	mw.reportOnce(ip.String(), Anomaly{
+		Kind:      AnomalyUnexpectedCIDR,
+		Namespace: namespace,
+		Name:      name,
+		Provider:  provider,
+		Version:   version,
+		ClientIP:  ip.String(),
+		Detail:    fmt.Sprintf("download by %s outside of the expected networks", ip),
+	})
+
+	return res, nil
+}
+
+func (mw *anomalyMiddleware) ListModuleVersions(ctx context.Context, namespace, name, provider string) ([]Module, error) {
+	res, err := mw.Service.ListModuleVersions(ctx, namespace, name, provider)
+	if err != nil || mw.spikeVersions <= 0 {
+		return res, err
+	}
+
+	since := mw.now().Add(-mw.spikeWindow)
+
+	var created int
+	for _, module := range res {
+		if module.Created.After(since) {
+			created++
+		}
+	}
+
+	if created > mw.spikeVersions {
+		mw.reportOnce(fmt.Sprintf("%s/%s/%s", namespace, name, provider), Anomaly{
+			Kind:      AnomalyVersionSpike,
+			Namespace: namespace,
+			Name:      name,
+			Provider:  provider,
+			Detail:    fmt.Sprintf("%d versions created within %s", created, mw.spikeWindow),
+		})
+	}
+
+	return res, nil
+}
+
+// reportOnce reports an anomaly unless the same kind of anomaly of the subject was reported within the interval.
+func (mw *anomalyMiddleware) reportOnce(subject string, anomaly Anomaly) {
+	now := mw.now()
+	key := anomaly.Kind + "/" + subject
+
+	mw.mu.Lock()
+	if last, ok := mw.reported[key]; ok && now.Sub(last) < mw.interval {
+		mw.mu.Unlock()
+		return
+	}
+	mw.reported[key] = now
+
+	// Drop expired entries, so clients with changing addresses don't grow the map forever
+	for k, last := range mw.reported {
+		if now.Sub(last) >= mw.interval {
+			delete(mw.reported, k)
+		}
+	}
+	mw.mu.Unlock()
+
+	anomaly.DetectedAt = now
+	mw.report(anomaly)
+}
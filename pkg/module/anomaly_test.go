@@ -0,0 +1,120 @@
+package module
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnomalyMiddleware_UnexpectedCIDR(t *testing.T) {
+	t.Parallel()
+
+	storage := NewInmemStorage()
+	_, err := storage.UploadModule(context.Background(), "tier", "test", "aws", "1.0.0", strings.NewReader("data"))
+	assert.NoError(t, err)
+
+	_, office, _ := net.ParseCIDR("10.0.0.0/8")
+
+	testCases := []struct {
+		name              string
+		remoteAddr        string
+		forwardedFor      string
+		trustForwardedFor bool
+		expected          []string
+	}{
+		{
+			name:       "expected network",
+			remoteAddr: "10.1.2.3:51234",
+		},
+		{
+			name:       "unexpected network",
+			remoteAddr: "203.0.113.7:51234",
+			expected:   []string{"203.0.113.7", "203.0.113.7"},
+		},
+		{
+			name:         "untrusted forwarded for",
+			remoteAddr:   "10.1.2.3:51234",
+			forwardedFor: "203.0.113.7",
+		},
+		{
+			name:              "trusted forwarded for",
+			remoteAddr:        "10.1.2.3:51234",
+			forwardedFor:      "203.0.113.7, 10.1.2.3",
+			trustForwardedFor: true,
+			expected:          []string{"203.0.113.7", "203.0.113.7"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+
+			var (
+				mu       sync.Mutex
+				reported []string
+				now      = time.Now()
+			)
+
+			svc := AnomalyMiddleware(func(a Anomaly) {
+				mu.Lock()
+				defer mu.Unlock()
+				assert.Equal(AnomalyUnexpectedCIDR, a.Kind)
+				reported = append(reported, a.ClientIP)
+			},
+				WithAnomalyExpectedCIDRs([]*net.IPNet{office}),
+				WithAnomalyTrustForwardedFor(tc.trustForwardedFor),
+			)(NewService(storage))
+			svc.(*anomalyMiddleware).now = func() time.Time { return now }
+
+			ctx := context.WithValue(context.Background(), httptransport.ContextKeyRequestRemoteAddr, tc.remoteAddr)
+			ctx = context.WithValue(ctx, httptransport.ContextKeyRequestXForwardedFor, tc.forwardedFor)
+
+			// The second download is within the interval and not reported again
+			for _, elapsed := range []time.Duration{0, time.Minute, 2 * time.Hour} {
+				now = now.Add(elapsed)
+				_, err := svc.GetModule(ctx, "tier", "test", "aws", "1.0.0")
+				assert.NoError(err)
+			}
+
+			assert.Equal(tc.expected, reported)
+		})
+	}
+}
+
+func TestAnomalyMiddleware_VersionSpike(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	storage := NewInmemStorage()
+	for _, version := range []string{"1.0.0", "1.0.1", "1.0.2"} {
+		_, err := storage.UploadModule(context.Background(), "tier", "test", "aws", version, strings.NewReader("data"))
+		assert.NoError(err)
+	}
+
+	var reported []Anomaly
+	report := func(a Anomaly) { reported = append(reported, a) }
+
+	svc := AnomalyMiddleware(report, WithAnomalyVersionSpike(3, time.Hour))(NewService(storage))
+	_, err := svc.ListModuleVersions(context.Background(), "tier", "test", "aws")
+	assert.NoError(err)
+	assert.Empty(reported)
+
+	_, err = storage.UploadModule(context.Background(), "tier", "test", "aws", "1.0.3", strings.NewReader("data"))
+	assert.NoError(err)
+
+	modules, err := svc.ListModuleVersions(context.Background(), "tier", "test", "aws")
+	assert.NoError(err)
+	assert.Len(modules, 4)
+	if assert.Len(reported, 1) {
+		assert.Equal(AnomalyVersionSpike, reported[0].Kind)
+		assert.Equal("4 versions created within 1h0m0s", reported[0].Detail)
+	}
+}
@@ -0,0 +1,195 @@
+package module
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/TierMobility/boring-registry/pkg/auth"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/pkg/errors"
+)
+
+// Approval marks a module version as approved for a namespace that requires approvals.
+type Approval struct {
+	Version    string    `json:"version"`
+	Approver   string    `json:"approver,omitempty"`
+	ApprovedAt time.Time `json:"approved_at"`
+}
+
+// approvalPrefix returns the prefix of the approvals of a module.
+// Approvals are kept apart from the module archives, so listing module versions doesn't pick them up.
+func approvalPrefix(prefix, namespace, name, provider string) string {
+	return path.Join(
+		prefix,
+		"approvals",
+		fmt.Sprintf("namespace=%s", namespace),
+		fmt.Sprintf("name=%s", name),
+		fmt.Sprintf("provider=%s", provider),
+	) + "/"
+}
+
+// approvalPath returns the path of the approval of a module version.
+// The version is part of the path, which allows to list the approved versions without reading every approval.
+func approvalPath(prefix, namespace, name, provider, version string) string {
+	return fmt.Sprintf("%sversion=%s", approvalPrefix(prefix, namespace, name, provider), version)
+}
+
+// approvedVersion returns the version of an approval path.
+func approvedVersion(key string) string {
+	return strings.TrimPrefix(path.Base(key), "version=")
+}
+
+type approvalMiddleware struct {
+	Service
+	namespaces map[string]bool
+	keys       []string
+}
+
+// ApprovalMiddleware hides module versions of the given namespaces until they are approved.
+// Only clients with one of the given API keys can see pending versions and approve them.
+func ApprovalMiddleware(namespaces, keys []string) Middleware {
+	return func(next Service) Service {
+		mw := &approvalMiddleware{
+			Service:    next,
+			namespaces: make(map[string]bool),
+			keys:       keys,
+		}
+		for _, namespace := range namespaces {
+			mw.namespaces[namespace] = true
+		}
+		return mw
+	}
+}
+
+func (mw *approvalMiddleware) ListModuleVersions(ctx context.Context, namespace, name, provider string) ([]Module, error) {
+	res, err := mw.Service.ListModuleVersions(ctx, namespace, name, provider)
+	if err != nil || !mw.namespaces[namespace] || mw.approver(ctx) {
+		return res, err
+	}
+
+	approved, err := mw.approved(ctx, namespace, name, provider)
+	if err != nil {
+		return nil, err
+	}
+
+	var modules []Module
+	for _, module := range res {
+		if approved[module.Version] {
+			modules = append(modules, module)
+		}
+	}
+
+	return modules, nil
+}
+
+func (mw *approvalMiddleware) ListModules(ctx context.Context, namespace string) ([]Module, error) {
+	res, err := mw.Service.ListModules(ctx, namespace)
+	if err != nil || !mw.namespaces[namespace] || mw.approver(ctx) {
+		return res, err
+	}
+
+	var (
+		modules  []Module
+		approved = make(map[string]map[string]bool)
+	)
+
+	for _, module := range res {
+		key := module.Name + "/" + module.Provider
+		if _, ok := approved[key]; !ok {
+			if approved[key], err = mw.approved(ctx, namespace, module.Name, module.Provider); err != nil {
+				return nil, err
+			}
+		}
+
+		if approved[key][module.Version] {
+			modules = append(modules, module)
+		}
+	}
+
+	return modules, nil
+}
+
+func (mw *approvalMiddleware) GetModule(ctx context.Context, namespace, name, provider, version string) (Module, error) {
+	res, err := mw.Service.GetModule(ctx, namespace, name, provider, version)
+	if err != nil || !mw.namespaces[namespace] || mw.approver(ctx) {
+		return res, err
+	}
+
+	approved, err := mw.approved(ctx, namespace, name, provider)
+	if err != nil {
+		return Module{}, err
+	}
+
+	if !approved[version] {
+		return Module{}, errors.Wrap(ErrNotFound, "version pending approval")
+	}
+
+	return res, nil
+}
+
+func (mw *approvalMiddleware) AddAnnotation(ctx context.Context, namespace, name, provider, version string, annotation Annotation) (Annotation, error) {
+	if _, err := mw.GetModule(ctx, namespace, name, provider, version); err != nil {
+		return Annotation{}, err
+	}
+
+	return mw.Service.AddAnnotation(ctx, namespace, name, provider, version, annotation)
+}
+
+func (mw *approvalMiddleware) ListAnnotations(ctx context.Context, namespace, name, provider, version string) ([]Annotation, error) {
+	if _, err := mw.GetModule(ctx, namespace, name, provider, version); err != nil {
+		return nil, err
+	}
+
+	return mw.Service.ListAnnotations(ctx, namespace, name, provider, version)
+}
+
+func (mw *approvalMiddleware) SetMaturity(ctx context.Context, namespace, name, provider, version string, maturity Maturity, reason string) (VersionMaturity, error) {
+	if _, err := mw.GetModule(ctx, namespace, name, provider, version); err != nil {
+		return VersionMaturity{}, err
+	}
+
+	return mw.Service.SetMaturity(ctx, namespace, name, provider, version, maturity, reason)
+}
+
+func (mw *approvalMiddleware) GetDocs(ctx context.Context, namespace, name, provider, version string) (Docs, error) {
+	if _, err := mw.GetModule(ctx, namespace, name, provider, version); err != nil {
+		return Docs{}, err
+	}
+
+	return mw.Service.GetDocs(ctx, namespace, name, provider, version)
+}
+
+func (mw *approvalMiddleware) ApproveModule(ctx context.Context, namespace, name, provider, version, approver string) (Approval, error) {
+	if !mw.approver(ctx) {
+		return Approval{}, auth.ErrForbidden
+	}
+
+	return mw.Service.ApproveModule(ctx, namespace, name, provider, version, approver)
+}
+
+func (mw *approvalMiddleware) approved(ctx context.Context, namespace, name, provider string) (map[string]bool, error) {
+	approvals, err := mw.Service.ListApprovals(ctx, namespace, name, provider)
+	if err != nil {
+		return nil, err
+	}
+
+	approved := make(map[string]bool)
+	for _, approval := range approvals {
+		approved[approval.Version] = true
+	}
+
+	return approved, nil
+}
+
+func (mw *approvalMiddleware) approver(ctx context.Context) bool {
+	for _, key := range mw.keys {
+		if fmt.Sprintf("Bearer %s", key) == ctx.Value(httptransport.ContextKeyRequestAuthorization) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,88 @@
+package module
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/TierMobility/boring-registry/pkg/auth"
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApprovals(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	storage := NewInmemStorage()
+	for _, m := range []struct{ namespace, version string }{
+		{"regulated", "1.0.0"},
+		{"regulated", "1.1.0"},
+		{"tier", "1.0.0"},
+	} {
+		_, err := storage.UploadModule(context.Background(), m.namespace, "vpc", "aws", m.version, strings.NewReader("data"))
+		assert.NoError(err)
+	}
+
+	handler := MakeHandler(
+		ApprovalMiddleware([]string{"regulated"}, []string{"approver"})(NewService(storage)),
+		endpoint.Chain(auth.Middleware("approver", "reader")),
+		httptransport.ServerBefore(httptransport.PopulateRequestContext),
+		httptransport.ServerErrorEncoder(ErrorEncoder),
+	)
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	versions := func(path, token string) []string {
+		rec := do(http.MethodGet, path, token, "")
+		assert.Equal(http.StatusOK, rec.Code)
+
+		var res listResponse
+		assert.NoError(json.NewDecoder(rec.Body).Decode(&res))
+
+		var versions []string
+		for _, m := range res.Modules {
+			for _, v := range m.Versions {
+				versions = append(versions, v.Version)
+			}
+		}
+		return versions
+	}
+
+	assert.Len(versions("/regulated/vpc/aws/versions", "reader"), 0)
+	assert.Len(versions("/regulated/vpc/aws/versions", "approver"), 2)
+	assert.Len(versions("/tier/vpc/aws/versions", "reader"), 1)
+	assert.Equal(http.StatusNotFound, do(http.MethodGet, "/regulated/vpc/aws/1.0.0/download", "reader", "").Code)
+
+	testCases := []struct {
+		name         string
+		path         string
+		token        string
+		body         string
+		expectedCode int
+	}{
+		{name: "reader can't approve", path: "/regulated/vpc/aws/1.0.0/approve", token: "reader", expectedCode: http.StatusForbidden},
+		{name: "unknown version", path: "/regulated/vpc/aws/2.0.0/approve", token: "approver", expectedCode: http.StatusNotFound},
+		{name: "invalid body", path: "/regulated/vpc/aws/1.0.0/approve", token: "approver", body: `{`, expectedCode: http.StatusBadRequest},
+		{name: "approver", path: "/regulated/vpc/aws/1.0.0/approve", token: "approver", body: `{"approver": "jane"}`, expectedCode: http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		rec := do(http.MethodPost, tc.path, tc.token, tc.body)
+		assert.Equal(tc.expectedCode, rec.Code, tc.name)
+	}
+
+	assert.Equal([]string{"1.0.0"}, versions("/regulated/vpc/aws/versions", "reader"))
+	assert.Equal(http.StatusNoContent, do(http.MethodGet, "/regulated/vpc/aws/1.0.0/download", "reader", "").Code)
+	assert.Equal(http.StatusNotFound, do(http.MethodGet, "/regulated/vpc/aws/1.1.0/download", "reader", "").Code)
+}
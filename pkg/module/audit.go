@@ -0,0 +1,98 @@
+package module
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/TierMobility/boring-registry/pkg/auth"
+)
+
+// Audited actions.
+const (
+	AuditActionUpload   = "upload"
+	AuditActionDownload = "download"
+)
+
+// AuditEvent records who uploaded or downloaded a module version.
+type AuditEvent struct {
+	Time      time.Time `json:"time"`
+	Action    string    `json:"action"`
+	Namespace string    `json:"namespace"`
+	Name      string    `json:"name"`
+	Provider  string    `json:"provider"`
+	Version   string    `json:"version"`
+	// Identity identifies the client, see auth.Identity.
+	Identity string `json:"identity"`
+	ClientIP string `json:"client_ip,omitempty"`
+}
+
+type auditMiddleware struct {
+	Service
+	record            func(AuditEvent)
+	trustForwardedFor bool
+	now               func() time.Time
+}
+
+// AuditOption provides additional options for the AuditMiddleware.
+type AuditOption func(*auditMiddleware)
+
+// WithAuditTrustForwardedFor uses the first address of the X-Forwarded-For header as client address, e.g. behind a load balancer.
+func WithAuditTrustForwardedFor(trust bool) AuditOption {
+	return func(mw *auditMiddleware) {
+		mw.trustForwardedFor = trust
+	}
+}
+
+// AuditMiddleware records successful uploads and downloads of module versions with the record function.
+// Modules uploaded by the CLI directly to the storage backend aren't seen by the server and can't be recorded.
+func AuditMiddleware(record func(AuditEvent), options ...AuditOption) Middleware {
+	return func(next Service) Service {
+		mw := &auditMiddleware{
+			Service: next,
+			record:  record,
+			now:     time.Now,
+		}
+
+		for _, option := range options {
+			option(mw)
+		}
+
+		return mw
+	}
+}
+
+func (mw *auditMiddleware) GetModule(ctx context.Context, namespace, name, provider, version string) (Module, error) {
+	res, err := mw.Service.GetModule(ctx, namespace, name, provider, version)
+	if err == nil {
+		mw.audit(ctx, AuditActionDownload, namespace, name, provider, version)
+	}
+
+	return res, err
+}
+
+func (mw *auditMiddleware) UploadModule(ctx context.Context, namespace, name, provider, version string, body io.Reader) (Module, error) {
+	res, err := mw.Service.UploadModule(ctx, namespace, name, provider, version, body)
+	if err == nil {
+		mw.audit(ctx, AuditActionUpload, namespace, name, provider, version)
+	}
+
+	return res, err
+}
+
+func (mw *auditMiddleware) audit(ctx context.Context, action, namespace, name, provider, version string) {
+	event := AuditEvent{
+		Time:      mw.now().UTC(),
+		Action:    action,
+		Namespace: namespace,
+		Name:      name,
+		Provider:  provider,
+		Version:   version,
+		Identity:  auth.Identity(ctx),
+	}
+	if ip := clientIP(ctx, mw.trustForwardedFor); ip != nil {
+		event.ClientIP = ip.String()
+	}
+
+	mw.record(event)
+}
@@ -0,0 +1,49 @@
+package module
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditMiddleware(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	var events []AuditEvent
+	now := time.Date(2024, 5, 13, 9, 0, 0, 0, time.UTC)
+
+	svc := AuditMiddleware(func(e AuditEvent) { events = append(events, e) }, WithAuditTrustForwardedFor(true))(NewService(NewInmemStorage()))
+	svc.(*auditMiddleware).now = func() time.Time { return now }
+
+	ctx := context.WithValue(context.Background(), httptransport.ContextKeyRequestAuthorization, "Bearer secret")
+	ctx = context.WithValue(ctx, httptransport.ContextKeyRequestRemoteAddr, "10.1.2.3:51234")
+	ctx = context.WithValue(ctx, httptransport.ContextKeyRequestXForwardedFor, "203.0.113.7")
+
+	_, err := svc.UploadModule(ctx, "tier", "vpc", "aws", "1.0.0", strings.NewReader("data"))
+	assert.NoError(err)
+	_, err = svc.GetModule(ctx, "tier", "vpc", "aws", "1.0.0")
+	assert.NoError(err)
+
+	// Failed downloads aren't recorded
+	_, err = svc.GetModule(ctx, "tier", "vpc", "aws", "2.0.0")
+	assert.Error(err)
+
+	event := AuditEvent{
+		Time:      now,
+		Action:    AuditActionUpload,
+		Namespace: "tier",
+		Name:      "vpc",
+		Provider:  "aws",
+		Version:   "1.0.0",
+		Identity:  "token:2bb80d537b1d",
+		ClientIP:  "203.0.113.7",
+	}
+	download := event
+	download.Action = AuditActionDownload
+	assert.Equal([]AuditEvent{event, download}, events)
+}
@@ -0,0 +1,100 @@
+package module
+
+import (
+	"context"
+	"strings"
+
+	"github.com/TierMobility/boring-registry/pkg/auth"
+	"github.com/go-kit/kit/endpoint"
+	"github.com/pkg/errors"
+)
+
+// AuthorizationMiddleware asks the authorizer whether a client may perform the action of a request on a module or namespace.
+// Listings of a namespace are authorized as a whole, the modules within aren't authorized one by one.
+func AuthorizationMiddleware(authorizer auth.Authorizer) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			var action, resource string
+
+			switch req := request.(type) {
+			case listRequest:
+				action, resource = auth.ActionList, moduleAddress(req.namespace, req.name, req.provider)
+			case downloadRequest:
+				action, resource = auth.ActionDownload, moduleAddress(req.namespace, req.name, req.provider, req.version)
+			case docsRequest:
+				action, resource = auth.ActionRead, moduleAddress(req.namespace, req.name, req.provider, req.version)
+			case uploadRequest:
+				action, resource = auth.ActionUpload, moduleAddress(req.namespace, req.name, req.provider, req.version)
+			case annotationsRequest:
+				action, resource = auth.ActionRead, moduleAddress(req.namespace, req.name, req.provider, req.version)
+				if req.annotation != nil {
+					action = auth.ActionWrite
+				}
+			case labelsRequest:
+				action, resource = auth.ActionRead, moduleAddress(req.namespace, req.name, req.provider)
+				if req.labels != nil {
+					action = auth.ActionWrite
+				}
+			case approveRequest:
+				action, resource = auth.ActionWrite, moduleAddress(req.namespace, req.name, req.provider, req.version)
+			case maturityRequest:
+				action, resource = auth.ActionWrite, moduleAddress(req.namespace, req.name, req.provider, req.version)
+			case feedRequest:
+				action, resource = auth.ActionList, moduleAddress(req.namespace, req.name, req.provider)
+				if req.name == "" {
+					resource = req.namespace
+				}
+			case modulesRequest:
+				action, resource = auth.ActionList, req.namespace
+			case changesRequest:
+				action, resource = auth.ActionList, req.namespace
+			case searchRequest:
+				return authorizeSearch(ctx, authorizer, next, req)
+			default:
+				return next(ctx, request)
+			}
+
+			err := authorizer.Authorize(ctx, auth.AuthorizationRequest{
+				Subject:      auth.Identity(ctx),
+				Action:       action,
+				ResourceType: auth.ResourceModule,
+				Resource:     resource,
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			return next(ctx, request)
+		}
+	}
+}
+
+// authorizeSearch hides the modules of namespaces the client may not list from search results.
+// Decisions are requested per namespace like for the listing of a namespace, errors of the authorizer fail the search.
+func authorizeSearch(ctx context.Context, authorizer auth.Authorizer, next endpoint.Endpoint, req searchRequest) (interface{}, error) {
+	var authzErr error
+	req.filter = andFilter(req.filter, func(m Module) bool {
+		err := authorizer.Authorize(ctx, auth.AuthorizationRequest{
+			Subject:      auth.Identity(ctx),
+			Action:       auth.ActionList,
+			ResourceType: auth.ResourceModule,
+			Resource:     m.Namespace,
+		})
+		if err != nil && !errors.Is(err, auth.ErrForbidden) && authzErr == nil {
+			authzErr = err
+		}
+		return err == nil
+	})
+
+	res, err := next(ctx, req)
+	if authzErr != nil {
+		return nil, authzErr
+	}
+
+	return res, err
+}
+
+// moduleAddress joins the parts of the address of a module or module version.
+func moduleAddress(parts ...string) string {
+	return strings.Join(parts, "/")
+}
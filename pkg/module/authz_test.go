@@ -0,0 +1,91 @@
+package module
+
+import (
+	"context"
+	"testing"
+
+	"github.com/TierMobility/boring-registry/pkg/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+type authorizerFunc func(ctx context.Context, req auth.AuthorizationRequest) error
+
+func (f authorizerFunc) Authorize(ctx context.Context, req auth.AuthorizationRequest) error {
+	return f(ctx, req)
+}
+
+func TestAuthorizationMiddleware(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		request  interface{}
+		expected auth.AuthorizationRequest
+	}{
+		{
+			name:     "list",
+			request:  listRequest{namespace: "tier", name: "vpc", provider: "aws"},
+			expected: auth.AuthorizationRequest{Action: auth.ActionList, Resource: "tier/vpc/aws"},
+		},
+		{
+			name:     "download",
+			request:  downloadRequest{namespace: "tier", name: "vpc", provider: "aws", version: "1.0.0"},
+			expected: auth.AuthorizationRequest{Action: auth.ActionDownload, Resource: "tier/vpc/aws/1.0.0"},
+		},
+		{
+			name:     "upload",
+			request:  uploadRequest{namespace: "tier", name: "vpc", provider: "aws", version: "1.0.0"},
+			expected: auth.AuthorizationRequest{Action: auth.ActionUpload, Resource: "tier/vpc/aws/1.0.0"},
+		},
+		{
+			name:     "read labels",
+			request:  labelsRequest{namespace: "tier", name: "vpc", provider: "aws"},
+			expected: auth.AuthorizationRequest{Action: auth.ActionRead, Resource: "tier/vpc/aws"},
+		},
+		{
+			name:     "replace labels",
+			request:  labelsRequest{namespace: "tier", name: "vpc", provider: "aws", labels: Labels{}},
+			expected: auth.AuthorizationRequest{Action: auth.ActionWrite, Resource: "tier/vpc/aws"},
+		},
+		{
+			name:     "approve",
+			request:  approveRequest{namespace: "tier", name: "vpc", provider: "aws", version: "1.0.0"},
+			expected: auth.AuthorizationRequest{Action: auth.ActionWrite, Resource: "tier/vpc/aws/1.0.0"},
+		},
+		{
+			name:     "modules of namespace",
+			request:  modulesRequest{namespace: "tier"},
+			expected: auth.AuthorizationRequest{Action: auth.ActionList, Resource: "tier"},
+		},
+		{
+			name:     "feed of namespace",
+			request:  feedRequest{namespace: "tier"},
+			expected: auth.AuthorizationRequest{Action: auth.ActionList, Resource: "tier"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var got auth.AuthorizationRequest
+			authorizer := authorizerFunc(func(ctx context.Context, req auth.AuthorizationRequest) error {
+				got = req
+				return auth.ErrForbidden
+			})
+
+			called := false
+			_, err := AuthorizationMiddleware(authorizer)(func(ctx context.Context, request interface{}) (interface{}, error) {
+				called = true
+				return nil, nil
+			})(context.Background(), tc.request)
+
+			tc.expected.Subject = "anonymous"
+			tc.expected.ResourceType = auth.ResourceModule
+			assert.Equal(t, auth.ErrForbidden, err)
+			assert.False(t, called)
+			assert.Equal(t, tc.expected, got)
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package module
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ChangeType is the kind of a change of the catalog.
+type ChangeType string
+
+// Changes of the catalog.
+const (
+	ChangeNewModule  ChangeType = "new_module"
+	ChangeNewVersion ChangeType = "new_version"
+	ChangeDeprecated ChangeType = "deprecated"
+)
+
+// changeOrder orders changes of a module at the same time, e.g. a new module before its first version.
+var changeOrder = map[ChangeType]int{
+	ChangeNewModule:  0,
+	ChangeNewVersion: 1,
+	ChangeDeprecated: 2,
+}
+
+// Change is a change of a module or module version of the catalog.
+type Change struct {
+	Type ChangeType `json:"type"`
+	// Module is the address of the module in the format namespace/name/provider.
+	Module  string    `json:"module"`
+	Version string    `json:"version"`
+	At      time.Time `json:"at"`
+}
+
+// catalog is implemented by both Service and Storage, so changes can be reported by the server and the CLI.
+type catalog interface {
+	ListModules(ctx context.Context, namespace string) ([]Module, error)
+	ListMaturities(ctx context.Context, namespace, name, provider string) ([]VersionMaturity, error)
+}
+
+// CatalogChanges returns the changes of the modules of a namespace in the time range [from, to), ordered by time.
+// Modules are new if their first version was uploaded in the range, and versions are only reported as deprecated
+// if that is still their maturity. Deleted versions can't be reported, as storage backends don't keep a record of them.
+func CatalogChanges(ctx context.Context, c catalog, namespace string, from, to time.Time) ([]Change, error) {
+	return catalogChanges(ctx, c, namespace, from, to, nil)
+}
+
+// catalogChanges returns the changes of the modules of a namespace which pass the filter.
+func catalogChanges(ctx context.Context, c catalog, namespace string, from, to time.Time, filter func(Module) bool) ([]Change, error) {
+	res, err := c.ListModules(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	inRange := func(t time.Time) bool {
+		return !t.IsZero() && !t.Before(from) && t.Before(to)
+	}
+
+	modules := make(map[string][]Module)
+	for _, m := range res {
+		m.Namespace = namespace
+		if filter != nil && !filter(m) {
+			continue
+		}
+		id := fmt.Sprintf("%s/%s/%s", m.Namespace, m.Name, m.Provider)
+		modules[id] = append(modules[id], m)
+	}
+
+	changes := []Change{}
+	for id, versions := range modules {
+		// The first upload of a module is its earliest version, storage backends without upload times never report new modules
+		var first Module
+		for _, v := range versions {
+			if inRange(v.Created) {
+				changes = append(changes, Change{Type: ChangeNewVersion, Module: id, Version: v.Version, At: v.Created.UTC()})
+			}
+			if first.Created.IsZero() || v.Created.Before(first.Created) {
+				first = v
+			}
+		}
+		if inRange(first.Created) {
+			changes = append(changes, Change{Type: ChangeNewModule, Module: id, Version: first.Version, At: first.Created.UTC()})
+		}
+
+		maturities, err := c.ListMaturities(ctx, namespace, versions[0].Name, versions[0].Provider)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range maturities {
+			if m.Maturity == MaturityDeprecated && inRange(m.UpdatedAt) {
+				changes = append(changes, Change{Type: ChangeDeprecated, Module: id, Version: m.Version, At: m.UpdatedAt.UTC()})
+			}
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		a, b := changes[i], changes[j]
+		switch {
+		case !a.At.Equal(b.At):
+			return a.At.Before(b.At)
+		case a.Module != b.Module:
+			return a.Module < b.Module
+		case a.Type != b.Type:
+			return changeOrder[a.Type] < changeOrder[b.Type]
+		}
+		return versionLess(a.Version, b.Version)
+	})
+
+	return changes, nil
+}
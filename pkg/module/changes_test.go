@@ -0,0 +1,106 @@
+package module
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/TierMobility/boring-registry/pkg/auth"
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/stretchr/testify/assert"
+)
+
+type testCatalog struct {
+	modules    []Module
+	maturities map[string][]VersionMaturity
+}
+
+func (c testCatalog) ListModules(ctx context.Context, namespace string) ([]Module, error) {
+	return c.modules, nil
+}
+
+func (c testCatalog) ListMaturities(ctx context.Context, namespace, name, provider string) ([]VersionMaturity, error) {
+	return c.maturities[name], nil
+}
+
+func TestCatalogChanges(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	week := time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	c := testCatalog{
+		modules: []Module{
+			{Name: "vpc", Provider: "aws", Version: "1.0.0", Created: week.Add(-30 * day)},
+			{Name: "vpc", Provider: "aws", Version: "1.1.0", Created: week.Add(2 * day)},
+			{Name: "s3", Provider: "aws", Version: "0.1.0", Created: week.Add(day)},
+			{Name: "s3", Provider: "aws", Version: "0.2.0", Created: week.Add(3 * day)},
+			{Name: "rds", Provider: "aws", Version: "1.0.0", Created: week.Add(8 * day)},
+			{Name: "legacy", Provider: "aws", Version: "1.0.0"},
+		},
+		maturities: map[string][]VersionMaturity{
+			"vpc": {
+				{Version: "1.0.0", Maturity: MaturityDeprecated, UpdatedAt: week.Add(4 * day)},
+				{Version: "1.1.0", Maturity: MaturityStable, UpdatedAt: week.Add(4 * day)},
+			},
+			"s3": {{Version: "0.1.0", Maturity: MaturityDeprecated, UpdatedAt: week.Add(-day)}},
+		},
+	}
+
+	changes, err := CatalogChanges(context.Background(), c, "tier", week, week.Add(7*day))
+	assert.NoError(err)
+	assert.Equal([]Change{
+		{Type: ChangeNewModule, Module: "tier/s3/aws", Version: "0.1.0", At: week.Add(day)},
+		{Type: ChangeNewVersion, Module: "tier/s3/aws", Version: "0.1.0", At: week.Add(day)},
+		{Type: ChangeNewVersion, Module: "tier/vpc/aws", Version: "1.1.0", At: week.Add(2 * day)},
+		{Type: ChangeNewVersion, Module: "tier/s3/aws", Version: "0.2.0", At: week.Add(3 * day)},
+		{Type: ChangeDeprecated, Module: "tier/vpc/aws", Version: "1.0.0", At: week.Add(4 * day)},
+	}, changes)
+
+	changes, err = CatalogChanges(context.Background(), c, "tier", week.Add(-2*day), week)
+	assert.NoError(err)
+	assert.Equal([]Change{
+		{Type: ChangeDeprecated, Module: "tier/s3/aws", Version: "0.1.0", At: week.Add(-day)},
+	}, changes)
+}
+
+func TestChangesEndpoint(t *testing.T) {
+	t.Parallel()
+
+	handler := MakeHandler(
+		NewService(NewInmemStorage()),
+		endpoint.Chain(auth.Middleware("reader")),
+		httptransport.ServerBefore(httptransport.PopulateRequestContext),
+		httptransport.ServerErrorEncoder(ErrorEncoder),
+	)
+
+	testCases := []struct {
+		name         string
+		query        string
+		expectedCode int
+	}{
+		{name: "range", query: "?from=2024-05-06T00:00:00Z&to=2024-05-13T00:00:00Z", expectedCode: http.StatusOK},
+		{name: "until now", query: "?from=1714953600", expectedCode: http.StatusOK},
+		{name: "missing from", query: "?to=2024-05-13T00:00:00Z", expectedCode: http.StatusBadRequest},
+		{name: "invalid to", query: "?from=1714953600&to=yesterday", expectedCode: http.StatusBadRequest},
+		{name: "from after to", query: "?from=2024-05-13T00:00:00Z&to=2024-05-06T00:00:00Z", expectedCode: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, "/tier/changes"+tc.query, nil)
+			req.Header.Set("Authorization", "Bearer reader")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedCode, rec.Code)
+		})
+	}
+}
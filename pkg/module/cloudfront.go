@@ -0,0 +1,105 @@
+package module
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// cloudFrontEncoding is the URL-safe base64 encoding of CloudFront, which replaces +, = and / with -, _ and ~.
+var cloudFrontEncoding = strings.NewReplacer("+", "-", "=", "_", "/", "~")
+
+// CloudFrontSigner signs download URLs of a CloudFront distribution with a canned policy,
+// so modules can be downloaded from a private bucket through CloudFront without AWS credentials.
+type CloudFrontSigner struct {
+	keyPairID string
+	key       *rsa.PrivateKey
+	expiry    time.Duration
+}
+
+// NewCloudFrontSigner returns a signer for the public key or key pair ID of a trusted key group of the distribution
+// and its PEM-encoded RSA private key. The signed URLs are valid for the expiry duration.
+func NewCloudFrontSigner(keyPairID string, privateKey []byte, expiry time.Duration) (*CloudFrontSigner, error) {
+	if keyPairID == "" {
+		return nil, errors.New("key pair ID not defined")
+	}
+
+	if expiry <= 0 {
+		return nil, errors.New("expiry of signed URLs must be positive")
+	}
+
+	block, _ := pem.Decode(privateKey)
+	if block == nil {
+		return nil, errors.New("private key isn't PEM-encoded")
+	}
+
+	// Keys generated with openssl genrsa are PKCS #1, converted ones PKCS #8
+	var key *rsa.PrivateKey
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		k, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid private key")
+		}
+		key = k
+	case "PRIVATE KEY":
+		k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid private key")
+		}
+		rsaKey, ok := k.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("CloudFront only supports RSA keys")
+		}
+		key = rsaKey
+	default:
+		return nil, errors.Errorf("unsupported private key type %q", block.Type)
+	}
+
+	return &CloudFrontSigner{
+		keyPairID: keyPairID,
+		key:       key,
+		expiry:    expiry,
+	}, nil
+}
+
+// Sign adds the expiry, signature and key pair ID of a canned policy to a URL, which expires the configured duration after now.
+func (s *CloudFrontSigner) Sign(rawURL string, now time.Time) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	expires := now.Add(s.expiry).Unix()
+
+	// CloudFront reconstructs the canned policy of the URL, so it has to be byte for byte the same
+	policy := fmt.Sprintf(`{"Statement":[{"Resource":"%s","Condition":{"DateLessThan":{"AWS:EpochTime":%d}}}]}`, rawURL, expires)
+
+	hash := sha1.Sum([]byte(policy))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA1, hash[:])
+	if err != nil {
+		return "", err
+	}
+
+	params := fmt.Sprintf("Expires=%s&Signature=%s&Key-Pair-Id=%s",
+		strconv.FormatInt(expires, 10),
+		cloudFrontEncoding.Replace(base64.StdEncoding.EncodeToString(signature)),
+		url.QueryEscape(s.keyPairID),
+	)
+
+	if u.RawQuery != "" {
+		return rawURL + "&" + params, nil
+	}
+	return rawURL + "?" + params, nil
+}
@@ -0,0 +1,171 @@
+package module
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCloudFrontSigner(t *testing.T) {
+	t.Parallel()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.NoError(t, err)
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(rsaKey)
+	assert.NoError(t, err)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	ecPKCS8, err := x509.MarshalPKCS8PrivateKey(ecKey)
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		name      string
+		keyPairID string
+		key       []byte
+		expiry    time.Duration
+		expectErr bool
+	}{
+		{
+			name:      "pkcs1",
+			keyPairID: "K2JCJMDEHXQW5F",
+			key:       pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}),
+			expiry:    time.Minute,
+		},
+		{
+			name:      "pkcs8",
+			keyPairID: "K2JCJMDEHXQW5F",
+			key:       pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}),
+			expiry:    time.Minute,
+		},
+		{
+			name:      "ecdsa",
+			keyPairID: "K2JCJMDEHXQW5F",
+			key:       pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: ecPKCS8}),
+			expiry:    time.Minute,
+			expectErr: true,
+		},
+		{
+			name:      "not pem",
+			keyPairID: "K2JCJMDEHXQW5F",
+			key:       x509.MarshalPKCS1PrivateKey(rsaKey),
+			expiry:    time.Minute,
+			expectErr: true,
+		},
+		{
+			name:      "missing key pair ID",
+			key:       pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}),
+			expiry:    time.Minute,
+			expectErr: true,
+		},
+		{
+			name:      "no expiry",
+			keyPairID: "K2JCJMDEHXQW5F",
+			key:       pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}),
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+
+			_, err := NewCloudFrontSigner(tc.keyPairID, tc.key, tc.expiry)
+			if tc.expectErr {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+		})
+	}
+}
+
+func TestCloudFrontSigner_Sign(t *testing.T) {
+	t.Parallel()
+
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.NoError(t, err)
+
+	signer, err := NewCloudFrontSigner("K2JCJMDEHXQW5F", pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 5*time.Minute)
+	assert.NoError(t, err)
+
+	now := time.Date(2024, 5, 13, 9, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name string
+		url  string
+	}{
+		{
+			name: "plain",
+			url:  "https://d111111abcdef8.cloudfront.net/modules/tier/vpc/aws/1.0.0/tier-vpc-aws-1.0.0.tar.gz",
+		},
+		{
+			name: "query",
+			url:  "https://d111111abcdef8.cloudfront.net/modules/tier-vpc-aws-1.0.0.tar.gz?archive=tar.gz",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+
+			signed, err := signer.Sign(tc.url, now)
+			assert.NoError(err)
+			assert.True(strings.HasPrefix(signed, tc.url))
+
+			u, err := url.Parse(signed)
+			assert.NoError(err)
+
+			query := u.Query()
+			assert.Equal("1715591100", query.Get("Expires"))
+			assert.Equal("K2JCJMDEHXQW5F", query.Get("Key-Pair-Id"))
+
+			// CloudFront verifies the signature of the canned policy of the URL without the signing parameters
+			signature, err := base64.StdEncoding.DecodeString(strings.NewReplacer("-", "+", "_", "=", "~", "/").Replace(query.Get("Signature")))
+			assert.NoError(err)
+
+			policy := fmt.Sprintf(`{"Statement":[{"Resource":"%s","Condition":{"DateLessThan":{"AWS:EpochTime":1715591100}}}]}`, tc.url)
+			hash := sha1.Sum([]byte(policy))
+			assert.NoError(rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA1, hash[:], signature))
+		})
+	}
+}
+
+func TestS3Storage_CloudFrontSigner(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.NoError(err)
+
+	signer, err := NewCloudFrontSigner("K2JCJMDEHXQW5F", pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 5*time.Minute)
+	assert.NoError(err)
+
+	// The S3 URLs of the bucket aren't served by CloudFront
+	_, err = NewS3Storage("bucket", WithS3StorageBucketRegion("eu-central-1"), WithS3CloudFrontSigner(signer))
+	assert.Error(err)
+
+	_, err = NewS3Storage("bucket",
+		WithS3StorageBucketRegion("eu-central-1"),
+		WithS3CloudFrontSigner(signer),
+		WithS3DownloadURLTemplate("https://d111111abcdef8.cloudfront.net/{{.Key}}"),
+	)
+	assert.NoError(err)
+}
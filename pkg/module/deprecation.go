@@ -0,0 +1,55 @@
+package module
+
+import (
+	"context"
+)
+
+// DeprecatedDownload is the download of a deprecated module version.
+type DeprecatedDownload struct {
+	Namespace string
+	Name      string
+	Provider  string
+	Version   string
+	Reason    string
+}
+
+type deprecationMiddleware struct {
+	Service
+	report func(DeprecatedDownload)
+}
+
+// DeprecationMiddleware reports successful downloads of deprecated module versions with the report function,
+// so their remaining consumers can be found. Every download lists the maturities of the module.
+func DeprecationMiddleware(report func(DeprecatedDownload)) Middleware {
+	return func(next Service) Service {
+		return &deprecationMiddleware{
+			Service: next,
+			report:  report,
+		}
+	}
+}
+
+func (mw *deprecationMiddleware) GetModule(ctx context.Context, namespace, name, provider, version string) (Module, error) {
+	res, err := mw.Service.GetModule(ctx, namespace, name, provider, version)
+	if err != nil {
+		return res, err
+	}
+
+	// The download succeeded, so failures to list the maturities only skip the report
+	maturities, err := mw.Service.ListMaturities(ctx, namespace, name, provider)
+	if err != nil {
+		return res, nil
+	}
+
+	if m := versionMaturityOf(maturities, version); m.Maturity == MaturityDeprecated {
+		mw.report(DeprecatedDownload{
+			Namespace: namespace,
+			Name:      name,
+			Provider:  provider,
+			Version:   version,
+			Reason:    m.Reason,
+		})
+	}
+
+	return res, nil
+}
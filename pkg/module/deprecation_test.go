@@ -0,0 +1,38 @@
+package module
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeprecationMiddleware(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	storage := NewInmemStorage()
+	for _, version := range []string{"1.0.0", "1.1.0"} {
+		_, err := storage.UploadModule(ctx, "tier", "vpc", "aws", version, strings.NewReader("data"))
+		assert.NoError(err)
+	}
+
+	var reports []DeprecatedDownload
+	svc := DeprecationMiddleware(func(d DeprecatedDownload) { reports = append(reports, d) })(NewService(storage))
+
+	_, err := svc.SetMaturity(ctx, "tier", "vpc", "aws", "1.0.0", MaturityDeprecated, "use 1.1.0")
+	assert.NoError(err)
+
+	_, err = svc.GetModule(ctx, "tier", "vpc", "aws", "1.0.0")
+	assert.NoError(err)
+	_, err = svc.GetModule(ctx, "tier", "vpc", "aws", "1.1.0")
+	assert.NoError(err)
+	_, err = svc.GetModule(ctx, "tier", "vpc", "aws", "2.0.0")
+	assert.Error(err)
+
+	assert.Equal([]DeprecatedDownload{
+		{Namespace: "tier", Name: "vpc", Provider: "aws", Version: "1.0.0", Reason: "use 1.1.0"},
+	}, reports)
+}
@@ -0,0 +1,274 @@
+package module
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/pkg/errors"
+)
+
+// maxDocsFileSize limits the size of READMEs and configuration files read from module archives.
+const maxDocsFileSize = 1 << 20
+
+// readmeNames are the file names of READMEs at the root of a module archive, in order of preference.
+var readmeNames = []string{"readme.md", "readme.markdown", "readme.txt", "readme"}
+
+// Docs is the documentation and interface of a module version, extracted from the root module of its archive.
+type Docs struct {
+	Readme    string     `json:"readme"`
+	Variables []Variable `json:"variables"`
+	Outputs   []Output   `json:"outputs"`
+	// RequiredVersion is the constraint of the Terraform versions supporting the module, as written in the module.
+	RequiredVersion   string             `json:"required_version,omitempty"`
+	RequiredProviders []RequiredProvider `json:"required_providers"`
+}
+
+// Variable is an input variable of a module. Its type and default are the expressions as written in the module.
+type Variable struct {
+	Name        string `json:"name"`
+	Type        string `json:"type,omitempty"`
+	Description string `json:"description,omitempty"`
+	Default     string `json:"default,omitempty"`
+	Required    bool   `json:"required"`
+	Sensitive   bool   `json:"sensitive,omitempty"`
+}
+
+// Output is an output value of a module.
+type Output struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Sensitive   bool   `json:"sensitive,omitempty"`
+}
+
+// RequiredProvider is a provider the module requires, with the constraint of its versions.
+type RequiredProvider struct {
+	Name    string `json:"name"`
+	Source  string `json:"source,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+// extractDocs returns the documentation of the root module of a gzipped tar archive.
+// Archives in other formats have no documentation.
+func extractDocs(r io.Reader) (Docs, error) {
+	docs := Docs{
+		Variables:         []Variable{},
+		Outputs:           []Output{},
+		RequiredProviders: []RequiredProvider{},
+	}
+	var versions []string
+
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err != nil || !bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		return docs, nil
+	}
+
+	gr, err := gzip.NewReader(br)
+	if err != nil {
+		return Docs{}, errors.Wrap(err, "failed to read module archive")
+	}
+	defer gr.Close()
+
+	readmes := make(map[string]string)
+
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Docs{}, errors.Wrap(err, "failed to read module archive")
+		}
+
+		name := strings.ToLower(path.Clean(strings.TrimPrefix(header.Name, "./")))
+		if header.Typeflag != tar.TypeReg || header.Size > maxDocsFileSize || !(isReadme(name) || isConfiguration(name)) {
+			continue
+		}
+
+		b, err := ioutil.ReadAll(io.LimitReader(tr, maxDocsFileSize))
+		if err != nil {
+			return Docs{}, errors.Wrap(err, "failed to read module archive")
+		}
+
+		if isReadme(name) {
+			readmes[name] = string(b)
+			continue
+		}
+
+		config := parseConfiguration(string(b))
+		docs.Variables = append(docs.Variables, config.variables...)
+		docs.Outputs = append(docs.Outputs, config.outputs...)
+		docs.RequiredProviders = append(docs.RequiredProviders, config.providers...)
+		versions = append(versions, config.versions...)
+	}
+
+	// Terraform requires all version constraints of a module to be met
+	docs.RequiredVersion = strings.Join(versions, ", ")
+
+	for _, name := range readmeNames {
+		if readme, ok := readmes[name]; ok {
+			docs.Readme = readme
+			break
+		}
+	}
+
+	sort.Slice(docs.Variables, func(i, j int) bool { return docs.Variables[i].Name < docs.Variables[j].Name })
+	sort.Slice(docs.Outputs, func(i, j int) bool { return docs.Outputs[i].Name < docs.Outputs[j].Name })
+	sort.Slice(docs.RequiredProviders, func(i, j int) bool { return docs.RequiredProviders[i].Name < docs.RequiredProviders[j].Name })
+
+	return docs, nil
+}
+
+func isReadme(name string) bool {
+	for _, n := range readmeNames {
+		if name == n {
+			return true
+		}
+	}
+	return false
+}
+
+// isConfiguration reports whether a file is a configuration file of the root module.
+func isConfiguration(name string) bool {
+	return !strings.Contains(name, "/") && strings.HasSuffix(name, ".tf")
+}
+
+// configuration is what a configuration file declares of the interface of a module.
+type configuration struct {
+	variables []Variable
+	outputs   []Output
+	providers []RequiredProvider
+	versions  []string
+}
+
+// parseConfiguration returns the variables, outputs and requirements declared in a configuration file.
+func parseConfiguration(src string) configuration {
+	var config configuration
+
+	for _, block := range parseTerraformBlocks(src) {
+		if block.typ == "terraform" && len(block.labels) == 0 {
+			if version, ok := block.attributes["required_version"]; ok {
+				config.versions = append(config.versions, version.stringValue())
+			}
+			for _, nested := range block.blocks {
+				if nested.typ == "required_providers" {
+					config.providers = append(config.providers, parseRequiredProviders(src, nested)...)
+				}
+			}
+			continue
+		}
+
+		if len(block.labels) != 1 {
+			continue
+		}
+
+		switch block.typ {
+		case "variable":
+			def, hasDefault := block.attributes["default"]
+			config.variables = append(config.variables, Variable{
+				Name:        block.labels[0],
+				Type:        block.attributes["type"].expr,
+				Description: block.attributes["description"].stringValue(),
+				Default:     def.expr,
+				Required:    !hasDefault,
+				Sensitive:   block.attributes["sensitive"].expr == "true",
+			})
+		case "output":
+			config.outputs = append(config.outputs, Output{
+				Name:        block.labels[0],
+				Description: block.attributes["description"].stringValue(),
+				Sensitive:   block.attributes["sensitive"].expr == "true",
+			})
+		}
+	}
+
+	return config
+}
+
+// parseRequiredProviders returns the providers of a required_providers block,
+// which are objects with source and version or only a version constraint in the syntax of Terraform 0.12.
+func parseRequiredProviders(src string, block tfBlock) []RequiredProvider {
+	var providers []RequiredProvider
+
+	for name, attribute := range block.attributes {
+		provider := RequiredProvider{Name: name}
+		if object := attribute.objectAttributes(src); object != nil {
+			provider.Source = object["source"].stringValue()
+			provider.Version = object["version"].stringValue()
+		} else {
+			provider.Version = attribute.stringValue()
+		}
+		providers = append(providers, provider)
+	}
+
+	return providers
+}
+
+// copyDocs returns a copy of the documentation which doesn't share its slices, nil slices stay nil.
+func copyDocs(docs Docs) Docs {
+	if docs.Variables != nil {
+		docs.Variables = append([]Variable{}, docs.Variables...)
+	}
+	if docs.Outputs != nil {
+		docs.Outputs = append([]Output{}, docs.Outputs...)
+	}
+	if docs.RequiredProviders != nil {
+		docs.RequiredProviders = append([]RequiredProvider{}, docs.RequiredProviders...)
+	}
+	return docs
+}
+
+// docsPath returns the path of the documentation of a module version.
+func docsPath(prefix, namespace, name, provider, version string) string {
+	return path.Join(
+		prefix,
+		"docs",
+		fmt.Sprintf("namespace=%s", namespace),
+		fmt.Sprintf("name=%s", name),
+		fmt.Sprintf("provider=%s", provider),
+		fmt.Sprintf("version=%s", version),
+		"docs.json",
+	)
+}
+
+type docsRequest struct {
+	namespace string
+	name      string
+	provider  string
+	version   string
+}
+
+// docsEndpoint returns the documentation of a module version.
+func docsEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(docsRequest)
+
+		return svc.GetDocs(ctx, req.namespace, req.name, req.provider, req.version)
+	}
+}
+
+func decodeDocsRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	res, err := decodeDownloadRequest(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+
+	download := res.(downloadRequest)
+	return docsRequest{
+		namespace: download.namespace,
+		name:      download.name,
+		provider:  download.provider,
+		version:   download.version,
+	}, nil
+}
@@ -0,0 +1,427 @@
+package module
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The HCL library of the registry only understands the syntax of Terraform 0.11, so the blocks of module
+// configurations are read with a small scanner. It only knows enough of the native syntax to find blocks
+// and their attributes, expressions are kept as written.
+
+type tfTokenKind int
+
+const (
+	tfIdent tfTokenKind = iota
+	tfString
+	tfNewline
+	tfOpen
+	tfClose
+	tfEquals
+	tfOther
+)
+
+type tfToken struct {
+	kind tfTokenKind
+	// value is the identifier, or the content of a string without template sequences.
+	value string
+	// literal is false for strings with template sequences.
+	literal    bool
+	start, end int
+}
+
+// scanTerraform splits a Terraform configuration into tokens, skipping whitespace and comments.
+func scanTerraform(src string) []tfToken {
+	var tokens []tfToken
+
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '\n':
+			tokens = append(tokens, tfToken{kind: tfNewline, start: i, end: i + 1})
+			i++
+		case c == '#' || strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return tokens
+			}
+			i += end + 4
+		case c == '"':
+			end, value, literal := scanTerraformString(src, i+1)
+			tokens = append(tokens, tfToken{kind: tfString, value: value, literal: literal, start: i, end: end})
+			i = end
+		case strings.HasPrefix(src[i:], "<<"):
+			if token, ok := scanTerraformHeredoc(src, i); ok {
+				tokens = append(tokens, token)
+				i = token.end
+				continue
+			}
+			tokens = append(tokens, tfToken{kind: tfOther, start: i, end: i + 2})
+			i += 2
+		case isTerraformIdent(rune(c), true):
+			start := i
+			for i < len(src) && isTerraformIdent(rune(src[i]), false) {
+				i++
+			}
+			tokens = append(tokens, tfToken{kind: tfIdent, value: src[start:i], start: start, end: i})
+		case c == '{' || c == '[' || c == '(':
+			tokens = append(tokens, tfToken{kind: tfOpen, start: i, end: i + 1})
+			i++
+		case c == '}' || c == ']' || c == ')':
+			tokens = append(tokens, tfToken{kind: tfClose, start: i, end: i + 1})
+			i++
+		case c == '=' && !strings.HasPrefix(src[i:], "==") && !strings.HasPrefix(src[i:], "=>"):
+			tokens = append(tokens, tfToken{kind: tfEquals, start: i, end: i + 1})
+			i++
+		default:
+			_, size := utf8.DecodeRuneInString(src[i:])
+			tokens = append(tokens, tfToken{kind: tfOther, start: i, end: i + size})
+			i += size
+		}
+	}
+
+	return tokens
+}
+
+func isTerraformIdent(r rune, first bool) bool {
+	if first {
+		return r == '_' || r < utf8.RuneSelf && unicode.IsLetter(r)
+	}
+	return r == '_' || r == '-' || r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// scanTerraformString scans a quoted string starting after its opening quote.
+// It returns the offset after the closing quote, the unescaped content and whether it has no template sequences.
+func scanTerraformString(src string, i int) (int, string, bool) {
+	var (
+		value   strings.Builder
+		literal = true
+	)
+
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == '"':
+			return i + 1, value.String(), literal
+		case c == '\n':
+			// Quoted strings can't span lines, the string is unterminated
+			return i, value.String(), literal
+		case c == '\\' && i+1 < len(src):
+			n := escapeLength(src[i:])
+			if unquoted, err := strconv.Unquote(`"` + src[i:i+n] + `"`); err == nil {
+				value.WriteString(unquoted)
+			} else {
+				value.WriteString(src[i : i+n])
+			}
+			i += n
+		case (c == '$' || c == '%') && strings.HasPrefix(src[i+1:], "{"):
+			literal = false
+			i = skipTerraformTemplate(src, i+2)
+		case (c == '$' || c == '%') && strings.HasPrefix(src[i+1:], string(c)+"{"):
+			// $${ and %%{ are escaped template sequences
+			value.WriteString(string(c) + "{")
+			i += 3
+		default:
+			value.WriteByte(c)
+			i++
+		}
+	}
+
+	return i, value.String(), literal
+}
+
+// escapeLength returns the length of the escape sequence at the start of s.
+func escapeLength(s string) int {
+	switch {
+	case strings.HasPrefix(s, `\u`) && len(s) >= 6:
+		return 6
+	case strings.HasPrefix(s, `\U`) && len(s) >= 10:
+		return 10
+	default:
+		return 2
+	}
+}
+
+// skipTerraformTemplate returns the offset after the closing brace of a template sequence, which may contain strings.
+func skipTerraformTemplate(src string, i int) int {
+	for depth := 0; i < len(src); {
+		switch src[i] {
+		case '{':
+			depth++
+			i++
+		case '}':
+			if depth == 0 {
+				return i + 1
+			}
+			depth--
+			i++
+		case '"':
+			i, _, _ = scanTerraformString(src, i+1)
+		case '\n':
+			return i
+		default:
+			i++
+		}
+	}
+	return i
+}
+
+// scanTerraformHeredoc scans a heredoc like <<EOT or <<-EOT, whose content is returned as literal string.
+func scanTerraformHeredoc(src string, start int) (tfToken, bool) {
+	i := start + 2
+	indented := strings.HasPrefix(src[i:], "-")
+	if indented {
+		i++
+	}
+
+	lineEnd := strings.IndexByte(src[i:], '\n')
+	if lineEnd < 0 {
+		return tfToken{}, false
+	}
+	marker := strings.TrimSpace(src[i : i+lineEnd])
+	if marker == "" || strings.IndexFunc(marker, func(r rune) bool { return !isTerraformIdent(r, false) }) >= 0 {
+		return tfToken{}, false
+	}
+	i += lineEnd + 1
+
+	var lines []string
+	for i <= len(src) {
+		end := strings.IndexByte(src[i:], '\n')
+		if end < 0 {
+			end = len(src) - i
+		}
+		line := strings.TrimSuffix(src[i:i+end], "\r")
+
+		if strings.TrimSpace(line) == marker {
+			return tfToken{
+				kind:    tfString,
+				value:   heredocContent(lines, indented),
+				literal: !strings.Contains(strings.Join(lines, "\n"), "${"),
+				start:   start,
+				end:     i + len(strings.TrimRight(src[i:i+end], "\r")),
+			}, true
+		}
+
+		lines = append(lines, line)
+		i += end + 1
+	}
+
+	return tfToken{}, false
+}
+
+// heredocContent joins the lines of a heredoc, removing their common indentation for indented heredocs.
+func heredocContent(lines []string, indented bool) string {
+	if indented {
+		indent := -1
+		for _, line := range lines {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			if n := len(line) - len(strings.TrimLeft(line, " \t")); indent < 0 || n < indent {
+				indent = n
+			}
+		}
+		for i, line := range lines {
+			if len(line) >= indent && indent > 0 {
+				lines[i] = line[indent:]
+			}
+		}
+	}
+
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// tfAttribute is an attribute of a block, its expression is kept as written.
+type tfAttribute struct {
+	expr   string
+	tokens []tfToken
+}
+
+// stringValue returns the value of a string attribute, or the expression if it isn't a literal string.
+func (a tfAttribute) stringValue() string {
+	if len(a.tokens) == 1 && a.tokens[0].kind == tfString && a.tokens[0].literal {
+		return a.tokens[0].value
+	}
+	return a.expr
+}
+
+// objectAttributes returns the attributes of an object constructor like { source = "hashicorp/aws" },
+// its values are kept as written. It returns nil if the attribute isn't an object.
+func (a tfAttribute) objectAttributes(src string) map[string]tfAttribute {
+	tokens := a.tokens
+	if len(tokens) < 2 || tokens[0].kind != tfOpen || src[tokens[0].start:tokens[0].end] != "{" || tokens[len(tokens)-1].kind != tfClose {
+		return nil
+	}
+	tokens = tokens[1 : len(tokens)-1]
+
+	attributes := make(map[string]tfAttribute)
+	for i := 0; i < len(tokens); {
+		key := tokens[i]
+		if (key.kind != tfIdent && key.kind != tfString) || i+1 >= len(tokens) ||
+			(tokens[i+1].kind != tfEquals && src[tokens[i+1].start:tokens[i+1].end] != ":") {
+			i++
+			continue
+		}
+
+		start := i + 2
+		i = start
+		for depth := 0; i < len(tokens); i++ {
+			token := tokens[i]
+			if depth == 0 && (token.kind == tfNewline || src[token.start:token.end] == ",") {
+				break
+			}
+			if token.kind == tfOpen {
+				depth++
+			}
+			if token.kind == tfClose {
+				depth--
+			}
+		}
+
+		if value := tokens[start:i]; len(value) > 0 {
+			attributes[key.value] = tfAttribute{
+				expr:   strings.TrimSpace(src[value[0].start:value[len(value)-1].end]),
+				tokens: value,
+			}
+		}
+	}
+
+	return attributes
+}
+
+// tfBlock is a block of a Terraform configuration.
+type tfBlock struct {
+	typ        string
+	labels     []string
+	attributes map[string]tfAttribute
+	blocks     []tfBlock
+}
+
+// parseTerraformBlocks returns the top-level blocks of a Terraform configuration with their attributes and nested blocks.
+// Syntax it doesn't understand is skipped until the next line.
+func parseTerraformBlocks(src string) []tfBlock {
+	p := &tfParser{src: src, tokens: scanTerraform(src)}
+
+	var blocks []tfBlock
+	for p.pos < len(p.tokens) {
+		token := p.tokens[p.pos]
+		if token.kind != tfIdent {
+			p.pos++
+			continue
+		}
+		p.pos++
+
+		var labels []string
+		for p.pos < len(p.tokens) && (p.tokens[p.pos].kind == tfString || p.tokens[p.pos].kind == tfIdent) {
+			labels = append(labels, p.tokens[p.pos].value)
+			p.pos++
+		}
+
+		switch {
+		case p.peek(tfOpen, "{"):
+			p.pos++
+			blocks = append(blocks, p.body(token.value, labels))
+		case p.peek(tfEquals, ""):
+			p.pos++
+			p.expression()
+		}
+	}
+
+	return blocks
+}
+
+type tfParser struct {
+	src    string
+	tokens []tfToken
+	pos    int
+}
+
+func (p *tfParser) peek(kind tfTokenKind, text string) bool {
+	if p.pos >= len(p.tokens) || p.tokens[p.pos].kind != kind {
+		return false
+	}
+	token := p.tokens[p.pos]
+	return text == "" || p.src[token.start:token.end] == text
+}
+
+// body returns the block of a body up to its closing brace, which is consumed.
+func (p *tfParser) body(typ string, labels []string) tfBlock {
+	block := tfBlock{
+		typ:        typ,
+		labels:     labels,
+		attributes: make(map[string]tfAttribute),
+	}
+
+	for p.pos < len(p.tokens) {
+		token := p.tokens[p.pos]
+		switch token.kind {
+		case tfClose:
+			p.pos++
+			return block
+		case tfIdent:
+			p.pos++
+			if p.peek(tfEquals, "") {
+				p.pos++
+				block.attributes[token.value] = p.expression()
+				continue
+			}
+
+			var nestedLabels []string
+			for p.pos < len(p.tokens) && (p.tokens[p.pos].kind == tfString || p.tokens[p.pos].kind == tfIdent) {
+				nestedLabels = append(nestedLabels, p.tokens[p.pos].value)
+				p.pos++
+			}
+			if p.peek(tfOpen, "{") {
+				p.pos++
+				block.blocks = append(block.blocks, p.body(token.value, nestedLabels))
+			}
+		default:
+			p.pos++
+		}
+	}
+
+	return block
+}
+
+// expression consumes an expression up to the end of its line, which may continue within brackets.
+func (p *tfParser) expression() tfAttribute {
+	start := p.pos
+
+	for depth := 0; p.pos < len(p.tokens); p.pos++ {
+		token := p.tokens[p.pos]
+		if token.kind == tfNewline && depth == 0 {
+			break
+		}
+		if token.kind == tfOpen {
+			depth++
+		}
+		if token.kind == tfClose {
+			if depth == 0 {
+				// Closing brace of a single-line block
+				break
+			}
+			depth--
+		}
+	}
+
+	tokens := p.tokens[start:p.pos]
+	if len(tokens) == 0 {
+		return tfAttribute{}
+	}
+
+	return tfAttribute{
+		expr:   strings.TrimSpace(p.src[tokens[0].start:tokens[len(tokens)-1].end]),
+		tokens: tokens,
+	}
+}
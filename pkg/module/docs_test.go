@@ -0,0 +1,349 @@
+package module
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TierMobility/boring-registry/pkg/auth"
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+const testVariables = `
+# The CIDR of the VPC
+variable "cidr" {
+  description = "CIDR block of the VPC"
+  type        = string
+}
+
+variable "tags" {
+  description = <<-EOT
+    Tags of all resources,
+    merged with the default tags.
+  EOT
+  type = map(string)
+  default = {
+    team = "platform" # owner
+  }
+}
+
+variable "azs" {
+  type    = list(string)
+  default = ["eu-central-1a", "eu-central-1b"]
+
+  validation {
+    condition     = length(var.azs) > 0
+    error_message = "At least one availability zone is required."
+  }
+}
+
+/* variable "commented" {} */
+variable "password" { sensitive = true }
+`
+
+const testOutputs = `
+output "vpc_id" {
+  description = "ID of the VPC in ${var.cidr}"
+  value       = aws_vpc.this.id
+}
+
+output "secret" {
+  value     = random_password.this.result
+  sensitive = true
+}
+
+resource "aws_vpc" "this" {
+  cidr_block = var.cidr
+  tags       = { for k, v in var.tags : k => "${v}-{}" }
+}
+`
+
+const testVersions = `
+terraform {
+  required_version = ">= 1.3.0"
+
+  required_providers {
+    aws = {
+      source  = "hashicorp/aws"
+      version = ">= 5.0, < 6.0"
+    }
+    random = { source = "hashicorp/random" }
+    null   = "~> 3.0"
+  }
+}
+
+terraform {
+  required_version = "< 2.0.0"
+}
+`
+
+func TestExtractDocs(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name         string
+		data         string
+		expectedDocs Docs
+		expectErr    bool
+	}{
+		{
+			name: "module",
+			data: testModuleData(map[string]string{
+				"README.md":             "# vpc",
+				"./README":              "plain",
+				"variables.tf":          testVariables,
+				"outputs.tf":            testOutputs,
+				"versions.tf":           testVersions,
+				"modules/sub/main.tf":   `variable "nested" {}`,
+				"modules/sub/README.md": "# sub",
+			}).String(),
+			expectedDocs: Docs{
+				Readme: "# vpc",
+				Variables: []Variable{
+					{Name: "azs", Type: "list(string)", Default: `["eu-central-1a", "eu-central-1b"]`},
+					{Name: "cidr", Type: "string", Description: "CIDR block of the VPC", Required: true},
+					{Name: "password", Required: true, Sensitive: true},
+					{Name: "tags", Type: "map(string)", Description: "Tags of all resources,\nmerged with the default tags.\n", Default: "{\n    team = \"platform\" # owner\n  }"},
+				},
+				Outputs: []Output{
+					{Name: "secret", Sensitive: true},
+					{Name: "vpc_id", Description: `"ID of the VPC in ${var.cidr}"`},
+				},
+				RequiredVersion: ">= 1.3.0, < 2.0.0",
+				RequiredProviders: []RequiredProvider{
+					{Name: "aws", Source: "hashicorp/aws", Version: ">= 5.0, < 6.0"},
+					{Name: "null", Version: "~> 3.0"},
+					{Name: "random", Source: "hashicorp/random"},
+				},
+			},
+		},
+		{
+			name: "no documentation",
+			data: testModuleData(map[string]string{"main.tf": `resource "null_resource" "this" {}`}).String(),
+			expectedDocs: Docs{
+				Variables:         []Variable{},
+				Outputs:           []Output{},
+				RequiredProviders: []RequiredProvider{},
+			},
+		},
+		{
+			name: "not gzipped",
+			data: "data",
+			expectedDocs: Docs{
+				Variables:         []Variable{},
+				Outputs:           []Output{},
+				RequiredProviders: []RequiredProvider{},
+			},
+		},
+		{
+			name:      "corrupt archive",
+			data:      "\x1f\x8bdata",
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			docs, err := extractDocs(strings.NewReader(tc.data))
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedDocs, docs)
+		})
+	}
+}
+
+func TestDocs(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	storage := NewInmemStorage()
+	svc := NewService(storage)
+
+	// Versions uploaded with the CLI have no stored documentation
+	_, err := storage.UploadModule(ctx, "tier", "s3", "aws", "1.0.0", testModuleData(map[string]string{"README.md": "# s3"}))
+	assert.NoError(t, err)
+	_, err = svc.UploadModule(ctx, "tier", "vpc", "aws", "1.0.0", testModuleData(map[string]string{"README.md": "# vpc", "variables.tf": testVariables}))
+	assert.NoError(t, err)
+	_, err = svc.UploadModule(ctx, "tier", "secrets", "aws", "1.0.0", testModuleData(map[string]string{"README.md": "# secrets"}))
+	assert.NoError(t, err)
+
+	stored, err := storage.GetDocs(ctx, "tier", "vpc", "aws", "1.0.0")
+	assert.NoError(t, err)
+	assert.Len(t, stored.Variables, 4)
+
+	// Documentation stored before the requirements were extracted
+	_, err = storage.UploadModule(ctx, "tier", "network", "aws", "1.0.0", testModuleData(map[string]string{"README.md": "# network", "versions.tf": testVersions}))
+	assert.NoError(t, err)
+	assert.NoError(t, storage.SetDocs(ctx, "tier", "network", "aws", "1.0.0", Docs{Readme: "# network"}))
+
+	handler := MakeHandler(
+		svc,
+		endpoint.Chain(
+			auth.Middleware("platform", "reader"),
+			ACLMiddleware(ACL{"tier/secrets/aws": {"platform"}}),
+		),
+		httptransport.ServerBefore(httptransport.PopulateRequestContext),
+		httptransport.ServerErrorEncoder(ErrorEncoder),
+	)
+
+	testCases := []struct {
+		name              string
+		path              string
+		token             string
+		expectedCode      int
+		expectedReadme    string
+		expectedVariables int
+		expectedProviders int
+	}{
+		{
+			name:              "stored docs",
+			path:              "/tier/vpc/aws/1.0.0/docs",
+			token:             "reader",
+			expectedCode:      http.StatusOK,
+			expectedReadme:    "# vpc",
+			expectedVariables: 4,
+		},
+		{
+			name:           "extracted docs",
+			path:           "/tier/s3/aws/1.0.0/docs",
+			token:          "reader",
+			expectedCode:   http.StatusOK,
+			expectedReadme: "# s3",
+		},
+		{
+			name:              "stale stored docs",
+			path:              "/tier/network/aws/1.0.0/docs",
+			token:             "reader",
+			expectedCode:      http.StatusOK,
+			expectedReadme:    "# network",
+			expectedProviders: 3,
+		},
+		{
+			name:         "unknown version",
+			path:         "/tier/vpc/aws/2.0.0/docs",
+			token:        "reader",
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "restricted module",
+			path:         "/tier/secrets/aws/1.0.0/docs",
+			token:        "reader",
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:           "allowed restricted module",
+			path:           "/tier/secrets/aws/1.0.0/docs",
+			token:          "platform",
+			expectedCode:   http.StatusOK,
+			expectedReadme: "# secrets",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedCode, rec.Code)
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+
+			var docs Docs
+			assert.NoError(t, json.NewDecoder(rec.Body).Decode(&docs))
+			assert.Equal(t, tc.expectedReadme, docs.Readme)
+			assert.Len(t, docs.Variables, tc.expectedVariables)
+			assert.Len(t, docs.RequiredProviders, tc.expectedProviders)
+		})
+	}
+}
+
+// downloadCounter counts the versions looked up through the service, like the middlewares recording downloads.
+type downloadCounter struct {
+	Service
+	downloads int
+}
+
+func (c *downloadCounter) GetModule(ctx context.Context, namespace, name, provider, version string) (Module, error) {
+	c.downloads++
+	return c.Service.GetModule(ctx, namespace, name, provider, version)
+}
+
+func TestDocs_HiddenVersions(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	storage := NewInmemStorage()
+	for _, module := range []struct{ namespace, version string }{
+		{namespace: "tier", version: "1.0.0"},
+		{namespace: "tier", version: "1.1.0"},
+		{namespace: "tier", version: "2.0.0-rc.1"},
+		{namespace: "team", version: "1.0.0"},
+	} {
+		_, err := storage.UploadModule(ctx, module.namespace, "vpc", "aws", module.version, testModuleData(map[string]string{"README.md": "# vpc"}))
+		assert.NoError(err)
+	}
+	assert.NoError(storage.ScheduleModule(ctx, "tier", "vpc", "aws", "1.1.0", time.Now().Add(time.Hour)))
+
+	svc := NewService(storage)
+	svc = ScheduleMiddleware()(svc)
+	svc = ApprovalMiddleware([]string{"team"}, nil)(svc)
+	svc = PreviewMiddleware([]string{"preview"}, 0)(svc)
+	counter := &downloadCounter{Service: svc}
+
+	handler := MakeHandler(
+		counter,
+		endpoint.Chain(auth.Middleware("reader")),
+		httptransport.ServerBefore(httptransport.PopulateRequestContext),
+		httptransport.ServerErrorEncoder(ErrorEncoder),
+	)
+
+	testCases := []struct {
+		name         string
+		path         string
+		expectedCode int
+	}{
+		{name: "released", path: "/tier/vpc/aws/1.0.0/docs", expectedCode: http.StatusOK},
+		{name: "scheduled", path: "/tier/vpc/aws/1.1.0/docs", expectedCode: http.StatusNotFound},
+		{name: "preview", path: "/tier/vpc/aws/2.0.0-rc.1/docs", expectedCode: http.StatusNotFound},
+		{name: "pending approval", path: "/team/vpc/aws/1.0.0/docs", expectedCode: http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		req.Header.Set("Authorization", "Bearer reader")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(tc.expectedCode, rec.Code, tc.name)
+	}
+
+	// Reading the documentation isn't a download
+	assert.Zero(counter.downloads)
+
+	// Every middleware hiding versions hides their documentation on its own
+	_, err := ScheduleMiddleware()(NewService(storage)).GetDocs(ctx, "tier", "vpc", "aws", "1.1.0")
+	assert.True(errors.Is(err, ErrNotFound))
+	_, err = ApprovalMiddleware([]string{"team"}, nil)(NewService(storage)).GetDocs(ctx, "team", "vpc", "aws", "1.0.0")
+	assert.True(errors.Is(err, ErrNotFound))
+}
@@ -2,18 +2,33 @@ package module
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
 
 	"github.com/go-kit/kit/endpoint"
+	"github.com/hashicorp/go-version"
 )
 
 type listRequest struct {
 	namespace string
 	name      string
 	provider  string
+	// asOf hides versions uploaded after it, if it is set.
+	asOf time.Time
 }
 
 type listResponseVersion struct {
 	Version string `json:"version,omitempty"`
+	// PublishedAt extends the Module Registry Protocol with the upload time, if the storage provides it.
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+	// Maturity extends the Module Registry Protocol with the maturity of the version, if one was set.
+	Maturity Maturity `json:"maturity,omitempty"`
+	// Deprecated and DeprecationReason extend the Module Registry Protocol with the deprecation of the version.
+	Deprecated        bool   `json:"deprecated,omitempty"`
+	DeprecationReason string `json:"deprecation_reason,omitempty"`
 }
 
 type listResponseModule struct {
@@ -32,13 +47,30 @@ func listEndpoint(svc Service) endpoint.Endpoint {
 		if err != nil {
 			return nil, err
 		}
+		res = uploadedBefore(res, req.asOf)
+
+		maturities, err := svc.ListMaturities(ctx, req.namespace, req.name, req.provider)
+		if err != nil {
+			return nil, err
+		}
 
 		var versions []listResponseVersion
 
 		for _, module := range res {
-			versions = append(versions, listResponseVersion{
-				Version: module.Version,
-			})
+			maturity := versionMaturityOf(maturities, module.Version)
+			version := listResponseVersion{
+				Version:    module.Version,
+				Maturity:   maturity.Maturity,
+				Deprecated: maturity.Maturity == MaturityDeprecated,
+			}
+			if version.Deprecated {
+				version.DeprecationReason = maturity.Reason
+			}
+			if !module.Created.IsZero() {
+				created := module.Created.UTC()
+				version.PublishedAt = &created
+			}
+			versions = append(versions, version)
 		}
 
 		return listResponse{
@@ -51,6 +83,96 @@ func listEndpoint(svc Service) endpoint.Endpoint {
 	}
 }
 
+type releasesResponseRelease struct {
+	Version          string     `json:"version"`
+	ReleaseTimestamp *time.Time `json:"releaseTimestamp,omitempty"`
+	IsDeprecated     bool       `json:"isDeprecated,omitempty"`
+}
+
+type releasesResponse struct {
+	Releases []releasesResponseRelease `json:"releases"`
+	Tags     map[string]string         `json:"tags,omitempty"`
+}
+
+// releasesEndpoint lists module versions in the format of a Renovate custom datasource.
+// For more information see: https://docs.renovatebot.com/modules/datasource/custom/.
+func releasesEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(listRequest)
+
+		res, err := svc.ListModuleVersions(ctx, req.namespace, req.name, req.provider)
+		if err != nil {
+			return nil, err
+		}
+		res = uploadedBefore(res, req.asOf)
+
+		sort.SliceStable(res, func(i, j int) bool {
+			return versionLess(res[i].Version, res[j].Version)
+		})
+
+		maturities, err := svc.ListMaturities(ctx, req.namespace, req.name, req.provider)
+		if err != nil {
+			return nil, err
+		}
+
+		response := releasesResponse{
+			Releases: []releasesResponseRelease{},
+		}
+
+		for _, module := range res {
+			release := releasesResponseRelease{
+				Version:      module.Version,
+				IsDeprecated: maturityOf(maturities, module.Version) == MaturityDeprecated,
+			}
+			if !module.Created.IsZero() {
+				created := module.Created.UTC()
+				release.ReleaseTimestamp = &created
+			}
+			response.Releases = append(response.Releases, release)
+
+			if !IsPreview(module.Version) {
+				response.Tags = map[string]string{"latest": module.Version}
+			}
+		}
+
+		return response, nil
+	}
+}
+
+// uploadedBefore returns the modules uploaded until the given time, which lets clients list versions as they were back then.
+// A zero time returns all modules, modules without an upload time are always returned.
+func uploadedBefore(modules []Module, t time.Time) []Module {
+	if t.IsZero() {
+		return modules
+	}
+
+	var res []Module
+	for _, module := range modules {
+		if module.Created.IsZero() || !module.Created.After(t) {
+			res = append(res, module)
+		}
+	}
+
+	return res
+}
+
+// versionLess orders semantic versions by precedence and all other versions lexically after them.
+func versionLess(a, b string) bool {
+	va, errA := version.NewVersion(a)
+	vb, errB := version.NewVersion(b)
+
+	switch {
+	case errA == nil && errB == nil:
+		return va.LessThan(vb)
+	case errA == nil:
+		return true
+	case errB == nil:
+		return false
+	default:
+		return a < b
+	}
+}
+
 type downloadRequest struct {
 	namespace string
 	name      string
@@ -58,7 +180,10 @@ type downloadRequest struct {
 	version   string
 }
 
-type downloadResponse struct{ url string }
+type downloadResponse struct {
+	url    string
+	digest string
+}
 
 func downloadEndpoint(svc Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
@@ -70,7 +195,355 @@ func downloadEndpoint(svc Service) endpoint.Endpoint {
 		}
 
 		return downloadResponse{
-			url: res.DownloadURL,
+			url:    res.DownloadURL,
+			digest: res.Digest,
+		}, nil
+	}
+}
+
+type uploadRequest struct {
+	namespace string
+	name      string
+	provider  string
+	version   string
+	body      io.Reader
+}
+
+type uploadResponse struct {
+	Module
+}
+
+// StatusCode implements httptransport.StatusCoder.
+func (uploadResponse) StatusCode() int {
+	return http.StatusCreated
+}
+
+func uploadEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(uploadRequest)
+
+		res, err := svc.UploadModule(ctx, req.namespace, req.name, req.provider, req.version, req.body)
+		if err != nil {
+			return nil, err
+		}
+
+		return uploadResponse{res}, nil
+	}
+}
+
+type annotationsRequest struct {
+	namespace string
+	name      string
+	provider  string
+	version   string
+	// annotation is only set when adding an annotation.
+	annotation *Annotation
+}
+
+type annotationsResponse struct {
+	Annotations []Annotation `json:"annotations"`
+}
+
+type addAnnotationResponse struct {
+	Annotation
+}
+
+// StatusCode implements httptransport.StatusCoder.
+func (addAnnotationResponse) StatusCode() int {
+	return http.StatusCreated
+}
+
+func listAnnotationsEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(annotationsRequest)
+
+		res, err := svc.ListAnnotations(ctx, req.namespace, req.name, req.provider, req.version)
+		if err != nil {
+			return nil, err
+		}
+
+		return annotationsResponse{
+			Annotations: res,
+		}, nil
+	}
+}
+
+func addAnnotationEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(annotationsRequest)
+
+		res, err := svc.AddAnnotation(ctx, req.namespace, req.name, req.provider, req.version, *req.annotation)
+		if err != nil {
+			return nil, err
+		}
+
+		return addAnnotationResponse{res}, nil
+	}
+}
+
+type approveRequest struct {
+	namespace string
+	name      string
+	provider  string
+	version   string
+	approver  string
+}
+
+func approveEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(approveRequest)
+
+		res, err := svc.ApproveModule(ctx, req.namespace, req.name, req.provider, req.version, req.approver)
+		if err != nil {
+			return nil, err
+		}
+
+		return res, nil
+	}
+}
+
+type labelsRequest struct {
+	namespace string
+	name      string
+	provider  string
+	// labels are only set when replacing the labels.
+	labels Labels
+}
+
+type labelsResponse struct {
+	Labels Labels `json:"labels"`
+}
+
+func getLabelsEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(labelsRequest)
+
+		res, err := svc.GetLabels(ctx, req.namespace, req.name, req.provider)
+		if err != nil {
+			return nil, err
+		}
+
+		return labelsResponse{
+			Labels: res,
+		}, nil
+	}
+}
+
+func setLabelsEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(labelsRequest)
+
+		res, err := svc.SetLabels(ctx, req.namespace, req.name, req.provider, req.labels)
+		if err != nil {
+			return nil, err
+		}
+
+		return labelsResponse{
+			Labels: res,
+		}, nil
+	}
+}
+
+type modulesRequest struct {
+	namespace string
+	// selector only lists the modules with matching labels.
+	selector LabelSelector
+	// filter hides modules from the listing, e.g. those restricted by an ACL.
+	filter func(Module) bool
+}
+
+type modulesResponseModule struct {
+	ID        string `json:"id"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Provider  string `json:"provider"`
+	// Version is the latest version of the module.
+	Version     string     `json:"version"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+	Maturity    Maturity   `json:"maturity,omitempty"`
+	Labels      Labels     `json:"labels"`
+}
+
+type modulesResponse struct {
+	Modules []modulesResponseModule `json:"modules"`
+}
+
+// modulesEndpoint lists the modules of a namespace with their latest version and labels.
+func modulesEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(modulesRequest)
+
+		res, err := svc.ListModules(ctx, req.namespace)
+		if err != nil {
+			return nil, err
+		}
+
+		response := modulesResponse{
+			Modules: []modulesResponseModule{},
+		}
+
+		for _, module := range latestVersions(res) {
+			module.Namespace = req.namespace
+			if req.filter != nil && !req.filter(module) {
+				continue
+			}
+
+			labels, err := svc.GetLabels(ctx, module.Namespace, module.Name, module.Provider)
+			if err != nil {
+				return nil, err
+			}
+			if !req.selector.Matches(labels) {
+				continue
+			}
+
+			m, err := summarizeModule(ctx, svc, module, labels)
+			if err != nil {
+				return nil, err
+			}
+			response.Modules = append(response.Modules, m)
+		}
+
+		return response, nil
+	}
+}
+
+// summarizeModule returns the latest version of a module with its maturity and labels for listings.
+func summarizeModule(ctx context.Context, svc Service, module Module, labels Labels) (modulesResponseModule, error) {
+	maturities, err := svc.ListMaturities(ctx, module.Namespace, module.Name, module.Provider)
+	if err != nil {
+		return modulesResponseModule{}, err
+	}
+
+	m := modulesResponseModule{
+		ID:        fmt.Sprintf("%s/%s/%s/%s", module.Namespace, module.Name, module.Provider, module.Version),
+		Namespace: module.Namespace,
+		Name:      module.Name,
+		Provider:  module.Provider,
+		Version:   module.Version,
+		Maturity:  maturityOf(maturities, module.Version),
+		Labels:    labels,
+	}
+	if !module.Created.IsZero() {
+		created := module.Created.UTC()
+		m.PublishedAt = &created
+	}
+
+	return m, nil
+}
+
+// latestVersions returns the latest version of every module, sorted by module.
+// Preview versions are only returned for modules without any other version.
+func latestVersions(modules []Module) []Module {
+	latest := make(map[string]Module)
+	for _, module := range modules {
+		id := fmt.Sprintf("%s/%s", module.Name, module.Provider)
+
+		current, ok := latest[id]
+		switch {
+		case !ok:
+		case IsPreview(current.Version) != IsPreview(module.Version):
+			if IsPreview(module.Version) {
+				continue
+			}
+		case !versionLess(current.Version, module.Version):
+			continue
+		}
+		latest[id] = module
+	}
+
+	res := make([]Module, 0, len(latest))
+	for _, module := range latest {
+		res = append(res, module)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Name != res[j].Name {
+			return res[i].Name < res[j].Name
+		}
+		return res[i].Provider < res[j].Provider
+	})
+
+	return res
+}
+
+type changesRequest struct {
+	namespace string
+	from      time.Time
+	to        time.Time
+	// filter hides modules from the report, e.g. those restricted by an ACL.
+	filter func(Module) bool
+}
+
+type changesResponse struct {
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	Changes []Change  `json:"changes"`
+}
+
+// changesEndpoint reports the changes of the modules of a namespace between two points in time.
+func changesEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(changesRequest)
+
+		res, err := catalogChanges(ctx, svc, req.namespace, req.from, req.to, req.filter)
+		if err != nil {
+			return nil, err
+		}
+
+		return changesResponse{
+			From:    req.from,
+			To:      req.to,
+			Changes: res,
+		}, nil
+	}
+}
+
+type maturityRequest struct {
+	namespace string
+	name      string
+	provider  string
+	version   string
+	maturity  Maturity
+	// reason explains why a version is deprecated.
+	reason string
+}
+
+func maturityEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(maturityRequest)
+
+		res, err := svc.SetMaturity(ctx, req.namespace, req.name, req.provider, req.version, req.maturity, req.reason)
+		if err != nil {
+			return nil, err
+		}
+
+		return res, nil
+	}
+}
+
+type archiveRequest struct {
+	namespace string
+	name      string
+	provider  string
+	version   string
+	expires   int64
+	signature string
+}
+
+type archiveResponse struct {
+	body io.ReadCloser
+}
+
+func archiveEndpoint(storage *ProxyStorage) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(archiveRequest)
+
+		res, err := storage.OpenSignedModule(ctx, req.namespace, req.name, req.provider, req.version, req.expires, req.signature)
+		if err != nil {
+			return nil, err
+		}
+
+		return archiveResponse{
+			body: res,
 		}, nil
 	}
 }
@@ -0,0 +1,148 @@
+package module
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReleasesEndpoint(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	storage := NewInmemStorage()
+	for _, version := range []string{"1.10.0", "1.2.0", "2.0.0-rc.1", "1.9.0"} {
+		_, err := storage.UploadModule(context.Background(), "tier", "test", "aws", version, strings.NewReader(version))
+		assert.NoError(err)
+	}
+
+	res, err := releasesEndpoint(NewService(storage))(context.Background(), listRequest{namespace: "tier", name: "test", provider: "aws"})
+	assert.NoError(err)
+
+	response := res.(releasesResponse)
+
+	var versions []string
+	for _, release := range response.Releases {
+		versions = append(versions, release.Version)
+		assert.NotNil(release.ReleaseTimestamp)
+	}
+
+	assert.Equal([]string{"1.2.0", "1.9.0", "1.10.0", "2.0.0-rc.1"}, versions)
+	assert.Equal(map[string]string{"latest": "1.10.0"}, response.Tags)
+}
+
+func TestListEndpoint_PublishedAt(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	storage := NewInmemStorage()
+	_, err := storage.UploadModule(context.Background(), "tier", "test", "aws", "1.0.0", strings.NewReader("1.0.0"))
+	assert.NoError(err)
+
+	res, err := listEndpoint(NewService(storage))(context.Background(), listRequest{namespace: "tier", name: "test", provider: "aws"})
+	assert.NoError(err)
+
+	versions := res.(listResponse).Modules[0].Versions
+	if assert.Len(versions, 1) {
+		assert.Equal("1.0.0", versions[0].Version)
+		if assert.NotNil(versions[0].PublishedAt) {
+			assert.Equal(time.UTC, versions[0].PublishedAt.Location())
+		}
+	}
+}
+
+func TestVersionLess(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		a, b     string
+		expected bool
+	}{
+		{a: "1.9.0", b: "1.10.0", expected: true},
+		{a: "1.0.0-rc.1", b: "1.0.0", expected: true},
+		{a: "1.0.0", b: "latest", expected: true},
+		{a: "latest", b: "1.0.0", expected: false},
+		{a: "alpha", b: "beta", expected: true},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, versionLess(tc.a, tc.b), "%s < %s", tc.a, tc.b)
+	}
+}
+
+func TestUploadedBefore(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	modules := []Module{
+		{Version: "1.0.0", Created: now.Add(-2 * time.Hour)},
+		{Version: "1.1.0", Created: now},
+		{Version: "1.2.0"},
+	}
+
+	testCases := []struct {
+		name     string
+		asOf     time.Time
+		expected []string
+	}{
+		{name: "all versions", expected: []string{"1.0.0", "1.1.0", "1.2.0"}},
+		{name: "snapshot", asOf: now.Add(-time.Hour), expected: []string{"1.0.0", "1.2.0"}},
+		{name: "inclusive", asOf: now, expected: []string{"1.0.0", "1.1.0", "1.2.0"}},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var versions []string
+			for _, module := range uploadedBefore(modules, tc.asOf) {
+				versions = append(versions, module.Version)
+			}
+			assert.Equal(t, tc.expected, versions)
+		})
+	}
+}
+
+func TestDecodeListRequest_AsOf(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.WithValue(context.Background(), varNamespace, "tier")
+	ctx = context.WithValue(ctx, varName, "test")
+	ctx = context.WithValue(ctx, varProvider, "aws")
+
+	testCases := []struct {
+		name     string
+		query    string
+		expected time.Time
+		err      bool
+	}{
+		{name: "without snapshot"},
+		{name: "rfc3339", query: "?as_of=2024-05-01T09:00:00Z", expected: time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)},
+		{name: "unix timestamp", query: "?as_of=1714554000", expected: time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)},
+		{name: "invalid", query: "?as_of=yesterday", err: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+
+			res, err := decodeListRequest(ctx, httptest.NewRequest(http.MethodGet, "/tier/test/aws/versions"+tc.query, nil))
+			if tc.err {
+				assert.True(errors.Is(err, ErrInvalidQuery))
+				return
+			}
+
+			assert.NoError(err)
+			assert.True(tc.expected.Equal(res.(listRequest).asOf))
+		})
+	}
+}
@@ -1,16 +1,153 @@
 package module
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+
+	"cloud.google.com/go/storage"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"google.golang.org/api/googleapi"
+)
 
 // Storage errors.
 var (
 	ErrAlreadyExists = errors.New("module already exists")
 	ErrNotFound      = errors.New("failed to locate module")
+	ErrGetFailed     = errors.New("failed to get module")
 	ErrUploadFailed  = errors.New("failed to upload module")
 	ErrListFailed    = errors.New("failed to list module versions")
+	ErrDeleteFailed  = errors.New("failed to delete module")
+	// ErrChecksumMismatch is returned if the storage backend received different bytes than were sent,
+	// or if a stored archive doesn't match the digest recorded on upload.
+	ErrChecksumMismatch = errors.New("module checksum mismatch")
+	// ErrReserved is returned if a module is uploaded to a reserved namespace or name.
+	ErrReserved = errors.New("module address is reserved")
+	// ErrOverloaded is returned if an upload is rejected to keep downloads working while the registry is saturated.
+	ErrOverloaded = errors.New("registry is overloaded")
+
+	ErrAnnotationFailed = errors.New("failed to annotate module")
+	ErrApprovalFailed   = errors.New("failed to approve module")
+	ErrScheduleFailed   = errors.New("failed to schedule module")
+	ErrLabelFailed      = errors.New("failed to label module")
+	ErrMaturityFailed   = errors.New("failed to set module maturity")
+	ErrDocsFailed       = errors.New("failed to store module docs")
+)
+
+// Storage backend errors, which tell why a storage operation failed.
+var (
+	ErrAccessDenied = errors.New("storage denied access")
+	ErrThrottled    = errors.New("storage is throttling requests")
+	ErrTimeout      = errors.New("storage request timed out")
 )
 
 // Transport errors.
 var (
-	ErrVarMissing = errors.New("variable missing")
+	ErrVarMissing     = errors.New("variable missing")
+	ErrInvalidQuery   = errors.New("invalid query parameter")
+	ErrInvalidAddress = errors.New("invalid module address")
+
+	ErrInvalidAnnotation = errors.New("invalid annotation")
+	ErrInvalidLabel      = errors.New("invalid label")
+	ErrInvalidMaturity   = errors.New("invalid maturity")
+	ErrInvalidArchive    = errors.New("invalid module archive")
+	ErrArchiveTooLarge   = errors.New("module archive too large")
+	ErrInvalidSignature  = errors.New("invalid download signature")
 )
+
+// storageError wraps an error returned by a storage backend.
+// It matches the registry error kind and the reason of the backend error with errors.Is,
+// errors.Cause returns the reason if the backend error is known and the kind otherwise,
+// while the backend error stays reachable with errors.As.
+type storageError struct {
+	kind   error
+	reason error
+	err    error
+}
+
+func wrapStorageError(kind, err error) error {
+	return &storageError{kind: kind, reason: storageErrorReason(err), err: err}
+}
+
+func (e *storageError) Error() string {
+	return fmt.Sprintf("%s: %s", e.kind, e.err)
+}
+
+func (e *storageError) Is(target error) bool {
+	return target == e.kind || (e.reason != nil && target == e.reason)
+}
+
+func (e *storageError) Unwrap() error {
+	return e.err
+}
+
+func (e *storageError) Cause() error {
+	if e.reason != nil {
+		return e.reason
+	}
+	return e.kind
+}
+
+// storageErrorReason maps the error codes of the storage backends to registry errors, it returns nil for unknown errors.
+func storageErrorReason(err error) error {
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		switch awsErr.Code() {
+		case "NotFound", s3.ErrCodeNoSuchKey:
+			return ErrNotFound
+		case "AccessDenied", "Forbidden":
+			return ErrAccessDenied
+		case "SlowDown", "Throttling", "RequestLimitExceeded":
+			return ErrThrottled
+		case "RequestTimeout", request.ErrCodeResponseTimeout:
+			return ErrTimeout
+		case "PreconditionFailed", "ConditionalRequestConflict":
+			// Conditional writes are rejected if the object was created concurrently
+			return ErrAlreadyExists
+		}
+
+		// The SDK doesn't support unwrapping, e.g. timeouts of the HTTP client are only reachable as the original error
+		if orig := awsErr.OrigErr(); orig != nil {
+			return storageErrorReason(orig)
+		}
+	}
+
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.Code {
+		case http.StatusNotFound:
+			return ErrNotFound
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return ErrAccessDenied
+		case http.StatusTooManyRequests:
+			return ErrThrottled
+		case http.StatusRequestTimeout:
+			return ErrTimeout
+		case http.StatusPreconditionFailed:
+			return ErrAlreadyExists
+		}
+	}
+
+	switch {
+	case errors.Is(err, storage.ErrObjectNotExist), errors.Is(err, os.ErrNotExist):
+		return ErrNotFound
+	case errors.Is(err, os.ErrPermission):
+		return ErrAccessDenied
+	case errors.Is(err, os.ErrExist):
+		return ErrAlreadyExists
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrTimeout
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrTimeout
+	}
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package module
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	pkgerrors "github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/googleapi"
+)
+
+type backendError struct{}
+
+func (backendError) Error() string { return "access denied" }
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestStorageError(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	err := pkgerrors.Wrap(wrapStorageError(ErrUploadFailed, backendError{}), "key")
+
+	var target backendError
+	assert.True(errors.Is(err, ErrUploadFailed))
+	assert.True(errors.As(err, &target))
+	assert.Equal(ErrUploadFailed, pkgerrors.Cause(err))
+	assert.Equal("key: failed to upload module: access denied", err.Error())
+}
+
+func TestStorageError_Reason(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name           string
+		err            error
+		expectedReason error
+		expectedStatus int
+	}{
+		{
+			name:           "unknown",
+			err:            backendError{},
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:           "s3 no such key",
+			err:            awserr.New("NoSuchKey", "The specified key does not exist.", nil),
+			expectedReason: ErrNotFound,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "s3 access denied",
+			err:            awserr.NewRequestFailure(awserr.New("AccessDenied", "Access Denied", nil), http.StatusForbidden, "id"),
+			expectedReason: ErrAccessDenied,
+			expectedStatus: http.StatusBadGateway,
+		},
+		{
+			name:           "s3 slow down",
+			err:            awserr.New("SlowDown", "Please reduce your request rate.", nil),
+			expectedReason: ErrThrottled,
+			expectedStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:           "s3 request timeout",
+			err:            awserr.New("RequestTimeout", "Your socket connection to the server was not read from or written to within the timeout period.", nil),
+			expectedReason: ErrTimeout,
+			expectedStatus: http.StatusGatewayTimeout,
+		},
+		{
+			name:           "s3 client timeout",
+			err:            awserr.New("RequestError", "send request failed", &url.Error{Op: "Get", URL: "https://s3.amazonaws.com", Err: timeoutError{}}),
+			expectedReason: ErrTimeout,
+			expectedStatus: http.StatusGatewayTimeout,
+		},
+		{
+			name:           "s3 conditional write",
+			err:            awserr.New("MultipartUpload", "upload multipart failed", awserr.New("PreconditionFailed", "At least one of the pre-conditions you specified did not hold", nil)),
+			expectedReason: ErrAlreadyExists,
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "gcs forbidden",
+			err:            &googleapi.Error{Code: http.StatusForbidden},
+			expectedReason: ErrAccessDenied,
+			expectedStatus: http.StatusBadGateway,
+		},
+		{
+			name:           "gcs rate limit",
+			err:            &googleapi.Error{Code: http.StatusTooManyRequests},
+			expectedReason: ErrThrottled,
+			expectedStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:           "gcs precondition",
+			err:            &googleapi.Error{Code: http.StatusPreconditionFailed},
+			expectedReason: ErrAlreadyExists,
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "local permission",
+			err:            &os.PathError{Op: "open", Path: "modules", Err: os.ErrPermission},
+			expectedReason: ErrAccessDenied,
+			expectedStatus: http.StatusBadGateway,
+		},
+		{
+			name:           "local exists",
+			err:            &os.LinkError{Op: "link", Old: ".tmp-123", New: "modules", Err: os.ErrExist},
+			expectedReason: ErrAlreadyExists,
+			expectedStatus: http.StatusConflict,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+
+			err := wrapStorageError(ErrListFailed, tc.err)
+			assert.True(errors.Is(err, ErrListFailed))
+			if tc.expectedReason != nil {
+				assert.True(errors.Is(err, tc.expectedReason))
+				assert.Equal(tc.expectedReason, pkgerrors.Cause(err))
+			}
+
+			rec := httptest.NewRecorder()
+			ErrorEncoder(context.Background(), err, rec)
+			assert.Equal(tc.expectedStatus, rec.Code)
+			assert.Equal("application/json; charset=utf-8", rec.Header().Get("Content-Type"))
+
+			retry := tc.expectedReason == ErrThrottled || tc.expectedReason == ErrTimeout
+			assert.Equal(retry, rec.Header().Get("Retry-After") != "")
+		})
+	}
+}
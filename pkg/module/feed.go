@@ -0,0 +1,214 @@
+package module
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/pkg/errors"
+)
+
+const (
+	feedFileName   = "feed.atom"
+	feedMaxEntries = 50
+)
+
+type feedRequest struct {
+	namespace string
+	// name and provider are empty for the feed of a namespace.
+	name     string
+	provider string
+	// url is the absolute URL of the feed.
+	url string
+	// filter hides modules from the feed of a namespace, e.g. those restricted by an ACL.
+	filter func(Module) bool
+	// selector only includes the modules with matching labels in the feed of a namespace.
+	selector LabelSelector
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Link    atomLink    `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID      string   `xml:"id"`
+	Title   string   `xml:"title"`
+	Updated string   `xml:"updated"`
+	Link    atomLink `xml:"link"`
+	Summary string   `xml:"summary"`
+}
+
+// feedEndpoint returns an Atom feed of the most recently published versions of a module or namespace.
+func feedEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(feedRequest)
+
+		var (
+			res   []Module
+			err   error
+			title = fmt.Sprintf("%s/%s/%s", req.namespace, req.name, req.provider)
+		)
+
+		if req.name == "" {
+			title = req.namespace
+			res, err = svc.ListModules(ctx, req.namespace)
+		} else {
+			res, err = svc.ListModuleVersions(ctx, req.namespace, req.name, req.provider)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var (
+			modules []Module
+			// matches caches whether the labels of a module match the selector
+			matches = make(map[string]bool)
+		)
+		for _, module := range res {
+			// Not all storage backends set the module of listed versions
+			module.Namespace = req.namespace
+			if req.name != "" {
+				module.Name, module.Provider = req.name, req.provider
+			}
+			if req.filter != nil && !req.filter(module) {
+				continue
+			}
+
+			if len(req.selector) > 0 {
+				id := module.ID(false)
+				match, ok := matches[id]
+				if !ok {
+					labels, err := svc.GetLabels(ctx, module.Namespace, module.Name, module.Provider)
+					if err != nil {
+						return nil, err
+					}
+					match = req.selector.Matches(labels)
+					matches[id] = match
+				}
+				if !match {
+					continue
+				}
+			}
+
+			modules = append(modules, module)
+		}
+
+		sort.SliceStable(modules, func(i, j int) bool {
+			if !modules[i].Created.Equal(modules[j].Created) {
+				return modules[i].Created.After(modules[j].Created)
+			}
+			return versionLess(modules[j].Version, modules[i].Version)
+		})
+
+		if len(modules) > feedMaxEntries {
+			modules = modules[:feedMaxEntries]
+		}
+
+		base := strings.TrimSuffix(req.url, feedFileName)
+		if req.name != "" {
+			base = strings.TrimSuffix(base, fmt.Sprintf("%s/%s/", req.name, req.provider))
+		}
+
+		feed := atomFeed{
+			ID:      req.url,
+			Title:   fmt.Sprintf("New versions of %s", title),
+			Updated: feedTime(time.Time{}),
+			Author:  atomAuthor{Name: "boring-registry"},
+			Link:    atomLink{Href: req.url, Rel: "self"},
+		}
+
+		for i, module := range modules {
+			if i == 0 {
+				feed.Updated = feedTime(module.Created)
+			}
+
+			link := fmt.Sprintf("%s%s/%s/%s/download", base, module.Name, module.Provider, module.Version)
+			feed.Entries = append(feed.Entries, atomEntry{
+				ID:      link,
+				Title:   fmt.Sprintf("%s/%s/%s %s", module.Namespace, module.Name, module.Provider, module.Version),
+				Updated: feedTime(module.Created),
+				Link:    atomLink{Href: link},
+				Summary: fmt.Sprintf("Version %s of the module %s/%s/%s has been published.", module.Version, module.Namespace, module.Name, module.Provider),
+			})
+		}
+
+		return feed, nil
+	}
+}
+
+func feedTime(t time.Time) string {
+	if t.IsZero() {
+		t = time.Unix(0, 0)
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func decodeFeedRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	namespace, ok := ctx.Value(varNamespace).(string)
+	if !ok {
+		return nil, errors.Wrap(ErrVarMissing, "namespace")
+	}
+
+	// The name and provider are only set for the feed of a module
+	name, _ := ctx.Value(varName).(string)
+	provider, _ := ctx.Value(varProvider).(string)
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+
+	// The request URI still contains prefixes stripped from the path by the server
+	uri, err := url.ParseRequestURI(r.RequestURI)
+	if err != nil {
+		return nil, err
+	}
+
+	req := feedRequest{
+		namespace: namespace,
+		name:      name,
+		provider:  provider,
+		url:       fmt.Sprintf("%s://%s%s", scheme, r.Host, uri.EscapedPath()),
+	}
+
+	// Labels belong to modules, so only the feed of a namespace can be filtered by them
+	if name == "" {
+		if req.selector, err = ParseLabelSelector(r.URL.Query()["label"]); err != nil {
+			return nil, err
+		}
+	}
+
+	return req, nil
+}
+
+func encodeFeedResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	if _, err := w.Write([]byte(xml.Header)); err != nil {
+		return err
+	}
+	return xml.NewEncoder(w).Encode(response)
+}
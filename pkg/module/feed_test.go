@@ -0,0 +1,77 @@
+package module
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeed(t *testing.T) {
+	t.Parallel()
+
+	storage := NewInmemStorage()
+	for _, m := range []Module{
+		{Namespace: "tier", Name: "vpc", Provider: "aws", Version: "1.0.0"},
+		{Namespace: "tier", Name: "vpc", Provider: "aws", Version: "1.1.0"},
+		{Namespace: "tier", Name: "secret", Provider: "aws", Version: "1.0.0"},
+		{Namespace: "other", Name: "dns", Provider: "aws", Version: "1.0.0"},
+	} {
+		_, err := storage.UploadModule(context.Background(), m.Namespace, m.Name, m.Provider, m.Version, strings.NewReader(m.ID(true)))
+		assert.NoError(t, err)
+	}
+
+	handler := http.StripPrefix("/v1/modules", MakeHandler(
+		NewService(storage),
+		endpoint.Chain(ACLMiddleware(ACL{"tier/secret/aws": {"secret"}})),
+		httptransport.ServerBefore(httptransport.PopulateRequestContext),
+	))
+
+	testCases := []struct {
+		name     string
+		path     string
+		expected []string
+	}{
+		{
+			name:     "module feed",
+			path:     "/v1/modules/tier/vpc/aws/feed.atom",
+			expected: []string{"/v1/modules/tier/vpc/aws/1.1.0/download", "/v1/modules/tier/vpc/aws/1.0.0/download"},
+		},
+		{
+			name:     "namespace feed hides restricted modules",
+			path:     "/v1/modules/tier/feed.atom",
+			expected: []string{"/v1/modules/tier/vpc/aws/1.1.0/download", "/v1/modules/tier/vpc/aws/1.0.0/download"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+
+			req := httptest.NewRequest(http.MethodGet, "http://registry.example.com"+tc.path, nil)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(http.StatusOK, rec.Code)
+			assert.Equal("application/atom+xml; charset=utf-8", rec.Header().Get("Content-Type"))
+
+			var feed atomFeed
+			assert.NoError(xml.Unmarshal(rec.Body.Bytes(), &feed))
+			assert.Equal("http://registry.example.com"+tc.path, feed.ID)
+
+			var links []string
+			for _, entry := range feed.Entries {
+				links = append(links, strings.TrimPrefix(entry.Link.Href, "http://registry.example.com"))
+			}
+			assert.ElementsMatch(tc.expected, links)
+		})
+	}
+}
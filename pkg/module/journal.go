@@ -0,0 +1,302 @@
+package module
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// JournalEntry is an upload recorded in the journal before the storage is touched.
+// The digest is only known if the client declared it for the archive.
+type JournalEntry struct {
+	ID        string    `json:"id"`
+	Namespace string    `json:"namespace"`
+	Name      string    `json:"name"`
+	Provider  string    `json:"provider"`
+	Version   string    `json:"version"`
+	Digest    string    `json:"digest"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// Journal durably records uploads together with their archives, so uploads interrupted by a crash of the server
+// can be completed when it starts again.
+type Journal interface {
+	// Begin records an upload and returns the ID of its entry once the entry is durable.
+	Begin(entry JournalEntry, archive io.Reader) (string, error)
+	// Complete removes the entry of an upload whose outcome is known.
+	Complete(id string) error
+	// Pending returns the entries of incomplete uploads, the oldest first.
+	Pending() ([]JournalEntry, error)
+	// Archive opens the archive of an entry.
+	Archive(id string) (io.ReadCloser, error)
+}
+
+const (
+	journalExt     = ".journal"
+	journalTmpGlob = ".tmp-*"
+)
+
+// FileJournal is a Journal in a local directory. Every entry is a single file with the entry as JSON in its first line,
+// followed by the archive, which is written to a temporary file first and renamed, so entries are never incomplete.
+type FileJournal struct {
+	dir string
+}
+
+// NewFileJournal returns a journal in the directory, which is created if it doesn't exist.
+// Temporary files of entries which were being written when the server crashed are removed.
+func NewFileJournal(dir string) (*FileJournal, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.Wrap(err, "failed to create journal directory")
+	}
+
+	tmp, err := filepath.Glob(filepath.Join(dir, journalTmpGlob))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for _, name := range tmp {
+		if err := os.Remove(name); err != nil {
+			return nil, errors.Wrap(err, "failed to remove incomplete journal entry")
+		}
+	}
+
+	return &FileJournal{dir: dir}, nil
+}
+
+// Begin streams the entry and archive to disk and syncs them.
+func (j *FileJournal) Begin(entry JournalEntry, archive io.Reader) (string, error) {
+	id, err := journalID()
+	if err != nil {
+		return "", err
+	}
+	entry.ID = id
+
+	header, err := json.Marshal(entry)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := ioutil.TempFile(j.dir, journalTmpGlob)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create journal entry")
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := io.Copy(f, io.MultiReader(bytes.NewReader(header), strings.NewReader("\n"), archive)); err != nil {
+		f.Close()
+		return "", errors.Wrap(err, "failed to write journal entry")
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return "", errors.Wrap(err, "failed to sync journal entry")
+	}
+	if err := f.Close(); err != nil {
+		return "", errors.Wrap(err, "failed to write journal entry")
+	}
+
+	if err := os.Rename(f.Name(), j.path(id)); err != nil {
+		return "", errors.Wrap(err, "failed to write journal entry")
+	}
+
+	// The rename is only durable once the directory is synced
+	if err := syncDir(j.dir); err != nil {
+		return "", errors.Wrap(err, "failed to sync journal directory")
+	}
+
+	return id, nil
+}
+
+// Complete removes the file of the entry.
+func (j *FileJournal) Complete(id string) error {
+	if err := os.Remove(j.path(id)); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "failed to complete journal entry")
+	}
+	return nil
+}
+
+// Pending reads the entries of all files of the journal.
+func (j *FileJournal) Pending() ([]JournalEntry, error) {
+	files, err := ioutil.ReadDir(j.dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read journal")
+	}
+
+	var entries []JournalEntry
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), journalExt) {
+			continue
+		}
+
+		entry, err := j.read(strings.TrimSuffix(file.Name(), journalExt))
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	// IDs start with the time the entry was written
+	sort.Slice(entries, func(i, k int) bool { return entries[i].ID < entries[k].ID })
+
+	return entries, nil
+}
+
+// Archive opens the file of an entry and skips the entry, so the archive is streamed from disk.
+func (j *FileJournal) Archive(id string) (io.ReadCloser, error) {
+	f, err := os.Open(j.path(id))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read journal entry")
+	}
+
+	header, err := readJournalHeader(f)
+	if err != nil {
+		f.Close()
+		return nil, errors.Wrapf(err, "failed to read journal entry %s", id)
+	}
+
+	// The buffered reader reads ahead of the header, so the file is positioned at the archive again
+	if _, err := f.Seek(int64(len(header)), io.SeekStart); err != nil {
+		f.Close()
+		return nil, errors.Wrapf(err, "failed to read journal entry %s", id)
+	}
+
+	return f, nil
+}
+
+func (j *FileJournal) read(id string) (JournalEntry, error) {
+	f, err := os.Open(j.path(id))
+	if err != nil {
+		return JournalEntry{}, errors.Wrap(err, "failed to read journal entry")
+	}
+	defer f.Close()
+
+	header, err := readJournalHeader(f)
+	if err != nil {
+		return JournalEntry{}, errors.Wrapf(err, "failed to read journal entry %s", id)
+	}
+
+	var entry JournalEntry
+	if err := json.Unmarshal(header, &entry); err != nil {
+		return JournalEntry{}, errors.Wrapf(err, "failed to decode journal entry %s", id)
+	}
+
+	return entry, nil
+}
+
+// readJournalHeader reads the first line of an entry, which holds the entry as JSON.
+func readJournalHeader(r io.Reader) ([]byte, error) {
+	return bufio.NewReader(r).ReadBytes('\n')
+}
+
+func (j *FileJournal) path(id string) string {
+	return filepath.Join(j.dir, id+journalExt)
+}
+
+// journalID returns an ID which sorts by the time it was created.
+func journalID() (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.WithStack(err)
+	}
+	return fmt.Sprintf("%020d-%s", time.Now().UnixNano(), hex.EncodeToString(b)), nil
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// ReplayJournal completes the uploads of all pending journal entries and returns the replayed entries.
+// Entries whose version exists already with another archive were rejected, so only their entry is removed.
+// Entries which fail to replay are kept for the next replay.
+func ReplayJournal(ctx context.Context, storage Storage, journal Journal) ([]JournalEntry, error) {
+	entries, err := journal.Pending()
+	if err != nil {
+		return nil, err
+	}
+
+	var replayed []JournalEntry
+	for _, entry := range entries {
+		if err := replayUpload(ctx, storage, journal, entry); err != nil {
+			return replayed, errors.Wrapf(err, "failed to replay upload of %s/%s/%s/%s", entry.Namespace, entry.Name, entry.Provider, entry.Version)
+		}
+
+		if err := journal.Complete(entry.ID); err != nil {
+			return replayed, err
+		}
+		replayed = append(replayed, entry)
+	}
+
+	return replayed, nil
+}
+
+func replayUpload(ctx context.Context, storage Storage, journal Journal, entry JournalEntry) error {
+	archive, err := journal.Archive(entry.ID)
+	if err != nil {
+		return err
+	}
+	_, err = storage.UploadModule(ctx, entry.Namespace, entry.Name, entry.Provider, entry.Version, archive)
+	archive.Close()
+
+	if errors.Cause(err) == ErrAlreadyExists {
+		module, err := storage.GetModule(ctx, entry.Namespace, entry.Name, entry.Provider, entry.Version)
+		if err != nil {
+			return err
+		}
+		digest, err := journalDigest(journal, entry.ID)
+		if err != nil {
+			return err
+		}
+		if module.Digest != digest {
+			return nil
+		}
+	} else if err != nil {
+		return err
+	}
+
+	archive, err = journal.Archive(entry.ID)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	docs, err := extractDocs(archive)
+	if err != nil {
+		// The documentation is extracted from the archive again when it is requested
+		return nil
+	}
+
+	return storage.SetDocs(ctx, entry.Namespace, entry.Name, entry.Provider, entry.Version, docs)
+}
+
+// journalDigest returns the digest of the archive of an entry.
+func journalDigest(journal Journal, id string) (string, error) {
+	archive, err := journal.Archive(id)
+	if err != nil {
+		return "", err
+	}
+	defer archive.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, archive); err != nil {
+		return "", errors.Wrapf(err, "failed to read journal entry %s", id)
+	}
+
+	return hashDigest(hash), nil
+}
@@ -0,0 +1,167 @@
+package module
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileJournal(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	assert.NoError(ioutil.WriteFile(filepath.Join(dir, ".tmp-123"), []byte("partial"), 0600))
+
+	journal, err := NewFileJournal(dir)
+	assert.NoError(err)
+
+	// Temporary files of entries interrupted while being written are removed
+	_, err = os.Stat(filepath.Join(dir, ".tmp-123"))
+	assert.True(os.IsNotExist(err))
+
+	first, err := journal.Begin(JournalEntry{Namespace: "tier", Name: "vpc", Provider: "aws", Version: "1.0.0"}, strings.NewReader("first\narchive"))
+	assert.NoError(err)
+	second, err := journal.Begin(JournalEntry{Namespace: "tier", Name: "vpc", Provider: "aws", Version: "1.1.0"}, strings.NewReader("second"))
+	assert.NoError(err)
+
+	entries, err := journal.Pending()
+	assert.NoError(err)
+	if assert.Len(entries, 2) {
+		assert.Equal(first, entries[0].ID)
+		assert.Equal("1.0.0", entries[0].Version)
+		assert.Equal(second, entries[1].ID)
+	}
+
+	archive, err := journal.Archive(first)
+	assert.NoError(err)
+	data, err := ioutil.ReadAll(archive)
+	assert.NoError(err)
+	assert.NoError(archive.Close())
+	assert.Equal("first\narchive", string(data))
+
+	assert.NoError(journal.Complete(first))
+	assert.NoError(journal.Complete(first), "completing an entry twice is harmless")
+
+	entries, err = journal.Pending()
+	assert.NoError(err)
+	if assert.Len(entries, 1) {
+		assert.Equal(second, entries[0].ID)
+	}
+}
+
+func TestReplayJournal(t *testing.T) {
+	t.Parallel()
+
+	archive := testModuleData(map[string]string{"README.md": "# vpc"}).Bytes()
+
+	testCases := []struct {
+		name           string
+		setup          func(storage Storage)
+		storage        func(storage Storage) Storage
+		expectErr      bool
+		expectReplayed int
+		expectDocs     bool
+	}{
+		{
+			name:           "interrupted before upload",
+			setup:          func(storage Storage) {},
+			expectReplayed: 1,
+			expectDocs:     true,
+		},
+		{
+			name: "interrupted before documentation",
+			setup: func(storage Storage) {
+				_, _ = storage.UploadModule(context.Background(), "tier", "vpc", "aws", "1.0.0", testModuleData(map[string]string{"README.md": "# vpc"}))
+			},
+			expectReplayed: 1,
+			expectDocs:     true,
+		},
+		{
+			name: "rejected upload",
+			setup: func(storage Storage) {
+				_, _ = storage.UploadModule(context.Background(), "tier", "vpc", "aws", "1.0.0", testModuleData(map[string]string{"README.md": "# other"}))
+			},
+			expectReplayed: 1,
+		},
+		{
+			name:  "failing storage",
+			setup: func(storage Storage) {},
+			storage: func(storage Storage) Storage {
+				return NewChaosStorage(storage, WithChaosErrorRate(1), WithChaosSeed(1))
+			},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+
+			ctx := context.Background()
+			storage := NewInmemStorage()
+			tc.setup(storage)
+
+			journal, err := NewFileJournal(t.TempDir())
+			assert.NoError(err)
+			_, err = journal.Begin(JournalEntry{Namespace: "tier", Name: "vpc", Provider: "aws", Version: "1.0.0"}, bytes.NewReader(archive))
+			assert.NoError(err)
+
+			replayStorage := storage
+			if tc.storage != nil {
+				replayStorage = tc.storage(storage)
+			}
+
+			replayed, err := ReplayJournal(ctx, replayStorage, journal)
+			assert.Len(replayed, tc.expectReplayed)
+
+			pending, pendingErr := journal.Pending()
+			assert.NoError(pendingErr)
+			if tc.expectErr {
+				assert.Error(err)
+				assert.Len(pending, 1, "failed entries are kept")
+				return
+			}
+			assert.NoError(err)
+			assert.Empty(pending)
+
+			docs, err := storage.GetDocs(ctx, "tier", "vpc", "aws", "1.0.0")
+			if tc.expectDocs {
+				assert.NoError(err)
+				assert.Equal("# vpc", docs.Readme)
+			} else {
+				assert.Equal(ErrNotFound, errors.Cause(err))
+			}
+		})
+	}
+}
+
+func TestService_UploadModuleJournal(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	journal, err := NewFileJournal(t.TempDir())
+	assert.NoError(err)
+
+	svc := NewService(NewInmemStorage(), WithJournal(journal))
+
+	_, err = svc.UploadModule(ctx, "tier", "vpc", "aws", "1.0.0", testModuleData(map[string]string{"README.md": "# vpc"}))
+	assert.NoError(err)
+	_, err = svc.UploadModule(ctx, "tier", "vpc", "aws", "1.0.0", testModuleData(map[string]string{"README.md": "# vpc"}))
+	assert.Equal(ErrAlreadyExists, errors.Cause(err))
+
+	// Uploads whose outcome the client learned aren't replayed
+	pending, err := journal.Pending()
+	assert.NoError(err)
+	assert.Empty(pending)
+}
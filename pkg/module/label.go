@@ -0,0 +1,130 @@
+package module
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/TierMobility/boring-registry/pkg/auth"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/pkg/errors"
+)
+
+const (
+	// maxLabels limits the number of labels of a module.
+	maxLabels = 64
+	// maxLabelValueLength limits the length of the value of a label.
+	maxLabelValueLength = 256
+)
+
+// labelKeyPattern matches label keys like tier or compliance.level, which never contain the colon separating keys from values in queries.
+var labelKeyPattern = regexp.MustCompile(`^[0-9a-z](?:[0-9a-z._-]{0,61}[0-9a-z])?$`)
+
+// Labels are key/value pairs attached to a module, e.g. its domain, compliance level or maturity.
+// Unlike annotations, labels belong to all versions of a module.
+type Labels map[string]string
+
+func (l Labels) validate() error {
+	if len(l) > maxLabels {
+		return errors.Wrapf(ErrInvalidLabel, "a module must not have more than %d labels", maxLabels)
+	}
+
+	for key, value := range l {
+		if !labelKeyPattern.MatchString(key) {
+			return errors.Wrapf(ErrInvalidLabel, "key %q must only contain lowercase letters, digits, dots, dashes and underscores, and not be longer than 63 characters", key)
+		}
+		if len(value) > maxLabelValueLength {
+			return errors.Wrapf(ErrInvalidLabel, "value of %q must not be longer than %d bytes", key, maxLabelValueLength)
+		}
+	}
+
+	return nil
+}
+
+// copyLabels returns a copy of labels, which is never nil.
+func copyLabels(labels Labels) Labels {
+	c := make(Labels, len(labels))
+	for key, value := range labels {
+		c[key] = value
+	}
+	return c
+}
+
+// LabelSelector selects modules by their labels, all of its requirements have to match.
+type LabelSelector []labelRequirement
+
+// labelRequirement requires a label to be set, and to have the given value if hasValue is set.
+type labelRequirement struct {
+	key      string
+	value    string
+	hasValue bool
+}
+
+// ParseLabelSelector parses label requirements in the format key:value, or key to only require the label to be set.
+func ParseLabelSelector(requirements []string) (LabelSelector, error) {
+	var selector LabelSelector
+	for _, s := range requirements {
+		key, value, hasValue := strings.Cut(s, ":")
+		if !labelKeyPattern.MatchString(key) {
+			return nil, errors.Wrapf(ErrInvalidQuery, "label %q must be in the format key:value or key", s)
+		}
+
+		selector = append(selector, labelRequirement{key: key, value: value, hasValue: hasValue})
+	}
+
+	return selector, nil
+}
+
+// Matches reports whether the labels meet all requirements of the selector.
+func (s LabelSelector) Matches(labels Labels) bool {
+	for _, r := range s {
+		value, ok := labels[r.key]
+		if !ok || (r.hasValue && value != r.value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// String returns the requirements of the selector in the format of the label query parameter.
+func (s LabelSelector) String() string {
+	requirements := make([]string, 0, len(s))
+	for _, r := range s {
+		if r.hasValue {
+			requirements = append(requirements, fmt.Sprintf("%s:%s", r.key, r.value))
+		} else {
+			requirements = append(requirements, r.key)
+		}
+	}
+	sort.Strings(requirements)
+
+	return strings.Join(requirements, ",")
+}
+
+// labelPath returns the path of the labels of a module.
+// Labels are kept apart from the module archives, so listing module versions doesn't pick them up.
+func labelPath(prefix, namespace, name, provider string) string {
+	return path.Join(
+		prefix,
+		"labels",
+		fmt.Sprintf("namespace=%s", namespace),
+		fmt.Sprintf("name=%s", name),
+		fmt.Sprintf("provider=%s", provider),
+		"labels.json",
+	)
+}
+
+// SetLabels only allows clients with one of the API keys of annotators to set the labels of modules.
+func (mw *annotatorMiddleware) SetLabels(ctx context.Context, namespace, name, provider string, labels Labels) (Labels, error) {
+	for _, key := range mw.keys {
+		if fmt.Sprintf("Bearer %s", key) == ctx.Value(httptransport.ContextKeyRequestAuthorization) {
+			return mw.Service.SetLabels(ctx, namespace, name, provider, labels)
+		}
+	}
+
+	return nil, auth.ErrForbidden
+}
@@ -0,0 +1,164 @@
+package module
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/TierMobility/boring-registry/pkg/auth"
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLabelSelector(t *testing.T) {
+	t.Parallel()
+
+	labels := Labels{"tier": "networking", "compliance": "pci"}
+
+	testCases := []struct {
+		name          string
+		requirements  []string
+		expected      bool
+		expectedError bool
+	}{
+		{name: "no requirements", expected: true},
+		{name: "value", requirements: []string{"tier:networking"}, expected: true},
+		{name: "other value", requirements: []string{"tier:storage"}},
+		{name: "key only", requirements: []string{"compliance"}, expected: true},
+		{name: "missing key", requirements: []string{"maturity"}},
+		{name: "all requirements", requirements: []string{"tier:networking", "compliance:pci"}, expected: true},
+		{name: "one requirement fails", requirements: []string{"tier:networking", "compliance:sox"}},
+		{name: "empty value", requirements: []string{"tier:"}},
+		{name: "invalid key", requirements: []string{"Tier:networking"}, expectedError: true},
+		{name: "empty key", requirements: []string{":networking"}, expectedError: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+
+			selector, err := ParseLabelSelector(tc.requirements)
+			if tc.expectedError {
+				assert.ErrorIs(err, ErrInvalidQuery)
+				return
+			}
+
+			assert.NoError(err)
+			assert.Equal(tc.expected, selector.Matches(labels))
+		})
+	}
+}
+
+func TestLabels(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	storage := NewInmemStorage()
+	for _, m := range []struct{ name, version string }{
+		{"vpc", "1.0.0"},
+		{"vpc", "1.1.0"},
+		{"vpc", "2.0.0-rc.1"},
+		{"s3", "1.0.0"},
+		{"secrets", "0.1.0"},
+	} {
+		_, err := storage.UploadModule(ctx, "tier", m.name, "aws", m.version, strings.NewReader("data"))
+		assert.NoError(err)
+	}
+	assert.NoError(storage.SetLabels(ctx, "tier", "s3", "aws", Labels{"tier": "storage"}))
+
+	handler := MakeHandler(
+		AnnotatorMiddleware([]string{"platform"})(NewService(storage)),
+		endpoint.Chain(
+			auth.Middleware("platform", "reader"),
+			ACLMiddleware(ACL{"tier/secrets/aws": {"platform"}}),
+		),
+		httptransport.ServerBefore(httptransport.PopulateRequestContext),
+		httptransport.ServerErrorEncoder(ErrorEncoder),
+	)
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	testCases := []struct {
+		name         string
+		path         string
+		token        string
+		body         string
+		expectedCode int
+	}{
+		{name: "reader can't label", path: "/tier/vpc/aws/labels", token: "reader", body: `{"labels": {"tier": "networking"}}`, expectedCode: http.StatusForbidden},
+		{name: "invalid key", path: "/tier/vpc/aws/labels", token: "platform", body: `{"labels": {"Tier": "networking"}}`, expectedCode: http.StatusBadRequest},
+		{name: "invalid body", path: "/tier/vpc/aws/labels", token: "platform", body: `{`, expectedCode: http.StatusBadRequest},
+		{name: "unknown module", path: "/tier/rds/aws/labels", token: "platform", body: `{"labels": {"tier": "storage"}}`, expectedCode: http.StatusNotFound},
+		{name: "labeler", path: "/tier/vpc/aws/labels", token: "platform", body: `{"labels": {"tier": "networking", "maturity": "stable"}}`, expectedCode: http.StatusOK},
+		{name: "restricted module", path: "/tier/secrets/aws/labels", token: "platform", body: `{"labels": {"tier": "networking"}}`, expectedCode: http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		rec := do(http.MethodPut, tc.path, tc.token, tc.body)
+		assert.Equal(tc.expectedCode, rec.Code, tc.name)
+	}
+
+	rec := do(http.MethodGet, "/tier/vpc/aws/labels", "reader", "")
+	assert.Equal(http.StatusOK, rec.Code)
+
+	var labels labelsResponse
+	assert.NoError(json.NewDecoder(rec.Body).Decode(&labels))
+	assert.Equal(Labels{"tier": "networking", "maturity": "stable"}, labels.Labels)
+
+	list := func(query, token string) []modulesResponseModule {
+		rec := do(http.MethodGet, "/tier"+query, token, "")
+		if !assert.Equal(http.StatusOK, rec.Code, query) {
+			return nil
+		}
+
+		var res modulesResponse
+		assert.NoError(json.NewDecoder(rec.Body).Decode(&res))
+		return res.Modules
+	}
+
+	modules := list("", "reader")
+	if assert.Len(modules, 2) {
+		assert.Equal("tier/s3/aws/1.0.0", modules[0].ID)
+		assert.Equal(Labels{"tier": "storage"}, modules[0].Labels)
+		assert.Equal("tier/vpc/aws/1.1.0", modules[1].ID)
+	}
+
+	modules = list("?label=tier:networking", "platform")
+	if assert.Len(modules, 2) {
+		assert.Equal("secrets", modules[0].Name)
+		assert.Equal("vpc", modules[1].Name)
+	}
+
+	modules = list("?label=tier:networking&label=maturity:stable", "platform")
+	if assert.Len(modules, 1) {
+		assert.Equal("vpc", modules[0].Name)
+	}
+
+	assert.Len(list("?label=compliance", "platform"), 0)
+	assert.Equal(http.StatusBadRequest, do(http.MethodGet, "/tier?label=:stable", "reader", "").Code)
+
+	rec = do(http.MethodGet, "/tier/feed.atom?label=tier:networking", "reader", "")
+	assert.Equal(http.StatusOK, rec.Code)
+
+	var feed atomFeed
+	assert.NoError(xml.NewDecoder(rec.Body).Decode(&feed))
+	if assert.Len(feed.Entries, 3) {
+		for _, entry := range feed.Entries {
+			assert.True(strings.HasPrefix(entry.Title, "tier/vpc/aws "), entry.Title)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package module
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/TierMobility/boring-registry/pkg/auth"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/pkg/errors"
+)
+
+// Maturity tells consumers how stable a module version is.
+type Maturity string
+
+// Maturities of module versions.
+const (
+	MaturityExperimental Maturity = "experimental"
+	MaturityBeta         Maturity = "beta"
+	MaturityStable       Maturity = "stable"
+	MaturityDeprecated   Maturity = "deprecated"
+)
+
+// maxDeprecationReasonLength limits the reason of a deprecation, which is part of the object key of the maturity.
+const maxDeprecationReasonLength = 256
+
+func (m Maturity) validate(reason string) error {
+	switch m {
+	case MaturityExperimental, MaturityBeta, MaturityStable:
+		if reason != "" {
+			return errors.Wrapf(ErrInvalidMaturity, "only deprecated versions have a reason")
+		}
+		return nil
+	case MaturityDeprecated:
+		if len(reason) > maxDeprecationReasonLength {
+			return errors.Wrapf(ErrInvalidMaturity, "reason must not be longer than %d characters", maxDeprecationReasonLength)
+		}
+		return nil
+	}
+
+	return errors.Wrapf(ErrInvalidMaturity, "maturity %q must be one of %s, %s, %s or %s", m, MaturityExperimental, MaturityBeta, MaturityStable, MaturityDeprecated)
+}
+
+// VersionMaturity is the maturity of a module version.
+type VersionMaturity struct {
+	Version  string   `json:"version"`
+	Maturity Maturity `json:"maturity"`
+	// Reason explains why a version is deprecated.
+	Reason    string    `json:"reason,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// maturityPrefix returns the prefix of the maturities of a module.
+func maturityPrefix(prefix, namespace, name, provider string) string {
+	return path.Join(
+		prefix,
+		"maturities",
+		fmt.Sprintf("namespace=%s", namespace),
+		fmt.Sprintf("name=%s", name),
+		fmt.Sprintf("provider=%s", provider),
+	) + "/"
+}
+
+// maturityPath returns the path of a maturity of a module version.
+// Every change of the maturity is a new path, so maturities can be listed without reading them and the latest change wins.
+// The escaped reason of a deprecation is part of the path as well.
+func maturityPath(prefix, namespace, name, provider string, m VersionMaturity) string {
+	var reason string
+	if m.Reason != "" {
+		reason = fmt.Sprintf("reason=%s/", url.PathEscape(m.Reason))
+	}
+
+	return fmt.Sprintf("%sversion=%s/maturity=%s/%supdated_at=%d", maturityPrefix(prefix, namespace, name, provider), m.Version, m.Maturity, reason, m.UpdatedAt.UnixNano())
+}
+
+// parseMaturityPath returns the maturity of a maturity path.
+func parseMaturityPath(key string) (VersionMaturity, error) {
+	metadata := objectMetadata(key)
+
+	nsec, err := strconv.ParseInt(metadata["updated_at"], 10, 64)
+	if err != nil || metadata["version"] == "" || metadata["maturity"] == "" {
+		return VersionMaturity{}, errors.Errorf("invalid maturity %s", key)
+	}
+
+	reason, err := url.PathUnescape(metadata["reason"])
+	if err != nil {
+		return VersionMaturity{}, errors.Errorf("invalid maturity %s", key)
+	}
+
+	return VersionMaturity{
+		Version:   metadata["version"],
+		Maturity:  Maturity(metadata["maturity"]),
+		Reason:    reason,
+		UpdatedAt: time.Unix(0, nsec).UTC(),
+	}, nil
+}
+
+// latestMaturities returns the most recent maturity of every version, sorted by version.
+func latestMaturities(maturities []VersionMaturity) []VersionMaturity {
+	latest := make(map[string]VersionMaturity)
+	for _, m := range maturities {
+		if current, ok := latest[m.Version]; !ok || m.UpdatedAt.After(current.UpdatedAt) {
+			latest[m.Version] = m
+		}
+	}
+
+	res := make([]VersionMaturity, 0, len(latest))
+	for _, m := range latest {
+		res = append(res, m)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return versionLess(res[i].Version, res[j].Version)
+	})
+
+	return res
+}
+
+// maturityOf returns the maturity of a version, which is empty if none was set.
+func maturityOf(maturities []VersionMaturity, version string) Maturity {
+	return versionMaturityOf(maturities, version).Maturity
+}
+
+// versionMaturityOf returns the maturity of a version including the reason of a deprecation, which is empty if none was set.
+func versionMaturityOf(maturities []VersionMaturity, version string) VersionMaturity {
+	for _, m := range maturities {
+		if m.Version == version {
+			return m
+		}
+	}
+
+	return VersionMaturity{}
+}
+
+// SetMaturity only allows clients with one of the API keys of annotators to change the maturity of module versions.
+func (mw *annotatorMiddleware) SetMaturity(ctx context.Context, namespace, name, provider, version string, maturity Maturity, reason string) (VersionMaturity, error) {
+	for _, key := range mw.keys {
+		if fmt.Sprintf("Bearer %s", key) == ctx.Value(httptransport.ContextKeyRequestAuthorization) {
+			return mw.Service.SetMaturity(ctx, namespace, name, provider, version, maturity, reason)
+		}
+	}
+
+	return VersionMaturity{}, auth.ErrForbidden
+}
@@ -0,0 +1,145 @@
+package module
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TierMobility/boring-registry/pkg/auth"
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLatestMaturities(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	maturities := latestMaturities([]VersionMaturity{
+		{Version: "1.10.0", Maturity: MaturityBeta, UpdatedAt: at},
+		{Version: "1.2.0", Maturity: MaturityStable, UpdatedAt: at.Add(time.Hour)},
+		{Version: "1.2.0", Maturity: MaturityExperimental, UpdatedAt: at},
+		{Version: "1.2.0", Maturity: MaturityDeprecated, UpdatedAt: at.Add(2 * time.Hour)},
+	})
+
+	assert.Equal([]VersionMaturity{
+		{Version: "1.2.0", Maturity: MaturityDeprecated, UpdatedAt: at.Add(2 * time.Hour)},
+		{Version: "1.10.0", Maturity: MaturityBeta, UpdatedAt: at},
+	}, maturities)
+	assert.Equal(MaturityBeta, maturityOf(maturities, "1.10.0"))
+	assert.Equal(Maturity(""), maturityOf(maturities, "2.0.0"))
+}
+
+func TestParseMaturityPath(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	m := VersionMaturity{Version: "1.0.0", Maturity: MaturityStable, UpdatedAt: time.Date(2024, 1, 1, 0, 0, 0, 1, time.UTC)}
+	res, err := parseMaturityPath(maturityPath("prefix", "tier", "s3", "aws", m))
+	assert.NoError(err)
+	assert.Equal(m, res)
+
+	m = VersionMaturity{Version: "1.0.0", Maturity: MaturityDeprecated, Reason: "use tier/vpc/aws 2.x, see https://example.com?a=b", UpdatedAt: time.Date(2024, 1, 1, 0, 0, 0, 1, time.UTC)}
+	res, err = parseMaturityPath(maturityPath("prefix", "tier", "s3", "aws", m))
+	assert.NoError(err)
+	assert.Equal(m, res)
+
+	_, err = parseMaturityPath("prefix/maturities/namespace=tier/name=s3/provider=aws/version=1.0.0")
+	assert.Error(err)
+}
+
+func TestMaturity(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	storage := NewInmemStorage()
+	for _, version := range []string{"1.0.0", "1.1.0"} {
+		_, err := storage.UploadModule(ctx, "tier", "vpc", "aws", version, strings.NewReader("data"))
+		assert.NoError(err)
+	}
+
+	handler := MakeHandler(
+		AnnotatorMiddleware([]string{"platform"})(NewService(storage)),
+		endpoint.Chain(auth.Middleware("platform", "reader")),
+		httptransport.ServerBefore(httptransport.PopulateRequestContext),
+		httptransport.ServerErrorEncoder(ErrorEncoder),
+	)
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	testCases := []struct {
+		name         string
+		path         string
+		token        string
+		body         string
+		expectedCode int
+	}{
+		{name: "reader can't set maturity", path: "/tier/vpc/aws/1.0.0/maturity", token: "reader", body: `{"maturity": "deprecated"}`, expectedCode: http.StatusForbidden},
+		{name: "unknown maturity", path: "/tier/vpc/aws/1.0.0/maturity", token: "platform", body: `{"maturity": "alpha"}`, expectedCode: http.StatusBadRequest},
+		{name: "invalid body", path: "/tier/vpc/aws/1.0.0/maturity", token: "platform", body: `{`, expectedCode: http.StatusBadRequest},
+		{name: "unknown version", path: "/tier/vpc/aws/2.0.0/maturity", token: "platform", body: `{"maturity": "stable"}`, expectedCode: http.StatusNotFound},
+		{name: "stable", path: "/tier/vpc/aws/1.0.0/maturity", token: "platform", body: `{"maturity": "stable"}`, expectedCode: http.StatusOK},
+		{name: "reason of stable version", path: "/tier/vpc/aws/1.0.0/maturity", token: "platform", body: `{"maturity": "stable", "reason": "fine"}`, expectedCode: http.StatusBadRequest},
+		{name: "too long reason", path: "/tier/vpc/aws/1.0.0/maturity", token: "platform", body: `{"maturity": "deprecated", "reason": "` + strings.Repeat("x", maxDeprecationReasonLength+1) + `"}`, expectedCode: http.StatusBadRequest},
+		{name: "deprecated", path: "/tier/vpc/aws/1.0.0/maturity", token: "platform", body: `{"maturity": "deprecated", "reason": "use 1.1.0"}`, expectedCode: http.StatusOK},
+		{name: "beta", path: "/tier/vpc/aws/1.1.0/maturity", token: "platform", body: `{"maturity": "beta"}`, expectedCode: http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		rec := do(http.MethodPut, tc.path, tc.token, tc.body)
+		assert.Equal(tc.expectedCode, rec.Code, tc.name)
+	}
+
+	rec := do(http.MethodGet, "/tier/vpc/aws/versions", "reader", "")
+	assert.Equal(http.StatusOK, rec.Code)
+
+	var versions listResponse
+	assert.NoError(json.NewDecoder(rec.Body).Decode(&versions))
+	maturities := make(map[string]listResponseVersion)
+	for _, v := range versions.Modules[0].Versions {
+		v.PublishedAt = nil
+		maturities[v.Version] = v
+	}
+	assert.Equal(map[string]listResponseVersion{
+		"1.0.0": {Version: "1.0.0", Maturity: MaturityDeprecated, Deprecated: true, DeprecationReason: "use 1.1.0"},
+		"1.1.0": {Version: "1.1.0", Maturity: MaturityBeta},
+	}, maturities)
+
+	rec = do(http.MethodGet, "/tier/vpc/aws/releases", "reader", "")
+	assert.Equal(http.StatusOK, rec.Code)
+
+	var releases releasesResponse
+	assert.NoError(json.NewDecoder(rec.Body).Decode(&releases))
+	assert.Equal([]releasesResponseRelease{
+		{Version: "1.0.0", IsDeprecated: true},
+		{Version: "1.1.0"},
+	}, stripReleaseTimestamps(releases.Releases))
+
+	rec = do(http.MethodGet, "/tier", "reader", "")
+	assert.Equal(http.StatusOK, rec.Code)
+
+	var modules modulesResponse
+	assert.NoError(json.NewDecoder(rec.Body).Decode(&modules))
+	if assert.Len(modules.Modules, 1) {
+		assert.Equal(MaturityBeta, modules.Modules[0].Maturity)
+	}
+}
+
+func stripReleaseTimestamps(releases []releasesResponseRelease) []releasesResponseRelease {
+	for i := range releases {
+		releases[i].ReleaseTimestamp = nil
+	}
+	return releases
+}
@@ -2,6 +2,7 @@ package module
 
 import (
 	"context"
+	"io"
 	"time"
 
 	"github.com/go-kit/kit/log"
@@ -65,3 +66,282 @@ func (mw loggingMiddleware) GetModule(ctx context.Context, namespace, name, prov
 
 	return mw.next.GetModule(ctx, namespace, name, provider, version)
 }
+
+func (mw loggingMiddleware) ListModules(ctx context.Context, namespace string) (modules []Module, err error) {
+	defer func(begin time.Time) {
+		logger := level.Info(mw.logger)
+		if err != nil {
+			logger = level.Error(mw.logger)
+		}
+
+		_ = logger.Log(
+			"op", "ListModules",
+			"namespace", namespace,
+			"took", time.Since(begin),
+			"err", err,
+		)
+
+	}(time.Now())
+
+	return mw.next.ListModules(ctx, namespace)
+}
+
+func (mw loggingMiddleware) ListNamespaces(ctx context.Context) (namespaces []string, err error) {
+	defer func(begin time.Time) {
+		logger := level.Info(mw.logger)
+		if err != nil {
+			logger = level.Error(mw.logger)
+		}
+
+		_ = logger.Log(
+			"op", "ListNamespaces",
+			"took", time.Since(begin),
+			"err", err,
+		)
+
+	}(time.Now())
+
+	return mw.next.ListNamespaces(ctx)
+}
+
+func (mw loggingMiddleware) AddAnnotation(ctx context.Context, namespace, name, provider, version string, annotation Annotation) (res Annotation, err error) {
+	defer func(begin time.Time) {
+		logger := level.Info(mw.logger)
+		if err != nil {
+			logger = level.Error(mw.logger)
+		}
+
+		_ = logger.Log(
+			"op", "AddAnnotation",
+			"namespace", namespace,
+			"name", name,
+			"provider", provider,
+			"version", version,
+			"took", time.Since(begin),
+			"err", err,
+		)
+
+	}(time.Now())
+
+	return mw.next.AddAnnotation(ctx, namespace, name, provider, version, annotation)
+}
+
+func (mw loggingMiddleware) UploadModule(ctx context.Context, namespace, name, provider, version string, body io.Reader) (module Module, err error) {
+	defer func(begin time.Time) {
+		logger := level.Info(mw.logger)
+		if err != nil {
+			logger = level.Error(mw.logger)
+		}
+
+		_ = logger.Log(
+			"op", "UploadModule",
+			"namespace", namespace,
+			"name", name,
+			"provider", provider,
+			"version", version,
+			"digest", module.Digest,
+			"took", time.Since(begin),
+			"err", err,
+		)
+
+	}(time.Now())
+
+	return mw.next.UploadModule(ctx, namespace, name, provider, version, body)
+}
+
+func (mw loggingMiddleware) ListAnnotations(ctx context.Context, namespace, name, provider, version string) (annotations []Annotation, err error) {
+	defer func(begin time.Time) {
+		logger := level.Info(mw.logger)
+		if err != nil {
+			logger = level.Error(mw.logger)
+		}
+
+		_ = logger.Log(
+			"op", "ListAnnotations",
+			"namespace", namespace,
+			"name", name,
+			"provider", provider,
+			"version", version,
+			"took", time.Since(begin),
+			"err", err,
+		)
+
+	}(time.Now())
+
+	return mw.next.ListAnnotations(ctx, namespace, name, provider, version)
+}
+
+func (mw loggingMiddleware) ApproveModule(ctx context.Context, namespace, name, provider, version, approver string) (approval Approval, err error) {
+	defer func(begin time.Time) {
+		logger := level.Info(mw.logger)
+		if err != nil {
+			logger = level.Error(mw.logger)
+		}
+
+		_ = logger.Log(
+			"op", "ApproveModule",
+			"namespace", namespace,
+			"name", name,
+			"provider", provider,
+			"version", version,
+			"approver", approver,
+			"took", time.Since(begin),
+			"err", err,
+		)
+
+	}(time.Now())
+
+	return mw.next.ApproveModule(ctx, namespace, name, provider, version, approver)
+}
+
+func (mw loggingMiddleware) ListApprovals(ctx context.Context, namespace, name, provider string) (approvals []Approval, err error) {
+	defer func(begin time.Time) {
+		logger := level.Info(mw.logger)
+		if err != nil {
+			logger = level.Error(mw.logger)
+		}
+
+		_ = logger.Log(
+			"op", "ListApprovals",
+			"namespace", namespace,
+			"name", name,
+			"provider", provider,
+			"took", time.Since(begin),
+			"err", err,
+		)
+
+	}(time.Now())
+
+	return mw.next.ListApprovals(ctx, namespace, name, provider)
+}
+
+func (mw loggingMiddleware) ListSchedules(ctx context.Context, namespace, name, provider string) (schedules []Schedule, err error) {
+	defer func(begin time.Time) {
+		logger := level.Info(mw.logger)
+		if err != nil {
+			logger = level.Error(mw.logger)
+		}
+
+		_ = logger.Log(
+			"op", "ListSchedules",
+			"namespace", namespace,
+			"name", name,
+			"provider", provider,
+			"took", time.Since(begin),
+			"err", err,
+		)
+
+	}(time.Now())
+
+	return mw.next.ListSchedules(ctx, namespace, name, provider)
+}
+
+func (mw loggingMiddleware) SetLabels(ctx context.Context, namespace, name, provider string, labels Labels) (res Labels, err error) {
+	defer func(begin time.Time) {
+		logger := level.Info(mw.logger)
+		if err != nil {
+			logger = level.Error(mw.logger)
+		}
+
+		_ = logger.Log(
+			"op", "SetLabels",
+			"namespace", namespace,
+			"name", name,
+			"provider", provider,
+			"labels", len(labels),
+			"took", time.Since(begin),
+			"err", err,
+		)
+
+	}(time.Now())
+
+	return mw.next.SetLabels(ctx, namespace, name, provider, labels)
+}
+
+func (mw loggingMiddleware) GetLabels(ctx context.Context, namespace, name, provider string) (labels Labels, err error) {
+	defer func(begin time.Time) {
+		logger := level.Info(mw.logger)
+		if err != nil {
+			logger = level.Error(mw.logger)
+		}
+
+		_ = logger.Log(
+			"op", "GetLabels",
+			"namespace", namespace,
+			"name", name,
+			"provider", provider,
+			"took", time.Since(begin),
+			"err", err,
+		)
+
+	}(time.Now())
+
+	return mw.next.GetLabels(ctx, namespace, name, provider)
+}
+
+func (mw loggingMiddleware) SetMaturity(ctx context.Context, namespace, name, provider, version string, maturity Maturity, reason string) (res VersionMaturity, err error) {
+	defer func(begin time.Time) {
+		logger := level.Info(mw.logger)
+		if err != nil {
+			logger = level.Error(mw.logger)
+		}
+
+		_ = logger.Log(
+			"op", "SetMaturity",
+			"namespace", namespace,
+			"name", name,
+			"provider", provider,
+			"version", version,
+			"maturity", maturity,
+			"reason", reason,
+			"took", time.Since(begin),
+			"err", err,
+		)
+
+	}(time.Now())
+
+	return mw.next.SetMaturity(ctx, namespace, name, provider, version, maturity, reason)
+}
+
+func (mw loggingMiddleware) ListMaturities(ctx context.Context, namespace, name, provider string) (maturities []VersionMaturity, err error) {
+	defer func(begin time.Time) {
+		logger := level.Info(mw.logger)
+		if err != nil {
+			logger = level.Error(mw.logger)
+		}
+
+		_ = logger.Log(
+			"op", "ListMaturities",
+			"namespace", namespace,
+			"name", name,
+			"provider", provider,
+			"took", time.Since(begin),
+			"err", err,
+		)
+
+	}(time.Now())
+
+	return mw.next.ListMaturities(ctx, namespace, name, provider)
+}
+
+func (mw loggingMiddleware) GetDocs(ctx context.Context, namespace, name, provider, version string) (docs Docs, err error) {
+	defer func(begin time.Time) {
+		logger := level.Info(mw.logger)
+		if err != nil {
+			logger = level.Error(mw.logger)
+		}
+
+		_ = logger.Log(
+			"op", "GetDocs",
+			"namespace", namespace,
+			"name", name,
+			"provider", provider,
+			"version", version,
+			"took", time.Since(begin),
+			"err", err,
+		)
+
+	}(time.Now())
+
+	return mw.next.GetDocs(ctx, namespace, name, provider, version)
+}
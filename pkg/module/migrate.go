@@ -0,0 +1,145 @@
+package module
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// Statuses of address migrations.
+const (
+	MigrationStatusPlanned  = "planned"
+	MigrationStatusMigrated = "migrated"
+	// MigrationStatusConflict marks module versions whose normalized address is taken already,
+	// both versions are kept until one of them is deleted.
+	MigrationStatusConflict = "conflict"
+)
+
+// AddressMigration is the move of a module version from a mixed-case address to its normalized address.
+type AddressMigration struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Status string `json:"status"`
+}
+
+// MigrateAddresses moves the module versions of a namespace with mixed-case addresses to their normalized addresses,
+// together with their annotations, approvals and schedules. If dryRun is set, the migrations are only planned.
+// The storage must not normalize addresses itself.
+func MigrateAddresses(ctx context.Context, storage Storage, namespace string, dryRun bool) ([]AddressMigration, error) {
+	modules, err := storage.ListModules(ctx, namespace)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	var migrations []AddressMigration
+	for _, m := range modules {
+		ns, n, p := NormalizeAddress(m.Namespace, m.Name, m.Provider)
+		if ns == m.Namespace && n == m.Name && p == m.Provider {
+			continue
+		}
+
+		migration := AddressMigration{
+			From:   fmt.Sprintf("%s/%s/%s/%s", m.Namespace, m.Name, m.Provider, m.Version),
+			To:     fmt.Sprintf("%s/%s/%s/%s", ns, n, p, m.Version),
+			Status: MigrationStatusPlanned,
+		}
+
+		_, err := storage.GetModule(ctx, ns, n, p, m.Version)
+		switch {
+		case err == nil:
+			migration.Status = MigrationStatusConflict
+		case !errors.Is(err, ErrNotFound):
+			return migrations, errors.Wrap(err, migration.To)
+		case !dryRun:
+			if err := migrateModule(ctx, storage, m, ns, n, p); err != nil {
+				return migrations, errors.Wrap(err, migration.From)
+			}
+			migration.Status = MigrationStatusMigrated
+		}
+
+		migrations = append(migrations, migration)
+	}
+
+	return migrations, nil
+}
+
+// migrateModule copies a module version to the given address and deletes it afterwards.
+func migrateModule(ctx context.Context, storage Storage, m Module, namespace, name, provider string) error {
+	// The schedule is copied first, so the version is never visible before its publication time
+	schedules, err := storage.ListSchedules(ctx, m.Namespace, m.Name, m.Provider)
+	if err != nil {
+		return err
+	}
+	for _, s := range schedules {
+		if s.Version != m.Version {
+			continue
+		}
+		if err := storage.ScheduleModule(ctx, namespace, name, provider, m.Version, s.PublishAt); err != nil {
+			return err
+		}
+	}
+
+	body, err := storage.OpenModule(ctx, m.Namespace, m.Name, m.Provider, m.Version)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	if _, err := storage.UploadModule(ctx, namespace, name, provider, m.Version, body); err != nil {
+		return err
+	}
+
+	annotations, err := storage.ListAnnotations(ctx, m.Namespace, m.Name, m.Provider, m.Version)
+	if err != nil {
+		return err
+	}
+	for _, a := range annotations {
+		if err := storage.AddAnnotation(ctx, namespace, name, provider, m.Version, a); err != nil {
+			return err
+		}
+	}
+
+	approvals, err := storage.ListApprovals(ctx, m.Namespace, m.Name, m.Provider)
+	if err != nil {
+		return err
+	}
+	for _, a := range approvals {
+		if a.Version != m.Version {
+			continue
+		}
+		if err := storage.ApproveModule(ctx, namespace, name, provider, m.Version, a); err != nil {
+			return err
+		}
+	}
+
+	maturities, err := storage.ListMaturities(ctx, m.Namespace, m.Name, m.Provider)
+	if err != nil {
+		return err
+	}
+	for _, mat := range maturities {
+		if mat.Version != m.Version {
+			continue
+		}
+		if err := storage.SetMaturity(ctx, namespace, name, provider, mat); err != nil {
+			return err
+		}
+	}
+
+	// Labels belong to all versions, so they are only copied if the new address has none yet
+	labels, err := storage.GetLabels(ctx, m.Namespace, m.Name, m.Provider)
+	if err != nil {
+		return err
+	}
+	existing, err := storage.GetLabels(ctx, namespace, name, provider)
+	if err != nil {
+		return err
+	}
+	if len(labels) > 0 && len(existing) == 0 {
+		if err := storage.SetLabels(ctx, namespace, name, provider, labels); err != nil {
+			return err
+		}
+	}
+
+	return storage.DeleteModule(ctx, m.Namespace, m.Name, m.Provider, m.Version)
+}
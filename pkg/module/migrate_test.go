@@ -0,0 +1,95 @@
+package module
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizingStorage(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	var (
+		ctx     = context.Background()
+		storage = NewNormalizingStorage(NewInmemStorage())
+	)
+
+	_, err := storage.UploadModule(ctx, "Tier", "Networking", "aws", "1.0.0", testModuleData(map[string]string{
+		"main.tf": `name = "foo"`,
+	}))
+	assert.NoError(err)
+
+	res, err := storage.GetModule(ctx, "tier", "networking", "aws", "1.0.0")
+	assert.NoError(err)
+	assert.Equal("networking", res.Name)
+
+	versions, err := storage.ListModuleVersions(ctx, "TIER", "NETWORKING", "AWS")
+	assert.NoError(err)
+	assert.Len(versions, 1)
+}
+
+func TestMigrateAddresses(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	var (
+		ctx      = context.Background()
+		storage  = NewInmemStorage()
+		approval = Approval{Version: "1.0.0", Approver: "jane", ApprovedAt: time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)}
+	)
+
+	for _, m := range [][]string{
+		{"tier", "Networking", "aws"},
+		{"tier", "Conflict", "aws"},
+		{"tier", "conflict", "aws"},
+		{"tier", "vpc", "aws"},
+	} {
+		_, err := storage.UploadModule(ctx, m[0], m[1], m[2], "1.0.0", testModuleData(map[string]string{
+			"main.tf": `name = "foo"`,
+		}))
+		assert.NoError(err)
+	}
+	assert.NoError(storage.AddAnnotation(ctx, "tier", "Networking", "aws", "1.0.0", Annotation{Text: "deprecated"}))
+	assert.NoError(storage.ApproveModule(ctx, "tier", "Networking", "aws", "1.0.0", approval))
+
+	// Dry runs don't touch the storage
+	migrations, err := MigrateAddresses(ctx, storage, "tier", true)
+	assert.NoError(err)
+	assert.Equal([]AddressMigration{
+		{From: "tier/Conflict/aws/1.0.0", To: "tier/conflict/aws/1.0.0", Status: MigrationStatusConflict},
+		{From: "tier/Networking/aws/1.0.0", To: "tier/networking/aws/1.0.0", Status: MigrationStatusPlanned},
+	}, sortMigrations(migrations))
+
+	migrations, err = MigrateAddresses(ctx, storage, "tier", false)
+	assert.NoError(err)
+	assert.Equal([]AddressMigration{
+		{From: "tier/Conflict/aws/1.0.0", To: "tier/conflict/aws/1.0.0", Status: MigrationStatusConflict},
+		{From: "tier/Networking/aws/1.0.0", To: "tier/networking/aws/1.0.0", Status: MigrationStatusMigrated},
+	}, sortMigrations(migrations))
+
+	_, err = storage.GetModule(ctx, "tier", "Networking", "aws", "1.0.0")
+	assert.True(errors.Is(err, ErrNotFound))
+	_, err = storage.GetModule(ctx, "tier", "networking", "aws", "1.0.0")
+	assert.NoError(err)
+
+	annotations, err := storage.ListAnnotations(ctx, "tier", "networking", "aws", "1.0.0")
+	assert.NoError(err)
+	assert.Equal([]Annotation{{Text: "deprecated"}}, annotations)
+
+	approvals, err := storage.ListApprovals(ctx, "tier", "networking", "aws")
+	assert.NoError(err)
+	assert.Equal([]Approval{approval}, approvals)
+}
+
+// sortMigrations sorts migrations by their source, as storages list modules in any order.
+func sortMigrations(migrations []AddressMigration) []AddressMigration {
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].From < migrations[j].From
+	})
+	return migrations
+}
@@ -0,0 +1,84 @@
+package module
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/TierMobility/boring-registry/pkg/auth"
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	httptransport "github.com/go-kit/kit/transport/http"
+)
+
+// DownloadNetworks restricts downloads of modules to clients within a set of networks, e.g. the egress addresses of a company.
+// It is keyed by namespace, namespaces without an entry are downloadable from everywhere.
+type DownloadNetworks map[string][]*net.IPNet
+
+// DownloadNetworksMiddleware enforces the download networks on the endpoints listing and downloading versions of modules.
+// Denied requests are logged for auditing.
+func DownloadNetworksMiddleware(networks DownloadNetworks, trustForwardedFor bool, logger log.Logger) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			var namespace, name, provider, version string
+
+			switch req := request.(type) {
+			case listRequest:
+				namespace, name, provider = req.namespace, req.name, req.provider
+			case downloadRequest:
+				namespace, name, provider, version = req.namespace, req.name, req.provider, req.version
+			default:
+				return next(ctx, request)
+			}
+
+			cidrs, ok := networks[namespace]
+			if !ok {
+				return next(ctx, request)
+			}
+
+			ip := clientIP(ctx, trustForwardedFor)
+			if ip == nil || !containsIP(cidrs, ip) {
+				_ = level.Warn(logger).Log(
+					"msg", "download denied outside of the allowed networks",
+					"module", namespace+"/"+name+"/"+provider,
+					"version", version,
+					"client", ip,
+				)
+				return nil, auth.ErrForbidden
+			}
+
+			return next(ctx, request)
+		}
+	}
+}
+
+// clientIP returns the address of the client of a request, or nil if it's unknown.
+// The first address of the X-Forwarded-For header is only used if it is trusted, e.g. behind a load balancer.
+func clientIP(ctx context.Context, trustForwardedFor bool) net.IP {
+	if trustForwardedFor {
+		if forwarded, ok := ctx.Value(httptransport.ContextKeyRequestXForwardedFor).(string); ok && forwarded != "" {
+			return net.ParseIP(strings.TrimSpace(strings.Split(forwarded, ",")[0]))
+		}
+	}
+
+	addr, ok := ctx.Value(httptransport.ContextKeyRequestRemoteAddr).(string)
+	if !ok || addr == "" {
+		return nil
+	}
+
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+
+	return net.ParseIP(addr)
+}
+
+func containsIP(cidrs []*net.IPNet, ip net.IP) bool {
+	for _, cidr := range cidrs {
+		if cidr.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package module
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+
+	"github.com/TierMobility/boring-registry/pkg/auth"
+	"github.com/go-kit/kit/log"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDownloadNetworksMiddleware(t *testing.T) {
+	t.Parallel()
+
+	_, office, _ := net.ParseCIDR("10.0.0.0/8")
+	networks := DownloadNetworks{
+		"tier": {office},
+	}
+
+	testCases := []struct {
+		name              string
+		remoteAddr        string
+		forwardedFor      string
+		trustForwardedFor bool
+		request           interface{}
+		expectError       bool
+	}{
+		{
+			name:       "unrestricted namespace",
+			remoteAddr: "203.0.113.7:51234",
+			request:    downloadRequest{namespace: "other", name: "vpc", provider: "aws", version: "1.0.0"},
+		},
+		{
+			name:       "allowed network",
+			remoteAddr: "10.1.2.3:51234",
+			request:    downloadRequest{namespace: "tier", name: "vpc", provider: "aws", version: "1.0.0"},
+		},
+		{
+			name:        "denied download",
+			remoteAddr:  "203.0.113.7:51234",
+			request:     downloadRequest{namespace: "tier", name: "vpc", provider: "aws", version: "1.0.0"},
+			expectError: true,
+		},
+		{
+			name:        "denied versions",
+			remoteAddr:  "203.0.113.7:51234",
+			request:     listRequest{namespace: "tier", name: "vpc", provider: "aws"},
+			expectError: true,
+		},
+		{
+			name:       "annotations are not restricted",
+			remoteAddr: "203.0.113.7:51234",
+			request:    annotationsRequest{namespace: "tier", name: "vpc", provider: "aws", version: "1.0.0"},
+		},
+		{
+			name:         "untrusted forwarded for",
+			remoteAddr:   "203.0.113.7:51234",
+			forwardedFor: "10.1.2.3",
+			request:      downloadRequest{namespace: "tier", name: "vpc", provider: "aws", version: "1.0.0"},
+			expectError:  true,
+		},
+		{
+			name:              "trusted forwarded for",
+			remoteAddr:        "192.0.2.1:51234",
+			forwardedFor:      "10.1.2.3, 192.0.2.1",
+			trustForwardedFor: true,
+			request:           downloadRequest{namespace: "tier", name: "vpc", provider: "aws", version: "1.0.0"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.WithValue(context.Background(), httptransport.ContextKeyRequestRemoteAddr, tc.remoteAddr)
+			ctx = context.WithValue(ctx, httptransport.ContextKeyRequestXForwardedFor, tc.forwardedFor)
+
+			var buf bytes.Buffer
+			_, err := DownloadNetworksMiddleware(networks, tc.trustForwardedFor, log.NewLogfmtLogger(&buf))(func(context.Context, interface{}) (interface{}, error) {
+				return nil, nil
+			})(ctx, tc.request)
+
+			if tc.expectError {
+				assert.Equal(t, auth.ErrForbidden, err)
+				assert.Contains(t, buf.String(), "module=tier/vpc/aws")
+			} else {
+				assert.NoError(t, err)
+				assert.Empty(t, buf.String())
+			}
+		})
+	}
+}
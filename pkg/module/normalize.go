@@ -0,0 +1,116 @@
+package module
+
+import (
+	"context"
+	"io"
+	"strings"
+	"time"
+)
+
+// NormalizeAddress returns the canonical form of a module address, in which namespace, name and provider are lowercase.
+// The address grammar only allows ASCII letters, so lowercasing is the only normalization needed,
+// there are no Unicode forms or punycode labels to fold.
+func NormalizeAddress(namespace, name, provider string) (string, string, string) {
+	return strings.ToLower(namespace), strings.ToLower(name), strings.ToLower(provider)
+}
+
+// normalizingStorage normalizes the module addresses of all operations,
+// so modules that only differ in case are stored and served as the same module.
+type normalizingStorage struct {
+	Storage
+}
+
+// NewNormalizingStorage returns a storage that normalizes module addresses with NormalizeAddress.
+// Modules stored with mixed-case addresses before have to be migrated with MigrateAddresses to stay reachable.
+func NewNormalizingStorage(next Storage) Storage {
+	return &normalizingStorage{Storage: next}
+}
+
+func (s *normalizingStorage) GetModule(ctx context.Context, namespace, name, provider, version string) (Module, error) {
+	namespace, name, provider = NormalizeAddress(namespace, name, provider)
+	return s.Storage.GetModule(ctx, namespace, name, provider, version)
+}
+
+func (s *normalizingStorage) OpenModule(ctx context.Context, namespace, name, provider, version string) (io.ReadCloser, error) {
+	namespace, name, provider = NormalizeAddress(namespace, name, provider)
+	return s.Storage.OpenModule(ctx, namespace, name, provider, version)
+}
+
+func (s *normalizingStorage) ListModuleVersions(ctx context.Context, namespace, name, provider string) ([]Module, error) {
+	namespace, name, provider = NormalizeAddress(namespace, name, provider)
+	return s.Storage.ListModuleVersions(ctx, namespace, name, provider)
+}
+
+func (s *normalizingStorage) ListModules(ctx context.Context, namespace string) ([]Module, error) {
+	return s.Storage.ListModules(ctx, strings.ToLower(namespace))
+}
+
+func (s *normalizingStorage) UploadModule(ctx context.Context, namespace, name, provider, version string, body io.Reader) (Module, error) {
+	namespace, name, provider = NormalizeAddress(namespace, name, provider)
+	return s.Storage.UploadModule(ctx, namespace, name, provider, version, body)
+}
+
+func (s *normalizingStorage) DeleteModule(ctx context.Context, namespace, name, provider, version string) error {
+	namespace, name, provider = NormalizeAddress(namespace, name, provider)
+	return s.Storage.DeleteModule(ctx, namespace, name, provider, version)
+}
+
+func (s *normalizingStorage) AddAnnotation(ctx context.Context, namespace, name, provider, version string, annotation Annotation) error {
+	namespace, name, provider = NormalizeAddress(namespace, name, provider)
+	return s.Storage.AddAnnotation(ctx, namespace, name, provider, version, annotation)
+}
+
+func (s *normalizingStorage) ListAnnotations(ctx context.Context, namespace, name, provider, version string) ([]Annotation, error) {
+	namespace, name, provider = NormalizeAddress(namespace, name, provider)
+	return s.Storage.ListAnnotations(ctx, namespace, name, provider, version)
+}
+
+func (s *normalizingStorage) ApproveModule(ctx context.Context, namespace, name, provider, version string, approval Approval) error {
+	namespace, name, provider = NormalizeAddress(namespace, name, provider)
+	return s.Storage.ApproveModule(ctx, namespace, name, provider, version, approval)
+}
+
+func (s *normalizingStorage) ListApprovals(ctx context.Context, namespace, name, provider string) ([]Approval, error) {
+	namespace, name, provider = NormalizeAddress(namespace, name, provider)
+	return s.Storage.ListApprovals(ctx, namespace, name, provider)
+}
+
+func (s *normalizingStorage) ScheduleModule(ctx context.Context, namespace, name, provider, version string, publishAt time.Time) error {
+	namespace, name, provider = NormalizeAddress(namespace, name, provider)
+	return s.Storage.ScheduleModule(ctx, namespace, name, provider, version, publishAt)
+}
+
+func (s *normalizingStorage) ListSchedules(ctx context.Context, namespace, name, provider string) ([]Schedule, error) {
+	namespace, name, provider = NormalizeAddress(namespace, name, provider)
+	return s.Storage.ListSchedules(ctx, namespace, name, provider)
+}
+
+func (s *normalizingStorage) SetLabels(ctx context.Context, namespace, name, provider string, labels Labels) error {
+	namespace, name, provider = NormalizeAddress(namespace, name, provider)
+	return s.Storage.SetLabels(ctx, namespace, name, provider, labels)
+}
+
+func (s *normalizingStorage) GetLabels(ctx context.Context, namespace, name, provider string) (Labels, error) {
+	namespace, name, provider = NormalizeAddress(namespace, name, provider)
+	return s.Storage.GetLabels(ctx, namespace, name, provider)
+}
+
+func (s *normalizingStorage) SetMaturity(ctx context.Context, namespace, name, provider string, maturity VersionMaturity) error {
+	namespace, name, provider = NormalizeAddress(namespace, name, provider)
+	return s.Storage.SetMaturity(ctx, namespace, name, provider, maturity)
+}
+
+func (s *normalizingStorage) ListMaturities(ctx context.Context, namespace, name, provider string) ([]VersionMaturity, error) {
+	namespace, name, provider = NormalizeAddress(namespace, name, provider)
+	return s.Storage.ListMaturities(ctx, namespace, name, provider)
+}
+
+func (s *normalizingStorage) SetDocs(ctx context.Context, namespace, name, provider, version string, docs Docs) error {
+	namespace, name, provider = NormalizeAddress(namespace, name, provider)
+	return s.Storage.SetDocs(ctx, namespace, name, provider, version, docs)
+}
+
+func (s *normalizingStorage) GetDocs(ctx context.Context, namespace, name, provider, version string) (Docs, error) {
+	namespace, name, provider = NormalizeAddress(namespace, name, provider)
+	return s.Storage.GetDocs(ctx, namespace, name, provider, version)
+}
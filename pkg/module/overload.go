@@ -0,0 +1,201 @@
+package module
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// overloadPollInterval is the interval queued uploads check whether the registry is still saturated in.
+const overloadPollInterval = 50 * time.Millisecond
+
+// OverloadEvent is reported for uploads which had to wait for or were rejected because of the load of the registry.
+type OverloadEvent string
+
+// Overload events.
+const (
+	// OverloadQueued is reported for uploads which were admitted after waiting for the load to drop.
+	OverloadQueued OverloadEvent = "queued"
+	// OverloadShed is reported for uploads which were rejected with ErrOverloaded.
+	OverloadShed OverloadEvent = "shed"
+)
+
+// OverloadOption configures an Overload.
+type OverloadOption func(*Overload)
+
+// WithOverloadMaxInFlight considers the registry saturated if this many downloads and uploads are processed, 0 disables the limit.
+func WithOverloadMaxInFlight(n int) OverloadOption {
+	return func(o *Overload) {
+		o.maxInFlight = n
+	}
+}
+
+// WithOverloadMaxLatency considers the registry saturated while downloads are processed and the moving average of their
+// latency exceeds this duration, 0 disables the limit.
+func WithOverloadMaxLatency(d time.Duration) OverloadOption {
+	return func(o *Overload) {
+		o.maxLatency = d
+	}
+}
+
+// WithOverloadQueueTimeout configures how long uploads wait for the load to drop before they are rejected, 0 rejects them immediately.
+func WithOverloadQueueTimeout(d time.Duration) OverloadOption {
+	return func(o *Overload) {
+		o.queueTimeout = d
+	}
+}
+
+// WithOverloadReport reports uploads which were queued or shed, e.g. to count them.
+func WithOverloadReport(report func(OverloadEvent)) OverloadOption {
+	return func(o *Overload) {
+		o.report = report
+	}
+}
+
+// Overload prioritizes downloads over uploads: while the registry is saturated, uploads are queued until the
+// load drops and rejected with ErrOverloaded after the queue timeout, so `terraform init` keeps working during upload storms.
+// Downloads are the lookups of module versions, they are never queued or rejected.
+// It tracks the load of the registry and is shared by the services of all hosts.
+type Overload struct {
+	maxInFlight  int
+	maxLatency   time.Duration
+	queueTimeout time.Duration
+	report       func(OverloadEvent)
+
+	mu            sync.Mutex
+	inFlight      int
+	readsInFlight int
+	// readLatency is the exponentially weighted moving average of the latency of downloads.
+	readLatency time.Duration
+}
+
+// NewOverload returns an Overload, which tracks downloads with its Middleware and admits uploads with its Handler.
+func NewOverload(options ...OverloadOption) *Overload {
+	o := &Overload{
+		report: func(OverloadEvent) {},
+	}
+
+	for _, option := range options {
+		option(o)
+	}
+
+	return o
+}
+
+// Middleware tracks the downloads of a service.
+func (o *Overload) Middleware() Middleware {
+	return func(next Service) Service {
+		return &overloadMiddleware{
+			Service:  next,
+			overload: o,
+		}
+	}
+}
+
+// Handler admits the uploads of the module API before their body is read, so queued uploads don't hold their
+// archive. Queued uploads count towards the read and write timeouts of the server, the queue timeout must be shorter.
+func (o *Overload) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || !strings.HasSuffix(r.URL.Path, "/upload") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		done, err := o.admit(r.Context())
+		if err != nil {
+			ErrorEncoder(r.Context(), err, w)
+			return
+		}
+		defer done()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+type overloadMiddleware struct {
+	Service
+	overload *Overload
+}
+
+func (mw *overloadMiddleware) GetModule(ctx context.Context, namespace, name, provider, version string) (Module, error) {
+	defer mw.overload.read()()
+	return mw.Service.GetModule(ctx, namespace, name, provider, version)
+}
+
+func (mw *overloadMiddleware) ListModuleVersions(ctx context.Context, namespace, name, provider string) ([]Module, error) {
+	defer mw.overload.read()()
+	return mw.Service.ListModuleVersions(ctx, namespace, name, provider)
+}
+
+// read tracks a download, the returned function records its latency once it's done.
+func (o *Overload) read() func() {
+	start := time.Now()
+
+	o.mu.Lock()
+	o.inFlight++
+	o.readsInFlight++
+	o.mu.Unlock()
+
+	return func() {
+		latency := time.Since(start)
+
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		o.inFlight--
+		o.readsInFlight--
+		// Recent downloads weigh 1/8, so a single slow download doesn't saturate the registry
+		o.readLatency += (latency - o.readLatency) / 8
+	}
+}
+
+// admit waits until the registry isn't saturated anymore to track an upload, the returned function marks it done.
+func (o *Overload) admit(ctx context.Context) (func(), error) {
+	if o.tryAdmit() {
+		return o.done, nil
+	}
+
+	timeout := time.NewTimer(o.queueTimeout)
+	defer timeout.Stop()
+	ticker := time.NewTicker(overloadPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timeout.C:
+			o.report(OverloadShed)
+			return nil, ErrOverloaded
+		case <-ticker.C:
+			if o.tryAdmit() {
+				o.report(OverloadQueued)
+				return o.done, nil
+			}
+		}
+	}
+}
+
+// tryAdmit tracks an upload if the registry isn't saturated.
+func (o *Overload) tryAdmit() bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.maxInFlight > 0 && o.inFlight >= o.maxInFlight {
+		return false
+	}
+	// The latency of downloads only matters while there are downloads to protect, it's stale otherwise
+	if o.maxLatency > 0 && o.readsInFlight > 0 && o.readLatency >= o.maxLatency {
+		return false
+	}
+
+	o.inFlight++
+	return true
+}
+
+func (o *Overload) done() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.inFlight--
+}
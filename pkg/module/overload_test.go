@@ -0,0 +1,197 @@
+package module
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// blockingStorage blocks lookups of module versions until release is closed.
+type blockingStorage struct {
+	Storage
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingStorage) GetModule(ctx context.Context, namespace, name, provider, version string) (Module, error) {
+	s.started <- struct{}{}
+	<-s.release
+	return s.Storage.GetModule(ctx, namespace, name, provider, version)
+}
+
+func TestOverload(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name           string
+		options        []OverloadOption
+		readLatency    time.Duration
+		releaseAfter   time.Duration
+		expectStatus   int
+		expectedEvents []OverloadEvent
+	}{
+		{
+			name:           "shed",
+			options:        []OverloadOption{WithOverloadMaxInFlight(1)},
+			expectStatus:   http.StatusServiceUnavailable,
+			expectedEvents: []OverloadEvent{OverloadShed},
+		},
+		{
+			name:           "queued",
+			options:        []OverloadOption{WithOverloadMaxInFlight(1), WithOverloadQueueTimeout(10 * time.Second)},
+			releaseAfter:   100 * time.Millisecond,
+			expectStatus:   http.StatusCreated,
+			expectedEvents: []OverloadEvent{OverloadQueued},
+		},
+		{
+			name:           "below limit",
+			options:        []OverloadOption{WithOverloadMaxInFlight(2)},
+			expectStatus:   http.StatusCreated,
+			expectedEvents: nil,
+		},
+		{
+			name:           "slow downloads",
+			options:        []OverloadOption{WithOverloadMaxLatency(time.Second)},
+			readLatency:    2 * time.Second,
+			expectStatus:   http.StatusServiceUnavailable,
+			expectedEvents: []OverloadEvent{OverloadShed},
+		},
+		{
+			name:           "fast downloads",
+			options:        []OverloadOption{WithOverloadMaxLatency(time.Second)},
+			readLatency:    100 * time.Millisecond,
+			expectStatus:   http.StatusCreated,
+			expectedEvents: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+
+			ctx := context.Background()
+			storage := &blockingStorage{
+				Storage: NewInmemStorage(),
+				started: make(chan struct{}, 1),
+				release: make(chan struct{}),
+			}
+
+			var (
+				mu     sync.Mutex
+				events []OverloadEvent
+			)
+			options := append(tc.options, WithOverloadReport(func(event OverloadEvent) {
+				mu.Lock()
+				defer mu.Unlock()
+				events = append(events, event)
+			}))
+			overload := NewOverload(options...)
+			overload.readLatency = tc.readLatency
+			svc := overload.Middleware()(NewService(storage))
+
+			// The body of shed uploads is never read
+			var read bool
+			handler := overload.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				read = true
+				w.WriteHeader(http.StatusCreated)
+			}))
+
+			// A download is in flight while the module is uploaded
+			downloaded := make(chan struct{})
+			go func() {
+				defer close(downloaded)
+				_, _ = svc.GetModule(ctx, "tier", "vpc", "aws", "1.0.0")
+			}()
+			<-storage.started
+
+			if tc.releaseAfter > 0 {
+				time.AfterFunc(tc.releaseAfter, func() { close(storage.release) })
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/tier/vpc/aws/1.0.0/upload", strings.NewReader("archive")))
+			assert.Equal(tc.expectStatus, rec.Code)
+			assert.Equal(tc.expectStatus == http.StatusCreated, read)
+
+			if tc.releaseAfter == 0 {
+				close(storage.release)
+			}
+			<-downloaded
+
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Equal(tc.expectedEvents, events)
+		})
+	}
+}
+
+func TestOverload_Idle(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	overload := NewOverload(WithOverloadMaxInFlight(1), WithOverloadMaxLatency(time.Second))
+	svc := overload.Middleware()(NewService(NewInmemStorage()))
+	handler := overload.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		version := strings.Split(r.URL.Path, "/")[4]
+		if _, err := svc.UploadModule(r.Context(), "tier", "vpc", "aws", version, r.Body); err != nil {
+			ErrorEncoder(r.Context(), err, w)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	// The latency of past downloads doesn't hold back uploads once there are no downloads anymore
+	overload.readLatency = time.Minute
+
+	for _, version := range []string{"1.0.0", "1.1.0"} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/tier/vpc/aws/"+version+"/upload", strings.NewReader("archive")))
+		assert.Equal(http.StatusCreated, rec.Code, "finished uploads don't count as in flight")
+	}
+
+	_, err := svc.GetModule(ctx, "tier", "vpc", "aws", "1.0.0")
+	assert.NoError(err)
+	assert.Less(overload.readLatency, time.Minute)
+}
+
+func TestOverload_Handler(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	overload := NewOverload(WithOverloadMaxInFlight(1))
+	handler := overload.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// The registry is saturated by a download
+	done := overload.read()
+	defer done()
+
+	testCases := []struct {
+		method       string
+		path         string
+		expectStatus int
+	}{
+		{http.MethodPost, "/tier/vpc/aws/1.0.0/upload", http.StatusServiceUnavailable},
+		{http.MethodGet, "/tier/vpc/aws/versions", http.StatusOK},
+		{http.MethodPost, "/tier/vpc/aws/1.0.0/annotations", http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.path, nil))
+		assert.Equal(tc.expectStatus, rec.Code, "%s %s", tc.method, tc.path)
+		if tc.expectStatus == http.StatusServiceUnavailable {
+			assert.NotEmpty(rec.Header().Get("Retry-After"))
+		}
+	}
+}
@@ -1,14 +1,11 @@
 package module
 
 import (
-	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 
-	"github.com/hashicorp/go-multierror"
-	"github.com/hashicorp/go-version"
 	"github.com/hashicorp/hcl"
 )
 
@@ -23,33 +20,26 @@ type Metadata struct {
 	Name      string `hcl:"name" json:"name"`
 	Provider  string `hcl:"provider" json:"provider"`
 	Version   string `hcl:"version" json:"version"`
+	// Labels replace the labels of the module when the version is uploaded.
+	Labels Labels `hcl:"labels" json:"labels,omitempty"`
+	// Maturity of the version, which can be changed later with the maturity endpoint of the server.
+	Maturity Maturity `hcl:"maturity" json:"maturity,omitempty"`
 }
 
 // Validate ensures that a spec is valid.
+// The returned error is a *ValidationError listing all fields which violate the module address grammar.
 func (s *Spec) Validate() error {
-	var result *multierror.Error
-
-	if s.Metadata.Namespace == "" {
-		result = multierror.Append(result, errors.New("metadata.namespace cannot be empty"))
-	}
-
-	if s.Metadata.Name == "" {
-		result = multierror.Append(result, errors.New("metadata.name cannot be empty"))
-	}
-
-	if s.Metadata.Provider == "" {
-		result = multierror.Append(result, errors.New("metadata.provider cannot be empty"))
-	}
-
-	if s.Metadata.Version == "" {
-		result = multierror.Append(result, errors.New("metadata.version cannot be empty"))
+	if err := ValidateAddress(s.Metadata.Namespace, s.Metadata.Name, s.Metadata.Provider, s.Metadata.Version); err != nil {
+		return err
 	}
 
-	if _, err := version.NewVersion(s.Metadata.Version); err != nil {
-		result = multierror.Append(result, err)
+	if s.Metadata.Maturity != "" {
+		if err := s.Metadata.Maturity.validate(""); err != nil {
+			return err
+		}
 	}
 
-	return result.ErrorOrNil()
+	return s.Metadata.Labels.validate()
 }
 
 func (s *Spec) Name() string {
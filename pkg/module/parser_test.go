@@ -37,6 +37,78 @@ func TestParser(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "labels",
+			input: strings.NewReader(`
+             metadata {
+               name      = "s3"
+               namespace = "tier"
+               version   = "1.0.0"
+               provider  = "aws"
+
+               labels {
+                 tier     = "networking"
+                 maturity = "stable"
+               }
+             }
+			`),
+			expected: &Spec{
+				Metadata{
+					Name:      "s3",
+					Namespace: "tier",
+					Version:   "1.0.0",
+					Provider:  "aws",
+					Labels:    Labels{"tier": "networking", "maturity": "stable"},
+				},
+			},
+		},
+		{
+			name: "maturity",
+			input: strings.NewReader(`
+             metadata {
+               name      = "s3"
+               namespace = "tier"
+               version   = "1.0.0"
+               provider  = "aws"
+               maturity  = "beta"
+             }
+			`),
+			expected: &Spec{
+				Metadata{
+					Name:      "s3",
+					Namespace: "tier",
+					Version:   "1.0.0",
+					Provider:  "aws",
+					Maturity:  MaturityBeta,
+				},
+			},
+		},
+		{
+			name: "invalid maturity",
+			input: strings.NewReader(`
+             metadata {
+               name      = "s3"
+               namespace = "tier"
+               version   = "1.0.0"
+               provider  = "aws"
+               maturity  = "alpha"
+             }
+			`),
+			expectedError: true,
+		},
+		{
+			name: "invalid label",
+			input: strings.NewReader(`
+             metadata {
+               name      = "s3"
+               namespace = "tier"
+               version   = "1.0.0"
+               provider  = "aws"
+               labels    = { "Tier" = "networking" }
+             }
+			`),
+			expectedError: true,
+		},
 		{
 			name:          "empty spec",
 			input:         strings.NewReader(``),
@@ -0,0 +1,170 @@
+package module
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/hashicorp/go-version"
+	"github.com/pkg/errors"
+)
+
+type previewMiddleware struct {
+	next Service
+	keys []string
+	ttl  time.Duration
+	now  func() time.Time
+}
+
+// PreviewMiddleware hides preview versions from clients without one of the given API keys.
+// Versions with a pre-release suffix like 1.2.0-rc.1 are preview versions,
+// they are hidden from all clients once the ttl since their upload has passed. A ttl of 0 disables the expiry.
+func PreviewMiddleware(keys []string, ttl time.Duration) Middleware {
+	return func(next Service) Service {
+		return &previewMiddleware{
+			next: next,
+			keys: keys,
+			ttl:  ttl,
+			now:  time.Now,
+		}
+	}
+}
+
+func (mw *previewMiddleware) ListModuleVersions(ctx context.Context, namespace, name, provider string) ([]Module, error) {
+	res, err := mw.next.ListModuleVersions(ctx, namespace, name, provider)
+	if err != nil {
+		return nil, err
+	}
+
+	var modules []Module
+	for _, module := range res {
+		if mw.visible(ctx, module) {
+			modules = append(modules, module)
+		}
+	}
+
+	return modules, nil
+}
+
+func (mw *previewMiddleware) ListModules(ctx context.Context, namespace string) ([]Module, error) {
+	res, err := mw.next.ListModules(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	var modules []Module
+	for _, module := range res {
+		if mw.visible(ctx, module) {
+			modules = append(modules, module)
+		}
+	}
+
+	return modules, nil
+}
+
+func (mw *previewMiddleware) ListNamespaces(ctx context.Context) ([]string, error) {
+	return mw.next.ListNamespaces(ctx)
+}
+
+func (mw *previewMiddleware) GetModule(ctx context.Context, namespace, name, provider, version string) (Module, error) {
+	res, err := mw.next.GetModule(ctx, namespace, name, provider, version)
+	if err != nil {
+		return Module{}, err
+	}
+
+	if !mw.visible(ctx, res) {
+		return Module{}, errors.Wrap(ErrNotFound, "preview version")
+	}
+
+	return res, nil
+}
+
+func (mw *previewMiddleware) UploadModule(ctx context.Context, namespace, name, provider, version string, body io.Reader) (Module, error) {
+	return mw.next.UploadModule(ctx, namespace, name, provider, version, body)
+}
+
+func (mw *previewMiddleware) AddAnnotation(ctx context.Context, namespace, name, provider, version string, annotation Annotation) (Annotation, error) {
+	if _, err := mw.GetModule(ctx, namespace, name, provider, version); err != nil {
+		return Annotation{}, err
+	}
+
+	return mw.next.AddAnnotation(ctx, namespace, name, provider, version, annotation)
+}
+
+func (mw *previewMiddleware) ListAnnotations(ctx context.Context, namespace, name, provider, version string) ([]Annotation, error) {
+	if _, err := mw.GetModule(ctx, namespace, name, provider, version); err != nil {
+		return nil, err
+	}
+
+	return mw.next.ListAnnotations(ctx, namespace, name, provider, version)
+}
+
+func (mw *previewMiddleware) ApproveModule(ctx context.Context, namespace, name, provider, version, approver string) (Approval, error) {
+	return mw.next.ApproveModule(ctx, namespace, name, provider, version, approver)
+}
+
+func (mw *previewMiddleware) ListApprovals(ctx context.Context, namespace, name, provider string) ([]Approval, error) {
+	return mw.next.ListApprovals(ctx, namespace, name, provider)
+}
+
+func (mw *previewMiddleware) ListSchedules(ctx context.Context, namespace, name, provider string) ([]Schedule, error) {
+	return mw.next.ListSchedules(ctx, namespace, name, provider)
+}
+
+func (mw *previewMiddleware) SetLabels(ctx context.Context, namespace, name, provider string, labels Labels) (Labels, error) {
+	return mw.next.SetLabels(ctx, namespace, name, provider, labels)
+}
+
+func (mw *previewMiddleware) GetLabels(ctx context.Context, namespace, name, provider string) (Labels, error) {
+	return mw.next.GetLabels(ctx, namespace, name, provider)
+}
+
+func (mw *previewMiddleware) SetMaturity(ctx context.Context, namespace, name, provider, version string, maturity Maturity, reason string) (VersionMaturity, error) {
+	if _, err := mw.GetModule(ctx, namespace, name, provider, version); err != nil {
+		return VersionMaturity{}, err
+	}
+
+	return mw.next.SetMaturity(ctx, namespace, name, provider, version, maturity, reason)
+}
+
+func (mw *previewMiddleware) GetDocs(ctx context.Context, namespace, name, provider, version string) (Docs, error) {
+	if _, err := mw.GetModule(ctx, namespace, name, provider, version); err != nil {
+		return Docs{}, err
+	}
+
+	return mw.next.GetDocs(ctx, namespace, name, provider, version)
+}
+
+func (mw *previewMiddleware) ListMaturities(ctx context.Context, namespace, name, provider string) ([]VersionMaturity, error) {
+	return mw.next.ListMaturities(ctx, namespace, name, provider)
+}
+
+func (mw *previewMiddleware) visible(ctx context.Context, module Module) bool {
+	if !IsPreview(module.Version) {
+		return true
+	}
+
+	if mw.ttl > 0 && !module.Created.IsZero() && mw.now().After(module.Created.Add(mw.ttl)) {
+		return false
+	}
+
+	for _, key := range mw.keys {
+		if fmt.Sprintf("Bearer %s", key) == ctx.Value(httptransport.ContextKeyRequestAuthorization) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsPreview reports whether a version is a preview version, which is the case for versions with a pre-release suffix.
+func IsPreview(v string) bool {
+	ver, err := version.NewVersion(v)
+	if err != nil {
+		return false
+	}
+
+	return ver.Prerelease() != ""
+}
@@ -0,0 +1,62 @@
+package module
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreviewMiddleware(t *testing.T) {
+	t.Parallel()
+
+	storage := NewInmemStorage()
+	for _, version := range []string{"1.0.0", "1.1.0-rc.1"} {
+		_, err := storage.UploadModule(context.Background(), "tier", "test", "aws", version, strings.NewReader("data"))
+		assert.NoError(t, err)
+	}
+
+	testCases := []struct {
+		name     string
+		token    string
+		elapsed  time.Duration
+		expected []string
+	}{
+		{name: "without preview scope", token: "other", expected: []string{"1.0.0"}},
+		{name: "with preview scope", token: "preview", expected: []string{"1.0.0", "1.1.0-rc.1"}},
+		{name: "expired preview", token: "preview", elapsed: 2 * time.Hour, expected: []string{"1.0.0"}},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+
+			svc := PreviewMiddleware([]string{"preview"}, time.Hour)(NewService(storage))
+			svc.(*previewMiddleware).now = func() time.Time { return time.Now().Add(tc.elapsed) }
+
+			ctx := context.WithValue(context.Background(), httptransport.ContextKeyRequestAuthorization, "Bearer "+tc.token)
+
+			modules, err := svc.ListModuleVersions(ctx, "tier", "test", "aws")
+			assert.NoError(err)
+
+			var versions []string
+			for _, module := range modules {
+				versions = append(versions, module.Version)
+			}
+			assert.ElementsMatch(tc.expected, versions)
+
+			_, err = svc.GetModule(ctx, "tier", "test", "aws", "1.1.0-rc.1")
+			if len(tc.expected) > 1 {
+				assert.NoError(err)
+			} else {
+				assert.True(errors.Is(err, ErrNotFound))
+			}
+		})
+	}
+}
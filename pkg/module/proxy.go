@@ -0,0 +1,204 @@
+package module
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ProxyStorage serves module archives through the registry instead of exposing the URLs of the storage backend,
+// so buckets can stay private. Terraform doesn't send credentials when downloading archives,
+// which is why the download URLs are signed and expire like presigned bucket URLs.
+type ProxyStorage struct {
+	Storage
+	baseURL       string
+	key           []byte
+	expiry        time.Duration
+	archiveFormat string
+	slots         chan struct{}
+	now           func() time.Time
+}
+
+// ProxyStorageOption provides additional options for the ProxyStorage.
+type ProxyStorageOption func(*ProxyStorage)
+
+// WithProxyURLExpiry configures how long download URLs are valid, it defaults to 15 minutes.
+func WithProxyURLExpiry(expiry time.Duration) ProxyStorageOption {
+	return func(s *ProxyStorage) {
+		if expiry > 0 {
+			s.expiry = expiry
+		}
+	}
+}
+
+// WithProxyArchiveFormat configures the extension of the download URLs, which Terraform uses to unpack the archives.
+func WithProxyArchiveFormat(archiveFormat string) ProxyStorageOption {
+	return func(s *ProxyStorage) {
+		if archiveFormat != "" {
+			s.archiveFormat = archiveFormat
+		}
+	}
+}
+
+// WithProxyMaxConcurrency limits how many archives are streamed from the storage at the same time,
+// further downloads are rejected with ErrOverloaded. Zero doesn't limit the downloads.
+func WithProxyMaxConcurrency(n int) ProxyStorageOption {
+	return func(s *ProxyStorage) {
+		if n > 0 {
+			s.slots = make(chan struct{}, n)
+		}
+	}
+}
+
+// NewProxyStorage returns a storage whose download URLs point to baseURL, where MakeArchiveHandler serves the archives.
+// The key signs the download URLs, all replicas serving the same registry need to use the same key.
+func NewProxyStorage(storage Storage, baseURL string, key []byte, options ...ProxyStorageOption) *ProxyStorage {
+	s := &ProxyStorage{
+		Storage:       storage,
+		baseURL:       baseURL,
+		key:           key,
+		expiry:        15 * time.Minute,
+		archiveFormat: DefaultArchiveFormat,
+		now:           time.Now,
+	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	return s
+}
+
+func (s *ProxyStorage) GetModule(ctx context.Context, namespace, name, provider, version string) (Module, error) {
+	module, err := s.Storage.GetModule(ctx, namespace, name, provider, version)
+	if err != nil {
+		return Module{}, err
+	}
+
+	module.DownloadURL = s.downloadURL(namespace, name, provider, version)
+	return module, nil
+}
+
+func (s *ProxyStorage) ListModuleVersions(ctx context.Context, namespace, name, provider string) ([]Module, error) {
+	modules, err := s.Storage.ListModuleVersions(ctx, namespace, name, provider)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.proxy(modules), nil
+}
+
+func (s *ProxyStorage) ListModules(ctx context.Context, namespace string) ([]Module, error) {
+	modules, err := s.Storage.ListModules(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.proxy(modules), nil
+}
+
+// OpenSignedModule opens the archive of a module if the signature of its download URL is valid and not expired.
+func (s *ProxyStorage) OpenSignedModule(ctx context.Context, namespace, name, provider, version string, expires int64, signature string) (io.ReadCloser, error) {
+	if s.now().Unix() > expires {
+		return nil, errors.Wrap(ErrInvalidSignature, "download URL expired")
+	}
+
+	expected, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(expected, s.sign(namespace, name, provider, version, expires)) {
+		return nil, ErrInvalidSignature
+	}
+
+	if s.slots == nil {
+		return s.openVerifiedModule(ctx, namespace, name, provider, version)
+	}
+
+	select {
+	case s.slots <- struct{}{}:
+	default:
+		return nil, errors.Wrap(ErrOverloaded, "too many concurrent downloads")
+	}
+
+	body, err := s.openVerifiedModule(ctx, namespace, name, provider, version)
+	if err != nil {
+		<-s.slots
+		return nil, err
+	}
+
+	// The slot is held until the archive has been streamed to the client
+	return &slotCloser{ReadCloser: body, release: func() { <-s.slots }}, nil
+}
+
+// slotCloser releases the download slot of a proxied archive once it is closed.
+type slotCloser struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (c *slotCloser) Close() error {
+	c.once.Do(c.release)
+	return c.ReadCloser.Close()
+}
+
+// openVerifiedModule opens the archive of a module and returns ErrChecksumMismatch if it doesn't match the digest
+// recorded on upload, e.g. because the object was modified in the bucket. Archives uploaded without a digest are served unverified.
+func (s *ProxyStorage) openVerifiedModule(ctx context.Context, namespace, name, provider, version string) (io.ReadCloser, error) {
+	module, err := s.Storage.GetModule(ctx, namespace, name, provider, version)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := s.Storage.OpenModule(ctx, namespace, name, provider, version)
+	if err != nil || module.Digest == "" {
+		return body, err
+	}
+	defer body.Close()
+
+	// The archive is spooled to a temporary file while it is hashed, so it's verified before the response is written
+	archive, digest, err := spoolArchive(body)
+	if err != nil {
+		return nil, wrapStorageError(ErrGetFailed, err)
+	}
+
+	if digest != module.Digest {
+		archive.Close()
+		return nil, errors.Wrapf(ErrChecksumMismatch, "archive has digest %s, recorded %s", digest, module.Digest)
+	}
+
+	return archive, nil
+}
+
+// proxy replaces the download URLs of the modules.
+func (s *ProxyStorage) proxy(modules []Module) []Module {
+	for i, module := range modules {
+		modules[i].DownloadURL = s.downloadURL(module.Namespace, module.Name, module.Provider, module.Version)
+	}
+
+	return modules
+}
+
+// downloadURL returns the signed URL the registry serves the archive of a module under.
+func (s *ProxyStorage) downloadURL(namespace, name, provider, version string) string {
+	expires := s.now().Add(s.expiry).Unix()
+
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("signature", hex.EncodeToString(s.sign(namespace, name, provider, version, expires)))
+
+	return fmt.Sprintf("%s/%s/%s/%s/%s/archive.%s?%s", s.baseURL, namespace, name, provider, version, s.archiveFormat, query.Encode())
+}
+
+func (s *ProxyStorage) sign(namespace, name, provider, version string, expires int64) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	fmt.Fprintf(mac, "%s/%s/%s/%s\n%d", namespace, name, provider, version, expires)
+	return mac.Sum(nil)
+}
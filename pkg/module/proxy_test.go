@@ -0,0 +1,156 @@
+package module
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxyStorage(t *testing.T) {
+	t.Parallel()
+
+	inmem := NewInmemStorage()
+	_, err := inmem.UploadModule(context.Background(), "tier", "test", "aws", "1.0.0", strings.NewReader("data"))
+	assert.NoError(t, err)
+
+	now := time.Unix(1600000000, 0)
+	storage := NewProxyStorage(inmem, "/v1/archives", []byte("secret"), WithProxyURLExpiry(time.Minute))
+	storage.now = func() time.Time { return now }
+
+	module, err := storage.GetModule(context.Background(), "tier", "test", "aws", "1.0.0")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(module.DownloadURL, "/v1/archives/tier/test/aws/1.0.0/archive.tar.gz?expires=1600000060&signature="), module.DownloadURL)
+
+	modules, err := storage.ListModuleVersions(context.Background(), "tier", "test", "aws")
+	assert.NoError(t, err)
+	if assert.Len(t, modules, 1) {
+		assert.Equal(t, module.DownloadURL, modules[0].DownloadURL)
+	}
+
+	// Archives modified in the storage after their upload aren't served
+	_, err = inmem.UploadModule(context.Background(), "tier", "test", "aws", "2.0.0", strings.NewReader("data"))
+	assert.NoError(t, err)
+	inmem.(*InmemStorage).moduleData[inmem.(*InmemStorage).moduleID("tier", "test", "aws", "2.0.0")] = bytes.NewReader([]byte("tampered"))
+	tampered, err := storage.GetModule(context.Background(), "tier", "test", "aws", "2.0.0")
+	assert.NoError(t, err)
+
+	handler := http.StripPrefix("/v1/archives", MakeArchiveHandler(storage, httptransport.ServerErrorEncoder(ErrorEncoder)))
+
+	testCases := []struct {
+		name           string
+		url            string
+		elapsed        time.Duration
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "signed",
+			url:            module.DownloadURL,
+			expectedStatus: http.StatusOK,
+			expectedBody:   "data",
+		},
+		{
+			name:           "expired",
+			url:            module.DownloadURL,
+			elapsed:        2 * time.Minute,
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "other version",
+			url:            strings.Replace(module.DownloadURL, "1.0.0", "1.0.1", 1),
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "tampered",
+			url:            tampered.DownloadURL,
+			expectedStatus: http.StatusBadGateway,
+		},
+		{
+			name:           "unsigned",
+			url:            "/v1/archives/tier/test/aws/1.0.0/archive.tar.gz",
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			storage.now = func() time.Time { return now.Add(tc.elapsed) }
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.url, nil))
+
+			assert.Equal(tc.expectedStatus, rec.Code)
+			if tc.expectedBody != "" {
+				assert.Equal(tc.expectedBody, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestProxyStorage_MaxConcurrency(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	inmem := NewInmemStorage()
+	_, err := inmem.UploadModule(context.Background(), "tier", "test", "aws", "1.0.0", strings.NewReader("data"))
+	assert.NoError(err)
+
+	storage := NewProxyStorage(inmem, "/v1/archives", []byte("secret"), WithProxyMaxConcurrency(1))
+	expires := time.Now().Add(time.Minute).Unix()
+	signature := hex.EncodeToString(storage.sign("tier", "test", "aws", "1.0.0", expires))
+
+	first, err := storage.OpenSignedModule(context.Background(), "tier", "test", "aws", "1.0.0", expires, signature)
+	assert.NoError(err)
+
+	_, err = storage.OpenSignedModule(context.Background(), "tier", "test", "aws", "1.0.0", expires, signature)
+	assert.Equal(ErrOverloaded, errors.Cause(err))
+
+	// Failed downloads don't hold a slot
+	assert.NoError(first.Close())
+	missing := hex.EncodeToString(storage.sign("tier", "test", "aws", "1.0.1", expires))
+	_, err = storage.OpenSignedModule(context.Background(), "tier", "test", "aws", "1.0.1", expires, missing)
+	assert.Error(err)
+
+	second, err := storage.OpenSignedModule(context.Background(), "tier", "test", "aws", "1.0.0", expires, signature)
+	assert.NoError(err)
+	assert.NoError(second.Close())
+}
+
+func TestProxyStorage_OpenVerifiedModule(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	inmem := NewInmemStorage()
+	_, err := inmem.UploadModule(context.Background(), "tier", "test", "aws", "1.0.0", strings.NewReader("data"))
+	assert.NoError(err)
+
+	storage := NewProxyStorage(inmem, "/v1/archives", []byte("secret"))
+	body, err := storage.openVerifiedModule(context.Background(), "tier", "test", "aws", "1.0.0")
+	assert.NoError(err)
+
+	data, err := ioutil.ReadAll(body)
+	assert.NoError(err)
+	assert.Equal("data", string(data))
+
+	// Verified archives are served from a temporary file, which is removed once the response was written
+	archive, ok := body.(*tempFile)
+	if assert.True(ok) {
+		assert.NoError(body.Close())
+		_, err = os.Stat(archive.Name())
+		assert.True(os.IsNotExist(err))
+	}
+}
@@ -0,0 +1,33 @@
+package module
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Reserved lists the namespaces and module names which can't be claimed by uploads.
+// Entries are either a namespace, e.g. "hashicorp", or a name within a namespace, e.g. "tier/aws",
+// where the namespace "*" reserves the name in every namespace. Entries match case-insensitively.
+type Reserved []string
+
+// Check returns ErrReserved if the namespace or the name of the module is reserved.
+func (r Reserved) Check(namespace, name string) error {
+	for _, entry := range r {
+		parts := strings.SplitN(entry, "/", 2)
+
+		if !strings.EqualFold(parts[0], namespace) && parts[0] != "*" {
+			continue
+		}
+
+		if len(parts) == 1 {
+			return errors.Wrapf(ErrReserved, "namespace %s", namespace)
+		}
+
+		if strings.EqualFold(parts[1], name) {
+			return errors.Wrapf(ErrReserved, "name %s/%s", namespace, name)
+		}
+	}
+
+	return nil
+}
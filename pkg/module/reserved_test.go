@@ -0,0 +1,38 @@
+package module
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReserved_Check(t *testing.T) {
+	t.Parallel()
+
+	reserved := Reserved{"hashicorp", "tier/aws", "*/terraform"}
+
+	testCases := []struct {
+		name      string
+		namespace string
+		module    string
+		expected  bool
+	}{
+		{name: "reserved namespace", namespace: "hashicorp", module: "vpc", expected: true},
+		{name: "reserved namespace in other case", namespace: "HashiCorp", module: "vpc", expected: true},
+		{name: "reserved name", namespace: "tier", module: "aws", expected: true},
+		{name: "reserved name in every namespace", namespace: "team", module: "Terraform", expected: true},
+		{name: "other name in namespace", namespace: "tier", module: "vpc"},
+		{name: "reserved name in other namespace", namespace: "team", module: "aws"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := reserved.Check(tc.namespace, tc.module)
+			assert.Equal(t, tc.expected, errors.Is(err, ErrReserved))
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package module
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Statuses of pruned module versions.
+const (
+	PruneStatusPlanned = "planned"
+	PruneStatusDeleted = "deleted"
+)
+
+// RetentionPolicy decides which versions of the modules of a namespace are kept. Versions are kept if they are
+// among the KeepLast latest versions of their module, were uploaded within KeepWithin or are pinned, all others are pruned.
+type RetentionPolicy struct {
+	// KeepLast is the number of latest versions of every module which are kept, at least the latest version is kept.
+	KeepLast int `json:"keep_last"`
+	// KeepWithin keeps all versions uploaded more recently, 0 keeps versions by their number only.
+	KeepWithin time.Duration `json:"keep_within"`
+}
+
+// PinnedVersions is a set of module versions in the format namespace/name/provider/version which are always kept,
+// e.g. because workspaces pin them.
+type PinnedVersions map[string]bool
+
+// PrunedVersion is a module version which isn't kept by a retention policy.
+type PrunedVersion struct {
+	// Module is the address of the module in the format namespace/name/provider.
+	Module      string    `json:"module"`
+	Version     string    `json:"version"`
+	PublishedAt time.Time `json:"published_at"`
+	Status      string    `json:"status"`
+}
+
+// PruneOption provides additional options for PruneVersions.
+type PruneOption func(*pruneOptions)
+
+type pruneOptions struct {
+	approval bool
+}
+
+// WithPruneApproval marks the namespace as requiring approval, so versions pending approval aren't released yet.
+func WithPruneApproval(approval bool) PruneOption {
+	return func(o *pruneOptions) {
+		o.approval = approval
+	}
+}
+
+// PruneVersions deletes the module versions of a namespace which the policy doesn't keep at the given time.
+// If dryRun is set, the deletions are only planned. Versions without upload time are kept, as their age is unknown.
+// Only released versions count towards KeepLast, versions scheduled in the future or pending approval are kept
+// without taking the place of a released version.
+func PruneVersions(ctx context.Context, storage Storage, namespace string, policy RetentionPolicy, pinned PinnedVersions, now time.Time, dryRun bool, options ...PruneOption) ([]PrunedVersion, error) {
+	if policy.KeepLast < 1 {
+		return nil, errors.New("retention policy must keep at least the latest version")
+	}
+
+	var opts pruneOptions
+	for _, option := range options {
+		option(&opts)
+	}
+
+	res, err := storage.ListModules(ctx, namespace)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	modules := make(map[string][]Module)
+	for _, m := range res {
+		id := fmt.Sprintf("%s/%s/%s", namespace, m.Name, m.Provider)
+		modules[id] = append(modules[id], m)
+	}
+
+	ids := make([]string, 0, len(modules))
+	for id := range modules {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	pruned := []PrunedVersion{}
+	for _, id := range ids {
+		versions, err := releasedVersions(ctx, storage, namespace, modules[id], now, opts.approval)
+		if err != nil {
+			return pruned, errors.Wrap(err, id)
+		}
+		sort.Slice(versions, func(i, j int) bool { return versionLess(versions[i].Version, versions[j].Version) })
+
+		if len(versions) <= policy.KeepLast {
+			continue
+		}
+
+		// The latest versions are at the end
+		for _, m := range versions[:len(versions)-policy.KeepLast] {
+			switch {
+			case pinned[fmt.Sprintf("%s/%s", id, m.Version)]:
+				continue
+			case m.Created.IsZero():
+				continue
+			case policy.KeepWithin > 0 && m.Created.After(now.Add(-policy.KeepWithin)):
+				continue
+			}
+
+			version := PrunedVersion{
+				Module:      id,
+				Version:     m.Version,
+				PublishedAt: m.Created.UTC(),
+				Status:      PruneStatusPlanned,
+			}
+
+			if !dryRun {
+				// Versions deleted concurrently, e.g. by another replica, count as deleted
+				if err := storage.DeleteModule(ctx, namespace, m.Name, m.Provider, m.Version); err != nil && !errors.Is(err, ErrNotFound) {
+					return pruned, errors.Wrapf(err, "%s/%s", id, m.Version)
+				}
+				version.Status = PruneStatusDeleted
+			}
+
+			pruned = append(pruned, version)
+		}
+	}
+
+	return pruned, nil
+}
+
+// releasedVersions returns the versions of a module which are neither scheduled in the future nor pending approval.
+func releasedVersions(ctx context.Context, storage Storage, namespace string, versions []Module, now time.Time, approval bool) ([]Module, error) {
+	m := versions[0]
+
+	schedules, err := storage.ListSchedules(ctx, namespace, m.Name, m.Provider)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	scheduled := make(map[string]bool)
+	for _, s := range schedules {
+		if now.Before(s.PublishAt) {
+			scheduled[s.Version] = true
+		}
+	}
+
+	approved := make(map[string]bool)
+	if approval {
+		approvals, err := storage.ListApprovals(ctx, namespace, m.Name, m.Provider)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+		for _, a := range approvals {
+			approved[a.Version] = true
+		}
+	}
+
+	released := make([]Module, 0, len(versions))
+	for _, v := range versions {
+		if scheduled[v.Version] || (approval && !approved[v.Version]) {
+			continue
+		}
+		released = append(released, v)
+	}
+
+	return released, nil
+}
@@ -0,0 +1,245 @@
+package module
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPruneVersions(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 5, 13, 9, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name     string
+		policy   RetentionPolicy
+		pinned   PinnedVersions
+		dryRun   bool
+		expected []string
+		err      bool
+	}{
+		{
+			name:     "keep last",
+			policy:   RetentionPolicy{KeepLast: 2},
+			expected: []string{"tier/vpc/aws/1.0.0", "tier/vpc/aws/1.2.0", "tier/vpc/aws/1.10.0"},
+		},
+		{
+			name:     "keep within",
+			policy:   RetentionPolicy{KeepLast: 1, KeepWithin: 75 * 24 * time.Hour},
+			expected: []string{"tier/vpc/aws/1.0.0", "tier/vpc/aws/1.2.0"},
+		},
+		{
+			name:     "pinned",
+			policy:   RetentionPolicy{KeepLast: 1},
+			pinned:   PinnedVersions{"tier/vpc/aws/1.2.0": true},
+			expected: []string{"tier/vpc/aws/1.0.0", "tier/vpc/aws/1.10.0", "tier/vpc/aws/2.0.0"},
+		},
+		{
+			name:     "dry run",
+			policy:   RetentionPolicy{KeepLast: 3},
+			dryRun:   true,
+			expected: []string{"tier/vpc/aws/1.0.0", "tier/vpc/aws/1.2.0"},
+		},
+		{
+			name:   "nothing kept",
+			policy: RetentionPolicy{KeepWithin: time.Hour},
+			err:    true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+
+			ctx := context.Background()
+			storage := NewInmemStorage()
+
+			// Versions are uploaded a month apart, the versions without upload time are never pruned
+			uploads := []struct {
+				name, version string
+				age           time.Duration
+			}{
+				{"vpc", "1.0.0", 120 * 24 * time.Hour},
+				{"vpc", "1.2.0", 90 * 24 * time.Hour},
+				{"vpc", "1.10.0", 60 * 24 * time.Hour},
+				{"vpc", "2.0.0", 30 * 24 * time.Hour},
+				{"vpc", "2.1.0", 0},
+				{"s3", "0.1.0", 0},
+				{"s3", "0.2.0", 0},
+			}
+			inmem := storage.(*InmemStorage)
+			for _, u := range uploads {
+				m, err := storage.UploadModule(ctx, "tier", u.name, "aws", u.version, strings.NewReader(u.version))
+				assert.NoError(err)
+
+				m.Created = time.Time{}
+				if u.age > 0 {
+					m.Created = now.Add(-u.age)
+				}
+				inmem.modules[inmem.moduleID("tier", u.name, "aws", u.version)] = m
+			}
+
+			pruned, err := PruneVersions(ctx, storage, "tier", tc.policy, tc.pinned, now, tc.dryRun)
+			if tc.err {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+
+			var versions []string
+			for _, p := range pruned {
+				versions = append(versions, p.Module+"/"+p.Version)
+
+				_, err := storage.GetModule(ctx, "tier", "vpc", "aws", p.Version)
+				if tc.dryRun {
+					assert.Equal(PruneStatusPlanned, p.Status)
+					assert.NoError(err)
+				} else {
+					assert.Equal(PruneStatusDeleted, p.Status)
+					assert.Equal(ErrNotFound, errors.Cause(err))
+				}
+			}
+			assert.Equal(tc.expected, versions)
+		})
+	}
+}
+
+func TestPruneVersions_Released(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 5, 13, 9, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name     string
+		approval bool
+		expected []string
+	}{
+		{
+			name:     "scheduled",
+			expected: []string{"1.0.0"},
+		},
+		{
+			name:     "pending approval",
+			approval: true,
+			expected: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+
+			ctx := context.Background()
+			storage := NewInmemStorage()
+			inmem := storage.(*InmemStorage)
+
+			for i, version := range []string{"1.0.0", "1.1.0", "2.0.0", "2.1.0"} {
+				m, err := storage.UploadModule(ctx, "tier", "vpc", "aws", version, strings.NewReader(version))
+				assert.NoError(err)
+				m.Created = now.Add(-time.Duration(4-i) * 24 * time.Hour)
+				inmem.modules[inmem.moduleID("tier", "vpc", "aws", version)] = m
+			}
+
+			// 1.1.0 and 2.0.0 are released, 2.1.0 is scheduled in the future
+			assert.NoError(storage.ScheduleModule(ctx, "tier", "vpc", "aws", "2.1.0", now.Add(time.Hour)))
+			for _, version := range []string{"1.1.0", "2.0.0"} {
+				assert.NoError(storage.ApproveModule(ctx, "tier", "vpc", "aws", version, Approval{Version: version, ApprovedAt: now}))
+			}
+
+			pruned, err := PruneVersions(ctx, storage, "tier", RetentionPolicy{KeepLast: 2}, nil, now, false, WithPruneApproval(tc.approval))
+			assert.NoError(err)
+
+			var versions []string
+			for _, p := range pruned {
+				versions = append(versions, p.Version)
+			}
+			assert.Equal(tc.expected, versions)
+
+			_, err = storage.GetModule(ctx, "tier", "vpc", "aws", "2.1.0")
+			assert.NoError(err)
+		})
+	}
+}
+
+func TestPruneVersions_Metadata(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 5, 13, 9, 0, 0, 0, time.UTC)
+
+	local, err := NewLocalStorage(t.TempDir())
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		name    string
+		storage Storage
+	}{
+		{
+			name:    "inmem",
+			storage: NewInmemStorage(),
+		},
+		{
+			name:    "local",
+			storage: local,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+
+			ctx := context.Background()
+			storage := tc.storage
+
+			for _, version := range []string{"1.0.0", "2.0.0"} {
+				_, err := storage.UploadModule(ctx, "tier", "vpc", "aws", version, strings.NewReader(version))
+				assert.NoError(err)
+				assert.NoError(storage.AddAnnotation(ctx, "tier", "vpc", "aws", version, Annotation{Text: "reviewed", CreatedAt: now}))
+				assert.NoError(storage.ApproveModule(ctx, "tier", "vpc", "aws", version, Approval{Version: version, ApprovedAt: now}))
+				assert.NoError(storage.ScheduleModule(ctx, "tier", "vpc", "aws", version, now.Add(-time.Hour)))
+				assert.NoError(storage.SetMaturity(ctx, "tier", "vpc", "aws", VersionMaturity{Version: version, Maturity: MaturityStable, UpdatedAt: now}))
+				assert.NoError(storage.SetDocs(ctx, "tier", "vpc", "aws", version, Docs{Readme: version}))
+			}
+
+			// Uploads are younger than now, so keep-within doesn't apply
+			pruned, err := PruneVersions(ctx, storage, "tier", RetentionPolicy{KeepLast: 1}, nil, now.Add(24*time.Hour), false)
+			assert.NoError(err)
+			assert.Len(pruned, 1)
+
+			annotations, err := storage.ListAnnotations(ctx, "tier", "vpc", "aws", "1.0.0")
+			assert.NoError(err)
+			assert.Empty(annotations)
+
+			_, err = storage.GetDocs(ctx, "tier", "vpc", "aws", "1.0.0")
+			assert.Equal(ErrNotFound, errors.Cause(err))
+
+			approvals, err := storage.ListApprovals(ctx, "tier", "vpc", "aws")
+			assert.NoError(err)
+			if assert.Len(approvals, 1) {
+				assert.Equal("2.0.0", approvals[0].Version)
+			}
+
+			schedules, err := storage.ListSchedules(ctx, "tier", "vpc", "aws")
+			assert.NoError(err)
+			assert.Len(schedules, 1)
+
+			maturities, err := storage.ListMaturities(ctx, "tier", "vpc", "aws")
+			assert.NoError(err)
+			assert.Len(maturities, 1)
+
+			docs, err := storage.GetDocs(ctx, "tier", "vpc", "aws", "2.0.0")
+			assert.NoError(err)
+			assert.Equal("2.0.0", docs.Readme)
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package module
+
+import (
+	"context"
+	"strings"
+)
+
+type rewriteMiddleware struct {
+	Service
+	rules map[string]string
+}
+
+// RewriteMiddleware serves requests for selected modules from other modules, e.g. public modules from their vetted internal mirrors.
+// Rules map a namespace to another namespace or a namespace/name/provider to another namespace/name/provider,
+// module rules take precedence over namespace rules. The served modules keep the coordinates of the request.
+func RewriteMiddleware(rules map[string]string) Middleware {
+	return func(next Service) Service {
+		return &rewriteMiddleware{
+			Service: next,
+			rules:   rules,
+		}
+	}
+}
+
+func (mw *rewriteMiddleware) GetModule(ctx context.Context, namespace, name, provider, version string) (Module, error) {
+	ns, n, p := mw.rewrite(namespace, name, provider)
+
+	res, err := mw.Service.GetModule(ctx, ns, n, p, version)
+	if err != nil {
+		return Module{}, err
+	}
+
+	res.Namespace, res.Name, res.Provider = namespace, name, provider
+	return res, nil
+}
+
+func (mw *rewriteMiddleware) ListModuleVersions(ctx context.Context, namespace, name, provider string) ([]Module, error) {
+	ns, n, p := mw.rewrite(namespace, name, provider)
+
+	res, err := mw.Service.ListModuleVersions(ctx, ns, n, p)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range res {
+		res[i].Namespace, res[i].Name, res[i].Provider = namespace, name, provider
+	}
+
+	return res, nil
+}
+
+// rewrite returns the module a request is served from.
+func (mw *rewriteMiddleware) rewrite(namespace, name, provider string) (string, string, string) {
+	if to, ok := mw.rules[namespace+"/"+name+"/"+provider]; ok {
+		if parts := strings.Split(to, "/"); len(parts) == 3 {
+			return parts[0], parts[1], parts[2]
+		}
+	}
+
+	if to, ok := mw.rules[namespace]; ok {
+		return to, name, provider
+	}
+
+	return namespace, name, provider
+}
@@ -0,0 +1,75 @@
+package module
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRewriteMiddleware(t *testing.T) {
+	t.Parallel()
+
+	storage := NewInmemStorage()
+	for _, m := range []Module{
+		{Namespace: "vetted", Name: "vpc", Provider: "aws", Version: "1.0.0"},
+		{Namespace: "platform", Name: "network", Provider: "aws", Version: "2.0.0"},
+	} {
+		_, err := storage.UploadModule(context.Background(), m.Namespace, m.Name, m.Provider, m.Version, strings.NewReader("data"))
+		assert.NoError(t, err)
+	}
+
+	svc := RewriteMiddleware(map[string]string{
+		"terraform-aws-modules":         "vetted",
+		"terraform-aws-modules/net/aws": "platform/network/aws",
+	})(NewService(storage))
+
+	testCases := []struct {
+		name      string
+		module    Module
+		expectErr bool
+	}{
+		{
+			name:   "namespace rule",
+			module: Module{Namespace: "terraform-aws-modules", Name: "vpc", Provider: "aws", Version: "1.0.0"},
+		},
+		{
+			name:   "module rule takes precedence",
+			module: Module{Namespace: "terraform-aws-modules", Name: "net", Provider: "aws", Version: "2.0.0"},
+		},
+		{
+			name:   "no rule",
+			module: Module{Namespace: "vetted", Name: "vpc", Provider: "aws", Version: "1.0.0"},
+		},
+		{
+			name:      "not mirrored",
+			module:    Module{Namespace: "terraform-aws-modules", Name: "eks", Provider: "aws", Version: "1.0.0"},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+
+			res, err := svc.GetModule(context.Background(), tc.module.Namespace, tc.module.Name, tc.module.Provider, tc.module.Version)
+			if tc.expectErr {
+				assert.True(errors.Is(err, ErrNotFound))
+				return
+			}
+
+			assert.NoError(err)
+			assert.Equal(tc.module.ID(true), res.ID(true))
+
+			modules, err := svc.ListModuleVersions(context.Background(), tc.module.Namespace, tc.module.Name, tc.module.Provider)
+			assert.NoError(err)
+			if assert.Len(modules, 1) {
+				assert.Equal(tc.module.ID(true), modules[0].ID(true))
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package module
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Schedule delays the publication of a module version until a given time.
+type Schedule struct {
+	Version   string    `json:"version"`
+	PublishAt time.Time `json:"publish_at"`
+}
+
+// schedulePrefix returns the prefix of the schedules of a module.
+func schedulePrefix(prefix, namespace, name, provider string) string {
+	return path.Join(
+		prefix,
+		"schedules",
+		fmt.Sprintf("namespace=%s", namespace),
+		fmt.Sprintf("name=%s", name),
+		fmt.Sprintf("provider=%s", provider),
+	) + "/"
+}
+
+// schedulePath returns the path of the schedule of a module version.
+// The version and publication time are part of the path, so schedules can be listed without reading them.
+func schedulePath(prefix, namespace, name, provider, version string, publishAt time.Time) string {
+	return fmt.Sprintf("%sversion=%s/publish_at=%d", schedulePrefix(prefix, namespace, name, provider), version, publishAt.Unix())
+}
+
+// parseSchedulePath returns the schedule of a schedule path.
+func parseSchedulePath(key string) (Schedule, error) {
+	metadata := objectMetadata(key)
+
+	sec, err := strconv.ParseInt(metadata["publish_at"], 10, 64)
+	if err != nil || metadata["version"] == "" {
+		return Schedule{}, errors.Errorf("invalid schedule %s", key)
+	}
+
+	return Schedule{
+		Version:   metadata["version"],
+		PublishAt: time.Unix(sec, 0).UTC(),
+	}, nil
+}
+
+type scheduleMiddleware struct {
+	Service
+	now func() time.Time
+}
+
+// ScheduleMiddleware hides module versions from all clients until their scheduled publication time.
+// Scheduled versions are reported as created at their publication time, so they show up in feeds when they are published.
+func ScheduleMiddleware() Middleware {
+	return func(next Service) Service {
+		return &scheduleMiddleware{
+			Service: next,
+			now:     time.Now,
+		}
+	}
+}
+
+func (mw *scheduleMiddleware) ListModuleVersions(ctx context.Context, namespace, name, provider string) ([]Module, error) {
+	res, err := mw.Service.ListModuleVersions(ctx, namespace, name, provider)
+	if err != nil {
+		return nil, err
+	}
+
+	schedules, err := mw.schedules(ctx, namespace, name, provider)
+	if err != nil {
+		return nil, err
+	}
+
+	var modules []Module
+	for _, module := range res {
+		if mw.publish(&module, schedules) {
+			modules = append(modules, module)
+		}
+	}
+
+	return modules, nil
+}
+
+func (mw *scheduleMiddleware) ListModules(ctx context.Context, namespace string) ([]Module, error) {
+	res, err := mw.Service.ListModules(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		modules   []Module
+		schedules = make(map[string]map[string]time.Time)
+	)
+
+	for _, module := range res {
+		key := module.Name + "/" + module.Provider
+		if _, ok := schedules[key]; !ok {
+			if schedules[key], err = mw.schedules(ctx, namespace, module.Name, module.Provider); err != nil {
+				return nil, err
+			}
+		}
+
+		if mw.publish(&module, schedules[key]) {
+			modules = append(modules, module)
+		}
+	}
+
+	return modules, nil
+}
+
+func (mw *scheduleMiddleware) GetModule(ctx context.Context, namespace, name, provider, version string) (Module, error) {
+	res, err := mw.Service.GetModule(ctx, namespace, name, provider, version)
+	if err != nil {
+		return Module{}, err
+	}
+
+	schedules, err := mw.schedules(ctx, namespace, name, provider)
+	if err != nil {
+		return Module{}, err
+	}
+
+	if !mw.publish(&res, schedules) {
+		return Module{}, errors.Wrap(ErrNotFound, "version not published yet")
+	}
+
+	return res, nil
+}
+
+func (mw *scheduleMiddleware) AddAnnotation(ctx context.Context, namespace, name, provider, version string, annotation Annotation) (Annotation, error) {
+	if _, err := mw.GetModule(ctx, namespace, name, provider, version); err != nil {
+		return Annotation{}, err
+	}
+
+	return mw.Service.AddAnnotation(ctx, namespace, name, provider, version, annotation)
+}
+
+func (mw *scheduleMiddleware) ListAnnotations(ctx context.Context, namespace, name, provider, version string) ([]Annotation, error) {
+	if _, err := mw.GetModule(ctx, namespace, name, provider, version); err != nil {
+		return nil, err
+	}
+
+	return mw.Service.ListAnnotations(ctx, namespace, name, provider, version)
+}
+
+func (mw *scheduleMiddleware) SetMaturity(ctx context.Context, namespace, name, provider, version string, maturity Maturity, reason string) (VersionMaturity, error) {
+	if _, err := mw.GetModule(ctx, namespace, name, provider, version); err != nil {
+		return VersionMaturity{}, err
+	}
+
+	return mw.Service.SetMaturity(ctx, namespace, name, provider, version, maturity, reason)
+}
+
+func (mw *scheduleMiddleware) GetDocs(ctx context.Context, namespace, name, provider, version string) (Docs, error) {
+	if _, err := mw.GetModule(ctx, namespace, name, provider, version); err != nil {
+		return Docs{}, err
+	}
+
+	return mw.Service.GetDocs(ctx, namespace, name, provider, version)
+}
+
+// schedules returns the publication times of the scheduled versions of a module.
+func (mw *scheduleMiddleware) schedules(ctx context.Context, namespace, name, provider string) (map[string]time.Time, error) {
+	res, err := mw.Service.ListSchedules(ctx, namespace, name, provider)
+	if err != nil {
+		return nil, err
+	}
+
+	schedules := make(map[string]time.Time)
+	for _, schedule := range res {
+		// A version scheduled several times is published at the latest time
+		if schedule.PublishAt.After(schedules[schedule.Version]) {
+			schedules[schedule.Version] = schedule.PublishAt
+		}
+	}
+
+	return schedules, nil
+}
+
+// publish reports whether a module is published and moves its creation time to the publication time.
+func (mw *scheduleMiddleware) publish(module *Module, schedules map[string]time.Time) bool {
+	publishAt, ok := schedules[module.Version]
+	if !ok {
+		return true
+	}
+
+	if mw.now().Before(publishAt) {
+		return false
+	}
+
+	if publishAt.After(module.Created) {
+		module.Created = publishAt
+	}
+
+	return true
+}
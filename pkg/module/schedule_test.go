@@ -0,0 +1,80 @@
+package module
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduleMiddleware(t *testing.T) {
+	t.Parallel()
+
+	publishAt := time.Now().Add(time.Hour).Truncate(time.Second).UTC()
+
+	storage := NewInmemStorage()
+	for _, version := range []string{"1.0.0", "1.1.0"} {
+		_, err := storage.UploadModule(context.Background(), "tier", "test", "aws", version, strings.NewReader("data"))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, storage.ScheduleModule(context.Background(), "tier", "test", "aws", "1.1.0", publishAt))
+
+	testCases := []struct {
+		name     string
+		elapsed  time.Duration
+		expected []string
+	}{
+		{name: "before publication", expected: []string{"1.0.0"}},
+		{name: "after publication", elapsed: 2 * time.Hour, expected: []string{"1.0.0", "1.1.0"}},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+
+			svc := ScheduleMiddleware()(NewService(storage))
+			svc.(*scheduleMiddleware).now = func() time.Time { return time.Now().Add(tc.elapsed) }
+
+			for _, list := range []func() ([]Module, error){
+				func() ([]Module, error) { return svc.ListModuleVersions(context.Background(), "tier", "test", "aws") },
+				func() ([]Module, error) { return svc.ListModules(context.Background(), "tier") },
+			} {
+				modules, err := list()
+				assert.NoError(err)
+
+				var versions []string
+				for _, module := range modules {
+					versions = append(versions, module.Version)
+				}
+				assert.ElementsMatch(tc.expected, versions)
+			}
+
+			res, err := svc.GetModule(context.Background(), "tier", "test", "aws", "1.1.0")
+			if len(tc.expected) > 1 {
+				assert.NoError(err)
+				assert.Equal(publishAt, res.Created)
+			} else {
+				assert.True(errors.Is(err, ErrNotFound))
+			}
+		})
+	}
+}
+
+func TestParseSchedulePath(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	publishAt := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+
+	schedule, err := parseSchedulePath(schedulePath("modules", "tier", "test", "aws", "1.1.0", publishAt))
+	assert.NoError(err)
+	assert.Equal(Schedule{Version: "1.1.0", PublishAt: publishAt}, schedule)
+
+	_, err = parseSchedulePath("modules/schedules/namespace=tier/name=test/provider=aws/version=1.1.0")
+	assert.Error(err)
+}
@@ -0,0 +1,260 @@
+package module
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/pkg/errors"
+)
+
+const (
+	// searchIndexTTL limits how long the index is used before it is built again from the storage listings,
+	// which is the longest new modules take to be found.
+	searchIndexTTL = time.Minute
+	// searchIndexTimeout limits how long building the index may take.
+	searchIndexTimeout = time.Minute
+
+	defaultSearchLimit = 15
+	maxSearchLimit     = 100
+)
+
+// searchIndex holds the latest version and labels of all modules, built from the listings of all namespaces.
+type searchIndex struct {
+	svc Service
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries []searchEntry
+	expires time.Time
+}
+
+type searchEntry struct {
+	module modulesResponseModule
+	// text is the lowercase address and labels of the module matched by search terms.
+	text string
+}
+
+func newSearchIndex(svc Service) *searchIndex {
+	return &searchIndex{
+		svc: svc,
+		ttl: searchIndexTTL,
+		now: time.Now,
+	}
+}
+
+// modules returns the entries of the index, which is built again once it expired.
+// Concurrent requests wait for a single build, and errors aren't cached.
+func (idx *searchIndex) modules() ([]searchEntry, error) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	now := idx.now()
+	if idx.entries != nil && now.Before(idx.expires) {
+		return idx.entries, nil
+	}
+
+	// The index is shared by all clients, so it's built without their credentials and only
+	// contains versions visible to everyone, e.g. no unapproved versions.
+	ctx, cancel := context.WithTimeout(context.Background(), searchIndexTimeout)
+	defer cancel()
+
+	entries, err := idx.build(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	idx.entries, idx.expires = entries, now.Add(idx.ttl)
+
+	return entries, nil
+}
+
+func (idx *searchIndex) build(ctx context.Context) ([]searchEntry, error) {
+	namespaces, err := idx.svc.ListNamespaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []searchEntry{}
+	for _, namespace := range namespaces {
+		res, err := idx.svc.ListModules(ctx, namespace)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, module := range latestVersions(res) {
+			module.Namespace = namespace
+
+			labels, err := idx.svc.GetLabels(ctx, module.Namespace, module.Name, module.Provider)
+			if err != nil {
+				return nil, err
+			}
+
+			m, err := summarizeModule(ctx, idx.svc, module, labels)
+			if err != nil {
+				return nil, err
+			}
+
+			text := []string{fmt.Sprintf("%s/%s/%s", m.Namespace, m.Name, m.Provider)}
+			for key, value := range labels {
+				text = append(text, key, value)
+			}
+
+			entries = append(entries, searchEntry{module: m, text: strings.ToLower(strings.Join(text, " "))})
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].module.ID < entries[j].module.ID
+	})
+
+	return entries, nil
+}
+
+type searchRequest struct {
+	// terms must all be part of the address or labels of a module, no terms list all modules.
+	terms     []string
+	namespace string
+	provider  string
+	offset    int
+	limit     int
+	// filter hides modules from the results, e.g. those restricted by an ACL.
+	filter func(Module) bool
+}
+
+type searchResponseMeta struct {
+	Limit         int  `json:"limit"`
+	CurrentOffset int  `json:"current_offset"`
+	NextOffset    *int `json:"next_offset,omitempty"`
+	PrevOffset    *int `json:"prev_offset,omitempty"`
+}
+
+type searchResponse struct {
+	Meta    searchResponseMeta      `json:"meta"`
+	Modules []modulesResponseModule `json:"modules"`
+}
+
+// searchEndpoint lists or searches the modules of all namespaces with their latest version, paginated like the public registry.
+func searchEndpoint(idx *searchIndex) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(searchRequest)
+
+		entries, err := idx.modules()
+		if err != nil {
+			return nil, err
+		}
+
+		var matches []modulesResponseModule
+		for _, entry := range entries {
+			m := entry.module
+			if req.namespace != "" && m.Namespace != req.namespace || req.provider != "" && m.Provider != req.provider {
+				continue
+			}
+			if !matchesTerms(entry.text, req.terms) {
+				continue
+			}
+			if req.filter != nil && !req.filter(Module{Namespace: m.Namespace, Name: m.Name, Provider: m.Provider, Version: m.Version}) {
+				continue
+			}
+			matches = append(matches, m)
+		}
+
+		response := searchResponse{
+			Meta: searchResponseMeta{
+				Limit:         req.limit,
+				CurrentOffset: req.offset,
+			},
+			Modules: []modulesResponseModule{},
+		}
+
+		if req.offset < len(matches) {
+			end := req.offset + req.limit
+			if end < len(matches) {
+				response.Meta.NextOffset = &end
+			} else {
+				end = len(matches)
+			}
+			response.Modules = matches[req.offset:end]
+		}
+		if req.offset > 0 {
+			prev := req.offset - req.limit
+			if prev < 0 {
+				prev = 0
+			}
+			response.Meta.PrevOffset = &prev
+		}
+
+		return response, nil
+	}
+}
+
+func matchesTerms(text string, terms []string) bool {
+	for _, term := range terms {
+		if !strings.Contains(text, term) {
+			return false
+		}
+	}
+	return true
+}
+
+// andFilter combines filters of modules, either of which may be nil.
+func andFilter(a, b func(Module) bool) func(Module) bool {
+	if a == nil {
+		return b
+	}
+	return func(m Module) bool {
+		return a(m) && b(m)
+	}
+}
+
+func decodeSearchRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	query := r.URL.Query()
+
+	req := searchRequest{
+		terms:     strings.Fields(strings.ToLower(query.Get("q"))),
+		namespace: query.Get("namespace"),
+		provider:  query.Get("provider"),
+		limit:     defaultSearchLimit,
+	}
+
+	// The search requires a query, while the listing of all modules doesn't
+	if strings.HasSuffix(r.URL.Path, "/search") && len(req.terms) == 0 {
+		return nil, errors.Wrap(ErrInvalidQuery, "q is required")
+	}
+
+	v := &ValidationError{}
+	if req.namespace != "" {
+		v.check("namespace", req.namespace, validateName)
+	}
+	if req.provider != "" {
+		v.check("provider", req.provider, validateName)
+	}
+	if err := v.errorOrNil(); err != nil {
+		return nil, err
+	}
+
+	if s := query.Get("offset"); s != "" {
+		offset, err := strconv.Atoi(s)
+		if err != nil || offset < 0 {
+			return nil, errors.Wrap(ErrInvalidQuery, "offset must be a non-negative integer")
+		}
+		req.offset = offset
+	}
+
+	if s := query.Get("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > maxSearchLimit {
+			return nil, errors.Wrapf(ErrInvalidQuery, "limit must be an integer between 1 and %d", maxSearchLimit)
+		}
+		req.limit = limit
+	}
+
+	return req, nil
+}
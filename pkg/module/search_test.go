@@ -0,0 +1,132 @@
+package module
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/TierMobility/boring-registry/pkg/auth"
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearch(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	storage := NewInmemStorage()
+	for _, m := range []struct{ namespace, name, provider, version string }{
+		{"tier", "vpc", "aws", "1.0.0"},
+		{"tier", "vpc", "aws", "1.1.0"},
+		{"tier", "vpc", "google", "0.1.0"},
+		{"tier", "s3", "aws", "1.0.0"},
+		{"tier", "secrets", "aws", "0.1.0"},
+		{"platform", "network", "aws", "2.0.0"},
+	} {
+		_, err := storage.UploadModule(ctx, m.namespace, m.name, m.provider, m.version, strings.NewReader("data"))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, storage.SetLabels(ctx, "tier", "s3", "aws", Labels{"tier": "storage"}))
+
+	handler := MakeHandler(
+		NewService(storage),
+		endpoint.Chain(
+			auth.Middleware("platform", "reader"),
+			ACLMiddleware(ACL{"tier/secrets/aws": {"platform"}}),
+		),
+		httptransport.ServerBefore(httptransport.PopulateRequestContext),
+		httptransport.ServerErrorEncoder(ErrorEncoder),
+	)
+
+	testCases := []struct {
+		name               string
+		path               string
+		token              string
+		expectedCode       int
+		expectedModules    []string
+		expectedNextOffset *int
+	}{
+		{
+			name:            "list all",
+			path:            "/",
+			token:           "reader",
+			expectedCode:    http.StatusOK,
+			expectedModules: []string{"platform/network/aws/2.0.0", "tier/s3/aws/1.0.0", "tier/vpc/aws/1.1.0", "tier/vpc/google/0.1.0"},
+		},
+		{
+			name:            "restricted modules",
+			path:            "/?namespace=tier&provider=aws",
+			token:           "platform",
+			expectedCode:    http.StatusOK,
+			expectedModules: []string{"tier/s3/aws/1.0.0", "tier/secrets/aws/0.1.0", "tier/vpc/aws/1.1.0"},
+		},
+		{
+			name:               "paginated",
+			path:               "/?limit=2&offset=1",
+			token:              "reader",
+			expectedCode:       http.StatusOK,
+			expectedModules:    []string{"tier/s3/aws/1.0.0", "tier/vpc/aws/1.1.0"},
+			expectedNextOffset: intPtr(3),
+		},
+		{
+			name:            "search by name",
+			path:            "/search?q=VPC",
+			token:           "reader",
+			expectedCode:    http.StatusOK,
+			expectedModules: []string{"tier/vpc/aws/1.1.0", "tier/vpc/google/0.1.0"},
+		},
+		{
+			name:            "search by label",
+			path:            "/search?q=tier+storage",
+			token:           "reader",
+			expectedCode:    http.StatusOK,
+			expectedModules: []string{"tier/s3/aws/1.0.0"},
+		},
+		{
+			name:            "no matches",
+			path:            "/search?q=rds",
+			token:           "reader",
+			expectedCode:    http.StatusOK,
+			expectedModules: []string{},
+		},
+		{name: "missing query", path: "/search", token: "reader", expectedCode: http.StatusBadRequest},
+		{name: "invalid limit", path: "/?limit=1000", token: "reader", expectedCode: http.StatusBadRequest},
+		{name: "invalid offset", path: "/search?q=vpc&offset=-1", token: "reader", expectedCode: http.StatusBadRequest},
+		{name: "unauthorized", path: "/search?q=vpc", token: "unknown", expectedCode: http.StatusUnauthorized},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedCode, rec.Code)
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+
+			var res searchResponse
+			assert.NoError(t, json.NewDecoder(rec.Body).Decode(&res))
+
+			ids := []string{}
+			for _, m := range res.Modules {
+				ids = append(ids, m.ID)
+			}
+			assert.Equal(t, tc.expectedModules, ids)
+			assert.Equal(t, tc.expectedNextOffset, res.Meta.NextOffset)
+		})
+	}
+}
+
+func intPtr(i int) *int {
+	return &i
+}
@@ -3,6 +3,11 @@ package module
 import (
 	"context"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/pkg/errors"
 )
 
 // Service implements the Module Registry Protocol.
@@ -10,17 +15,47 @@ import (
 type Service interface {
 	GetModule(ctx context.Context, namespace, name, provider, version string) (Module, error)
 	ListModuleVersions(ctx context.Context, namespace, name, provider string) ([]Module, error)
+	ListModules(ctx context.Context, namespace string) ([]Module, error)
+	ListNamespaces(ctx context.Context) ([]string, error)
+	UploadModule(ctx context.Context, namespace, name, provider, version string, body io.Reader) (Module, error)
+	AddAnnotation(ctx context.Context, namespace, name, provider, version string, annotation Annotation) (Annotation, error)
+	ListAnnotations(ctx context.Context, namespace, name, provider, version string) ([]Annotation, error)
+	ApproveModule(ctx context.Context, namespace, name, provider, version, approver string) (Approval, error)
+	ListApprovals(ctx context.Context, namespace, name, provider string) ([]Approval, error)
+	ListSchedules(ctx context.Context, namespace, name, provider string) ([]Schedule, error)
+	SetLabels(ctx context.Context, namespace, name, provider string, labels Labels) (Labels, error)
+	GetLabels(ctx context.Context, namespace, name, provider string) (Labels, error)
+	SetMaturity(ctx context.Context, namespace, name, provider, version string, maturity Maturity, reason string) (VersionMaturity, error)
+	ListMaturities(ctx context.Context, namespace, name, provider string) ([]VersionMaturity, error)
+	GetDocs(ctx context.Context, namespace, name, provider, version string) (Docs, error)
 }
 
 type service struct {
 	storage Storage
+	journal Journal
+}
+
+// ServiceOption provides additional options for the Service.
+type ServiceOption func(*service)
+
+// WithJournal records uploads in the journal before they touch the storage, see ReplayJournal.
+func WithJournal(journal Journal) ServiceOption {
+	return func(s *service) {
+		s.journal = journal
+	}
 }
 
 // NewService returns a fully initialized Service.
-func NewService(storage Storage) Service {
-	return &service{
+func NewService(storage Storage, options ...ServiceOption) Service {
+	s := &service{
 		storage: storage,
 	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	return s
 }
 
 func (s *service) GetModule(ctx context.Context, namespace, name, provider, version string) (Module, error) {
@@ -41,6 +76,239 @@ func (s *service) ListModuleVersions(ctx context.Context, namespace, name, provi
 	return res, nil
 }
 
+func (s *service) ListModules(ctx context.Context, namespace string) ([]Module, error) {
+	res, err := s.storage.ListModules(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (s *service) ListNamespaces(ctx context.Context) ([]string, error) {
+	return s.storage.ListNamespaces(ctx)
+}
+
+// UploadModule stores a module archive, the storage returns ErrAlreadyExists if the version exists already.
+// The documentation of the module is extracted from the archive and stored along with it.
+func (s *service) UploadModule(ctx context.Context, namespace, name, provider, version string, body io.Reader) (Module, error) {
+	if s.journal != nil {
+		return s.uploadJournaled(ctx, namespace, name, provider, version, body)
+	}
+
+	// The documentation is extracted while the archive is streamed to the storage
+	pr, pw := io.Pipe()
+	extracted := make(chan *Docs, 1)
+	go func() {
+		docs, err := extractDocs(pr)
+		// The rest of the archive is drained, so the upload isn't blocked by the pipe
+		_, _ = io.Copy(ioutil.Discard, pr)
+		if err != nil {
+			extracted <- nil
+			return
+		}
+		extracted <- &docs
+	}()
+
+	module, err := s.storage.UploadModule(ctx, namespace, name, provider, version, teeArchive(body, pw))
+	pw.Close()
+	docs := <-extracted
+	if err != nil {
+		return module, err
+	}
+
+	// The version is published already, so failing to store its documentation doesn't fail the upload,
+	// GetDocs extracts it from the archive again
+	if docs != nil {
+		_ = s.storage.SetDocs(ctx, namespace, name, provider, version, *docs)
+	}
+
+	return module, nil
+}
+
+// uploadJournaled writes the archive to the journal before it's uploaded from there.
+func (s *service) uploadJournaled(ctx context.Context, namespace, name, provider, version string, body io.Reader) (Module, error) {
+	entry := JournalEntry{
+		Namespace: namespace,
+		Name:      name,
+		Provider:  provider,
+		Version:   version,
+		StartedAt: time.Now().UTC(),
+	}
+	if d, ok := body.(digester); ok {
+		entry.Digest = d.Digest()
+	}
+
+	id, err := s.journal.Begin(entry, body)
+	if err != nil {
+		return Module{}, errors.Wrap(err, "failed to journal upload")
+	}
+
+	// The client learns the outcome of the upload, so it isn't replayed either way.
+	// An entry which fails to be removed is replayed without harm.
+	defer s.journal.Complete(id)
+
+	archive, err := s.journal.Archive(id)
+	if err != nil {
+		return Module{}, errors.Wrap(err, "failed to read journaled upload")
+	}
+	module, err := s.storage.UploadModule(ctx, namespace, name, provider, version, archive)
+	archive.Close()
+	if err != nil {
+		return module, err
+	}
+
+	if archive, err := s.journal.Archive(id); err == nil {
+		if docs, err := extractDocs(archive); err == nil {
+			_ = s.storage.SetDocs(ctx, namespace, name, provider, version, docs)
+		}
+		archive.Close()
+	}
+
+	return module, nil
+}
+
+// digestTee is an archive copied to a writer while it's read, which keeps the digest declared for the archive.
+type digestTee struct {
+	io.Reader
+	digest string
+}
+
+func (t digestTee) Digest() string {
+	return t.digest
+}
+
+// teeArchive is like io.TeeReader, but archives with a declared digest keep it, so they are still streamed to the storage.
+func teeArchive(body io.Reader, w io.Writer) io.Reader {
+	tee := io.TeeReader(body, w)
+	if d, ok := body.(digester); ok && d.Digest() != "" {
+		return digestTee{Reader: tee, digest: d.Digest()}
+	}
+	return tee
+}
+
+func (s *service) AddAnnotation(ctx context.Context, namespace, name, provider, version string, annotation Annotation) (Annotation, error) {
+	if err := annotation.validate(); err != nil {
+		return Annotation{}, err
+	}
+
+	if _, err := s.storage.GetModule(ctx, namespace, name, provider, version); err != nil {
+		return Annotation{}, err
+	}
+
+	annotation.CreatedAt = time.Now().UTC()
+
+	if err := s.storage.AddAnnotation(ctx, namespace, name, provider, version, annotation); err != nil {
+		return Annotation{}, err
+	}
+
+	return annotation, nil
+}
+
+func (s *service) ListAnnotations(ctx context.Context, namespace, name, provider, version string) ([]Annotation, error) {
+	if _, err := s.storage.GetModule(ctx, namespace, name, provider, version); err != nil {
+		return nil, err
+	}
+
+	return s.storage.ListAnnotations(ctx, namespace, name, provider, version)
+}
+
+func (s *service) ApproveModule(ctx context.Context, namespace, name, provider, version, approver string) (Approval, error) {
+	if len(approver) > maxAnnotationLength {
+		return Approval{}, errors.Wrapf(ErrInvalidAnnotation, "approver must not be longer than %d bytes", maxAnnotationLength)
+	}
+
+	if _, err := s.storage.GetModule(ctx, namespace, name, provider, version); err != nil {
+		return Approval{}, err
+	}
+
+	approval := Approval{
+		Version:    version,
+		Approver:   approver,
+		ApprovedAt: time.Now().UTC(),
+	}
+
+	if err := s.storage.ApproveModule(ctx, namespace, name, provider, version, approval); err != nil {
+		return Approval{}, err
+	}
+
+	return approval, nil
+}
+
+func (s *service) ListApprovals(ctx context.Context, namespace, name, provider string) ([]Approval, error) {
+	return s.storage.ListApprovals(ctx, namespace, name, provider)
+}
+
+func (s *service) ListSchedules(ctx context.Context, namespace, name, provider string) ([]Schedule, error) {
+	return s.storage.ListSchedules(ctx, namespace, name, provider)
+}
+
+// SetLabels replaces the labels of a module, which has to have at least one version.
+func (s *service) SetLabels(ctx context.Context, namespace, name, provider string, labels Labels) (Labels, error) {
+	if err := labels.validate(); err != nil {
+		return nil, err
+	}
+
+	if err := s.moduleExists(ctx, namespace, name, provider); err != nil {
+		return nil, err
+	}
+
+	if err := s.storage.SetLabels(ctx, namespace, name, provider, labels); err != nil {
+		return nil, err
+	}
+
+	return copyLabels(labels), nil
+}
+
+func (s *service) GetLabels(ctx context.Context, namespace, name, provider string) (Labels, error) {
+	if err := s.moduleExists(ctx, namespace, name, provider); err != nil {
+		return nil, err
+	}
+
+	return s.storage.GetLabels(ctx, namespace, name, provider)
+}
+
+func (s *service) SetMaturity(ctx context.Context, namespace, name, provider, version string, maturity Maturity, reason string) (VersionMaturity, error) {
+	if err := maturity.validate(reason); err != nil {
+		return VersionMaturity{}, err
+	}
+
+	if _, err := s.storage.GetModule(ctx, namespace, name, provider, version); err != nil {
+		return VersionMaturity{}, err
+	}
+
+	res := VersionMaturity{
+		Version:   version,
+		Maturity:  maturity,
+		Reason:    reason,
+		UpdatedAt: time.Now().UTC(),
+	}
+
+	if err := s.storage.SetMaturity(ctx, namespace, name, provider, res); err != nil {
+		return VersionMaturity{}, err
+	}
+
+	return res, nil
+}
+
+func (s *service) ListMaturities(ctx context.Context, namespace, name, provider string) ([]VersionMaturity, error) {
+	return s.storage.ListMaturities(ctx, namespace, name, provider)
+}
+
+// moduleExists returns ErrNotFound if a module has no versions.
+func (s *service) moduleExists(ctx context.Context, namespace, name, provider string) error {
+	versions, err := s.storage.ListModuleVersions(ctx, namespace, name, provider)
+	if err != nil {
+		return err
+	}
+
+	if len(versions) == 0 {
+		return errors.Wrapf(ErrNotFound, "%s/%s/%s", namespace, name, provider)
+	}
+
+	return nil
+}
+
 // Module represents Terraform module metadata.
 type Module struct {
 	Namespace   string `json:"namespace"`
@@ -48,6 +316,10 @@ type Module struct {
 	Provider    string `json:"provider"`
 	Version     string `json:"version"`
 	DownloadURL string `json:"download_url"`
+	// Digest of the module archive in the format sha256:<hex>, empty for modules uploaded without one.
+	Digest string `json:"digest,omitempty"`
+	// Created is the time the module was uploaded, zero if the storage doesn't provide it.
+	Created time.Time `json:"-"`
 }
 
 // ID returns the module metadata in a compact format.
@@ -59,3 +331,24 @@ func (m *Module) ID(version bool) string {
 
 	return id
 }
+
+// GetDocs returns the documentation of a module version.
+// Versions uploaded without the server, e.g. with the CLI, have no stored documentation, it is extracted from their archive instead.
+func (s *service) GetDocs(ctx context.Context, namespace, name, provider, version string) (Docs, error) {
+	docs, err := s.storage.GetDocs(ctx, namespace, name, provider, version)
+	if err != nil && errors.Cause(err) != ErrNotFound {
+		return Docs{}, err
+	}
+	// Documentation stored before the requirements of modules were extracted has no required providers at all
+	if err == nil && docs.RequiredProviders != nil {
+		return docs, nil
+	}
+
+	body, err := s.storage.OpenModule(ctx, namespace, name, provider, version)
+	if err != nil {
+		return Docs{}, err
+	}
+	defer body.Close()
+
+	return extractDocs(body)
+}
@@ -8,6 +8,7 @@ import (
 	"io"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -51,6 +52,9 @@ func TestService_GetModule(t *testing.T) {
 				Name:      "s3",
 				Provider:  "aws",
 				Version:   "1.0.0",
+				Digest: ArchiveDigest(testModuleData(map[string]string{
+					"main.tf": `name = "foo"`,
+				}).Bytes()),
 			},
 			data: testModuleData(map[string]string{
 				"main.tf": `name = "foo"`,
@@ -94,6 +98,8 @@ func TestService_GetModule(t *testing.T) {
 				assert.Error(err)
 			case false:
 				assert.NoError(err)
+				assert.False(module.Created.IsZero())
+				module.Created = time.Time{}
 				assert.Equal(tc.module, module)
 			}
 		})
@@ -183,6 +189,10 @@ func TestService_ListModuleVersions(t *testing.T) {
 				for _, module := range modules {
 					assert.True(strings.HasSuffix(module.DownloadURL, "."+tc.format))
 					module.DownloadURL = ""
+					assert.True(strings.HasPrefix(module.Digest, "sha256:"))
+					module.Digest = ""
+					assert.False(module.Created.IsZero())
+					module.Created = time.Time{}
 					versions = append(versions, module.Version)
 					module.Version = ""
 					assert.Equal(tc.module, module)
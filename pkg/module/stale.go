@@ -0,0 +1,63 @@
+package module
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// StaleModule is a module whose latest version was published before the staleness threshold.
+type StaleModule struct {
+	// Module is the address of the module in the format namespace/name/provider.
+	Module      string    `json:"module"`
+	Version     string    `json:"version"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+// StaleReport lists the modules of a namespace which aren't maintained anymore.
+type StaleReport struct {
+	Namespace string `json:"namespace"`
+	// LastPublishedAt is the upload time of the most recent version of any module of the namespace.
+	LastPublishedAt time.Time `json:"last_published_at"`
+	// Inactive is set if nothing has been published to the namespace since the inactivity threshold.
+	Inactive bool          `json:"inactive"`
+	Modules  []StaleModule `json:"modules"`
+}
+
+// Empty reports whether the namespace is neither inactive nor has stale modules.
+func (r *StaleReport) Empty() bool {
+	return !r.Inactive && len(r.Modules) == 0
+}
+
+// StaleModules returns the modules of a namespace whose latest version was published before staleBefore,
+// and whether nothing has been published to the namespace since inactiveBefore.
+// Preview versions are only considered the latest version of modules without other versions, like in the namespace listing.
+// Versions without upload time are ignored, as their age is unknown.
+func StaleModules(ctx context.Context, c catalog, namespace string, staleBefore, inactiveBefore time.Time) (*StaleReport, error) {
+	res, err := c.ListModules(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &StaleReport{Namespace: namespace, Modules: []StaleModule{}}
+
+	for _, m := range res {
+		if m.Created.After(report.LastPublishedAt) {
+			report.LastPublishedAt = m.Created.UTC()
+		}
+	}
+	report.Inactive = !report.LastPublishedAt.IsZero() && report.LastPublishedAt.Before(inactiveBefore)
+
+	for _, m := range latestVersions(res) {
+		if m.Created.IsZero() || !m.Created.Before(staleBefore) {
+			continue
+		}
+		report.Modules = append(report.Modules, StaleModule{
+			Module:      fmt.Sprintf("%s/%s/%s", namespace, m.Name, m.Provider),
+			Version:     m.Version,
+			PublishedAt: m.Created.UTC(),
+		})
+	}
+
+	return report, nil
+}
@@ -0,0 +1,71 @@
+package module
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStaleModules(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 5, 13, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	c := testCatalog{
+		modules: []Module{
+			{Name: "vpc", Provider: "aws", Version: "1.0.0", Created: now.Add(-400 * day)},
+			{Name: "vpc", Provider: "aws", Version: "1.1.0", Created: now.Add(-200 * day)},
+			{Name: "s3", Provider: "aws", Version: "0.1.0", Created: now.Add(-300 * day)},
+			{Name: "s3", Provider: "aws", Version: "0.2.0", Created: now.Add(-10 * day)},
+			{Name: "rds", Provider: "aws", Version: "1.0.0", Created: now.Add(-250 * day)},
+			{Name: "rds", Provider: "aws", Version: "2.0.0-beta.1", Created: now.Add(-day)},
+			{Name: "legacy", Provider: "aws", Version: "1.0.0"},
+		},
+	}
+
+	testCases := []struct {
+		name           string
+		inactiveBefore time.Time
+		expected       *StaleReport
+	}{
+		{
+			name:           "active",
+			inactiveBefore: now.Add(-90 * day),
+			expected: &StaleReport{
+				Namespace:       "tier",
+				LastPublishedAt: now.Add(-day),
+				Modules: []StaleModule{
+					{Module: "tier/rds/aws", Version: "1.0.0", PublishedAt: now.Add(-250 * day)},
+					{Module: "tier/vpc/aws", Version: "1.1.0", PublishedAt: now.Add(-200 * day)},
+				},
+			},
+		},
+		{
+			name:           "inactive",
+			inactiveBefore: now,
+			expected: &StaleReport{
+				Namespace:       "tier",
+				LastPublishedAt: now.Add(-day),
+				Inactive:        true,
+				Modules: []StaleModule{
+					{Module: "tier/rds/aws", Version: "1.0.0", PublishedAt: now.Add(-250 * day)},
+					{Module: "tier/vpc/aws", Version: "1.1.0", PublishedAt: now.Add(-200 * day)},
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			report, err := StaleModules(context.Background(), c, "tier", now.Add(-180*day), tc.inactiveBefore)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, report)
+		})
+	}
+}
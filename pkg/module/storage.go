@@ -2,20 +2,58 @@ package module
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"hash"
 	"io"
+	"io/ioutil"
+	"os"
 	"path"
+	"time"
+
+	"github.com/pkg/errors"
 )
 
 const (
 	DefaultArchiveFormat = "tar.gz"
+
+	// metadataKeyDigest is the object metadata key holding the digest of a module archive.
+	metadataKeyDigest = "digest"
 )
 
 // Storage represents the repository of Terraform modules.
 type Storage interface {
 	GetModule(ctx context.Context, namespace, name, provider, version string) (Module, error)
+	OpenModule(ctx context.Context, namespace, name, provider, version string) (io.ReadCloser, error)
 	ListModuleVersions(ctx context.Context, namespace, name, provider string) ([]Module, error)
+	ListModules(ctx context.Context, namespace string) ([]Module, error)
+	ListNamespaces(ctx context.Context) ([]string, error)
 	UploadModule(ctx context.Context, namespace, name, provider, version string, body io.Reader) (Module, error)
+	DeleteModule(ctx context.Context, namespace, name, provider, version string) error
+	AddAnnotation(ctx context.Context, namespace, name, provider, version string, annotation Annotation) error
+	ListAnnotations(ctx context.Context, namespace, name, provider, version string) ([]Annotation, error)
+	ApproveModule(ctx context.Context, namespace, name, provider, version string, approval Approval) error
+	ListApprovals(ctx context.Context, namespace, name, provider string) ([]Approval, error)
+	ScheduleModule(ctx context.Context, namespace, name, provider, version string, publishAt time.Time) error
+	ListSchedules(ctx context.Context, namespace, name, provider string) ([]Schedule, error)
+	SetLabels(ctx context.Context, namespace, name, provider string, labels Labels) error
+	GetLabels(ctx context.Context, namespace, name, provider string) (Labels, error)
+	SetMaturity(ctx context.Context, namespace, name, provider string, maturity VersionMaturity) error
+	ListMaturities(ctx context.Context, namespace, name, provider string) ([]VersionMaturity, error)
+	SetDocs(ctx context.Context, namespace, name, provider, version string, docs Docs) error
+	// GetDocs returns ErrNotFound if no documentation was stored for the version.
+	GetDocs(ctx context.Context, namespace, name, provider, version string) (Docs, error)
+}
+
+// namespacesPrefix returns the prefix of the directories of all namespaces.
+func namespacesPrefix(prefix string) string {
+	return path.Join(prefix, "namespace=")
+}
+
+// namespacePrefix returns the prefix of all modules of a namespace, including the trailing separator.
+func namespacePrefix(prefix, namespace string) string {
+	return path.Join(prefix, fmt.Sprintf("namespace=%s", namespace)) + "/"
 }
 
 func storagePrefix(prefix, namespace, name, provider string) string {
@@ -37,3 +75,146 @@ func storagePath(prefix, namespace, name, provider, version, format string) stri
 		fmt.Sprintf("%s-%s-%s-%s.%s", namespace, name, provider, version, format),
 	)
 }
+
+// versionMetadata returns the keys of the metadata of a module version and the prefixes of the metadata
+// with a key per change, which storage backends delete along with the version.
+func versionMetadata(prefix, namespace, name, provider, version string) ([]string, []string) {
+	keys := []string{
+		approvalPath(prefix, namespace, name, provider, version),
+		docsPath(prefix, namespace, name, provider, version),
+	}
+	prefixes := []string{
+		annotationPrefix(prefix, namespace, name, provider, version),
+		fmt.Sprintf("%sversion=%s/", schedulePrefix(prefix, namespace, name, provider), version),
+		fmt.Sprintf("%sversion=%s/", maturityPrefix(prefix, namespace, name, provider), version),
+	}
+
+	return keys, prefixes
+}
+
+// ArchiveDigest returns the digest of a module archive in the format sha256:<hex>.
+func ArchiveDigest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// hashDigest returns the digest of an archive hashed with SHA256 while it was streamed, like ArchiveDigest.
+func hashDigest(hash hash.Hash) string {
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil))
+}
+
+// digester is implemented by archives whose digest is known before they are read, e.g. from the Digest header
+// of an upload. Reading them fails with ErrInvalidArchive at their end if they don't match the digest.
+type digester interface {
+	io.Reader
+	Digest() string
+}
+
+// uploadArchive is a module archive being uploaded to a storage backend, which records its digest as metadata.
+type uploadArchive struct {
+	r      io.Reader
+	digest string
+	// err is the first error reading the archive, which takes precedence over the error of the storage.
+	err   error
+	close func() error
+}
+
+// openArchive prepares a module archive for its upload. Archives whose digest was declared are streamed
+// as they are read, seekable archives are hashed before they are rewound, and any other archive is spooled
+// to a temporary file while it's hashed, so archives are never held in memory.
+func openArchive(body io.Reader) (*uploadArchive, error) {
+	if d, ok := body.(digester); ok && d.Digest() != "" {
+		return &uploadArchive{r: d, digest: d.Digest()}, nil
+	}
+
+	if rs, ok := body.(io.ReadSeeker); ok {
+		// The archive doesn't necessarily start at the beginning, e.g. in a journal entry
+		start, err := rs.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, wrapStorageError(ErrUploadFailed, err)
+		}
+
+		hash := sha256.New()
+		if _, err := io.Copy(hash, rs); err != nil {
+			return nil, archiveError(err)
+		}
+		if _, err := rs.Seek(start, io.SeekStart); err != nil {
+			return nil, wrapStorageError(ErrUploadFailed, err)
+		}
+
+		return &uploadArchive{r: rs, digest: hashDigest(hash)}, nil
+	}
+
+	f, digest, err := spoolArchive(body)
+	if err != nil {
+		return nil, archiveError(err)
+	}
+
+	return &uploadArchive{r: f, digest: digest, close: f.Close}, nil
+}
+
+func (a *uploadArchive) Read(p []byte) (int, error) {
+	n, err := a.r.Read(p)
+	if err != nil && err != io.EOF && a.err == nil {
+		a.err = err
+	}
+	return n, err
+}
+
+// Close removes the temporary file of a spooled archive.
+func (a *uploadArchive) Close() error {
+	if a.close == nil {
+		return nil
+	}
+	return a.close()
+}
+
+// uploadError returns the error of a failed upload, an archive which failed to be read is reported instead of the storage.
+func (a *uploadArchive) uploadError(err error) error {
+	if a.err != nil {
+		return archiveError(a.err)
+	}
+	return wrapStorageError(ErrUploadFailed, err)
+}
+
+// archiveError keeps the errors of invalid archives, e.g. archives exceeding the size limit of the upload,
+// while other errors reading an archive fail the upload.
+func archiveError(err error) error {
+	switch errors.Cause(err) {
+	case ErrInvalidArchive, ErrArchiveTooLarge:
+		return err
+	}
+	return wrapStorageError(ErrUploadFailed, err)
+}
+
+// spoolArchive copies an archive to a temporary file while it's hashed and returns the file rewound to its start.
+func spoolArchive(body io.Reader) (*tempFile, string, error) {
+	f, err := ioutil.TempFile("", "boring-registry-archive-")
+	if err != nil {
+		return nil, "", err
+	}
+	archive := &tempFile{File: f}
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, hash), body); err != nil {
+		archive.Close()
+		return nil, "", err
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		archive.Close()
+		return nil, "", err
+	}
+
+	return archive, hashDigest(hash), nil
+}
+
+// tempFile removes the temporary file once it is closed.
+type tempFile struct {
+	*os.File
+}
+
+func (f *tempFile) Close() error {
+	defer os.Remove(f.Name())
+	return f.File.Close()
+}
@@ -0,0 +1,189 @@
+package module
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// cachingStorage is a Storage wrapper that caches the versions of recently listed modules in memory,
+// as listing them is an expensive call of the storage backend on every terraform init.
+type cachingStorage struct {
+	Storage
+	size   int
+	ttl    time.Duration
+	now    func() time.Time
+	report func(CacheEvent)
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+type cacheEntry struct {
+	key     string
+	modules []Module
+	expires time.Time
+}
+
+// CacheEvent is a lookup or removal of a cache entry, reported with WithCacheReport.
+type CacheEvent string
+
+// Events of caches. Lookups are hits or misses, every hit saves a call of the storage backend.
+const (
+	CacheHit  CacheEvent = "hit"
+	CacheMiss CacheEvent = "miss"
+	// CacheExpired is the removal of an entry which outlived its TTL.
+	CacheExpired CacheEvent = "expired"
+	// CacheFull is the eviction of the least recently used entry, as the cache is full.
+	CacheFull CacheEvent = "full"
+	// CacheInvalidated is the removal of an entry whose module was changed through the storage.
+	CacheInvalidated CacheEvent = "invalidated"
+)
+
+// CacheOption provides additional options for the caching storage.
+type CacheOption func(*cachingStorage)
+
+// WithCacheSize configures the maximum number of cached modules, the least recently used module is evicted first.
+func WithCacheSize(size int) CacheOption {
+	return func(s *cachingStorage) {
+		s.size = size
+	}
+}
+
+// WithCacheTTL configures how long the versions of a module are cached.
+func WithCacheTTL(ttl time.Duration) CacheOption {
+	return func(s *cachingStorage) {
+		s.ttl = ttl
+	}
+}
+
+// WithCacheReport configures a function which is called on every lookup and removal of a cache entry, e.g. to count them.
+// It is called while the cache is locked, so it must not block.
+func WithCacheReport(report func(CacheEvent)) CacheOption {
+	return func(s *cachingStorage) {
+		s.report = report
+	}
+}
+
+// NewCachingStorage returns a storage that caches the versions of 1024 modules for 30 seconds by default.
+// Uploads and deletions through the storage invalidate the versions of the module, modules uploaded by other
+// processes, e.g. the CLI, are listed once their cache entry expired.
+func NewCachingStorage(next Storage, options ...CacheOption) Storage {
+	s := &cachingStorage{
+		Storage: next,
+		size:    1024,
+		ttl:     30 * time.Second,
+		now:     time.Now,
+		report:  func(CacheEvent) {},
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	return s
+}
+
+// ListModuleVersions lists the versions of a module from the cache, errors of the wrapped storage aren't cached.
+func (s *cachingStorage) ListModuleVersions(ctx context.Context, namespace, name, provider string) ([]Module, error) {
+	key := fmt.Sprintf("%s/%s/%s", namespace, name, provider)
+
+	if modules, ok := s.get(key); ok {
+		return modules, nil
+	}
+
+	modules, err := s.Storage.ListModuleVersions(ctx, namespace, name, provider)
+	if err != nil {
+		return modules, err
+	}
+
+	s.put(key, modules)
+
+	return copyModules(modules), nil
+}
+
+func (s *cachingStorage) UploadModule(ctx context.Context, namespace, name, provider, version string, body io.Reader) (Module, error) {
+	defer s.invalidate(fmt.Sprintf("%s/%s/%s", namespace, name, provider))
+	return s.Storage.UploadModule(ctx, namespace, name, provider, version, body)
+}
+
+func (s *cachingStorage) DeleteModule(ctx context.Context, namespace, name, provider, version string) error {
+	defer s.invalidate(fmt.Sprintf("%s/%s/%s", namespace, name, provider))
+	return s.Storage.DeleteModule(ctx, namespace, name, provider, version)
+}
+
+func (s *cachingStorage) get(key string) ([]Module, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok {
+		s.report(CacheMiss)
+		return nil, false
+	}
+
+	entry := e.Value.(*cacheEntry)
+	if !s.now().Before(entry.expires) {
+		s.lru.Remove(e)
+		delete(s.entries, key)
+		s.report(CacheExpired)
+		s.report(CacheMiss)
+		return nil, false
+	}
+
+	s.lru.MoveToFront(e)
+	s.report(CacheHit)
+
+	return copyModules(entry.modules), true
+}
+
+func (s *cachingStorage) put(key string, modules []Module) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := &cacheEntry{key: key, modules: copyModules(modules), expires: s.now().Add(s.ttl)}
+
+	if e, ok := s.entries[key]; ok {
+		e.Value = entry
+		s.lru.MoveToFront(e)
+		return
+	}
+
+	s.entries[key] = s.lru.PushFront(entry)
+
+	for s.lru.Len() > s.size {
+		oldest := s.lru.Back()
+		s.lru.Remove(oldest)
+		delete(s.entries, oldest.Value.(*cacheEntry).key)
+		s.report(CacheFull)
+	}
+}
+
+func (s *cachingStorage) invalidate(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[key]; ok {
+		s.lru.Remove(e)
+		delete(s.entries, key)
+		s.report(CacheInvalidated)
+	}
+}
+
+// copyModules copies a list of modules, so callers can't modify cached lists.
+func copyModules(modules []Module) []Module {
+	if modules == nil {
+		return nil
+	}
+
+	res := make([]Module, len(modules))
+	copy(res, modules)
+
+	return res
+}
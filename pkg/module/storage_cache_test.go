@@ -0,0 +1,93 @@
+package module
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countingStorage counts the listings of module versions of the wrapped storage.
+type countingStorage struct {
+	Storage
+	lists int
+}
+
+func (s *countingStorage) ListModuleVersions(ctx context.Context, namespace, name, provider string) ([]Module, error) {
+	s.lists++
+	return s.Storage.ListModuleVersions(ctx, namespace, name, provider)
+}
+
+func TestCachingStorage(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	backend := &countingStorage{Storage: NewInmemStorage()}
+	for _, name := range []string{"vpc", "s3", "rds"} {
+		_, err := backend.UploadModule(ctx, "tier", name, "aws", "1.0.0", strings.NewReader("data"))
+		assert.NoError(err)
+	}
+
+	now := time.Date(2024, 5, 13, 9, 0, 0, 0, time.UTC)
+	events := make(map[CacheEvent]int)
+	storage := NewCachingStorage(backend, WithCacheSize(2), WithCacheTTL(time.Minute), WithCacheReport(func(event CacheEvent) {
+		events[event]++
+	}))
+	storage.(*cachingStorage).now = func() time.Time { return now }
+
+	list := func(name string) []Module {
+		modules, err := storage.ListModuleVersions(ctx, "tier", name, "aws")
+		assert.NoError(err)
+		return modules
+	}
+
+	// Cached lists can't be modified by callers
+	list("vpc")[0].Version = "9.9.9"
+	assert.Equal("1.0.0", list("vpc")[0].Version)
+	assert.Equal(1, backend.lists)
+
+	// Uploads invalidate the versions of the module
+	_, err := storage.UploadModule(ctx, "tier", "vpc", "aws", "1.1.0", strings.NewReader("data"))
+	assert.NoError(err)
+	assert.Len(list("vpc"), 2)
+	assert.Equal(2, backend.lists)
+
+	// The least recently used module is evicted
+	list("s3")
+	list("vpc")
+	list("rds")
+	assert.Equal(4, backend.lists)
+	list("vpc")
+	assert.Equal(4, backend.lists)
+	list("s3")
+	assert.Equal(5, backend.lists)
+
+	// Expired entries are listed again
+	now = now.Add(time.Minute)
+	list("s3")
+	assert.Equal(6, backend.lists)
+
+	// Errors aren't cached
+	_, err = storage.ListModuleVersions(ctx, "tier", "unknown", "aws")
+	assert.Error(err)
+	_, err = storage.ListModuleVersions(ctx, "tier", "unknown", "aws")
+	assert.Error(err)
+	assert.Equal(8, backend.lists)
+
+	// Deletions invalidate the versions of the module
+	assert.NoError(storage.DeleteModule(ctx, "tier", "s3", "aws", "1.0.0"))
+	_, err = storage.ListModuleVersions(ctx, "tier", "s3", "aws")
+	assert.Error(err)
+
+	// Every miss is a call of the storage backend
+	assert.Equal(map[CacheEvent]int{
+		CacheHit:         3,
+		CacheMiss:        backend.lists,
+		CacheExpired:     1,
+		CacheFull:        2,
+		CacheInvalidated: 2,
+	}, events)
+}
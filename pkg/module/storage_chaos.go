@@ -0,0 +1,286 @@
+package module
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var errChaos = errors.New("chaos: injected failure")
+
+// ChaosStorage is a Storage wrapper that injects faults into another Storage.
+// It is meant to test client retry behavior and server resilience and must not be used in production.
+type ChaosStorage struct {
+	next            Storage
+	maxLatency      time.Duration
+	errorRate       float64
+	partialReadRate float64
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+func (s *ChaosStorage) GetModule(ctx context.Context, namespace, name, provider, version string) (Module, error) {
+	if err := s.inject(ctx); err != nil {
+		return Module{}, err
+	}
+
+	return s.next.GetModule(ctx, namespace, name, provider, version)
+}
+
+func (s *ChaosStorage) OpenModule(ctx context.Context, namespace, name, provider, version string) (io.ReadCloser, error) {
+	if err := s.inject(ctx); err != nil {
+		return nil, err
+	}
+
+	return s.next.OpenModule(ctx, namespace, name, provider, version)
+}
+
+func (s *ChaosStorage) ListModuleVersions(ctx context.Context, namespace, name, provider string) ([]Module, error) {
+	if err := s.inject(ctx); err != nil {
+		return nil, err
+	}
+
+	return s.next.ListModuleVersions(ctx, namespace, name, provider)
+}
+
+func (s *ChaosStorage) ListModules(ctx context.Context, namespace string) ([]Module, error) {
+	if err := s.inject(ctx); err != nil {
+		return nil, err
+	}
+
+	return s.next.ListModules(ctx, namespace)
+}
+
+func (s *ChaosStorage) ListNamespaces(ctx context.Context) ([]string, error) {
+	if err := s.inject(ctx); err != nil {
+		return nil, err
+	}
+
+	return s.next.ListNamespaces(ctx)
+}
+
+func (s *ChaosStorage) UploadModule(ctx context.Context, namespace, name, provider, version string, body io.Reader) (Module, error) {
+	if err := s.inject(ctx); err != nil {
+		return Module{}, err
+	}
+
+	if s.chance(s.partialReadRate) {
+		body = &partialReader{r: body, remaining: s.intn(512)}
+	}
+
+	return s.next.UploadModule(ctx, namespace, name, provider, version, body)
+}
+
+func (s *ChaosStorage) DeleteModule(ctx context.Context, namespace, name, provider, version string) error {
+	if err := s.inject(ctx); err != nil {
+		return err
+	}
+
+	return s.next.DeleteModule(ctx, namespace, name, provider, version)
+}
+
+func (s *ChaosStorage) AddAnnotation(ctx context.Context, namespace, name, provider, version string, annotation Annotation) error {
+	if err := s.inject(ctx); err != nil {
+		return err
+	}
+
+	return s.next.AddAnnotation(ctx, namespace, name, provider, version, annotation)
+}
+
+func (s *ChaosStorage) ListAnnotations(ctx context.Context, namespace, name, provider, version string) ([]Annotation, error) {
+	if err := s.inject(ctx); err != nil {
+		return nil, err
+	}
+
+	return s.next.ListAnnotations(ctx, namespace, name, provider, version)
+}
+
+func (s *ChaosStorage) ApproveModule(ctx context.Context, namespace, name, provider, version string, approval Approval) error {
+	if err := s.inject(ctx); err != nil {
+		return err
+	}
+
+	return s.next.ApproveModule(ctx, namespace, name, provider, version, approval)
+}
+
+func (s *ChaosStorage) ListApprovals(ctx context.Context, namespace, name, provider string) ([]Approval, error) {
+	if err := s.inject(ctx); err != nil {
+		return nil, err
+	}
+
+	return s.next.ListApprovals(ctx, namespace, name, provider)
+}
+
+func (s *ChaosStorage) ScheduleModule(ctx context.Context, namespace, name, provider, version string, publishAt time.Time) error {
+	if err := s.inject(ctx); err != nil {
+		return err
+	}
+
+	return s.next.ScheduleModule(ctx, namespace, name, provider, version, publishAt)
+}
+
+func (s *ChaosStorage) ListSchedules(ctx context.Context, namespace, name, provider string) ([]Schedule, error) {
+	if err := s.inject(ctx); err != nil {
+		return nil, err
+	}
+
+	return s.next.ListSchedules(ctx, namespace, name, provider)
+}
+
+func (s *ChaosStorage) SetLabels(ctx context.Context, namespace, name, provider string, labels Labels) error {
+	if err := s.inject(ctx); err != nil {
+		return err
+	}
+
+	return s.next.SetLabels(ctx, namespace, name, provider, labels)
+}
+
+func (s *ChaosStorage) GetLabels(ctx context.Context, namespace, name, provider string) (Labels, error) {
+	if err := s.inject(ctx); err != nil {
+		return nil, err
+	}
+
+	return s.next.GetLabels(ctx, namespace, name, provider)
+}
+
+func (s *ChaosStorage) SetMaturity(ctx context.Context, namespace, name, provider string, maturity VersionMaturity) error {
+	if err := s.inject(ctx); err != nil {
+		return err
+	}
+
+	return s.next.SetMaturity(ctx, namespace, name, provider, maturity)
+}
+
+func (s *ChaosStorage) ListMaturities(ctx context.Context, namespace, name, provider string) ([]VersionMaturity, error) {
+	if err := s.inject(ctx); err != nil {
+		return nil, err
+	}
+
+	return s.next.ListMaturities(ctx, namespace, name, provider)
+}
+
+func (s *ChaosStorage) SetDocs(ctx context.Context, namespace, name, provider, version string, docs Docs) error {
+	if err := s.inject(ctx); err != nil {
+		return err
+	}
+
+	return s.next.SetDocs(ctx, namespace, name, provider, version, docs)
+}
+
+func (s *ChaosStorage) GetDocs(ctx context.Context, namespace, name, provider, version string) (Docs, error) {
+	if err := s.inject(ctx); err != nil {
+		return Docs{}, err
+	}
+
+	return s.next.GetDocs(ctx, namespace, name, provider, version)
+}
+
+// inject delays the call by a random latency and fails it according to the error rate.
+func (s *ChaosStorage) inject(ctx context.Context) error {
+	if s.maxLatency > 0 {
+		select {
+		case <-time.After(time.Duration(s.intn(int(s.maxLatency)))):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if s.chance(s.errorRate) {
+		return errChaos
+	}
+
+	return nil
+}
+
+func (s *ChaosStorage) chance(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.rnd.Float64() < rate
+}
+
+func (s *ChaosStorage) intn(n int) int {
+	if n <= 0 {
+		return 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.rnd.Intn(n)
+}
+
+// partialReader simulates a connection that breaks after a number of bytes.
+type partialReader struct {
+	r         io.Reader
+	remaining int
+}
+
+func (r *partialReader) Read(p []byte) (int, error) {
+	if r.remaining <= 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	if len(p) > r.remaining {
+		p = p[:r.remaining]
+	}
+
+	n, err := r.r.Read(p)
+	r.remaining -= n
+
+	return n, err
+}
+
+// ChaosStorageOption provides additional options for the ChaosStorage.
+type ChaosStorageOption func(*ChaosStorage)
+
+// WithChaosMaxLatency configures the upper bound of the random latency added to every call.
+func WithChaosMaxLatency(latency time.Duration) ChaosStorageOption {
+	return func(s *ChaosStorage) {
+		s.maxLatency = latency
+	}
+}
+
+// WithChaosErrorRate configures the fraction of calls (0 to 1) that fail.
+func WithChaosErrorRate(rate float64) ChaosStorageOption {
+	return func(s *ChaosStorage) {
+		s.errorRate = rate
+	}
+}
+
+// WithChaosPartialReadRate configures the fraction of uploads (0 to 1) whose body is cut off.
+func WithChaosPartialReadRate(rate float64) ChaosStorageOption {
+	return func(s *ChaosStorage) {
+		s.partialReadRate = rate
+	}
+}
+
+// WithChaosSeed configures the seed of the random number generator to make faults reproducible.
+func WithChaosSeed(seed int64) ChaosStorageOption {
+	return func(s *ChaosStorage) {
+		s.rnd = rand.New(rand.NewSource(seed))
+	}
+}
+
+// NewChaosStorage returns a Storage that injects faults into the next Storage.
+func NewChaosStorage(next Storage, options ...ChaosStorageOption) Storage {
+	s := &ChaosStorage{
+		next: next,
+		rnd:  rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	return s
+}
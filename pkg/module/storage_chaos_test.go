@@ -0,0 +1,66 @@
+package module
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChaosStorage(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		options   []ChaosStorageOption
+		expectErr bool
+	}{
+		{
+			name:      "no faults",
+			options:   nil,
+			expectErr: false,
+		},
+		{
+			name:      "always failing",
+			options:   []ChaosStorageOption{WithChaosErrorRate(1)},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+
+			ctx := context.Background()
+			storage := NewChaosStorage(NewInmemStorage(), append(tc.options, WithChaosSeed(1))...)
+
+			_, err := storage.UploadModule(ctx, "tier", "s3", "aws", "1.0.0", bytes.NewReader([]byte("data")))
+			_, getErr := storage.GetModule(ctx, "tier", "s3", "aws", "1.0.0")
+
+			if tc.expectErr {
+				assert.True(errors.Is(err, errChaos))
+				assert.True(errors.Is(getErr, errChaos))
+			} else {
+				assert.NoError(err)
+				assert.NoError(getErr)
+			}
+		})
+	}
+}
+
+func TestPartialReader(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	r := &partialReader{r: bytes.NewReader(bytes.Repeat([]byte("a"), 100)), remaining: 10}
+
+	data, err := ioutil.ReadAll(r)
+	assert.Len(data, 10)
+	assert.Equal(io.ErrUnexpectedEOF, err)
+}
@@ -1,11 +1,15 @@
 package module
 
 import (
+	"bytes"
 	"context"
+	"crypto/md5"
+	"encoding/json"
 	"fmt"
 	"io"
 	"time"
 
+	"cloud.google.com/go/compute/metadata"
 	credentials "cloud.google.com/go/iam/credentials/apiv1"
 	"cloud.google.com/go/storage"
 	"github.com/pkg/errors"
@@ -28,8 +32,10 @@ type GCSStorage struct {
 func (s *GCSStorage) GetModule(ctx context.Context, namespace, name, provider, version string) (Module, error) {
 	o := s.sc.Bucket(s.bucket).Object(storagePath(s.bucketPrefix, namespace, name, provider, version, s.archiveFormat))
 	attrs, err := o.Attrs(ctx)
-	if err != nil {
-		return Module{}, errors.Wrap(ErrNotFound, err.Error())
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return Module{}, wrapStorageError(ErrNotFound, err)
+	} else if err != nil {
+		return Module{}, wrapStorageError(ErrGetFailed, err)
 	}
 	var url string
 	if s.signedURL {
@@ -38,20 +44,34 @@ func (s *GCSStorage) GetModule(ctx context.Context, namespace, name, provider, v
 		url, err = s.generateDownloadURL(attrs.Bucket, attrs.Name)
 	}
 	if err != nil {
-		return Module{}, errors.Wrap(ErrNotFound, err.Error())
+		return Module{}, wrapStorageError(ErrGetFailed, err)
 	}
 	return Module{
 		Namespace: namespace,
-		Name:      attrs.Name,
+		Name:      name,
 		Provider:  provider,
 		Version:   version,
 		/* https://www.terraform.io/docs/internals/module-registry-protocol.html#sample-response-1
 		e.g. "gcs::https://www.googleapis.com/storage/v1/modules/foomodule.zip
 		*/
 		DownloadURL: url,
+		Digest:      attrs.Metadata[metadataKeyDigest],
+		Created:     attrs.Created,
 	}, nil
 }
 
+// OpenModule opens the archive of a module in the GCS storage.
+func (s *GCSStorage) OpenModule(ctx context.Context, namespace, name, provider, version string) (io.ReadCloser, error) {
+	r, err := s.sc.Bucket(s.bucket).Object(storagePath(s.bucketPrefix, namespace, name, provider, version, s.archiveFormat)).NewReader(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, wrapStorageError(ErrNotFound, err)
+	} else if err != nil {
+		return nil, wrapStorageError(ErrGetFailed, err)
+	}
+
+	return r, nil
+}
+
 func (s *GCSStorage) ListModuleVersions(ctx context.Context, namespace, name, provider string) ([]Module, error) {
 	var modules []Module
 	prefix := storagePrefix(s.bucketPrefix, namespace, name, provider)
@@ -66,7 +86,7 @@ func (s *GCSStorage) ListModuleVersions(ctx context.Context, namespace, name, pr
 			break
 		}
 		if err != nil {
-			return modules, err
+			return nil, wrapStorageError(ErrListFailed, err)
 		}
 		metadata := objectMetadata(attrs.Name)
 
@@ -76,7 +96,11 @@ func (s *GCSStorage) ListModuleVersions(ctx context.Context, namespace, name, pr
 		}
 
 		module := Module{
-			Version: version,
+			Namespace: namespace,
+			Name:      name,
+			Provider:  provider,
+			Version:   version,
+			Created:   attrs.Created,
 		}
 
 		modules = append(modules, module)
@@ -84,6 +108,67 @@ func (s *GCSStorage) ListModuleVersions(ctx context.Context, namespace, name, pr
 	return modules, nil
 }
 
+// ListModules lists all module versions of a namespace.
+func (s *GCSStorage) ListModules(ctx context.Context, namespace string) ([]Module, error) {
+	var modules []Module
+
+	query := &storage.Query{
+		Prefix: namespacePrefix(s.bucketPrefix, namespace),
+	}
+	it := s.sc.Bucket(s.bucket).Objects(ctx, query)
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, wrapStorageError(ErrListFailed, err)
+		}
+		metadata := objectMetadata(attrs.Name)
+
+		module := Module{
+			Namespace: namespace,
+			Name:      metadata["name"],
+			Provider:  metadata["provider"],
+			Version:   metadata["version"],
+			Created:   attrs.Created,
+		}
+
+		if module.Name == "" || module.Provider == "" || module.Version == "" {
+			continue
+		}
+
+		modules = append(modules, module)
+	}
+	return modules, nil
+}
+
+// ListNamespaces lists the namespaces with modules.
+func (s *GCSStorage) ListNamespaces(ctx context.Context) ([]string, error) {
+	var namespaces []string
+
+	query := &storage.Query{
+		Prefix:    namespacesPrefix(s.bucketPrefix),
+		Delimiter: "/",
+	}
+	it := s.sc.Bucket(s.bucket).Objects(ctx, query)
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, wrapStorageError(ErrListFailed, err)
+		}
+
+		// Directories are returned as synthetic objects with only a prefix
+		if namespace := objectMetadata(attrs.Prefix)["namespace"]; namespace != "" {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	return namespaces, nil
+}
+
 func (s *GCSStorage) UploadModule(ctx context.Context, namespace, name, provider, version string, body io.Reader) (Module, error) {
 	if namespace == "" {
 		return Module{}, errors.New("namespace not defined")
@@ -106,21 +191,336 @@ func (s *GCSStorage) UploadModule(ctx context.Context, namespace, name, provider
 		return Module{}, errors.Wrap(ErrAlreadyExists, key)
 	}
 
-	wc := s.sc.Bucket(s.bucket).Object(key).NewWriter(ctx)
-	if _, err := io.Copy(wc, body); err != nil {
-		return Module{}, errors.Wrapf(ErrUploadFailed, err.Error())
+	archive, err := openArchive(body)
+	if err != nil {
+		return Module{}, err
+	}
+	defer archive.Close()
+
+	// Canceling the context aborts the upload, so archives which fail to be read aren't stored
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// The check above only fails early, the precondition keeps concurrent uploads from overwriting each other
+	o := s.sc.Bucket(s.bucket).Object(key)
+	wc := o.If(storage.Conditions{DoesNotExist: true}).NewWriter(ctx)
+	wc.Metadata = map[string]string{
+		metadataKeyDigest: archive.digest,
+	}
+
+	// The archive is streamed, so its MD5 sum is only known once it was written
+	hash := md5.New()
+	if _, err := io.Copy(wc, io.TeeReader(archive, hash)); err != nil {
+		cancel()
+		return Module{}, archive.uploadError(err)
 	}
 	if err := wc.Close(); err != nil {
-		return Module{}, errors.Wrapf(ErrUploadFailed, err.Error())
+		return Module{}, archive.uploadError(err)
+	}
+
+	// A corrupted module is deleted again, so the upload can be retried
+	if sum := wc.Attrs().MD5; len(sum) > 0 && !bytes.Equal(sum, hash.Sum(nil)) {
+		if err := o.Delete(ctx); err != nil {
+			return Module{}, errors.Wrapf(ErrChecksumMismatch, "failed to delete corrupted module: %v", err)
+		}
+		return Module{}, errors.Wrapf(ErrChecksumMismatch, "object has MD5 sum %x, uploaded %x", sum, hash.Sum(nil))
 	}
 
 	return s.GetModule(ctx, namespace, name, provider, version)
 }
 
+// DeleteModule removes a module from the GCS storage.
+func (s *GCSStorage) DeleteModule(ctx context.Context, namespace, name, provider, version string) error {
+	o := s.sc.Bucket(s.bucket).Object(storagePath(s.bucketPrefix, namespace, name, provider, version, s.archiveFormat))
+	if _, err := o.Attrs(ctx); errors.Is(err, storage.ErrObjectNotExist) {
+		return errors.Wrap(ErrNotFound, err.Error())
+	}
+
+	// The metadata is deleted first, so a failed deletion can be retried as long as the version exists
+	keys, prefixes := versionMetadata(s.bucketPrefix, namespace, name, provider, version)
+	for _, prefix := range prefixes {
+		it := s.sc.Bucket(s.bucket).Objects(ctx, &storage.Query{Prefix: prefix})
+		for {
+			attrs, err := it.Next()
+			if err == iterator.Done {
+				break
+			}
+			if err != nil {
+				return wrapStorageError(ErrDeleteFailed, err)
+			}
+			keys = append(keys, attrs.Name)
+		}
+	}
+
+	for _, key := range keys {
+		if err := s.sc.Bucket(s.bucket).Object(key).Delete(ctx); err != nil && !errors.Is(err, storage.ErrObjectNotExist) {
+			return wrapStorageError(ErrDeleteFailed, err)
+		}
+	}
+
+	if err := o.Delete(ctx); errors.Is(err, storage.ErrObjectNotExist) {
+		return errors.Wrap(ErrNotFound, err.Error())
+	} else if err != nil {
+		return wrapStorageError(ErrDeleteFailed, err)
+	}
+
+	return nil
+}
+
+// AddAnnotation stores an annotation of a module version in the GCS storage.
+func (s *GCSStorage) AddAnnotation(ctx context.Context, namespace, name, provider, version string, annotation Annotation) error {
+	b, err := json.Marshal(annotation)
+	if err != nil {
+		return err
+	}
+
+	wc := s.sc.Bucket(s.bucket).Object(annotationPath(s.bucketPrefix, namespace, name, provider, version, annotation)).NewWriter(ctx)
+	wc.ContentType = "application/json"
+	if _, err := wc.Write(b); err != nil {
+		return wrapStorageError(ErrAnnotationFailed, err)
+	}
+	if err := wc.Close(); err != nil {
+		return wrapStorageError(ErrAnnotationFailed, err)
+	}
+
+	return nil
+}
+
+// ListAnnotations lists the annotations of a module version in the order they were added.
+func (s *GCSStorage) ListAnnotations(ctx context.Context, namespace, name, provider, version string) ([]Annotation, error) {
+	annotations := []Annotation{}
+
+	query := &storage.Query{
+		Prefix: annotationPrefix(s.bucketPrefix, namespace, name, provider, version),
+	}
+	it := s.sc.Bucket(s.bucket).Objects(ctx, query)
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, wrapStorageError(ErrListFailed, err)
+		}
+
+		r, err := s.sc.Bucket(s.bucket).Object(attrs.Name).NewReader(ctx)
+		if err != nil {
+			return nil, wrapStorageError(ErrListFailed, err)
+		}
+
+		var annotation Annotation
+		err = json.NewDecoder(r).Decode(&annotation)
+		r.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decode annotation %s", attrs.Name)
+		}
+
+		annotations = append(annotations, annotation)
+	}
+
+	return annotations, nil
+}
+
+// ApproveModule stores the approval of a module version in the GCS storage.
+func (s *GCSStorage) ApproveModule(ctx context.Context, namespace, name, provider, version string, approval Approval) error {
+	b, err := json.Marshal(approval)
+	if err != nil {
+		return err
+	}
+
+	wc := s.sc.Bucket(s.bucket).Object(approvalPath(s.bucketPrefix, namespace, name, provider, version)).NewWriter(ctx)
+	wc.ContentType = "application/json"
+	if _, err := wc.Write(b); err != nil {
+		return wrapStorageError(ErrApprovalFailed, err)
+	}
+	if err := wc.Close(); err != nil {
+		return wrapStorageError(ErrApprovalFailed, err)
+	}
+
+	return nil
+}
+
+// ListApprovals lists the approvals of all versions of a module.
+// Only the version and approval time are set, as the approvals aren't read.
+func (s *GCSStorage) ListApprovals(ctx context.Context, namespace, name, provider string) ([]Approval, error) {
+	approvals := []Approval{}
+
+	query := &storage.Query{
+		Prefix: approvalPrefix(s.bucketPrefix, namespace, name, provider),
+	}
+	it := s.sc.Bucket(s.bucket).Objects(ctx, query)
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, wrapStorageError(ErrListFailed, err)
+		}
+
+		approvals = append(approvals, Approval{
+			Version:    approvedVersion(attrs.Name),
+			ApprovedAt: attrs.Created,
+		})
+	}
+
+	return approvals, nil
+}
+
+// ScheduleModule stores the publication time of a module version in the GCS storage.
+func (s *GCSStorage) ScheduleModule(ctx context.Context, namespace, name, provider, version string, publishAt time.Time) error {
+	wc := s.sc.Bucket(s.bucket).Object(schedulePath(s.bucketPrefix, namespace, name, provider, version, publishAt)).NewWriter(ctx)
+	if err := wc.Close(); err != nil {
+		return wrapStorageError(ErrScheduleFailed, err)
+	}
+
+	return nil
+}
+
+// ListSchedules lists the schedules of all versions of a module.
+func (s *GCSStorage) ListSchedules(ctx context.Context, namespace, name, provider string) ([]Schedule, error) {
+	schedules := []Schedule{}
+
+	query := &storage.Query{
+		Prefix: schedulePrefix(s.bucketPrefix, namespace, name, provider),
+	}
+	it := s.sc.Bucket(s.bucket).Objects(ctx, query)
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, wrapStorageError(ErrListFailed, err)
+		}
+
+		schedule, err := parseSchedulePath(attrs.Name)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, schedule)
+	}
+
+	return schedules, nil
+}
+
+// SetLabels replaces the labels of a module in the GCS storage.
+func (s *GCSStorage) SetLabels(ctx context.Context, namespace, name, provider string, labels Labels) error {
+	b, err := json.Marshal(labels)
+	if err != nil {
+		return err
+	}
+
+	wc := s.sc.Bucket(s.bucket).Object(labelPath(s.bucketPrefix, namespace, name, provider)).NewWriter(ctx)
+	wc.ContentType = "application/json"
+	if _, err := wc.Write(b); err != nil {
+		return wrapStorageError(ErrLabelFailed, err)
+	}
+	if err := wc.Close(); err != nil {
+		return wrapStorageError(ErrLabelFailed, err)
+	}
+
+	return nil
+}
+
+// GetLabels returns the labels of a module, which are empty if none were set.
+func (s *GCSStorage) GetLabels(ctx context.Context, namespace, name, provider string) (Labels, error) {
+	key := labelPath(s.bucketPrefix, namespace, name, provider)
+
+	r, err := s.sc.Bucket(s.bucket).Object(key).NewReader(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return Labels{}, nil
+	} else if err != nil {
+		return nil, wrapStorageError(ErrGetFailed, err)
+	}
+	defer r.Close()
+
+	labels := Labels{}
+	if err := json.NewDecoder(r).Decode(&labels); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode labels %s", key)
+	}
+
+	return labels, nil
+}
+
+// SetMaturity stores the maturity of a module version in the GCS storage.
+func (s *GCSStorage) SetMaturity(ctx context.Context, namespace, name, provider string, maturity VersionMaturity) error {
+	wc := s.sc.Bucket(s.bucket).Object(maturityPath(s.bucketPrefix, namespace, name, provider, maturity)).NewWriter(ctx)
+	if err := wc.Close(); err != nil {
+		return wrapStorageError(ErrMaturityFailed, err)
+	}
+
+	return nil
+}
+
+// ListMaturities lists the latest maturity of all versions of a module.
+func (s *GCSStorage) ListMaturities(ctx context.Context, namespace, name, provider string) ([]VersionMaturity, error) {
+	var maturities []VersionMaturity
+
+	query := &storage.Query{
+		Prefix: maturityPrefix(s.bucketPrefix, namespace, name, provider),
+	}
+	it := s.sc.Bucket(s.bucket).Objects(ctx, query)
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, wrapStorageError(ErrListFailed, err)
+		}
+
+		maturity, err := parseMaturityPath(attrs.Name)
+		if err != nil {
+			return nil, err
+		}
+		maturities = append(maturities, maturity)
+	}
+
+	return latestMaturities(maturities), nil
+}
+
+// SetDocs stores the documentation of a module version in the GCS storage.
+func (s *GCSStorage) SetDocs(ctx context.Context, namespace, name, provider, version string, docs Docs) error {
+	b, err := json.Marshal(docs)
+	if err != nil {
+		return err
+	}
+
+	wc := s.sc.Bucket(s.bucket).Object(docsPath(s.bucketPrefix, namespace, name, provider, version)).NewWriter(ctx)
+	wc.ContentType = "application/json"
+	if _, err := wc.Write(b); err != nil {
+		return wrapStorageError(ErrDocsFailed, err)
+	}
+	if err := wc.Close(); err != nil {
+		return wrapStorageError(ErrDocsFailed, err)
+	}
+
+	return nil
+}
+
+// GetDocs returns the documentation of a module version.
+func (s *GCSStorage) GetDocs(ctx context.Context, namespace, name, provider, version string) (Docs, error) {
+	key := docsPath(s.bucketPrefix, namespace, name, provider, version)
+
+	r, err := s.sc.Bucket(s.bucket).Object(key).NewReader(ctx)
+	if err != nil {
+		return Docs{}, wrapStorageError(ErrGetFailed, err)
+	}
+	defer r.Close()
+
+	var docs Docs
+	if err := json.NewDecoder(r).Decode(&docs); err != nil {
+		return Docs{}, errors.Wrapf(err, "failed to decode docs %s", key)
+	}
+
+	return docs, nil
+}
+
 // GCSStorageOption provides additional options for the GCSStorage.
 type GCSStorageOption func(*GCSStorage)
 
-// WithGCSStorageBucketPrefix configures the s3 storage to work under a given prefix.
+// WithGCSStorageBucketPrefix configures the gcs storage to work under a given prefix.
 func WithGCSStorageBucketPrefix(prefix string) GCSStorageOption {
 	return func(s *GCSStorage) {
 		s.bucketPrefix = prefix
@@ -134,7 +534,7 @@ func WithGCSArchiveFormat(archiveFormat string) GCSStorageOption {
 	}
 }
 
-// WithGCSStorageSignedURL configures the s3 storage to work under a given prefix.
+// WithGCSStorageSignedURL configures the gcs storage to return signed download URLs, which need no GCP credentials to download.
 func WithGCSStorageSignedURL(set bool) GCSStorageOption {
 	return func(s *GCSStorage) {
 		s.signedURL = set
@@ -148,7 +548,7 @@ func WithGCSServiceAccount(sa string) GCSStorageOption {
 	}
 }
 
-// WithGCSServiceAccount configures Application Default Credentials (ADC) service account email.
+// WithGCSSignedUrlExpiry configures how many seconds signed URLs are valid.
 func WithGCSSignedUrlExpiry(seconds int64) GCSStorageOption {
 	return func(s *GCSStorage) {
 		s.signedURLExpiry = seconds
@@ -180,55 +580,64 @@ func (s *GCSStorage) generateDownloadURL(bucket, key string) (string, error) {
 
 // https://github.com/GoogleCloudPlatform/golang-samples/blob/73d60a5de091dcdda5e4f753b594ef18eee67906/storage/objects/generate_v4_get_object_signed_url.go#L28
 // generateV4GetObjectSignedURL generates object signed URL with GET method.
+// URLs are signed with the key of a service account key file, or with the IAM API as the configured service account
+// or the service account attached to the workload (e.g. with workload identity), which have no key.
 func (s *GCSStorage) generateV4GetObjectSignedURL(bucket, object string) (string, error) {
 	ctx := context.Background()
-	//https://godoc.org/golang.org/x/oauth2/google#DefaultClient
-	cred, err := google.FindDefaultCredentials(ctx, "cloud-platform")
-	if err != nil {
-		return "", fmt.Errorf("google.FindDefaultCredentials: %v", err)
+
+	opts := &storage.SignedURLOptions{
+		Scheme:  storage.SigningSchemeV4,
+		Method:  "GET",
+		Expires: time.Now().Add(time.Duration(s.signedURLExpiry) * time.Second),
 	}
 
-	var url string
-	if s.serviceAccount != "" {
-		// needs Service Account Token Creator role
-		c, err := credentials.NewIamCredentialsClient(ctx)
+	serviceAccount := s.serviceAccount
+	if serviceAccount == "" {
+		//https://godoc.org/golang.org/x/oauth2/google#DefaultClient
+		cred, err := google.FindDefaultCredentials(ctx, "cloud-platform")
 		if err != nil {
-			return "", fmt.Errorf("credentials.NewIamCredentialsClient: %v", err)
-		}
-
-		url, err = storage.SignedURL(bucket, object, &storage.SignedURLOptions{
-			Scheme:         storage.SigningSchemeV4,
-			Method:         "GET",
-			GoogleAccessID: s.serviceAccount,
-			Expires:        time.Now().Add(time.Duration(s.signedURLExpiry) * time.Second),
-			SignBytes: func(b []byte) ([]byte, error) {
-				req := &credentialspb.SignBlobRequest{
-					Payload: b,
-					Name:    s.serviceAccount,
-				}
-				resp, err := c.SignBlob(ctx, req)
-				if err != nil {
-					return nil, fmt.Errorf("storage.signedURL.SignBytes: %v", err)
-				}
-				return resp.SignedBlob, nil
-			},
-		})
+			return "", fmt.Errorf("google.FindDefaultCredentials: %v", err)
+		}
+
+		if conf, err := google.JWTConfigFromJSON(cred.JSON); err == nil {
+			opts.GoogleAccessID = conf.Email
+			opts.PrivateKey = conf.PrivateKey
+			return storage.SignedURL(bucket, object, opts)
+		}
+
+		if !metadata.OnGCE() {
+			return "", errors.New("signing URLs requires a service account key file, a service account email or running on GCP")
+		}
+
+		serviceAccount, err = metadata.Email("default")
 		if err != nil {
-			return "", fmt.Errorf("storage.signedURL: %v", err)
+			return "", fmt.Errorf("metadata.Email: %v", err)
 		}
-	} else {
-		conf, err := google.JWTConfigFromJSON(cred.JSON)
-		opts := &storage.SignedURLOptions{
-			Scheme:         storage.SigningSchemeV4,
-			Method:         "GET",
-			GoogleAccessID: conf.Email,
-			PrivateKey:     conf.PrivateKey,
-			Expires:        time.Now().Add(time.Duration(s.signedURLExpiry) * time.Second),
-		}
-		url, err = storage.SignedURL(bucket, object, opts)
+	}
+
+	// needs Service Account Token Creator role
+	c, err := credentials.NewIamCredentialsClient(ctx)
+	if err != nil {
+		return "", fmt.Errorf("credentials.NewIamCredentialsClient: %v", err)
+	}
+	defer c.Close()
+
+	opts.GoogleAccessID = serviceAccount
+	opts.SignBytes = func(b []byte) ([]byte, error) {
+		req := &credentialspb.SignBlobRequest{
+			Payload: b,
+			Name:    fmt.Sprintf("projects/-/serviceAccounts/%s", serviceAccount),
+		}
+		resp, err := c.SignBlob(ctx, req)
 		if err != nil {
-			return "", fmt.Errorf("storage.signedURL: %v", err)
+			return nil, fmt.Errorf("storage.signedURL.SignBytes: %v", err)
 		}
+		return resp.SignedBlob, nil
+	}
+
+	url, err := storage.SignedURL(bucket, object, opts)
+	if err != nil {
+		return "", fmt.Errorf("storage.signedURL: %v", err)
 	}
 
 	return url, nil
@@ -0,0 +1,281 @@
+package module
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+	"github.com/pkg/errors"
+)
+
+// DefaultGitHubAPIURL is the API of github.com, GitHub Enterprise servers serve it under https://<host>/api/v3.
+const DefaultGitHubAPIURL = "https://api.github.com"
+
+// GitHubCacheStorage is a Storage wrapper that pulls modules of allowlisted GitHub organizations through on first request.
+// The module tier/vpc/aws maps to the tags of the repository github.com/tier/terraform-aws-vpc, like on the public registry.
+// A missing version is downloaded from GitHub, repackaged as registry module and stored in the wrapped storage,
+// which serves it from then on.
+type GitHubCacheStorage struct {
+	Storage
+	orgs   map[string]bool
+	apiURL string
+	token  string
+	client *http.Client
+}
+
+// GetModule retrieves a module from the wrapped storage and pulls it from GitHub if it's missing.
+func (s *GitHubCacheStorage) GetModule(ctx context.Context, namespace, name, provider, version string) (Module, error) {
+	module, err := s.Storage.GetModule(ctx, namespace, name, provider, version)
+	if err == nil || !errors.Is(err, ErrNotFound) || !s.orgs[namespace] {
+		return module, err
+	}
+
+	data, err := s.archive(ctx, namespace, name, provider, version)
+	if err != nil {
+		return Module{}, err
+	}
+
+	module, err = s.Storage.UploadModule(ctx, namespace, name, provider, version, bytes.NewReader(data))
+	if errors.Is(err, ErrAlreadyExists) {
+		// Another request pulled the same version in the meantime
+		return s.Storage.GetModule(ctx, namespace, name, provider, version)
+	}
+
+	return module, err
+}
+
+// ListModuleVersions lists the stored module versions together with all versions tagged on GitHub.
+func (s *GitHubCacheStorage) ListModuleVersions(ctx context.Context, namespace, name, provider string) ([]Module, error) {
+	modules, err := s.Storage.ListModuleVersions(ctx, namespace, name, provider)
+	if !s.orgs[namespace] {
+		return modules, err
+	}
+
+	tags, tagErr := s.tags(ctx, namespace, name, provider)
+	if tagErr != nil {
+		// Serve the stored versions if GitHub is unavailable
+		if err == nil && len(modules) > 0 {
+			return modules, nil
+		}
+		return nil, tagErr
+	}
+
+	stored := make(map[string]bool)
+	for _, module := range modules {
+		stored[module.Version] = true
+	}
+
+	for _, tag := range tags {
+		v := strings.TrimPrefix(tag, "v")
+		if _, err := version.NewSemver(v); err != nil || stored[v] {
+			continue
+		}
+
+		stored[v] = true
+		modules = append(modules, Module{
+			Namespace: namespace,
+			Name:      name,
+			Provider:  provider,
+			Version:   v,
+		})
+	}
+
+	return modules, nil
+}
+
+// archive downloads the tarball of a version and repackages it as module archive.
+// The version is looked up as tag v<version> first and as tag <version> afterwards.
+func (s *GitHubCacheStorage) archive(ctx context.Context, namespace, name, provider, version string) ([]byte, error) {
+	for _, tag := range []string{"v" + version, version} {
+		res, err := s.get(ctx, fmt.Sprintf("/repos/%s/%s/tarball/%s", url.PathEscape(namespace), url.PathEscape(gitHubRepository(name, provider)), url.PathEscape(tag)))
+		if err != nil {
+			return nil, err
+		}
+
+		if res.StatusCode == http.StatusNotFound {
+			res.Body.Close()
+			continue
+		}
+
+		data, err := repackageGitHubArchive(res.Body)
+		res.Body.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to repackage %s/%s@%s", namespace, gitHubRepository(name, provider), tag)
+		}
+
+		return data, nil
+	}
+
+	return nil, errors.Wrapf(ErrNotFound, "no tag %s on github.com/%s/%s", version, namespace, gitHubRepository(name, provider))
+}
+
+// tags lists all tags of the repository of a module.
+func (s *GitHubCacheStorage) tags(ctx context.Context, namespace, name, provider string) ([]string, error) {
+	var tags []string
+
+	for page := 1; ; page++ {
+		res, err := s.get(ctx, fmt.Sprintf("/repos/%s/%s/tags?per_page=100&page=%d", url.PathEscape(namespace), url.PathEscape(gitHubRepository(name, provider)), page))
+		if err != nil {
+			return nil, err
+		}
+
+		if res.StatusCode == http.StatusNotFound {
+			res.Body.Close()
+			return tags, nil
+		}
+
+		var body []struct {
+			Name string `json:"name"`
+		}
+		err = json.NewDecoder(res.Body).Decode(&body)
+		res.Body.Close()
+		if err != nil {
+			return nil, wrapStorageError(ErrListFailed, err)
+		}
+
+		for _, tag := range body {
+			tags = append(tags, tag.Name)
+		}
+
+		if len(body) < 100 {
+			return tags, nil
+		}
+	}
+}
+
+// get sends a request to the GitHub API, any status other than 200 and 404 is an error.
+func (s *GitHubCacheStorage) get(ctx context.Context, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.apiURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, wrapStorageError(ErrNotFound, err)
+	}
+
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNotFound {
+		res.Body.Close()
+		return nil, wrapStorageError(ErrNotFound, fmt.Errorf("GitHub responded with %s to %s", res.Status, path))
+	}
+
+	return res, nil
+}
+
+// gitHubRepository returns the repository name of a module, following the terraform-<PROVIDER>-<NAME> convention.
+func gitHubRepository(name, provider string) string {
+	return fmt.Sprintf("terraform-%s-%s", provider, name)
+}
+
+// repackageGitHubArchive strips the top-level directory GitHub wraps all files of a tarball in,
+// so the files of the repository are at the root of the module archive.
+func repackageGitHubArchive(r io.Reader) ([]byte, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gr.Close()
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if header.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+
+		parts := strings.SplitN(header.Name, "/", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			continue
+		}
+		header.Name = parts[1]
+
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, err
+		}
+
+		if _, err := io.Copy(tw, tr); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+
+	if err := gw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// GitHubCacheStorageOption provides additional options for the GitHubCacheStorage.
+type GitHubCacheStorageOption func(*GitHubCacheStorage)
+
+// WithGitHubCacheAPIURL configures the GitHub API to pull modules from, e.g. of a GitHub Enterprise server.
+func WithGitHubCacheAPIURL(apiURL string) GitHubCacheStorageOption {
+	return func(s *GitHubCacheStorage) {
+		s.apiURL = strings.TrimSuffix(apiURL, "/")
+	}
+}
+
+// WithGitHubCacheToken configures the token to authenticate to GitHub with, which is required for private repositories.
+func WithGitHubCacheToken(token string) GitHubCacheStorageOption {
+	return func(s *GitHubCacheStorage) {
+		s.token = token
+	}
+}
+
+// WithGitHubCacheHTTPClient configures the HTTP client of all requests to GitHub.
+func WithGitHubCacheHTTPClient(client *http.Client) GitHubCacheStorageOption {
+	return func(s *GitHubCacheStorage) {
+		if client != nil {
+			s.client = client
+		}
+	}
+}
+
+// NewGitHubCacheStorage returns a storage which pulls modules of the given GitHub organizations through into the storage.
+func NewGitHubCacheStorage(storage Storage, orgs []string, options ...GitHubCacheStorageOption) *GitHubCacheStorage {
+	s := &GitHubCacheStorage{
+		Storage: storage,
+		orgs:    make(map[string]bool),
+		apiURL:  DefaultGitHubAPIURL,
+		client:  http.DefaultClient,
+	}
+
+	for _, org := range orgs {
+		s.orgs[org] = true
+	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	return s
+}
@@ -0,0 +1,121 @@
+package module
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// testGitHubTarball returns a tarball like GitHub creates it, with all files below a top-level directory.
+func testGitHubTarball(files map[string]string) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+
+	_ = tw.WriteHeader(&tar.Header{Typeflag: tar.TypeXGlobalHeader, Name: "pax_global_header", PAXRecords: map[string]string{"comment": "abc"}})
+	_ = tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: "tier-terraform-aws-vpc-abc/", Mode: 0o755})
+	for name, content := range files {
+		_ = tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: "tier-terraform-aws-vpc-abc/" + name, Mode: 0o644, Size: int64(len(content))})
+		_, _ = tw.Write([]byte(content))
+	}
+
+	_ = tw.Close()
+	_ = gw.Close()
+
+	return buf.Bytes()
+}
+
+func archiveFiles(t *testing.T, data []byte) []string {
+	gr, err := gzip.NewReader(bytes.NewReader(data))
+	assert.NoError(t, err)
+
+	var names []string
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		names = append(names, header.Name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+func TestGitHubCacheStorage(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	var downloads int32
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/tier/terraform-aws-vpc/tags", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("Bearer secret", r.Header.Get("Authorization"))
+		w.Write([]byte(`[{"name": "v1.1.0"}, {"name": "1.0.0"}, {"name": "latest"}]`))
+	})
+	mux.HandleFunc("/repos/tier/terraform-aws-vpc/tarball/v1.1.0", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&downloads, 1)
+		w.Write(testGitHubTarball(map[string]string{
+			"main.tf":            `name = "vpc"`,
+			"modules/subnet.tf":  `name = "subnet"`,
+			"examples/simple.tf": `name = "simple"`,
+		}))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	var (
+		ctx     = context.Background()
+		inner   = NewInmemStorage()
+		storage = NewGitHubCacheStorage(inner, []string{"tier"},
+			WithGitHubCacheAPIURL(server.URL+"/"),
+			WithGitHubCacheToken("secret"),
+		)
+	)
+
+	_, err := inner.UploadModule(ctx, "tier", "vpc", "aws", "0.9.0", testModuleData(map[string]string{
+		"main.tf": `name = "vpc"`,
+	}))
+	assert.NoError(err)
+
+	modules, err := storage.ListModuleVersions(ctx, "tier", "vpc", "aws")
+	assert.NoError(err)
+
+	var versions []string
+	for _, module := range modules {
+		versions = append(versions, module.Version)
+	}
+	assert.ElementsMatch([]string{"0.9.0", "1.1.0", "1.0.0"}, versions)
+
+	module, err := storage.GetModule(ctx, "tier", "vpc", "aws", "1.1.0")
+	assert.NoError(err)
+	assert.Equal("1.1.0", module.Version)
+
+	// The second request is served from the wrapped storage
+	_, err = storage.GetModule(ctx, "tier", "vpc", "aws", "1.1.0")
+	assert.NoError(err)
+	assert.Equal(int32(1), atomic.LoadInt32(&downloads))
+
+	data, err := io.ReadAll(inner.(*InmemStorage).moduleData[inner.(*InmemStorage).moduleID("tier", "vpc", "aws", "1.1.0")])
+	assert.NoError(err)
+	assert.Equal([]string{"examples/simple.tf", "main.tf", "modules/subnet.tf"}, archiveFiles(t, data))
+
+	_, err = storage.GetModule(ctx, "tier", "vpc", "aws", "2.0.0")
+	assert.True(errors.Is(err, ErrNotFound))
+
+	// Other namespaces are not pulled through
+	_, err = storage.GetModule(ctx, "other", "vpc", "aws", "1.1.0")
+	assert.True(errors.Is(err, ErrNotFound))
+}
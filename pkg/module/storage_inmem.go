@@ -1,10 +1,14 @@
 package module
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -14,6 +18,12 @@ import (
 type InmemStorage struct {
 	modules       map[string]Module
 	moduleData    map[string]io.Reader
+	annotations   map[string][]Annotation
+	approvals     map[string]Approval
+	schedules     map[string]time.Time
+	labels        map[string]Labels
+	maturities    map[string][]VersionMaturity
+	docs          map[string]Docs
 	mu            sync.RWMutex
 	archiveFormat string
 }
@@ -31,6 +41,19 @@ func (s *InmemStorage) GetModule(ctx context.Context, namespace, name, provider,
 	return module, nil
 }
 
+// OpenModule opens the archive of a module in the in-memory storage.
+func (s *InmemStorage) OpenModule(ctx context.Context, namespace, name, provider, version string) (io.ReadCloser, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, ok := s.moduleData[s.moduleID(namespace, name, provider, version)].(*bytes.Reader)
+	if !ok {
+		return nil, errors.Wrap(ErrNotFound, "id")
+	}
+
+	return ioutil.NopCloser(io.NewSectionReader(data, 0, data.Size())), nil
+}
+
 func (s *InmemStorage) ListModuleVersions(ctx context.Context, namespace, name, provider string) ([]Module, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -45,12 +68,49 @@ func (s *InmemStorage) ListModuleVersions(ctx context.Context, namespace, name,
 	}
 
 	if len(modules) == 0 {
-		return nil, errors.Errorf("no modules found for namespace=%s name=%s provider=%s", namespace, name, provider)
+		return nil, errors.Wrapf(ErrNotFound, "no modules found for namespace=%s name=%s provider=%s", namespace, name, provider)
 	}
 
 	return modules, nil
 }
 
+// ListModules lists all module versions of a namespace in the in-memory storage.
+func (s *InmemStorage) ListModules(ctx context.Context, namespace string) ([]Module, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var modules []Module
+
+	for _, module := range s.modules {
+		if module.Namespace == namespace {
+			module.DownloadURL = storagePath("inmem", namespace, module.Name, module.Provider, module.Version, s.archiveFormat)
+			modules = append(modules, module)
+		}
+	}
+
+	return modules, nil
+}
+
+// ListNamespaces lists the namespaces with modules in the in-memory storage.
+func (s *InmemStorage) ListNamespaces(ctx context.Context) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	var namespaces []string
+
+	for _, module := range s.modules {
+		if !seen[module.Namespace] {
+			seen[module.Namespace] = true
+			namespaces = append(namespaces, module.Namespace)
+		}
+	}
+
+	sort.Strings(namespaces)
+
+	return namespaces, nil
+}
+
 func (s *InmemStorage) UploadModule(ctx context.Context, namespace, name, provider, version string, body io.Reader) (Module, error) {
 	if namespace == "" {
 		return Module{}, errors.New("namespace not defined")
@@ -68,10 +128,18 @@ func (s *InmemStorage) UploadModule(ctx context.Context, namespace, name, provid
 		return Module{}, errors.New("version not defined")
 	}
 
+	// The storage holds the archives in memory anyway
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return Module{}, archiveError(err)
+	}
+	digest := ArchiveDigest(data)
+
 	s.mu.Lock()
 
 	id := s.moduleID(namespace, name, provider, version)
 	if _, ok := s.modules[id]; ok {
+		s.mu.Unlock()
 		return Module{}, errors.Wrap(ErrAlreadyExists, "id")
 	}
 
@@ -80,14 +148,178 @@ func (s *InmemStorage) UploadModule(ctx context.Context, namespace, name, provid
 		Name:      name,
 		Provider:  provider,
 		Version:   version,
+		Digest:    digest,
+		Created:   time.Now(),
 	}
 
-	s.moduleData[id] = body
+	s.moduleData[id] = bytes.NewReader(data)
 	s.mu.Unlock()
 
 	return s.GetModule(ctx, namespace, name, provider, version)
 }
 
+// DeleteModule removes a module from the in-memory storage.
+func (s *InmemStorage) DeleteModule(ctx context.Context, namespace, name, provider, version string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := s.moduleID(namespace, name, provider, version)
+	if _, ok := s.modules[id]; !ok {
+		return errors.Wrap(ErrNotFound, "id")
+	}
+
+	delete(s.modules, id)
+	delete(s.moduleData, id)
+	delete(s.annotations, id)
+	delete(s.approvals, id)
+	delete(s.schedules, id)
+	delete(s.docs, docsPath("", namespace, name, provider, version))
+
+	prefix := maturityPrefix("", namespace, name, provider)
+	maturities := s.maturities[prefix][:0]
+	for _, maturity := range s.maturities[prefix] {
+		if maturity.Version != version {
+			maturities = append(maturities, maturity)
+		}
+	}
+	s.maturities[prefix] = maturities
+
+	return nil
+}
+
+// AddAnnotation stores an annotation of a module version in the in-memory storage.
+func (s *InmemStorage) AddAnnotation(ctx context.Context, namespace, name, provider, version string, annotation Annotation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := s.moduleID(namespace, name, provider, version)
+	s.annotations[id] = append(s.annotations[id], annotation)
+
+	return nil
+}
+
+// ListAnnotations lists the annotations of a module version in the order they were added.
+func (s *InmemStorage) ListAnnotations(ctx context.Context, namespace, name, provider, version string) ([]Annotation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	annotations := []Annotation{}
+	annotations = append(annotations, s.annotations[s.moduleID(namespace, name, provider, version)]...)
+
+	return annotations, nil
+}
+
+// ApproveModule stores the approval of a module version in the in-memory storage.
+func (s *InmemStorage) ApproveModule(ctx context.Context, namespace, name, provider, version string, approval Approval) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.approvals[s.moduleID(namespace, name, provider, version)] = approval
+
+	return nil
+}
+
+// ListApprovals lists the approvals of all versions of a module.
+func (s *InmemStorage) ListApprovals(ctx context.Context, namespace, name, provider string) ([]Approval, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	approvals := []Approval{}
+	for id, approval := range s.approvals {
+		if id == s.moduleID(namespace, name, provider, approval.Version) {
+			approvals = append(approvals, approval)
+		}
+	}
+
+	return approvals, nil
+}
+
+// ScheduleModule stores the publication time of a module version in the in-memory storage.
+func (s *InmemStorage) ScheduleModule(ctx context.Context, namespace, name, provider, version string, publishAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.schedules[s.moduleID(namespace, name, provider, version)] = publishAt
+
+	return nil
+}
+
+// ListSchedules lists the schedules of all versions of a module.
+func (s *InmemStorage) ListSchedules(ctx context.Context, namespace, name, provider string) ([]Schedule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	schedules := []Schedule{}
+	for id, publishAt := range s.schedules {
+		metadata := objectMetadata(id)
+		if id == s.moduleID(namespace, name, provider, metadata["version"]) {
+			schedules = append(schedules, Schedule{Version: metadata["version"], PublishAt: publishAt})
+		}
+	}
+
+	return schedules, nil
+}
+
+// SetLabels replaces the labels of a module in the in-memory storage.
+func (s *InmemStorage) SetLabels(ctx context.Context, namespace, name, provider string, labels Labels) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.labels[labelPath("", namespace, name, provider)] = copyLabels(labels)
+
+	return nil
+}
+
+// GetLabels returns the labels of a module, which are empty if none were set.
+func (s *InmemStorage) GetLabels(ctx context.Context, namespace, name, provider string) (Labels, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return copyLabels(s.labels[labelPath("", namespace, name, provider)]), nil
+}
+
+// SetMaturity stores the maturity of a module version in the in-memory storage.
+func (s *InmemStorage) SetMaturity(ctx context.Context, namespace, name, provider string, maturity VersionMaturity) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prefix := maturityPrefix("", namespace, name, provider)
+	s.maturities[prefix] = append(s.maturities[prefix], maturity)
+
+	return nil
+}
+
+// ListMaturities lists the latest maturity of all versions of a module.
+func (s *InmemStorage) ListMaturities(ctx context.Context, namespace, name, provider string) ([]VersionMaturity, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return latestMaturities(s.maturities[maturityPrefix("", namespace, name, provider)]), nil
+}
+
+// SetDocs stores the documentation of a module version in the in-memory storage.
+func (s *InmemStorage) SetDocs(ctx context.Context, namespace, name, provider, version string, docs Docs) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.docs[docsPath("", namespace, name, provider, version)] = copyDocs(docs)
+
+	return nil
+}
+
+// GetDocs returns the documentation of a module version.
+func (s *InmemStorage) GetDocs(ctx context.Context, namespace, name, provider, version string) (Docs, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	docs, ok := s.docs[docsPath("", namespace, name, provider, version)]
+	if !ok {
+		return Docs{}, errors.Wrap(ErrNotFound, "docs")
+	}
+
+	return copyDocs(docs), nil
+}
+
 func (s *InmemStorage) moduleID(namespace, name, provider, version string) string {
 	return fmt.Sprintf("namespace=%s/name=%s/provider=%s/version=%s/format=%s", namespace, name, provider, version, s.archiveFormat)
 }
@@ -107,6 +339,12 @@ func NewInmemStorage(options ...InmemStorageOption) Storage {
 	s := &InmemStorage{
 		modules:       make(map[string]Module),
 		moduleData:    make(map[string]io.Reader),
+		annotations:   make(map[string][]Annotation),
+		approvals:     make(map[string]Approval),
+		schedules:     make(map[string]time.Time),
+		labels:        make(map[string]Labels),
+		maturities:    make(map[string][]VersionMaturity),
+		docs:          make(map[string]Docs),
 		archiveFormat: DefaultArchiveFormat,
 	}
 
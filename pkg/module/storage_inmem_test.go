@@ -0,0 +1,32 @@
+package module
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInmemStorage_DeleteModule(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	var (
+		ctx     = context.Background()
+		storage = NewInmemStorage()
+	)
+
+	_, err := storage.UploadModule(ctx, "tier", "s3", "aws", "1.0.0", testModuleData(map[string]string{
+		"main.tf": `name = "foo"`,
+	}))
+	assert.NoError(err)
+
+	assert.NoError(storage.DeleteModule(ctx, "tier", "s3", "aws", "1.0.0"))
+
+	_, err = storage.GetModule(ctx, "tier", "s3", "aws", "1.0.0")
+	assert.True(errors.Is(err, ErrNotFound))
+
+	err = storage.DeleteModule(ctx, "tier", "s3", "aws", "1.0.0")
+	assert.True(errors.Is(err, ErrNotFound))
+}
@@ -0,0 +1,592 @@
+package module
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// LocalStorage is a Storage implementation backed by a directory on the local disk.
+// Module archives are stored once per content under blobs/sha256/<hex>.<format>,
+// while each module version is a small reference file holding the digest of its archive.
+type LocalStorage struct {
+	dir           string
+	baseURL       string
+	archiveFormat string
+}
+
+// GetModule retrieves information about a module from the local storage.
+func (s *LocalStorage) GetModule(ctx context.Context, namespace, name, provider, version string) (Module, error) {
+	key := storagePath("", namespace, name, provider, version, s.archiveFormat)
+
+	module, err := s.module(key)
+	if err != nil {
+		if os.IsNotExist(errors.Cause(err)) {
+			return Module{}, errors.Wrap(ErrNotFound, key)
+		}
+		return Module{}, wrapStorageError(ErrGetFailed, err)
+	}
+
+	return module, nil
+}
+
+// OpenModule opens the archive of a module in the local storage.
+func (s *LocalStorage) OpenModule(ctx context.Context, namespace, name, provider, version string) (io.ReadCloser, error) {
+	module, err := s.GetModule(ctx, namespace, name, provider, version)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(s.path(s.blobPath(module.Digest)))
+	if err != nil {
+		return nil, wrapStorageError(ErrGetFailed, err)
+	}
+
+	return f, nil
+}
+
+func (s *LocalStorage) ListModuleVersions(ctx context.Context, namespace, name, provider string) ([]Module, error) {
+	modules, err := s.list(storagePrefix("", namespace, name, provider))
+	if err != nil {
+		return nil, wrapStorageError(ErrListFailed, err)
+	}
+
+	return modules, nil
+}
+
+// ListModules lists all module versions of a namespace.
+func (s *LocalStorage) ListModules(ctx context.Context, namespace string) ([]Module, error) {
+	modules, err := s.list(namespacePrefix("", namespace))
+	if err != nil {
+		return nil, wrapStorageError(ErrListFailed, err)
+	}
+
+	return modules, nil
+}
+
+// ListNamespaces lists the namespaces with modules.
+func (s *LocalStorage) ListNamespaces(ctx context.Context) ([]string, error) {
+	entries, err := os.ReadDir(s.path(""))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, wrapStorageError(ErrListFailed, err)
+	}
+
+	var namespaces []string
+	for _, entry := range entries {
+		if namespace := objectMetadata(entry.Name())["namespace"]; entry.IsDir() && namespace != "" {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+
+	return namespaces, nil
+}
+
+func (s *LocalStorage) UploadModule(ctx context.Context, namespace, name, provider, version string, body io.Reader) (Module, error) {
+	if namespace == "" {
+		return Module{}, errors.New("namespace not defined")
+	}
+
+	if name == "" {
+		return Module{}, errors.New("name not defined")
+	}
+
+	if provider == "" {
+		return Module{}, errors.New("provider not defined")
+	}
+
+	if version == "" {
+		return Module{}, errors.New("version not defined")
+	}
+
+	key := storagePath("", namespace, name, provider, version, s.archiveFormat)
+
+	if _, err := s.GetModule(ctx, namespace, name, provider, version); err == nil {
+		return Module{}, errors.Wrap(ErrAlreadyExists, key)
+	}
+
+	// The archive is written next to the blobs while it's hashed, as the digest names its blob
+	tmp, digest, err := s.writeArchive(body)
+	if err != nil {
+		return Module{}, err
+	}
+	defer os.Remove(tmp)
+
+	// Identical archives share a blob, so it's only moved into place if it doesn't exist yet
+	blob := s.blobPath(digest)
+	if _, err := os.Stat(s.path(blob)); os.IsNotExist(err) {
+		if err := os.Rename(tmp, s.path(blob)); err != nil {
+			return Module{}, wrapStorageError(ErrUploadFailed, err)
+		}
+	}
+
+	// The check above only fails early, creating the reference keeps concurrent uploads from overwriting each other
+	if err := s.create(key, []byte(digest)); err != nil {
+		return Module{}, wrapStorageError(ErrUploadFailed, err)
+	}
+
+	return s.GetModule(ctx, namespace, name, provider, version)
+}
+
+// DeleteModule removes a module from the local storage.
+// Only the reference is removed, as the blob of its archive might be shared with other module versions.
+func (s *LocalStorage) DeleteModule(ctx context.Context, namespace, name, provider, version string) error {
+	if _, err := s.GetModule(ctx, namespace, name, provider, version); err != nil {
+		return err
+	}
+
+	// The metadata is deleted first, so a failed deletion can be retried as long as the version exists
+	keys, prefixes := versionMetadata("", namespace, name, provider, version)
+	for _, key := range keys {
+		if err := os.Remove(s.path(key)); err != nil && !os.IsNotExist(err) {
+			return wrapStorageError(ErrDeleteFailed, err)
+		}
+	}
+	for _, prefix := range prefixes {
+		if err := os.RemoveAll(s.path(prefix)); err != nil {
+			return wrapStorageError(ErrDeleteFailed, err)
+		}
+	}
+
+	if err := os.Remove(s.path(storagePath("", namespace, name, provider, version, s.archiveFormat))); err != nil {
+		return wrapStorageError(ErrDeleteFailed, err)
+	}
+
+	return nil
+}
+
+// AddAnnotation stores an annotation of a module version in the local storage.
+func (s *LocalStorage) AddAnnotation(ctx context.Context, namespace, name, provider, version string, annotation Annotation) error {
+	b, err := json.Marshal(annotation)
+	if err != nil {
+		return err
+	}
+
+	if err := s.write(annotationPath("", namespace, name, provider, version, annotation), b); err != nil {
+		return wrapStorageError(ErrAnnotationFailed, err)
+	}
+
+	return nil
+}
+
+// ListAnnotations lists the annotations of a module version in the order they were added.
+func (s *LocalStorage) ListAnnotations(ctx context.Context, namespace, name, provider, version string) ([]Annotation, error) {
+	keys, err := s.keys(annotationPrefix("", namespace, name, provider, version))
+	if err != nil {
+		return nil, wrapStorageError(ErrListFailed, err)
+	}
+
+	annotations := []Annotation{}
+	for _, key := range keys {
+		b, err := ioutil.ReadFile(s.path(key))
+		if err != nil {
+			return nil, wrapStorageError(ErrListFailed, err)
+		}
+
+		var annotation Annotation
+		if err := json.Unmarshal(b, &annotation); err != nil {
+			return nil, errors.Wrapf(err, "failed to decode annotation %s", key)
+		}
+
+		annotations = append(annotations, annotation)
+	}
+
+	return annotations, nil
+}
+
+// ApproveModule stores the approval of a module version in the local storage.
+func (s *LocalStorage) ApproveModule(ctx context.Context, namespace, name, provider, version string, approval Approval) error {
+	b, err := json.Marshal(approval)
+	if err != nil {
+		return err
+	}
+
+	if err := s.write(approvalPath("", namespace, name, provider, version), b); err != nil {
+		return wrapStorageError(ErrApprovalFailed, err)
+	}
+
+	return nil
+}
+
+// ListApprovals lists the approvals of all versions of a module.
+// Only the version and approval time are set, as the approvals aren't read.
+func (s *LocalStorage) ListApprovals(ctx context.Context, namespace, name, provider string) ([]Approval, error) {
+	keys, err := s.keys(approvalPrefix("", namespace, name, provider))
+	if err != nil {
+		return nil, wrapStorageError(ErrListFailed, err)
+	}
+
+	approvals := []Approval{}
+	for _, key := range keys {
+		info, err := os.Stat(s.path(key))
+		if err != nil {
+			return nil, wrapStorageError(ErrListFailed, err)
+		}
+
+		approvals = append(approvals, Approval{
+			Version:    approvedVersion(key),
+			ApprovedAt: info.ModTime(),
+		})
+	}
+
+	return approvals, nil
+}
+
+// ScheduleModule stores the publication time of a module version in the local storage.
+func (s *LocalStorage) ScheduleModule(ctx context.Context, namespace, name, provider, version string, publishAt time.Time) error {
+	if err := s.write(schedulePath("", namespace, name, provider, version, publishAt), nil); err != nil {
+		return wrapStorageError(ErrScheduleFailed, err)
+	}
+
+	return nil
+}
+
+// ListSchedules lists the schedules of all versions of a module.
+func (s *LocalStorage) ListSchedules(ctx context.Context, namespace, name, provider string) ([]Schedule, error) {
+	keys, err := s.keys(schedulePrefix("", namespace, name, provider))
+	if err != nil {
+		return nil, wrapStorageError(ErrListFailed, err)
+	}
+
+	schedules := []Schedule{}
+	for _, key := range keys {
+		schedule, err := parseSchedulePath(key)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, schedule)
+	}
+
+	return schedules, nil
+}
+
+// SetLabels replaces the labels of a module in the local storage.
+func (s *LocalStorage) SetLabels(ctx context.Context, namespace, name, provider string, labels Labels) error {
+	b, err := json.Marshal(labels)
+	if err != nil {
+		return err
+	}
+
+	if err := s.write(labelPath("", namespace, name, provider), b); err != nil {
+		return wrapStorageError(ErrLabelFailed, err)
+	}
+
+	return nil
+}
+
+// GetLabels returns the labels of a module, which are empty if none were set.
+func (s *LocalStorage) GetLabels(ctx context.Context, namespace, name, provider string) (Labels, error) {
+	key := labelPath("", namespace, name, provider)
+
+	b, err := ioutil.ReadFile(s.path(key))
+	if os.IsNotExist(err) {
+		return Labels{}, nil
+	} else if err != nil {
+		return nil, wrapStorageError(ErrGetFailed, err)
+	}
+
+	labels := Labels{}
+	if err := json.Unmarshal(b, &labels); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode labels %s", key)
+	}
+
+	return labels, nil
+}
+
+// SetMaturity stores the maturity of a module version in the local storage.
+func (s *LocalStorage) SetMaturity(ctx context.Context, namespace, name, provider string, maturity VersionMaturity) error {
+	if err := s.write(maturityPath("", namespace, name, provider, maturity), nil); err != nil {
+		return wrapStorageError(ErrMaturityFailed, err)
+	}
+
+	return nil
+}
+
+// ListMaturities lists the latest maturity of all versions of a module.
+func (s *LocalStorage) ListMaturities(ctx context.Context, namespace, name, provider string) ([]VersionMaturity, error) {
+	keys, err := s.keys(maturityPrefix("", namespace, name, provider))
+	if err != nil {
+		return nil, wrapStorageError(ErrListFailed, err)
+	}
+
+	var maturities []VersionMaturity
+	for _, key := range keys {
+		maturity, err := parseMaturityPath(key)
+		if err != nil {
+			return nil, err
+		}
+		maturities = append(maturities, maturity)
+	}
+
+	return latestMaturities(maturities), nil
+}
+
+// SetDocs stores the documentation of a module version in the local storage.
+func (s *LocalStorage) SetDocs(ctx context.Context, namespace, name, provider, version string, docs Docs) error {
+	b, err := json.Marshal(docs)
+	if err != nil {
+		return err
+	}
+
+	if err := s.write(docsPath("", namespace, name, provider, version), b); err != nil {
+		return wrapStorageError(ErrDocsFailed, err)
+	}
+
+	return nil
+}
+
+// GetDocs returns the documentation of a module version.
+func (s *LocalStorage) GetDocs(ctx context.Context, namespace, name, provider, version string) (Docs, error) {
+	key := docsPath("", namespace, name, provider, version)
+
+	b, err := ioutil.ReadFile(s.path(key))
+	if err != nil {
+		return Docs{}, wrapStorageError(ErrGetFailed, err)
+	}
+
+	var docs Docs
+	if err := json.Unmarshal(b, &docs); err != nil {
+		return Docs{}, errors.Wrapf(err, "failed to decode docs %s", key)
+	}
+
+	return docs, nil
+}
+
+// module reads the reference file of a module version.
+func (s *LocalStorage) module(key string) (Module, error) {
+	info, err := os.Stat(s.path(key))
+	if err != nil {
+		return Module{}, errors.WithStack(err)
+	}
+
+	b, err := ioutil.ReadFile(s.path(key))
+	if err != nil {
+		return Module{}, errors.WithStack(err)
+	}
+	digest := strings.TrimSpace(string(b))
+
+	metadata := objectMetadata(key)
+
+	return Module{
+		Namespace:   metadata["namespace"],
+		Name:        metadata["name"],
+		Provider:    metadata["provider"],
+		Version:     metadata["version"],
+		DownloadURL: s.baseURL + "/" + s.blobPath(digest),
+		Digest:      digest,
+		Created:     info.ModTime(),
+	}, nil
+}
+
+// list returns all module versions below a prefix.
+func (s *LocalStorage) list(prefix string) ([]Module, error) {
+	keys, err := s.keys(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var modules []Module
+	for _, key := range keys {
+		if !strings.HasSuffix(key, "."+s.archiveFormat) {
+			continue
+		}
+
+		module, err := s.module(key)
+		if err != nil {
+			return nil, err
+		}
+
+		if module.Name == "" || module.Provider == "" || module.Version == "" {
+			continue
+		}
+
+		modules = append(modules, module)
+	}
+
+	return modules, nil
+}
+
+// keys returns the sorted keys of all files below a prefix, a missing prefix has no keys.
+func (s *LocalStorage) keys(prefix string) ([]string, error) {
+	var keys []string
+
+	err := filepath.WalkDir(s.path(prefix), func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+
+		// Temporary files of unfinished writes start with a dot
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.dir, p)
+		if err != nil {
+			return err
+		}
+
+		keys = append(keys, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// write atomically writes a file by renaming a temporary file, so readers never see a partial file.
+func (s *LocalStorage) write(key string, data []byte) error {
+	tmp, err := s.writeTemp(key, data)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	return os.Rename(tmp, s.path(key))
+}
+
+// create writes a file like write, but fails with os.ErrExist instead of replacing an existing file.
+func (s *LocalStorage) create(key string, data []byte) error {
+	tmp, err := s.writeTemp(key, data)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	// Unlike renaming, linking doesn't replace the file of a concurrent upload
+	return os.Link(tmp, s.path(key))
+}
+
+// writeTemp writes data to a temporary file next to the file of a key, so it can be moved into place atomically.
+func (s *LocalStorage) writeTemp(key string, data []byte) (string, error) {
+	p := s.path(key)
+
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return "", err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(p), ".tmp-")
+	if err != nil {
+		return "", err
+	}
+
+	if err := writeSync(f, data); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+
+	if err := os.Chmod(f.Name(), 0o644); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+
+	return f.Name(), nil
+}
+
+// writeArchive streams an archive to a temporary file in the blobs directory and returns its path and digest.
+func (s *LocalStorage) writeArchive(body io.Reader) (string, string, error) {
+	dir := filepath.Dir(s.path(s.blobPath("")))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", "", wrapStorageError(ErrUploadFailed, err)
+	}
+
+	f, err := ioutil.TempFile(dir, ".tmp-")
+	if err != nil {
+		return "", "", wrapStorageError(ErrUploadFailed, err)
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, hash), body); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", "", archiveError(err)
+	}
+
+	if err := writeSync(f, nil); err != nil {
+		os.Remove(f.Name())
+		return "", "", wrapStorageError(ErrUploadFailed, err)
+	}
+
+	if err := os.Chmod(f.Name(), 0o644); err != nil {
+		os.Remove(f.Name())
+		return "", "", wrapStorageError(ErrUploadFailed, err)
+	}
+
+	return f.Name(), hashDigest(hash), nil
+}
+
+// writeSync writes data to a file and closes it once it's flushed to disk.
+func writeSync(f *os.File, data []byte) error {
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// path returns the path of a key on the local disk.
+func (s *LocalStorage) path(key string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(key))
+}
+
+// blobPath returns the key of the archive with the given digest.
+func (s *LocalStorage) blobPath(digest string) string {
+	return path.Join("blobs", "sha256", strings.TrimPrefix(digest, "sha256:")+"."+s.archiveFormat)
+}
+
+// LocalStorageOption provides additional options for the LocalStorage.
+type LocalStorageOption func(*LocalStorage)
+
+// WithLocalStorageBaseURL configures the URL the blobs directory is served under.
+// A relative URL like /v1/files/modules is resolved by Terraform against the registry host.
+func WithLocalStorageBaseURL(baseURL string) LocalStorageOption {
+	return func(s *LocalStorage) {
+		s.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// WithLocalArchiveFormat configures the module archive format (zip, tar, tgz, etc.)
+func WithLocalArchiveFormat(archiveFormat string) LocalStorageOption {
+	return func(s *LocalStorage) {
+		s.archiveFormat = archiveFormat
+	}
+}
+
+// NewLocalStorage returns a fully initialized local storage, creating its directory if needed.
+func NewLocalStorage(dir string, options ...LocalStorageOption) (Storage, error) {
+	s := &LocalStorage{
+		dir:           dir,
+		archiveFormat: DefaultArchiveFormat,
+	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, errors.Wrap(err, "failed to create storage directory")
+	}
+
+	return s, nil
+}
@@ -0,0 +1,179 @@
+package module
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalStorage_UploadModule(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	var (
+		ctx = context.Background()
+		dir = t.TempDir()
+	)
+
+	storage, err := NewLocalStorage(dir, WithLocalStorageBaseURL("/v1/files/modules/"))
+	assert.NoError(err)
+
+	data := func() []byte {
+		b, err := ioutil.ReadAll(testModuleData(map[string]string{
+			"main.tf": `name = "foo"`,
+		}))
+		assert.NoError(err)
+		return b
+	}()
+
+	first, err := storage.UploadModule(ctx, "tier", "s3", "aws", "1.0.0", strings.NewReader(string(data)))
+	assert.NoError(err)
+	assert.Equal(ArchiveDigest(data), first.Digest)
+	assert.Equal("/v1/files/modules/blobs/sha256/"+strings.TrimPrefix(first.Digest, "sha256:")+".tar.gz", first.DownloadURL)
+
+	blob, err := ioutil.ReadFile(filepath.Join(dir, "blobs", "sha256", strings.TrimPrefix(first.Digest, "sha256:")+".tar.gz"))
+	assert.NoError(err)
+	assert.Equal(data, blob)
+
+	_, err = storage.UploadModule(ctx, "tier", "s3", "aws", "1.0.0", strings.NewReader(string(data)))
+	assert.True(errors.Is(err, ErrAlreadyExists))
+
+	// An identical archive shares the blob of the first version
+	second, err := storage.UploadModule(ctx, "tier", "s3", "aws", "1.1.0", strings.NewReader(string(data)))
+	assert.NoError(err)
+	assert.Equal(first.DownloadURL, second.DownloadURL)
+
+	versions, err := storage.ListModuleVersions(ctx, "tier", "s3", "aws")
+	assert.NoError(err)
+	assert.Len(versions, 2)
+
+	modules, err := storage.ListModules(ctx, "tier")
+	assert.NoError(err)
+	assert.Len(modules, 2)
+
+	modules, err = storage.ListModules(ctx, "other")
+	assert.NoError(err)
+	assert.Empty(modules)
+
+	// Blobs aren't a namespace
+	namespaces, err := storage.ListNamespaces(ctx)
+	assert.NoError(err)
+	assert.Equal([]string{"tier"}, namespaces)
+}
+
+func TestLocalStorage_Create(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	storage, err := NewLocalStorage(t.TempDir())
+	assert.NoError(err)
+	s := storage.(*LocalStorage)
+
+	// A concurrent upload which passed the existence check as well can't replace the reference of the first one
+	key := storagePath("", "tier", "s3", "aws", "1.0.0", s.archiveFormat)
+	assert.NoError(s.create(key, []byte("sha256:first")))
+	err = s.create(key, []byte("sha256:second"))
+	assert.True(errors.Is(err, os.ErrExist))
+
+	b, err := ioutil.ReadFile(s.path(key))
+	assert.NoError(err)
+	assert.Equal("sha256:first", string(b))
+
+	// Temporary files are removed either way
+	files, err := ioutil.ReadDir(filepath.Dir(s.path(key)))
+	assert.NoError(err)
+	assert.Len(files, 1)
+}
+
+func TestLocalStorage_DeleteModule(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	ctx := context.Background()
+
+	storage, err := NewLocalStorage(t.TempDir())
+	assert.NoError(err)
+
+	_, err = storage.UploadModule(ctx, "tier", "s3", "aws", "1.0.0", testModuleData(map[string]string{
+		"main.tf": `name = "foo"`,
+	}))
+	assert.NoError(err)
+
+	assert.NoError(storage.DeleteModule(ctx, "tier", "s3", "aws", "1.0.0"))
+
+	_, err = storage.GetModule(ctx, "tier", "s3", "aws", "1.0.0")
+	assert.True(errors.Is(err, ErrNotFound))
+
+	err = storage.DeleteModule(ctx, "tier", "s3", "aws", "1.0.0")
+	assert.True(errors.Is(err, ErrNotFound))
+}
+
+func TestLocalStorage_Metadata(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	var (
+		ctx       = context.Background()
+		createdAt = time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+		publishAt = time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC)
+	)
+
+	storage, err := NewLocalStorage(t.TempDir())
+	assert.NoError(err)
+
+	_, err = storage.UploadModule(ctx, "tier", "s3", "aws", "1.0.0", testModuleData(map[string]string{
+		"main.tf": `name = "foo"`,
+	}))
+	assert.NoError(err)
+
+	assert.NoError(storage.AddAnnotation(ctx, "tier", "s3", "aws", "1.0.0", Annotation{Text: "ci passed", Author: "ci", CreatedAt: createdAt}))
+	assert.NoError(storage.ApproveModule(ctx, "tier", "s3", "aws", "1.0.0", Approval{Version: "1.0.0", Approver: "jane"}))
+	assert.NoError(storage.ScheduleModule(ctx, "tier", "s3", "aws", "1.0.0", publishAt))
+
+	annotations, err := storage.ListAnnotations(ctx, "tier", "s3", "aws", "1.0.0")
+	assert.NoError(err)
+	assert.Equal([]Annotation{{Text: "ci passed", Author: "ci", CreatedAt: createdAt}}, annotations)
+
+	approvals, err := storage.ListApprovals(ctx, "tier", "s3", "aws")
+	assert.NoError(err)
+	if assert.Len(approvals, 1) {
+		assert.Equal("1.0.0", approvals[0].Version)
+	}
+
+	schedules, err := storage.ListSchedules(ctx, "tier", "s3", "aws")
+	assert.NoError(err)
+	assert.Equal([]Schedule{{Version: "1.0.0", PublishAt: publishAt}}, schedules)
+
+	labels, err := storage.GetLabels(ctx, "tier", "s3", "aws")
+	assert.NoError(err)
+	assert.Equal(Labels{}, labels)
+
+	assert.NoError(storage.SetLabels(ctx, "tier", "s3", "aws", Labels{"tier": "storage"}))
+	labels, err = storage.GetLabels(ctx, "tier", "s3", "aws")
+	assert.NoError(err)
+	assert.Equal(Labels{"tier": "storage"}, labels)
+
+	assert.NoError(storage.SetMaturity(ctx, "tier", "s3", "aws", VersionMaturity{Version: "1.0.0", Maturity: MaturityBeta, UpdatedAt: createdAt}))
+	assert.NoError(storage.SetMaturity(ctx, "tier", "s3", "aws", VersionMaturity{Version: "1.0.0", Maturity: MaturityStable, UpdatedAt: publishAt}))
+	maturities, err := storage.ListMaturities(ctx, "tier", "s3", "aws")
+	assert.NoError(err)
+	assert.Equal([]VersionMaturity{{Version: "1.0.0", Maturity: MaturityStable, UpdatedAt: publishAt}}, maturities)
+
+	deprecated := VersionMaturity{Version: "1.0.0", Maturity: MaturityDeprecated, Reason: "use tier/s3/aws 2.x", UpdatedAt: publishAt.Add(time.Hour)}
+	assert.NoError(storage.SetMaturity(ctx, "tier", "s3", "aws", deprecated))
+	maturities, err = storage.ListMaturities(ctx, "tier", "s3", "aws")
+	assert.NoError(err)
+	assert.Equal([]VersionMaturity{deprecated}, maturities)
+
+	// Metadata is kept apart from the module archives
+	versions, err := storage.ListModuleVersions(ctx, "tier", "s3", "aws")
+	assert.NoError(err)
+	assert.Len(versions, 1)
+}
@@ -0,0 +1,217 @@
+package module
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ReplicationPolicy decides the outcome of writes which failed to replicate to the secondary storage.
+type ReplicationPolicy string
+
+const (
+	// ReplicationPolicyFail fails the write, even though the primary storage has it already.
+	ReplicationPolicyFail ReplicationPolicy = "fail"
+	// ReplicationPolicyIgnore only reports the failed replication, the write succeeds.
+	ReplicationPolicyIgnore ReplicationPolicy = "ignore"
+)
+
+// ReplicationFailure is a write of the primary storage which failed to replicate to the secondary storage.
+type ReplicationFailure struct {
+	Operation string
+	Namespace string
+	Name      string
+	Provider  string
+	Version   string
+	Err       error
+}
+
+// replicatingStorage is a Storage wrapper that replicates all writes of the primary storage to a secondary storage,
+// while reads are served by the primary storage only.
+type replicatingStorage struct {
+	Storage
+	secondary Storage
+	policy    ReplicationPolicy
+	report    func(ReplicationFailure)
+}
+
+// ReplicationOption provides additional options for the replicating storage.
+type ReplicationOption func(*replicatingStorage)
+
+// WithReplicationPolicy configures the outcome of writes which failed to replicate, ReplicationPolicyFail by default.
+func WithReplicationPolicy(policy ReplicationPolicy) ReplicationOption {
+	return func(s *replicatingStorage) {
+		s.policy = policy
+	}
+}
+
+// WithReplicationReport configures the function failed replications are reported to, regardless of the policy.
+func WithReplicationReport(report func(ReplicationFailure)) ReplicationOption {
+	return func(s *replicatingStorage) {
+		s.report = report
+	}
+}
+
+// NewReplicatingStorage returns a storage that writes to the primary storage first and then synchronously to the
+// secondary storage, so no publish is lost while moving the registry to another storage backend.
+// Modules which exist already in the secondary storage, e.g. because they were copied before, count as replicated.
+func NewReplicatingStorage(primary, secondary Storage, options ...ReplicationOption) Storage {
+	s := &replicatingStorage{
+		Storage:   primary,
+		secondary: secondary,
+		policy:    ReplicationPolicyFail,
+		report:    func(ReplicationFailure) {},
+	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	return s
+}
+
+func (s *replicatingStorage) UploadModule(ctx context.Context, namespace, name, provider, version string, body io.Reader) (Module, error) {
+	// The body is written to both storages, so it is spooled to a temporary file
+	archive, _, err := spoolArchive(body)
+	if err != nil {
+		return Module{}, archiveError(err)
+	}
+	defer archive.Close()
+
+	module, err := s.Storage.UploadModule(ctx, namespace, name, provider, version, archive)
+	if errors.Cause(err) == ErrAlreadyExists {
+		return module, s.repair(ctx, namespace, name, provider, version, err)
+	}
+	if err != nil {
+		return module, err
+	}
+
+	if _, err := archive.Seek(0, io.SeekStart); err != nil {
+		return module, s.replicated("upload", namespace, name, provider, version, err)
+	}
+
+	_, err = s.secondary.UploadModule(ctx, namespace, name, provider, version, archive)
+	if errors.Cause(err) == ErrAlreadyExists {
+		err = nil
+	}
+
+	return module, s.replicated("upload", namespace, name, provider, version, err)
+}
+
+func (s *replicatingStorage) DeleteModule(ctx context.Context, namespace, name, provider, version string) error {
+	if err := s.Storage.DeleteModule(ctx, namespace, name, provider, version); err != nil {
+		return err
+	}
+
+	err := s.secondary.DeleteModule(ctx, namespace, name, provider, version)
+	if errors.Cause(err) == ErrNotFound {
+		err = nil
+	}
+
+	return s.replicated("delete", namespace, name, provider, version, err)
+}
+
+func (s *replicatingStorage) AddAnnotation(ctx context.Context, namespace, name, provider, version string, annotation Annotation) error {
+	if err := s.Storage.AddAnnotation(ctx, namespace, name, provider, version, annotation); err != nil {
+		return err
+	}
+
+	err := s.secondary.AddAnnotation(ctx, namespace, name, provider, version, annotation)
+	return s.replicated("annotate", namespace, name, provider, version, err)
+}
+
+func (s *replicatingStorage) ApproveModule(ctx context.Context, namespace, name, provider, version string, approval Approval) error {
+	if err := s.Storage.ApproveModule(ctx, namespace, name, provider, version, approval); err != nil {
+		return err
+	}
+
+	err := s.secondary.ApproveModule(ctx, namespace, name, provider, version, approval)
+	return s.replicated("approve", namespace, name, provider, version, err)
+}
+
+func (s *replicatingStorage) ScheduleModule(ctx context.Context, namespace, name, provider, version string, publishAt time.Time) error {
+	if err := s.Storage.ScheduleModule(ctx, namespace, name, provider, version, publishAt); err != nil {
+		return err
+	}
+
+	err := s.secondary.ScheduleModule(ctx, namespace, name, provider, version, publishAt)
+	return s.replicated("schedule", namespace, name, provider, version, err)
+}
+
+func (s *replicatingStorage) SetLabels(ctx context.Context, namespace, name, provider string, labels Labels) error {
+	if err := s.Storage.SetLabels(ctx, namespace, name, provider, labels); err != nil {
+		return err
+	}
+
+	err := s.secondary.SetLabels(ctx, namespace, name, provider, labels)
+	return s.replicated("labels", namespace, name, provider, "", err)
+}
+
+func (s *replicatingStorage) SetMaturity(ctx context.Context, namespace, name, provider string, maturity VersionMaturity) error {
+	if err := s.Storage.SetMaturity(ctx, namespace, name, provider, maturity); err != nil {
+		return err
+	}
+
+	err := s.secondary.SetMaturity(ctx, namespace, name, provider, maturity)
+	return s.replicated("maturity", namespace, name, provider, maturity.Version, err)
+}
+
+func (s *replicatingStorage) SetDocs(ctx context.Context, namespace, name, provider, version string, docs Docs) error {
+	if err := s.Storage.SetDocs(ctx, namespace, name, provider, version, docs); err != nil {
+		return err
+	}
+
+	err := s.secondary.SetDocs(ctx, namespace, name, provider, version, docs)
+	return s.replicated("docs", namespace, name, provider, version, err)
+}
+
+// repair replicates a version the primary storage has already, but the secondary storage doesn't, e.g. because the
+// server crashed before replicating it, when the version is uploaded again. The archive of the primary storage is
+// replicated, as the uploaded one may differ. The upload fails with the error of the primary storage regardless.
+func (s *replicatingStorage) repair(ctx context.Context, namespace, name, provider, version string, uploadErr error) error {
+	if _, err := s.secondary.GetModule(ctx, namespace, name, provider, version); errors.Cause(err) != ErrNotFound {
+		return uploadErr
+	}
+
+	body, err := s.Storage.OpenModule(ctx, namespace, name, provider, version)
+	if err == nil {
+		_, err = s.secondary.UploadModule(ctx, namespace, name, provider, version, body)
+		body.Close()
+	}
+	if err != nil {
+		s.report(ReplicationFailure{
+			Operation: "upload",
+			Namespace: namespace,
+			Name:      name,
+			Provider:  provider,
+			Version:   version,
+			Err:       err,
+		})
+	}
+
+	return uploadErr
+}
+
+// replicated reports a failed replication and returns the error of the write according to the policy.
+func (s *replicatingStorage) replicated(operation, namespace, name, provider, version string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	s.report(ReplicationFailure{
+		Operation: operation,
+		Namespace: namespace,
+		Name:      name,
+		Provider:  provider,
+		Version:   version,
+		Err:       err,
+	})
+
+	if s.policy == ReplicationPolicyIgnore {
+		return nil
+	}
+
+	return errors.Wrap(err, "failed to replicate to secondary storage")
+}
@@ -0,0 +1,123 @@
+package module
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplicatingStorage(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name             string
+		secondary        func() Storage
+		policy           ReplicationPolicy
+		expectErr        bool
+		expectReplicated bool
+		expectFailures   int
+	}{
+		{
+			name:             "replicated",
+			secondary:        func() Storage { return NewInmemStorage() },
+			policy:           ReplicationPolicyFail,
+			expectReplicated: true,
+		},
+		{
+			name: "copied before",
+			secondary: func() Storage {
+				s := NewInmemStorage()
+				_, _ = s.UploadModule(context.Background(), "tier", "s3", "aws", "1.0.0", strings.NewReader("data"))
+				return s
+			},
+			policy:           ReplicationPolicyFail,
+			expectReplicated: true,
+		},
+		{
+			name:           "failing secondary",
+			secondary:      func() Storage { return NewChaosStorage(NewInmemStorage(), WithChaosErrorRate(1), WithChaosSeed(1)) },
+			policy:         ReplicationPolicyFail,
+			expectErr:      true,
+			expectFailures: 2,
+		},
+		{
+			name:           "ignored failing secondary",
+			secondary:      func() Storage { return NewChaosStorage(NewInmemStorage(), WithChaosErrorRate(1), WithChaosSeed(1)) },
+			policy:         ReplicationPolicyIgnore,
+			expectFailures: 2,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+
+			var (
+				ctx       = context.Background()
+				primary   = NewInmemStorage()
+				secondary = tc.secondary()
+				failures  []ReplicationFailure
+			)
+
+			storage := NewReplicatingStorage(primary, secondary,
+				WithReplicationPolicy(tc.policy),
+				WithReplicationReport(func(failure ReplicationFailure) {
+					failures = append(failures, failure)
+				}),
+			)
+
+			_, err := storage.UploadModule(ctx, "tier", "s3", "aws", "1.0.0", strings.NewReader("data"))
+			labelsErr := storage.SetLabels(ctx, "tier", "s3", "aws", Labels{"team": "platform"})
+			if tc.expectErr {
+				assert.Error(err)
+				assert.Error(labelsErr)
+			} else {
+				assert.NoError(err)
+				assert.NoError(labelsErr)
+			}
+
+			// The primary storage has all writes, regardless of the replication
+			_, err = primary.GetModule(ctx, "tier", "s3", "aws", "1.0.0")
+			assert.NoError(err)
+
+			if tc.expectReplicated {
+				_, err = secondary.GetModule(ctx, "tier", "s3", "aws", "1.0.0")
+				assert.NoError(err)
+
+				labels, err := secondary.GetLabels(ctx, "tier", "s3", "aws")
+				assert.NoError(err)
+				assert.Equal(Labels{"team": "platform"}, labels)
+			}
+
+			assert.Len(failures, tc.expectFailures)
+			if tc.expectFailures > 0 {
+				assert.Equal("upload", failures[0].Operation)
+				assert.Equal("1.0.0", failures[0].Version)
+				assert.True(errors.Is(failures[0].Err, errChaos))
+			}
+
+			// Uploading a version again repairs a missed replication with the archive of the primary storage
+			if !tc.expectReplicated {
+				repaired := NewInmemStorage()
+				_, err = NewReplicatingStorage(primary, repaired).UploadModule(ctx, "tier", "s3", "aws", "1.0.0", strings.NewReader("other"))
+				assert.Equal(ErrAlreadyExists, errors.Cause(err))
+
+				primaryModule, _ := primary.GetModule(ctx, "tier", "s3", "aws", "1.0.0")
+				secondaryModule, err := repaired.GetModule(ctx, "tier", "s3", "aws", "1.0.0")
+				assert.NoError(err)
+				assert.Equal(primaryModule.Digest, secondaryModule.Digest)
+			}
+
+			// Deleting replicates as well, versions missing in the secondary storage count as deleted
+			err = storage.DeleteModule(ctx, "tier", "s3", "aws", "1.0.0")
+			assert.Equal(tc.expectErr, err != nil)
+			_, err = primary.GetModule(ctx, "tier", "s3", "aws", "1.0.0")
+			assert.Equal(ErrNotFound, errors.Cause(err))
+		})
+	}
+}
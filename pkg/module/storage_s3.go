@@ -1,14 +1,31 @@
 package module
 
 import (
+	"bytes"
 	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
 	"github.com/pkg/errors"
 )
 
@@ -22,6 +39,17 @@ type S3Storage struct {
 	bucketRegion   string
 	pathStyle      bool
 	bucketEndpoint string
+	// endpoint is the custom endpoint of an S3-compatible storage, it's empty for AWS.
+	endpoint     string
+	storageClass string
+	// downloadURLTemplate renders the download URLs of modules, it defaults to S3 URLs of AWS or the custom endpoint.
+	downloadURLTemplate string
+	urlTemplate         *template.Template
+	// cloudFront signs the download URLs of modules, it's nil if they aren't signed.
+	cloudFront *CloudFrontSigner
+	// namespaceStorageClasses overrides the storage class of uploads per namespace.
+	namespaceStorageClasses map[string]string
+	logger                  log.Logger
 }
 
 // GetModule retrieves information about a module from the S3 storage.
@@ -33,8 +61,20 @@ func (s *S3Storage) GetModule(ctx context.Context, namespace, name, provider, ve
 		Key:    aws.String(key),
 	}
 
-	if _, err := s.s3.HeadObject(input); err != nil {
-		return Module{}, errors.Wrap(ErrNotFound, err.Error())
+	out, err := s.s3.HeadObject(input)
+	if err != nil {
+		if storageErrorReason(err) == ErrNotFound {
+			return Module{}, wrapStorageError(ErrNotFound, err)
+		}
+		return Module{}, wrapStorageError(ErrGetFailed, err)
+	}
+
+	downloadURL := s.downloadURL(*input.Key)
+	if s.cloudFront != nil {
+		// Only the URLs of downloads are signed, so they don't expire while listed versions are cached
+		if downloadURL, err = s.cloudFront.Sign(downloadURL, time.Now()); err != nil {
+			return Module{}, wrapStorageError(ErrGetFailed, err)
+		}
 	}
 
 	return Module{
@@ -42,10 +82,28 @@ func (s *S3Storage) GetModule(ctx context.Context, namespace, name, provider, ve
 		Name:        name,
 		Provider:    provider,
 		Version:     version,
-		DownloadURL: fmt.Sprintf("%s.s3-%s.amazonaws.com/%s", s.bucket, s.bucketRegion, *input.Key),
+		DownloadURL: downloadURL,
+		Digest:      s3MetadataValue(out.Metadata, metadataKeyDigest),
+		Created:     aws.TimeValue(out.LastModified),
 	}, nil
 }
 
+// OpenModule opens the archive of a module in the S3 storage.
+func (s *S3Storage) OpenModule(ctx context.Context, namespace, name, provider, version string) (io.ReadCloser, error) {
+	out, err := s.s3.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(storagePath(s.bucketPrefix, namespace, name, provider, version, s.archiveFormat)),
+	})
+	if err != nil {
+		if storageErrorReason(err) == ErrNotFound {
+			return nil, wrapStorageError(ErrNotFound, err)
+		}
+		return nil, wrapStorageError(ErrGetFailed, err)
+	}
+
+	return out.Body, nil
+}
+
 func (s *S3Storage) ListModuleVersions(ctx context.Context, namespace, name, provider string) ([]Module, error) {
 	var modules []Module
 
@@ -68,7 +126,8 @@ func (s *S3Storage) ListModuleVersions(ctx context.Context, namespace, name, pro
 				Name:        name,
 				Provider:    provider,
 				Version:     version,
-				DownloadURL: fmt.Sprintf("%s.s3-%s.amazonaws.com/%s", s.bucket, s.bucketRegion, *obj.Key),
+				DownloadURL: s.downloadURL(*obj.Key),
+				Created:     aws.TimeValue(obj.LastModified),
 			}
 
 			modules = append(modules, module)
@@ -78,12 +137,78 @@ func (s *S3Storage) ListModuleVersions(ctx context.Context, namespace, name, pro
 	}
 
 	if err := s.s3.ListObjectsV2Pages(input, fn); err != nil {
-		return nil, errors.Wrap(ErrListFailed, err.Error())
+		return nil, wrapStorageError(ErrListFailed, err)
 	}
 
 	return modules, nil
 }
 
+// ListModules lists all module versions of a namespace.
+func (s *S3Storage) ListModules(ctx context.Context, namespace string) ([]Module, error) {
+	var modules []Module
+
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(namespacePrefix(s.bucketPrefix, namespace)),
+	}
+
+	fn := func(page *s3.ListObjectsV2Output, last bool) bool {
+		for _, obj := range page.Contents {
+			metadata := objectMetadata(*obj.Key)
+
+			module := Module{
+				Namespace:   namespace,
+				Name:        metadata["name"],
+				Provider:    metadata["provider"],
+				Version:     metadata["version"],
+				DownloadURL: s.downloadURL(*obj.Key),
+				Created:     aws.TimeValue(obj.LastModified),
+			}
+
+			if module.Name == "" || module.Provider == "" || module.Version == "" {
+				continue
+			}
+
+			modules = append(modules, module)
+		}
+
+		return true
+	}
+
+	if err := s.s3.ListObjectsV2PagesWithContext(ctx, input, fn); err != nil {
+		return nil, wrapStorageError(ErrListFailed, err)
+	}
+
+	return modules, nil
+}
+
+// ListNamespaces lists the namespaces with modules in the S3 storage.
+func (s *S3Storage) ListNamespaces(ctx context.Context) ([]string, error) {
+	var namespaces []string
+
+	input := &s3.ListObjectsV2Input{
+		Bucket:    aws.String(s.bucket),
+		Prefix:    aws.String(namespacesPrefix(s.bucketPrefix)),
+		Delimiter: aws.String("/"),
+	}
+
+	fn := func(page *s3.ListObjectsV2Output, last bool) bool {
+		for _, prefix := range page.CommonPrefixes {
+			if namespace := objectMetadata(aws.StringValue(prefix.Prefix))["namespace"]; namespace != "" {
+				namespaces = append(namespaces, namespace)
+			}
+		}
+
+		return true
+	}
+
+	if err := s.s3.ListObjectsV2PagesWithContext(ctx, input, fn); err != nil {
+		return nil, wrapStorageError(ErrListFailed, err)
+	}
+
+	return namespaces, nil
+}
+
 // UploadModule uploads a module to the S3 storage.
 func (s *S3Storage) UploadModule(ctx context.Context, namespace, name, provider, version string, body io.Reader) (Module, error) {
 	if namespace == "" {
@@ -108,19 +233,278 @@ func (s *S3Storage) UploadModule(ctx context.Context, namespace, name, provider,
 		return Module{}, errors.Wrap(ErrAlreadyExists, key)
 	}
 
+	archive, err := openArchive(body)
+	if err != nil {
+		return Module{}, err
+	}
+	defer archive.Close()
+
+	// The archive is streamed, s3manager only buffers the parts it's uploading
 	input := &s3manager.UploadInput{
 		Bucket: aws.String(s.bucket),
 		Key:    aws.String(storagePath(s.bucketPrefix, namespace, name, provider, version, DefaultArchiveFormat)),
-		Body:   body,
+		Body:   archive,
+		Metadata: map[string]*string{
+			metadataKeyDigest: aws.String(archive.digest),
+		},
+	}
+
+	if class := s.uploadStorageClass(namespace); class != "" {
+		input.StorageClass = aws.String(class)
 	}
 
-	if _, err := s.uploader.Upload(input); err != nil {
-		return Module{}, errors.Wrapf(ErrUploadFailed, err.Error())
+	// The check above only fails early, the conditional write keeps concurrent uploads from overwriting each other
+	parts := &s3PartChecksums{}
+	if _, err := s.uploader.Upload(input, s3manager.WithUploaderRequestOptions(createOnly, parts.checksumParts)); err != nil {
+		var awsErr awserr.Error
+		if errors.As(err, &awsErr) && awsErr.Code() == "BadDigest" {
+			return Module{}, wrapStorageError(ErrChecksumMismatch, err)
+		}
+		return Module{}, archive.uploadError(err)
+	}
+
+	if err := s.verifyUpload(ctx, *input.Key, parts.etag()); err != nil {
+		return Module{}, err
 	}
 
 	return s.GetModule(ctx, namespace, name, provider, version)
 }
 
+// Default templates of download URLs, Terraform downloads both with its S3 credentials.
+const (
+	awsS3DownloadURLTemplate      = "{{.Bucket}}.s3-{{.Region}}.amazonaws.com/{{.Key}}"
+	endpointS3DownloadURLTemplate = "s3::{{.Endpoint}}/{{.Bucket}}/{{.Key}}"
+)
+
+// s3DownloadURL is the data of download URL templates.
+type s3DownloadURL struct {
+	Bucket   string
+	Region   string
+	Endpoint string
+	Key      string
+}
+
+// parseDownloadURLTemplate parses the template of download URLs, S3-compatible storages default to URLs of their endpoint.
+func (s *S3Storage) parseDownloadURLTemplate() error {
+	text := s.downloadURLTemplate
+	if text == "" {
+		text = awsS3DownloadURLTemplate
+		if s.endpoint != "" {
+			text = endpointS3DownloadURLTemplate
+		}
+	}
+
+	tmpl, err := template.New("download-url").Parse(text)
+	if err != nil {
+		return errors.Wrap(err, "invalid download URL template")
+	}
+
+	// Unknown fields only fail when the template is executed
+	if err := tmpl.Execute(ioutil.Discard, s3DownloadURL{}); err != nil {
+		return errors.Wrap(err, "invalid download URL template")
+	}
+
+	s.urlTemplate = tmpl
+	return nil
+}
+
+// downloadURL renders the download URL of an object.
+func (s *S3Storage) downloadURL(key string) string {
+	var b strings.Builder
+	// NewS3Storage executed the template already, so rendering it doesn't fail
+	_ = s.urlTemplate.Execute(&b, s3DownloadURL{
+		Bucket:   s.bucket,
+		Region:   s.bucketRegion,
+		Endpoint: s.endpoint,
+		Key:      key,
+	})
+	return b.String()
+}
+
+// createOnly makes S3 reject the write of an object which exists already.
+// Multipart uploads only create the object on completion, so their parts are written unconditionally.
+func createOnly(r *request.Request) {
+	switch r.Operation.Name {
+	case "PutObject", "CompleteMultipartUpload":
+		r.HTTPRequest.Header.Set("If-None-Match", "*")
+	}
+}
+
+// s3PartChecksums records the MD5 sums of the parts of an upload, to verify the ETag of the uploaded object.
+// Uploads in a single part are a PutObject, which is recorded as part 0.
+type s3PartChecksums struct {
+	mu   sync.Mutex
+	sums map[int64][]byte
+}
+
+// checksumParts makes S3 reject parts whose received bytes don't match. s3manager doesn't pass ContentMD5 on
+// to the parts, and the SDK skips their MD5 sums if S3DisableContentMD5Validation is set, so they are computed here.
+func (c *s3PartChecksums) checksumParts(r *request.Request) {
+	if r.Operation.Name != "UploadPart" && r.Operation.Name != "PutObject" {
+		return
+	}
+
+	// The body is only set once the request is built
+	r.Handlers.Build.PushBack(func(r *request.Request) {
+		if r.Error != nil {
+			return
+		}
+
+		hash := md5.New()
+		if _, err := aws.CopySeekableBody(hash, r.Body); err != nil {
+			r.Error = awserr.New("BodyHashError", "failed to compute MD5 sum of part", err)
+			return
+		}
+		sum := hash.Sum(nil)
+		r.HTTPRequest.Header.Set("Content-Md5", base64.StdEncoding.EncodeToString(sum))
+
+		var part int64
+		if input, ok := r.Params.(*s3.UploadPartInput); ok {
+			part = aws.Int64Value(input.PartNumber)
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.sums == nil {
+			c.sums = make(map[int64][]byte)
+		}
+		c.sums[part] = sum
+	})
+}
+
+// etag returns the ETag of the uploaded object. It's the MD5 sum of an object uploaded in a single part, while
+// the ETag of a multipart upload is the MD5 sum of the MD5 sums of all parts followed by the number of parts.
+func (c *s3PartChecksums) etag() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if sum, ok := c.sums[0]; ok {
+		return hex.EncodeToString(sum)
+	}
+
+	hash := md5.New()
+	for part := int64(1); part <= int64(len(c.sums)); part++ {
+		hash.Write(c.sums[part])
+	}
+
+	return fmt.Sprintf("%x-%d", hash.Sum(nil), len(c.sums))
+}
+
+// verifyUpload compares the ETag of an uploaded module with the ETag expected from the MD5 sums of the archive.
+// A corrupted module is deleted again, so the upload can be retried.
+func (s *S3Storage) verifyUpload(ctx context.Context, key, etag string) error {
+	out, err := s.s3.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return wrapStorageError(ErrUploadFailed, err)
+	}
+
+	verified, err := verifyS3ETag(out, etag)
+	if err != nil {
+		if _, deleteErr := s.s3.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(key),
+		}); deleteErr != nil {
+			return errors.Wrapf(err, "failed to delete corrupted module: %v", deleteErr)
+		}
+		return err
+	}
+
+	if !verified {
+		// The parts were checked by S3 on upload, but the object as a whole can't be
+		_ = level.Warn(s.logger).Log(
+			"msg", "unable to verify the ETag of the uploaded module",
+			"key", key,
+			"etag", aws.StringValue(out.ETag),
+		)
+	}
+
+	return nil
+}
+
+// verifyS3ETag returns ErrChecksumMismatch if the ETag of an object doesn't match the expected ETag.
+// The ETag is only derived from the MD5 sums of objects which aren't encrypted with KMS or customer keys,
+// the ETags of other objects can't be verified, which is reported as false.
+func verifyS3ETag(out *s3.HeadObjectOutput, expected string) (bool, error) {
+	etag := strings.Trim(aws.StringValue(out.ETag), `"`)
+
+	switch {
+	case etag == "":
+		return false, nil
+	case aws.StringValue(out.ServerSideEncryption) == s3.ServerSideEncryptionAwsKms, out.SSECustomerAlgorithm != nil:
+		return false, nil
+	}
+
+	if etag != expected {
+		return false, wrapStorageError(ErrChecksumMismatch, fmt.Errorf("ETag %s doesn't match the expected ETag %s", etag, expected))
+	}
+
+	return true, nil
+}
+
+// uploadStorageClass returns the storage class of module uploads to a namespace, empty for the bucket default.
+func (s *S3Storage) uploadStorageClass(namespace string) string {
+	if class, ok := s.namespaceStorageClasses[namespace]; ok {
+		return class
+	}
+
+	return s.storageClass
+}
+
+// DeleteModule removes a module from the S3 storage.
+func (s *S3Storage) DeleteModule(ctx context.Context, namespace, name, provider, version string) error {
+	if _, err := s.GetModule(ctx, namespace, name, provider, version); err != nil {
+		return err
+	}
+
+	// The metadata is deleted first, so a failed deletion can be retried as long as the version exists
+	keys, prefixes := versionMetadata(s.bucketPrefix, namespace, name, provider, version)
+	for _, prefix := range prefixes {
+		input := &s3.ListObjectsV2Input{
+			Bucket: aws.String(s.bucket),
+			Prefix: aws.String(prefix),
+		}
+
+		fn := func(page *s3.ListObjectsV2Output, last bool) bool {
+			for _, obj := range page.Contents {
+				keys = append(keys, aws.StringValue(obj.Key))
+			}
+			return true
+		}
+
+		if err := s.s3.ListObjectsV2PagesWithContext(ctx, input, fn); err != nil {
+			return wrapStorageError(ErrDeleteFailed, err)
+		}
+	}
+
+	// Deleting keys which don't exist succeeds in S3
+	for _, key := range append(keys, storagePath(s.bucketPrefix, namespace, name, provider, version, s.archiveFormat)) {
+		input := &s3.DeleteObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(key),
+		}
+
+		if _, err := s.s3.DeleteObjectWithContext(ctx, input); err != nil {
+			return wrapStorageError(ErrDeleteFailed, err)
+		}
+	}
+
+	return nil
+}
+
+// s3MetadataValue looks up an object metadata value.
+// S3 returns metadata keys in canonical header format, so they are compared case-insensitively.
+func s3MetadataValue(metadata map[string]*string, key string) string {
+	for k, v := range metadata {
+		if strings.EqualFold(k, key) && v != nil {
+			return *v
+		}
+	}
+	return ""
+}
+
 func (s *S3Storage) determineBucketRegion() (string, error) {
 	region, err := s3manager.GetBucketRegionWithClient(context.Background(), s.s3, s.bucket)
 	if err != nil {
@@ -130,6 +514,298 @@ func (s *S3Storage) determineBucketRegion() (string, error) {
 	return region, nil
 }
 
+// AddAnnotation stores an annotation of a module version in the S3 storage.
+func (s *S3Storage) AddAnnotation(ctx context.Context, namespace, name, provider, version string, annotation Annotation) error {
+	b, err := json.Marshal(annotation)
+	if err != nil {
+		return err
+	}
+
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(annotationPath(s.bucketPrefix, namespace, name, provider, version, annotation)),
+		Body:        bytes.NewReader(b),
+		ContentType: aws.String("application/json"),
+	}
+
+	if _, err := s.s3.PutObjectWithContext(ctx, input); err != nil {
+		return wrapStorageError(ErrAnnotationFailed, err)
+	}
+
+	return nil
+}
+
+// ListAnnotations lists the annotations of a module version in the order they were added.
+func (s *S3Storage) ListAnnotations(ctx context.Context, namespace, name, provider, version string) ([]Annotation, error) {
+	var keys []string
+
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(annotationPrefix(s.bucketPrefix, namespace, name, provider, version)),
+	}
+
+	fn := func(page *s3.ListObjectsV2Output, last bool) bool {
+		for _, obj := range page.Contents {
+			keys = append(keys, aws.StringValue(obj.Key))
+		}
+		return true
+	}
+
+	if err := s.s3.ListObjectsV2PagesWithContext(ctx, input, fn); err != nil {
+		return nil, wrapStorageError(ErrListFailed, err)
+	}
+
+	annotations := []Annotation{}
+	for _, key := range keys {
+		out, err := s.s3.GetObjectWithContext(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(key),
+		})
+		if err != nil {
+			return nil, wrapStorageError(ErrListFailed, err)
+		}
+
+		var annotation Annotation
+		err = json.NewDecoder(out.Body).Decode(&annotation)
+		out.Body.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decode annotation %s", key)
+		}
+
+		annotations = append(annotations, annotation)
+	}
+
+	return annotations, nil
+}
+
+// ApproveModule stores the approval of a module version in the S3 storage.
+func (s *S3Storage) ApproveModule(ctx context.Context, namespace, name, provider, version string, approval Approval) error {
+	b, err := json.Marshal(approval)
+	if err != nil {
+		return err
+	}
+
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(approvalPath(s.bucketPrefix, namespace, name, provider, version)),
+		Body:        bytes.NewReader(b),
+		ContentType: aws.String("application/json"),
+	}
+
+	if _, err := s.s3.PutObjectWithContext(ctx, input); err != nil {
+		return wrapStorageError(ErrApprovalFailed, err)
+	}
+
+	return nil
+}
+
+// ListApprovals lists the approvals of all versions of a module.
+// Only the version and approval time are set, as the approvals aren't read.
+func (s *S3Storage) ListApprovals(ctx context.Context, namespace, name, provider string) ([]Approval, error) {
+	approvals := []Approval{}
+
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(approvalPrefix(s.bucketPrefix, namespace, name, provider)),
+	}
+
+	fn := func(page *s3.ListObjectsV2Output, last bool) bool {
+		for _, obj := range page.Contents {
+			approvals = append(approvals, Approval{
+				Version:    approvedVersion(aws.StringValue(obj.Key)),
+				ApprovedAt: aws.TimeValue(obj.LastModified),
+			})
+		}
+		return true
+	}
+
+	if err := s.s3.ListObjectsV2PagesWithContext(ctx, input, fn); err != nil {
+		return nil, wrapStorageError(ErrListFailed, err)
+	}
+
+	return approvals, nil
+}
+
+// ScheduleModule stores the publication time of a module version in the S3 storage.
+func (s *S3Storage) ScheduleModule(ctx context.Context, namespace, name, provider, version string, publishAt time.Time) error {
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(schedulePath(s.bucketPrefix, namespace, name, provider, version, publishAt)),
+		Body:   bytes.NewReader(nil),
+	}
+
+	if _, err := s.s3.PutObjectWithContext(ctx, input); err != nil {
+		return wrapStorageError(ErrScheduleFailed, err)
+	}
+
+	return nil
+}
+
+// ListSchedules lists the schedules of all versions of a module.
+func (s *S3Storage) ListSchedules(ctx context.Context, namespace, name, provider string) ([]Schedule, error) {
+	var keys []string
+
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(schedulePrefix(s.bucketPrefix, namespace, name, provider)),
+	}
+
+	fn := func(page *s3.ListObjectsV2Output, last bool) bool {
+		for _, obj := range page.Contents {
+			keys = append(keys, aws.StringValue(obj.Key))
+		}
+		return true
+	}
+
+	if err := s.s3.ListObjectsV2PagesWithContext(ctx, input, fn); err != nil {
+		return nil, wrapStorageError(ErrListFailed, err)
+	}
+
+	schedules := []Schedule{}
+	for _, key := range keys {
+		schedule, err := parseSchedulePath(key)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, schedule)
+	}
+
+	return schedules, nil
+}
+
+// SetLabels replaces the labels of a module in the S3 storage.
+func (s *S3Storage) SetLabels(ctx context.Context, namespace, name, provider string, labels Labels) error {
+	b, err := json.Marshal(labels)
+	if err != nil {
+		return err
+	}
+
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(labelPath(s.bucketPrefix, namespace, name, provider)),
+		Body:        bytes.NewReader(b),
+		ContentType: aws.String("application/json"),
+	}
+
+	if _, err := s.s3.PutObjectWithContext(ctx, input); err != nil {
+		return wrapStorageError(ErrLabelFailed, err)
+	}
+
+	return nil
+}
+
+// GetLabels returns the labels of a module, which are empty if none were set.
+func (s *S3Storage) GetLabels(ctx context.Context, namespace, name, provider string) (Labels, error) {
+	key := labelPath(s.bucketPrefix, namespace, name, provider)
+
+	out, err := s.s3.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if storageErrorReason(err) == ErrNotFound {
+		return Labels{}, nil
+	} else if err != nil {
+		return nil, wrapStorageError(ErrGetFailed, err)
+	}
+	defer out.Body.Close()
+
+	labels := Labels{}
+	if err := json.NewDecoder(out.Body).Decode(&labels); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode labels %s", key)
+	}
+
+	return labels, nil
+}
+
+// SetMaturity stores the maturity of a module version in the S3 storage.
+func (s *S3Storage) SetMaturity(ctx context.Context, namespace, name, provider string, maturity VersionMaturity) error {
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(maturityPath(s.bucketPrefix, namespace, name, provider, maturity)),
+		Body:   bytes.NewReader(nil),
+	}
+
+	if _, err := s.s3.PutObjectWithContext(ctx, input); err != nil {
+		return wrapStorageError(ErrMaturityFailed, err)
+	}
+
+	return nil
+}
+
+// ListMaturities lists the latest maturity of all versions of a module.
+func (s *S3Storage) ListMaturities(ctx context.Context, namespace, name, provider string) ([]VersionMaturity, error) {
+	var keys []string
+
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(maturityPrefix(s.bucketPrefix, namespace, name, provider)),
+	}
+
+	fn := func(page *s3.ListObjectsV2Output, last bool) bool {
+		for _, obj := range page.Contents {
+			keys = append(keys, aws.StringValue(obj.Key))
+		}
+		return true
+	}
+
+	if err := s.s3.ListObjectsV2PagesWithContext(ctx, input, fn); err != nil {
+		return nil, wrapStorageError(ErrListFailed, err)
+	}
+
+	var maturities []VersionMaturity
+	for _, key := range keys {
+		maturity, err := parseMaturityPath(key)
+		if err != nil {
+			return nil, err
+		}
+		maturities = append(maturities, maturity)
+	}
+
+	return latestMaturities(maturities), nil
+}
+
+// SetDocs stores the documentation of a module version in the S3 storage.
+func (s *S3Storage) SetDocs(ctx context.Context, namespace, name, provider, version string, docs Docs) error {
+	b, err := json.Marshal(docs)
+	if err != nil {
+		return err
+	}
+
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(docsPath(s.bucketPrefix, namespace, name, provider, version)),
+		Body:        bytes.NewReader(b),
+		ContentType: aws.String("application/json"),
+	}
+
+	if _, err := s.s3.PutObjectWithContext(ctx, input); err != nil {
+		return wrapStorageError(ErrDocsFailed, err)
+	}
+
+	return nil
+}
+
+// GetDocs returns the documentation of a module version.
+func (s *S3Storage) GetDocs(ctx context.Context, namespace, name, provider, version string) (Docs, error) {
+	key := docsPath(s.bucketPrefix, namespace, name, provider, version)
+
+	out, err := s.s3.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return Docs{}, wrapStorageError(ErrGetFailed, err)
+	}
+	defer out.Body.Close()
+
+	var docs Docs
+	if err := json.NewDecoder(out.Body).Decode(&docs); err != nil {
+		return Docs{}, errors.Wrapf(err, "failed to decode docs %s", key)
+	}
+
+	return docs, nil
+}
+
 // S3StorageOption provides additional options for the S3Storage.
 type S3StorageOption func(*S3Storage)
 
@@ -160,6 +836,7 @@ func WithS3StorageBucketEndpoint(endpoint string) S3StorageOption {
 		// default value is "", so don't set and leave to aws sdk
 		if len(endpoint) > 0 {
 			s.s3.Client.Endpoint = endpoint
+			s.endpoint = strings.TrimSuffix(endpoint, "/")
 		}
 		s.bucketEndpoint = "aws sdk default"
 	}
@@ -176,24 +853,142 @@ func WithS3StoragePathStyle(pathStyle bool) S3StorageOption {
 	}
 }
 
+// WithS3HTTPClient configures the HTTP client of all requests to S3, e.g. to tune its connection pool.
+func WithS3HTTPClient(client *http.Client) S3StorageOption {
+	return func(s *S3Storage) {
+		if client != nil {
+			s.s3.Client.Config.HTTPClient = client
+		}
+	}
+}
+
+// WithS3Credentials configures the credentials of all requests to S3 instead of the default credential chain.
+// The credentials are refreshed when they expire, so they have to be backed by a provider that can retrieve them again.
+func WithS3Credentials(creds *credentials.Credentials) S3StorageOption {
+	return func(s *S3Storage) {
+		if creds != nil {
+			s.s3.Client.Config.Credentials = creds
+		}
+	}
+}
+
+// WithS3MaxRetries configures how often failed requests to S3 are retried, a negative value keeps the SDK default.
+func WithS3MaxRetries(retries int) S3StorageOption {
+	return func(s *S3Storage) {
+		if retries >= 0 {
+			s.s3.Client.Config.MaxRetries = aws.Int(retries)
+			s.s3.Client.Retryer = client.DefaultRetryer{NumMaxRetries: retries}
+		}
+	}
+}
+
+// WithS3StorageClass configures the storage class of uploaded modules, e.g. STANDARD_IA or INTELLIGENT_TIERING.
+func WithS3StorageClass(class string) S3StorageOption {
+	return func(s *S3Storage) {
+		s.storageClass = class
+	}
+}
+
+// WithS3NamespaceStorageClass configures the storage class of modules uploaded to a namespace,
+// which takes precedence over the storage class configured by WithS3StorageClass.
+func WithS3NamespaceStorageClass(namespace, class string) S3StorageOption {
+	return func(s *S3Storage) {
+		if s.namespaceStorageClasses == nil {
+			s.namespaceStorageClasses = make(map[string]string)
+		}
+		s.namespaceStorageClasses[namespace] = class
+	}
+}
+
+// WithS3UploadPartSize configures the size of the parts archives larger than it are uploaded in,
+// 0 keeps the SDK default of 5 MiB, which is also the minimum. Archives of more than 10,000 parts use larger parts.
+func WithS3UploadPartSize(size int64) S3StorageOption {
+	return func(s *S3Storage) {
+		if size > 0 {
+			s.uploader.PartSize = size
+		}
+	}
+}
+
+// WithS3UploadConcurrency configures how many parts of an archive are uploaded in parallel, 0 keeps the SDK default of 5.
+func WithS3UploadConcurrency(concurrency int) S3StorageOption {
+	return func(s *S3Storage) {
+		if concurrency > 0 {
+			s.uploader.Concurrency = concurrency
+		}
+	}
+}
+
+// WithS3DownloadURLTemplate configures the Go template the download URLs of modules are rendered with, e.g. for CloudFront.
+// It has access to .Bucket, .Region, .Endpoint and the object .Key, an empty template keeps the default S3 URLs.
+func WithS3DownloadURLTemplate(tmpl string) S3StorageOption {
+	return func(s *S3Storage) {
+		s.downloadURLTemplate = tmpl
+	}
+}
+
+// WithS3CloudFrontSigner signs the download URLs of modules for CloudFront, the URLs of the distribution are
+// configured with WithS3DownloadURLTemplate.
+func WithS3CloudFrontSigner(signer *CloudFrontSigner) S3StorageOption {
+	return func(s *S3Storage) {
+		s.cloudFront = signer
+	}
+}
+
+// WithS3Logger configures the logger of warnings, e.g. about uploads whose ETag can't be verified.
+func WithS3Logger(logger log.Logger) S3StorageOption {
+	return func(s *S3Storage) {
+		s.logger = logger
+	}
+}
+
 // NewS3Storage returns a fully initialized S3 storage.
 func NewS3Storage(bucket string, options ...S3StorageOption) (Storage, error) {
-	sess, err := session.NewSession()
+	// Shared config is enabled, so profiles with credential_process or web_identity_token_file work without AWS_SDK_LOAD_CONFIG
+	sess, err := session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	})
 	if err != nil {
 		return nil, err
 	}
 
+	// The uploader shares the client and with it the HTTP client configured by the options
+	client := s3.New(sess)
 	s := &S3Storage{
-		s3:            s3.New(sess),
-		uploader:      s3manager.NewUploader(sess),
+		s3:            client,
+		uploader:      s3manager.NewUploaderWithClient(client),
 		bucket:        bucket,
 		archiveFormat: DefaultArchiveFormat,
+		logger:        log.NewNopLogger(),
 	}
 
 	for _, option := range options {
 		option(s)
 	}
 
+	if s.uploader.PartSize < s3manager.MinUploadPartSize {
+		return nil, fmt.Errorf("invalid upload part size %d, expected at least %d bytes", s.uploader.PartSize, s3manager.MinUploadPartSize)
+	}
+
+	if err := s.parseDownloadURLTemplate(); err != nil {
+		return nil, err
+	}
+
+	if s.cloudFront != nil && s.downloadURLTemplate == "" {
+		return nil, errors.New("signing download URLs for CloudFront requires a download URL template of the distribution")
+	}
+
+	classes := []string{s.storageClass}
+	for _, class := range s.namespaceStorageClasses {
+		classes = append(classes, class)
+	}
+
+	for _, class := range classes {
+		if class != "" && !validS3StorageClass(class) {
+			return nil, fmt.Errorf("invalid storage class %q, expected one of %s", class, strings.Join(s3.StorageClass_Values(), ", "))
+		}
+	}
+
 	if s.bucketRegion == "" {
 		region, err := s.determineBucketRegion()
 		if err != nil {
@@ -204,3 +999,13 @@ func NewS3Storage(bucket string, options ...S3StorageOption) (Storage, error) {
 
 	return s, nil
 }
+
+func validS3StorageClass(class string) bool {
+	for _, v := range s3.StorageClass_Values() {
+		if v == class {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,492 @@
+package module
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestS3Storage_StorageClass(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		options  []S3StorageOption
+		expected map[string]string
+		err      bool
+	}{
+		{
+			name:     "bucket default",
+			expected: map[string]string{"tier": ""},
+		},
+		{
+			name:     "storage class",
+			options:  []S3StorageOption{WithS3StorageClass("INTELLIGENT_TIERING")},
+			expected: map[string]string{"tier": "INTELLIGENT_TIERING"},
+		},
+		{
+			name: "namespace storage class",
+			options: []S3StorageOption{
+				WithS3StorageClass("INTELLIGENT_TIERING"),
+				WithS3NamespaceStorageClass("archive", "STANDARD_IA"),
+			},
+			expected: map[string]string{"tier": "INTELLIGENT_TIERING", "archive": "STANDARD_IA"},
+		},
+		{
+			name:    "invalid storage class",
+			options: []S3StorageOption{WithS3StorageClass("COLD")},
+			err:     true,
+		},
+		{
+			name:    "invalid namespace storage class",
+			options: []S3StorageOption{WithS3NamespaceStorageClass("archive", "COLD")},
+			err:     true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+
+			// The region is set to avoid looking it up
+			s, err := NewS3Storage("bucket", append(tc.options, WithS3StorageBucketRegion("eu-central-1"))...)
+			if tc.err {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+
+			for namespace, class := range tc.expected {
+				assert.Equal(class, s.(*S3Storage).uploadStorageClass(namespace))
+			}
+		})
+	}
+}
+
+func TestS3Storage_Uploader(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name                string
+		options             []S3StorageOption
+		expectedPartSize    int64
+		expectedConcurrency int
+		err                 bool
+	}{
+		{
+			name:                "SDK defaults",
+			options:             []S3StorageOption{WithS3UploadPartSize(0), WithS3UploadConcurrency(0)},
+			expectedPartSize:    s3manager.DefaultUploadPartSize,
+			expectedConcurrency: s3manager.DefaultUploadConcurrency,
+		},
+		{
+			name:                "large parts",
+			options:             []S3StorageOption{WithS3UploadPartSize(64 << 20), WithS3UploadConcurrency(16)},
+			expectedPartSize:    64 << 20,
+			expectedConcurrency: 16,
+		},
+		{
+			name:    "parts below the minimum",
+			options: []S3StorageOption{WithS3UploadPartSize(1 << 20)},
+			err:     true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+
+			s, err := NewS3Storage("bucket", append(tc.options, WithS3StorageBucketRegion("eu-central-1"))...)
+			if tc.err {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+
+			assert.Equal(tc.expectedPartSize, s.(*S3Storage).uploader.PartSize)
+			assert.Equal(tc.expectedConcurrency, s.(*S3Storage).uploader.Concurrency)
+		})
+	}
+}
+
+func TestS3Storage_DownloadURL(t *testing.T) {
+	t.Parallel()
+
+	key := "modules/namespace=tier/name=vpc/provider=aws/version=1.0.0/tier-vpc-aws-1.0.0.tar.gz"
+
+	testCases := []struct {
+		name     string
+		options  []S3StorageOption
+		expected string
+		err      bool
+	}{
+		{
+			name:     "aws",
+			expected: "bucket.s3-eu-central-1.amazonaws.com/" + key,
+		},
+		{
+			name:     "custom endpoint",
+			options:  []S3StorageOption{WithS3StorageBucketEndpoint("https://minio.example.com:9000/")},
+			expected: "s3::https://minio.example.com:9000/bucket/" + key,
+		},
+		{
+			name: "template",
+			options: []S3StorageOption{
+				WithS3StorageBucketEndpoint("https://minio.example.com:9000"),
+				WithS3DownloadURLTemplate("https://modules.example.com/{{.Key}}"),
+			},
+			expected: "https://modules.example.com/" + key,
+		},
+		{
+			name:    "invalid template",
+			options: []S3StorageOption{WithS3DownloadURLTemplate("https://modules.example.com/{{.Key")},
+			err:     true,
+		},
+		{
+			name:    "unknown field",
+			options: []S3StorageOption{WithS3DownloadURLTemplate("https://modules.example.com/{{.Path}}")},
+			err:     true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+
+			s, err := NewS3Storage("bucket", append(tc.options, WithS3StorageBucketRegion("eu-central-1"))...)
+			if tc.err {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+
+			assert.Equal(tc.expected, s.(*S3Storage).downloadURL(key))
+		})
+	}
+}
+
+func TestS3Storage_UploadModuleConditionally(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	// The version doesn't exist yet when it's checked, but another upload creates it before the archive is written
+	var conditions []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodHead:
+			w.WriteHeader(http.StatusNotFound)
+		case http.MethodPut:
+			conditions = append(conditions, r.Header.Get("If-None-Match"))
+			w.WriteHeader(http.StatusPreconditionFailed)
+			fmt.Fprint(w, `<Error><Code>PreconditionFailed</Code><Message>At least one of the pre-conditions you specified did not hold</Message></Error>`)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer server.Close()
+
+	s, err := NewS3Storage("bucket",
+		WithS3StorageBucketRegion("eu-central-1"),
+		WithS3StorageBucketEndpoint(server.URL),
+		WithS3StoragePathStyle(true),
+		WithS3MaxRetries(0),
+		WithS3Credentials(credentials.NewStaticCredentials("id", "secret", "")),
+	)
+	assert.NoError(err)
+
+	// The region of the client is usually configured by the environment
+	s.(*S3Storage).s3.Config.Region = aws.String("eu-central-1")
+
+	_, err = s.UploadModule(context.Background(), "tier", "vpc", "aws", "1.0.0", strings.NewReader("archive"))
+	assert.Equal(ErrAlreadyExists, errors.Cause(err))
+	assert.True(errors.Is(err, ErrUploadFailed))
+	assert.Equal([]string{"*"}, conditions)
+}
+
+func TestVerifyS3ETag(t *testing.T) {
+	t.Parallel()
+
+	expected := fmt.Sprintf("%x", md5.Sum([]byte("data")))
+
+	testCases := []struct {
+		name             string
+		out              *s3.HeadObjectOutput
+		expected         string
+		expectedVerified bool
+		err              bool
+	}{
+		{name: "match", out: &s3.HeadObjectOutput{ETag: aws.String(`"` + expected + `"`)}, expected: expected, expectedVerified: true},
+		{name: "mismatch", out: &s3.HeadObjectOutput{ETag: aws.String(`"d41d8cd98f00b204e9800998ecf8427e"`)}, expected: expected, err: true},
+		{name: "multipart", out: &s3.HeadObjectOutput{ETag: aws.String(`"d41d8cd98f00b204e9800998ecf8427e-2"`)}, expected: "d41d8cd98f00b204e9800998ecf8427e-2", expectedVerified: true},
+		{name: "multipart mismatch", out: &s3.HeadObjectOutput{ETag: aws.String(`"d41d8cd98f00b204e9800998ecf8427e-2"`)}, expected: expected + "-2", err: true},
+		{name: "kms", out: &s3.HeadObjectOutput{ETag: aws.String(`"d41d8cd98f00b204e9800998ecf8427e"`), ServerSideEncryption: aws.String(s3.ServerSideEncryptionAwsKms)}, expected: expected},
+		{name: "customer key", out: &s3.HeadObjectOutput{ETag: aws.String(`"d41d8cd98f00b204e9800998ecf8427e"`), SSECustomerAlgorithm: aws.String("AES256")}, expected: expected},
+		{name: "without etag", out: &s3.HeadObjectOutput{}, expected: expected},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			verified, err := verifyS3ETag(tc.out, tc.expected)
+			if tc.err {
+				assert.True(t, errors.Is(err, ErrChecksumMismatch))
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expectedVerified, verified)
+		})
+	}
+}
+
+// fakeS3 serves the S3 requests of module uploads with path style addressing and records the headers of all requests.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string]fakeS3Object
+	parts   map[string]map[int][]byte
+	// requests are the headers of all requests by operation, e.g. "UploadPart".
+	requests map[string][]http.Header
+	// corrupt flips a bit of objects uploaded in parts after their parts were checked.
+	corrupt bool
+}
+
+type fakeS3Object struct {
+	data     []byte
+	etag     string
+	metadata http.Header
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{
+		objects:  make(map[string]fakeS3Object),
+		parts:    make(map[string]map[int][]byte),
+		requests: make(map[string][]http.Header),
+	}
+}
+
+// storage returns an S3Storage of the bucket "bucket" of a server serving the fake.
+func (f *fakeS3) storage(t *testing.T, options ...S3StorageOption) *S3Storage {
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+
+	s, err := NewS3Storage("bucket", append([]S3StorageOption{
+		WithS3StorageBucketRegion("eu-central-1"),
+		WithS3StorageBucketEndpoint(server.URL),
+		WithS3StoragePathStyle(true),
+		WithS3MaxRetries(0),
+		WithS3Credentials(credentials.NewStaticCredentials("id", "secret", "")),
+	}, options...)...)
+	assert.NoError(t, err)
+
+	// The region of the client is usually configured by the environment
+	s.(*S3Storage).s3.Config.Region = aws.String("eu-central-1")
+
+	return s.(*S3Storage)
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := r.URL.Path
+	query := r.URL.Query()
+	body, _ := ioutil.ReadAll(r.Body)
+
+	badDigest := func(data []byte) bool {
+		sum := md5.Sum(data)
+		if v := r.Header.Get("Content-Md5"); v != "" && v != base64.StdEncoding.EncodeToString(sum[:]) {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `<Error><Code>BadDigest</Code><Message>The Content-MD5 you specified did not match what we received.</Message></Error>`)
+			return true
+		}
+		return false
+	}
+
+	switch {
+	case r.Method == http.MethodHead:
+		obj, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		for k, v := range obj.metadata {
+			w.Header()[k] = v
+		}
+		w.Header().Set("ETag", obj.etag)
+	case r.Method == http.MethodPut && query.Get("uploadId") != "":
+		f.requests["UploadPart"] = append(f.requests["UploadPart"], r.Header)
+		if badDigest(body) {
+			return
+		}
+		part, _ := strconv.Atoi(query.Get("partNumber"))
+		f.parts[query.Get("uploadId")][part] = body
+		sum := md5.Sum(body)
+		w.Header().Set("ETag", fmt.Sprintf(`"%x"`, sum))
+	case r.Method == http.MethodPut:
+		f.requests["PutObject"] = append(f.requests["PutObject"], r.Header)
+		if badDigest(body) {
+			return
+		}
+		sum := md5.Sum(body)
+		f.objects[key] = fakeS3Object{data: body, etag: fmt.Sprintf(`"%x"`, sum), metadata: fakeS3Metadata(r.Header)}
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		f.requests["CreateMultipartUpload"] = append(f.requests["CreateMultipartUpload"], r.Header)
+		id := strconv.Itoa(len(f.parts) + 1)
+		f.parts[id] = make(map[int][]byte)
+		f.objects[key+"?uploadId="+id] = fakeS3Object{metadata: fakeS3Metadata(r.Header)}
+		fmt.Fprintf(w, `<InitiateMultipartUploadResult><Bucket>bucket</Bucket><Key>%s</Key><UploadId>%s</UploadId></InitiateMultipartUploadResult>`, key, id)
+	case r.Method == http.MethodPost && query.Get("uploadId") != "":
+		f.requests["CompleteMultipartUpload"] = append(f.requests["CompleteMultipartUpload"], r.Header)
+		id := query.Get("uploadId")
+
+		// The ETag of multipart uploads is the MD5 sum of the MD5 sums of the parts
+		var data, sums []byte
+		for part := 1; part <= len(f.parts[id]); part++ {
+			if f.corrupt {
+				f.parts[id][part][0] ^= 1
+			}
+			data = append(data, f.parts[id][part]...)
+			sum := md5.Sum(f.parts[id][part])
+			sums = append(sums, sum[:]...)
+		}
+		sum := md5.Sum(sums)
+		etag := fmt.Sprintf(`"%x-%d"`, sum, len(f.parts[id]))
+
+		f.objects[key] = fakeS3Object{data: data, etag: etag, metadata: f.objects[key+"?uploadId="+id].metadata}
+		fmt.Fprintf(w, `<CompleteMultipartUploadResult><Bucket>bucket</Bucket><Key>%s</Key><ETag>%s</ETag></CompleteMultipartUploadResult>`, key, etag)
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// fakeS3Metadata returns the object metadata headers of a request.
+func fakeS3Metadata(header http.Header) http.Header {
+	metadata := make(http.Header)
+	for k, v := range header {
+		if strings.HasPrefix(k, "X-Amz-Meta-") {
+			metadata[k] = v
+		}
+	}
+	return metadata
+}
+
+// largeArchive returns an archive which is uploaded in two parts of the default part size.
+func largeArchive() []byte {
+	data := make([]byte, s3manager.MinUploadPartSize+1024)
+	_, _ = rand.New(rand.NewSource(1)).Read(data)
+	return data
+}
+
+func TestS3Storage_UploadModuleInParts(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	fake := newFakeS3()
+	s := fake.storage(t)
+	// The SDK doesn't send the MD5 sums of parts itself then
+	s.s3.Config.S3DisableContentMD5Validation = aws.Bool(true)
+
+	data := largeArchive()
+	res, err := s.UploadModule(context.Background(), "tier", "vpc", "aws", "1.0.0", bytes.NewReader(data))
+	assert.NoError(err)
+	assert.Equal(ArchiveDigest(data), res.Digest)
+
+	// Every part is checked by S3, as s3manager only sets ContentMD5 of uploads in a single part.
+	// The parts are uploaded concurrently, so their order isn't known.
+	var expected, sent []string
+	for _, part := range [][]byte{data[:s3manager.MinUploadPartSize], data[s3manager.MinUploadPartSize:]} {
+		sum := md5.Sum(part)
+		expected = append(expected, base64.StdEncoding.EncodeToString(sum[:]))
+	}
+	for _, header := range fake.requests["UploadPart"] {
+		sent = append(sent, header.Get("Content-Md5"))
+	}
+	assert.ElementsMatch(expected, sent)
+}
+
+func TestS3Storage_UploadModuleStreamed(t *testing.T) {
+	t.Parallel()
+
+	data := largeArchive()
+
+	testCases := []struct {
+		name      string
+		digest    string
+		expectErr error
+	}{
+		{name: "declared digest", digest: ArchiveDigest(data)},
+		{name: "corrupted on the way", digest: ArchiveDigest([]byte("data")), expectErr: ErrInvalidArchive},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert := assert.New(t)
+
+			fake := newFakeS3()
+			s := fake.storage(t)
+
+			// The body of a request can't be rewound, so the archive is streamed in parts as it's read
+			body := newUploadBody(struct{ io.Reader }{bytes.NewReader(data)}, DefaultMaxArchiveSize, tc.digest)
+			res, err := s.UploadModule(context.Background(), "tier", "vpc", "aws", "1.0.0", body)
+			if tc.expectErr != nil {
+				assert.Equal(tc.expectErr, errors.Cause(err))
+
+				_, err = s.GetModule(context.Background(), "tier", "vpc", "aws", "1.0.0")
+				assert.Equal(ErrNotFound, errors.Cause(err))
+				assert.Empty(fake.requests["CompleteMultipartUpload"])
+				return
+			}
+
+			assert.NoError(err)
+			assert.Equal(ArchiveDigest(data), res.Digest)
+			assert.Len(fake.requests["UploadPart"], 2)
+			assert.Equal(data, fake.objects["/bucket/"+storagePath("", "tier", "vpc", "aws", "1.0.0", DefaultArchiveFormat)].data)
+		})
+	}
+}
+
+func TestS3Storage_UploadModuleInParts_Corrupted(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	fake := newFakeS3()
+	fake.corrupt = true
+	s := fake.storage(t)
+
+	_, err := s.UploadModule(context.Background(), "tier", "vpc", "aws", "1.0.0", bytes.NewReader(largeArchive()))
+	assert.True(errors.Is(err, ErrChecksumMismatch))
+
+	// The corrupted module is deleted, so the upload can be retried
+	_, err = s.GetModule(context.Background(), "tier", "vpc", "aws", "1.0.0")
+	assert.Equal(ErrNotFound, errors.Cause(err))
+}
@@ -3,7 +3,10 @@ package module
 import (
 	"context"
 	"encoding/json"
+	"io"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/TierMobility/boring-registry/pkg/auth"
 	"github.com/go-kit/kit/endpoint"
@@ -25,6 +28,22 @@ const (
 // MakeHandler returns a fully initialized http.Handler.
 func MakeHandler(svc Service, auth endpoint.Middleware, options ...httptransport.ServerOption) http.Handler {
 	r := mux.NewRouter().StrictSlash(true)
+	index := newSearchIndex(svc)
+
+	// The search is registered before the listing of a namespace, which would match it as well
+	for _, path := range []string{`/`, `/search`} {
+		r.Methods("GET").Path(path).Handler(
+			httptransport.NewServer(
+				auth(searchEndpoint(index)),
+				decodeSearchRequest,
+				httptransport.EncodeJSONResponse,
+				append(
+					options,
+					httptransport.ServerBefore(extractHeaders("Authorization")),
+				)...,
+			),
+		)
+	}
 
 	r.Methods("GET").Path(`/{namespace}/{name}/{provider}/versions`).Handler(
 		httptransport.NewServer(
@@ -39,6 +58,97 @@ func MakeHandler(svc Service, auth endpoint.Middleware, options ...httptransport
 		),
 	)
 
+	r.Methods("GET").Path(`/{namespace}/{name}/{provider}/releases`).Handler(
+		httptransport.NewServer(
+			auth(releasesEndpoint(svc)),
+			decodeListRequest,
+			httptransport.EncodeJSONResponse,
+			append(
+				options,
+				httptransport.ServerBefore(extractMuxVars(varNamespace, varName, varProvider)),
+				httptransport.ServerBefore(extractHeaders("Authorization")),
+			)...,
+		),
+	)
+
+	r.Methods("GET").Path(`/{namespace}/{name}/{provider}/` + feedFileName).Handler(
+		httptransport.NewServer(
+			auth(feedEndpoint(svc)),
+			decodeFeedRequest,
+			encodeFeedResponse,
+			append(
+				options,
+				httptransport.ServerBefore(extractMuxVars(varNamespace, varName, varProvider)),
+				httptransport.ServerBefore(extractHeaders("Authorization")),
+			)...,
+		),
+	)
+
+	r.Methods("GET").Path(`/{namespace}/` + feedFileName).Handler(
+		httptransport.NewServer(
+			auth(feedEndpoint(svc)),
+			decodeFeedRequest,
+			encodeFeedResponse,
+			append(
+				options,
+				httptransport.ServerBefore(extractMuxVars(varNamespace)),
+				httptransport.ServerBefore(extractHeaders("Authorization")),
+			)...,
+		),
+	)
+
+	r.Methods("GET").Path(`/{namespace}`).Handler(
+		httptransport.NewServer(
+			auth(modulesEndpoint(svc)),
+			decodeModulesRequest,
+			httptransport.EncodeJSONResponse,
+			append(
+				options,
+				httptransport.ServerBefore(extractMuxVars(varNamespace)),
+				httptransport.ServerBefore(extractHeaders("Authorization")),
+			)...,
+		),
+	)
+
+	r.Methods("GET").Path(`/{namespace}/changes`).Handler(
+		httptransport.NewServer(
+			auth(changesEndpoint(svc)),
+			decodeChangesRequest,
+			httptransport.EncodeJSONResponse,
+			append(
+				options,
+				httptransport.ServerBefore(extractMuxVars(varNamespace)),
+				httptransport.ServerBefore(extractHeaders("Authorization")),
+			)...,
+		),
+	)
+
+	r.Methods("GET").Path(`/{namespace}/{name}/{provider}/labels`).Handler(
+		httptransport.NewServer(
+			auth(getLabelsEndpoint(svc)),
+			decodeLabelsRequest,
+			httptransport.EncodeJSONResponse,
+			append(
+				options,
+				httptransport.ServerBefore(extractMuxVars(varNamespace, varName, varProvider)),
+				httptransport.ServerBefore(extractHeaders("Authorization")),
+			)...,
+		),
+	)
+
+	r.Methods("PUT").Path(`/{namespace}/{name}/{provider}/labels`).Handler(
+		httptransport.NewServer(
+			auth(setLabelsEndpoint(svc)),
+			decodeLabelsRequest,
+			httptransport.EncodeJSONResponse,
+			append(
+				options,
+				httptransport.ServerBefore(extractMuxVars(varNamespace, varName, varProvider)),
+				httptransport.ServerBefore(extractHeaders("Authorization")),
+			)...,
+		),
+	)
+
 	r.Methods("GET").Path(`/{namespace}/{name}/{provider}/{version}/download`).Handler(
 		httptransport.NewServer(
 			auth(downloadEndpoint(svc)),
@@ -52,6 +162,104 @@ func MakeHandler(svc Service, auth endpoint.Middleware, options ...httptransport
 		),
 	)
 
+	r.Methods("POST").Path(`/{namespace}/{name}/{provider}/{version}/upload`).Handler(
+		httptransport.NewServer(
+			auth(uploadEndpoint(svc)),
+			decodeUploadRequest,
+			httptransport.EncodeJSONResponse,
+			append(
+				options,
+				httptransport.ServerBefore(extractMuxVars(varNamespace, varName, varProvider, varVersion)),
+				httptransport.ServerBefore(extractHeaders("Authorization")),
+			)...,
+		),
+	)
+
+	r.Methods("GET").Path(`/{namespace}/{name}/{provider}/{version}/annotations`).Handler(
+		httptransport.NewServer(
+			auth(listAnnotationsEndpoint(svc)),
+			decodeAnnotationsRequest,
+			httptransport.EncodeJSONResponse,
+			append(
+				options,
+				httptransport.ServerBefore(extractMuxVars(varNamespace, varName, varProvider, varVersion)),
+				httptransport.ServerBefore(extractHeaders("Authorization")),
+			)...,
+		),
+	)
+
+	r.Methods("GET").Path(`/{namespace}/{name}/{provider}/{version}/docs`).Handler(
+		httptransport.NewServer(
+			auth(docsEndpoint(svc)),
+			decodeDocsRequest,
+			httptransport.EncodeJSONResponse,
+			append(
+				options,
+				httptransport.ServerBefore(extractMuxVars(varNamespace, varName, varProvider, varVersion)),
+				httptransport.ServerBefore(extractHeaders("Authorization")),
+			)...,
+		),
+	)
+
+	r.Methods("POST").Path(`/{namespace}/{name}/{provider}/{version}/annotations`).Handler(
+		httptransport.NewServer(
+			auth(addAnnotationEndpoint(svc)),
+			decodeAnnotationsRequest,
+			httptransport.EncodeJSONResponse,
+			append(
+				options,
+				httptransport.ServerBefore(extractMuxVars(varNamespace, varName, varProvider, varVersion)),
+				httptransport.ServerBefore(extractHeaders("Authorization")),
+			)...,
+		),
+	)
+
+	r.Methods("PUT").Path(`/{namespace}/{name}/{provider}/{version}/maturity`).Handler(
+		httptransport.NewServer(
+			auth(maturityEndpoint(svc)),
+			decodeMaturityRequest,
+			httptransport.EncodeJSONResponse,
+			append(
+				options,
+				httptransport.ServerBefore(extractMuxVars(varNamespace, varName, varProvider, varVersion)),
+				httptransport.ServerBefore(extractHeaders("Authorization")),
+			)...,
+		),
+	)
+
+	r.Methods("POST").Path(`/{namespace}/{name}/{provider}/{version}/approve`).Handler(
+		httptransport.NewServer(
+			auth(approveEndpoint(svc)),
+			decodeApproveRequest,
+			httptransport.EncodeJSONResponse,
+			append(
+				options,
+				httptransport.ServerBefore(extractMuxVars(varNamespace, varName, varProvider, varVersion)),
+				httptransport.ServerBefore(extractHeaders("Authorization")),
+			)...,
+		),
+	)
+
+	return r
+}
+
+// MakeArchiveHandler returns a fully initialized http.Handler serving the module archives of the ProxyStorage.
+// Instead of an API key, the signature of the download URL authorizes the download.
+func MakeArchiveHandler(storage *ProxyStorage, options ...httptransport.ServerOption) http.Handler {
+	r := mux.NewRouter().StrictSlash(true)
+
+	r.Methods("GET").Path(`/{namespace}/{name}/{provider}/{version}/archive.{format}`).Handler(
+		httptransport.NewServer(
+			archiveEndpoint(storage),
+			decodeArchiveRequest,
+			encodeArchiveResponse,
+			append(
+				options,
+				httptransport.ServerBefore(extractMuxVars(varNamespace, varName, varProvider, varVersion)),
+			)...,
+		),
+	)
+
 	return r
 }
 
@@ -71,11 +279,34 @@ func decodeListRequest(ctx context.Context, r *http.Request) (interface{}, error
 		return nil, errors.Wrap(ErrVarMissing, "provider")
 	}
 
-	return listRequest{
+	if err := validateModuleAddress(namespace, name, provider); err != nil {
+		return nil, err
+	}
+
+	req := listRequest{
 		namespace: namespace,
 		name:      name,
 		provider:  provider,
-	}, nil
+	}
+
+	if asOf := r.URL.Query().Get("as_of"); asOf != "" {
+		t, err := parseAsOf(asOf)
+		if err != nil {
+			return nil, errors.Wrap(ErrInvalidQuery, "as_of must be an RFC 3339 time or a Unix timestamp")
+		}
+		req.asOf = t
+	}
+
+	return req, nil
+}
+
+// parseAsOf parses the snapshot of the catalog to list, either as RFC 3339 time or Unix timestamp.
+func parseAsOf(s string) (time.Time, error) {
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(sec, 0).UTC(), nil
+	}
+
+	return time.Parse(time.RFC3339, s)
 }
 
 func decodeDownloadRequest(ctx context.Context, r *http.Request) (interface{}, error) {
@@ -99,6 +330,10 @@ func decodeDownloadRequest(ctx context.Context, r *http.Request) (interface{}, e
 		return nil, errors.Wrap(ErrVarMissing, "version")
 	}
 
+	if err := ValidateAddress(namespace, name, provider, version); err != nil {
+		return nil, err
+	}
+
 	return downloadRequest{
 		namespace: namespace,
 		name:      name,
@@ -107,23 +342,277 @@ func decodeDownloadRequest(ctx context.Context, r *http.Request) (interface{}, e
 	}, nil
 }
 
+func decodeArchiveRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	res, err := decodeDownloadRequest(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+
+	expires, err := strconv.ParseInt(r.URL.Query().Get("expires"), 10, 64)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidSignature, "expires must be a Unix timestamp")
+	}
+
+	download := res.(downloadRequest)
+	return archiveRequest{
+		namespace: download.namespace,
+		name:      download.name,
+		provider:  download.provider,
+		version:   download.version,
+		expires:   expires,
+		signature: r.URL.Query().Get("signature"),
+	}, nil
+}
+
+func decodeUploadRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	res, err := decodeDownloadRequest(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Archives announced to be too large are rejected before they are read
+	limit := maxArchiveSize(ctx)
+	if r.ContentLength > limit {
+		return nil, errors.Wrapf(ErrArchiveTooLarge, "archive must not be larger than %d bytes", limit)
+	}
+
+	if r.ContentLength == 0 {
+		return nil, errors.Wrap(ErrInvalidArchive, "body must contain the module archive")
+	}
+
+	digest, err := parseDigestHeader(r.Header.Get("Digest"))
+	if err != nil {
+		return nil, err
+	}
+
+	// The archive is streamed to the storage, which fails the upload if the body turns out to be invalid
+	download := res.(downloadRequest)
+	return uploadRequest{
+		namespace: download.namespace,
+		name:      download.name,
+		provider:  download.provider,
+		version:   download.version,
+		body:      newUploadBody(r.Body, limit, digest),
+	}, nil
+}
+
+func decodeAnnotationsRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	res, err := decodeDownloadRequest(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+
+	download := res.(downloadRequest)
+	req := annotationsRequest{
+		namespace: download.namespace,
+		name:      download.name,
+		provider:  download.provider,
+		version:   download.version,
+	}
+
+	if r.Method == http.MethodPost {
+		var annotation Annotation
+		if err := json.NewDecoder(io.LimitReader(r.Body, 2*maxAnnotationLength+1024)).Decode(&annotation); err != nil {
+			return nil, errors.Wrap(ErrInvalidAnnotation, err.Error())
+		}
+		req.annotation = &annotation
+	}
+
+	return req, nil
+}
+
+func decodeModulesRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	namespace, ok := ctx.Value(varNamespace).(string)
+	if !ok {
+		return nil, errors.Wrap(ErrVarMissing, "namespace")
+	}
+
+	v := &ValidationError{}
+	v.check("namespace", namespace, validateName)
+	if err := v.errorOrNil(); err != nil {
+		return nil, err
+	}
+
+	selector, err := ParseLabelSelector(r.URL.Query()["label"])
+	if err != nil {
+		return nil, err
+	}
+
+	return modulesRequest{
+		namespace: namespace,
+		selector:  selector,
+	}, nil
+}
+
+func decodeChangesRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	namespace, ok := ctx.Value(varNamespace).(string)
+	if !ok {
+		return nil, errors.Wrap(ErrVarMissing, "namespace")
+	}
+
+	v := &ValidationError{}
+	v.check("namespace", namespace, validateName)
+	if err := v.errorOrNil(); err != nil {
+		return nil, err
+	}
+
+	query := r.URL.Query()
+	if query.Get("from") == "" {
+		return nil, errors.Wrap(ErrInvalidQuery, "from is required")
+	}
+
+	from, err := parseAsOf(query.Get("from"))
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidQuery, "from must be an RFC 3339 time or a Unix timestamp")
+	}
+
+	to := time.Now().UTC()
+	if s := query.Get("to"); s != "" {
+		if to, err = parseAsOf(s); err != nil {
+			return nil, errors.Wrap(ErrInvalidQuery, "to must be an RFC 3339 time or a Unix timestamp")
+		}
+	}
+
+	if !from.Before(to) {
+		return nil, errors.Wrap(ErrInvalidQuery, "from must be before to")
+	}
+
+	return changesRequest{
+		namespace: namespace,
+		from:      from,
+		to:        to,
+	}, nil
+}
+
+func decodeLabelsRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	res, err := decodeListRequest(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+
+	list := res.(listRequest)
+	req := labelsRequest{
+		namespace: list.namespace,
+		name:      list.name,
+		provider:  list.provider,
+	}
+
+	if r.Method == http.MethodPut {
+		var body struct {
+			Labels Labels `json:"labels"`
+		}
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxLabels*(maxLabelValueLength+1024))).Decode(&body); err != nil {
+			return nil, errors.Wrap(ErrInvalidLabel, err.Error())
+		}
+		req.labels = body.Labels
+		if req.labels == nil {
+			req.labels = Labels{}
+		}
+	}
+
+	return req, nil
+}
+
+func decodeMaturityRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	res, err := decodeDownloadRequest(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+
+	download := res.(downloadRequest)
+
+	var body struct {
+		Maturity Maturity `json:"maturity"`
+		Reason   string   `json:"reason"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1024)).Decode(&body); err != nil {
+		return nil, errors.Wrap(ErrInvalidMaturity, err.Error())
+	}
+
+	return maturityRequest{
+		namespace: download.namespace,
+		name:      download.name,
+		provider:  download.provider,
+		version:   download.version,
+		maturity:  body.Maturity,
+		reason:    body.Reason,
+	}, nil
+}
+
+func decodeApproveRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	res, err := decodeDownloadRequest(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+
+	download := res.(downloadRequest)
+
+	// The body is optional and only names the approver
+	var body struct {
+		Approver string `json:"approver"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxAnnotationLength+1024)).Decode(&body); err != nil && err != io.EOF {
+		return nil, errors.Wrap(ErrInvalidAnnotation, err.Error())
+	}
+
+	return approveRequest{
+		namespace: download.namespace,
+		name:      download.name,
+		provider:  download.provider,
+		version:   download.version,
+		approver:  body.Approver,
+	}, nil
+}
+
+// retryAfter is the number of seconds clients are asked to wait before retrying requests the storage throttled or timed out.
+const retryAfter = "5"
+
 // ErrorEncoder translates domain specific errors to HTTP status codes.
+// Failures of the storage backend map to gateway errors, the transient ones with a Retry-After header.
 func ErrorEncoder(_ context.Context, err error, w http.ResponseWriter) {
+	status := http.StatusInternalServerError
 	switch errors.Cause(err) {
-	case ErrVarMissing:
-		w.WriteHeader(http.StatusBadRequest)
+	case ErrVarMissing, ErrInvalidQuery, ErrInvalidAddress:
+		status = http.StatusBadRequest
+	case ErrNotFound:
+		status = http.StatusNotFound
+	case ErrAlreadyExists:
+		status = http.StatusConflict
+	case ErrInvalidArchive:
+		status = http.StatusBadRequest
+	case ErrArchiveTooLarge:
+		status = http.StatusRequestEntityTooLarge
+	case ErrInvalidAnnotation, ErrInvalidLabel, ErrInvalidMaturity:
+		status = http.StatusBadRequest
 	case auth.ErrInvalidKey:
-		w.WriteHeader(http.StatusUnauthorized)
-	default:
-		w.WriteHeader(http.StatusInternalServerError)
+		status = http.StatusUnauthorized
+	case auth.ErrForbidden, ErrInvalidSignature, ErrReserved:
+		status = http.StatusForbidden
+	case ErrAccessDenied, ErrChecksumMismatch:
+		status = http.StatusBadGateway
+	case ErrThrottled, ErrOverloaded:
+		status = http.StatusServiceUnavailable
+		w.Header().Set("Retry-After", retryAfter)
+	case ErrTimeout:
+		status = http.StatusGatewayTimeout
+		w.Header().Set("Retry-After", retryAfter)
 	}
 
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+
+	var fields []FieldError
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		fields = validationErr.Fields
+	}
 
 	_ = json.NewEncoder(w).Encode(struct {
-		Error string `json:"error"`
+		Error  string       `json:"error"`
+		Fields []FieldError `json:"fields,omitempty"`
 	}{
-		Error: err.Error(),
+		Error:  err.Error(),
+		Fields: fields,
 	})
 }
 
@@ -154,6 +643,18 @@ func extractMuxVars(keys ...muxVar) httptransport.RequestFunc {
 func encodeDownloadResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
 	res := response.(downloadResponse)
 	w.Header().Set("X-Terraform-Get", res.url)
+	if res.digest != "" {
+		w.Header().Set("X-Boring-Registry-Digest", res.digest)
+	}
 	w.WriteHeader(http.StatusNoContent)
 	return nil
 }
+
+func encodeArchiveResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(archiveResponse)
+	defer res.body.Close()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	_, err := io.Copy(w, res.body)
+	return err
+}
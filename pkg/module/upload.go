@@ -0,0 +1,174 @@
+package module
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/TierMobility/boring-registry/pkg/auth"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/pkg/errors"
+)
+
+// DefaultMaxArchiveSize limits the size of module archives uploaded through the API.
+const DefaultMaxArchiveSize = 64 << 20
+
+// contextKeyMaxArchiveSize holds the limit of WithMaxArchiveSize.
+const contextKeyMaxArchiveSize contextKey = "max-archive-size"
+
+// WithMaxArchiveSize configures the size limit of module archives uploaded through the handler, which defaults to
+// DefaultMaxArchiveSize. Archives are streamed to the storage, uploads exceeding the limit fail once they reach it.
+func WithMaxArchiveSize(size int64) httptransport.ServerOption {
+	return httptransport.ServerBefore(func(ctx context.Context, _ *http.Request) context.Context {
+		return context.WithValue(ctx, contextKeyMaxArchiveSize, size)
+	})
+}
+
+// parseDigestHeader returns the SHA-256 digest of a Digest header (RFC 3230) in the format of ArchiveDigest,
+// e.g. "sha256:<hex>" for "SHA-256=<base64>". It's empty if the header doesn't contain a SHA-256 digest,
+// as digests of other algorithms are ignored.
+func parseDigestHeader(header string) (string, error) {
+	for _, instance := range strings.Split(header, ",") {
+		parts := strings.SplitN(strings.TrimSpace(instance), "=", 2)
+		if len(parts) != 2 || !strings.EqualFold(parts[0], "SHA-256") {
+			continue
+		}
+
+		sum, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil || len(sum) != sha256.Size {
+			return "", errors.Wrapf(ErrInvalidArchive, "invalid SHA-256 digest %q", parts[1])
+		}
+
+		return "sha256:" + hex.EncodeToString(sum), nil
+	}
+
+	return "", nil
+}
+
+// uploadBody streams an uploaded archive and fails once the archive exceeds the size limit,
+// turns out to be empty or doesn't match the digest of the Digest header.
+type uploadBody struct {
+	r      io.Reader
+	limit  int64
+	n      int64
+	digest string
+	hash   hash.Hash
+}
+
+func newUploadBody(r io.Reader, limit int64, digest string) *uploadBody {
+	return &uploadBody{r: r, limit: limit, digest: digest, hash: sha256.New()}
+}
+
+// Digest returns the digest of the Digest header, which is empty if the client didn't send one.
+func (b *uploadBody) Digest() string {
+	return b.digest
+}
+
+func (b *uploadBody) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	b.n += int64(n)
+	b.hash.Write(p[:n])
+
+	switch {
+	case b.n > b.limit:
+		return n, errors.Wrapf(ErrArchiveTooLarge, "archive must not be larger than %d bytes", b.limit)
+	case err == io.EOF && b.n == 0:
+		return n, errors.Wrap(ErrInvalidArchive, "body must contain the module archive")
+	case err == io.EOF && b.digest != "":
+		// The archive was corrupted on the way, e.g. by a proxy, so it's rejected before it's published
+		if digest := hashDigest(b.hash); digest != b.digest {
+			return n, errors.Wrapf(ErrInvalidArchive, "archive has digest %s, the Digest header %s", digest, b.digest)
+		}
+	case err != nil && err != io.EOF:
+		return n, errors.Wrap(ErrInvalidArchive, err.Error())
+	}
+
+	return n, err
+}
+
+// maxArchiveSize returns the size limit of uploaded module archives.
+func maxArchiveSize(ctx context.Context) int64 {
+	if size, ok := ctx.Value(contextKeyMaxArchiveSize).(int64); ok && size > 0 {
+		return size
+	}
+
+	return DefaultMaxArchiveSize
+}
+
+type uploaderMiddleware struct {
+	Service
+	keys      []string
+	workloads WorkloadPermissions
+	reserved  Reserved
+	admins    []string
+}
+
+// UploaderOption provides additional options for the UploaderMiddleware.
+type UploaderOption func(*uploaderMiddleware)
+
+// WithUploaderWorkloads also allows workloads to upload module versions to the namespaces granted to them.
+func WithUploaderWorkloads(permissions WorkloadPermissions) UploaderOption {
+	return func(mw *uploaderMiddleware) {
+		mw.workloads = permissions
+	}
+}
+
+// WithUploaderReserved rejects uploads to reserved namespaces and names with ErrReserved,
+// unless the client authenticates with one of the admin API keys.
+func WithUploaderReserved(reserved Reserved, adminKeys []string) UploaderOption {
+	return func(mw *uploaderMiddleware) {
+		mw.reserved = reserved
+		mw.admins = adminKeys
+	}
+}
+
+// UploaderMiddleware only allows clients with one of the given API keys to upload module versions.
+// Without keys or workloads, modules can't be uploaded through the API at all.
+func UploaderMiddleware(keys []string, options ...UploaderOption) Middleware {
+	return func(next Service) Service {
+		mw := &uploaderMiddleware{
+			Service: next,
+			keys:    keys,
+		}
+
+		for _, option := range options {
+			option(mw)
+		}
+
+		return mw
+	}
+}
+
+func (mw *uploaderMiddleware) UploadModule(ctx context.Context, namespace, name, provider, version string, body io.Reader) (Module, error) {
+	if !hasBearerKey(ctx, mw.keys) {
+		if id := workloadID(ctx); id == "" || !mw.workloads.allowed(namespace, id, true) {
+			return Module{}, auth.ErrForbidden
+		}
+	}
+
+	// Reserved namespaces and names can only be claimed by administrators
+	if !hasBearerKey(ctx, mw.admins) {
+		if err := mw.reserved.Check(namespace, name); err != nil {
+			return Module{}, err
+		}
+	}
+
+	return mw.Service.UploadModule(ctx, namespace, name, provider, version, body)
+}
+
+// hasBearerKey returns true if the request is authorized with one of the API keys.
+func hasBearerKey(ctx context.Context, keys []string) bool {
+	for _, key := range keys {
+		if fmt.Sprintf("Bearer %s", key) == ctx.Value(httptransport.ContextKeyRequestAuthorization) {
+			return true
+		}
+	}
+
+	return false
+}
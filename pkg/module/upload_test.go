@@ -0,0 +1,91 @@
+package module
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/TierMobility/boring-registry/pkg/auth"
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpload(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	storage := NewInmemStorage()
+	handler := MakeHandler(
+		UploaderMiddleware([]string{"uploader", "admin"}, WithUploaderReserved(Reserved{"hashicorp"}, []string{"admin"}))(NewService(storage)),
+		endpoint.Chain(auth.Middleware("uploader", "admin", "reader")),
+		httptransport.ServerBefore(httptransport.PopulateRequestContext),
+		httptransport.ServerErrorEncoder(ErrorEncoder),
+		WithMaxArchiveSize(8),
+	)
+
+	do := func(path, token, body, digest string, chunked bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		if digest != "" {
+			req.Header.Set("Digest", digest)
+		}
+		if chunked {
+			req.ContentLength = -1
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	testCases := []struct {
+		name         string
+		path         string
+		token        string
+		body         string
+		digest       string
+		chunked      bool
+		expectedCode int
+	}{
+		{name: "unauthenticated", path: "/tier/vpc/aws/1.0.0/upload", token: "unknown", body: "data", expectedCode: http.StatusUnauthorized},
+		{name: "reader can't upload", path: "/tier/vpc/aws/1.0.0/upload", token: "reader", body: "data", expectedCode: http.StatusForbidden},
+		{name: "invalid address", path: "/tier/vpc/AWS/1.0.0/upload", token: "uploader", body: "data", expectedCode: http.StatusBadRequest},
+		{name: "empty archive", path: "/tier/vpc/aws/1.0.0/upload", token: "uploader", expectedCode: http.StatusBadRequest},
+		{name: "too large", path: "/tier/vpc/aws/1.0.0/upload", token: "uploader", body: "large data", expectedCode: http.StatusRequestEntityTooLarge},
+		{name: "too large without length", path: "/tier/vpc/aws/1.0.0/upload", token: "uploader", body: "large data", chunked: true, expectedCode: http.StatusRequestEntityTooLarge},
+		{name: "corrupted archive", path: "/tier/vpc/aws/1.0.0/upload", token: "uploader", body: "data", digest: digestHeader("date"), expectedCode: http.StatusBadRequest},
+		{name: "invalid digest", path: "/tier/vpc/aws/1.0.0/upload", token: "uploader", body: "data", digest: "SHA-256=data", expectedCode: http.StatusBadRequest},
+		{name: "uploader", path: "/tier/vpc/aws/1.0.0/upload", token: "uploader", body: "data", digest: "MD5=jXd/OF09/siBXSD3SWAm3A==, " + digestHeader("data"), expectedCode: http.StatusCreated},
+		{name: "existing version", path: "/tier/vpc/aws/1.0.0/upload", token: "uploader", body: "data", expectedCode: http.StatusConflict},
+		{name: "reserved namespace", path: "/hashicorp/vpc/aws/1.0.0/upload", token: "uploader", body: "data", expectedCode: http.StatusForbidden},
+		{name: "reader can't claim reserved namespace", path: "/hashicorp/vpc/aws/1.0.0/upload", token: "reader", body: "data", expectedCode: http.StatusForbidden},
+		{name: "admin claims reserved namespace", path: "/hashicorp/vpc/aws/1.0.0/upload", token: "admin", body: "data", expectedCode: http.StatusCreated},
+	}
+
+	for _, tc := range testCases {
+		rec := do(tc.path, tc.token, tc.body, tc.digest, tc.chunked)
+		assert.Equal(tc.expectedCode, rec.Code, tc.name)
+
+		if rec.Code == http.StatusCreated {
+			var res Module
+			assert.NoError(json.NewDecoder(rec.Body).Decode(&res))
+			assert.Equal(ArchiveDigest([]byte("data")), res.Digest)
+		}
+	}
+
+	_, err := storage.GetModule(context.Background(), "tier", "vpc", "aws", "1.0.0")
+	assert.NoError(err)
+	_, err = storage.GetModule(context.Background(), "hashicorp", "vpc", "aws", "1.0.0")
+	assert.NoError(err)
+}
+
+// digestHeader returns the Digest header of data.
+func digestHeader(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,98 @@
+package module
+
+import (
+	"context"
+
+	"github.com/TierMobility/boring-registry/pkg/auth"
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+)
+
+// WorkloadGrant lists the patterns of the SPIFFE IDs of workloads allowed to access the modules of a namespace,
+// see auth.MatchSPIFFEID. Workloads allowed to upload are allowed to download as well.
+type WorkloadGrant struct {
+	Upload   []string
+	Download []string
+}
+
+// WorkloadPermissions grants workloads authenticated by their SPIFFE ID access to namespaces.
+// It is keyed by namespace, workloads can't access namespaces without an entry.
+// Requests with a Bearer token aren't restricted, even if the client presents an X.509 SVID.
+type WorkloadPermissions map[string]WorkloadGrant
+
+func (p WorkloadPermissions) allowed(namespace, id string, upload bool) bool {
+	grant := p[namespace]
+
+	for _, pattern := range grant.Upload {
+		if auth.MatchSPIFFEID(pattern, id) {
+			return true
+		}
+	}
+
+	if upload {
+		return false
+	}
+
+	for _, pattern := range grant.Download {
+		if auth.MatchSPIFFEID(pattern, id) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// workloadID returns the SPIFFE ID of a request authenticated by an X.509 SVID alone, or an empty string otherwise.
+func workloadID(ctx context.Context) string {
+	if authorization, _ := ctx.Value(httptransport.ContextKeyRequestAuthorization).(string); authorization != "" {
+		return ""
+	}
+
+	return auth.SPIFFEID(ctx)
+}
+
+// WorkloadPermissionsMiddleware enforces the permissions of workloads on the endpoints listing, downloading and uploading modules.
+func WorkloadPermissionsMiddleware(permissions WorkloadPermissions) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			id := workloadID(ctx)
+			if id == "" {
+				return next(ctx, request)
+			}
+
+			var namespace string
+			var upload bool
+
+			switch req := request.(type) {
+			case listRequest:
+				namespace = req.namespace
+			case downloadRequest:
+				namespace = req.namespace
+			case docsRequest:
+				namespace = req.namespace
+			case feedRequest:
+				namespace = req.namespace
+			case modulesRequest:
+				namespace = req.namespace
+			case changesRequest:
+				namespace = req.namespace
+			case searchRequest:
+				// Search results only contain the namespaces granted to the workload
+				req.filter = andFilter(req.filter, func(m Module) bool {
+					return permissions.allowed(m.Namespace, id, false)
+				})
+				return next(ctx, req)
+			case uploadRequest:
+				namespace, upload = req.namespace, true
+			default:
+				return next(ctx, request)
+			}
+
+			if !permissions.allowed(namespace, id, upload) {
+				return nil, auth.ErrForbidden
+			}
+
+			return next(ctx, request)
+		}
+	}
+}
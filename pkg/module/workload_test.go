@@ -0,0 +1,119 @@
+package module
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/TierMobility/boring-registry/pkg/auth"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/stretchr/testify/assert"
+)
+
+func workloadContext(id, authorization string) context.Context {
+	u, _ := url.Parse(id)
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{URIs: []*url.URL{u}}}}}
+
+	ctx := auth.PopulateRequestContext(context.Background(), r)
+	if authorization != "" {
+		ctx = context.WithValue(ctx, httptransport.ContextKeyRequestAuthorization, authorization)
+	}
+	return ctx
+}
+
+func TestWorkloadPermissionsMiddleware(t *testing.T) {
+	t.Parallel()
+
+	permissions := WorkloadPermissions{
+		"tier": {
+			Upload:   []string{"spiffe://example.org/ci/*"},
+			Download: []string{"spiffe://example.org/deploy"},
+		},
+	}
+
+	testCases := []struct {
+		name        string
+		ctx         context.Context
+		request     interface{}
+		expectError bool
+	}{
+		{
+			name:    "download",
+			ctx:     workloadContext("spiffe://example.org/deploy", ""),
+			request: downloadRequest{namespace: "tier", name: "vpc", provider: "aws", version: "1.0.0"},
+		},
+		{
+			name:    "download by uploader",
+			ctx:     workloadContext("spiffe://example.org/ci/runner", ""),
+			request: listRequest{namespace: "tier", name: "vpc", provider: "aws"},
+		},
+		{
+			name:    "upload",
+			ctx:     workloadContext("spiffe://example.org/ci/runner", ""),
+			request: uploadRequest{namespace: "tier", name: "vpc", provider: "aws", version: "1.0.0"},
+		},
+		{
+			name:        "upload by downloader",
+			ctx:         workloadContext("spiffe://example.org/deploy", ""),
+			request:     uploadRequest{namespace: "tier", name: "vpc", provider: "aws", version: "1.0.0"},
+			expectError: true,
+		},
+		{
+			name:        "namespace without grant",
+			ctx:         workloadContext("spiffe://example.org/deploy", ""),
+			request:     modulesRequest{namespace: "payments"},
+			expectError: true,
+		},
+		{
+			name:    "request with token",
+			ctx:     workloadContext("spiffe://example.org/deploy", "Bearer secret"),
+			request: modulesRequest{namespace: "payments"},
+		},
+		{
+			name:    "request without svid",
+			ctx:     context.Background(),
+			request: modulesRequest{namespace: "payments"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := WorkloadPermissionsMiddleware(permissions)(func(ctx context.Context, request interface{}) (interface{}, error) {
+				return nil, nil
+			})(tc.ctx, tc.request)
+
+			if tc.expectError {
+				assert.Equal(t, auth.ErrForbidden, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestUploaderMiddlewareWorkloads(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	permissions := WorkloadPermissions{"tier": {Upload: []string{"spiffe://example.org/ci/*"}}}
+	svc := UploaderMiddleware([]string{"uploader"}, WithUploaderWorkloads(permissions))(NewService(NewInmemStorage()))
+
+	_, err := svc.UploadModule(workloadContext("spiffe://example.org/ci/runner", ""), "tier", "vpc", "aws", "1.0.0", strings.NewReader("data"))
+	assert.NoError(err)
+
+	_, err = svc.UploadModule(workloadContext("spiffe://example.org/ci/runner", ""), "payments", "vpc", "aws", "1.0.0", strings.NewReader("data"))
+	assert.Equal(auth.ErrForbidden, err)
+
+	// Tokens aren't combined with SVIDs
+	_, err = svc.UploadModule(workloadContext("spiffe://example.org/ci/runner", "Bearer other"), "tier", "vpc", "aws", "1.1.0", strings.NewReader("data"))
+	assert.Equal(auth.ErrForbidden, err)
+}
@@ -0,0 +1,38 @@
+package provider
+
+import (
+	"context"
+
+	"github.com/TierMobility/boring-registry/pkg/auth"
+	"github.com/go-kit/kit/endpoint"
+)
+
+// AuthorizationMiddleware asks the authorizer whether a client may list or download the versions of a provider.
+func AuthorizationMiddleware(authorizer auth.Authorizer) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			var action, resource string
+
+			switch req := request.(type) {
+			case listRequest:
+				action, resource = auth.ActionList, req.namespace+"/"+req.name
+			case downloadRequest:
+				action, resource = auth.ActionDownload, req.namespace+"/"+req.name+"/"+req.version
+			default:
+				return next(ctx, request)
+			}
+
+			err := authorizer.Authorize(ctx, auth.AuthorizationRequest{
+				Subject:      auth.Identity(ctx),
+				Action:       action,
+				ResourceType: auth.ResourceProvider,
+				Resource:     resource,
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			return next(ctx, request)
+		}
+	}
+}
@@ -2,6 +2,15 @@ package provider
 
 import "errors"
 
+// Storage errors.
+var (
+	ErrNotFound = errors.New("failed to locate provider")
+
+	ErrAccessDenied = errors.New("storage denied access")
+	ErrThrottled    = errors.New("storage is throttling requests")
+	ErrTimeout      = errors.New("storage request timed out")
+)
+
 // Transport errors.
 var (
 	ErrVarMissing = errors.New("variable missing")
@@ -0,0 +1,73 @@
+package provider
+
+import (
+	"context"
+	"strings"
+
+	"github.com/TierMobility/boring-registry/pkg/core"
+)
+
+type rewriteMiddleware struct {
+	next  Service
+	rules map[string]string
+}
+
+// RewriteMiddleware serves requests for selected providers from other providers, e.g. public providers from their vetted internal mirrors.
+// Rules map a namespace to another namespace or a namespace/name to another namespace/name,
+// provider rules take precedence over namespace rules. The served providers keep the coordinates of the request.
+func RewriteMiddleware(rules map[string]string) Middleware {
+	return func(next Service) Service {
+		return &rewriteMiddleware{
+			next:  next,
+			rules: rules,
+		}
+	}
+}
+
+func (mw *rewriteMiddleware) GetProvider(ctx context.Context, namespace, name, version, os, arch string) (core.Provider, error) {
+	ns, n := mw.rewrite(namespace, name)
+	if ns == namespace && n == name {
+		return mw.next.GetProvider(ctx, namespace, name, version, os, arch)
+	}
+
+	res, err := mw.next.GetProvider(ctx, ns, n, version, os, arch)
+	if err != nil {
+		return core.Provider{}, err
+	}
+
+	res.Namespace, res.Name = namespace, name
+	return res, nil
+}
+
+func (mw *rewriteMiddleware) ListProviderVersions(ctx context.Context, namespace, name string) ([]core.ProviderVersion, error) {
+	ns, n := mw.rewrite(namespace, name)
+	if ns == namespace && n == name {
+		return mw.next.ListProviderVersions(ctx, namespace, name)
+	}
+
+	res, err := mw.next.ListProviderVersions(ctx, ns, n)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range res {
+		res[i].Namespace, res[i].Name = namespace, name
+	}
+
+	return res, nil
+}
+
+// rewrite returns the provider a request is served from.
+func (mw *rewriteMiddleware) rewrite(namespace, name string) (string, string) {
+	if to, ok := mw.rules[namespace+"/"+name]; ok {
+		if parts := strings.Split(to, "/"); len(parts) == 2 {
+			return parts[0], parts[1]
+		}
+	}
+
+	if to, ok := mw.rules[namespace]; ok {
+		return to, name
+	}
+
+	return namespace, name
+}
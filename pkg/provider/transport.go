@@ -109,18 +109,34 @@ func decodeDownloadRequest(ctx context.Context, r *http.Request) (interface{}, e
 	}, nil
 }
 
+// retryAfter is the number of seconds clients are asked to wait before retrying requests the storage throttled or timed out.
+const retryAfter = "5"
+
 // ErrorEncoder translates domain specific errors to HTTP status codes.
+// Failures of the storage backend map to gateway errors, the transient ones with a Retry-After header.
 func ErrorEncoder(_ context.Context, err error, w http.ResponseWriter) {
+	status := http.StatusInternalServerError
 	switch errors.Cause(err) {
 	case ErrVarMissing:
-		w.WriteHeader(http.StatusBadRequest)
+		status = http.StatusBadRequest
+	case ErrNotFound:
+		status = http.StatusNotFound
 	case auth.ErrInvalidKey:
-		w.WriteHeader(http.StatusUnauthorized)
-	default:
-		w.WriteHeader(http.StatusInternalServerError)
+		status = http.StatusUnauthorized
+	case auth.ErrForbidden:
+		status = http.StatusForbidden
+	case ErrAccessDenied:
+		status = http.StatusBadGateway
+	case ErrThrottled:
+		status = http.StatusServiceUnavailable
+		w.Header().Set("Retry-After", retryAfter)
+	case ErrTimeout:
+		status = http.StatusGatewayTimeout
+		w.Header().Set("Retry-After", retryAfter)
 	}
 
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
 
 	_ = json.NewEncoder(w).Encode(struct {
 		Error string `json:"error"`
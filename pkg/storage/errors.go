@@ -1,11 +1,27 @@
 package storage
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+
+	"cloud.google.com/go/storage"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"google.golang.org/api/googleapi"
+
+	"github.com/TierMobility/boring-registry/pkg/provider"
+)
 
 // Storage errors.
 var (
 	ErrAlreadyExists = errors.New("provider already exists")
-	ErrNotFound      = errors.New("failed to locate provider")
+	ErrNotFound      = provider.ErrNotFound
+	ErrGetFailed     = errors.New("failed to get provider")
 	ErrListFailed    = errors.New("failed to list provider versions")
 )
 
@@ -13,3 +29,87 @@ var (
 var (
 	ErrVarMissing = errors.New("variable missing")
 )
+
+// storageError wraps an error returned by a storage backend.
+// It matches the registry error kind and the reason of the backend error with errors.Is,
+// errors.Cause returns the reason if the backend error is known and the kind otherwise.
+type storageError struct {
+	kind   error
+	reason error
+	err    error
+}
+
+func wrapStorageError(kind, err error) error {
+	return &storageError{kind: kind, reason: storageErrorReason(err), err: err}
+}
+
+func (e *storageError) Error() string {
+	return fmt.Sprintf("%s: %s", e.kind, e.err)
+}
+
+func (e *storageError) Is(target error) bool {
+	return target == e.kind || (e.reason != nil && target == e.reason)
+}
+
+func (e *storageError) Unwrap() error {
+	return e.err
+}
+
+func (e *storageError) Cause() error {
+	if e.reason != nil {
+		return e.reason
+	}
+	return e.kind
+}
+
+// storageErrorReason maps the error codes of the storage backends to provider errors, it returns nil for unknown errors.
+func storageErrorReason(err error) error {
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		switch awsErr.Code() {
+		case "NotFound", s3.ErrCodeNoSuchKey:
+			return provider.ErrNotFound
+		case "AccessDenied", "Forbidden":
+			return provider.ErrAccessDenied
+		case "SlowDown", "Throttling", "RequestLimitExceeded":
+			return provider.ErrThrottled
+		case "RequestTimeout", request.ErrCodeResponseTimeout:
+			return provider.ErrTimeout
+		}
+
+		// The SDK doesn't support unwrapping, e.g. timeouts of the HTTP client are only reachable as the original error
+		if orig := awsErr.OrigErr(); orig != nil {
+			return storageErrorReason(orig)
+		}
+	}
+
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.Code {
+		case http.StatusNotFound:
+			return provider.ErrNotFound
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return provider.ErrAccessDenied
+		case http.StatusTooManyRequests:
+			return provider.ErrThrottled
+		case http.StatusRequestTimeout:
+			return provider.ErrTimeout
+		}
+	}
+
+	switch {
+	case errors.Is(err, storage.ErrObjectNotExist), errors.Is(err, os.ErrNotExist):
+		return provider.ErrNotFound
+	case errors.Is(err, os.ErrPermission):
+		return provider.ErrAccessDenied
+	case errors.Is(err, context.DeadlineExceeded):
+		return provider.ErrTimeout
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return provider.ErrTimeout
+	}
+
+	return nil
+}
@@ -0,0 +1,28 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	pkgerrors "github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/TierMobility/boring-registry/pkg/provider"
+)
+
+func TestLocalStorage_Errors(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	s, err := NewLocalStorage(t.TempDir())
+	assert.NoError(err)
+
+	_, err = s.GetProvider(context.Background(), "tier", "dummy", "1.0.0", "linux", "amd64")
+	assert.Equal(provider.ErrNotFound, pkgerrors.Cause(err))
+	assert.True(errors.Is(err, ErrGetFailed))
+
+	_, err = s.ListProviderVersions(context.Background(), "tier", "dummy")
+	assert.Equal(provider.ErrNotFound, pkgerrors.Cause(err))
+
+}
@@ -114,7 +114,7 @@ func (s *GCSStorage) ListProviderVersions(ctx context.Context, namespace, name s
 			break
 		}
 		if err != nil {
-			return nil, err
+			return nil, wrapStorageError(ErrListFailed, err)
 		}
 
 		provider, err := core.NewProviderFromArchive(attrs.Name)
@@ -128,7 +128,7 @@ func (s *GCSStorage) ListProviderVersions(ctx context.Context, namespace, name s
 	result := collection.List()
 
 	if len(result) == 0 {
-		return nil, fmt.Errorf("no provider versions found for %s/%s", namespace, name)
+		return nil, errors.Wrapf(ErrNotFound, "no provider versions found for %s/%s", namespace, name)
 	}
 
 	return result, nil
@@ -141,13 +141,13 @@ func (s *GCSStorage) generateAPIURL(key string) (string, error) {
 func (s *GCSStorage) download(ctx context.Context, path string) ([]byte, error) {
 	r, err := s.sc.Bucket(s.bucket).Object(path).NewReader(ctx)
 	if err != nil {
-		return nil, err
+		return nil, errors.Wrap(wrapStorageError(ErrGetFailed, err), path)
 	}
 	defer r.Close()
 
 	data, err := ioutil.ReadAll(r)
 	if err != nil {
-		return nil, err
+		return nil, errors.Wrap(wrapStorageError(ErrGetFailed, err), path)
 	}
 
 	return data, nil
@@ -184,7 +184,7 @@ func (s *GCSStorage) signedURL(ctx context.Context, v string) (string, error) {
 			SignBytes: func(b []byte) ([]byte, error) {
 				req := &credentialspb.SignBlobRequest{
 					Payload: b,
-					Name:    s.serviceAccount,
+					Name:    fmt.Sprintf("projects/-/serviceAccounts/%s", s.serviceAccount),
 				}
 				resp, err := c.SignBlob(ctx, req)
 				if err != nil {
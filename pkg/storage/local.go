@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/TierMobility/boring-registry/pkg/core"
+	"github.com/pkg/errors"
+)
+
+// LocalStorage is a Storage implementation backed by a directory on the local disk.
+// It uses the same layout as the bucket storages, so a bucket can be synced to the directory and back.
+// LocalStorage implements provider.Storage
+type LocalStorage struct {
+	dir     string
+	baseURL string
+}
+
+// GetProvider retrieves information about a provider from the local storage.
+func (s *LocalStorage) GetProvider(ctx context.Context, namespace, name, version, os, arch string) (core.Provider, error) {
+	archivePath, shasumPath, shasumSigPath, err := internalProviderPath(".", namespace, name, version, os, arch)
+	if err != nil {
+		return core.Provider{}, err
+	}
+
+	pathSigningKeys := signingKeysPath(".", namespace)
+
+	if _, err := s.read(archivePath); err != nil {
+		return core.Provider{}, err
+	}
+
+	signingKeysRaw, err := s.read(pathSigningKeys)
+	if err != nil {
+		return core.Provider{}, errors.Wrap(err, pathSigningKeys)
+	}
+	var signingKey core.GPGPublicKey
+	if err := json.Unmarshal(signingKeysRaw, &signingKey); err != nil {
+		return core.Provider{}, err
+	}
+
+	shasumBytes, err := s.read(shasumPath)
+	if err != nil {
+		return core.Provider{}, err
+	}
+
+	shasum, err := readSHASums(bytes.NewReader(shasumBytes), path.Base(archivePath))
+	if err != nil {
+		return core.Provider{}, err
+	}
+
+	return core.Provider{
+		Namespace:           namespace,
+		Name:                name,
+		Version:             version,
+		OS:                  os,
+		Arch:                arch,
+		Shasum:              shasum,
+		Filename:            path.Base(archivePath),
+		DownloadURL:         s.url(archivePath),
+		SHASumsURL:          s.url(shasumPath),
+		SHASumsSignatureURL: s.url(shasumSigPath),
+		SigningKeys: core.SigningKeys{
+			GPGPublicKeys: []core.GPGPublicKey{
+				signingKey,
+			},
+		},
+	}, nil
+}
+
+func (s *LocalStorage) ListProviderVersions(ctx context.Context, namespace, name string) ([]core.ProviderVersion, error) {
+	prefix, err := providerStoragePrefix(".", internalProviderType, "", namespace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := ioutil.ReadDir(filepath.Join(s.dir, filepath.FromSlash(prefix)))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, wrapStorageError(ErrListFailed, err)
+	}
+
+	collection := NewCollection()
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		provider, err := core.NewProviderFromArchive(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		collection.Add(provider)
+	}
+
+	result := collection.List()
+
+	if len(result) == 0 {
+		return nil, errors.Wrapf(ErrNotFound, "no provider versions found for %s/%s", namespace, name)
+	}
+
+	return result, nil
+}
+
+func (s *LocalStorage) read(key string) ([]byte, error) {
+	b, err := ioutil.ReadFile(filepath.Join(s.dir, filepath.FromSlash(key)))
+	if err != nil {
+		return nil, errors.Wrap(wrapStorageError(ErrGetFailed, err), key)
+	}
+
+	return b, nil
+}
+
+// url returns the URL a file of the local storage is served under.
+func (s *LocalStorage) url(key string) string {
+	return s.baseURL + "/" + key
+}
+
+// LocalStorageOption provides additional options for the LocalStorage.
+type LocalStorageOption func(*LocalStorage)
+
+// WithLocalStorageBaseURL configures the URL the storage directory is served under.
+func WithLocalStorageBaseURL(baseURL string) LocalStorageOption {
+	return func(s *LocalStorage) {
+		s.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// NewLocalStorage returns a fully initialized local storage.
+func NewLocalStorage(dir string, options ...LocalStorageOption) (*LocalStorage, error) {
+	s := &LocalStorage{
+		dir: dir,
+	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, errors.Wrap(err, "failed to create storage directory")
+	}
+
+	return s, nil
+}
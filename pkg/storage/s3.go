@@ -6,10 +6,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/TierMobility/boring-registry/pkg/core"
+	"net/http"
 	"path"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
@@ -115,13 +118,13 @@ func (s *S3Storage) ListProviderVersions(ctx context.Context, namespace, name st
 	}
 
 	if err := s.s3.ListObjectsV2PagesWithContext(ctx, input, fn); err != nil {
-		return nil, errors.Wrap(ErrListFailed, err.Error())
+		return nil, wrapStorageError(ErrListFailed, err)
 	}
 
 	result := collection.List()
 
 	if len(result) == 0 {
-		return nil, fmt.Errorf("no provider versions found for %s/%s", namespace, name)
+		return nil, errors.Wrapf(ErrNotFound, "no provider versions found for %s/%s", namespace, name)
 	}
 
 	return result, nil
@@ -153,7 +156,7 @@ func (s *S3Storage) download(ctx context.Context, path string) ([]byte, error) {
 	}
 
 	if _, err := s.downloader.DownloadWithContext(ctx, buf, input); err != nil {
-		return nil, errors.Wrapf(err, "failed to download: %s", path)
+		return nil, errors.Wrap(wrapStorageError(ErrGetFailed, err), path)
 	}
 
 	return buf.Bytes(), nil
@@ -198,9 +201,41 @@ func WithS3StoragePathStyle(pathStyle bool) S3StorageOption {
 	}
 }
 
+// WithS3HTTPClient configures the HTTP client of all requests to S3, e.g. to tune its connection pool.
+func WithS3HTTPClient(client *http.Client) S3StorageOption {
+	return func(s *S3Storage) {
+		if client != nil {
+			s.s3.Client.Config.HTTPClient = client
+		}
+	}
+}
+
+// WithS3Credentials configures the credentials of all requests to S3 instead of the default credential chain.
+// The credentials are refreshed when they expire, so they have to be backed by a provider that can retrieve them again.
+func WithS3Credentials(creds *credentials.Credentials) S3StorageOption {
+	return func(s *S3Storage) {
+		if creds != nil {
+			s.s3.Client.Config.Credentials = creds
+		}
+	}
+}
+
+// WithS3MaxRetries configures how often failed requests to S3 are retried, a negative value keeps the SDK default.
+func WithS3MaxRetries(retries int) S3StorageOption {
+	return func(s *S3Storage) {
+		if retries >= 0 {
+			s.s3.Client.Config.MaxRetries = aws.Int(retries)
+			s.s3.Client.Retryer = client.DefaultRetryer{NumMaxRetries: retries}
+		}
+	}
+}
+
 // NewS3Storage returns a fully initialized S3 storage.
 func NewS3Storage(bucket string, options ...S3StorageOption) (*S3Storage, error) {
-	sess, err := session.NewSession()
+	// Shared config is enabled, so profiles with credential_process or web_identity_token_file work without AWS_SDK_LOAD_CONFIG
+	sess, err := session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,256 @@
+'use strict';
+
+// The UI is served by the registry itself, so the module endpoints are on the same origin.
+const modulesURL = '/v1/modules';
+const tokenKey = 'boring-registry-token';
+const maxPages = 50;
+
+const content = document.getElementById('content');
+const login = document.getElementById('login');
+const logout = document.getElementById('logout');
+const search = document.getElementById('search');
+
+class UnauthorizedError extends Error {}
+
+async function api(path) {
+  const headers = { Accept: 'application/json' };
+  const token = sessionStorage.getItem(tokenKey);
+  if (token) {
+    headers.Authorization = `Bearer ${token}`;
+  }
+
+  const res = await fetch(modulesURL + path, { headers });
+  if (res.status === 401) {
+    throw new UnauthorizedError(`${res.status} ${res.statusText}`);
+  }
+  if (res.status === 404) {
+    return null;
+  }
+  if (!res.ok) {
+    throw new Error(`${res.status} ${res.statusText}`);
+  }
+  return res.json();
+}
+
+// allModules follows the pagination of the listing of all modules.
+async function allModules(query) {
+  const modules = [];
+  let offset = 0;
+  for (let page = 0; page < maxPages; page++) {
+    const params = new URLSearchParams(query);
+    params.set('limit', '100');
+    params.set('offset', String(offset));
+    const res = await api(`/?${params}`);
+    modules.push(...res.modules);
+    if (res.meta.next_offset === undefined) {
+      break;
+    }
+    offset = res.meta.next_offset;
+  }
+  return modules;
+}
+
+function el(tag, attrs, ...children) {
+  const node = document.createElement(tag);
+  for (const [key, value] of Object.entries(attrs || {})) {
+    node.setAttribute(key, value);
+  }
+  for (const child of children) {
+    node.append(child instanceof Node ? child : String(child));
+  }
+  return node;
+}
+
+function link(parts, text) {
+  return el('a', { href: '#/' + parts.map(encodeURIComponent).join('/') }, text);
+}
+
+function table(headers, rows) {
+  return el('table', {},
+    el('thead', {}, el('tr', {}, ...headers.map((h) => el('th', {}, h)))),
+    el('tbody', {}, ...rows.map((row) => el('tr', {}, ...row.map((cell) => el('td', {}, cell))))));
+}
+
+function published(value) {
+  return value ? new Date(value).toISOString().slice(0, 10) : '';
+}
+
+function render(title, ...children) {
+  document.title = title ? `${title} - Boring Registry` : 'Boring Registry';
+  content.replaceChildren(el('h1', {}, title || 'Namespaces'), ...children);
+}
+
+function source(namespace, name, provider, version) {
+  const text = [
+    `module "${name}" {`,
+    `  source  = "${location.host}/${namespace}/${name}/${provider}"`,
+    `  version = "${version}"`,
+    '}',
+  ].join('\n');
+
+  const node = document.getElementById('source').content.cloneNode(true);
+  node.querySelector('code').textContent = text;
+  const button = node.querySelector('button');
+  button.addEventListener('click', async () => {
+    await navigator.clipboard.writeText(text);
+    button.textContent = 'Copied';
+    setTimeout(() => { button.textContent = 'Copy'; }, 2000);
+  });
+  return node;
+}
+
+// compareVersions orders semantic versions, pre-releases before their release.
+function compareVersions(a, b) {
+  const [coreA, preA] = a.split('-', 2);
+  const [coreB, preB] = b.split('-', 2);
+  const partsA = coreA.split('.').map(Number);
+  const partsB = coreB.split('.').map(Number);
+  for (let i = 0; i < 3; i++) {
+    if ((partsA[i] || 0) !== (partsB[i] || 0)) {
+      return (partsA[i] || 0) - (partsB[i] || 0);
+    }
+  }
+  if (preA === preB) {
+    return 0;
+  }
+  if (preA === undefined) {
+    return 1;
+  }
+  if (preB === undefined) {
+    return -1;
+  }
+  return preA < preB ? -1 : 1;
+}
+
+async function showNamespaces() {
+  const counts = new Map();
+  for (const m of await allModules({})) {
+    counts.set(m.namespace, (counts.get(m.namespace) || 0) + 1);
+  }
+  render('', table(['Namespace', 'Modules'],
+    [...counts.entries()].map(([namespace, count]) => [link([namespace], namespace), count])));
+}
+
+function moduleRows(modules) {
+  return modules.map((m) => [
+    link([m.namespace, m.name, m.provider], `${m.namespace}/${m.name}/${m.provider}`),
+    m.version,
+    m.maturity || '',
+    published(m.published_at),
+  ]);
+}
+
+async function showSearch(query) {
+  const res = await api(`/search?${new URLSearchParams({ q: query, limit: '100' })}`);
+  render(`Search: ${query}`, table(['Module', 'Latest', 'Maturity', 'Published'], moduleRows(res.modules)));
+}
+
+async function showNamespace(namespace, name) {
+  const res = await api(`/${encodeURIComponent(namespace)}`);
+  let modules = res ? res.modules : [];
+  if (name) {
+    // The providers of a module are the modules of the namespace with the same name
+    modules = modules.filter((m) => m.name === name);
+  }
+  render(name ? `${namespace}/${name}` : namespace,
+    table(['Module', 'Latest', 'Maturity', 'Published'], moduleRows(modules)));
+}
+
+async function showModule(namespace, name, provider, selected) {
+  const address = [namespace, name, provider].map(encodeURIComponent).join('/');
+  const res = await api(`/${address}/versions`);
+  if (!res || !res.modules || !res.modules.length) {
+    render(`${namespace}/${name}/${provider}`, el('p', {}, 'This module has no versions.'));
+    return;
+  }
+
+  const versions = res.modules[0].versions.sort((a, b) => compareVersions(b.version, a.version));
+  const releases = versions.filter((v) => !v.version.includes('-'));
+  const version = selected || (releases[0] || versions[0]).version;
+  const current = versions.find((v) => v.version === version);
+
+  const readme = el('pre', { class: 'readme' }, 'Loading documentation…');
+  render(`${namespace}/${name}/${provider}`,
+    el('section', { class: 'module' },
+      el('div', {},
+        el('h2', {}, `Version ${version}`),
+        current && current.deprecated
+          ? el('p', { class: 'deprecated' }, `Deprecated${current.deprecation_reason ? `: ${current.deprecation_reason}` : ''}`)
+          : '',
+        source(namespace, name, provider, version),
+        readme),
+      el('nav', {},
+        el('h2', {}, 'Versions'),
+        el('ul', {}, ...versions.map((v) => el('li', {},
+          link([namespace, name, provider, v.version], v.version),
+          ' ', el('small', {}, [v.maturity, published(v.published_at)].filter(Boolean).join(', ')))))),
+    ));
+
+  const docs = await api(`/${address}/${encodeURIComponent(version)}/docs`);
+  readme.textContent = docs && docs.readme ? docs.readme : 'This version has no README.';
+  if (docs && docs.variables.length) {
+    readme.before(el('h3', {}, 'Inputs'), table(['Name', 'Type', 'Description', 'Default'],
+      docs.variables.map((v) => [el('code', {}, v.name), el('code', {}, v.type || 'any'), v.description || '',
+        v.required ? el('strong', {}, 'required') : el('code', {}, v.default || '')])));
+  }
+  if (docs && docs.outputs.length) {
+    readme.before(el('h3', {}, 'Outputs'), table(['Name', 'Description'],
+      docs.outputs.map((o) => [el('code', {}, o.name), o.description || ''])));
+  }
+  if (docs && (docs.required_version || docs.required_providers.length)) {
+    const requirements = docs.required_providers.map((p) => [el('code', {}, p.source || p.name), el('code', {}, p.version || '')]);
+    if (docs.required_version) {
+      requirements.unshift([el('code', {}, 'terraform'), el('code', {}, docs.required_version)]);
+    }
+    readme.before(el('h3', {}, 'Requirements'), table(['Name', 'Version'], requirements));
+  }
+}
+
+async function route() {
+  const parts = location.hash.replace(/^#\/?/, '').split('/').filter(Boolean).map(decodeURIComponent);
+  logout.hidden = !sessionStorage.getItem(tokenKey);
+  login.hidden = true;
+
+  try {
+    if (parts[0] === 'search' && parts.length === 2) {
+      await showSearch(parts[1]);
+    } else if (parts.length === 0) {
+      await showNamespaces();
+    } else if (parts.length <= 2) {
+      await showNamespace(parts[0], parts[1]);
+    } else {
+      await showModule(parts[0], parts[1], parts[2], parts[3]);
+    }
+  } catch (err) {
+    if (err instanceof UnauthorizedError) {
+      content.replaceChildren();
+      login.hidden = false;
+      login.elements.token.focus();
+      return;
+    }
+    render('Error', el('p', { class: 'error' }, err.message));
+  }
+}
+
+login.addEventListener('submit', (event) => {
+  event.preventDefault();
+  sessionStorage.setItem(tokenKey, login.elements.token.value);
+  login.reset();
+  route();
+});
+
+logout.addEventListener('click', () => {
+  sessionStorage.removeItem(tokenKey);
+  route();
+});
+
+search.addEventListener('submit', (event) => {
+  event.preventDefault();
+  const query = search.elements.q.value.trim();
+  if (query) {
+    location.hash = `#/search/${encodeURIComponent(query)}`;
+  }
+});
+
+window.addEventListener('hashchange', route);
+route();
@@ -0,0 +1,54 @@
+package ui
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name         string
+		path         string
+		expectedCode int
+		expectedType string
+	}{
+		{
+			name:         "index",
+			path:         "/",
+			expectedCode: http.StatusOK,
+			expectedType: "text/html; charset=utf-8",
+		},
+		{
+			name:         "script",
+			path:         "/app.js",
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "missing file",
+			path:         "/missing.js",
+			expectedCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+			assert.Equal(t, tc.expectedCode, rec.Code)
+			assert.Equal(t, contentSecurityPolicy, rec.Header().Get("Content-Security-Policy"))
+			assert.Equal(t, "nosniff", rec.Header().Get("X-Content-Type-Options"))
+			if tc.expectedType != "" {
+				assert.Equal(t, tc.expectedType, rec.Header().Get("Content-Type"))
+			}
+		})
+	}
+}